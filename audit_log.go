@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Create the "fetch audit-log" command, whose subcommands work with the audit logs written with --audit-log
+func createAuditLogCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "audit-log",
+		Usage: "Work with the audit logs written with --audit-log.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "verify",
+				Usage:     "Check that no record of an audit log was modified, removed, added, or reordered since it was written.",
+				UsageText: "fetch audit-log verify <audit-log>",
+				Action:    runAuditLogVerifyWrapper,
+			},
+		},
+	}
+}
+
+func runAuditLogVerifyWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	exitOnError(context.Background(), logger, runAuditLogVerify(c, logger))
+	return nil
+}
+
+// Run the "fetch audit-log verify" command, which prints the hash of the last record, so it can be kept elsewhere to
+// check later that no records were removed from the end of the log
+func runAuditLogVerify(c *cli.Context, logger *logrus.Entry) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("Missing required argument specifying the audit log to verify. Run \"fetch audit-log verify --help\" for full usage info.")
+	}
+	path := c.Args().First()
+
+	count, lastHash, err := fetch.VerifyAuditLog(path)
+	if err != nil {
+		return err
+	}
+	logger.Infof("All %d records of the audit log %s are intact\n", count, path)
+	if lastHash != "" {
+		fmt.Fprintln(c.App.Writer, lastHash)
+	}
+	return nil
+}
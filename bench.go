@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionIterations = "iterations"
+
+// Create the "fetch bench" command, which runs the same fetch several times with each combination of the concurrency
+// settings it's given, and reports how long resolving, downloading, and extracting took, to help tune those settings
+func createBenchCommand() *cli.Command {
+	flags := fetchFlagsNamed(
+		optionRepo,
+		optionSource,
+		optionRef,
+		optionCommit,
+		optionBranch,
+		optionTag,
+		optionLooseSemver,
+		optionSourcePath,
+		optionDownloadStrategy,
+		optionReleaseAsset,
+		optionAutoAsset,
+		optionOS,
+		optionArch,
+		optionAllReleaseAssets,
+		optionGithubToken,
+		optionTokenCommand,
+		optionGithubTokenFile,
+		optionCredentialFallback,
+		optionArtifactRepoUsername,
+		optionArtifactRepoPassword,
+		optionArtifactRepoApiKey,
+		optionGitlabToken,
+		optionGithubAPIVersion,
+		optionGhesVersion,
+	)
+	flags = append(flags,
+		&cli.IntFlag{
+			Name:     optionIterations,
+			Category: flagCategoryPerformance,
+			Value:    3,
+			Usage:    "How many times to run the fetch with each combination of --max-concurrent-downloads and\n\t--download-connections. The report gives the minimum, median, and maximum of the runs.",
+		},
+		&cli.IntSliceFlag{
+			Name:     optionMaxConcurrentDownloads,
+			Category: flagCategoryPerformance,
+			Value:    cli.NewIntSlice(fetch.DefaultMaxConcurrentDownloads),
+			Usage:    "The maximum number of release assets to download at once. Can be specified more than once to\n\tcompare several values.",
+		},
+		&cli.IntSliceFlag{
+			Name:     optionDownloadConnections,
+			Category: flagCategoryPerformance,
+			Value:    cli.NewIntSlice(1),
+			Usage:    "How many connections to download each release asset of 8MB or more over. Can be specified more\n\tthan once to compare several values.",
+		},
+	)
+	flags = append(flags, connectionFlags()...)
+
+	return &cli.Command{
+		Name:      "bench",
+		Usage:     "Measure how long resolving the tag, downloading, and extracting take for a repo or release asset, with each of the given concurrency settings, and print a JSON report.",
+		UsageText: "fetch bench --repo <repo> [--tag <tag>] [--source-path <path> | --release-asset <name>] [--iterations <n>] [--max-concurrent-downloads <n>...] [--download-connections <n>...] [options]",
+		Action:    runBenchWrapper,
+		Flags:     flags,
+	}
+}
+
+// The settings to run a benchmark with
+type benchOptions struct {
+	Fetch                  fetch.Options // What to fetch. The download path and concurrency settings are set for each run.
+	Iterations             int
+	MaxConcurrentDownloads []int
+	DownloadConnections    []int
+}
+
+// The report of a benchmark written by "fetch bench"
+type benchReport struct {
+	Repo       string        `json:"repo"`
+	Tag        string        `json:"tag,omitempty"`
+	Iterations int           `json:"iterations"`
+	Results    []benchResult `json:"results"`
+}
+
+// The runs of a benchmark with one combination of concurrency settings. Download times exclude extraction, and the
+// rates are in bytes per second of the median runs.
+type benchResult struct {
+	MaxConcurrentDownloads int        `json:"maxConcurrentDownloads"`
+	DownloadConnections    int        `json:"downloadConnections"`
+	Bytes                  int64      `json:"bytes"` // The size of the files written to the download path by each run
+	Resolve                benchStats `json:"resolve"`
+	Download               benchStats `json:"download"`
+	Extract                benchStats `json:"extract"`
+	DownloadRate           float64    `json:"downloadRate"`
+	ExtractRate            float64    `json:"extractRate,omitempty"`
+}
+
+// The minimum, median, and maximum of the durations of several runs, in seconds
+type benchStats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Max    float64 `json:"max"`
+}
+
+// The measurements of a single run of a benchmark
+type benchRun struct {
+	resolve  time.Duration
+	download time.Duration
+	extract  time.Duration
+	bytes    int64
+}
+
+func runBenchWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runBench(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch bench" command
+func runBench(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := parseBenchOptions(c, logger)
+	if err := validateBenchOptions(options); err != nil {
+		return err
+	}
+	if usesGithubToken(options.Fetch) {
+		token, err := resolveGithubToken(ctx, c, options.Fetch.RepoUrl, options.Fetch.GithubToken)
+		if err != nil {
+			return err
+		}
+		options.Fetch.GithubToken = token
+	}
+
+	report, err := bench(ctx, logger, options)
+	if err != nil {
+		return err
+	}
+	return writeBenchReport(c.App.Writer, report)
+}
+
+func parseBenchOptions(c *cli.Context, logger *logrus.Entry) benchOptions {
+	return benchOptions{
+		Fetch: fetch.Options{
+			RepoUrl:          c.String(optionRepo),
+			Source:           c.String(optionSource),
+			GitRef:           c.String(optionRef),
+			CommitSha:        c.String(optionCommit),
+			BranchName:       c.String(optionBranch),
+			TagConstraint:    c.String(optionTag),
+			LooseSemver:      c.Bool(optionLooseSemver),
+			SourcePaths:      c.StringSlice(optionSourcePath),
+			DownloadStrategy: c.String(optionDownloadStrategy),
+			ReleaseAsset:     c.String(optionReleaseAsset),
+			AutoAsset:        c.Bool(optionAutoAsset),
+			OS:               c.String(optionOS),
+			Arch:             c.String(optionArch),
+			AllReleaseAssets: c.Bool(optionAllReleaseAssets),
+			GithubToken:      c.String(optionGithubToken),
+			GithubApiVersion: c.String(optionGithubAPIVersion),
+			GhesVersion:      c.String(optionGhesVersion),
+			Connection:       parseConnectionOptions(c),
+			ArtifactRepoAuth: parseArtifactRepoAuth(c),
+			GitlabToken:      c.String(optionGitlabToken),
+			Logger:           logger,
+			Locale:           resolveLocale(c),
+		},
+		Iterations:             c.Int(optionIterations),
+		MaxConcurrentDownloads: c.IntSlice(optionMaxConcurrentDownloads),
+		DownloadConnections:    c.IntSlice(optionDownloadConnections),
+	}
+}
+
+func validateBenchOptions(options benchOptions) error {
+	if options.Fetch.RepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch bench --help\" for full usage info.", optionRepo)
+	}
+	if err := fetch.ValidateSource(options.Fetch.Source); err != nil {
+		return err
+	}
+	if options.Iterations < 1 {
+		return fmt.Errorf("The --%s flag must be at least 1. Run \"fetch bench --help\" for full usage info.", optionIterations)
+	}
+	for _, maxConcurrentDownloads := range options.MaxConcurrentDownloads {
+		if maxConcurrentDownloads < 1 {
+			return fmt.Errorf("Every --%s value must be at least 1. Run \"fetch bench --help\" for full usage info.", optionMaxConcurrentDownloads)
+		}
+	}
+	for _, connections := range options.DownloadConnections {
+		if connections < 1 {
+			return fmt.Errorf("Every --%s value must be at least 1. Run \"fetch bench --help\" for full usage info.", optionDownloadConnections)
+		}
+		if connections > 1 && !downloadsReleaseAssets(options.Fetch) {
+			return fmt.Errorf("The --%s flag can only be above 1 with --%s or --%s. Run \"fetch bench --help\" for full usage info.", optionDownloadConnections, optionReleaseAsset, optionAllReleaseAssets)
+		}
+	}
+	if _, err := fetch.ParseGhesVersion(options.Fetch.GhesVersion); err != nil {
+		return err
+	}
+	return validateConnectionOptions(options.Fetch.Connection)
+}
+
+// Run the fetch in options the given number of iterations with each combination of the concurrency settings, one run
+// at a time, each into a new temporary folder, and report how long each step took
+func bench(ctx context.Context, logger *logrus.Entry, options benchOptions) (*benchReport, error) {
+	workDir, err := ioutil.TempDir("", "fetch-bench")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	report := &benchReport{Repo: options.Fetch.RepoUrl, Iterations: options.Iterations, Results: []benchResult{}}
+	for _, maxConcurrentDownloads := range options.MaxConcurrentDownloads {
+		for _, connections := range options.DownloadConnections {
+			runOptions := options.Fetch
+			runOptions.MaxConcurrentDownloads = maxConcurrentDownloads
+			runOptions.DownloadConnections = connections
+
+			var runs []benchRun
+			for i := 1; i <= options.Iterations; i++ {
+				logger.Infof("Benchmark run %d of %d with --%s=%d and --%s=%d\n", i, options.Iterations, optionMaxConcurrentDownloads, maxConcurrentDownloads, optionDownloadConnections, connections)
+				runOptions.LocalDownloadPath = filepath.Join(workDir, fmt.Sprintf("%d-%d-%d", maxConcurrentDownloads, connections, i))
+				run, tag, err := benchFetch(ctx, runOptions)
+				if err != nil {
+					return nil, err
+				}
+				report.Tag = tag
+				runs = append(runs, run)
+			}
+			report.Results = append(report.Results, summarizeBenchRuns(maxConcurrentDownloads, connections, runs))
+		}
+	}
+	return report, nil
+}
+
+// Run the fetch in options once, then remove what it downloaded. Returns the measurements of the run and the tag it
+// resolved.
+func benchFetch(ctx context.Context, options fetch.Options) (benchRun, string, error) {
+	if err := os.MkdirAll(options.LocalDownloadPath, 0755); err != nil {
+		return benchRun{}, "", err
+	}
+	defer os.RemoveAll(options.LocalDownloadPath)
+
+	fetcher, err := fetch.NewFetcher(options)
+	if err != nil {
+		return benchRun{}, "", err
+	}
+	result, err := fetcher.Fetch(ctx, io.Discard)
+	if err != nil {
+		return benchRun{}, "", err
+	}
+	bytes, err := folderSize(options.LocalDownloadPath)
+	if err != nil {
+		return benchRun{}, "", err
+	}
+
+	run := benchRun{
+		resolve:  result.Timings.Resolve,
+		download: result.Timings.Download - result.Timings.Extract,
+		extract:  result.Timings.Extract,
+		bytes:    bytes,
+	}
+	return run, result.Tag, nil
+}
+
+// Return the total size of the files in the given folder and its subfolders
+func folderSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Summarize the given runs with the given concurrency settings, of which there must be at least one
+func summarizeBenchRuns(maxConcurrentDownloads int, connections int, runs []benchRun) benchResult {
+	result := benchResult{MaxConcurrentDownloads: maxConcurrentDownloads, DownloadConnections: connections}
+
+	var resolves, downloads, extracts []time.Duration
+	for _, run := range runs {
+		resolves = append(resolves, run.resolve)
+		downloads = append(downloads, run.download)
+		extracts = append(extracts, run.extract)
+		if run.bytes > result.Bytes {
+			result.Bytes = run.bytes
+		}
+	}
+	result.Resolve = newBenchStats(resolves)
+	result.Download = newBenchStats(downloads)
+	result.Extract = newBenchStats(extracts)
+
+	if result.Download.Median > 0 {
+		result.DownloadRate = float64(result.Bytes) / result.Download.Median
+	}
+	if result.Extract.Median > 0 {
+		result.ExtractRate = float64(result.Bytes) / result.Extract.Median
+	}
+	return result
+}
+
+// Return the minimum, median, and maximum of the given durations, of which there must be at least one. The median of an
+// even number of durations is the mean of the middle two.
+func newBenchStats(durations []time.Duration) benchStats {
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	middle := len(sorted) / 2
+	median := sorted[middle]
+	if len(sorted)%2 == 0 {
+		median = (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return benchStats{Min: sorted[0].Seconds(), Median: median.Seconds(), Max: sorted[len(sorted)-1].Seconds()}
+}
+
+// Write the given report to writer as JSON
+func writeBenchReport(writer io.Writer, report *benchReport) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestValidateBenchOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := benchOptions{
+		Fetch:                  fetch.Options{RepoUrl: "https://github.com/foo/bar", TagConstraint: "~>0.1.0", ReleaseAsset: "tool_.*"},
+		Iterations:             3,
+		MaxConcurrentDownloads: []int{1, 4},
+		DownloadConnections:    []int{1, 8},
+	}
+	assert.NoError(t, validateBenchOptions(valid))
+
+	noRepo := valid
+	noRepo.Fetch.RepoUrl = ""
+	assert.Error(t, validateBenchOptions(noRepo))
+
+	noIterations := valid
+	noIterations.Iterations = 0
+	assert.Error(t, validateBenchOptions(noIterations))
+
+	noConcurrency := valid
+	noConcurrency.MaxConcurrentDownloads = []int{4, 0}
+	assert.Error(t, validateBenchOptions(noConcurrency))
+
+	sourcePathConnections := valid
+	sourcePathConnections.Fetch.ReleaseAsset = ""
+	sourcePathConnections.Fetch.SourcePaths = []string{"/modules"}
+	assert.Error(t, validateBenchOptions(sourcePathConnections))
+	sourcePathConnections.DownloadConnections = []int{1}
+	assert.NoError(t, validateBenchOptions(sourcePathConnections))
+}
+
+func TestBenchCommandParsesConcurrencySettings(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                           string
+		args                           []string
+		expectedMaxConcurrentDownloads []int
+		expectedDownloadConnections    []int
+	}{
+		{"defaults", nil, []int{fetch.DefaultMaxConcurrentDownloads}, []int{1}},
+		{"several", []string{"--max-concurrent-downloads", "1", "--max-concurrent-downloads", "8", "--download-connections", "4"}, []int{1, 8}, []int{4}},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var options benchOptions
+			app := CreateFetchCli(VERSION, nil, nil)
+			for i := range app.Commands {
+				if app.Commands[i].Name == "bench" {
+					app.Commands[i].Action = func(c *cli.Context) error {
+						options = parseBenchOptions(c, fetch.GetProjectLogger())
+						return nil
+					}
+				}
+			}
+
+			args := append([]string{"fetch", "bench", "--repo", "https://github.com/foo/bar", "--release-asset", "tool_.*"}, tc.args...)
+			require.NoError(t, app.Run(args))
+			assert.Equal(t, 3, options.Iterations)
+			assert.Equal(t, tc.expectedMaxConcurrentDownloads, options.MaxConcurrentDownloads)
+			assert.Equal(t, tc.expectedDownloadConnections, options.DownloadConnections)
+		})
+	}
+}
+
+func TestSummarizeBenchRuns(t *testing.T) {
+	t.Parallel()
+
+	runs := []benchRun{
+		{resolve: 300 * time.Millisecond, download: 4 * time.Second, bytes: 8000},
+		{resolve: 100 * time.Millisecond, download: 1 * time.Second, bytes: 8000},
+		{resolve: 200 * time.Millisecond, download: 2 * time.Second, bytes: 8000},
+	}
+	assert.Equal(t, benchResult{
+		MaxConcurrentDownloads: 4,
+		DownloadConnections:    2,
+		Bytes:                  8000,
+		Resolve:                benchStats{Min: 0.1, Median: 0.2, Max: 0.3},
+		Download:               benchStats{Min: 1, Median: 2, Max: 4},
+		DownloadRate:           4000,
+	}, summarizeBenchRuns(4, 2, runs))
+
+	// The median of an even number of runs is the mean of the middle two, and extraction gets a rate when it took time
+	runs = []benchRun{
+		{download: 1 * time.Second, extract: 1 * time.Second, bytes: 1000},
+		{download: 3 * time.Second, extract: 3 * time.Second, bytes: 1000},
+	}
+	result := summarizeBenchRuns(1, 1, runs)
+	assert.Equal(t, benchStats{Min: 1, Median: 2, Max: 3}, result.Download)
+	assert.Equal(t, 500.0, result.DownloadRate)
+	assert.Equal(t, 500.0, result.ExtractRate)
+}
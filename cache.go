@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionCacheDir = "cache-dir"
+const optionNoCache = "no-cache"
+
+const envVarCacheDir = "FETCH_CACHE_DIR"
+
+// Create the "fetch cache" command, whose subcommands manage the cache of repo archives and release assets
+func createCacheCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Manage the cache of repo archives and release assets in --cache-dir.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "purge",
+				Usage:     "Delete the cache directory and everything cached in it.",
+				UsageText: "fetch cache purge [options]",
+				Action:    runCachePurgeWrapper,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    optionCacheDir,
+						Usage:   "The cache directory to purge. Defaults to the one fetch uses by default, e.g. ~/.cache/fetch on Linux.",
+						EnvVars: []string{envVarCacheDir},
+					},
+					&cli.StringFlag{
+						Name:    optionArchiveCacheDir,
+						Hidden:  true,
+						EnvVars: []string{envVarArchiveCacheDir},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Return the directory to cache repo archives and release assets in: the one set with --cache-dir (or its deprecated
+// name, --archive-cache-dir), or else the default one. Returns an empty string, which turns caching off, with
+// --no-cache, or if there's no default cache directory (e.g. because $HOME isn't set).
+func cacheDir(c *cli.Context, logger *logrus.Entry) string {
+	if boolFlagOrDefault(c, optionNoCache, false) {
+		return ""
+	}
+	if c.IsSet(optionCacheDir) {
+		return c.String(optionCacheDir)
+	}
+	if c.IsSet(optionArchiveCacheDir) {
+		return c.String(optionArchiveCacheDir)
+	}
+
+	dir, err := fetch.DefaultCacheDir()
+	if err != nil {
+		logger.Debugf("Not caching anything, as there's no default cache directory: %s\n", err)
+		return ""
+	}
+	return dir
+}
+
+func runCachePurgeWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	exitOnError(context.Background(), logger, runCachePurge(c, logger))
+	return nil
+}
+
+// Run the "fetch cache purge" command
+func runCachePurge(c *cli.Context, logger *logrus.Entry) error {
+	dir := cacheDir(c, logger)
+	if dir == "" {
+		return fmt.Errorf("There's no default cache directory to purge. Use --%s to set the one to purge.", optionCacheDir)
+	}
+
+	size, err := fetch.PurgeCache(dir)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Purged %s of cached files from %s\n", humanize.Bytes(uint64(size)), dir)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDir(t *testing.T) {
+	t.Parallel()
+
+	logger := fetch.GetProjectLogger()
+
+	defaultDir, err := fetch.DefaultCacheDir()
+	require.NoError(t, err)
+
+	assert.Equal(t, defaultDir, cacheDir(newTestCliContext(t), logger))
+	assert.Equal(t, "/tmp/cache", cacheDir(newTestCliContext(t, "--cache-dir=/tmp/cache"), logger))
+	assert.Equal(t, "/tmp/cache", cacheDir(newTestCliContext(t, "--archive-cache-dir=/tmp/cache"), logger))
+	assert.Equal(t, "", cacheDir(newTestCliContext(t, "--no-cache"), logger))
+	assert.Equal(t, "", cacheDir(newTestCliContext(t, "--cache-dir=/tmp/cache", "--no-cache"), logger))
+	assert.Equal(t, defaultDir, cacheDir(newTestCliContext(t, "--no-cache=false"), logger))
+}
+
+func TestDefaultLinkModeDoesNotShareContentsWithTheCache(t *testing.T) {
+	t.Parallel()
+
+	// The cache is on by default, so by default a downloaded release asset must not be a link that modifying it in place
+	// would change the cached copy through
+	assert.Equal(t, fetch.LinkModeReflink, newTestCliContext(t).String(optionLinkMode))
+}
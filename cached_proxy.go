@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionListen = "listen"
+const optionUpstream = "upstream"
+
+// Create the "fetch cached-proxy" command, which serves verified release assets from a cache shared by every client of
+// a long-running local HTTP service, such as all the CI jobs on a host
+func createCachedProxyCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  optionListen,
+			Value: "127.0.0.1:8080",
+			Usage: "The address to serve release assets at. Clients send their GitHub tokens to it, so only listen on\n\tother interfaces behind TLS or on a trusted network.",
+		},
+		&cli.StringFlag{
+			Name:  optionUpstream,
+			Value: "https://github.com",
+			Usage: "The URL of the GitHub instance to fetch release assets from, e.g. a GitHub Enterprise Server instance.",
+		},
+		&cli.StringFlag{
+			Name:    optionCacheDir,
+			Usage:   "The directory to cache release assets in. Defaults to the one fetch uses by default, e.g. ~/.cache/fetch on Linux.",
+			EnvVars: []string{envVarCacheDir},
+		},
+	}
+	// There's deliberately no --github-oauth-token: each request is fetched with the token it was sent with
+	flags = append(flags, fetchFlagsNamed(
+		optionReleaseAssetChecksumAlgo,
+		optionReleaseAssetChecksumFile,
+		optionUpgradeWeakChecksums,
+		optionVerifyWithRepoKey,
+		optionCosignVerify,
+		optionCosignCertificateIdentity,
+		optionCosignCertificateOidcIssuer,
+		optionCosignFulcioRoot,
+		optionCosignRekorPublicKey,
+		optionCosignRekorUrl,
+		optionPackageSigningKey,
+		optionGithubAPIVersion,
+		optionGhesVersion,
+		optionDownloadConnections,
+	)...)
+	flags = append(flags, connectionFlags()...)
+
+	return &cli.Command{
+		Name:      "cached-proxy",
+		Usage:     "Serve verified release assets from the cache over HTTP to many clients, such as the CI jobs on a host, downloading them into the cache on a miss.",
+		UsageText: "fetch cached-proxy [--listen <address>] [--release-asset-checksum-file <file> | --verify-with-repo-key | --cosign-verify] [options]",
+		Action:    runCachedProxyWrapper,
+		Flags:     flags,
+	}
+}
+
+func runCachedProxyWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runCachedProxy(ctx, c, logger)
+	// Stopping the proxy with Ctrl-C or SIGTERM is how it's meant to be stopped, so it's not reported as an interruption
+	if ctx.Err() != nil && err == nil {
+		logger.Infof("Stopped serving release assets.\n")
+		return nil
+	}
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch cached-proxy" command
+func runCachedProxy(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := parseCachedProxyOptions(c, logger)
+	if err := validateCachedProxyOptions(options); err != nil {
+		return err
+	}
+
+	proxy, err := fetch.NewCachedProxy(logger, options)
+	if err != nil {
+		return err
+	}
+	return fetch.ServeCachedProxy(ctx, logger, c.String(optionListen), proxy)
+}
+
+func parseCachedProxyOptions(c *cli.Context, logger *logrus.Entry) fetch.CachedProxyOptions {
+	return fetch.CachedProxyOptions{
+		Base: fetch.Options{
+			GithubApiVersion:         c.String(optionGithubAPIVersion),
+			GhesVersion:              c.String(optionGhesVersion),
+			Connection:               parseConnectionOptions(c),
+			Locale:                   resolveLocale(c),
+			ReleaseAssetChecksumAlgo: c.String(optionReleaseAssetChecksumAlgo),
+			ReleaseAssetChecksumFile: c.String(optionReleaseAssetChecksumFile),
+			UpgradeWeakChecksums:     c.Bool(optionUpgradeWeakChecksums),
+			VerifyWithRepoKey:        c.Bool(optionVerifyWithRepoKey),
+			CosignVerify:             c.Bool(optionCosignVerify),
+			CosignVerifyOptions: fetch.CosignVerifyOptions{
+				CertificateIdentity:   c.String(optionCosignCertificateIdentity),
+				CertificateOidcIssuer: c.String(optionCosignCertificateOidcIssuer),
+				FulcioRootPath:        c.String(optionCosignFulcioRoot),
+				RekorPublicKeyPath:    c.String(optionCosignRekorPublicKey),
+				RekorUrl:              c.String(optionCosignRekorUrl),
+			},
+			PackageSigningKey:   c.String(optionPackageSigningKey),
+			DownloadConnections: c.Int(optionDownloadConnections),
+			ArchiveCacheDir:     cacheDir(c, logger),
+		},
+		UpstreamUrl: c.String(optionUpstream),
+	}
+}
+
+func validateCachedProxyOptions(options fetch.CachedProxyOptions) error {
+	if options.Base.ArchiveCacheDir == "" {
+		return fmt.Errorf("There's no default cache directory to serve release assets from. Use --%s to set one.", optionCacheDir)
+	}
+	if options.Base.CosignVerify && (options.Base.CosignVerifyOptions.CertificateIdentity == "" || options.Base.CosignVerifyOptions.CertificateOidcIssuer == "") {
+		return fmt.Errorf("The --%s flag requires both --%s and --%s to be set. Run \"fetch cached-proxy --help\" for full usage info.", optionCosignVerify, optionCosignCertificateIdentity, optionCosignCertificateOidcIssuer)
+	}
+	if options.Base.PackageSigningKey != "" {
+		if err := fetch.ValidatePackageSigningKey(options.Base.PackageSigningKey); err != nil {
+			return err
+		}
+	}
+	if _, err := fetch.ParseGhesVersion(options.Base.GhesVersion); err != nil {
+		return err
+	}
+	if err := validateConnectionOptions(options.Base.Connection); err != nil {
+		return err
+	}
+	return fetch.ValidateCachedProxyOptions(options)
+}
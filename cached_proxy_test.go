@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCachedProxyOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.CachedProxyOptions{
+		Base:        fetch.Options{ArchiveCacheDir: "/tmp/fetch-cache", VerifyWithRepoKey: true},
+		UpstreamUrl: "https://github.com",
+	}
+	assert.NoError(t, validateCachedProxyOptions(valid))
+
+	noCache := valid
+	noCache.Base.ArchiveCacheDir = ""
+	assert.Error(t, validateCachedProxyOptions(noCache))
+
+	badUpstream := valid
+	badUpstream.UpstreamUrl = "github.com"
+	assert.Error(t, validateCachedProxyOptions(badUpstream))
+
+	cosignWithoutIdentity := valid
+	cosignWithoutIdentity.Base.CosignVerify = true
+	assert.Error(t, validateCachedProxyOptions(cosignWithoutIdentity))
+
+	badProxy := valid
+	badProxy.Base.Connection.Proxy = "ftp://proxy.example.com"
+	assert.Error(t, validateCachedProxyOptions(badProxy))
+}
+
+func TestCachedProxyTakesNoGithubToken(t *testing.T) {
+	t.Parallel()
+
+	for _, flag := range createCachedProxyCommand().Flags {
+		assert.NotEqual(t, optionGithubToken, flag.Names()[0])
+	}
+}
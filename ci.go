@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const optionProfile = "profile"
+const optionLogFormat = "log-format"
+
+const envVarProfile = "FETCH_PROFILE"
+
+// The profiles of defaults fetch can run with
+const (
+	profileAuto = "auto" // Use the CI profile if a CI system is detected
+	profileCi   = "ci"
+	profileNone = "none"
+)
+
+// The formats fetch can write logs in
+const (
+	logFormatText    = "text"
+	logFormatJson    = "json"
+	logFormatConsole = "console" // Aligned, optionally colored columns, for reading in a terminal
+)
+
+// Environment variables that common CI systems set in every build. Most set CI, but not all of them do.
+var ciEnvVars = []string{
+	"CI",
+	"GITHUB_ACTIONS",
+	"GITLAB_CI",
+	"CIRCLECI",
+	"BUILDKITE",
+	"JENKINS_URL",
+	"TF_BUILD",
+	"TEAMCITY_VERSION",
+	"CODEBUILD_BUILD_ID",
+	"BITBUCKET_BUILD_NUMBER",
+}
+
+// Return the first of ciEnvVars that is set in the environment, as read with getenv, or an empty string if none is.
+// Values such as CI=false, which some people set to turn off CI behavior locally, don't count.
+func detectCi(getenv func(string) string) string {
+	for _, envVar := range ciEnvVars {
+		value := strings.ToLower(strings.TrimSpace(getenv(envVar)))
+		if value != "" && value != "false" && value != "0" {
+			return envVar
+		}
+	}
+	return ""
+}
+
+// Return whether the given profile means the CI profile applies, detecting a CI system with getenv for profileAuto. If
+// it was detected, also return the environment variable that gave it away.
+func resolveCiProfile(profile string, getenv func(string) string) (bool, string, error) {
+	switch profile {
+	case profileAuto:
+		envVar := detectCi(getenv)
+		return envVar != "", envVar, nil
+	case profileCi:
+		return true, "", nil
+	case profileNone:
+		return false, "", nil
+	default:
+		return false, "", fmt.Errorf("Unknown --%s \"%s\". Must be one of: %s, %s, %s.", optionProfile, profile, profileAuto, profileCi, profileNone)
+	}
+}
+
+func validateLogFormat(format string) error {
+	if format != logFormatText && format != logFormatJson && format != logFormatConsole {
+		return fmt.Errorf("Unknown --%s \"%s\". Must be one of: %s, %s, %s.", optionLogFormat, format, logFormatConsole, logFormatJson, logFormatText)
+	}
+	return nil
+}
+
+// Return the log format to use: the one set with --log-format, or else JSON for the CI profile and text otherwise
+func logFormat(c *cli.Context, ci bool) string {
+	if c.IsSet(optionLogFormat) || !ci {
+		return c.String(optionLogFormat)
+	}
+	return logFormatJson
+}
+
+// Return the value of the given bool flag if it was set explicitly (including to false, as in --flag=false), or else
+// the given default from the profile
+func boolFlagOrDefault(c *cli.Context, name string, profileDefault bool) bool {
+	if c.IsSet(name) {
+		return c.Bool(name)
+	}
+	return profileDefault
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// Return a getenv function that reads from the given map instead of the real environment
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestDetectCi(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"CI": "true"}, "CI"},
+		{map[string]string{"CI": "1"}, "CI"},
+		{map[string]string{"CI": "false"}, ""},
+		{map[string]string{"CI": "0"}, ""},
+		{map[string]string{"GITHUB_ACTIONS": "true"}, "GITHUB_ACTIONS"},
+		{map[string]string{"JENKINS_URL": "https://jenkins.example.com"}, "JENKINS_URL"},
+		{map[string]string{"CI": "false", "BUILDKITE": "true"}, "BUILDKITE"},
+		{map[string]string{"HOME": "/root"}, ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, detectCi(fakeEnv(tc.env)))
+		})
+	}
+}
+
+func TestResolveCiProfile(t *testing.T) {
+	t.Parallel()
+
+	inCi := fakeEnv(map[string]string{"GITLAB_CI": "true"})
+	notInCi := fakeEnv(map[string]string{})
+
+	ci, envVar, err := resolveCiProfile(profileAuto, inCi)
+	require.NoError(t, err)
+	assert.True(t, ci)
+	assert.Equal(t, "GITLAB_CI", envVar)
+
+	ci, _, err = resolveCiProfile(profileAuto, notInCi)
+	require.NoError(t, err)
+	assert.False(t, ci)
+
+	ci, envVar, err = resolveCiProfile(profileCi, notInCi)
+	require.NoError(t, err)
+	assert.True(t, ci)
+	assert.Empty(t, envVar)
+
+	ci, _, err = resolveCiProfile(profileNone, inCi)
+	require.NoError(t, err)
+	assert.False(t, ci)
+
+	_, _, err = resolveCiProfile("github", inCi)
+	assert.Error(t, err)
+}
+
+func TestValidateLogFormat(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateLogFormat(logFormatText))
+	assert.NoError(t, validateLogFormat(logFormatJson))
+	assert.NoError(t, validateLogFormat(logFormatConsole))
+	assert.Error(t, validateLogFormat("logfmt"))
+}
+
+// Return a cli.Context for the fetch CLI, parsed from the given args
+func newTestCliContext(t *testing.T, args ...string) *cli.Context {
+	app := CreateFetchCli(VERSION, nil, nil)
+	set := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	for _, appFlag := range app.Flags {
+		appFlag.Apply(set)
+	}
+	require.NoError(t, set.Parse(args))
+	return cli.NewContext(app, set, nil)
+}
+
+func TestProfileDefaultsAreOverriddenByFlags(t *testing.T) {
+	t.Parallel()
+
+	noFlags := newTestCliContext(t)
+	assert.True(t, boolFlagOrDefault(noFlags, optionWaitForRateLimit, true))
+	assert.False(t, boolFlagOrDefault(noFlags, optionWaitForRateLimit, false))
+	assert.Equal(t, logFormatJson, logFormat(noFlags, true))
+	assert.Equal(t, logFormatText, logFormat(noFlags, false))
+
+	withFlags := newTestCliContext(t, "--"+optionWaitForRateLimit+"=false", "--"+optionLogFormat+"="+logFormatText)
+	assert.False(t, boolFlagOrDefault(withFlags, optionWaitForRateLimit, true))
+	assert.Equal(t, logFormatText, logFormat(withFlags, true))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const optionConfig = "config"
+
+// The config file defaults are read from if --config isn't set, in the home directory
+const defaultConfigFile = ".fetchrc"
+
+// The key in the App's Metadata of the set of the names of the options applyConfigFile set
+const configFileOptionsKey = "configFileOptions"
+
+// Return the path of the config file to read defaults from, and whether it must exist: the one given with --config (or
+// its env var), or else ~/.fetchrc, if the home directory is known
+func configFilePath(c *cli.Context) (string, bool) {
+	if path := c.String(optionConfig); path != "" {
+		return path, true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(home, defaultConfigFile), false
+}
+
+// Read the YAML config file at the given path, which maps the names of flags to their values, e.g. "log-level: debug".
+// A flag that can be given more than once takes a list. Return the values of each flag as strings, as they would be
+// given on the command line.
+func readConfigFile(path string) (map[string][]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(contents, &settings); err != nil {
+		return nil, fmt.Errorf("Could not parse the config file %s: %s", path, err)
+	}
+
+	knownFlags := map[string]bool{}
+	for _, flag := range fetchFlags() {
+		for _, name := range flag.Names() {
+			knownFlags[name] = true
+		}
+	}
+
+	values := map[string][]string{}
+	for name, setting := range settings {
+		if !knownFlags[name] || name == optionConfig {
+			return nil, fmt.Errorf("Unknown option \"%s\" in the config file %s. Use the name of a flag without the leading --, e.g. %s.", name, path, optionLogLevel)
+		}
+		switch setting := setting.(type) {
+		case []interface{}:
+			for _, value := range setting {
+				values[name] = append(values[name], fmt.Sprint(value))
+			}
+		case map[string]interface{}:
+			return nil, fmt.Errorf("The value of \"%s\" in the config file %s must be a single value or a list.", name, path)
+		case nil:
+		default:
+			values[name] = []string{fmt.Sprint(setting)}
+		}
+	}
+	return values, nil
+}
+
+// Set each flag in the config file that the given command takes, and that wasn't set with a flag or env var, to its
+// value in the config file. Flags take precedence over env vars, which take precedence over the config file.
+func applyConfigFile(c *cli.Context) error {
+	path, required := configFilePath(c)
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !required {
+		return nil
+	}
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Apply the settings in a stable order, so that errors don't depend on the order of the map
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fromConfigFile := map[string]bool{}
+	for _, name := range names {
+		if !commandHasFlag(c, name) || c.IsSet(name) {
+			continue
+		}
+		for _, value := range values[name] {
+			if err := c.Set(name, value); err != nil {
+				return fmt.Errorf("Invalid value \"%s\" for \"%s\" in the config file %s: %s", value, name, path, err)
+			}
+		}
+		fromConfigFile[name] = true
+	}
+
+	// Setting an option makes c.IsSet true for it, just as if it had been given as a flag, so keep track of which ones
+	// were only defaults from the config file
+	if c.App.Metadata == nil {
+		c.App.Metadata = map[string]interface{}{}
+	}
+	c.App.Metadata[configFileOptionsKey] = fromConfigFile
+	return nil
+}
+
+// Return true if the option with the given name was set by a flag or env var, rather than only defaulted in the config
+// file
+func isSetExplicitly(c *cli.Context, name string) bool {
+	if !c.IsSet(name) {
+		return false
+	}
+	fromConfigFile, _ := c.App.Metadata[configFileOptionsKey].(map[string]bool)
+	return !fromConfigFile[name]
+}
+
+// Return true if the command of the given context takes the flag with the given name
+func commandHasFlag(c *cli.Context, name string) bool {
+	flags := c.App.Flags
+	if c.Command != nil {
+		flags = c.Command.Flags
+	}
+	for _, flag := range flags {
+		for _, flagName := range flag.Names() {
+			if flagName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
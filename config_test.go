@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// Write a config file with the given contents, and return its path
+func writeTestConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "fetchrc.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeTestConfigFile(t, `
+log-level: debug
+max-concurrent-downloads: 8
+unpack: true
+source-path:
+  - /modules/a
+  - /modules/b
+github-api-version:
+`)
+	values, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		optionLogLevel:               {"debug"},
+		optionMaxConcurrentDownloads: {"8"},
+		optionUnpack:                 {"true"},
+		optionSourcePath:             {"/modules/a", "/modules/b"},
+	}, values)
+}
+
+func TestReadConfigFileRejectsInvalidSettings(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		contents string
+	}{
+		{"unknown-option", "log-levle: debug\n"},
+		{"config", "config: /etc/fetchrc\n"},
+		{"map", "log-level:\n  name: debug\n"},
+		{"not-yaml", "log-level: [debug\n"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := readConfigFile(writeTestConfigFile(t, tc.contents))
+			assert.Error(t, err)
+		})
+	}
+}
+
+// Env vars are process-wide, so this test can't run in parallel with others
+func TestConfigFileDefaultsAreOverriddenByEnvVarsAndFlags(t *testing.T) {
+	t.Setenv("FETCH_CONFIG", writeTestConfigFile(t, `
+repo: https://github.com/foo/bar
+tag: "~>0.1.0"
+github-api-version: v4
+max-concurrent-downloads: 8
+source-path: [/modules/a, /modules/b]
+`))
+	t.Setenv("FETCH_TAG", "~>0.2.0")
+
+	var options fetch.Options
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Before = applyConfigFile
+	app.Action = func(c *cli.Context) error {
+		options = parseOptions(c, fetch.GetProjectLogger())
+		return nil
+	}
+
+	require.NoError(t, app.Run([]string{"fetch", "--github-api-version=v3", "/tmp/bar"}))
+	assert.Equal(t, "https://github.com/foo/bar", options.RepoUrl)
+	assert.Equal(t, "~>0.2.0", options.TagConstraint)
+	assert.Equal(t, "v3", options.GithubApiVersion)
+	assert.Equal(t, 8, options.MaxConcurrentDownloads)
+	assert.Equal(t, []string{"/modules/a", "/modules/b"}, options.SourcePaths)
+}
+
+// Env vars are process-wide, so this test can't run in parallel with others
+func TestMissingConfigFileIsOnlyAnErrorIfGiven(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Before = applyConfigFile
+	app.Action = func(c *cli.Context) error { return nil }
+
+	assert.NoError(t, app.Run([]string{"fetch"}))
+	assert.Error(t, app.Run([]string{"fetch", "--config", filepath.Join(t.TempDir(), "missing.yaml")}))
+}
+
+// Env vars are process-wide, so this test can't run in parallel with others
+func TestConfigFileDefaultsDontConflictWithQuiet(t *testing.T) {
+	testCases := []struct {
+		name             string
+		config           string
+		args             []string
+		expectedLevel    logrus.Level
+		expectedProgress bool
+		expectError      bool
+	}{
+		{"log-level-in-config", "log-level: debug\n", []string{"--quiet"}, logrus.ErrorLevel, false, false},
+		{"progress-in-config", "progress: true\n", []string{"--quiet"}, logrus.ErrorLevel, false, false},
+		{"quiet-in-config", "quiet: true\n", []string{"--log-level=debug"}, logrus.DebugLevel, false, false},
+		{"quiet-in-config-with-progress", "quiet: true\n", []string{"--progress"}, logrus.InfoLevel, true, false},
+		{"all-in-config", "quiet: true\nlog-level: debug\nprogress: true\n", nil, logrus.ErrorLevel, false, false},
+		{"flags-still-conflict", "log-level: debug\n", []string{"--quiet", "--progress"}, logrus.ErrorLevel, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("FETCH_CONFIG", writeTestConfigFile(t, tc.config))
+
+			var level logrus.Level
+			var progress bool
+			app := CreateFetchCli(VERSION, nil, nil)
+			app.Before = func(c *cli.Context) error {
+				if err := applyConfigFile(c); err != nil {
+					return err
+				}
+				var err error
+				level, err = resolveLogLevel(c)
+				return err
+			}
+			app.Action = func(c *cli.Context) error {
+				progress = c.Bool(optionWithProgress)
+				return nil
+			}
+
+			err := app.Run(append([]string{"fetch"}, tc.args...))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLevel, level)
+			assert.Equal(t, tc.expectedProgress, progress)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/urfave/cli/v2"
+)
+
+const optionProxy = "proxy"
+const optionCaCert = "ca-cert"
+const optionClientCert = "client-cert"
+const optionClientKey = "client-key"
+const optionInsecureSkipTlsVerify = "insecure-skip-tls-verify"
+const optionAuthScheme = "auth-scheme"
+
+// Return the flags for how fetch connects to servers, which every command that calls GitHub takes
+func connectionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     optionAuthScheme,
+			Category: flagCategoryAuth,
+			Value:    fetch.AuthSchemeAuto,
+			Usage:    "How the GitHub token is sent: \"token\", \"bearer\", or \"auto\", which uses bearer for fine-grained\n\tpersonal access tokens, OAuth tokens, and GitHub App tokens, and token for classic personal access tokens.",
+		},
+		&cli.StringFlag{
+			Name:     optionProxy,
+			Category: flagCategoryNetwork,
+			Usage:    "Send every request through this proxy (e.g. http://proxy.example.com:3128 or socks5://127.0.0.1:1080),\n\toverriding the HTTPS_PROXY and HTTP_PROXY env vars. Hosts in the NO_PROXY env var still bypass it.",
+		},
+		&cli.StringFlag{
+			Name:     optionCaCert,
+			Category: flagCategoryNetwork,
+			Usage:    "The path of a PEM bundle of CA certificates to trust, in addition to the system's, e.g. for a GitHub\n\tEnterprise Server instance with a certificate signed by an internal CA.",
+		},
+		&cli.StringFlag{
+			Name:     optionClientCert,
+			Category: flagCategoryNetwork,
+			Usage:    "The path of a PEM client certificate to present to servers that require mutual TLS. Requires --client-key.",
+		},
+		&cli.StringFlag{
+			Name:     optionClientKey,
+			Category: flagCategoryNetwork,
+			Usage:    "The path of the PEM private key of --client-cert.",
+		},
+		&cli.BoolFlag{
+			Name:     optionInsecureSkipTlsVerify,
+			Category: flagCategoryNetwork,
+			Usage:    "Don't verify the TLS certificates of servers at all. This lets anyone on the network tamper with\n\tdownloads, so only use it for testing, and prefer --ca-cert.",
+		},
+	}
+}
+
+func parseConnectionOptions(c *cli.Context) fetch.ConnectionOptions {
+	return fetch.ConnectionOptions{
+		Proxy:                 c.String(optionProxy),
+		CaCert:                c.String(optionCaCert),
+		ClientCert:            c.String(optionClientCert),
+		ClientKey:             c.String(optionClientKey),
+		InsecureSkipTlsVerify: c.Bool(optionInsecureSkipTlsVerify),
+		AuthScheme:            c.String(optionAuthScheme),
+	}
+}
+
+func validateConnectionOptions(options fetch.ConnectionOptions) error {
+	if err := fetch.ValidateAuthScheme(options.AuthScheme); err != nil {
+		return err
+	}
+	if _, err := fetch.ParseProxy(options.Proxy); err != nil {
+		return err
+	}
+	if (options.ClientCert == "") != (options.ClientKey == "") {
+		return fmt.Errorf("The --%s and --%s flags must be used together. Run \"fetch --help\" for full usage info.", optionClientCert, optionClientKey)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+)
+
+const optionDryRun = "dry-run"
+const optionDryRunFormat = "dry-run-format"
+
+// The formats in which --dry-run can print the plan
+const (
+	dryRunFormatText = "text"
+	dryRunFormatJson = "json"
+)
+
+func validateDryRunFormat(format string) error {
+	if format != dryRunFormatText && format != dryRunFormatJson {
+		return fmt.Errorf("Unknown --%s \"%s\". Must be one of: %s, %s.", optionDryRunFormat, format, dryRunFormatJson, dryRunFormatText)
+	}
+	return nil
+}
+
+// Write the given plan to writer in the given format: either a table with one row per download, or JSON
+func writeFetchPlan(writer io.Writer, plan *fetch.FetchPlan, format string) error {
+	if format == dryRunFormatJson {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	if plan.Tag != "" {
+		if plan.TagCommitSha != "" {
+			fmt.Fprintf(writer, "Tag: %s (commit %s)\n", plan.Tag, plan.TagCommitSha)
+		} else {
+			fmt.Fprintf(writer, "Tag: %s\n", plan.Tag)
+		}
+	}
+	if plan.CommitSha != "" {
+		fmt.Fprintf(writer, "Commit: %s\n", plan.CommitSha)
+	}
+	if plan.Ref != "" {
+		fmt.Fprintf(writer, "Ref: %s\n", plan.Ref)
+	}
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "KIND\tNAME\tSIZE\tURL\tDESTINATION")
+	writeRows := func(kind string, downloads []fetch.PlannedDownload) {
+		for _, download := range downloads {
+			size := "-"
+			if download.Size > 0 {
+				size = fmt.Sprintf("%d", download.Size)
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", kind, download.Name, size, download.Url, download.Destination)
+		}
+	}
+	writeRows("source-path", plan.SourcePaths)
+	writeRows("source-file", plan.SourceFiles)
+	writeRows("release-asset", plan.ReleaseAssets)
+	return table.Flush()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var samplePlan = &fetch.FetchPlan{
+	Tag:          "v0.0.4",
+	TagCommitSha: "abc123",
+	Ref:          "v0.0.4",
+	SourcePaths: []fetch.PlannedDownload{
+		{Name: "/modules", Url: "https://api.github.com/repos/foo/bar/zipball/v0.0.4", Destination: "/tmp/bar"},
+	},
+	ReleaseAssets: []fetch.PlannedDownload{
+		{Name: "tool_linux_amd64", Url: "https://api.github.com/repos/foo/bar/releases/assets/1", Size: 1048576, Destination: "/tmp/bar/tool"},
+	},
+}
+
+func TestValidateDryRunFormat(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateDryRunFormat(dryRunFormatText))
+	assert.NoError(t, validateDryRunFormat(dryRunFormatJson))
+	assert.Error(t, validateDryRunFormat("yaml"))
+}
+
+func TestWriteFetchPlanText(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	require.NoError(t, writeFetchPlan(&out, samplePlan, dryRunFormatText))
+
+	assert.Equal(t, ""+
+		"Tag: v0.0.4 (commit abc123)\n"+
+		"Ref: v0.0.4\n"+
+		"KIND           NAME              SIZE     URL                                                     DESTINATION\n"+
+		"source-path    /modules          -        https://api.github.com/repos/foo/bar/zipball/v0.0.4     /tmp/bar\n"+
+		"release-asset  tool_linux_amd64  1048576  https://api.github.com/repos/foo/bar/releases/assets/1  /tmp/bar/tool\n", out.String())
+}
+
+func TestWriteFetchPlanJson(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	require.NoError(t, writeFetchPlan(&out, samplePlan, dryRunFormatJson))
+
+	var decoded fetch.FetchPlan
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, *samplePlan, decoded)
+}
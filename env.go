@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// The env var that sets the local download path of a fetch, if it isn't passed as an argument
+const envVarDownloadPath = "FETCH_DOWNLOAD_PATH"
+
+// Shorter names for the env vars of the options that are most often set, e.g. in the initContainers of Kubernetes pods,
+// which can then run fetch with env vars alone
+var optionEnvVarAliases = map[string][]string{
+	optionReleaseAsset:         {"FETCH_ASSET"},
+	optionReleaseAssetChecksum: {"FETCH_CHECKSUM"},
+}
+
+// Return the env var that sets the option with the given name, e.g. FETCH_RELEASE_ASSET for --release-asset
+func optionEnvVar(name string) string {
+	return "FETCH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Let each of the given flags also be set with the env var from optionEnvVar, and any alias in optionEnvVarAliases.
+// Env vars the flag already has, such as GITHUB_OAUTH_TOKEN, take precedence, and flags take precedence over all of
+// them. A flag that can be given more than once takes its env var's value as a single value.
+func withOptionEnvVars(flags []cli.Flag) []cli.Flag {
+	for _, flag := range flags {
+		name := flag.Names()[0]
+		envVars := append([]string{optionEnvVar(name)}, optionEnvVarAliases[name]...)
+
+		switch flag := flag.(type) {
+		case *cli.StringFlag:
+			flag.EnvVars = appendMissing(flag.EnvVars, envVars)
+		case *cli.BoolFlag:
+			flag.EnvVars = appendMissing(flag.EnvVars, envVars)
+		case *cli.IntFlag:
+			flag.EnvVars = appendMissing(flag.EnvVars, envVars)
+		case *cli.DurationFlag:
+			flag.EnvVars = appendMissing(flag.EnvVars, envVars)
+		case *cli.StringSliceFlag:
+			flag.EnvVars = appendMissing(flag.EnvVars, envVars)
+		}
+	}
+	return flags
+}
+
+// Append each of values to list, unless it's already in it
+func appendMissing(list []string, values []string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
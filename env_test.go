@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestOptionEnvVar(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "FETCH_REPO", optionEnvVar(optionRepo))
+	assert.Equal(t, "FETCH_RELEASE_ASSET_CHECKSUM_ALGO", optionEnvVar(optionReleaseAssetChecksumAlgo))
+}
+
+func TestWithOptionEnvVarsKeepsExistingEnvVarsFirst(t *testing.T) {
+	t.Parallel()
+
+	for _, flag := range fetchFlags() {
+		switch flag.Names()[0] {
+		case optionGithubToken:
+			assert.Equal(t, []string{envVarGithubToken, "FETCH_GITHUB_OAUTH_TOKEN"}, flag.(*cli.StringFlag).EnvVars)
+		case optionCacheDir:
+			assert.Equal(t, []string{envVarCacheDir}, flag.(*cli.StringFlag).EnvVars)
+		case optionReleaseAsset:
+			assert.Equal(t, []string{"FETCH_RELEASE_ASSET", "FETCH_ASSET"}, flag.(*cli.StringFlag).EnvVars)
+		case optionUnpack:
+			assert.Equal(t, []string{"FETCH_UNPACK"}, flag.(*cli.BoolFlag).EnvVars)
+		}
+	}
+}
+
+// Env vars are process-wide, so this test can't run in parallel with others
+func TestFetchCanBeConfiguredWithEnvVarsAlone(t *testing.T) {
+	t.Setenv("FETCH_REPO", "https://github.com/foo/bar")
+	t.Setenv("FETCH_TAG", "~>0.1.0")
+	t.Setenv("FETCH_ASSET", "tool_linux_amd64")
+	t.Setenv("FETCH_CHECKSUM", "sha256:abc123")
+	t.Setenv("FETCH_UNPACK", "true")
+	t.Setenv("FETCH_VERIFY_WITH_REPO_KEY", "false")
+	t.Setenv("FETCH_MAX_CONCURRENT_DOWNLOADS", "2")
+	t.Setenv(envVarDownloadPath, "/opt/bin")
+
+	var options fetch.Options
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Action = func(c *cli.Context) error {
+		options = parseOptions(c, fetch.GetProjectLogger())
+		return nil
+	}
+
+	require.NoError(t, app.Run([]string{"fetch"}))
+	assert.Equal(t, "https://github.com/foo/bar", options.RepoUrl)
+	assert.Equal(t, "~>0.1.0", options.TagConstraint)
+	assert.Equal(t, "tool_linux_amd64", options.ReleaseAsset)
+	assert.Equal(t, map[string]bool{"sha256:abc123": true}, options.ReleaseAssetChecksums)
+	assert.True(t, options.Unpack)
+	assert.False(t, options.VerifyWithRepoKey)
+	assert.Equal(t, 2, options.MaxConcurrentDownloads)
+	assert.Equal(t, "/opt/bin", options.LocalDownloadPath)
+	assert.NoError(t, validateOptions(options))
+
+	// Flags and arguments take precedence over env vars
+	require.NoError(t, app.Run([]string{"fetch", "--release-asset", "tool_darwin_arm64", "--unpack=false", "/tmp/bin"}))
+	assert.Equal(t, "tool_darwin_arm64", options.ReleaseAsset)
+	assert.False(t, options.Unpack)
+	assert.Equal(t, "/tmp/bin", options.LocalDownloadPath)
+}
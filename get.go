@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Create the "fetch get" command, which runs a fetch exactly as the flat "fetch [global options] <local-download-path>"
+// invocation does. The flat invocation is kept working for backwards compatibility.
+func createGetCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "Download files, folders, and release assets from a git commit, branch, or tag of a GitHub repo.",
+		UsageText: "fetch get --repo <repo> [--tag <tag> | --ref <ref> | --commit <sha> | --branch <branch>] [options] <local-download-path>",
+		// The command's flags shadow the global ones, so the logger is set up again from the command's flags
+		Before: initLogger,
+		Action: runFetchWrapper,
+		Flags:  fetchFlags(),
+	}
+}
+
+// Create the "fetch asset" command, which runs a fetch that downloads release assets only
+func createAssetCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "asset",
+		Usage:     "Download release assets from a GitHub release, without any files from the repo itself.",
+		UsageText: "fetch asset --repo <repo> --tag <tag> [--release-asset <name> | --auto-asset | --all-release-assets] [options] <local-download-path>",
+		Before:    initLogger,
+		Action:    runAssetWrapper,
+		Flags:     fetchFlags(),
+	}
+}
+
+func runAssetWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runAsset(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch asset" command
+func runAsset(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := parseOptions(c, logger)
+	if err := validateAssetOptions(options); err != nil {
+		return err
+	}
+	return runValidatedFetch(ctx, c, options)
+}
+
+func validateAssetOptions(options fetch.Options) error {
+	if !downloadsReleaseAssets(options) {
+		return fmt.Errorf("The \"fetch asset\" command requires one of --%s, --%s, or --%s. Run \"fetch asset --help\" for full usage info.", optionReleaseAsset, optionAutoAsset, optionAllReleaseAssets)
+	}
+	if len(options.SourcePaths) > 0 || len(options.SourceFiles) > 0 {
+		return fmt.Errorf("The \"fetch asset\" command only downloads release assets, so it cannot be used with --%s or --%s. Use \"fetch get\" instead.", optionSourcePath, optionSourceFile)
+	}
+	return validateOptions(options)
+}
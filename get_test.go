@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestValidateAssetOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		ReleaseAsset:           "tool_linux_amd64",
+		LocalDownloadPath:      "/tmp/bin",
+		MaxConcurrentDownloads: 1,
+		LinkMode:               fetch.LinkModeCopy,
+	}
+	assert.NoError(t, validateAssetOptions(valid))
+
+	noAsset := valid
+	noAsset.ReleaseAsset = ""
+	assert.Error(t, validateAssetOptions(noAsset))
+
+	withSourcePath := valid
+	withSourcePath.SourcePaths = []string{"/modules"}
+	assert.Error(t, validateAssetOptions(withSourcePath))
+
+	withSourceFile := valid
+	withSourceFile.SourceFiles = []string{"README.md"}
+	assert.Error(t, validateAssetOptions(withSourceFile))
+
+	// The checks for any fetch still apply
+	noRepo := valid
+	noRepo.RepoUrl = ""
+	assert.Error(t, validateAssetOptions(noRepo))
+}
+
+func TestGetCommandParsesTheSameOptionsAsTheFlatInvocation(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--repo", "https://github.com/foo/bar", "--tag", "~>0.1.0", "--release-asset", "tool_.*", "--no-cache", "/tmp/bin"}
+
+	var flatOptions, getOptions fetch.Options
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Action = func(c *cli.Context) error {
+		flatOptions = parseOptions(c, fetch.GetProjectLogger())
+		return nil
+	}
+	for i := range app.Commands {
+		if app.Commands[i].Name == "get" {
+			app.Commands[i].Before = nil
+			app.Commands[i].Action = func(c *cli.Context) error {
+				getOptions = parseOptions(c, fetch.GetProjectLogger())
+				return nil
+			}
+		}
+	}
+
+	require.NoError(t, app.Run(append([]string{"fetch"}, args...)))
+	require.NoError(t, app.Run(append([]string{"fetch", "get"}, args...)))
+
+	assert.Equal(t, "/tmp/bin", getOptions.LocalDownloadPath)
+	assert.Equal(t, "tool_.*", getOptions.ReleaseAsset)
+	assert.Empty(t, getOptions.ArchiveCacheDir)
+	flatOptions.Logger, getOptions.Logger = nil, nil
+	assert.Equal(t, flatOptions, getOptions)
+}
+
+func TestRepeatedFlagValuesAreNotSplitOnCommas(t *testing.T) {
+	t.Parallel()
+
+	var options fetch.Options
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Action = func(c *cli.Context) error {
+		options = parseOptions(c, fetch.GetProjectLogger())
+		return nil
+	}
+
+	require.NoError(t, app.Run([]string{"fetch", "--repo", "https://github.com/foo/bar", "--tag", "v0.0.1", "--source-path", "/a,b", "--rename", "c,d=e", "/tmp/src"}))
+	assert.Equal(t, []string{"/a,b"}, options.SourcePaths)
+	assert.Equal(t, []string{"c,d=e"}, options.Renames)
+}
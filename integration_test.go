@@ -2,16 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/gruntwork-io/fetch/pkg/fetch"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	cli "gopkg.in/urfave/cli.v1"
+	"github.com/urfave/cli/v2"
 )
 
 func TestFetchWithBranchOption(t *testing.T) {
@@ -47,11 +51,34 @@ func TestFetchWithBranchOption(t *testing.T) {
 			assert.Contains(t, erroutput, "Downloading latest commit from branch")
 
 			// Ensure the expected file was downloaded
-			assert.FileExists(t, JoinPath(tmpDownloadPath, tc.expectedFile))
+			assert.FileExists(t, fetch.JoinPath(tmpDownloadPath, tc.expectedFile))
 		})
 	}
 }
 
+func TestFetchWithSourceFileOption(t *testing.T) {
+	tmpDownloadPath := createTempDir(t, "fetch-source-file-test")
+
+	cmd := fmt.Sprintf("fetch --repo https://github.com/gruntwork-io/fetch-test-public --branch sample-branch --source-file /foo.txt %s", tmpDownloadPath)
+	_, erroutput, err := runFetchCommandWithOutput(t, cmd)
+	require.NoError(t, err)
+
+	// Only the one file is downloaded, without downloading the repo archive
+	assert.NotContains(t, erroutput, "Downloading latest commit from branch")
+	assert.FileExists(t, fetch.JoinPath(tmpDownloadPath, "foo.txt"))
+}
+
+func TestFetchWithContentsDownloadStrategy(t *testing.T) {
+	tmpDownloadPath := createTempDir(t, "fetch-contents-strategy-test")
+
+	cmd := fmt.Sprintf("fetch --repo https://github.com/gruntwork-io/fetch-test-public --branch sample-branch --source-path / --download-strategy contents %s", tmpDownloadPath)
+	_, erroutput, err := runFetchCommandWithOutput(t, cmd)
+	require.NoError(t, err)
+
+	assert.Contains(t, erroutput, "with the contents API")
+	assert.FileExists(t, fetch.JoinPath(tmpDownloadPath, "foo.txt"))
+}
+
 func TestFetchWithStdoutOption(t *testing.T) {
 	tmpDownloadPath, err := ioutil.TempDir("", "fetch-stdout-test")
 	require.NoError(t, err)
@@ -66,19 +93,98 @@ func TestFetchWithStdoutOption(t *testing.T) {
 	require.NoError(t, err)
 
 	// Ensure the expected file was downloaded
-	assert.FileExists(t, JoinPath(tmpDownloadPath, releaseAsset))
+	assert.FileExists(t, fetch.JoinPath(tmpDownloadPath, releaseAsset))
 
 	// When --stdout is specified, ensure the file contents are piped to the standard output stream
 	assert.Contains(t, stdoutput, "hello world")
 }
 
+func TestFetchStreamToStdout(t *testing.T) {
+	repoUrl := "https://github.com/gruntwork-io/fetch-test-public"
+	releaseTag := "v0.0.4"
+	releaseAsset := "hello+world.txt"
+
+	cmd := fmt.Sprintf("fetch --repo %s --tag %s --release-asset %s -", repoUrl, releaseTag, releaseAsset)
+	t.Logf("Testing command: %s", cmd)
+	stdoutput, _, err := runFetchCommandWithOutput(t, cmd)
+	require.NoError(t, err)
+
+	// With a download path of "-", the contents of the release asset are all that's written to stdout
+	assert.Equal(t, "hello world\n", stdoutput)
+	assert.NoFileExists(t, releaseAsset)
+}
+
+func TestFetchStreamToStdoutWithChecksum(t *testing.T) {
+	repoUrl := "https://github.com/gruntwork-io/fetch-test-public"
+	releaseTag := "v0.0.4"
+	releaseAsset := "hello+world.txt"
+	checksum := "sha256:a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+
+	for _, extraFlags := range []string{"", "--verify-before-stdout"} {
+		cmd := fmt.Sprintf("fetch --repo %s --tag %s --release-asset %s --release-asset-checksum %s %s -", repoUrl, releaseTag, releaseAsset, checksum, extraFlags)
+		t.Logf("Testing command: %s", cmd)
+		stdoutput, _, err := runFetchCommandWithOutput(t, cmd)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world\n", stdoutput)
+
+		cmd = fmt.Sprintf("fetch --repo %s --tag %s --release-asset %s --release-asset-checksum sha256:%s %s -", repoUrl, releaseTag, releaseAsset, strings.Repeat("0", 64), extraFlags)
+		t.Logf("Testing command: %s", cmd)
+		_, _, err = runFetchCommandWithOutput(t, cmd)
+		assert.Error(t, err)
+	}
+}
+
+func TestFetchDryRun(t *testing.T) {
+	tmpDownloadPath := createTempDir(t, "fetch-dry-run-test")
+
+	repoUrl := "https://github.com/gruntwork-io/fetch-test-public"
+	releaseAsset := "hello+world.txt"
+
+	cmd := fmt.Sprintf("fetch --repo %s --tag v0.0.4 --release-asset %s --dry-run --dry-run-format json %s", repoUrl, releaseAsset, tmpDownloadPath)
+	t.Logf("Testing command: %s", cmd)
+	stdoutput, _, err := runFetchCommandWithOutput(t, cmd)
+	require.NoError(t, err)
+
+	var plan fetch.FetchPlan
+	require.NoError(t, json.Unmarshal([]byte(stdoutput), &plan))
+	assert.Equal(t, "v0.0.4", plan.Tag)
+	require.Len(t, plan.ReleaseAssets, 1)
+	assert.Equal(t, filepath.Join(tmpDownloadPath, releaseAsset), plan.ReleaseAssets[0].Destination)
+
+	// Nothing is written, not even the local download path
+	assert.NoDirExists(t, tmpDownloadPath)
+}
+
+func TestFetchWithJsonOutput(t *testing.T) {
+	tmpDownloadPath := createTempDir(t, "fetch-json-output-test")
+
+	repoUrl := "https://github.com/gruntwork-io/fetch-test-public"
+	releaseAsset := "hello+world.txt"
+
+	cmd := fmt.Sprintf("fetch --repo %s --tag v0.0.4 --release-asset %s --output json %s", repoUrl, releaseAsset, tmpDownloadPath)
+	t.Logf("Testing command: %s", cmd)
+	stdoutput, _, err := runFetchCommandWithOutput(t, cmd)
+	require.NoError(t, err)
+
+	var summary fetchSummary
+	require.NoError(t, json.Unmarshal([]byte(stdoutput), &summary))
+	assert.Equal(t, "v0.0.4", summary.Tag)
+	assert.Equal(t, []fetchSummaryFile{{
+		Kind:   fetch.FetchedReleaseAsset,
+		Path:   filepath.Join(tmpDownloadPath, releaseAsset),
+		Size:   12,
+		Sha256: "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447",
+	}}, summary.Files)
+	assert.Greater(t, summary.Durations.Total, 0.0)
+}
+
 func TestFetchWithStdoutOptionMultipleAssets(t *testing.T) {
 	tmpDownloadPath, err := ioutil.TempDir("", "fetch-stdout-test")
 	require.NoError(t, err)
 
-	repoUrl := SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL
-	releaseTag := SAMPLE_RELEASE_ASSET_VERSION
-	releaseAsset := SAMPLE_RELEASE_ASSET_REGEX
+	repoUrl := "https://github.com/gruntwork-io/health-checker"
+	releaseTag := "v0.0.2"
+	releaseAsset := "health-checker_linux_[a-z0-9]+"
 
 	cmd := fmt.Sprintf("fetch --repo %s --tag %s --release-asset %s --stdout true %s", repoUrl, releaseTag, releaseAsset, tmpDownloadPath)
 	t.Logf("Testing command: %s", cmd)
@@ -106,7 +212,8 @@ func runFetchCommandWithOutput(t *testing.T, command string) (string, string, er
 }
 
 func runFetchCommand(t *testing.T, command string, writer io.Writer, errwriter io.Writer) error {
-	args := strings.Split(command, " ")
+	// Split on runs of spaces, so that a format string with an empty argument doesn't produce an empty positional arg
+	args := strings.Fields(command)
 
 	app := CreateFetchCli(VERSION, writer, errwriter)
 	app.Action = runFetchTestWrapper
@@ -134,6 +241,6 @@ func createTempDir(t *testing.T, prefix string) string {
 // and suddenly exit using os.Exit(1), so we use a separate wrapper method in the integration tests.
 func runFetchTestWrapper(c *cli.Context) error {
 	// initialize the logger
-	logger := GetProjectLoggerWithWriter(c.App.ErrWriter)
-	return runFetch(c, logger)
+	logger := fetch.GetProjectLoggerWithWriter(c.App.ErrWriter)
+	return runFetch(context.Background(), c, logger)
 }
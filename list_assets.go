@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionSortBy = "sort-by"
+
+// Create the "fetch list-assets" command, which prints the assets of a release along with their size, last update
+// time, and download count
+func createListAssetsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "list-assets",
+		Usage:     "List the release assets of a release in a GitHub repo, with their size, last update time, and download count.",
+		UsageText: "fetch list-assets --repo <repo> --tag <tag> [options]",
+		Action:    runListAssetsWrapper,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  optionRepo,
+				Usage: "Required. Fully qualified URL of the GitHub repo.",
+			},
+			&cli.StringFlag{
+				Name:  optionTag,
+				Usage: "Required. The git tag of the release, expressed with Version Constraint Operators.",
+			},
+			&cli.StringFlag{
+				Name:  optionReleaseAsset,
+				Usage: "A regular expression matching the names of the release assets to list. Defaults to all assets.",
+			},
+			&cli.StringFlag{
+				Name:  optionSortBy,
+				Value: fetch.AssetOrderName,
+				Usage: "The order in which to list the assets: \"name\", \"size\" (largest first), \"updated\" (most recent\n\tfirst), or \"downloads\" (most downloaded first).",
+			},
+			&cli.StringFlag{
+				Name:    optionGithubToken,
+				Usage:   "A GitHub Personal Access Token, which is required for downloading from private repos. Populate by setting env var",
+				EnvVars: []string{envVarGithubToken},
+			},
+			&cli.StringFlag{
+				Name:    optionTokenCommand,
+				Usage:   "A command that prints the GitHub token to use, such as \"gh auth token\", which is run with the shell.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionTokenCommand)},
+			},
+			&cli.StringFlag{
+				Name:    optionGithubTokenFile,
+				Aliases: []string{"token-file"},
+				Usage:   "A file to read the GitHub token from, such as a secret mounted as a file, or \"-\" to read it from stdin.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionGithubTokenFile)},
+			},
+			&cli.StringSliceFlag{
+				Name:    optionCredentialFallback,
+				Usage:   "Where to look for the GitHub token if none is given: \"netrc\" (~/.netrc, or $NETRC), \"git\"\n\t(git credential fill), or both, in the order given.",
+				EnvVars: []string{optionEnvVar(optionCredentialFallback)},
+			},
+			&cli.StringFlag{
+				Name:  optionGithubAPIVersion,
+				Value: "v3",
+				Usage: "The api version of the GitHub instance. If left blank, v3 will be used.\n\tThis will only be used if the repo url is not a github.com url.",
+			},
+		}, connectionFlags()...),
+	}
+}
+
+func runListAssetsWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runListAssets(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch list-assets" command
+func runListAssets(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := fetch.Options{
+		RepoUrl:          c.String(optionRepo),
+		TagConstraint:    c.String(optionTag),
+		ReleaseAsset:     c.String(optionReleaseAsset),
+		GithubToken:      c.String(optionGithubToken),
+		GithubApiVersion: c.String(optionGithubAPIVersion),
+		Connection:       parseConnectionOptions(c),
+		Logger:           logger,
+		Locale:           resolveLocale(c),
+	}
+	sortBy := c.String(optionSortBy)
+	if err := validateListAssetsOptions(options, sortBy); err != nil {
+		return err
+	}
+	token, err := resolveGithubToken(ctx, c, options.RepoUrl, options.GithubToken)
+	if err != nil {
+		return err
+	}
+	options.GithubToken = token
+
+	fetcher, err := fetch.NewFetcher(options)
+	if err != nil {
+		return err
+	}
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return err
+	}
+	assets, err := fetcher.ListReleaseAssets(ctx, resolvedTag.Tag, sortBy)
+	if err != nil {
+		return err
+	}
+
+	return writeAssetList(c.App.Writer, assets)
+}
+
+func validateListAssetsOptions(options fetch.Options, sortBy string) error {
+	if options.RepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch list-assets --help\" for full usage info.", optionRepo)
+	}
+	if options.TagConstraint == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch list-assets --help\" for full usage info.", optionTag)
+	}
+	return fetch.ValidateAssetOrder(sortBy)
+}
+
+// Write the given assets to writer as a table with one row per asset. Sizes are in bytes.
+func writeAssetList(writer io.Writer, assets [](*fetch.GitHubReleaseAsset)) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tSIZE\tUPDATED\tDOWNLOADS")
+	for _, asset := range assets {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%d\n", asset.Name, asset.Size, asset.UpdatedAt.UTC().Format(time.RFC3339), asset.DownloadCount)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateListAssetsOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{RepoUrl: "https://github.com/foo/bar", TagConstraint: "v0.0.1"}
+	assert.NoError(t, validateListAssetsOptions(valid, fetch.AssetOrderUpdated))
+
+	assert.Error(t, validateListAssetsOptions(valid, "newest"))
+
+	noRepo := valid
+	noRepo.RepoUrl = ""
+	assert.Error(t, validateListAssetsOptions(noRepo, fetch.AssetOrderName))
+
+	noTag := valid
+	noTag.TagConstraint = ""
+	assert.Error(t, validateListAssetsOptions(noTag, fetch.AssetOrderName))
+}
+
+func TestWriteAssetList(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	require.NoError(t, writeAssetList(&out, [](*fetch.GitHubReleaseAsset){
+		{Name: "tool_linux_amd64", Size: 1048576, UpdatedAt: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), DownloadCount: 42},
+		{Name: "SHA256SUMS", Size: 90, UpdatedAt: time.Date(2022, 1, 2, 3, 4, 0, 0, time.UTC), DownloadCount: 7},
+	}))
+
+	assert.Equal(t, ""+
+		"NAME              SIZE     UPDATED               DOWNLOADS\n"+
+		"tool_linux_amd64  1048576  2022-01-02T03:04:05Z  42\n"+
+		"SHA256SUMS        90       2022-01-02T03:04:00Z  7\n", out.String())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionLogColor = "log-color"
+
+// When fetch colors its logs
+const (
+	logColorAuto   = "auto" // Only when stderr is a terminal
+	logColorAlways = "always"
+	logColorNever  = "never"
+)
+
+// Return whether logs should be colored for the given --log-color mode. For logColorAuto, that depends on whether stderr
+// is a terminal, and on the NO_COLOR (see https://no-color.org) and TERM env vars, as read with getenv.
+func resolveLogColor(mode string, isTerminal bool, getenv func(string) string) (bool, error) {
+	switch mode {
+	case logColorAuto:
+		return isTerminal && getenv("NO_COLOR") == "" && getenv("TERM") != "dumb", nil
+	case logColorAlways:
+		return true, nil
+	case logColorNever:
+		return false, nil
+	default:
+		return false, fmt.Errorf("Unknown --%s \"%s\". Must be one of: %s, %s, %s.", optionLogColor, mode, logColorAuto, logColorAlways, logColorNever)
+	}
+}
+
+// Return the level to log at: the --log-level, or only errors with --quiet. The config file only sets defaults, so if
+// it sets --quiet or one of the options --quiet can't be used with, that gives way to the other set by a flag or env var.
+func resolveLogLevel(c *cli.Context) (logrus.Level, error) {
+	if c.Bool(optionQuiet) {
+		conflicting := isSetExplicitly(c, optionLogLevel) || c.Bool(optionWithProgress) && isSetExplicitly(c, optionWithProgress)
+		switch {
+		case conflicting && isSetExplicitly(c, optionQuiet):
+			return logrus.ErrorLevel, fmt.Errorf("The --%s flag cannot be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionQuiet, optionLogLevel, optionWithProgress)
+		case !conflicting:
+			if c.Bool(optionWithProgress) {
+				if err := c.Set(optionWithProgress, "false"); err != nil {
+					return logrus.ErrorLevel, err
+				}
+			}
+			return logrus.ErrorLevel, nil
+		}
+	}
+	level, err := logrus.ParseLevel(c.String(optionLogLevel))
+	if err != nil {
+		return level, fmt.Errorf("Error: %s\n", err)
+	}
+	return level, nil
+}
+
+// Return the formatter for logs in the given format, or nil if the go-commons one for the format should be used
+func newLogFormatter(format string, colors bool) logrus.Formatter {
+	switch format {
+	case logFormatConsole:
+		return &fetch.ConsoleFormatter{Colors: colors}
+	case logFormatText:
+		return &logrus.TextFormatter{FullTimestamp: true, ForceColors: colors, DisableColors: !colors}
+	default:
+		return nil
+	}
+}
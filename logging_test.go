@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLogLevel(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		args          []string
+		expectedLevel logrus.Level
+		expectError   bool
+	}{
+		{nil, logrus.InfoLevel, false},
+		{[]string{"--log-level=debug"}, logrus.DebugLevel, false},
+		{[]string{"--quiet"}, logrus.ErrorLevel, false},
+		{[]string{"-q"}, logrus.ErrorLevel, false},
+		{[]string{"--quiet", "--log-level=info"}, logrus.ErrorLevel, true},
+		{[]string{"--quiet", "--progress"}, logrus.ErrorLevel, true},
+		{[]string{"--log-level=loud"}, logrus.InfoLevel, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			t.Parallel()
+			level, err := resolveLogLevel(newTestCliContext(t, tc.args...))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLevel, level)
+		})
+	}
+}
+
+func TestResolveLogColor(t *testing.T) {
+	t.Parallel()
+
+	noEnv := func(string) string { return "" }
+	noColor := func(envVar string) string {
+		if envVar == "NO_COLOR" {
+			return "1"
+		}
+		return ""
+	}
+	dumbTerm := func(envVar string) string {
+		if envVar == "TERM" {
+			return "dumb"
+		}
+		return ""
+	}
+
+	testCases := []struct {
+		mode       string
+		isTerminal bool
+		getenv     func(string) string
+		expected   bool
+	}{
+		{logColorAuto, true, noEnv, true},
+		{logColorAuto, false, noEnv, false},
+		{logColorAuto, true, noColor, false},
+		{logColorAuto, true, dumbTerm, false},
+		{logColorAlways, false, noColor, true},
+		{logColorNever, true, noEnv, false},
+	}
+
+	for _, tc := range testCases {
+		colors, err := resolveLogColor(tc.mode, tc.isTerminal, tc.getenv)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, colors, "mode %s, terminal %t", tc.mode, tc.isTerminal)
+	}
+
+	_, err := resolveLogColor("yes", true, noEnv)
+	assert.Error(t, err)
+}
+
+func TestNewLogFormatter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, &fetch.ConsoleFormatter{Colors: true}, newLogFormatter(logFormatConsole, true))
+	assert.Equal(t, &logrus.TextFormatter{FullTimestamp: true, DisableColors: true}, newLogFormatter(logFormatText, false))
+	assert.Nil(t, newLogFormatter(logFormatJson, true))
+}
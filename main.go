@@ -1,65 +1,127 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path"
-	"regexp"
 	"strings"
-	"sync"
+	"syscall"
+	"time"
 
+	"github.com/gruntwork-io/fetch/pkg/fetch"
 	"github.com/gruntwork-io/go-commons/logging"
 	"github.com/sirupsen/logrus"
-	cli "gopkg.in/urfave/cli.v1"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 )
 
 // This variable is set at build time using -ldflags parameters. For more info, see:
 // http://stackoverflow.com/a/11355611/483528
 var VERSION string
 
-type FetchOptions struct {
-	RepoUrl                  string
-	GitRef                   string
-	CommitSha                string
-	BranchName               string
-	TagConstraint            string
-	GithubToken              string
-	SourcePaths              []string
-	ReleaseAsset             string
-	ReleaseAssetChecksums    map[string]bool
-	ReleaseAssetChecksumAlgo string
-	Stdout                   bool
-	LocalDownloadPath        string
-	GithubApiVersion         string
-	WithProgress             bool
-
-	// Project logger
-	Logger *logrus.Entry
-}
-
-type AssetDownloadResult struct {
-	assetPath string
-	err       error
-}
-
 const optionRepo = "repo"
+const optionSource = "source"
+const optionUrl = "url"
 const optionRef = "ref"
 const optionCommit = "commit"
 const optionBranch = "branch"
 const optionTag = "tag"
+const optionChannel = "channel"
+const optionIncludePrereleases = "include-prereleases"
+const optionIncludeDrafts = "include-drafts"
+const optionLooseSemver = "loose-semver"
+const optionTagPrefix = "tag-prefix"
 const optionGithubToken = "github-oauth-token"
+const optionTokenCommand = "token-command"
+const optionGithubTokenFile = "github-oauth-token-file"
+const optionArtifactRepoUsername = "artifact-repo-username"
+const optionArtifactRepoPassword = "artifact-repo-password"
+const optionArtifactRepoApiKey = "artifact-repo-api-key"
+const optionGitlabToken = "gitlab-token"
+const optionBitbucketToken = "bitbucket-token"
+const optionCredentialFallback = "credential-fallback"
 const optionSourcePath = "source-path"
+const optionSourceFile = "source-file"
+const optionDownloadStrategy = "download-strategy"
 const optionReleaseAsset = "release-asset"
+const optionReleaseAssetPickBy = "release-asset-pick-by"
+const optionAutoAsset = "auto-asset"
+const optionOS = "os"
+const optionArch = "arch"
+const optionAllReleaseAssets = "all-release-assets"
+const optionMinAssetSize = "min-asset-size"
+const optionMaxAssetSize = "max-asset-size"
+const optionJoinParts = "join-parts"
 const optionReleaseAssetChecksum = "release-asset-checksum"
 const optionReleaseAssetChecksumAlgo = "release-asset-checksum-algo"
+const optionReleaseAssetChecksumFile = "release-asset-checksum-file"
+const optionVerifyWithRepoKey = "verify-with-repo-key"
+const optionCosignVerify = "cosign-verify"
+const optionPackageSigningKey = "package-signing-key"
+const optionUpgradeWeakChecksums = "upgrade-weak-checksums"
+const optionCosignCertificateIdentity = "cosign-certificate-identity"
+const optionCosignCertificateOidcIssuer = "cosign-certificate-oidc-issuer"
+const optionCosignFulcioRoot = "cosign-fulcio-root"
+const optionCosignRekorPublicKey = "cosign-rekor-public-key"
+const optionCosignRekorUrl = "cosign-rekor-url"
+const optionCheckImmutableTag = "check-immutable-tag"
+const optionRequireImmutableTag = "require-immutable-tag"
+const optionExpectedCommitSha = "expected-commit-sha"
+const optionRequireSignedRef = "require-signed-ref"
+const optionSignedRefKey = "signed-ref-key"
 const optionStdout = "stdout"
+const optionVerifyBeforeStdout = "verify-before-stdout"
 const optionGithubAPIVersion = "github-api-version"
+const optionGhesVersion = "ghes-version"
 const optionWithProgress = "progress"
+const optionMaxConcurrentDownloads = "max-concurrent-downloads"
+const optionDownloadConnections = "download-connections"
 const optionLogLevel = "log-level"
+const optionQuiet = "quiet"
+const optionLocale = "locale"
+const optionWaitForRateLimit = "wait-for-rate-limit"
+const optionArchiveCacheDir = "archive-cache-dir"
+const optionLinkMode = "link-mode"
+const optionUnpack = "unpack"
+const optionKeepArchive = "keep-archive"
+const optionUnpackInclude = "unpack-include"
+const optionUnpackBinary = "unpack-binary"
+const optionInstall = "install"
+const optionInstallDir = "install-dir"
+const optionBinaryName = "binary-name"
+const optionPreservePermissions = "preserve-permissions"
+const optionPreserveSymlinks = "preserve-symlinks"
+const optionFollowDestSymlinks = "follow-dest-symlinks"
+const optionStripComponents = "strip-components"
+const optionFlatten = "flatten"
+const optionRename = "rename"
+const optionDirMode = "dir-mode"
+const optionFileMode = "file-mode"
+const optionPublishS3 = "publish-s3"
+const optionPublishS3Region = "publish-s3-region"
+const optionPublishS3UrlExpiry = "publish-s3-url-expiry"
+const optionEmitSbomLite = "emit-sbom-lite"
+const optionAuditLog = "audit-log"
+const optionResolveRef = "resolve-ref"
+const optionSbomLiteSigningKey = "sbom-lite-signing-key"
+
+// The categories the flags of a fetch are grouped into in the --help output
+const (
+	flagCategorySelection    = "Selecting what to download"
+	flagCategoryAuth         = "Authentication and GitHub Enterprise"
+	flagCategoryVerification = "Verification"
+	flagCategoryOutput       = "Output and logging"
+	flagCategoryPublishing   = "Publishing"
+	flagCategoryWriting      = "Writing, unpacking, and installing files"
+	flagCategoryPerformance  = "Performance and caching"
+	flagCategoryNetwork      = "Network"
+)
 
 const envVarGithubToken = "GITHUB_OAUTH_TOKEN"
+const envVarArchiveCacheDir = "FETCH_ARCHIVE_CACHE_DIR"
 
 // Create the Fetch CLI App
 func CreateFetchCli(version string, writer io.Writer, errwriter io.Writer) *cli.App {
@@ -71,75 +133,545 @@ func CreateFetchCli(version string, writer io.Writer, errwriter io.Writer) *cli.
 	app := cli.NewApp()
 	app.Name = "fetch"
 	app.Usage = "fetch makes it easy to download files, folders, and release assets from a specific git commit, branch, or tag of public and private GitHub repos."
-	app.UsageText = "fetch [global options] <local-download-path>\n   (See https://github.com/gruntwork-io/fetch for examples, argument definitions, and additional docs.)"
-	app.Author = "Gruntwork <www.gruntwork.io>"
+	app.UsageText = "fetch [global options] <local-download-path>\nfetch command [command options] [arguments...]\n(See https://github.com/gruntwork-io/fetch for examples, argument definitions, and additional docs.)"
+	app.Authors = []*cli.Author{{Name: "Gruntwork", Email: "www.gruntwork.io"}}
 	app.Version = version
 	app.Writer = writer
 	app.ErrWriter = errwriter
+	// Flags that can be specified more than once take each value as given, even if it has a comma in it
+	app.DisableSliceFlagSeparator = true
+	app.Commands = []*cli.Command{
+		createGetCommand(),
+		createAssetCommand(),
+		createVerifyCommand(),
+		createRepublishCommand(),
+		createListAssetsCommand(),
+		createManifestCommand(),
+		createCacheCommand(),
+		createCachedProxyCommand(),
+		createOciExportCommand(),
+		createBenchCommand(),
+		createAuditLogCommand(),
+	}
+
+	app.Flags = fetchFlags()
+
+	return app
+}
 
-	app.Flags = []cli.Flag{
-		cli.StringFlag{
-			Name:  optionRepo,
-			Usage: "Required. Fully qualified URL of the GitHub repo.",
+// Return the flags of a fetch with the given names, for commands that only take some of them
+func fetchFlagsNamed(names ...string) []cli.Flag {
+	var flags []cli.Flag
+	for _, flag := range fetchFlags() {
+		for _, name := range names {
+			if flag.Names()[0] == name {
+				flags = append(flags, flag)
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// Return the flags of a fetch, which are shared by the flat "fetch [global options] <local-download-path>" invocation
+// and the "fetch get" and "fetch asset" commands. Each can also be set with an env var, e.g. FETCH_REPO for --repo.
+func fetchFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     optionRepo,
+			Category: flagCategorySelection,
+			Usage:    "Required. Fully qualified URL of the GitHub repo.",
+		},
+		&cli.StringFlag{
+			Name:     optionSource,
+			Category: flagCategorySelection,
+			Value:    fetch.SourceAuto,
+			Usage:    "What kind of repo --repo is: \"auto\" (GitHub or Bitbucket Server, or CodeCommit for an HTTPS or\n\tcodecommit:// URL, told apart by the URL), \"bitbucket-dc\" (a Bitbucket Server or Data Center repo, which may\n\tbe given by its HTTPS clone URL), \"codecommit\" (an AWS CodeCommit repo, which may be given by name),\n\t\"goproxy\" (a Go module path, e.g. golang.org/x/net, whose source is downloaded from the module proxy in GOPROXY),\n\t\"artifactory\" or \"nexus\" (the URL of a folder of an Artifactory generic or Nexus raw repo, with a folder of\n\trelease assets for each version), or \"gitlab-package\" (the URL of a package in a GitLab project's Generic Packages\n\tregistry, e.g. https://gitlab.com/api/v4/projects/1234/packages/generic/tool, with files for each version).",
+		},
+		&cli.StringFlag{
+			Name:     optionUrl,
+			Category: flagCategorySelection,
+			Usage:    "Instead of --repo, download the file at this plain http(s) URL, such as a vendor's download site, and\n\tverify, install, or unpack it as a release asset. The GitHub token is never sent to it.",
+		},
+		&cli.StringFlag{
+			Name:     optionRef,
+			Category: flagCategorySelection,
+			Usage:    "The git reference to download. If specified, will take lower precendence than --commit, --branch, and --tag.",
+		},
+		&cli.StringFlag{
+			Name:     optionCommit,
+			Category: flagCategorySelection,
+			Usage:    "The specific git commit SHA to download. If specified, will override --branch and --tag.",
+		},
+		&cli.StringFlag{
+			Name:     optionBranch,
+			Category: flagCategorySelection,
+			Usage:    "The git branch from which to download the commit; the latest commit in the branch\n\twill be used.\n\tIf specified, will override --tag.",
+		},
+		&cli.StringFlag{
+			Name:     optionTag,
+			Category: flagCategorySelection,
+			Usage:    "The specific git tag to download, expressed with Version Constraint Operators.\n\tIf left blank, fetch will download the latest git tag. \"latest-release\" downloads the latest\n\tGitHub release without listing the repo's tags.\n\tSee https://github.com/gruntwork-io/fetch#version-constraint-operators for examples.",
+		},
+		&cli.StringFlag{
+			Name:     optionChannel,
+			Aliases:  []string{"release-channel"},
+			Category: flagCategorySelection,
+			Usage:    "Download the latest tag in the given release channel: \"stable\" (final releases only), \"rc\" (final\n\treleases and release candidates), \"beta\" (final releases, release candidates, and betas), or\n\t\"any\" (every version, including all pre-releases; also called \"nightly\").\n\tCan be combined with --tag to narrow down the versions.",
+		},
+		&cli.BoolFlag{
+			Name:     optionIncludePrereleases,
+			Category: flagCategorySelection,
+			Usage:    "If set, match pre-release versions (e.g. v1.2.3-rc.1) against the --tag constraint by their\n\tversion, so ~>1.2 matches v1.3.0-rc.1. By default, a pre-release only matches a constraint\n\ton a pre-release of the same version, e.g. ~>1.3.0-rc.1.",
+		},
+		&cli.BoolFlag{
+			Name:     optionIncludeDrafts,
+			Category: flagCategorySelection,
+			Usage:    "If set, also match the tags of the repo's draft GitHub releases against the --tag constraint,\n\tand download release assets from drafts. Requires a GitHub token with push access to the repo.",
+		},
+		&cli.BoolFlag{
+			Name:     optionLooseSemver,
+			Category: flagCategorySelection,
+			Usage:    "If set, coerce tags that aren't valid versions (e.g. release-1.2.3) into versions when matching\n\tthe --tag or --ref constraint, rather than ignoring them.\n\tSee https://github.com/gruntwork-io/fetch#loosely-versioned-tags for the rules.",
+		},
+		&cli.StringFlag{
+			Name:     optionTagPrefix,
+			Category: flagCategorySelection,
+			Usage:    "Only match the tags with this prefix (e.g. modules/vpc/) against the --tag or --ref constraint,\n\twith the prefix stripped, so modules/vpc/v1.2.3 is matched as v1.2.3. For monorepos that\n\trelease each module with its own tags.",
+		},
+		&cli.StringFlag{
+			Name:     optionGithubToken,
+			Category: flagCategoryAuth,
+			Usage:    "A GitHub Personal Access Token, which is required for downloading from private\n\trepos. Populate by setting env var",
+			EnvVars:  []string{envVarGithubToken},
+		},
+		&cli.StringFlag{
+			Name:     optionTokenCommand,
+			Category: flagCategoryAuth,
+			Usage:    "A command that prints the GitHub token to use, such as \"gh auth token\", which is run with the shell.\n\tTakes precedence over --github-oauth-token.",
+		},
+		&cli.StringFlag{
+			Name:     optionGithubTokenFile,
+			Aliases:  []string{"token-file"},
+			Category: flagCategoryAuth,
+			Usage:    "A file to read the GitHub token from, such as a secret mounted as a file, or \"-\" to read it from stdin.\n\tTakes precedence over --github-oauth-token.",
+		},
+		&cli.StringFlag{
+			Name:     optionArtifactRepoUsername,
+			Category: flagCategoryAuth,
+			Usage:    "The username to read an Artifactory or Nexus repository with, using basic auth with --artifact-repo-password.",
+		},
+		&cli.StringFlag{
+			Name:     optionArtifactRepoPassword,
+			Category: flagCategoryAuth,
+			Usage:    "The password, access token, or Nexus user token to read an Artifactory or Nexus repository with.",
+		},
+		&cli.StringFlag{
+			Name:     optionArtifactRepoApiKey,
+			Category: flagCategoryAuth,
+			Usage:    "Instead of a username and password, the Artifactory API key to read an Artifactory repository with.",
+		},
+		&cli.StringFlag{
+			Name:     optionGitlabToken,
+			Category: flagCategoryAuth,
+			Usage:    "The personal, project, or group access token to read a GitLab package registry with. Defaults to the\n\tjob token in a GitLab CI job on the same instance.",
+			EnvVars:  []string{"GITLAB_TOKEN"},
+		},
+		&cli.StringFlag{
+			Name:     optionBitbucketToken,
+			Category: flagCategoryAuth,
+			Usage:    "The HTTP access token or personal access token to read a Bitbucket Server or Data Center repo with.",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionCredentialFallback,
+			Category: flagCategoryAuth,
+			Usage:    "Where to look for a GitHub token for the repo's host if none is given: \"netrc\" (~/.netrc, or $NETRC),\n\t\"git\" (git credential fill), or both, in the order given.",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionSourcePath,
+			Category: flagCategorySelection,
+			Usage:    "The source path to download from the repo. If this or --release-asset aren't specified,\n\tall files are downloaded. Can be specified more than once.",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionSourceFile,
+			Category: flagCategorySelection,
+			Usage:    "The path of a single file to download from the repo with the GitHub contents API, rather than by\n\tdownloading and extracting an archive of the whole repo. Can be specified more than once.",
+		},
+		&cli.StringFlag{
+			Name:     optionDownloadStrategy,
+			Category: flagCategorySelection,
+			Value:    fetch.DownloadStrategyZipball,
+			Usage:    "How --source-path files are downloaded: \"zipball\" (extract them from an archive of the whole repo),\n\t\"contents\" (download them one by one with the GitHub contents API), or \"auto\" (the contents API for\n\tseveral source paths below the repo root, or else the zipball).",
+		},
+		&cli.StringFlag{
+			Name:     optionReleaseAsset,
+			Category: flagCategorySelection,
+			Usage:    "The name of a release asset--that is, a binary uploaded to a GitHub Release--to download.\n\tOnly works with --tag. May use the {{.OS}}, {{.Arch}}, {{.Tag}}, and {{.Version}} placeholders.",
+		},
+		&cli.BoolFlag{
+			Name:     optionAutoAsset,
+			Category: flagCategorySelection,
+			Usage:    "Instead of --release-asset, download the release asset whose name best matches the current operating\n\tsystem and architecture, understanding synonyms such as x86_64 and aarch64. Only works with --tag.",
+		},
+		&cli.StringFlag{
+			Name:     optionOS,
+			Category: flagCategorySelection,
+			Usage:    "The operating system to fill in for {{.OS}} in --release-asset, or to look for with --auto-asset.\n\tDefaults to the current one (e.g. \"linux\").",
+		},
+		&cli.StringFlag{
+			Name:     optionArch,
+			Category: flagCategorySelection,
+			Usage:    "The architecture to fill in for {{.Arch}} in --release-asset, or to look for with --auto-asset.\n\tDefaults to the current one (e.g. \"amd64\").",
+		},
+		&cli.BoolFlag{
+			Name:     optionAllReleaseAssets,
+			Category: flagCategorySelection,
+			Usage:    "Download every asset of the release, e.g. to mirror it into an artifact store. Only works with --tag.",
+		},
+		&cli.StringFlag{
+			Name:     optionMinAssetSize,
+			Category: flagCategorySelection,
+			Usage:    "Skip release assets matching --release-asset that are smaller than this size (e.g. \"1KB\"), and fail\n\tif every matching asset is skipped.",
+		},
+		&cli.StringFlag{
+			Name:     optionMaxAssetSize,
+			Category: flagCategorySelection,
+			Usage:    "Skip release assets matching --release-asset that are larger than this size (e.g. \"500MiB\"), and fail\n\tif every matching asset is skipped.",
+		},
+		&cli.BoolFlag{
+			Name:     optionJoinParts,
+			Category: flagCategorySelection,
+			Usage:    "Join the parts of release assets that were split into several files (e.g. tool.tar.gz.part1 and\n\ttool.tar.gz.part2, tool.tar.gz.001, or the .z01 parts of a split tool.zip) into one file before verifying it.",
+		},
+		&cli.StringFlag{
+			Name:     optionReleaseAssetPickBy,
+			Category: flagCategorySelection,
+			Usage:    "If several release assets match --release-asset, download only the first one in this order:\n\t\"name\", \"size\" (largest), \"updated\" (most recent), or \"downloads\" (most downloaded).",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionReleaseAssetChecksum,
+			Category: flagCategoryVerification,
+			Usage:    "The checksum that a release asset should have. Fetch will fail if this value is non-empty\n\tand does not match any of the checksums computed by Fetch.\n\tCan be specified more than once. If more than one\n\trelease asset is downloaded and one or more checksums are provided,\n\tthe asset's checksum must match one.",
+		},
+		&cli.StringFlag{
+			Name:     optionReleaseAssetChecksumAlgo,
+			Category: flagCategoryVerification,
+			Usage:    "The algorithm Fetch will use to compute a checksum of the release asset. Acceptable values\n\tare \"sha256\", \"sha512\", \"sha1\", \"md5\", \"sha3-256\", and \"blake2b-256\". Checksums passed to\n\t--release-asset-checksum can override this per checksum with a prefix (e.g. \"sha512:<checksum>\").",
+		},
+		&cli.StringFlag{
+			Name:     optionReleaseAssetChecksumFile,
+			Category: flagCategoryVerification,
+			Usage:    "The name of a release asset (e.g. \"SHA256SUMS\"), or a URL, of a checksum file in sha256sum format.\n\tEach downloaded release asset is verified against its entry in the file.",
+		},
+		&cli.BoolFlag{
+			Name:     optionUpgradeWeakChecksums,
+			Category: flagCategoryVerification,
+			Usage:    "If a release asset is verified with an md5 or sha1 checksum, also compute its sha256 checksum and\n\tprint it in the warning about the weak checksum, so the checksum can be replaced with it.",
+		},
+		&cli.BoolFlag{
+			Name:     optionVerifyWithRepoKey,
+			Category: flagCategoryVerification,
+			Usage:    "If set, verify the signature of each release asset (published as <asset>.sig or <asset>.asc)\n\twith the public key the repo publishes at cosign.pub or signing-key.asc at the same tag.",
+		},
+		&cli.BoolFlag{
+			Name:     optionCosignVerify,
+			Category: flagCategoryVerification,
+			Usage:    "If set, verify that each release asset was signed keylessly with cosign, using the <asset>.bundle\n\tor the <asset>.sig and <asset>.pem published in the release. Requires --cosign-certificate-identity\n\tand --cosign-certificate-oidc-issuer.",
+		},
+		&cli.StringFlag{
+			Name:     optionCosignCertificateIdentity,
+			Category: flagCategoryVerification,
+			Usage:    "The identity (e.g. an email address or GitHub Actions workflow URL) the cosign signing certificate\n\tmust have been issued to.",
+		},
+		&cli.StringFlag{
+			Name:     optionCosignCertificateOidcIssuer,
+			Category: flagCategoryVerification,
+			Usage:    "The OIDC issuer (e.g. https://token.actions.githubusercontent.com) that must have issued the\n\tcosign signing identity.",
+		},
+		&cli.StringFlag{
+			Name:     optionCosignFulcioRoot,
+			Category: flagCategoryVerification,
+			Usage:    "The path of the PEM-encoded Fulcio root and intermediate certificates. Defaults to the copy cached\n\tby \"cosign initialize\" in ~/.sigstore.",
+		},
+		&cli.StringFlag{
+			Name:     optionCosignRekorPublicKey,
+			Category: flagCategoryVerification,
+			Usage:    "The path of the PEM-encoded Rekor public key. Defaults to the copy cached by \"cosign initialize\"\n\tin ~/.sigstore.",
+		},
+		&cli.StringFlag{
+			Name:     optionCosignRekorUrl,
+			Category: flagCategoryVerification,
+			Value:    "https://rekor.sigstore.dev",
+			Usage:    "The Rekor instance to look up transparency log entries in for signatures not published as a bundle.",
+		},
+		&cli.StringFlag{
+			Name:     optionPackageSigningKey,
+			Category: flagCategoryVerification,
+			Usage:    "The path of an armored PGP public key. If set, each .deb and .rpm release asset must carry an embedded\n\tsignature (made with debsigs or rpmsign) from it.",
+		},
+		&cli.BoolFlag{
+			Name:     optionCheckImmutableTag,
+			Category: flagCategoryVerification,
+			Usage:    "Before downloading, check that the tag can't be replaced upstream and warn if it can. Release assets\n\trequire an immutable release; source files also accept a ruleset blocking tag updates and deletions.",
+		},
+		&cli.BoolFlag{
+			Name:     optionRequireImmutableTag,
+			Category: flagCategoryVerification,
+			Usage:    "Like --check-immutable-tag, but fail instead of warning if the tag could be replaced upstream.",
+		},
+		&cli.StringFlag{
+			Name:     optionExpectedCommitSha,
+			Category: flagCategoryVerification,
+			Usage:    "The full SHA of the commit the tag is expected to point to. If set, fail before downloading anything if\n\tthe tag resolves to any other commit, e.g. because it was moved upstream.",
+		},
+		&cli.BoolFlag{
+			Name:     optionRequireSignedRef,
+			Category: flagCategoryVerification,
+			Usage:    "Before downloading, check that the tag, or the commit it points to, has a GPG, SSH, or S/MIME signature\n\tthat GitHub verified, and fail if neither does.",
+		},
+		&cli.StringFlag{
+			Name:     optionSignedRefKey,
+			Category: flagCategoryVerification,
+			Usage:    "The path of an armored PGP public key. If set, --require-signed-ref checks the signature against it\n\tlocally, instead of trusting GitHub's verification.",
+		},
+		&cli.StringFlag{
+			Name:     optionStdout,
+			Category: flagCategoryOutput,
+			Usage:    "If \"true\", the contents of the release asset is sent to standard output so it can be piped to another command.",
+		},
+		&cli.BoolFlag{
+			Name:     optionVerifyBeforeStdout,
+			Category: flagCategoryVerification,
+			Usage:    "If set along with a local download path of \"-\", download the release asset to a temporary file and verify\n\tit before writing any of it to stdout, rather than verifying it as it's streamed.",
+		},
+		&cli.StringFlag{
+			Name:     optionPublishS3,
+			Category: flagCategoryPublishing,
+			Usage:    "If set to a value of the form bucket/prefix, upload the verified release assets to that S3 location\n\tand print a presigned URL for each one. AWS credentials are read from the standard AWS env vars.",
+		},
+		&cli.StringFlag{
+			Name:     optionPublishS3Region,
+			Category: flagCategoryPublishing,
+			Usage:    "The AWS region of the --publish-s3 bucket. Defaults to the AWS_REGION or AWS_DEFAULT_REGION env var,\n\tor us-east-1 if neither is set.",
+		},
+		&cli.DurationFlag{
+			Name:     optionPublishS3UrlExpiry,
+			Category: flagCategoryPublishing,
+			Value:    time.Hour,
+			Usage:    "How long the presigned URLs printed by --publish-s3 remain valid.",
+		},
+		&cli.StringFlag{
+			Name:     optionEmitSbomLite,
+			Category: flagCategoryOutput,
+			Usage:    "If set, write a JSON manifest of every artifact downloaded in this run (URLs, versions, SHA256\n\thashes, and sizes) to this path.",
+		},
+		&cli.StringFlag{
+			Name:     optionSbomLiteSigningKey,
+			Category: flagCategoryOutput,
+			Usage:    "The path to a PEM-encoded Ed25519 private key used to sign the --emit-sbom-lite manifest.\n\tThe base64-encoded signature is written next to the manifest with a .sig extension.",
 		},
-		cli.StringFlag{
-			Name:  optionRef,
-			Usage: "The git reference to download. If specified, will take lower precendence than --commit, --branch, and --tag.",
+		&cli.StringFlag{
+			Name:     optionAuditLog,
+			Category: flagCategoryOutput,
+			Usage:    "If set, append a hash-chained record of what was resolved and downloaded (who, when, what, where,\n\tand SHA256 checksums) to this file. Check it with \"fetch audit-log verify\".",
 		},
-		cli.StringFlag{
-			Name:  optionCommit,
-			Usage: "The specific git commit SHA to download. If specified, will override --branch and --tag.",
+		&cli.BoolFlag{
+			Name:     optionResolveRef,
+			Category: flagCategoryOutput,
+			Usage:    "If set, look up the full SHA of the commit the tag, branch, or commit resolves to, download source\n\tfiles from exactly that commit, and print the SHA to stdout once the fetch is done (or report it\n\tas commitSha with --output=json).",
 		},
-		cli.StringFlag{
-			Name:  optionBranch,
-			Usage: "The git branch from which to download the commit; the latest commit in the branch\n\twill be used.\n\tIf specified, will override --tag.",
+		&cli.StringFlag{
+			Name:     optionGithubAPIVersion,
+			Category: flagCategoryAuth,
+			Value:    "v3",
+			Usage:    "The api version of the GitHub instance. If left blank, v3 will be used.\n\tThis will only be used if the repo url is not a github.com url.",
 		},
-		cli.StringFlag{
-			Name:  optionTag,
-			Usage: "The specific git tag to download, expressed with Version Constraint Operators.\n\tIf left blank, fetch will download the latest git tag.\n\tSee https://github.com/gruntwork-io/fetch#version-constraint-operators for examples.",
+		&cli.StringFlag{
+			Name:     optionGhesVersion,
+			Category: flagCategoryAuth,
+			Usage:    "The version of the GitHub Enterprise Server instance (e.g. \"3.8\"), used to pick API features\n\tthat instance supports. Ignored for github.com urls.",
 		},
-		cli.StringFlag{
-			Name:   optionGithubToken,
-			Usage:  "A GitHub Personal Access Token, which is required for downloading from private\n\trepos. Populate by setting env var",
-			EnvVar: envVarGithubToken,
+		&cli.BoolFlag{
+			Name:     optionDryRun,
+			Category: flagCategoryOutput,
+			Usage:    "If set, resolve the tag and match the release assets, then print what would be downloaded (URLs, sizes,\n\tand destinations) without downloading or writing anything.",
 		},
-		cli.StringSliceFlag{
-			Name:  optionSourcePath,
-			Usage: "The source path to download from the repo. If this or --release-asset aren't specified,\n\tall files are downloaded. Can be specified more than once.",
+		&cli.StringFlag{
+			Name:     optionDryRunFormat,
+			Category: flagCategoryOutput,
+			Value:    dryRunFormatText,
+			Usage:    "The format in which --dry-run prints what would be downloaded: \"text\" or \"json\".",
 		},
-		cli.StringFlag{
-			Name:  optionReleaseAsset,
-			Usage: "The name of a release asset--that is, a binary uploaded to a GitHub Release--to download.\n\tOnly works with --tag.",
+		&cli.StringFlag{
+			Name:     optionOutput,
+			Category: flagCategoryOutput,
+			Value:    outputFormatText,
+			Usage:    "How to report the result: \"text\" logs progress only, while \"json\" also prints a summary of the\n\tresolved tag, commit SHA, downloaded files with their sizes and SHA256 checksums, and timings.",
 		},
-		cli.StringSliceFlag{
-			Name:  optionReleaseAssetChecksum,
-			Usage: "The checksum that a release asset should have. Fetch will fail if this value is non-empty\n\tand does not match any of the checksums computed by Fetch.\n\tCan be specified more than once. If more than one\n\trelease asset is downloaded and one or more checksums are provided,\n\tthe asset's checksum must match one.",
+		&cli.BoolFlag{
+			Name:     optionWithProgress,
+			Category: flagCategoryOutput,
+			Usage:    "Display progress on file downloads and checksum verification, especially useful for large files",
 		},
-		cli.StringFlag{
-			Name:  optionReleaseAssetChecksumAlgo,
-			Usage: "The algorithm Fetch will use to compute a checksum of the release asset. Acceptable values\n\tare \"sha256\" and \"sha512\".",
+		&cli.IntFlag{
+			Name:     optionMaxConcurrentDownloads,
+			Category: flagCategoryPerformance,
+			Value:    fetch.DefaultMaxConcurrentDownloads,
+			Usage:    "The maximum number of release assets to download at once.",
 		},
-		cli.StringFlag{
-			Name:  optionStdout,
-			Usage: "If \"true\", the contents of the release asset is sent to standard output so it can be piped to another command.",
+		&cli.IntFlag{
+			Name:     optionDownloadConnections,
+			Category: flagCategoryPerformance,
+			Value:    1,
+			Usage:    "Download each release asset of 8MB or more over this many connections at once, each fetching\n\tits own byte range. Speeds up very large downloads when one connection can't use all the bandwidth.",
 		},
-		cli.StringFlag{
-			Name:  optionGithubAPIVersion,
-			Value: "v3",
-			Usage: "The api version of the GitHub instance. If left blank, v3 will be used.\n\tThis will only be used if the repo url is not a github.com url.",
+		&cli.BoolFlag{
+			Name:     optionPreservePermissions,
+			Category: flagCategoryWriting,
+			Usage:    "If set, files extracted from the repo keep the permissions stored in the archive (e.g. executable\n\tscripts stay executable). Otherwise, files are written with mode 0644, subject to the umask.",
 		},
-		cli.BoolFlag{
-			Name:  optionWithProgress,
-			Usage: "Display progress on file downloads, especially useful for large files",
+		&cli.BoolFlag{
+			Name:     optionPreserveSymlinks,
+			Category: flagCategoryWriting,
+			Usage:    "If set, symbolic links in the repo are recreated as symbolic links. Links pointing outside of\n\tthe local download path are rejected. Otherwise, each link is written as a file containing its target.",
 		},
-		cli.StringFlag{
-			Name:  optionLogLevel,
-			Value: logrus.InfoLevel.String(),
-			Usage: "The logging level of the command. Acceptable values\n\tare \"trace\", \"debug\", \"info\", \"warn\", \"error\", \"fatal\" and \"panic\".",
+		&cli.BoolFlag{
+			Name:     optionFollowDestSymlinks,
+			Category: flagCategoryWriting,
+			Usage:    "If set, write through symbolic links that already exist in the local download path even if they\n\tpoint outside of it. Otherwise, fetch refuses to write through such links.",
+		},
+		&cli.IntFlag{
+			Name:     optionStripComponents,
+			Category: flagCategoryWriting,
+			Usage:    "Strip this many leading path components from the files extracted from the repo, relative to the\n\tsource path (e.g. 1 places the files in /modules/vpc directly in the local download path with\n\t--source-path=/modules). Files with no more components than this are skipped.",
+		},
+		&cli.BoolFlag{
+			Name:     optionFlatten,
+			Category: flagCategoryWriting,
+			Usage:    "If set, write every file extracted from the repo directly into the local download path under its\n\tbase name, rather than recreating the folders it's in.",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionRename,
+			Category: flagCategoryWriting,
+			Usage:    "Write the file, folder, or release asset that would be written to src (relative to the local download\n\tpath) to dest instead, given as src=dest (e.g. \"terragrunt_linux_amd64=terragrunt\"). Can be specified more than once.",
+		},
+		&cli.StringFlag{
+			Name:     optionDirMode,
+			Category: flagCategoryWriting,
+			Usage:    "The octal permission mode (e.g. \"0750\") with which to create directories. Defaults to 0777.\n\tThe process umask is applied either way.",
+		},
+		&cli.StringFlag{
+			Name:     optionFileMode,
+			Category: flagCategoryWriting,
+			Usage:    "The octal permission mode (e.g. \"0640\") with which to create files, overriding the default of 0644\n\tand any modes stored in release asset archives. The process umask is applied either way.",
+		},
+		&cli.BoolFlag{
+			Name:     optionUnpack,
+			Category: flagCategoryWriting,
+			Usage:    "If set, release assets that are .zip, .tar.gz, .tgz, .tar.xz, .tar.bz2, or .gz archives are extracted\n\tinto the local download path after they are downloaded and verified.",
+		},
+		&cli.StringSliceFlag{
+			Name:     optionUnpackInclude,
+			Category: flagCategoryWriting,
+			Usage:    "If set along with --unpack, only extract the archive members matching this glob (e.g. \"bin/*\"), or inside\n\ta directory matching it. Can be specified more than once.",
+		},
+		&cli.BoolFlag{
+			Name:     optionUnpackBinary,
+			Category: flagCategoryWriting,
+			Usage:    "If set along with --unpack, only place the binary found in each archive in the local download path,\n\tmade executable and with any platform suffix stripped from its name, rather than every file.",
+		},
+		&cli.BoolFlag{
+			Name:     optionKeepArchive,
+			Category: flagCategoryWriting,
+			Usage:    "If set along with --unpack, keep the release asset archive after extracting it instead of deleting it.",
+		},
+		&cli.BoolFlag{
+			Name:     optionInstall,
+			Category: flagCategoryWriting,
+			Usage:    "Once the release asset is downloaded and verified, pick the binary out of it, make it executable, and\n\tmove it into --install-dir with any platform suffix (e.g. \"_linux_amd64\") stripped from its name.",
+		},
+		&cli.StringFlag{
+			Name:     optionInstallDir,
+			Category: flagCategoryWriting,
+			Usage:    "Required with --install. The directory to install the binary into, usually one on your PATH.",
+		},
+		&cli.StringFlag{
+			Name:     optionBinaryName,
+			Category: flagCategoryWriting,
+			Usage:    "The name to install the binary as with --install or --unpack-binary. Also picks the binary out of an\n\tarchive with several executables. Defaults to the binary's own name without its platform suffix.",
+		},
+		&cli.BoolFlag{
+			Name:     optionWaitForRateLimit,
+			Category: flagCategoryPerformance,
+			Usage:    "If the GitHub API rate limit is exhausted, wait until it resets and retry instead of failing.",
+		},
+		&cli.StringFlag{
+			Name:     optionCacheDir,
+			Category: flagCategoryPerformance,
+			Usage:    "Cache the repo archives that source files are extracted from in this directory, keyed by commit SHA,\n\tso that fetching another branch or tag that points at the same commit doesn't download it again.\n\tRelease assets are cached here too. Defaults to fetch in the user's cache directory, e.g. ~/.cache/fetch.",
+			EnvVars:  []string{envVarCacheDir},
+		},
+		&cli.StringFlag{
+			Name:     optionArchiveCacheDir,
+			Category: flagCategoryPerformance,
+			Hidden:   true, // The original name of --cache-dir
+			EnvVars:  []string{envVarArchiveCacheDir},
+		},
+		&cli.BoolFlag{
+			Name:     optionNoCache,
+			Category: flagCategoryPerformance,
+			Usage:    "Don't read from or write to the cache, even if --cache-dir is set.",
+		},
+		&cli.StringFlag{
+			Name:     optionLinkMode,
+			Category: flagCategoryPerformance,
+			Value:    fetch.LinkModeReflink,
+			Usage:    "How release assets served from the cache are placed in the download path: \"reflink\" (copy-on-write\n\twhere supported, and a copy elsewhere), \"hardlink\", \"symlink\" (not with --no-cache), or \"copy\".",
+		},
+		&cli.StringFlag{
+			Name:     optionLogLevel,
+			Category: flagCategoryOutput,
+			Value:    logrus.InfoLevel.String(),
+			Usage:    "The logging level of the command. Acceptable values\n\tare \"trace\", \"debug\", \"info\", \"warn\", \"error\", \"fatal\" and \"panic\".",
+		},
+		&cli.BoolFlag{
+			Name:     optionQuiet,
+			Aliases:  []string{"q"},
+			Category: flagCategoryOutput,
+			Usage:    "Only log errors. Nothing else is written to stderr, and stdout only gets what was asked for, such as\n\tthe release asset with --stdout. Cannot be used with --log-level or --progress.",
+		},
+		&cli.StringFlag{
+			Name:     optionLogFormat,
+			Category: flagCategoryOutput,
+			Value:    logFormatText,
+			Usage:    "The format of the logs: \"text\", \"json\", or \"console\", which lines up the time, level, and\n\tmessage of each entry in columns. Defaults to \"json\" with the CI profile.",
+		},
+		&cli.StringFlag{
+			Name:     optionLogColor,
+			Category: flagCategoryOutput,
+			Value:    logColorAuto,
+			Usage:    "When to color the \"text\" and \"console\" logs by level: \"always\", \"never\", or \"auto\", which colors\n\tthem only when stderr is a terminal and the NO_COLOR env var isn't set.",
+		},
+		&cli.StringFlag{
+			Name:     optionLocale,
+			Category: flagCategoryOutput,
+			Value:    localeAuto,
+			Usage:    "The language of the explanations of common errors: \"en\", \"ja\", \"de\", or \"auto\", which uses the\n\tlanguage of the LC_ALL, LC_MESSAGES, or LANG env var, or English if there are no explanations in it.",
+		},
+		&cli.StringFlag{
+			Name:     optionProfile,
+			Category: flagCategoryOutput,
+			Value:    profileAuto,
+			Usage:    "The profile of defaults to run with: \"ci\" (JSON logs, no progress, and waiting out rate limits),\n\t\"none\", or \"auto\", which uses \"ci\" when a CI system such as GitHub Actions is detected. Flags set\n\texplicitly always take precedence over the profile.",
+			EnvVars:  []string{envVarProfile},
+		},
+		&cli.StringFlag{
+			Name:     optionConfig,
+			Category: flagCategoryOutput,
+			Usage:    "The path of a YAML file of defaults for any of these flags, e.g. \"log-level: debug\". Defaults to ~/.fetchrc\n\tif it exists. Flags and their FETCH_* env vars take precedence over it.",
 		},
 	}
-
-	return app
+	return withOptionEnvVars(append(flags, connectionFlags()...))
 }
 
 func main() {
@@ -152,134 +684,224 @@ func main() {
 }
 
 // initLogger initializes the Logger before any command is actually executed. This function will handle all the setup
-// code, such as setting up the logger with the appropriate log level.
+// code, such as reading defaults from the config file and setting up the logger with the appropriate log level.
 func initLogger(cliContext *cli.Context) error {
+	if err := applyConfigFile(cliContext); err != nil {
+		return err
+	}
+
 	// Set logging level
-	logLevel := cliContext.String(optionLogLevel)
-	level, err := logrus.ParseLevel(logLevel)
+	level, err := resolveLogLevel(cliContext)
 	if err != nil {
-		return fmt.Errorf("Error: %s\n", err)
+		return err
 	}
 	logging.SetGlobalLogLevel(level)
+	fetch.SetLogOutput(cliContext.App.ErrWriter)
+
+	ci, ciEnvVar, err := resolveCiProfile(cliContext.String(optionProfile), os.Getenv)
+	if err != nil {
+		return err
+	}
+	format := logFormat(cliContext, ci)
+	if err := validateLogFormat(format); err != nil {
+		return err
+	}
+	colors, err := resolveLogColor(cliContext.String(optionLogColor), term.IsTerminal(int(os.Stderr.Fd())), os.Getenv)
+	if err != nil {
+		return err
+	}
+	logging.SetGlobalLogFormatter(format)
+	fetch.SetLogFormatter(newLogFormatter(format, colors))
+
+	if err := fetch.ValidateLocale(resolveLocale(cliContext)); err != nil {
+		return err
+	}
+
+	if ciEnvVar != "" {
+		fetch.GetProjectLogger().Debugf("Detected a CI environment (%s is set), so using the CI profile. Use --%s=%s to turn it off.\n", ciEnvVar, optionProfile, profileNone)
+	}
 	return nil
 }
 
-// We just want to call runFetch(), but app.Action won't permit us to return an error, so call a wrapper function instead.
-func runFetchWrapper(c *cli.Context) {
+// The --locale that picks the locale of the environment
+const localeAuto = "auto"
+
+// Return the locale of the explanations of common errors that --locale asks for, which with "auto" is the one of the
+// environment
+func resolveLocale(c *cli.Context) string {
+	locale := c.String(optionLocale)
+	if locale == localeAuto {
+		return fetch.LocaleFromEnv(os.Getenv)
+	}
+	return locale
+}
+
+// The exit status of a program that was interrupted by a signal, following the shell convention of 128 + SIGINT
+const exitCodeInterrupted = 130
+
+// We just want to call runFetch(), but an error returned by app.Action is neither logged nor turned into an exit code,
+// so call a wrapper function instead.
+func runFetchWrapper(c *cli.Context) error {
 	// initialize the logger
-	logger := GetProjectLogger()
-	err := runFetch(c, logger)
-	if err != nil {
-		logger.Errorf("%s\n", err)
-		os.Exit(1)
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runFetch(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Return a context that is canceled when fetch receives SIGINT (e.g. Ctrl-C) or SIGTERM, which cancels any in-flight
+// requests and removes the files they were partway through writing
+func newInterruptibleContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// If err is set, log it and exit. If ctx was canceled by a signal, the error is just a side effect of the cancellation,
+// so exit with the conventional status for an interrupted program instead.
+func exitOnError(ctx context.Context, logger *logrus.Entry, err error) {
+	if err == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		logger.Warnf("Interrupted. Canceled all in-flight downloads and removed partially downloaded files.\n")
+		os.Exit(exitCodeInterrupted)
 	}
+	logger.Errorf("%s\n", err)
+	os.Exit(1)
 }
 
 // Run the fetch program
-func runFetch(c *cli.Context, logger *logrus.Entry) error {
+func runFetch(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
 	options := parseOptions(c, logger)
 	if err := validateOptions(options); err != nil {
 		return err
 	}
+	return runValidatedFetch(ctx, c, options)
+}
 
-	instance, fetchErr := ParseUrlIntoGithubInstance(logger, options.RepoUrl, options.GithubApiVersion)
-	if fetchErr != nil {
-		return fetchErr
-	}
-
-	// Get the tags for the given repo
-	tags, fetchErr := FetchTags(options.RepoUrl, options.GithubToken, instance)
-	if fetchErr != nil {
-		if fetchErr.errorCode == invalidGithubTokenOrAccessDenied {
-			return errors.New(getErrorMessage(invalidGithubTokenOrAccessDenied, fetchErr.details))
-		} else if fetchErr.errorCode == repoDoesNotExistOrAccessDenied {
-			return errors.New(getErrorMessage(repoDoesNotExistOrAccessDenied, fetchErr.details))
-		} else {
-			return fmt.Errorf("Error occurred while getting tags from GitHub repo: %s", fetchErr)
-		}
+// Run the fetch described by the given options, which have already been parsed from c and validated
+func runValidatedFetch(ctx context.Context, c *cli.Context, options fetch.Options) error {
+	dryRunFormat := c.String(optionDryRunFormat)
+	if err := validateDryRunFormat(dryRunFormat); err != nil {
+		return err
 	}
 
-	var specific bool
-	var desiredTag string
-	var tagConstraint string
-
-	if options.GitRef != "" {
-		specific, desiredTag = isTagConstraintSpecificTag(options.GitRef)
-		tagConstraint = options.GitRef
-	} else {
-		specific, desiredTag = isTagConstraintSpecificTag(options.TagConstraint)
-		tagConstraint = options.TagConstraint
+	outputFormat := c.String(optionOutput)
+	if err := validateOutput(outputFormat, options, c.Bool(optionDryRun)); err != nil {
+		return err
 	}
 
-	if !specific {
-		// Find the specific release that matches the latest version constraint
-		latestTag, err := getLatestAcceptableTag(tagConstraint, tags)
+	if usesGithubToken(options) {
+		token, err := resolveGithubToken(ctx, c, options.RepoUrl, options.GithubToken)
 		if err != nil {
-			if err.errorCode == invalidTagConstraintExpression {
-				return errors.New(getErrorMessage(invalidTagConstraintExpression, err.details))
-			} else {
-				return fmt.Errorf("Error occurred while computing latest tag that satisfies version contraint expression: %s", err)
-			}
+			return err
 		}
-		desiredTag = latestTag
+		options.GithubToken = token
 	}
 
-	// Prepare the vars we'll need to download
-	repo, fetchErr := ParseUrlIntoGitHubRepo(options.RepoUrl, options.GithubToken, instance)
-	if fetchErr != nil {
-		return fmt.Errorf("Error occurred while parsing GitHub URL: %s", fetchErr)
+	fetcher, err := fetch.NewFetcher(options)
+	if err != nil {
+		return err
 	}
 
-	// If no release asset and no source paths are specified, then by default, download all the source files from the repo
-	if len(options.SourcePaths) == 0 && options.ReleaseAsset == "" {
-		options.SourcePaths = []string{"/"}
+	if c.Bool(optionDryRun) {
+		plan, err := fetcher.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		return writeFetchPlan(c.App.Writer, plan, dryRunFormat)
 	}
 
-	// Download any requested source files
-	if err := downloadSourcePaths(logger, options.SourcePaths, options.LocalDownloadPath, repo, desiredTag, options.BranchName, options.CommitSha, instance); err != nil {
-		return err
+	if outputFormat == outputFormatJson {
+		// The presigned URLs Fetch would write to stdout are in the summary instead
+		result, err := fetcher.Fetch(ctx, io.Discard)
+		if err != nil {
+			return err
+		}
+		return writeFetchSummary(c.App.Writer, result)
 	}
 
-	// Download the requested release assets
-	assetPaths, err := downloadReleaseAssets(logger, options.ReleaseAsset, options.LocalDownloadPath, repo, desiredTag, options.WithProgress)
+	result, err := fetcher.Fetch(ctx, c.App.Writer)
 	if err != nil {
 		return err
 	}
+	if options.ResolveRef {
+		fmt.Fprintln(c.App.Writer, result.CommitSha)
+	}
+	return nil
+}
 
-	// If applicable, verify the release asset
-	if len(options.ReleaseAssetChecksums) > 0 {
-		for _, assetPath := range assetPaths {
-			fetchErr = verifyChecksumOfReleaseAsset(logger, assetPath, options.ReleaseAssetChecksums, options.ReleaseAssetChecksumAlgo)
-			if fetchErr != nil {
-				return fetchErr
-			}
-		}
+// Return true if the given options fetch from GitHub, and so may need a token. A file downloaded from a URL, a mirror, a
+// Go module proxy, an artifact repository, a GitLab package registry, or a Bitbucket Server repo is never sent the GitHub
+// token, so there's no need to look it up.
+func usesGithubToken(options fetch.Options) bool {
+	switch {
+	case options.Url != "" || fetch.IsBucketMirrorUrl(options.RepoUrl) || isBitbucketServerRepo(options):
+		return false
+	case options.Source == fetch.SourceGoProxy || options.Source == fetch.SourceArtifactory || options.Source == fetch.SourceNexus || options.Source == fetch.SourceGitlabPackage:
+		return false
+	default:
+		return true
 	}
+}
 
-	if options.Stdout {
-		// Print to stdout only if a single asset was downloaded
-		if len(assetPaths) == 1 {
-			dat, err := os.ReadFile(assetPaths[0])
-			if err != nil {
-				return err
-			}
-			c.App.Writer.Write(dat) // This should be stdout
-		} else {
+// Return true if the given options fetch from a Bitbucket Server repo, which is read with --bitbucket-token
+func isBitbucketServerRepo(options fetch.Options) bool {
+	if options.Url != "" {
+		return false
+	}
+	return options.Source == fetch.SourceBitbucketDc || (options.Source == fetch.SourceAuto || options.Source == "") && fetch.ParseBitbucketServerRepo(options.RepoUrl, "") != nil
+}
 
-			if len(assetPaths) > 1 {
-				logger.Warn("Multiple assets were downloaded. Ignoring --stdout")
-			} else {
-				logger.Warn("No assets were downloaded. Ignoring --stdout")
-			}
+// Return the GitHub token to use for the repo at the given URL: the output of --token-command or the contents of
+// --github-oauth-token-file, if either is set, or else the given token from --github-oauth-token, or else the first one
+// found in the --credential-fallback sources
+func resolveGithubToken(ctx context.Context, c *cli.Context, repoUrl string, token string) (string, error) {
+	fallback := c.StringSlice(optionCredentialFallback)
+	if err := fetch.ValidateCredentialSources(fallback); err != nil {
+		return "", err
+	}
 
-		}
+	command := c.String(optionTokenCommand)
+	tokenFile := c.String(optionGithubTokenFile)
+	if command != "" && tokenFile != "" {
+		return "", fmt.Errorf("The --%s and --%s flags can't both be set.", optionTokenCommand, optionGithubTokenFile)
+	}
+	if command != "" {
+		return fetch.RunTokenCommand(ctx, command)
 	}
+	if tokenFile != "" {
+		return fetch.ReadTokenFile(tokenFile, c.App.Reader)
+	}
+	if token != "" || len(fallback) == 0 {
+		return token, nil
+	}
+	return fetch.LookupCredential(ctx, fetch.GetProjectLogger(), credentialHost(repoUrl), fallback)
+}
 
-	return nil
+func parseArtifactRepoAuth(c *cli.Context) fetch.ArtifactRepoAuth {
+	return fetch.ArtifactRepoAuth{
+		Username: c.String(optionArtifactRepoUsername),
+		Password: c.String(optionArtifactRepoPassword),
+		ApiKey:   c.String(optionArtifactRepoApiKey),
+	}
 }
 
-func parseOptions(c *cli.Context, logger *logrus.Entry) FetchOptions {
+// Return the host to look up credentials for the repo at the given URL with, which is github.com if the URL has none
+func credentialHost(repoUrl string) string {
+	if host := fetch.RepoUrlHost(repoUrl); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+func parseOptions(c *cli.Context, logger *logrus.Entry) fetch.Options {
 	localDownloadPath := c.Args().First()
+	if c.NArg() == 0 {
+		localDownloadPath = os.Getenv(envVarDownloadPath)
+	}
 	sourcePaths := c.StringSlice(optionSourcePath)
 	assetChecksums := c.StringSlice(optionReleaseAssetChecksum)
 	assetChecksumMap := make(map[string]bool, len(assetChecksums))
@@ -296,243 +918,439 @@ func parseOptions(c *cli.Context, logger *logrus.Entry) FetchOptions {
 		assetChecksumMap[assetChecksum] = true
 	}
 
-	return FetchOptions{
+	// An unknown profile is reported by initLogger, so it can be treated as no profile here
+	ci, _, _ := resolveCiProfile(c.String(optionProfile), os.Getenv)
+
+	return fetch.Options{
 		RepoUrl:                  c.String(optionRepo),
+		Source:                   c.String(optionSource),
+		Url:                      c.String(optionUrl),
 		GitRef:                   c.String(optionRef),
 		CommitSha:                c.String(optionCommit),
 		BranchName:               c.String(optionBranch),
 		TagConstraint:            c.String(optionTag),
+		Channel:                  c.String(optionChannel),
+		IncludePrereleases:       c.Bool(optionIncludePrereleases),
+		IncludeDrafts:            c.Bool(optionIncludeDrafts),
+		LooseSemver:              c.Bool(optionLooseSemver),
+		TagPrefix:                c.String(optionTagPrefix),
 		GithubToken:              c.String(optionGithubToken),
 		SourcePaths:              sourcePaths,
+		SourceFiles:              c.StringSlice(optionSourceFile),
+		DownloadStrategy:         c.String(optionDownloadStrategy),
 		ReleaseAsset:             c.String(optionReleaseAsset),
+		ReleaseAssetPickBy:       c.String(optionReleaseAssetPickBy),
+		AutoAsset:                c.Bool(optionAutoAsset),
+		OS:                       c.String(optionOS),
+		Arch:                     c.String(optionArch),
+		AllReleaseAssets:         c.Bool(optionAllReleaseAssets),
+		MinAssetSize:             c.String(optionMinAssetSize),
+		MaxAssetSize:             c.String(optionMaxAssetSize),
+		JoinParts:                c.Bool(optionJoinParts),
 		ReleaseAssetChecksums:    assetChecksumMap,
 		ReleaseAssetChecksumAlgo: c.String(optionReleaseAssetChecksumAlgo),
-		Stdout:                   c.String(optionStdout) == "true",
-		LocalDownloadPath:        localDownloadPath,
-		GithubApiVersion:         c.String(optionGithubAPIVersion),
-		WithProgress:             c.IsSet(optionWithProgress),
-		Logger:                   logger,
+		ReleaseAssetChecksumFile: c.String(optionReleaseAssetChecksumFile),
+		UpgradeWeakChecksums:     c.Bool(optionUpgradeWeakChecksums),
+		VerifyWithRepoKey:        c.Bool(optionVerifyWithRepoKey),
+		CosignVerify:             c.Bool(optionCosignVerify),
+		PackageSigningKey:        c.String(optionPackageSigningKey),
+		CosignVerifyOptions: fetch.CosignVerifyOptions{
+			CertificateIdentity:   c.String(optionCosignCertificateIdentity),
+			CertificateOidcIssuer: c.String(optionCosignCertificateOidcIssuer),
+			FulcioRootPath:        c.String(optionCosignFulcioRoot),
+			RekorPublicKeyPath:    c.String(optionCosignRekorPublicKey),
+			RekorUrl:              c.String(optionCosignRekorUrl),
+		},
+		CheckImmutableTag:      c.Bool(optionCheckImmutableTag),
+		RequireImmutableTag:    c.Bool(optionRequireImmutableTag),
+		ExpectedCommitSha:      c.String(optionExpectedCommitSha),
+		RequireSignedRef:       c.Bool(optionRequireSignedRef),
+		SignedRefKey:           c.String(optionSignedRefKey),
+		Stdout:                 c.String(optionStdout) == "true",
+		LocalDownloadPath:      localDownloadPath,
+		VerifyBeforeStdout:     c.Bool(optionVerifyBeforeStdout),
+		GithubApiVersion:       c.String(optionGithubAPIVersion),
+		GhesVersion:            c.String(optionGhesVersion),
+		Connection:             parseConnectionOptions(c),
+		ArtifactRepoAuth:       parseArtifactRepoAuth(c),
+		GitlabToken:            c.String(optionGitlabToken),
+		BitbucketToken:         c.String(optionBitbucketToken),
+		WithProgress:           c.Bool(optionWithProgress),
+		MaxConcurrentDownloads: c.Int(optionMaxConcurrentDownloads),
+		DownloadConnections:    c.Int(optionDownloadConnections),
+		WaitForRateLimit:       boolFlagOrDefault(c, optionWaitForRateLimit, ci),
+		ArchiveCacheDir:        cacheDir(c, logger),
+		LinkMode:               c.String(optionLinkMode),
+		Unpack:                 c.Bool(optionUnpack),
+		UnpackInclude:          c.StringSlice(optionUnpackInclude),
+		UnpackBinary:           c.Bool(optionUnpackBinary),
+		KeepArchive:            c.Bool(optionKeepArchive),
+		Install:                c.Bool(optionInstall),
+		InstallDir:             c.String(optionInstallDir),
+		BinaryName:             c.String(optionBinaryName),
+		PreservePermissions:    c.Bool(optionPreservePermissions),
+		PreserveSymlinks:       c.Bool(optionPreserveSymlinks),
+		StripComponents:        c.Int(optionStripComponents),
+		Flatten:                c.Bool(optionFlatten),
+		Renames:                c.StringSlice(optionRename),
+		FollowDestSymlinks:     c.Bool(optionFollowDestSymlinks),
+		DirMode:                c.String(optionDirMode),
+		FileMode:               c.String(optionFileMode),
+		PublishS3:              c.String(optionPublishS3),
+		PublishS3Region:        c.String(optionPublishS3Region),
+		PublishS3UrlExpiry:     c.Duration(optionPublishS3UrlExpiry),
+		EmitSbomLite:           c.String(optionEmitSbomLite),
+		RecordChecksums:        c.String(optionOutput) == outputFormatJson,
+		SbomLiteSigningKey:     c.String(optionSbomLiteSigningKey),
+		AuditLog:               c.String(optionAuditLog),
+		ResolveRef:             c.Bool(optionResolveRef),
+		ToolVersion:            VERSION,
+		Logger:                 logger,
+		Locale:                 resolveLocale(c),
 	}
 }
 
-func validateOptions(options FetchOptions) error {
-	if options.RepoUrl == "" {
+// Return true if options ask for a tag to be resolved, either from a tag constraint or a release channel
+func resolvesTag(options fetch.Options) bool {
+	return options.TagConstraint != "" || options.Channel != ""
+}
+
+// Return true if options ask for release assets to be downloaded. A file downloaded from a URL is verified, installed,
+// and unpacked just like a release asset.
+func downloadsReleaseAssets(options fetch.Options) bool {
+	return options.ReleaseAsset != "" || options.AllReleaseAssets || options.AutoAsset || options.Url != ""
+}
+
+// Return an error if options stream a release asset to stdout (with a local download path of "-") but also ask for
+// anything that needs the asset on disk, or that writes to stdout itself. Checksums are verified as the asset is
+// streamed, but signatures can only be verified with --verify-before-stdout, which downloads the asset first.
+func validateStreamToStdout(options fetch.Options) error {
+	if options.LocalDownloadPath != fetch.StdoutDownloadPath {
+		if options.VerifyBeforeStdout {
+			return fmt.Errorf("The --%s flag can only be used with a local download path of \"%s\". Run \"fetch --help\" for full usage info.", optionVerifyBeforeStdout, fetch.StdoutDownloadPath)
+		}
+		return nil
+	}
+	if options.ReleaseAsset == "" && !options.AutoAsset {
+		return fmt.Errorf("A local download path of \"%s\" can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", fetch.StdoutDownloadPath, optionReleaseAsset, optionAutoAsset)
+	}
+
+	conflicts := []struct {
+		isSet  bool
+		option string
+	}{
+		{len(options.SourcePaths) > 0, optionSourcePath},
+		{len(options.SourceFiles) > 0, optionSourceFile},
+		{options.AllReleaseAssets, optionAllReleaseAssets},
+		{options.JoinParts, optionJoinParts},
+		{options.DownloadConnections > 1, optionDownloadConnections},
+		{options.VerifyWithRepoKey && !options.VerifyBeforeStdout, optionVerifyWithRepoKey},
+		{options.CosignVerify && !options.VerifyBeforeStdout, optionCosignVerify},
+		{options.PackageSigningKey != "" && !options.VerifyBeforeStdout, optionPackageSigningKey},
+		{options.Stdout, optionStdout},
+		{options.Unpack, optionUnpack},
+		{options.Install, optionInstall},
+		{len(options.Renames) > 0, optionRename},
+		{options.PublishS3 != "", optionPublishS3},
+		{options.EmitSbomLite != "", optionEmitSbomLite},
+	}
+	for _, conflict := range conflicts {
+		if conflict.isSet {
+			return fmt.Errorf("The --%s flag cannot be used with a local download path of \"%s\", which streams the release asset to stdout without writing it to disk. Run \"fetch --help\" for full usage info.", conflict.option, fetch.StdoutDownloadPath)
+		}
+	}
+	return nil
+}
+
+// Return an error if the link mode in options is unknown, or is a symlink into a cache that won't outlive the run
+func validateLinkMode(options fetch.Options) error {
+	if err := fetch.ValidateLinkMode(options.LinkMode); err != nil {
+		return err
+	}
+	if options.LinkMode == fetch.LinkModeSymlink && options.ArchiveCacheDir == "" {
+		return fmt.Errorf("The --%s=%s flag cannot be used with --%s, as the links would point into a cache that doesn't outlive the run. Run \"fetch --help\" for full usage info.", optionLinkMode, fetch.LinkModeSymlink, optionNoCache)
+	}
+	return nil
+}
+
+func validateOptions(options fetch.Options) error {
+	if options.RepoUrl == "" && options.Url == "" {
 		return fmt.Errorf("The --%s flag is required. Run \"fetch --help\" for full usage info.", optionRepo)
 	}
 
 	if options.LocalDownloadPath == "" {
-		return fmt.Errorf("Missing required arguments specifying the local download path. Run \"fetch --help\" for full usage info.")
+		return fmt.Errorf("Missing required arguments specifying the local download path, which can also be set with the %s env var. Run \"fetch --help\" for full usage info.", envVarDownloadPath)
 	}
 
-	if options.GitRef == "" && options.TagConstraint == "" && options.CommitSha == "" && options.BranchName == "" {
+	if options.Url == "" && options.GitRef == "" && !resolvesTag(options) && options.CommitSha == "" && options.BranchName == "" {
 		return fmt.Errorf("You must specify exactly one of --%s, --%s, --%s, or --%s. Run \"fetch --help\" for full usage info.", optionRef, optionTag, optionCommit, optionBranch)
 	}
 
-	if options.ReleaseAsset != "" && options.TagConstraint == "" {
+	if options.Channel != "" {
+		if options.GitRef != "" || options.CommitSha != "" || options.BranchName != "" {
+			return fmt.Errorf("The --%s flag cannot be used with --%s, --%s, or --%s. Run \"fetch --help\" for full usage info.", optionChannel, optionRef, optionCommit, optionBranch)
+		}
+		if err := fetch.ValidateChannel(options.Channel); err != nil {
+			return err
+		}
+		if options.IncludePrereleases {
+			return fmt.Errorf("The --%s flag cannot be used with --%s, which already says which pre-releases to include. Run \"fetch --help\" for full usage info.", optionIncludePrereleases, optionChannel)
+		}
+	}
+
+	if options.TagPrefix != "" && options.GitRef == "" && !resolvesTag(options) {
+		return fmt.Errorf("The --%s flag can only be used with --%s, --%s, or --%s. Run \"fetch --help\" for full usage info.", optionTagPrefix, optionRef, optionTag, optionChannel)
+	}
+
+	if options.IncludeDrafts && !usesGithubToken(options) {
+		return fmt.Errorf("The --%s flag can only be used with GitHub repos, as only GitHub has draft releases. Run \"fetch --help\" for full usage info.", optionIncludeDrafts)
+	}
+
+	for _, sourceFile := range options.SourceFiles {
+		if name := path.Base(strings.Trim(sourceFile, "/")); name == "." || name == ".." || strings.HasSuffix(sourceFile, "/") {
+			return fmt.Errorf("The --%s value \"%s\" must be the path of a file in the repo. Use --%s to download a folder.", optionSourceFile, sourceFile, optionSourcePath)
+		}
+	}
+
+	if err := fetch.ValidateSource(options.Source); err != nil {
+		return err
+	}
+	if options.ArtifactRepoAuth != (fetch.ArtifactRepoAuth{}) && options.Source != fetch.SourceArtifactory && options.Source != fetch.SourceNexus {
+		return fmt.Errorf("The --%s, --%s, and --%s flags can only be used with --%s=%s or --%s=%s. Run \"fetch --help\" for full usage info.", optionArtifactRepoUsername, optionArtifactRepoPassword, optionArtifactRepoApiKey, optionSource, fetch.SourceArtifactory, optionSource, fetch.SourceNexus)
+	}
+	if options.GitlabToken != "" && options.Source != fetch.SourceGitlabPackage {
+		return fmt.Errorf("The --%s flag can only be used with --%s=%s. Run \"fetch --help\" for full usage info.", optionGitlabToken, optionSource, fetch.SourceGitlabPackage)
+	}
+	if options.BitbucketToken != "" && !isBitbucketServerRepo(options) {
+		return fmt.Errorf("The --%s flag can only be used with a Bitbucket Server repo. Run \"fetch --help\" for full usage info.", optionBitbucketToken)
+	}
+	if err := fetch.ValidateDownloadStrategy(options.DownloadStrategy); err != nil {
+		return err
+	}
+	if options.DownloadStrategy == fetch.DownloadStrategyContents && (options.PreservePermissions || options.PreserveSymlinks) {
+		return fmt.Errorf("The --%s and --%s flags cannot be used with --%s=%s, as the GitHub contents API doesn't return file modes or symlinks. Run \"fetch --help\" for full usage info.", optionPreservePermissions, optionPreserveSymlinks, optionDownloadStrategy, fetch.DownloadStrategyContents)
+	}
+
+	if (options.CheckImmutableTag || options.RequireImmutableTag) && (options.CommitSha != "" || options.BranchName != "") {
+		return fmt.Errorf("The --%s and --%s flags cannot be used with --%s or --%s, which download from a commit rather than a tag. Run \"fetch --help\" for full usage info.", optionCheckImmutableTag, optionRequireImmutableTag, optionCommit, optionBranch)
+	}
+
+	if options.ExpectedCommitSha != "" && (options.CommitSha != "" || options.BranchName != "") {
+		return fmt.Errorf("The --%s flag cannot be used with --%s or --%s, as it checks the commit a tag points to. Run \"fetch --help\" for full usage info.", optionExpectedCommitSha, optionCommit, optionBranch)
+	}
+
+	if options.ReleaseAsset != "" && !resolvesTag(options) {
 		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionReleaseAsset, optionTag)
 	}
 
-	if len(options.ReleaseAssetChecksums) > 0 && options.ReleaseAssetChecksumAlgo == "" {
-		return fmt.Errorf("If the %s flag is set, you must also enter a value for the %s flag.", optionReleaseAssetChecksum, optionReleaseAssetChecksumAlgo)
+	if _, err := fetch.ExpandReleaseAssetTemplate(options.ReleaseAsset, fetch.ReleaseAssetTemplateVars{}); err != nil {
+		return err
 	}
 
-	return nil
-}
+	if (options.OS != "" || options.Arch != "") && options.ReleaseAsset == "" && !options.AutoAsset {
+		return fmt.Errorf("The --%s and --%s flags can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionOS, optionArch, optionReleaseAsset, optionAutoAsset)
+	}
 
-// Download the specified source files from the given repo
-func downloadSourcePaths(logger *logrus.Entry, sourcePaths []string, destPath string, githubRepo GitHubRepo, latestTag string, branchName string, commitSha string, instance GitHubInstance) error {
-	if len(sourcePaths) == 0 {
-		return nil
+	if options.AutoAsset {
+		if options.ReleaseAsset != "" || options.AllReleaseAssets {
+			return fmt.Errorf("The --%s flag cannot be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionAutoAsset, optionReleaseAsset, optionAllReleaseAssets)
+		}
+		if !resolvesTag(options) {
+			return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionAutoAsset, optionTag)
+		}
 	}
 
-	// We want to respect the GitHubCommit Hierarchy of "CommitSha > GitTag > BranchName"
-	// Note that CommitSha or BranchName may be blank here if the user did not specify values for these.
-	// If the user specified no value for GitTag, our call to getLatestAcceptableTag() above still gave us some value
-	// So we can guarantee (at least logically) that this struct instance is in a valid state right now.
-	gitHubCommit := GitHubCommit{
-		Repo:       githubRepo,
-		GitRef:     latestTag,
-		GitTag:     latestTag,
-		BranchName: branchName,
-		CommitSha:  commitSha,
-	}
-
-	// Download that release as a .zip file
-
-	// Ordering matters in this conditional
-	// GitRef needs to be the fallback and therefore must be last
-	// See https://github.com/gruntwork-io/fetch/issues/87 for an example
-	if gitHubCommit.CommitSha != "" {
-		logger.Infof("Downloading git commit \"%s\" of %s ...\n", gitHubCommit.CommitSha, githubRepo.Url)
-	} else if gitHubCommit.BranchName != "" {
-		logger.Infof("Downloading latest commit from branch \"%s\" of %s ...\n", gitHubCommit.BranchName, githubRepo.Url)
-	} else if gitHubCommit.GitTag != "" {
-		logger.Infof("Downloading tag \"%s\" of %s ...\n", latestTag, githubRepo.Url)
-	} else if gitHubCommit.GitRef != "" {
-		logger.Infof("Downloading git reference \"%s\" of %s ...\n", gitHubCommit.GitRef, githubRepo.Url)
-	} else {
-		return fmt.Errorf("The commit sha, tag, and branch name are all empty")
-	}
-
-	localZipFilePath, err := downloadGithubZipFile(logger, gitHubCommit, githubRepo.Token, instance)
-	if err != nil {
-		return fmt.Errorf("Error occurred while downloading zip file from GitHub repo: %s", err)
+	if options.AllReleaseAssets {
+		if options.ReleaseAsset != "" {
+			return fmt.Errorf("The --%s flag cannot be used with --%s. Run \"fetch --help\" for full usage info.", optionAllReleaseAssets, optionReleaseAsset)
+		}
+		if !resolvesTag(options) {
+			return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionAllReleaseAssets, optionTag)
+		}
 	}
-	defer cleanupZipFile(localZipFilePath)
 
-	// Unzip and move the files we need to our destination
-	for _, sourcePath := range sourcePaths {
-		logger.Infof("Extracting files from <repo>%s to %s ...\n", sourcePath, destPath)
+	if options.ReleaseAssetPickBy != "" {
+		if options.ReleaseAsset == "" && !options.AutoAsset {
+			return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionReleaseAssetPickBy, optionReleaseAsset, optionAutoAsset)
+		}
+		if err := fetch.ValidateAssetOrder(options.ReleaseAssetPickBy); err != nil {
+			return err
+		}
+	}
 
-		fileCount, err := extractFiles(localZipFilePath, sourcePath, destPath)
-		plural := ""
-		if fileCount != 1 {
-			plural = "s"
+	if options.MinAssetSize != "" || options.MaxAssetSize != "" {
+		if !downloadsReleaseAssets(options) {
+			return fmt.Errorf("The --%s and --%s flags can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionMinAssetSize, optionMaxAssetSize, optionReleaseAsset, optionAllReleaseAssets)
 		}
-		logger.Infof("%d file%s extracted\n", fileCount, plural)
+		minSize, err := fetch.ParseAssetSize(optionMinAssetSize, options.MinAssetSize)
 		if err != nil {
-			return fmt.Errorf("Error occurred while extracting files from GitHub zip file: %s", err.Error())
+			return err
 		}
+		maxSize, err := fetch.ParseAssetSize(optionMaxAssetSize, options.MaxAssetSize)
+		if err != nil {
+			return err
+		}
+		if minSize > 0 && maxSize > 0 && minSize > maxSize {
+			return fmt.Errorf("The --%s value must not be larger than the --%s value.", optionMinAssetSize, optionMaxAssetSize)
+		}
+	}
 
+	if options.JoinParts && !downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionJoinParts, optionReleaseAsset, optionAllReleaseAssets)
 	}
 
-	logger.Infof("Download and file extraction complete.\n")
-	return nil
-}
+	if options.ReleaseAssetChecksumFile != "" && !downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionReleaseAssetChecksumFile, optionReleaseAsset, optionAllReleaseAssets)
+	}
 
-// Download any matching files that were uploaded as release assets to the specified GitHub release.
-// Each file that matches the assetRegex will be downloaded in a separate go routine. If any of the
-// downloads fail, an error will be returned. It is possible that only some of the matching assets
-// were downloaded. For those that succeeded, the path they were downloaded to will be passed back
-// along with the error.
-// Returns the paths where the release assets were downloaded.
-func downloadReleaseAssets(logger *logrus.Entry, assetRegex string, destPath string, githubRepo GitHubRepo, tag string, withProgress bool) ([]string, error) {
-	var err error
-	var assetPaths []string
+	if options.VerifyWithRepoKey && !downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionVerifyWithRepoKey, optionReleaseAsset, optionAllReleaseAssets)
+	}
 
-	if assetRegex == "" {
-		return assetPaths, nil
+	if options.CosignVerify {
+		if !downloadsReleaseAssets(options) {
+			return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionCosignVerify, optionReleaseAsset, optionAllReleaseAssets)
+		}
+		if options.CosignVerifyOptions.CertificateIdentity == "" || options.CosignVerifyOptions.CertificateOidcIssuer == "" {
+			return fmt.Errorf("The --%s flag requires both --%s and --%s to be set. Run \"fetch --help\" for full usage info.", optionCosignVerify, optionCosignCertificateIdentity, optionCosignCertificateOidcIssuer)
+		}
 	}
 
-	release, releaseInfoErr := GetGitHubReleaseInfo(githubRepo, tag)
-	if releaseInfoErr != nil {
-		return nil, releaseInfoErr
+	if options.SignedRefKey != "" {
+		if !options.RequireSignedRef {
+			return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionSignedRefKey, optionRequireSignedRef)
+		}
+		if err := fetch.ValidatePackageSigningKey(options.SignedRefKey); err != nil {
+			return err
+		}
 	}
 
-	assets, err := findAssetsInRelease(assetRegex, release)
-	if err != nil {
-		return nil, err
+	if options.PackageSigningKey != "" {
+		if !downloadsReleaseAssets(options) {
+			return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionPackageSigningKey, optionReleaseAsset, optionAllReleaseAssets)
+		}
+		if err := fetch.ValidatePackageSigningKey(options.PackageSigningKey); err != nil {
+			return err
+		}
 	}
-	if assets == nil {
-		return nil, fmt.Errorf("Could not find assets matching %s in release %s", assetRegex, tag)
+
+	if _, err := fetch.ParseGhesVersion(options.GhesVersion); err != nil {
+		return err
 	}
 
-	var wg sync.WaitGroup
-	results := make(chan AssetDownloadResult, len(assets))
+	if err := validateConnectionOptions(options.Connection); err != nil {
+		return err
+	}
 
-	for _, asset := range assets {
-		wg.Add(1)
-		go func(asset *GitHubReleaseAsset, results chan<- AssetDownloadResult) {
-			// Signal the WaitGroup once this go routine has finished
-			defer wg.Done()
+	if options.MaxConcurrentDownloads < 1 {
+		return fmt.Errorf("The --%s flag must be at least 1. Run \"fetch --help\" for full usage info.", optionMaxConcurrentDownloads)
+	}
 
-			assetPath := path.Join(destPath, asset.Name)
-			logger.Infof("Downloading release asset %s to %s\n", asset.Name, assetPath)
-			if downloadErr := DownloadReleaseAsset(githubRepo, asset.Id, assetPath, withProgress); downloadErr == nil {
-				logger.Infof("Downloaded %s\n", assetPath)
-				results <- AssetDownloadResult{assetPath, nil}
-			} else {
-				logger.Infof("Download failed for %s: %s\n", asset.Name, downloadErr)
-				results <- AssetDownloadResult{assetPath, downloadErr}
-			}
-		}(asset, results)
+	if options.DownloadConnections > 1 && !downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionDownloadConnections, optionReleaseAsset, optionAllReleaseAssets)
+	}
+
+	if err := validateLinkMode(options); err != nil {
+		return err
 	}
 
-	wg.Wait()
-	close(results)
-	logger.Infof("Download of release assets complete\n")
+	if err := validateStreamToStdout(options); err != nil {
+		return err
+	}
 
-	var errorStrs []string
-	for result := range results {
-		if result.err != nil {
-			errorStrs = append(errorStrs, fmt.Sprintf("%s: %s", result.assetPath, result.err))
-		} else {
-			assetPaths = append(assetPaths, result.assetPath)
+	if options.PublishS3 != "" {
+		if !downloadsReleaseAssets(options) {
+			return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionPublishS3, optionReleaseAsset, optionAllReleaseAssets)
+		}
+		if options.Stdout {
+			return fmt.Errorf("The --%s flag cannot be used with --%s, as both write to stdout.", optionPublishS3, optionStdout)
+		}
+		if _, err := fetch.ParseS3Location(options.PublishS3); err != nil {
+			return err
 		}
 	}
 
-	if numErrors := len(errorStrs); numErrors > 0 {
-		logger.Errorf("%d errors while downloading assets:\n\t%s", numErrors, strings.Join(errorStrs, "\n\t"))
+	if options.SbomLiteSigningKey != "" && options.EmitSbomLite == "" {
+		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionSbomLiteSigningKey, optionEmitSbomLite)
 	}
 
-	return assetPaths, err
-}
+	if options.StripComponents < 0 {
+		return fmt.Errorf("The --%s flag must not be negative. Run \"fetch --help\" for full usage info.", optionStripComponents)
+	}
 
-func findAssetsInRelease(assetRegex string, release GitHubReleaseApiResponse) ([](*GitHubReleaseAsset), error) {
-	var matches [](*GitHubReleaseAsset)
+	if options.StripComponents > 0 && len(options.SourcePaths) == 0 && downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag only applies to files extracted from the repo, so it can't be used with --%s or --%s unless --%s is also set. Run \"fetch --help\" for full usage info.", optionStripComponents, optionReleaseAsset, optionAllReleaseAssets, optionSourcePath)
+	}
 
-	pattern, err := regexp.Compile(assetRegex)
-	if err != nil {
-		return nil, fmt.Errorf("Could not parse provided release asset regex: %s", err.Error())
+	if options.Flatten && len(options.SourcePaths) == 0 && downloadsReleaseAssets(options) {
+		return fmt.Errorf("The --%s flag only applies to files extracted from the repo, so it can't be used with --%s or --%s unless --%s is also set. Run \"fetch --help\" for full usage info.", optionFlatten, optionReleaseAsset, optionAllReleaseAssets, optionSourcePath)
 	}
 
-	for _, asset := range release.Assets {
-		matched := pattern.MatchString(asset.Name)
-		if matched {
-			assetRef := asset
-			matches = append(matches, &assetRef)
-		} else if asset.Name == assetRegex {
-			// Sometimes the actual asset name contains regex symbols that could mess up matching.
-			// Perform a direct comparison as a last resort.
-			assetRef := asset
-			matches = append(matches, &assetRef)
-		}
+	if _, err := fetch.ParseRenames(options.Renames); err != nil {
+		return err
 	}
 
-	return matches, nil
-}
+	if _, err := fetch.ParseFileMode(optionDirMode, options.DirMode); err != nil {
+		return err
+	}
 
-// Delete the given zip file.
-func cleanupZipFile(localZipFilePath string) error {
-	err := os.Remove(localZipFilePath)
-	if err != nil {
-		return fmt.Errorf("Failed to delete local zip file at %s", localZipFilePath)
+	if _, err := fetch.ParseFileMode(optionFileMode, options.FileMode); err != nil {
+		return err
 	}
 
-	return nil
-}
+	if options.FileMode != "" && options.PreservePermissions {
+		return fmt.Errorf("The --%s flag cannot be used with --%s. Run \"fetch --help\" for full usage info.", optionFileMode, optionPreservePermissions)
+	}
 
-func getErrorMessage(errorCode int, errorDetails string) string {
-	switch errorCode {
-	case invalidTagConstraintExpression:
-		return fmt.Sprintf(`
-The --tag value you entered is not a valid constraint expression.
-See https://github.com/gruntwork-io/fetch#version-constraint-operators for examples.
+	if options.KeepArchive && !options.Unpack {
+		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionKeepArchive, optionUnpack)
+	}
 
-Underlying error message:
-%s
-`, errorDetails)
-	case invalidGithubTokenOrAccessDenied:
-		return fmt.Sprintf(`
-Received an HTTP 401 Response when attempting to query the repo for its tags.
+	if len(options.UnpackInclude) > 0 {
+		if !options.Unpack {
+			return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionUnpackInclude, optionUnpack)
+		}
+		if err := fetch.ValidateUnpackInclude(options.UnpackInclude); err != nil {
+			return err
+		}
+	}
 
-This means that either your GitHub oAuth Token is invalid, or that the token is valid but is being used to request access
-to either a public repo or a private repo to which you don't have access.
+	if options.UnpackBinary && !options.Unpack {
+		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionUnpackBinary, optionUnpack)
+	}
 
-Underlying error message:
-%s
-`, errorDetails)
-	case repoDoesNotExistOrAccessDenied:
-		return fmt.Sprintf(`
-Received an HTTP 404 Response when attempting to query the repo for its tags.
+	if options.Install {
+		if !downloadsReleaseAssets(options) || options.AllReleaseAssets {
+			return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionInstall, optionReleaseAsset, optionAutoAsset)
+		}
+		if options.InstallDir == "" {
+			return fmt.Errorf("The --%s flag is required with --%s. Run \"fetch --help\" for full usage info.", optionInstallDir, optionInstall)
+		}
+	} else if options.InstallDir != "" {
+		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch --help\" for full usage info.", optionInstallDir, optionInstall)
+	}
 
-This means that either no GitHub repo exists at the URL provided, or that you don't have permission to access it.
-If the URL is correct, you may need to pass in a --github-oauth-token.
+	if options.BinaryName != "" && !options.Install && !options.UnpackBinary {
+		return fmt.Errorf("The --%s flag can only be used with --%s or --%s. Run \"fetch --help\" for full usage info.", optionBinaryName, optionInstall, optionUnpackBinary)
+	}
 
-Underlying error message:
-%s
-`, errorDetails)
+	if strings.ContainsAny(options.BinaryName, `/\`) || options.BinaryName == "." || options.BinaryName == ".." {
+		return fmt.Errorf("The --%s value must be a file name, not a path.", optionBinaryName)
 	}
 
-	return ""
+	return validateReleaseAssetChecksums(options)
+}
+
+// Return an error if the algorithm of any of the release asset checksums in options is missing or unknown
+func validateReleaseAssetChecksums(options fetch.Options) error {
+	for checksum := range options.ReleaseAssetChecksums {
+		algorithm, _ := fetch.ParseChecksum(checksum, options.ReleaseAssetChecksumAlgo)
+		if algorithm == "" {
+			return fmt.Errorf("If the %s flag is set without an algorithm prefix (e.g. sha256:<checksum>), you must also enter a value for the %s flag.", optionReleaseAssetChecksum, optionReleaseAssetChecksumAlgo)
+		}
+		if _, err := fetch.GetHasher(algorithm); err != nil {
+			return err
+		}
+	}
+	return nil
 }
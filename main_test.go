@@ -1,115 +1,575 @@
 package main
 
 import (
-	"fmt"
-	"github.com/stretchr/testify/assert"
-	"os"
 	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
 )
 
-// Expect to download 2 assets:
-// - health-checker_linux_386
-// - health-checker_linux_amd64
-const SAMPLE_RELEASE_ASSET_REGEX = "health-checker_linux_[a-z0-9]+"
+func TestValidateOptionsAllReleaseAssets(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		AllReleaseAssets:       true,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	withChecksumFile := valid
+	withChecksumFile.ReleaseAssetChecksumFile = "SHA256SUMS"
+	assert.NoError(t, validateOptions(withChecksumFile))
+
+	withReleaseAsset := valid
+	withReleaseAsset.ReleaseAsset = "bar_.*"
+	assert.Error(t, validateOptions(withReleaseAsset))
+
+	withBranch := valid
+	withBranch.TagConstraint = ""
+	withBranch.BranchName = "main"
+	assert.Error(t, validateOptions(withBranch))
+}
+
+func TestValidateOptionsAssetSize(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_.*",
+		MinAssetSize:           "1KB",
+		MaxAssetSize:           "100MB",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	inverted := valid
+	inverted.MinAssetSize = "1GB"
+	assert.Error(t, validateOptions(inverted))
+
+	malformed := valid
+	malformed.MaxAssetSize = "lots"
+	assert.Error(t, validateOptions(malformed))
+
+	noReleaseAsset := valid
+	noReleaseAsset.ReleaseAsset = ""
+	assert.Error(t, validateOptions(noReleaseAsset))
+}
+
+func TestValidateOptionsChannel(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		Channel:                fetch.ChannelRc,
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_.*",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	unknown := valid
+	unknown.Channel = "alpha"
+	assert.Error(t, validateOptions(unknown))
+
+	withBranch := valid
+	withBranch.BranchName = "main"
+	assert.Error(t, validateOptions(withBranch))
+}
+
+func TestValidateOptionsTagPrefix(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "~>1.2",
+		TagPrefix:              "modules/vpc/",
+		LocalDownloadPath:      "/tmp/bar",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	withBranch := valid
+	withBranch.TagConstraint = ""
+	withBranch.BranchName = "main"
+	assert.Error(t, validateOptions(withBranch))
+}
+
+func TestValidateOptionsReleaseAssetTemplate(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_{{.OS}}_{{.Arch}}",
+		OS:                     "windows",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	unknownPlaceholder := valid
+	unknownPlaceholder.ReleaseAsset = "bar_{{.Platform}}"
+	assert.Error(t, validateOptions(unknownPlaceholder))
+
+	noReleaseAsset := valid
+	noReleaseAsset.ReleaseAsset = ""
+	noReleaseAsset.AllReleaseAssets = true
+	assert.Error(t, validateOptions(noReleaseAsset))
+}
+
+func TestValidateOptionsAutoAsset(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		AutoAsset:              true,
+		Arch:                   "arm64",
+		ReleaseAssetPickBy:     fetch.AssetOrderDownloads,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	withReleaseAsset := valid
+	withReleaseAsset.ReleaseAsset = "bar_linux_arm64"
+	assert.Error(t, validateOptions(withReleaseAsset))
+
+	withAllReleaseAssets := valid
+	withAllReleaseAssets.AllReleaseAssets = true
+	assert.Error(t, validateOptions(withAllReleaseAssets))
+
+	noTag := valid
+	noTag.TagConstraint = ""
+	noTag.BranchName = "main"
+	assert.Error(t, validateOptions(noTag))
+}
+
+func TestValidateOptionsInstall(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64.tar.gz",
+		Install:                true,
+		InstallDir:             "/usr/local/bin",
+		BinaryName:             "bar",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	noInstallDir := valid
+	noInstallDir.InstallDir = ""
+	assert.Error(t, validateOptions(noInstallDir))
+
+	allReleaseAssets := valid
+	allReleaseAssets.ReleaseAsset = ""
+	allReleaseAssets.AllReleaseAssets = true
+	assert.Error(t, validateOptions(allReleaseAssets))
+
+	binaryNamePath := valid
+	binaryNamePath.BinaryName = "../bar"
+	assert.Error(t, validateOptions(binaryNamePath))
+
+	noInstall := valid
+	noInstall.Install = false
+	assert.Error(t, validateOptions(noInstall))
+}
+
+func TestValidateOptionsStreamToStdout(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      fetch.StdoutDownloadPath,
+		ReleaseAsset:           "install.sh",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
+	}
+	assert.NoError(t, validateOptions(valid))
+
+	autoAsset := valid
+	autoAsset.ReleaseAsset = ""
+	autoAsset.AutoAsset = true
+	assert.NoError(t, validateOptions(autoAsset))
+
+	noReleaseAsset := valid
+	noReleaseAsset.ReleaseAsset = ""
+	assert.Error(t, validateOptions(noReleaseAsset))
+
+	sourcePaths := valid
+	sourcePaths.SourcePaths = []string{"/modules"}
+	assert.Error(t, validateOptions(sourcePaths))
+
+	unpack := valid
+	unpack.Unpack = true
+	assert.Error(t, validateOptions(unpack))
+
+	// Checksums are verified as the asset is streamed
+	checksum := valid
+	checksum.ReleaseAssetChecksums = map[string]bool{"sha256:abcd": true}
+	assert.NoError(t, validateOptions(checksum))
 
-func TestDownloadReleaseAssets(t *testing.T) {
-	tmpDir := mkTempDir(t)
-	logger := GetProjectLogger()
-	testInst := GitHubInstance{
-		BaseUrl: "github.com",
-		ApiUrl:  "api.github.com",
+	// Signatures can only be verified once the whole asset has been downloaded
+	repoKey := valid
+	repoKey.VerifyWithRepoKey = true
+	assert.Error(t, validateOptions(repoKey))
+
+	repoKeyBeforeStdout := repoKey
+	repoKeyBeforeStdout.VerifyBeforeStdout = true
+	assert.NoError(t, validateOptions(repoKeyBeforeStdout))
+
+	verifyBeforeStdoutToDisk := valid
+	verifyBeforeStdoutToDisk.LocalDownloadPath = "/tmp/bar"
+	verifyBeforeStdoutToDisk.VerifyBeforeStdout = true
+	assert.Error(t, validateOptions(verifyBeforeStdoutToDisk))
+
+	stdout := valid
+	stdout.Stdout = true
+	assert.Error(t, validateOptions(stdout))
+}
+
+func TestValidateOptionsSourceFile(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		BranchName:             "main",
+		LocalDownloadPath:      "/tmp/bar",
+		SourceFiles:            []string{"/scripts/install.sh", "README.md"},
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
 
-	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
-	if err != nil {
-		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	for _, sourceFile := range []string{"", "/", "/scripts/", "scripts/.."} {
+		invalid := valid
+		invalid.SourceFiles = []string{sourceFile}
+		assert.Error(t, validateOptions(invalid), "source file %q", sourceFile)
 	}
+}
 
-	assetPaths, fetchErr := downloadReleaseAssets(logger, SAMPLE_RELEASE_ASSET_REGEX, tmpDir, githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false)
-	if fetchErr != nil {
-		t.Fatalf("Failed to download release asset: %s", fetchErr)
+func TestValidateOptionsDownloadStrategy(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		BranchName:             "main",
+		LocalDownloadPath:      "/tmp/bar",
+		SourcePaths:            []string{"/modules/a", "/modules/b"},
+		DownloadStrategy:       fetch.DownloadStrategyContents,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	unknownStrategy := valid
+	unknownStrategy.DownloadStrategy = "git"
+	assert.Error(t, validateOptions(unknownStrategy))
 
-	if len(assetPaths) != 2 {
-		t.Fatalf("Expected to download 2 assets, not %d", len(assetPaths))
+	preservePermissions := valid
+	preservePermissions.PreservePermissions = true
+	assert.Error(t, validateOptions(preservePermissions))
+
+	autoPreservePermissions := preservePermissions
+	autoPreservePermissions.DownloadStrategy = fetch.DownloadStrategyAuto
+	assert.NoError(t, validateOptions(autoPreservePermissions))
+}
+
+func TestValidateOptionsUnpackInclude(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64.tar.gz",
+		Unpack:                 true,
+		UnpackInclude:          []string{"bin/*"},
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	noUnpack := valid
+	noUnpack.Unpack = false
+	assert.Error(t, validateOptions(noUnpack))
 
-	for _, assetPath := range assetPaths {
-		if _, err := os.Stat(assetPath); os.IsNotExist(err) {
-			t.Fatalf("Downloaded file should exist at %s", assetPath)
-		} else {
-			fmt.Printf("Verified the downloaded asset exists at %s\n", assetPath)
-		}
+	badGlob := valid
+	badGlob.UnpackInclude = []string{"bin/[a-"}
+	assert.Error(t, validateOptions(badGlob))
+}
+
+func TestValidateOptionsUnpackBinary(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64.tar.gz",
+		Unpack:                 true,
+		UnpackBinary:           true,
+		BinaryName:             "bar",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	noUnpack := valid
+	noUnpack.Unpack = false
+	assert.Error(t, validateOptions(noUnpack))
+
+	binaryNameOnly := valid
+	binaryNameOnly.UnpackBinary = false
+	assert.Error(t, validateOptions(binaryNameOnly))
 }
 
-func TestDownloadReleaseAssetsWithRegexCharacters(t *testing.T) {
-	tmpDir := mkTempDir(t)
-	logger := GetProjectLogger()
-	testInst := GitHubInstance{
-		BaseUrl: "github.com",
-		ApiUrl:  "api.github.com",
+func TestValidateOptionsJoinParts(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar.tar.gz.part[0-9]+",
+		JoinParts:              true,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	noReleaseAsset := valid
+	noReleaseAsset.ReleaseAsset = ""
+	assert.Error(t, validateOptions(noReleaseAsset))
 
-	const githubRepoUrl = "https://github.com/gruntwork-io/fetch-test-public"
-	const releaseAsset = "hello+world.txt"
-	const assetVersion = "v0.0.4"
+	streamToStdout := valid
+	streamToStdout.LocalDownloadPath = fetch.StdoutDownloadPath
+	assert.Error(t, validateOptions(streamToStdout))
+}
+
+func TestValidateOptionsDownloadConnections(t *testing.T) {
+	t.Parallel()
 
-	githubRepo, err := ParseUrlIntoGitHubRepo(githubRepoUrl, "", testInst)
-	if err != nil {
-		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64",
+		DownloadConnections:    8,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
 
-	assetPaths, fetchErr := downloadReleaseAssets(logger, releaseAsset, tmpDir, githubRepo, assetVersion, false)
-	if fetchErr != nil {
-		t.Fatalf("Failed to download release asset: %s", fetchErr)
+	noReleaseAsset := valid
+	noReleaseAsset.ReleaseAsset = ""
+	assert.Error(t, validateOptions(noReleaseAsset))
+
+	streamToStdout := valid
+	streamToStdout.LocalDownloadPath = fetch.StdoutDownloadPath
+	assert.Error(t, validateOptions(streamToStdout))
+}
+
+func TestValidateOptionsStripComponents(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		SourcePaths:            []string{"/modules"},
+		StripComponents:        1,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	wholeRepo := valid
+	wholeRepo.SourcePaths = nil
+	assert.NoError(t, validateOptions(wholeRepo))
 
-	if len(assetPaths) != 1 {
-		t.Fatalf("Expected to download 1 assets, not %d", len(assetPaths))
+	negative := valid
+	negative.StripComponents = -1
+	assert.Error(t, validateOptions(negative))
+
+	releaseAssetOnly := valid
+	releaseAssetOnly.SourcePaths = nil
+	releaseAssetOnly.ReleaseAsset = "bar_linux_amd64"
+	assert.Error(t, validateOptions(releaseAssetOnly))
+}
+
+func TestValidateOptionsFlattenAndRename(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		SourcePaths:            []string{"/modules"},
+		ReleaseAsset:           "terragrunt_linux_amd64",
+		Flatten:                true,
+		Renames:                []string{"terragrunt_linux_amd64=terragrunt"},
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	flattenReleaseAssetOnly := valid
+	flattenReleaseAssetOnly.SourcePaths = nil
+	assert.Error(t, validateOptions(flattenReleaseAssetOnly))
+
+	renameReleaseAssetOnly := flattenReleaseAssetOnly
+	renameReleaseAssetOnly.Flatten = false
+	assert.NoError(t, validateOptions(renameReleaseAssetOnly))
+
+	invalidRename := valid
+	invalidRename.Renames = []string{"terragrunt"}
+	assert.Error(t, validateOptions(invalidRename))
 
-	assetPath := assetPaths[0]
+	streamToStdout := renameReleaseAssetOnly
+	streamToStdout.LocalDownloadPath = fetch.StdoutDownloadPath
+	assert.Error(t, validateOptions(streamToStdout))
+}
+
+func TestValidateOptionsLinkMode(t *testing.T) {
+	t.Parallel()
 
-	if _, err := os.Stat(assetPath); os.IsNotExist(err) {
-		t.Fatalf("Downloaded file should exist at %s", assetPath)
-	} else {
-		fmt.Printf("Verified the downloaded asset exists at %s\n", assetPath)
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64",
+		ArchiveCacheDir:        "/tmp/cache",
+		LinkMode:               fetch.LinkModeSymlink,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	noCacheDir := valid
+	noCacheDir.ArchiveCacheDir = ""
+	assert.Error(t, validateOptions(noCacheDir))
+
+	unknownMode := valid
+	unknownMode.LinkMode = "junction"
+	assert.Error(t, validateOptions(unknownMode))
 }
 
-func TestInvalidReleaseAssetsRegex(t *testing.T) {
-	tmpDir := mkTempDir(t)
-	logger := GetProjectLogger()
-	testInst := GitHubInstance{
-		BaseUrl: "github.com",
-		ApiUrl:  "api.github.com",
+func TestValidateOptionsImmutableTag(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "~>0.1.5",
+		LocalDownloadPath:      "/tmp/bar",
+		ReleaseAsset:           "bar_linux_amd64",
+		RequireImmutableTag:    true,
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	withCommit := valid
+	withCommit.ReleaseAsset = ""
+	withCommit.TagConstraint = ""
+	withCommit.CommitSha = "abc123"
+	assert.Error(t, validateOptions(withCommit))
+
+	withBranch := valid
+	withBranch.ReleaseAsset = ""
+	withBranch.TagConstraint = ""
+	withBranch.RequireImmutableTag = false
+	withBranch.CheckImmutableTag = true
+	withBranch.BranchName = "main"
+	assert.Error(t, validateOptions(withBranch))
+}
+
+func TestValidateOptionsExpectedCommitSha(t *testing.T) {
+	t.Parallel()
 
-	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
-	if err != nil {
-		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.1.5",
+		LocalDownloadPath:      "/tmp/bar",
+		ExpectedCommitSha:      "0123456789abcdef0123456789abcdef01234567",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+
+	withBranch := valid
+	withBranch.TagConstraint = ""
+	withBranch.BranchName = "main"
+	assert.Error(t, validateOptions(withBranch))
+}
 
-	_, fetchErr := downloadReleaseAssets(logger, "*", tmpDir, githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false)
-	if fetchErr == nil {
-		t.Fatalf("Expected error for invalid regex")
+func TestValidateOptionsSignedRefKey(t *testing.T) {
+	t.Parallel()
+
+	withoutRequire := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.1.5",
+		LocalDownloadPath:      "/tmp/bar",
+		SignedRefKey:           "/does/not/exist/signing-key.asc",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.Error(t, validateOptions(withoutRequire))
+
+	missingKey := withoutRequire
+	missingKey.RequireSignedRef = true
+	assert.Error(t, validateOptions(missingKey))
 }
 
-func TestInvalidReleaseAssetTag(t *testing.T) {
-	tmpDir := mkTempDir(t)
-	logger := GetProjectLogger()
-	testInst := GitHubInstance{
-		BaseUrl: "github.com",
-		ApiUrl:  "api.github.com",
+func TestValidateOptionsConnection(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://github.com/foo/bar",
+		TagConstraint:          "v0.0.1",
+		LocalDownloadPath:      "/tmp/bar",
+		Connection:             fetch.ConnectionOptions{Proxy: "socks5://127.0.0.1:1080"},
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
 
-	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
-	if err != nil {
-		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	noScheme := valid
+	noScheme.Connection.Proxy = "proxy.example.com:3128"
+	assert.Error(t, validateOptions(noScheme))
+
+	unsupportedScheme := valid
+	unsupportedScheme.Connection.Proxy = "ftp://proxy.example.com"
+	assert.Error(t, validateOptions(unsupportedScheme))
+
+	clientCertWithoutKey := valid
+	clientCertWithoutKey.Connection.ClientCert = "/tmp/client.pem"
+	assert.Error(t, validateOptions(clientCertWithoutKey))
+
+	clientCertWithKey := clientCertWithoutKey
+	clientCertWithKey.Connection.ClientKey = "/tmp/client-key.pem"
+	assert.NoError(t, validateOptions(clientCertWithKey))
+}
+
+func TestValidateOptionsBitbucketToken(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{
+		RepoUrl:                "https://bitbucket.mycompany.com/projects/OPS/repos/modules",
+		TagConstraint:          "~>1.2",
+		SourcePaths:            []string{"/modules/vpc"},
+		BitbucketToken:         "bitbucket-token",
+		LocalDownloadPath:      "/tmp/vpc",
+		MaxConcurrentDownloads: fetch.DefaultMaxConcurrentDownloads,
 	}
+	assert.NoError(t, validateOptions(valid))
+	assert.False(t, usesGithubToken(valid))
+
+	cloneUrl := valid
+	cloneUrl.RepoUrl = "https://bitbucket.mycompany.com/scm/ops/modules.git"
+	cloneUrl.Source = fetch.SourceBitbucketDc
+	assert.NoError(t, validateOptions(cloneUrl))
+	assert.False(t, usesGithubToken(cloneUrl))
 
-	_, fetchErr := downloadReleaseAssets(logger, SAMPLE_RELEASE_ASSET_REGEX, tmpDir, githubRepo, "6.6.6", false)
-	assert.Error(t, fetchErr)
+	// The Bitbucket token is never sent to GitHub
+	github := valid
+	github.RepoUrl = "https://github.com/foo/bar"
+	assert.Error(t, validateOptions(github))
+	github.BitbucketToken = ""
+	assert.True(t, usesGithubToken(github))
 }
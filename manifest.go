@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionManifest = "manifest"
+const optionManifestChecksum = "manifest-checksum"
+const optionVersionsFile = "versions-file"
+const optionUpdateSummary = "update-summary"
+const optionEntryTimeout = "entry-timeout"
+const optionEntryMaxSize = "entry-max-size"
+const optionJournal = "journal"
+
+// Create the "fetch manifest" command, which runs every fetch listed in a JSON or YAML manifest file
+func createManifestCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "manifest",
+		Usage:     "Run every fetch listed in a JSON or YAML manifest file, downloading release assets that several entries share only once.",
+		UsageText: "fetch manifest [options] <manifest-path>\nfetch manifest [options] --manifest <host>/<owner>/<repo>//<path>?ref=<ref>",
+		Action:    runManifestWrapper,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  optionManifest,
+				Usage: "The manifest to run, instead of the one in the argument: a local path, or a file in a GitHub repo at a\n\tgit ref, e.g. github.com/org/tooling//bootstrap.yaml?ref=v2.1.0.",
+			},
+			&cli.StringFlag{
+				Name:  optionManifestChecksum,
+				Usage: "The checksum the manifest must match before any of it is run, with an algorithm prefix (e.g. sha256:abcd...).",
+			},
+			&cli.StringFlag{
+				Name:  optionVersionsFile,
+				Usage: "A JSON file recording the version each entry was fetched at. It's read before the run, to tell which\n\tentries were updated, and rewritten after it. Commit it along with the files the manifest fetches.",
+			},
+			&cli.StringFlag{
+				Name:  optionUpdateSummary,
+				Usage: "Write a markdown summary of the entries that were updated since --versions-file was written, with\n\ttheir old and new versions, changelog links, and checksums, to this file, e.g. for a pull request description.",
+			},
+			&cli.StringFlag{
+				Name:    optionGithubToken,
+				Usage:   "A GitHub Personal Access Token, which is required for downloading from private repos. Populate by setting env var",
+				EnvVars: []string{envVarGithubToken},
+			},
+			&cli.StringFlag{
+				Name:    optionTokenCommand,
+				Usage:   "A command that prints the GitHub token to use, such as \"gh auth token\", which is run with the shell.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionTokenCommand)},
+			},
+			&cli.StringFlag{
+				Name:    optionGithubTokenFile,
+				Aliases: []string{"token-file"},
+				Usage:   "A file to read the GitHub token from, such as a secret mounted as a file, or \"-\" to read it from stdin.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionGithubTokenFile)},
+			},
+			&cli.StringSliceFlag{
+				Name:    optionCredentialFallback,
+				Usage:   "Where to look for the GitHub token if none is given: \"netrc\" (~/.netrc, or $NETRC), \"git\"\n\t(git credential fill), or both, in the order given.",
+				EnvVars: []string{optionEnvVar(optionCredentialFallback)},
+			},
+			&cli.StringFlag{
+				Name:  optionGithubAPIVersion,
+				Value: "v3",
+				Usage: "The api version of the GitHub instance. If left blank, v3 will be used.\n\tThis will only be used if the repo url is not a github.com url.",
+			},
+			&cli.BoolFlag{
+				Name:  optionWithProgress,
+				Usage: "Display progress on file downloads and checksum verification, especially useful for large files",
+			},
+			&cli.IntFlag{
+				Name:  optionMaxConcurrentDownloads,
+				Value: fetch.DefaultMaxConcurrentDownloads,
+				Usage: "The maximum number of release assets to download at once for each entry.",
+			},
+			&cli.BoolFlag{
+				Name:  optionUpgradeWeakChecksums,
+				Usage: "If an entry's release asset is verified with an md5 or sha1 checksum, also compute its sha256 checksum\n\tand print it in the warning about the weak checksum, so the manifest can be upgraded to it.",
+			},
+			&cli.BoolFlag{
+				Name:  optionWaitForRateLimit,
+				Usage: "If the GitHub API rate limit is exhausted, wait until it resets and retry instead of failing.",
+			},
+			&cli.StringFlag{
+				Name:    optionCacheDir,
+				Usage:   "Cache the repo archives and release assets that entries download in this directory, so later runs can\n\treuse them. Defaults to fetch in the user's cache directory, e.g. ~/.cache/fetch.",
+				EnvVars: []string{envVarCacheDir},
+			},
+			&cli.StringFlag{
+				Name:    optionArchiveCacheDir,
+				Hidden:  true, // The original name of --cache-dir
+				EnvVars: []string{envVarArchiveCacheDir},
+			},
+			&cli.BoolFlag{
+				Name:  optionNoCache,
+				Usage: "Don't read from or write to the cache, even if --cache-dir is set.",
+			},
+			&cli.StringFlag{
+				Name:  optionLinkMode,
+				Value: fetch.LinkModeReflink,
+				Usage: "How release assets shared by several entries are placed in each destination: \"reflink\" (copy-on-write\n\twhere supported, and a copy elsewhere), \"hardlink\", \"symlink\" (not with --no-cache), or \"copy\".",
+			},
+			&cli.DurationFlag{
+				Name:  optionEntryTimeout,
+				Usage: "Fail an entry that takes longer than this (e.g. 5m). Entries can override it with \"timeout\".",
+			},
+			&cli.StringFlag{
+				Name:  optionEntryMaxSize,
+				Usage: "Fail an entry that downloads more than this (e.g. \"500MiB\"). Entries can override it with \"maxSize\".",
+			},
+			&cli.StringFlag{
+				Name:  optionJournal,
+				Usage: "Record each entry that succeeds in this file, so that re-running a manifest that failed skips them and\n\tresumes from the entry that failed. The file is deleted once every entry has succeeded.",
+			},
+			&cli.StringFlag{
+				Name:    optionAuditLog,
+				Usage:   "If set, append a hash-chained record of what each entry resolved and downloaded to this file.",
+				EnvVars: []string{optionEnvVar(optionAuditLog)},
+			},
+		}, connectionFlags()...),
+	}
+}
+
+func runManifestWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runManifest(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch manifest" command
+func runManifest(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	source := c.String(optionManifest)
+	if c.NArg() > 1 || (c.NArg() == 1 && source != "") {
+		return fmt.Errorf("Specify a single manifest, either as an argument or with --%s. Run \"fetch manifest --help\" for full usage info.", optionManifest)
+	}
+	if c.NArg() == 1 {
+		source = c.Args().First()
+	}
+	if source == "" {
+		return fmt.Errorf("Missing required argument specifying the manifest path. Run \"fetch manifest --help\" for full usage info.")
+	}
+	checksum := c.String(optionManifestChecksum)
+	if checksum != "" {
+		if err := fetch.ValidateManifestChecksum(checksum); err != nil {
+			return err
+		}
+	}
+	if c.Int(optionMaxConcurrentDownloads) < 1 {
+		return fmt.Errorf("The --%s flag must be at least 1. Run \"fetch manifest --help\" for full usage info.", optionMaxConcurrentDownloads)
+	}
+	if c.Duration(optionEntryTimeout) < 0 {
+		return fmt.Errorf("The --%s flag must not be negative. Run \"fetch manifest --help\" for full usage info.", optionEntryTimeout)
+	}
+	entryMaxSize, err := fetch.ParseAssetSize(optionEntryMaxSize, c.String(optionEntryMaxSize))
+	if err != nil {
+		return err
+	}
+
+	versionsPath := c.String(optionVersionsFile)
+	summaryPath := c.String(optionUpdateSummary)
+	if summaryPath != "" && versionsPath == "" {
+		return fmt.Errorf("The --%s flag can only be used with --%s. Run \"fetch manifest --help\" for full usage info.", optionUpdateSummary, optionVersionsFile)
+	}
+	var previousVersions *fetch.ManifestVersions
+	if versionsPath != "" {
+		if previousVersions, err = fetch.LoadManifestVersions(versionsPath); err != nil {
+			return err
+		}
+	}
+
+	cachePath := cacheDir(c, logger)
+	if err := validateLinkMode(fetch.Options{ArchiveCacheDir: cachePath, LinkMode: c.String(optionLinkMode)}); err != nil {
+		return err
+	}
+
+	token, err := resolveGithubToken(ctx, c, source, c.String(optionGithubToken))
+	if err != nil {
+		return err
+	}
+
+	base := fetch.Options{
+		GithubToken:            token,
+		GithubApiVersion:       c.String(optionGithubAPIVersion),
+		Connection:             parseConnectionOptions(c),
+		WithProgress:           c.Bool(optionWithProgress),
+		MaxConcurrentDownloads: c.Int(optionMaxConcurrentDownloads),
+		WaitForRateLimit:       c.Bool(optionWaitForRateLimit),
+		UpgradeWeakChecksums:   c.Bool(optionUpgradeWeakChecksums),
+		RecordChecksums:        versionsPath != "",
+		AuditLog:               c.String(optionAuditLog),
+		ManifestJournal:        c.String(optionJournal),
+		ArchiveCacheDir:        cachePath,
+		LinkMode:               c.String(optionLinkMode),
+		ToolVersion:            VERSION,
+		Logger:                 logger,
+		Locale:                 resolveLocale(c),
+	}
+	manifest, err := fetch.LoadManifestFrom(ctx, source, checksum, base)
+	if err != nil {
+		return err
+	}
+	// The budgets are for each entry, not for reading the manifest, and entries can override them
+	base.Timeout = c.Duration(optionEntryTimeout)
+	base.MaxDownloadSize = entryMaxSize
+	results, err := fetch.RunManifest(ctx, manifest, base, c.App.Writer)
+	if err != nil || versionsPath == "" {
+		return err
+	}
+	return writeManifestVersions(manifest, results, previousVersions, versionsPath, summaryPath)
+}
+
+// Write the update summary, if summaryPath is set, and then the versions the manifest's entries were fetched at, so that
+// the summary of the next run is relative to this one
+func writeManifestVersions(manifest *fetch.Manifest, results []*fetch.Result, previousVersions *fetch.ManifestVersions, versionsPath string, summaryPath string) error {
+	versions := fetch.NewManifestVersions(manifest, results)
+	if summaryPath != "" {
+		var summary bytes.Buffer
+		if err := fetch.WriteUpdateSummary(&summary, previousVersions, versions); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(summaryPath, summary.Bytes(), 0644); err != nil {
+			return fmt.Errorf("Error writing the update summary to %s: %s", summaryPath, err)
+		}
+	}
+	if err := versions.Write(versionsPath); err != nil {
+		return fmt.Errorf("Error writing the versions file %s: %s", versionsPath, err)
+	}
+	return nil
+}
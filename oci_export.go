@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionOciRef = "oci-ref"
+const optionRegistryUsername = "registry-username"
+const optionRegistryPassword = "registry-password"
+const optionRegistryPlainHttp = "registry-plain-http"
+
+// Create the "fetch oci-export" command, which pushes the verified release assets of a release to an OCI registry as a
+// single artifact
+func createOciExportCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     optionRepo,
+			Category: flagCategorySelection,
+			Usage:    "Required. Fully qualified URL of the GitHub repo to export release assets from.",
+		},
+		&cli.StringFlag{
+			Name:     optionTag,
+			Category: flagCategorySelection,
+			Usage:    "Required. The git tag of the release to export, expressed with Version Constraint Operators.",
+		},
+		&cli.StringFlag{
+			Name:     optionReleaseAsset,
+			Category: flagCategorySelection,
+			Value:    ".*",
+			Usage:    "A regular expression matching the names of the release assets to export. Defaults to all assets.",
+		},
+		&cli.StringFlag{
+			Name:  optionOciRef,
+			Usage: "Required. The OCI reference to push the artifact to, e.g. ghcr.io/org/tool:v1.0.0. The tag defaults to\n\tthe release tag.",
+		},
+		&cli.StringFlag{
+			Name:  optionRegistryUsername,
+			Usage: "The username to push to the registry with, if it requires one.",
+		},
+		&cli.StringFlag{
+			Name:    optionRegistryPassword,
+			Usage:   "The password or token to push to the registry with. Populate by setting env var",
+			EnvVars: []string{optionEnvVar(optionRegistryPassword)},
+		},
+		&cli.BoolFlag{
+			Name:  optionRegistryPlainHttp,
+			Usage: "Connect to the registry over plain HTTP rather than HTTPS. Only meant for local registries.",
+		},
+	}
+	flags = append(flags, fetchFlagsNamed(
+		optionReleaseAssetChecksum,
+		optionReleaseAssetChecksumAlgo,
+		optionReleaseAssetChecksumFile,
+		optionUpgradeWeakChecksums,
+		optionVerifyWithRepoKey,
+		optionCosignVerify,
+		optionCosignCertificateIdentity,
+		optionCosignCertificateOidcIssuer,
+		optionCosignFulcioRoot,
+		optionCosignRekorPublicKey,
+		optionCosignRekorUrl,
+		optionPackageSigningKey,
+		optionGithubToken,
+		optionTokenCommand,
+		optionGithubTokenFile,
+		optionCredentialFallback,
+		optionGithubAPIVersion,
+		optionGhesVersion,
+	)...)
+	flags = append(flags, connectionFlags()...)
+
+	return &cli.Command{
+		Name:      "oci-export",
+		Usage:     "Push the verified release assets of a release to an OCI registry as a single artifact, annotated with the repo, tag, and commit they came from.",
+		UsageText: "fetch oci-export --repo <repo> --tag <tag> --oci-ref <registry>/<repository>[:<tag>] [--release-asset-checksum <checksum> | --release-asset-checksum-file <file> | --verify-with-repo-key | --cosign-verify] [options]",
+		Action:    runOciExportWrapper,
+		Flags:     flags,
+	}
+}
+
+func runOciExportWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runOciExport(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch oci-export" command. The reference the artifact was pushed to is written to stdout with its digest, so
+// scripts can pin it.
+func runOciExport(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := parseOciExportOptions(c)
+	if err := validateOciExportOptions(options); err != nil {
+		return err
+	}
+	token, err := resolveGithubToken(ctx, c, options.Source.RepoUrl, options.Source.GithubToken)
+	if err != nil {
+		return err
+	}
+	options.Source.GithubToken = token
+
+	result, err := fetch.OciExport(ctx, logger, options)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.App.Writer, "%s@%s\n", result.Reference, result.Digest)
+	return err
+}
+
+func parseOciExportOptions(c *cli.Context) fetch.OciExportOptions {
+	assetChecksums := c.StringSlice(optionReleaseAssetChecksum)
+	assetChecksumMap := make(map[string]bool, len(assetChecksums))
+	for _, assetChecksum := range assetChecksums {
+		assetChecksumMap[assetChecksum] = true
+	}
+
+	return fetch.OciExportOptions{
+		Source: fetch.Options{
+			RepoUrl:                  c.String(optionRepo),
+			TagConstraint:            c.String(optionTag),
+			ReleaseAsset:             c.String(optionReleaseAsset),
+			GithubToken:              c.String(optionGithubToken),
+			GithubApiVersion:         c.String(optionGithubAPIVersion),
+			GhesVersion:              c.String(optionGhesVersion),
+			Connection:               parseConnectionOptions(c),
+			Locale:                   resolveLocale(c),
+			ReleaseAssetChecksums:    assetChecksumMap,
+			ReleaseAssetChecksumAlgo: c.String(optionReleaseAssetChecksumAlgo),
+			ReleaseAssetChecksumFile: c.String(optionReleaseAssetChecksumFile),
+			UpgradeWeakChecksums:     c.Bool(optionUpgradeWeakChecksums),
+			VerifyWithRepoKey:        c.Bool(optionVerifyWithRepoKey),
+			CosignVerify:             c.Bool(optionCosignVerify),
+			CosignVerifyOptions: fetch.CosignVerifyOptions{
+				CertificateIdentity:   c.String(optionCosignCertificateIdentity),
+				CertificateOidcIssuer: c.String(optionCosignCertificateOidcIssuer),
+				FulcioRootPath:        c.String(optionCosignFulcioRoot),
+				RekorPublicKeyPath:    c.String(optionCosignRekorPublicKey),
+				RekorUrl:              c.String(optionCosignRekorUrl),
+			},
+			PackageSigningKey: c.String(optionPackageSigningKey),
+		},
+		Reference:        c.String(optionOciRef),
+		RegistryUsername: c.String(optionRegistryUsername),
+		RegistryPassword: c.String(optionRegistryPassword),
+		PlainHttp:        c.Bool(optionRegistryPlainHttp),
+	}
+}
+
+func validateOciExportOptions(options fetch.OciExportOptions) error {
+	source := options.Source
+	if source.RepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch oci-export --help\" for full usage info.", optionRepo)
+	}
+	if source.TagConstraint == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch oci-export --help\" for full usage info.", optionTag)
+	}
+	if options.Reference == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch oci-export --help\" for full usage info.", optionOciRef)
+	}
+	if _, err := fetch.ParseOciReference(options.Reference); err != nil {
+		return err
+	}
+	if options.RegistryPassword != "" && options.RegistryUsername == "" {
+		return fmt.Errorf("The --%s flag requires --%s to be set. Run \"fetch oci-export --help\" for full usage info.", optionRegistryPassword, optionRegistryUsername)
+	}
+
+	// Only verified release assets are exported, so that the artifact can be trusted as much as the release
+	if len(source.ReleaseAssetChecksums) == 0 && source.ReleaseAssetChecksumFile == "" && !source.VerifyWithRepoKey && !source.CosignVerify && source.PackageSigningKey == "" {
+		return fmt.Errorf("You must specify at least one of --%s, --%s, --%s, --%s, or --%s. Run \"fetch oci-export --help\" for full usage info.", optionReleaseAssetChecksum, optionReleaseAssetChecksumFile, optionVerifyWithRepoKey, optionCosignVerify, optionPackageSigningKey)
+	}
+	if source.PackageSigningKey != "" {
+		if err := fetch.ValidatePackageSigningKey(source.PackageSigningKey); err != nil {
+			return err
+		}
+	}
+	if source.CosignVerify && (source.CosignVerifyOptions.CertificateIdentity == "" || source.CosignVerifyOptions.CertificateOidcIssuer == "") {
+		return fmt.Errorf("The --%s flag requires both --%s and --%s to be set. Run \"fetch oci-export --help\" for full usage info.", optionCosignVerify, optionCosignCertificateIdentity, optionCosignCertificateOidcIssuer)
+	}
+
+	if _, err := fetch.ParseGhesVersion(source.GhesVersion); err != nil {
+		return err
+	}
+	if err := validateConnectionOptions(source.Connection); err != nil {
+		return err
+	}
+	return validateReleaseAssetChecksums(source)
+}
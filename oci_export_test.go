@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOciExportOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.OciExportOptions{
+		Source: fetch.Options{
+			RepoUrl:                  "https://github.com/foo/bar",
+			TagConstraint:            "v0.0.1",
+			ReleaseAssetChecksumFile: "SHA256SUMS",
+		},
+		Reference: "ghcr.io/foo/bar",
+	}
+	assert.NoError(t, validateOciExportOptions(valid))
+
+	noReference := valid
+	noReference.Reference = ""
+	assert.Error(t, validateOciExportOptions(noReference))
+
+	noRegistry := valid
+	noRegistry.Reference = "foo/bar:v0.0.1"
+	assert.Error(t, validateOciExportOptions(noRegistry))
+
+	passwordWithoutUsername := valid
+	passwordWithoutUsername.RegistryPassword = "secret"
+	assert.Error(t, validateOciExportOptions(passwordWithoutUsername))
+
+	nothingToVerify := valid
+	nothingToVerify.Source.ReleaseAssetChecksumFile = ""
+	assert.Error(t, validateOciExportOptions(nothingToVerify))
+}
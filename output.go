@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+)
+
+const optionOutput = "output"
+
+// The formats in which fetch can report the result of a fetch
+const (
+	outputFormatText = "text"
+	outputFormatJson = "json"
+)
+
+// The summary of a fetch written by --output=json
+type fetchSummary struct {
+	Tag           string              `json:"tag,omitempty"`
+	TagCommitSha  string              `json:"tagCommitSha,omitempty"`
+	CommitSha     string              `json:"commitSha,omitempty"`
+	Files         []fetchSummaryFile  `json:"files"`
+	InstalledPath string              `json:"installedPath,omitempty"`
+	PresignedUrls []string            `json:"presignedUrls,omitempty"`
+	Durations     fetchSummaryTimings `json:"durations"`
+}
+
+// A single downloaded file in a fetchSummary
+type fetchSummaryFile struct {
+	Kind    string               `json:"kind"` // "source-file" or "release-asset"
+	Path    string               `json:"path"`
+	Size    int64                `json:"size"`
+	Sha256  string               `json:"sha256,omitempty"`
+	Package *fetchSummaryPackage `json:"package,omitempty"`
+}
+
+// The metadata of a release asset that's a .deb or .rpm package, in a fetchSummaryFile
+type fetchSummaryPackage struct {
+	Format  string `json:"format"` // "deb" or "rpm"
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+}
+
+// How long each step of the fetch took, in seconds
+type fetchSummaryTimings struct {
+	Resolve  float64 `json:"resolve"`
+	Download float64 `json:"download"`
+	Extract  float64 `json:"extract"`
+	Verify   float64 `json:"verify"`
+	Total    float64 `json:"total"`
+}
+
+func validateOutputFormat(format string) error {
+	if format != outputFormatText && format != outputFormatJson {
+		return fmt.Errorf("Unknown --%s \"%s\". Must be one of: %s, %s.", optionOutput, format, outputFormatJson, outputFormatText)
+	}
+	return nil
+}
+
+// Write a summary of the given result to writer as JSON
+func writeFetchSummary(writer io.Writer, result *fetch.Result) error {
+	summary := fetchSummary{
+		Tag:           result.Tag,
+		TagCommitSha:  result.TagCommitSha,
+		CommitSha:     result.CommitSha,
+		Files:         []fetchSummaryFile{},
+		InstalledPath: result.InstalledPath,
+		PresignedUrls: result.PresignedUrls,
+		Durations: fetchSummaryTimings{
+			Resolve:  result.Timings.Resolve.Seconds(),
+			Download: result.Timings.Download.Seconds(),
+			Extract:  result.Timings.Extract.Seconds(),
+			Verify:   result.Timings.Verify.Seconds(),
+			Total:    result.Timings.Total.Seconds(),
+		},
+	}
+	for _, file := range result.Files {
+		summaryFile := fetchSummaryFile{Kind: file.Kind, Path: file.Path, Size: file.Size, Sha256: file.Sha256}
+		if file.Package != nil {
+			summaryFile.Package = &fetchSummaryPackage{Format: file.Package.Format, Name: file.Package.Name, Version: file.Package.Version, Arch: file.Package.Arch}
+		}
+		summary.Files = append(summary.Files, summaryFile)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+// Return an error if the given output format is unknown, or can't be used with the given options, which write to
+// stdout themselves
+func validateOutput(format string, options fetch.Options, dryRun bool) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+	if format != outputFormatJson {
+		// The commit SHA is printed to stdout, so it can't share stdout with a file
+		if options.ResolveRef && !dryRun && (options.Stdout || options.LocalDownloadPath == fetch.StdoutDownloadPath) {
+			return fmt.Errorf("The --%s flag prints the commit SHA to stdout, so it cannot be used with --%s or a local download path of \"%s\", which write a file to stdout. Run \"fetch --help\" for full usage info.", optionResolveRef, optionStdout, fetch.StdoutDownloadPath)
+		}
+		return nil
+	}
+
+	if options.LocalDownloadPath == fetch.StdoutDownloadPath {
+		return fmt.Errorf("The --%s=%s flag cannot be used with a local download path of \"%s\", which streams the release asset to stdout. Run \"fetch --help\" for full usage info.", optionOutput, outputFormatJson, fetch.StdoutDownloadPath)
+	}
+	if options.Stdout {
+		return fmt.Errorf("The --%s=%s flag cannot be used with --%s. Run \"fetch --help\" for full usage info.", optionOutput, outputFormatJson, optionStdout)
+	}
+	if dryRun {
+		return fmt.Errorf("The --%s=%s flag cannot be used with --%s. Use --%s=%s instead. Run \"fetch --help\" for full usage info.", optionOutput, outputFormatJson, optionDryRun, optionDryRunFormat, dryRunFormatJson)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOutput(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.Options{LocalDownloadPath: "/tmp/fetch"}
+
+	testCases := []struct {
+		name        string
+		format      string
+		options     fetch.Options
+		dryRun      bool
+		expectError bool
+	}{
+		{"text", outputFormatText, valid, false, false},
+		{"json", outputFormatJson, valid, false, false},
+		{"unknown format", "yaml", valid, false, true},
+		{"json streaming to stdout", outputFormatJson, fetch.Options{LocalDownloadPath: fetch.StdoutDownloadPath}, false, true},
+		{"json with --stdout", outputFormatJson, fetch.Options{LocalDownloadPath: "/tmp/fetch", Stdout: true}, false, true},
+		{"json with --dry-run", outputFormatJson, valid, true, true},
+		{"text with --stdout", outputFormatText, fetch.Options{LocalDownloadPath: "/tmp/fetch", Stdout: true}, false, false},
+		{"text with --resolve-ref", outputFormatText, fetch.Options{LocalDownloadPath: "/tmp/fetch", ResolveRef: true}, false, false},
+		{"--resolve-ref with --stdout", outputFormatText, fetch.Options{LocalDownloadPath: "/tmp/fetch", Stdout: true, ResolveRef: true}, false, true},
+		{"--resolve-ref streaming to stdout", outputFormatText, fetch.Options{LocalDownloadPath: fetch.StdoutDownloadPath, ResolveRef: true}, false, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateOutput(tc.format, tc.options, tc.dryRun)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteFetchSummary(t *testing.T) {
+	t.Parallel()
+
+	result := &fetch.Result{
+		Tag:          "v0.0.4",
+		TagCommitSha: "abc123",
+		AssetPaths:   []string{"/tmp/fetch/tool"},
+		Files: []fetch.FetchedFile{
+			{Kind: fetch.FetchedSourceFile, Path: "/tmp/fetch/README.md", Size: 42, Sha256: "deadbeef"},
+			{Kind: fetch.FetchedReleaseAsset, Path: "/tmp/fetch/tool", Size: 1048576, Sha256: "cafef00d"},
+			{Kind: fetch.FetchedReleaseAsset, Path: "/tmp/fetch/tool_1.2.0_amd64.deb", Size: 2048, Package: &fetch.PackageMetadata{Format: fetch.PackageFormatDeb, Name: "tool", Version: "1.2.0-1", Arch: "amd64"}},
+		},
+		Timings: fetch.Timings{Resolve: 500 * time.Millisecond, Download: 2 * time.Second, Extract: 250 * time.Millisecond, Total: 3 * time.Second},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeFetchSummary(&out, result))
+
+	var summary fetchSummary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+	assert.Equal(t, fetchSummary{
+		Tag:          "v0.0.4",
+		TagCommitSha: "abc123",
+		Files: []fetchSummaryFile{
+			{Kind: "source-file", Path: "/tmp/fetch/README.md", Size: 42, Sha256: "deadbeef"},
+			{Kind: "release-asset", Path: "/tmp/fetch/tool", Size: 1048576, Sha256: "cafef00d"},
+			{Kind: "release-asset", Path: "/tmp/fetch/tool_1.2.0_amd64.deb", Size: 2048, Package: &fetchSummaryPackage{Format: "deb", Name: "tool", Version: "1.2.0-1", Arch: "amd64"}},
+		},
+		Durations: fetchSummaryTimings{Resolve: 0.5, Download: 2, Extract: 0.25, Total: 3},
+	}, summary)
+}
+
+func TestWriteFetchSummaryWithNoFiles(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	require.NoError(t, writeFetchSummary(&out, &fetch.Result{Tag: "v0.0.4"}))
+	assert.Contains(t, out.String(), `"files": []`)
+}
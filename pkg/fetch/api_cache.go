@@ -0,0 +1,107 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+)
+
+// A cache of GitHub API responses and their ETags, so that later requests for the same URL can be made conditional with
+// an If-None-Match header. GitHub answers those with 304 Not Modified if the response hasn't changed, which doesn't
+// count against the rate limit. For more info, see:
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#conditional-requests
+type apiResponseCache struct {
+	dir string
+}
+
+// A GitHub API response in an apiResponseCache
+type cachedApiResponse struct {
+	ETag string `json:"etag"`
+	Link string `json:"link,omitempty"` // The pagination links, which aren't sent with a 304 response
+	Body []byte `json:"body"`
+}
+
+// Return the cache of API responses in the given cache directory, or nil if cacheDir is empty, which turns caching off
+func newApiResponseCache(cacheDir string) *apiResponseCache {
+	if cacheDir == "" {
+		return nil
+	}
+	return &apiResponseCache{dir: cacheDir}
+}
+
+// Return the path at which the response to the given URL, as requested with the given token, is cached. Responses are
+// cached separately for each token, as they may differ depending on what the token has access to.
+func (cache *apiResponseCache) path(url string, token string) string {
+	tokenSha := sha256.Sum256([]byte(token))
+	keySha := sha256.Sum256([]byte(url + "\n" + hex.EncodeToString(tokenSha[:])))
+	return filepath.Join(cache.dir, "api-responses", hex.EncodeToString(keySha[:])+".json")
+}
+
+func (cache *apiResponseCache) get(url string, token string) (cachedApiResponse, bool) {
+	var cached cachedApiResponse
+	contents, err := ioutil.ReadFile(cache.path(url, token))
+	if err != nil {
+		return cached, false
+	}
+	if err := json.Unmarshal(contents, &cached); err != nil || cached.ETag == "" {
+		return cached, false
+	}
+	return cached, true
+}
+
+func (cache *apiResponseCache) put(url string, token string, response cachedApiResponse) error {
+	if err := markCacheDir(cache.dir); err != nil {
+		return err
+	}
+
+	contents, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(cache.path(url, token), func(tempPath string) error {
+		return ioutil.WriteFile(tempPath, contents, 0644)
+	})
+}
+
+// Call the GitHub API at the given URL and return the response body and its pagination links. If the response is in
+// the given cache, which may be nil, the request is conditional on it having changed, and if it hasn't, the cached
+// response is returned.
+func getGitHubApiConditionally(ctx context.Context, cache *apiResponseCache, repo GitHubRepo, url string) ([]byte, string, *FetchError) {
+	headers := map[string]string{}
+	cached, isCached := cachedApiResponse{}, false
+	if cache != nil {
+		if cached, isCached = cache.get(url, repo.Token); isCached {
+			headers["If-None-Match"] = cached.ETag
+		}
+	}
+
+	resp, fetchErr := callGitHubApiRaw(ctx, url, "GET", repo.Token, withApiVersionHeaders(repo, headers))
+	if fetchErr != nil {
+		if isCached && fetchErr.errorCode == http.StatusNotModified {
+			loggerFrom(ctx).Debugf("%s hasn't changed since it was cached\n", url)
+			return cached.Body, cached.Link, nil
+		}
+		return nil, "", addGhesCompatibilityHint(repo, fetchErr)
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, "", wrapError(err)
+	}
+	body := buf.Bytes()
+	link := resp.Header.Get("link")
+
+	if etag := resp.Header.Get("ETag"); cache != nil && etag != "" {
+		// The cache only saves rate limit, so a fetch shouldn't fail just because it can't be written to
+		if err := cache.put(url, repo.Token, cachedApiResponse{ETag: etag, Link: link, Body: body}); err != nil {
+			loggerFrom(ctx).Debugf("Could not cache the response from %s: %s\n", url, err)
+		}
+	}
+	return body, link, nil
+}
@@ -0,0 +1,59 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGitHubApiConditionally(t *testing.T) {
+	t.Parallel()
+
+	const etag = `"abc123"`
+	requests := 0
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Link", `<https://api.github.com/repos/foo/bar/tags?page=2>; rel="next"`)
+		w.Write([]byte(`[{"name": "v0.0.1"}]`))
+	}))
+	defer server.Close()
+
+	repo := GitHubRepo{Token: "token"}
+	cache := newApiResponseCache(t.TempDir())
+
+	for i := 0; i < 2; i++ {
+		body, link, fetchErr := getGitHubApiConditionally(context.Background(), cache, repo, server.URL)
+		require.Nil(t, fetchErr)
+		assert.Equal(t, `[{"name": "v0.0.1"}]`, string(body))
+		assert.Equal(t, `<https://api.github.com/repos/foo/bar/tags?page=2>; rel="next"`, link)
+	}
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+
+	// Responses are cached separately for each token
+	_, _, fetchErr := getGitHubApiConditionally(context.Background(), cache, GitHubRepo{Token: "other-token"}, server.URL)
+	require.Nil(t, fetchErr)
+	assert.Equal(t, 1, notModified)
+
+	// Without a cache, requests are never conditional
+	_, _, fetchErr = getGitHubApiConditionally(context.Background(), nil, repo, server.URL)
+	require.Nil(t, fetchErr)
+	assert.Equal(t, 1, notModified)
+}
+
+func TestNewApiResponseCacheWithoutCacheDir(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, newApiResponseCache(""))
+}
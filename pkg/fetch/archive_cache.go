@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Matches full SHA-1 and SHA-256 commit hashes. Abbreviated SHAs are never used as cache keys, since a longer prefix
+// of the same commit would miss the cache.
+var fullCommitShaRegex = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// A directory of the zip archives of GitHub repos, keyed by the SHA of the commit they were downloaded from. As every
+// branch and tag that points at the same commit has the same archive, they all share a single cache entry.
+type archiveCache struct {
+	dir string
+}
+
+// Return the path at which the archive of the given commit of the given repo is cached
+func (cache archiveCache) path(repo GitHubRepo, commitSha string) string {
+	return filepath.Join(cache.dir, repo.BaseUrl, repo.Owner, repo.Name, commitSha+".zip")
+}
+
+// Return the path of the cached archive of the given commit of the given repo, and whether it's in the cache at all
+func (cache archiveCache) get(repo GitHubRepo, commitSha string) (string, bool) {
+	cachedPath := cache.path(repo, commitSha)
+	if info, err := os.Stat(cachedPath); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return cachedPath, true
+}
+
+// Copy the archive at zipFilePath into the cache as the archive of the given commit of the given repo, and return its
+// path in the cache. The archive is written to a temporary file first, so that a concurrent or interrupted fetch never
+// sees a partial archive.
+func (cache archiveCache) put(repo GitHubRepo, commitSha string, zipFilePath string) (string, error) {
+	if err := markCacheDir(cache.dir); err != nil {
+		return "", err
+	}
+
+	cachedPath := cache.path(repo, commitSha)
+	if err := os.MkdirAll(filepath.Dir(cachedPath), 0755); err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(zipFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(cachedPath), commitSha+".zip.tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(out.Name(), cachedPath); err != nil {
+		return "", err
+	}
+	return cachedPath, nil
+}
@@ -0,0 +1,59 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveCachePutAndGet(t *testing.T) {
+	t.Parallel()
+
+	cache := archiveCache{dir: t.TempDir()}
+	repo := GitHubRepo{BaseUrl: "github.com", Owner: "gruntwork-io", Name: "fetch"}
+	commitSha := "d2de34edb1c6ef6ffd6a6a1a6f8c8a3c1b6d2f0e"
+
+	_, ok := cache.get(repo, commitSha)
+	assert.False(t, ok)
+
+	zipFilePath := filepath.Join(t.TempDir(), "repo.zip")
+	require.NoError(t, ioutil.WriteFile(zipFilePath, []byte("zip"), 0644))
+
+	cachedPath, err := cache.put(repo, commitSha, zipFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cache.dir, "github.com", "gruntwork-io", "fetch", commitSha+".zip"), cachedPath)
+
+	gotPath, ok := cache.get(repo, commitSha)
+	assert.True(t, ok)
+	assert.Equal(t, cachedPath, gotPath)
+
+	contents, err := ioutil.ReadFile(gotPath)
+	require.NoError(t, err)
+	assert.Equal(t, "zip", string(contents))
+
+	// Only the archive itself should be left in the cache, not the temporary file it was written to
+	entries, err := os.ReadDir(filepath.Dir(cachedPath))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestArchiveCommitShaWithoutLookup(t *testing.T) {
+	t.Parallel()
+
+	fetcher := &Fetcher{repo: GitHubRepo{BaseUrl: "github.com", Owner: "gruntwork-io", Name: "fetch"}}
+	commitSha := "d2de34edb1c6ef6ffd6a6a1a6f8c8a3c1b6d2f0e"
+	tagCommitSha := "0e1d4b3cf2c07c2c1a0c6b1e3a1e7f5b0d4e9c8a"
+
+	sha, err := fetcher.archiveCommitSha(context.Background(), GitHubCommit{CommitSha: commitSha, GitTag: "v0.1.0"}, tagCommitSha)
+	assert.Nil(t, err)
+	assert.Equal(t, commitSha, sha)
+
+	sha, err = fetcher.archiveCommitSha(context.Background(), GitHubCommit{GitTag: "v0.1.0", GitRef: "v0.1.0"}, tagCommitSha)
+	assert.Nil(t, err)
+	assert.Equal(t, tagCommitSha, sha)
+}
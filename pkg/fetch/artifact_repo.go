@@ -0,0 +1,372 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// The header Artifactory reads API keys from
+const artifactoryApiKeyHeader = "X-JFrog-Art-Api"
+
+// The credentials to read an Artifactory or Nexus repository with. Requests are sent with basic auth if Username is set,
+// or else with the Artifactory API key in ApiKey, if that's set.
+type ArtifactRepoAuth struct {
+	Username string
+	Password string // Or an access token, or a Nexus user token
+	ApiKey   string // An Artifactory API key
+}
+
+// A folder of a JFrog Artifactory generic repo or a Sonatype Nexus raw repo that releases are published to, often to
+// proxy GitHub releases inside a company network. It's laid out as <folder>/<version>/<asset>, so the "tags" of the
+// repository are the folders directly in its folder, and the "release assets" of a tag are the files directly in that,
+// e.g. https://artifactory.mycompany.com/artifactory/tools/terraform/v1.5.7/terraform_1.5.7_linux_amd64.zip.
+type ArtifactRepository struct {
+	Url        string // The URL of the folder, e.g. https://artifactory.mycompany.com/artifactory/tools/terraform
+	Kind       string // SourceArtifactory or SourceNexus
+	BaseUrl    string // The URL of the instance, including any context path, e.g. https://artifactory.mycompany.com/artifactory
+	Repository string // The key of the repository, e.g. tools
+	Path       string // The path of the folder in the repository, without a leading or trailing slash. May be empty.
+	Auth       ArtifactRepoAuth
+
+	// Every file under Path in a Nexus repository, listed on the first request that needs them, as Nexus can only list
+	// the files of a whole repository
+	nexusFiles []artifactRepoFile
+}
+
+// A file in an ArtifactRepository, by its path relative to the repository's folder
+type artifactRepoFile struct {
+	Path      string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// Parse the URL of a folder of an Artifactory or Nexus repository, as the given Source constant says it is. Artifactory
+// URLs have the form https://<host>/artifactory/<repo>/<path>, and Nexus URLs https://<host>[/<context-path>]/repository/<repo>/<path>.
+func ParseArtifactRepository(repoUrl string, kind string, auth ArtifactRepoAuth) (*ArtifactRepository, error) {
+	marker, form := "/artifactory/", "https://<host>/artifactory/<repo>/<path>"
+	if kind == SourceNexus {
+		marker, form = "/repository/", "https://<host>[/<context-path>]/repository/<repo>/<path>"
+	}
+
+	parsedUrl, err := url.Parse(repoUrl)
+	if err != nil || (parsedUrl.Scheme != "https" && parsedUrl.Scheme != "http") || parsedUrl.Host == "" {
+		return nil, fmt.Errorf("The %s repository %s must be a URL of the form %s.", kind, repoUrl, form)
+	}
+	contextPath, folder, found := strings.Cut(parsedUrl.Path, marker)
+	repository, folderPath, _ := strings.Cut(folder, "/")
+	if !found || repository == "" {
+		return nil, fmt.Errorf("The %s repository %s must be a URL of the form %s.", kind, repoUrl, form)
+	}
+
+	baseUrl := parsedUrl.Scheme + "://" + parsedUrl.Host + contextPath
+	if kind == SourceArtifactory {
+		baseUrl += "/artifactory"
+	}
+	return &ArtifactRepository{
+		Url:        strings.TrimSuffix(repoUrl, "/"),
+		Kind:       kind,
+		BaseUrl:    baseUrl,
+		Repository: repository,
+		Path:       strings.Trim(folderPath, "/"),
+		Auth:       auth,
+	}, nil
+}
+
+// Return the name the repository's releases go by, which is the last part of its path, or the repository's key if it
+// has none
+func (repo *ArtifactRepository) name() string {
+	if repo.Path == "" {
+		return repo.Repository
+	}
+	return path.Base(repo.Path)
+}
+
+func (repo *ArtifactRepository) description() string {
+	if repo.Kind == SourceNexus {
+		return "Nexus repository " + repo.Url
+	}
+	return "Artifactory repository " + repo.Url
+}
+
+func (repo *ArtifactRepository) assetUrl(tag string, name string) string {
+	return repo.Url + "/" + url.PathEscape(tag) + "/" + url.PathEscape(name)
+}
+
+// Return the path of the given folder in the repository, relative to its root, with no leading or trailing slash
+func (repo *ArtifactRepository) repositoryPath(folder string) string {
+	return strings.Trim(path.Join(repo.Path, folder), "/")
+}
+
+// Return the tags of the repository that are valid versions
+func (repo *ArtifactRepository) listTags(ctx context.Context, looseSemver bool) ([]string, error) {
+	folders, _, err := repo.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, folder := range folders {
+		if _, err := parseTagVersion(folder, looseSemver); err == nil {
+			tags = append(tags, folder)
+		}
+	}
+	return tags, nil
+}
+
+// Return the files in the folder of the given tag as a release, so that its assets can be matched as those of a GitHub
+// release are
+func (repo *ArtifactRepository) release(ctx context.Context, tag string) (GitHubReleaseApiResponse, error) {
+	release := GitHubReleaseApiResponse{Name: tag}
+	_, files, err := repo.list(ctx, tag)
+	if err != nil {
+		return release, err
+	}
+	if len(files) == 0 {
+		return release, fmt.Errorf("The %s has no release %s: there are no files in %s.", repo.description(), tag, repo.Url+"/"+tag+"/")
+	}
+	for _, file := range files {
+		release.Assets = append(release.Assets, GitHubReleaseAsset{Url: repo.assetUrl(tag, file.Path), Name: file.Path, Size: file.Size, UpdatedAt: file.UpdatedAt})
+	}
+	return release, nil
+}
+
+// List the names of the folders and the files directly in the given folder of the repository, relative to it. A folder
+// that doesn't exist has nothing in it.
+func (repo *ArtifactRepository) list(ctx context.Context, folder string) ([]string, []artifactRepoFile, error) {
+	if repo.Kind == SourceNexus {
+		return repo.listNexus(ctx, folder)
+	}
+	return repo.listArtifactory(ctx, folder)
+}
+
+// The response to an Artifactory file list request. For more info, see:
+// https://jfrog.com/help/r/jfrog-rest-apis/file-list
+type artifactoryFileList struct {
+	Files []struct {
+		Uri          string `json:"uri"`
+		Size         int64  `json:"size"`
+		LastModified string `json:"lastModified"`
+		Folder       bool   `json:"folder"`
+	} `json:"files"`
+}
+
+func (repo *ArtifactRepository) listArtifactory(ctx context.Context, folder string) ([]string, []artifactRepoFile, error) {
+	requestUrl := fmt.Sprintf("%s/api/storage/%s/%s?list&deep=0&listFolders=1", repo.BaseUrl, url.PathEscape(repo.Repository), escapePath(repo.repositoryPath(folder)))
+	body, fetchErr := repo.get(ctx, requestUrl)
+	if fetchErr != nil {
+		if fetchErr.errorCode == http.StatusNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fetchErr
+	}
+	defer body.Close()
+
+	var list artifactoryFileList
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, nil, fmt.Errorf("Could not parse the files in %s: %s", repo.Url+"/"+folder, err)
+	}
+	var folders []string
+	var files []artifactRepoFile
+	for _, item := range list.Files {
+		name := strings.Trim(item.Uri, "/")
+		if item.Folder {
+			folders = append(folders, name)
+			continue
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, item.LastModified)
+		files = append(files, artifactRepoFile{Path: name, Size: item.Size, UpdatedAt: updatedAt})
+	}
+	return folders, files, nil
+}
+
+// A page of the response to a Nexus list assets request. For more info, see:
+// https://help.sonatype.com/en/assets-api.html
+type nexusAssetsPage struct {
+	Items []struct {
+		Path         string `json:"path"`
+		FileSize     int64  `json:"fileSize"`
+		LastModified string `json:"lastModified"`
+	} `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+func (repo *ArtifactRepository) listNexus(ctx context.Context, folder string) ([]string, []artifactRepoFile, error) {
+	if repo.nexusFiles == nil {
+		files, err := repo.listNexusFiles(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo.nexusFiles = files
+	}
+
+	prefix := ""
+	if folder != "" {
+		prefix = folder + "/"
+	}
+	seen := map[string]bool{}
+	var folders []string
+	var files []artifactRepoFile
+	for _, file := range repo.nexusFiles {
+		if !strings.HasPrefix(file.Path, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(file.Path, prefix)
+		if subfolder, _, isInSubfolder := strings.Cut(name, "/"); isInSubfolder {
+			if !seen[subfolder] {
+				seen[subfolder] = true
+				folders = append(folders, subfolder)
+			}
+			continue
+		}
+		file.Path = name
+		files = append(files, file)
+	}
+	return folders, files, nil
+}
+
+// List every file under the repository's path, following every page of results
+func (repo *ArtifactRepository) listNexusFiles(ctx context.Context) ([]artifactRepoFile, error) {
+	prefix := ""
+	if repo.Path != "" {
+		prefix = repo.Path + "/"
+	}
+	files := []artifactRepoFile{}
+	continuationToken := ""
+	for {
+		query := url.Values{"repository": {repo.Repository}}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+		body, fetchErr := repo.get(ctx, repo.BaseUrl+"/service/rest/v1/assets?"+query.Encode())
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		var page nexusAssetsPage
+		err := json.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse the files in %s: %s", repo.Url, err)
+		}
+
+		for _, item := range page.Items {
+			itemPath := strings.TrimPrefix(item.Path, "/")
+			if !strings.HasPrefix(itemPath, prefix) {
+				continue
+			}
+			updatedAt, _ := time.Parse(time.RFC3339, item.LastModified)
+			files = append(files, artifactRepoFile{Path: strings.TrimPrefix(itemPath, prefix), Size: item.FileSize, UpdatedAt: updatedAt})
+		}
+		if page.ContinuationToken == "" {
+			return files, nil
+		}
+		continuationToken = page.ContinuationToken
+	}
+}
+
+// Return a GET request for the given URL, with the repository's credentials
+func (repo *ArtifactRepository) newRequest(ctx context.Context, requestUrl string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Auth.Username != "" {
+		request.SetBasicAuth(repo.Auth.Username, repo.Auth.Password)
+	} else if repo.Auth.ApiKey != "" {
+		request.Header.Set(artifactoryApiKeyHeader, repo.Auth.ApiKey)
+	}
+	return request, nil
+}
+
+// Return an HTTP client that follows redirects, which Artifactory sends to serve files from cloud storage, without
+// sending the API key to any other host
+func (repo *ArtifactRepository) httpClient(ctx context.Context) *http.Client {
+	return newHttpClientWithoutCrossHostHeaders(ctx, artifactoryApiKeyHeader)
+}
+
+// Send a GET request for the given URL of the repository's API, and return the body of its response if it succeeded.
+// The error code of a FetchError for a response that didn't succeed is its status code.
+func (repo *ArtifactRepository) get(ctx context.Context, requestUrl string) (io.ReadCloser, *FetchError) {
+	request, err := repo.newRequest(ctx, requestUrl)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	resp, err := repo.httpClient(ctx).Do(request)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, newError(resp.StatusCode, fmt.Sprintf("Request to the %s failed with HTTP Response %d: %s", repo.description(), resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return resp.Body, nil
+}
+
+// Download the file with the given name in the folder of the given tag to destPath. With noCache set, caches along the
+// way are asked not to serve a stored copy, as downloadUrl does.
+func (repo *ArtifactRepository) downloadAsset(ctx context.Context, tag string, name string, destPath string, withProgress bool, noCache bool) *FetchError {
+	assetUrl := repo.assetUrl(tag, name)
+	request, err := repo.newRequest(ctx, assetUrl)
+	if err != nil {
+		return wrapError(err)
+	}
+	if noCache {
+		request.Header.Set("Cache-Control", "no-cache")
+	}
+	resp, err := repo.httpClient(ctx).Do(request)
+	if err != nil {
+		return wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return newError(failedToDownloadFile, fmt.Sprintf("Failed to download %s. Received HTTP Response %d.", assetUrl, resp.StatusCode))
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+// Escape each segment of the given slash-separated path for use in a URL
+func escapePath(slashPath string) string {
+	segments := strings.Split(slashPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Return an error if options ask for anything an artifact repository doesn't have, as validateBucketMirrorOptions does
+// for a mirror, or if its credentials are incomplete
+func validateArtifactRepoOptions(options Options) error {
+	auth := options.ArtifactRepoAuth
+	switch {
+	case len(options.SourcePaths) > 0 || len(options.SourceFiles) > 0:
+		return fmt.Errorf("An artifact repository only has release assets, so source paths and files can't be downloaded from %s.", options.RepoUrl)
+	case options.CommitSha != "" || options.BranchName != "" || options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("An artifact repository only has versions, so --commit, --branch, --resolve-ref, and --expected-commit-sha can't be used with %s.", options.RepoUrl)
+	case options.releaseAssetRegex() == "" && !options.AutoAsset && !options.AllReleaseAssets:
+		return fmt.Errorf("An artifact repository only has release assets, so one must be selected with --release-asset, --auto-asset, or --all-release-assets to download from %s.", options.RepoUrl)
+	case options.VerifyWithRepoKey || options.CosignVerify || options.CheckImmutableTag || options.RequireImmutableTag || options.RequireSignedRef:
+		return fmt.Errorf("Only checksums and package signatures can be verified for release assets downloaded from an artifact repository, as the other checks need a GitHub repo.")
+	case options.DownloadConnections > 1:
+		return fmt.Errorf("Release assets can't be downloaded from an artifact repository over several connections.")
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for release assets downloaded from an artifact repository.")
+	case options.LocalDownloadPath == StdoutDownloadPath:
+		return fmt.Errorf("Release assets downloaded from an artifact repository can't be streamed to stdout. Use --stdout to print one once it's verified.")
+	case options.AssetSink != nil:
+		return fmt.Errorf("Release assets downloaded from an artifact repository can't be streamed to an AssetSink.")
+	case auth.Password != "" && auth.Username == "":
+		return fmt.Errorf("A password for the artifact repository %s requires a username. Use an API key to authenticate to Artifactory without one.", options.RepoUrl)
+	case auth.ApiKey != "" && options.Source != SourceArtifactory:
+		return fmt.Errorf("API keys can only be used with Artifactory. Authenticate to Nexus with a username and password or user token.")
+	case auth.ApiKey != "" && auth.Username != "":
+		return fmt.Errorf("Authenticate to the artifact repository %s with either a username and password or an API key, not both.", options.RepoUrl)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArtifactRepository(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		repoUrl            string
+		kind               string
+		expectedBaseUrl    string
+		expectedRepository string
+		expectedPath       string
+		expectedName       string
+	}{
+		{"https://artifactory.example.com/artifactory/tools/terraform", SourceArtifactory, "https://artifactory.example.com/artifactory", "tools", "terraform", "terraform"},
+		{"https://example.com/jfrog/artifactory/tools/hashicorp/terraform/", SourceArtifactory, "https://example.com/jfrog/artifactory", "tools", "hashicorp/terraform", "terraform"},
+		{"https://nexus.example.com/repository/tools/terraform", SourceNexus, "https://nexus.example.com", "tools", "terraform", "terraform"},
+		{"https://example.com/nexus/repository/terraform", SourceNexus, "https://example.com/nexus", "terraform", "", "terraform"},
+		{"https://nexus.example.com/repository/", SourceNexus, "", "", "", ""},
+		{"https://artifactory.example.com/tools/terraform", SourceArtifactory, "", "", "", ""},
+		{"s3://artifactory/tools", SourceArtifactory, "", "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			t.Parallel()
+			repo, err := ParseArtifactRepository(tc.repoUrl, tc.kind, ArtifactRepoAuth{})
+			if tc.expectedRepository == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedBaseUrl, repo.BaseUrl)
+			assert.Equal(t, tc.expectedRepository, repo.Repository)
+			assert.Equal(t, tc.expectedPath, repo.Path)
+			assert.Equal(t, tc.expectedName, repo.name())
+		})
+	}
+}
+
+func TestValidateArtifactRepoOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"release-asset", Options{Source: SourceArtifactory, TagConstraint: "~>1.0", ReleaseAsset: "tool"}, true},
+		{"api-key", Options{Source: SourceArtifactory, TagConstraint: "~>1.0", ReleaseAsset: "tool", ArtifactRepoAuth: ArtifactRepoAuth{ApiKey: "key"}}, true},
+		{"user-token", Options{Source: SourceNexus, TagConstraint: "~>1.0", ReleaseAsset: "tool", ArtifactRepoAuth: ArtifactRepoAuth{Username: "user", Password: "token"}}, true},
+		{"no-asset", Options{Source: SourceArtifactory, TagConstraint: "~>1.0"}, false},
+		{"source-path", Options{Source: SourceNexus, TagConstraint: "~>1.0", ReleaseAsset: "tool", SourcePaths: []string{"/"}}, false},
+		{"password-only", Options{Source: SourceNexus, TagConstraint: "~>1.0", ReleaseAsset: "tool", ArtifactRepoAuth: ArtifactRepoAuth{Password: "secret"}}, false},
+		{"nexus-api-key", Options{Source: SourceNexus, TagConstraint: "~>1.0", ReleaseAsset: "tool", ArtifactRepoAuth: ArtifactRepoAuth{ApiKey: "key"}}, false},
+		{"api-key-and-username", Options{Source: SourceArtifactory, TagConstraint: "~>1.0", ReleaseAsset: "tool", ArtifactRepoAuth: ArtifactRepoAuth{Username: "user", ApiKey: "key"}}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.options.RepoUrl = "https://example.com/artifactory/tools/tool"
+			err := validateArtifactRepoOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+// The files in the fake artifact repositories below, keyed by their path in the repository tools
+var fakeArtifactRepoFiles = map[string]string{
+	"tool/v1.0.0/tool_linux_amd64":  "old tool",
+	"tool/v1.1.0/tool_linux_amd64":  "new tool",
+	"tool/v1.1.0/tool_darwin_arm64": "new tool for mac",
+	"tool/v1.1.0/SHA256SUMS":        sha256Hex([]byte("new tool")) + "  tool_linux_amd64\n",
+	"tool/latest/tool_linux_amd64":  "not a version",
+	"other/v9.0.0/tool_linux_amd64": "another tool",
+}
+
+// Serve the file at the given path of the repository tools, as both fake repositories do
+func serveFakeArtifactRepoFile(w http.ResponseWriter, repoPath string) {
+	contents, ok := fakeArtifactRepoFiles[repoPath]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(contents))
+}
+
+// A fake Artifactory instance serving fakeArtifactRepoFiles from the repository tools, which requires the given API key
+func newFakeArtifactory(t *testing.T, apiKey string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(artifactoryApiKeyHeader) != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if folder := strings.TrimPrefix(r.URL.Path, "/artifactory/api/storage/tools/"); folder != r.URL.Path {
+			_, listFolders := r.URL.Query()["list"]
+			require.True(t, listFolders)
+			prefix := strings.Trim(folder, "/") + "/"
+			var list artifactoryFileList
+			seen := map[string]bool{}
+			for repoPath, contents := range fakeArtifactRepoFiles {
+				if !strings.HasPrefix(repoPath, prefix) {
+					continue
+				}
+				name := strings.TrimPrefix(repoPath, prefix)
+				if subfolder, _, isInSubfolder := strings.Cut(name, "/"); isInSubfolder {
+					if !seen[subfolder] {
+						seen[subfolder] = true
+						list.Files = append(list.Files, struct {
+							Uri          string `json:"uri"`
+							Size         int64  `json:"size"`
+							LastModified string `json:"lastModified"`
+							Folder       bool   `json:"folder"`
+						}{Uri: "/" + subfolder, Folder: true})
+					}
+					continue
+				}
+				list.Files = append(list.Files, struct {
+					Uri          string `json:"uri"`
+					Size         int64  `json:"size"`
+					LastModified string `json:"lastModified"`
+					Folder       bool   `json:"folder"`
+				}{Uri: "/" + name, Size: int64(len(contents)), LastModified: "2024-01-01T00:00:00.000Z"})
+			}
+			if len(list.Files) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		serveFakeArtifactRepoFile(w, strings.TrimPrefix(r.URL.Path, "/artifactory/tools/"))
+	}))
+}
+
+// A fake Nexus instance serving fakeArtifactRepoFiles from the repository tools, which requires the given basic auth
+// credentials, and lists one asset per page to exercise pagination
+func newFakeNexus(t *testing.T, username string, password string) *httptest.Server {
+	var repoPaths []string
+	for repoPath := range fakeArtifactRepoFiles {
+		repoPaths = append(repoPaths, repoPath)
+	}
+	sort.Strings(repoPaths)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != username || pass != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/service/rest/v1/assets" {
+			require.Equal(t, "tools", r.URL.Query().Get("repository"))
+			index := 0
+			if token := r.URL.Query().Get("continuationToken"); token != "" {
+				fmt.Sscan(token, &index)
+			}
+			repoPath := repoPaths[index]
+			var page nexusAssetsPage
+			page.Items = append(page.Items, struct {
+				Path         string `json:"path"`
+				FileSize     int64  `json:"fileSize"`
+				LastModified string `json:"lastModified"`
+			}{Path: "/" + repoPath, FileSize: int64(len(fakeArtifactRepoFiles[repoPath]))})
+			if index+1 < len(repoPaths) {
+				page.ContinuationToken = fmt.Sprint(index + 1)
+			}
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+		serveFakeArtifactRepoFile(w, strings.TrimPrefix(r.URL.Path, "/repository/tools/"))
+	}))
+}
+
+func TestFetchFromArtifactRepository(t *testing.T) {
+	t.Parallel()
+
+	artifactory := newFakeArtifactory(t, "api-key")
+	defer artifactory.Close()
+	nexus := newFakeNexus(t, "user", "token")
+	defer nexus.Close()
+
+	testCases := []struct {
+		name         string
+		source       string
+		repoUrl      string
+		auth         ArtifactRepoAuth
+		checksumFile string
+		tag          string
+		expectedTag  string
+	}{
+		{"artifactory", SourceArtifactory, artifactory.URL + "/artifactory/tools/tool", ArtifactRepoAuth{ApiKey: "api-key"}, "SHA256SUMS", "~>1.0", "v1.1.0"},
+		{"artifactory-exact", SourceArtifactory, artifactory.URL + "/artifactory/tools/tool", ArtifactRepoAuth{ApiKey: "api-key"}, "", "v1.0.0", "v1.0.0"},
+		{"artifactory-missing-version", SourceArtifactory, artifactory.URL + "/artifactory/tools/tool", ArtifactRepoAuth{ApiKey: "api-key"}, "", "~>2.0", ""},
+		{"artifactory-wrong-key", SourceArtifactory, artifactory.URL + "/artifactory/tools/tool", ArtifactRepoAuth{ApiKey: "wrong"}, "", "~>1.0", ""},
+		{"nexus", SourceNexus, nexus.URL + "/repository/tools/tool", ArtifactRepoAuth{Username: "user", Password: "token"}, "SHA256SUMS", "~>1.0", "v1.1.0"},
+		{"nexus-missing-checksums", SourceNexus, nexus.URL + "/repository/tools/tool", ArtifactRepoAuth{Username: "user", Password: "token"}, "MISSING", "~>1.0", ""},
+		{"nexus-no-auth", SourceNexus, nexus.URL + "/repository/tools/tool", ArtifactRepoAuth{}, "", "~>1.0", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:                  tc.repoUrl,
+				Source:                   tc.source,
+				ArtifactRepoAuth:         tc.auth,
+				TagConstraint:            tc.tag,
+				ReleaseAsset:             "tool_linux_amd64",
+				ReleaseAssetChecksumFile: tc.checksumFile,
+				LocalDownloadPath:        destPath,
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if tc.expectedTag == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTag, result.Tag)
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "tool_linux_amd64"))
+			require.NoError(t, err)
+			assert.Equal(t, fakeArtifactRepoFiles["tool/"+tc.expectedTag+"/tool_linux_amd64"], string(contents))
+			assert.NoFileExists(t, filepath.Join(destPath, "tool_darwin_arm64"))
+		})
+	}
+}
+
+func TestArtifactRepositoryDropsApiKeyOnRedirect(t *testing.T) {
+	t.Parallel()
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(artifactoryApiKeyHeader))
+		w.Write([]byte("new tool"))
+	}))
+	defer storage.Close()
+	artifactory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "api-key", r.Header.Get(artifactoryApiKeyHeader))
+		http.Redirect(w, r, storage.URL+"/blob", http.StatusFound)
+	}))
+	defer artifactory.Close()
+
+	repo, err := ParseArtifactRepository(artifactory.URL+"/artifactory/tools/tool", SourceArtifactory, ArtifactRepoAuth{ApiKey: "api-key"})
+	require.NoError(t, err)
+	destPath := filepath.Join(t.TempDir(), "tool_linux_amd64")
+	require.Nil(t, repo.downloadAsset(context.Background(), "v1.1.0", "tool_linux_amd64", destPath, false, false))
+
+	contents, err := ioutil.ReadFile(destPath)
+	require.NoError(t, err)
+	assert.Equal(t, "new tool", string(contents))
+}
+
+func TestLatestReleaseOnlyResolvesInGitHubRepos(t *testing.T) {
+	t.Parallel()
+
+	artifactory := newFakeArtifactory(t, "api-key")
+	defer artifactory.Close()
+
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:           artifactory.URL + "/artifactory/tools/tool",
+		Source:            SourceArtifactory,
+		ArtifactRepoAuth:  ArtifactRepoAuth{ApiKey: "api-key"},
+		TagConstraint:     LatestReleaseTag,
+		ReleaseAsset:      "tool_linux_amd64",
+		LocalDownloadPath: t.TempDir(),
+	})
+	require.NoError(t, err)
+	_, err = fetcher.ResolveTag(context.Background())
+	assert.ErrorContains(t, err, "Only GitHub repos have a latest release")
+}
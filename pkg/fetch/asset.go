@@ -0,0 +1,276 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// The orders in which release assets can be sorted, both to list them and to pick a single asset out of several that
+// match the ReleaseAsset option. Each order puts the asset you'd most likely want first.
+const (
+	AssetOrderName      = "name"      // Alphabetically by name
+	AssetOrderSize      = "size"      // Largest first
+	AssetOrderUpdated   = "updated"   // Most recently updated first
+	AssetOrderDownloads = "downloads" // Most downloaded first
+)
+
+var assetOrders = map[string]func(a *GitHubReleaseAsset, b *GitHubReleaseAsset) bool{
+	AssetOrderName:      func(a, b *GitHubReleaseAsset) bool { return a.Name < b.Name },
+	AssetOrderSize:      func(a, b *GitHubReleaseAsset) bool { return a.Size > b.Size },
+	AssetOrderUpdated:   func(a, b *GitHubReleaseAsset) bool { return a.UpdatedAt.After(b.UpdatedAt) },
+	AssetOrderDownloads: func(a, b *GitHubReleaseAsset) bool { return a.DownloadCount > b.DownloadCount },
+}
+
+// Return an error if order is not one of the AssetOrder constants
+func ValidateAssetOrder(order string) error {
+	if _, ok := assetOrders[order]; ok {
+		return nil
+	}
+
+	var orders []string
+	for name := range assetOrders {
+		orders = append(orders, name)
+	}
+	sort.Strings(orders)
+	return fmt.Errorf("Unknown release asset order \"%s\". Must be one of: %s.", order, strings.Join(orders, ", "))
+}
+
+// Sort the given assets in the given order. Assets that tie keep their relative order.
+func sortAssets(assets [](*GitHubReleaseAsset), order string) error {
+	if err := ValidateAssetOrder(order); err != nil {
+		return err
+	}
+
+	less := assetOrders[order]
+	sort.SliceStable(assets, func(i, j int) bool {
+		return less(assets[i], assets[j])
+	})
+	return nil
+}
+
+// The values that can be used in a ReleaseAsset template, e.g. tool_{{.OS}}_{{.Arch}}.tar.gz
+type ReleaseAssetTemplateVars struct {
+	OS      string // The operating system, e.g. linux. Defaults to runtime.GOOS.
+	Arch    string // The architecture, e.g. amd64. Defaults to runtime.GOARCH.
+	Tag     string // The resolved tag, e.g. v1.2.3
+	Version string // The resolved tag without a leading "v", e.g. 1.2.3
+}
+
+// Return the template values for the given tag, taking the OS and architecture from the Fetcher's options if they're
+// set, or from the platform fetch is running on otherwise
+func (fetcher *Fetcher) releaseAssetTemplateVars(tag string) ReleaseAssetTemplateVars {
+	vars := ReleaseAssetTemplateVars{
+		OS:      fetcher.options.OS,
+		Arch:    fetcher.options.Arch,
+		Tag:     tag,
+		Version: strings.TrimPrefix(tag, "v"),
+	}
+	if vars.OS == "" {
+		vars.OS = runtime.GOOS
+	}
+	if vars.Arch == "" {
+		vars.Arch = runtime.GOARCH
+	}
+	return vars
+}
+
+// Fill in the Go template placeholders, such as {{.OS}}, in the given release asset regex. The values are escaped, as
+// they're meant to match literally. A regex without placeholders is returned as-is.
+func ExpandReleaseAssetTemplate(assetRegex string, vars ReleaseAssetTemplateVars) (string, error) {
+	if !strings.Contains(assetRegex, "{{") {
+		return assetRegex, nil
+	}
+
+	tmpl, err := template.New("release-asset").Option("missingkey=error").Parse(assetRegex)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the template in release asset %s: %s", assetRegex, err)
+	}
+
+	quoted := ReleaseAssetTemplateVars{
+		OS:      regexp.QuoteMeta(vars.OS),
+		Arch:    regexp.QuoteMeta(vars.Arch),
+		Tag:     regexp.QuoteMeta(vars.Tag),
+		Version: regexp.QuoteMeta(vars.Version),
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, quoted); err != nil {
+		return "", fmt.Errorf("Could not fill in the template in release asset %s: %s", assetRegex, err)
+	}
+	return out.String(), nil
+}
+
+// Return the assets of the release with the given tag, in the given order (one of the AssetOrder constants). If the
+// ReleaseAsset option is set, only the assets matching it are returned.
+func (fetcher *Fetcher) ListReleaseAssets(ctx context.Context, tag string, order string) ([](*GitHubReleaseAsset), error) {
+	ctx = fetcher.withConnection(ctx)
+	release, fetchErr := GetGitHubReleaseInfo(ctx, fetcher.repo, tag)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	assetRegex, err := ExpandReleaseAssetTemplate(fetcher.options.ReleaseAsset, fetcher.releaseAssetTemplateVars(tag))
+	if err != nil {
+		return nil, err
+	}
+	if assetRegex == "" {
+		assetRegex = ".*"
+	}
+	assets, err := findAssetsInRelease(assetRegex, release)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sortAssets(assets, order); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// Selects the release assets to download out of the assets of a release
+type releaseAssetMatcher struct {
+	regex    string         // Assets whose names match this regex are candidates
+	platform *assetPlatform // If set, the assets that best match this platform are candidates instead, and only one may be selected
+	pickBy   string         // If set, only the first candidate in this order (one of the AssetOrder constants) is selected
+	minSize  uint64         // If not zero, candidates smaller than this many bytes are rejected
+	maxSize  uint64         // If not zero, candidates larger than this many bytes are rejected
+}
+
+// Build the releaseAssetMatcher described by the Fetcher's options for the release with the given tag
+func (fetcher *Fetcher) releaseAssetMatcher(tag string) (releaseAssetMatcher, error) {
+	options := fetcher.options
+
+	regex, err := ExpandReleaseAssetTemplate(options.releaseAssetRegex(), fetcher.releaseAssetTemplateVars(tag))
+	if err != nil {
+		return releaseAssetMatcher{}, err
+	}
+
+	minSize, err := ParseAssetSize("min-asset-size", options.MinAssetSize)
+	if err != nil {
+		return releaseAssetMatcher{}, err
+	}
+	maxSize, err := ParseAssetSize("max-asset-size", options.MaxAssetSize)
+	if err != nil {
+		return releaseAssetMatcher{}, err
+	}
+
+	var platform *assetPlatform
+	if options.AutoAsset {
+		autoPlatform := options.assetPlatform()
+		platform = &autoPlatform
+	}
+
+	return releaseAssetMatcher{
+		regex:    regex,
+		platform: platform,
+		pickBy:   options.ReleaseAssetPickBy,
+		minSize:  minSize,
+		maxSize:  maxSize,
+	}, nil
+}
+
+// Return true if the matcher can select any release assets at all
+func (matcher releaseAssetMatcher) selectsAssets() bool {
+	return matcher.regex != "" || matcher.platform != nil
+}
+
+// Describe the assets the matcher looks for, for use in log and error messages
+func (matcher releaseAssetMatcher) String() string {
+	if matcher.platform != nil {
+		return fmt.Sprintf("%s/%s", matcher.platform.os, matcher.platform.arch)
+	}
+	return matcher.regex
+}
+
+// Parse a size given with the --<optionName> flag, such as "512", "64KB", or "1.5GiB", into a number of bytes. An
+// empty value means there is no bound, and is returned as 0.
+func ParseAssetSize(optionName string, value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	size, err := humanize.ParseBytes(value)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("The --%s value \"%s\" must be a positive number of bytes, optionally with a unit (e.g. 64KB or 1.5GiB).", optionName, value)
+	}
+	return size, nil
+}
+
+// Return the assets of the given release that the matcher selects. Candidates outside of the size bounds are skipped
+// with a warning, and if every candidate is outside of them, an error listing them all is returned instead.
+func (matcher releaseAssetMatcher) match(logger *logrus.Entry, release GitHubReleaseApiResponse, tag string) ([](*GitHubReleaseAsset), error) {
+	assets, err := matcher.candidates(release)
+	if err != nil {
+		return nil, err
+	}
+	if assets == nil {
+		return nil, fmt.Errorf("Could not find assets matching %s in release %s", matcher, tag)
+	}
+
+	assets, rejected := matcher.filterBySize(assets)
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("All %d assets matching %s in release %s are outside of the allowed size range:\n\t%s", len(rejected), matcher, tag, strings.Join(rejected, "\n\t"))
+	}
+	if len(rejected) > 0 {
+		logger.Warnf("Skipping %d assets matching %s that are outside of the allowed size range:\n\t%s\n", len(rejected), matcher, strings.Join(rejected, "\n\t"))
+	}
+
+	if matcher.pickBy != "" {
+		if err := sortAssets(assets, matcher.pickBy); err != nil {
+			return nil, err
+		}
+		if len(assets) > 1 {
+			logger.Infof("Picked release asset %s, the first of %d matching assets by %s\n", assets[0].Name, len(assets), matcher.pickBy)
+		}
+		assets = assets[:1]
+	}
+
+	if matcher.platform != nil {
+		if len(assets) > 1 {
+			return nil, ambiguousAutoAssetError(*matcher.platform, tag, assets)
+		}
+		logger.Infof("Picked release asset %s for %s\n", assets[0].Name, matcher)
+	}
+
+	return assets, nil
+}
+
+// Return the assets of the given release that the matcher considers before applying its size bounds and pickBy order
+func (matcher releaseAssetMatcher) candidates(release GitHubReleaseApiResponse) ([](*GitHubReleaseAsset), error) {
+	if matcher.platform != nil {
+		var assets [](*GitHubReleaseAsset)
+		for _, asset := range release.Assets {
+			assetRef := asset
+			assets = append(assets, &assetRef)
+		}
+		return matcher.platform.bestAssets(assets), nil
+	}
+	return findAssetsInRelease(matcher.regex, release)
+}
+
+// Split the given assets into the ones within the matcher's size bounds and descriptions of the ones that aren't
+func (matcher releaseAssetMatcher) filterBySize(assets [](*GitHubReleaseAsset)) ([](*GitHubReleaseAsset), []string) {
+	var kept [](*GitHubReleaseAsset)
+	var rejected []string
+
+	for _, asset := range assets {
+		size := uint64(asset.Size)
+		if matcher.minSize > 0 && size < matcher.minSize {
+			rejected = append(rejected, fmt.Sprintf("%s (%s, smaller than the minimum of %s)", asset.Name, humanize.Bytes(size), humanize.Bytes(matcher.minSize)))
+		} else if matcher.maxSize > 0 && size > matcher.maxSize {
+			rejected = append(rejected, fmt.Sprintf("%s (%s, larger than the maximum of %s)", asset.Name, humanize.Bytes(size), humanize.Bytes(matcher.maxSize)))
+		} else {
+			kept = append(kept, asset)
+		}
+	}
+
+	return kept, rejected
+}
@@ -0,0 +1,240 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// How files served from a cache are placed in the directories that need them
+const (
+	LinkModeHardlink = "hardlink" // Hardlink to the cached file, or copy it if that fails (e.g. across file systems)
+	LinkModeSymlink  = "symlink"  // Symlink to the cached file, which only makes sense if the cache outlives the run
+	LinkModeReflink  = "reflink"  // Share the cached file's blocks copy-on-write where supported, or copy it otherwise
+	LinkModeCopy     = "copy"     // Copy the cached file
+)
+
+var linkModes = map[string]bool{LinkModeHardlink: true, LinkModeSymlink: true, LinkModeReflink: true, LinkModeCopy: true}
+
+// Return an error if mode is not one of the LinkMode constants. An empty mode means LinkModeHardlink.
+func ValidateLinkMode(mode string) error {
+	if mode == "" || linkModes[mode] {
+		return nil
+	}
+
+	var modes []string
+	for name := range linkModes {
+		modes = append(modes, name)
+	}
+	sort.Strings(modes)
+	return fmt.Errorf("Unknown link mode \"%s\". Must be one of: %s.", mode, strings.Join(modes, ", "))
+}
+
+// A content-addressed store of release assets, so that fetches that need an asset that's already been downloaded can
+// reuse it rather than download it again. Each asset is stored once under the SHA256 of its contents, however many
+// URLs it was downloaded from, and is indexed by the URL it was downloaded from:
+//
+//	<dir>/content/<sha256 of contents>
+//	<dir>/urls/<sha256 of URL>, which holds the sha256 of the contents
+//
+// The store either lives in a temporary directory for the length of a run, or in a cache directory that persists
+// across runs.
+type assetStore struct {
+	dir        string
+	persistent bool
+	linkMode   string
+	mutex      sync.Mutex
+}
+
+// Create an empty assetStore in a temporary directory. Call close once done with it.
+func newAssetStore(linkMode string) (*assetStore, error) {
+	dir, err := ioutil.TempDir("", "fetch-assets")
+	if err != nil {
+		return nil, err
+	}
+	return &assetStore{dir: dir, linkMode: linkMode}, nil
+}
+
+// Open the assetStore that persists in the given cache directory, creating it if it doesn't exist yet
+func openAssetStore(cacheDir string, linkMode string) *assetStore {
+	return &assetStore{dir: filepath.Join(cacheDir, "release-assets"), persistent: true, linkMode: linkMode}
+}
+
+// Delete the assets in the store, unless it persists across runs
+func (store *assetStore) close() error {
+	if store.persistent {
+		return nil
+	}
+	return os.RemoveAll(store.dir)
+}
+
+// Return the URL that identifies the given release asset of the given repo in the store. Every upload of a release
+// asset gets a new ID, so the contents at a URL never change.
+func releaseAssetUrl(repo GitHubRepo, asset *GitHubReleaseAsset) string {
+	return formatUrl(repo, createGitHubRepoUrlForPath(repo, fmt.Sprintf("releases/assets/%d", asset.Id)))
+}
+
+func (store *assetStore) contentPath(contentSha string) string {
+	return filepath.Join(store.dir, "content", contentSha)
+}
+
+func (store *assetStore) urlPath(assetUrl string) string {
+	urlSha := sha256.Sum256([]byte(assetUrl))
+	return filepath.Join(store.dir, "urls", hex.EncodeToString(urlSha[:]))
+}
+
+// If the asset at the given URL is in the store, place it at destPath according to the store's link mode and return
+// true
+func (store *assetStore) place(assetUrl string, destPath string) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	contents, err := ioutil.ReadFile(store.urlPath(assetUrl))
+	if err != nil {
+		return false, nil
+	}
+	contentSha := strings.TrimSpace(string(contents))
+	if !store.hasContent(contentSha) {
+		return false, nil
+	}
+	return true, placeFile(store.contentPath(contentSha), destPath, store.linkMode)
+}
+
+// Return true if the store has a copy of the contents with the given sha256. A copy whose contents no longer match
+// their sha256, e.g. because a download was written through a hardlink to it, is removed, so that it's stored again.
+func (store *assetStore) hasContent(contentSha string) bool {
+	storedPath := store.contentPath(contentSha)
+	if _, err := os.Stat(storedPath); err != nil {
+		return false
+	}
+	if actualSha, err := computeChecksum(storedPath, "sha256", nil); err != nil || actualSha != contentSha {
+		os.Remove(storedPath)
+		return false
+	}
+	return true
+}
+
+// Add the asset at the given URL, which has been downloaded to path, to the store. If the store's link mode is
+// LinkModeSymlink, the file at path is then replaced with a symlink to the stored copy.
+func (store *assetStore) add(assetUrl string, path string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.persistent {
+		if err := markCacheDir(filepath.Dir(store.dir)); err != nil {
+			return err
+		}
+	}
+
+	contentSha, err := computeChecksum(path, "sha256", nil)
+	if err != nil {
+		return err
+	}
+
+	storedPath := store.contentPath(contentSha)
+	if !store.hasContent(contentSha) {
+		// Symlinks can't be the stored copy itself, so store a hardlink (or copy) of the download instead
+		storeMode := store.linkMode
+		if storeMode == LinkModeSymlink || storeMode == "" {
+			storeMode = LinkModeHardlink
+		}
+		if err := writeFileAtomically(storedPath, func(tempPath string) error { return placeFile(path, tempPath, storeMode) }); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileAtomically(store.urlPath(assetUrl), func(tempPath string) error {
+		return ioutil.WriteFile(tempPath, []byte(contentSha), 0644)
+	}); err != nil {
+		return err
+	}
+
+	if store.linkMode == LinkModeSymlink {
+		return placeFile(storedPath, path, LinkModeSymlink)
+	}
+	return nil
+}
+
+// Create the file at path by calling write with a temporary path in the same directory, and then renaming the
+// temporary file to path, so that other runs sharing the store never see a partially written file
+func writeFileAtomically(path string, write func(tempPath string) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempPath)
+
+	if err := write(tempPath); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// Place the file at srcPath at destPath, replacing any file already there, according to the given link mode (one of
+// the LinkMode constants, or empty for LinkModeHardlink)
+func placeFile(srcPath string, destPath string, linkMode string) error {
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch linkMode {
+	case LinkModeSymlink:
+		absSrcPath, err := filepath.Abs(srcPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(absSrcPath, destPath)
+	case LinkModeCopy:
+		return copyFile(srcPath, destPath, false)
+	case LinkModeReflink:
+		return copyFile(srcPath, destPath, true)
+	default:
+		if err := os.Link(srcPath, destPath); err == nil {
+			return nil
+		}
+		return copyFile(srcPath, destPath, false)
+	}
+}
+
+// Copy the file at srcPath to destPath with the same permissions. If reflink is true, the copy shares the blocks of the
+// original copy-on-write, if the platform and file system support it.
+func copyFile(srcPath string, destPath string, reflink bool) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if reflink && cloneFile(in, out) == nil {
+		return out.Close()
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(destPath)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,160 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetStoreSharesAssets(t *testing.T) {
+	t.Parallel()
+
+	store, err := newAssetStore("")
+	require.NoError(t, err)
+	defer store.close()
+
+	repo := GitHubRepo{ApiUrl: "api.github.com", Owner: "foo", Name: "bar"}
+	assetUrl := releaseAssetUrl(repo, &GitHubReleaseAsset{Id: 42})
+	assert.Equal(t, "https://api.github.com/repos/foo/bar/releases/assets/42", assetUrl)
+
+	firstPath := filepath.Join(t.TempDir(), "bar_linux_amd64")
+	secondPath := filepath.Join(t.TempDir(), "bar_linux_amd64")
+
+	placed, err := store.place(assetUrl, secondPath)
+	require.NoError(t, err)
+	assert.False(t, placed)
+
+	require.NoError(t, ioutil.WriteFile(firstPath, []byte("binary"), 0644))
+	require.NoError(t, store.add(assetUrl, firstPath))
+
+	// The stored copy has to outlive the original, which --unpack may delete
+	require.NoError(t, os.Remove(firstPath))
+
+	placed, err = store.place(assetUrl, secondPath)
+	require.NoError(t, err)
+	assert.True(t, placed)
+
+	contents, err := ioutil.ReadFile(secondPath)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(contents))
+}
+
+func TestAssetStorePersistsAcrossRuns(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	assetUrl := "https://api.github.com/repos/foo/bar/releases/assets/42"
+	firstPath := filepath.Join(t.TempDir(), "bar_linux_amd64")
+	require.NoError(t, ioutil.WriteFile(firstPath, []byte("binary"), 0644))
+
+	first := openAssetStore(cacheDir, LinkModeSymlink)
+	require.NoError(t, first.add(assetUrl, firstPath))
+	require.NoError(t, first.close())
+
+	// With the symlink mode, the download itself is replaced with a link into the cache
+	target, err := os.Readlink(firstPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(target, cacheDir), target)
+
+	secondPath := filepath.Join(t.TempDir(), "bar_linux_amd64")
+	second := openAssetStore(cacheDir, LinkModeCopy)
+	placed, err := second.place(assetUrl, secondPath)
+	require.NoError(t, err)
+	assert.True(t, placed)
+
+	info, err := os.Lstat(secondPath)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+}
+
+func TestAssetStoreKeepsHardlinkedAssetsIntact(t *testing.T) {
+	t.Parallel()
+
+	// Two releases with an asset of the same name, but different contents
+	contents := map[string]string{"/api/v3/repos/foo/bar/releases/assets/1": "v1", "/api/v3/repos/foo/bar/releases/assets/2": "v2"}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := contents[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:          server.URL + "/foo/bar",
+		GithubApiVersion: "v3",
+		Connection:       ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+	})
+	require.NoError(t, err)
+	ctx := fetcher.withConnection(context.Background())
+
+	store := openAssetStore(t.TempDir(), LinkModeHardlink)
+	dest := newDestRoot(t.TempDir(), false)
+	v1 := &GitHubReleaseAsset{Id: 1, Name: "tool", Size: 2}
+	v2 := &GitHubReleaseAsset{Id: 2, Name: "tool", Size: 2}
+
+	// Downloading v2 over the hardlink that v1 was placed with must not change the cached copy of v1
+	for _, asset := range []*GitHubReleaseAsset{v1, v2, v1} {
+		result := downloadReleaseAssetToDir(ctx, fetcher.logger, fetcher.repo, asset, dest, false, 1, store)
+		require.NoError(t, result.err)
+		written, err := ioutil.ReadFile(result.assetPath)
+		require.NoError(t, err)
+		assert.Equal(t, contents[fmt.Sprintf("/api/v3/repos/foo/bar/releases/assets/%d", asset.Id)], string(written))
+	}
+
+	// A cached copy that was changed anyway is not placed, so that it's downloaded again
+	assetPath := filepath.Join(dest.dir, "tool")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("v3"), 0644))
+	placed, err := store.place(releaseAssetUrl(fetcher.repo, v1), filepath.Join(t.TempDir(), "tool"))
+	require.NoError(t, err)
+	assert.False(t, placed)
+}
+
+func TestPlaceFile(t *testing.T) {
+	t.Parallel()
+
+	for _, linkMode := range []string{LinkModeHardlink, LinkModeSymlink, LinkModeReflink, LinkModeCopy} {
+		// The following is necessary to make sure linkMode's value doesn't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		linkMode := linkMode
+
+		t.Run(linkMode, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			srcPath := filepath.Join(dir, "src")
+			destPath := filepath.Join(dir, "dest")
+			require.NoError(t, ioutil.WriteFile(srcPath, []byte("new"), 0755))
+			require.NoError(t, ioutil.WriteFile(destPath, []byte("old"), 0644))
+
+			require.NoError(t, placeFile(srcPath, destPath, linkMode))
+
+			contents, err := ioutil.ReadFile(destPath)
+			require.NoError(t, err)
+			assert.Equal(t, "new", string(contents))
+
+			info, err := os.Lstat(destPath)
+			require.NoError(t, err)
+			assert.Equal(t, linkMode == LinkModeSymlink, info.Mode()&os.ModeSymlink != 0)
+		})
+	}
+}
+
+func TestValidateLinkMode(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateLinkMode(""))
+	assert.NoError(t, ValidateLinkMode(LinkModeReflink))
+	assert.EqualError(t, ValidateLinkMode("junction"), "Unknown link mode \"junction\". Must be one of: copy, hardlink, reflink, symlink.")
+}
@@ -0,0 +1,170 @@
+package fetch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortAssets(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		order         string
+		expectedNames []string
+	}{
+		{AssetOrderName, []string{"a", "b", "c", "d"}},
+		{AssetOrderSize, []string{"b", "c", "d", "a"}},
+		{AssetOrderUpdated, []string{"d", "a", "b", "c"}},
+		{AssetOrderDownloads, []string{"c", "a", "d", "b"}},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.order, func(t *testing.T) {
+			t.Parallel()
+
+			assets := [](*GitHubReleaseAsset){
+				{Name: "c", Size: 100, UpdatedAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), DownloadCount: 30},
+				{Name: "a", Size: 10, UpdatedAt: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), DownloadCount: 20},
+				{Name: "d", Size: 100, UpdatedAt: time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC), DownloadCount: 10},
+				{Name: "b", Size: 1000, UpdatedAt: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), DownloadCount: 0},
+			}
+			require.NoError(t, sortAssets(assets, tc.order))
+
+			var names []string
+			for _, asset := range assets {
+				names = append(names, asset.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestValidateAssetOrder(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateAssetOrder(AssetOrderUpdated))
+	assert.EqualError(t, ValidateAssetOrder("newest"), "Unknown release asset order \"newest\". Must be one of: downloads, name, size, updated.")
+}
+
+func TestGitHubReleaseAssetFromApiResponse(t *testing.T) {
+	t.Parallel()
+
+	var asset GitHubReleaseAsset
+	require.NoError(t, json.Unmarshal([]byte(`{"id": 1, "name": "tool_linux_amd64", "size": 1024, "updated_at": "2022-01-02T03:04:05Z", "download_count": 42}`), &asset))
+	assert.Equal(t, GitHubReleaseAsset{
+		Id:            1,
+		Name:          "tool_linux_amd64",
+		Size:          1024,
+		UpdatedAt:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		DownloadCount: 42,
+	}, asset)
+}
+
+func TestReleaseAssetMatcherMatch(t *testing.T) {
+	t.Parallel()
+
+	release := GitHubReleaseApiResponse{Assets: []GitHubReleaseAsset{
+		{Name: "tool_linux_amd64", Size: 5000000},
+		{Name: "tool_linux_amd64.placeholder", Size: 2},
+		{Name: "tool_linux_amd64.debug", Size: 900000000},
+	}}
+
+	cases := []struct {
+		name          string
+		matcher       releaseAssetMatcher
+		expectedNames []string
+		expectedError string
+	}{
+		{"no-bounds", releaseAssetMatcher{regex: "tool_linux_amd64"}, []string{"tool_linux_amd64", "tool_linux_amd64.placeholder", "tool_linux_amd64.debug"}, ""},
+		{"bounds", releaseAssetMatcher{regex: "tool_linux_amd64", minSize: 1000, maxSize: 100000000}, []string{"tool_linux_amd64"}, ""},
+		{"bounds-then-pick", releaseAssetMatcher{regex: "tool_linux_amd64", pickBy: AssetOrderSize, maxSize: 100000000}, []string{"tool_linux_amd64"}, ""},
+		{"all-rejected", releaseAssetMatcher{regex: "tool_linux_amd64\\.", minSize: 1000, maxSize: 100000000}, nil, "All 2 assets matching tool_linux_amd64\\. in release v1.0.0 are outside of the allowed size range:\n\ttool_linux_amd64.placeholder (2 B, smaller than the minimum of 1.0 kB)\n\ttool_linux_amd64.debug (900 MB, larger than the maximum of 100 MB)"},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assets, err := tc.matcher.match(GetProjectLogger(), release, "v1.0.0")
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, asset := range assets {
+				names = append(names, asset.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestParseAssetSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value        string
+		expectedSize uint64
+		expectErr    bool
+	}{
+		{"", 0, false},
+		{"512", 512, false},
+		{"64KB", 64000, false},
+		{"1.5GiB", 1610612736, false},
+		{"0", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tc := range cases {
+		size, err := ParseAssetSize("min-asset-size", tc.value)
+		if tc.expectErr {
+			assert.Error(t, err, tc.value)
+		} else {
+			assert.NoError(t, err, tc.value)
+			assert.Equal(t, tc.expectedSize, size, tc.value)
+		}
+	}
+}
+
+func TestExpandReleaseAssetTemplate(t *testing.T) {
+	t.Parallel()
+
+	vars := ReleaseAssetTemplateVars{OS: "linux", Arch: "amd64", Tag: "v1.2.3", Version: "1.2.3"}
+
+	cases := []struct {
+		assetRegex    string
+		expectedRegex string
+		expectErr     bool
+	}{
+		{"tool_linux_amd64", "tool_linux_amd64", false},
+		{"tool_{{.OS}}_{{.Arch}}.tar.gz", "tool_linux_amd64.tar.gz", false},
+		{"tool_{{.Version}}_{{.OS}}_.*", "tool_1\\.2\\.3_linux_.*", false},
+		{"tool-{{.Tag}}", "tool-v1\\.2\\.3", false},
+		{"tool_{{.Platform}}", "", true},
+		{"tool_{{.OS", "", true},
+	}
+
+	for _, tc := range cases {
+		regex, err := ExpandReleaseAssetTemplate(tc.assetRegex, vars)
+		if tc.expectErr {
+			assert.Error(t, err, tc.assetRegex)
+		} else {
+			assert.NoError(t, err, tc.assetRegex)
+			assert.Equal(t, tc.expectedRegex, regex, tc.assetRegex)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package fetch
+
+import "os"
+
+// Files can't be locked on this platform, so appends to an audit log are only serialized within a single process
+func lockAuditLog(file *os.File) error {
+	return nil
+}
+
+func unlockAuditLog(file *os.File) error {
+	return nil
+}
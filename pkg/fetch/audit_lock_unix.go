@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package fetch
+
+import (
+	"os"
+	"syscall"
+)
+
+// Take an exclusive lock on the given audit log, waiting for any other process that has it to let go
+func lockAuditLog(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+func unlockAuditLog(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
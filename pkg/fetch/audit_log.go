@@ -0,0 +1,240 @@
+package fetch
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The operations recorded in an audit log
+const (
+	AuditResolve  = "resolve"  // The reference a fetch was asked for was resolved to a tag or commit
+	AuditDownload = "download" // A file, source path, or release asset was downloaded
+)
+
+// The kind of the AuditDownload record of a source path, which isn't a single file, so has no size or checksum
+const auditSourcePath = "source-path"
+
+// The most a single record of an audit log is expected to take up, which is how far back from its end the log is
+// read to find the hash of its last record
+const maxAuditRecordSize = 64 * 1024
+
+// A single line of an audit log written with the AuditLog option. Each record holds the hash of the one before it, so
+// that changing, removing, or reordering any record breaks the chain from there on. See VerifyAuditLog.
+type AuditRecord struct {
+	Time        string `json:"time"`
+	Operation   string `json:"operation"`
+	User        string `json:"user"`
+	Host        string `json:"host"`
+	ToolVersion string `json:"toolVersion,omitempty"`
+
+	// What was asked for
+	Repo          string `json:"repo"`
+	TagConstraint string `json:"tagConstraint,omitempty"`
+	Branch        string `json:"branch,omitempty"`
+	Commit        string `json:"commit,omitempty"`
+
+	// What it was resolved to
+	Tag       string `json:"tag,omitempty"`
+	TagCommit string `json:"tagCommit,omitempty"`
+
+	// What was downloaded, and where to. Only set on AuditDownload records.
+	Kind   string `json:"kind,omitempty"` // FetchedSourceFile, FetchedReleaseAsset, or source-path
+	Name   string `json:"name,omitempty"` // The source path, for a source-path record
+	Path   string `json:"path,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Sha256 string `json:"sha256,omitempty"`
+
+	PrevHash string `json:"prevHash"` // The Hash of the record before this one, or empty for the first record
+	Hash     string `json:"hash"`     // The SHA256 checksum of this record with an empty Hash
+}
+
+// Serializes appending to audit logs within this process. Appends from other processes are serialized with a lock on
+// the file, where the platform supports it.
+var auditLogLock = sync.Mutex{}
+
+// Return the SHA256 checksum of the given record with an empty Hash, which is what its Hash should be
+func (record AuditRecord) computeHash() (string, error) {
+	record.Hash = ""
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Return the records of the AuditLog option for the given successful fetch: one AuditResolve record, then one
+// AuditDownload record for each source path and file it downloaded
+func (fetcher *Fetcher) auditRecords(result *Result, now time.Time) []AuditRecord {
+	options := fetcher.options
+	base := AuditRecord{
+		Time:          now.UTC().Format(time.RFC3339),
+		User:          auditUser(),
+		ToolVersion:   options.ToolVersion,
+		Repo:          fetcher.repo.Url,
+		TagConstraint: options.TagConstraint,
+		Branch:        options.BranchName,
+		Commit:        options.CommitSha,
+		Tag:           result.Tag,
+		TagCommit:     result.TagCommitSha,
+	}
+	base.Host, _ = os.Hostname()
+	if options.Url != "" {
+		base.Repo = options.Url
+	}
+
+	resolve := base
+	resolve.Operation = AuditResolve
+	records := []AuditRecord{resolve}
+	if options.Url == "" {
+		for _, sourcePath := range options.sourcePaths() {
+			record := base
+			record.Operation = AuditDownload
+			record.Kind = auditSourcePath
+			record.Name = sourcePath
+			record.Path = options.LocalDownloadPath
+			records = append(records, record)
+		}
+	}
+	for _, file := range result.Files {
+		record := base
+		record.Operation = AuditDownload
+		record.Kind = file.Kind
+		record.Path = file.Path
+		record.Size = file.Size
+		record.Sha256 = file.Sha256
+		records = append(records, record)
+	}
+	return records
+}
+
+// Return the name of the user fetch runs as, or an empty string if it can't be found out
+func auditUser() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// Append the given records to the audit log at the given path, creating it if it doesn't exist, and chaining each to
+// the record before it
+func appendAuditRecords(path string, records []AuditRecord) error {
+	auditLogLock.Lock()
+	defer auditLogLock.Unlock()
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not open the audit log %s: %s", path, err)
+	}
+	defer file.Close()
+	if err := lockAuditLog(file); err != nil {
+		return fmt.Errorf("Could not lock the audit log %s: %s", path, err)
+	}
+	defer unlockAuditLog(file)
+
+	prevHash, err := lastAuditHash(file)
+	if err != nil {
+		return fmt.Errorf("Could not read the last record of the audit log %s: %s", path, err)
+	}
+	var lines []byte
+	for _, record := range records {
+		record.PrevHash = prevHash
+		if record.Hash, err = record.computeHash(); err != nil {
+			return err
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+		prevHash = record.Hash
+	}
+	// The records are written at once, so a fetch's records aren't split up if writing fails part way
+	if _, err := file.Write(lines); err != nil {
+		return fmt.Errorf("Could not write to the audit log %s: %s", path, err)
+	}
+	return file.Sync()
+}
+
+// Return the Hash of the last record in the given audit log, or an empty string if it has none
+func lastAuditHash(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := info.Size() - maxAuditRecordSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return "", err
+	}
+
+	content := strings.TrimRight(string(tail), "\n")
+	if content == "" {
+		return "", nil
+	}
+	lastNewline := strings.LastIndex(content, "\n")
+	if lastNewline < 0 && offset > 0 {
+		return "", fmt.Errorf("its last record is longer than %d bytes", maxAuditRecordSize)
+	}
+	var record AuditRecord
+	if err := json.Unmarshal([]byte(content[lastNewline+1:]), &record); err != nil {
+		return "", fmt.Errorf("its last record is not valid JSON: %s", err)
+	}
+	return record.Hash, nil
+}
+
+// VerifyAuditLog checks that every record of the audit log at the given path is intact and chained to the record
+// before it. It returns the number of records and the Hash of the last one, which can be kept elsewhere to check later
+// that no records were removed from the end of the log, which the chain alone can't tell.
+func VerifyAuditLog(path string) (int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), maxAuditRecordSize)
+	count := 0
+	prevHash := ""
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, prevHash, fmt.Errorf("Line %d of the audit log %s is not a valid record: %s", lineNumber, path, err)
+		}
+		if record.PrevHash != prevHash {
+			return count, prevHash, fmt.Errorf("Line %d of the audit log %s doesn't follow the record before it, so records were removed, added, or reordered.", lineNumber, path)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return count, prevHash, err
+		}
+		if record.Hash != hash {
+			return count, prevHash, fmt.Errorf("Line %d of the audit log %s was modified after it was written: its hash is %s, but it should be %s.", lineNumber, path, record.Hash, hash)
+		}
+		count++
+		prevHash = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, prevHash, fmt.Errorf("Could not read the audit log %s: %s", path, err)
+	}
+	return count, prevHash, nil
+}
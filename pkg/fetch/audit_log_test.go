@@ -0,0 +1,174 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Return the records of the audit log at the given path
+func readAuditLog(t *testing.T, path string) []AuditRecord {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestAppendAuditRecordsChainsRecords(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, appendAuditRecords(path, []AuditRecord{{Operation: AuditResolve, Repo: "https://github.com/foo/bar", Tag: "v1.0.0"}}))
+	require.NoError(t, appendAuditRecords(path, []AuditRecord{
+		{Operation: AuditResolve, Repo: "https://github.com/foo/bar", Tag: "v1.1.0"},
+		{Operation: AuditDownload, Repo: "https://github.com/foo/bar", Tag: "v1.1.0", Kind: FetchedReleaseAsset, Path: "/tmp/tool", Size: 8, Sha256: "abcd"},
+	}))
+
+	records := readAuditLog(t, path)
+	require.Len(t, records, 3)
+	assert.Empty(t, records[0].PrevHash)
+	assert.Equal(t, records[0].Hash, records[1].PrevHash)
+	assert.Equal(t, records[1].Hash, records[2].PrevHash)
+
+	count, lastHash, err := VerifyAuditLog(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, records[2].Hash, lastHash)
+}
+
+func TestVerifyAuditLogDetectsTampering(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		tamper func(lines []string) []string
+	}{
+		{"modified", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "v1.1.0", "v6.6.6", 1)
+			return lines
+		}},
+		{"removed", func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		}},
+		{"reordered", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}},
+		{"not-json", func(lines []string) []string {
+			return append(lines, "not a record")
+		}},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "audit.log")
+			for _, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+				require.NoError(t, appendAuditRecords(path, []AuditRecord{{Operation: AuditResolve, Repo: "https://github.com/foo/bar", Tag: tag}}))
+			}
+			contents, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			lines := tc.tamper(strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n"))
+			require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+			_, _, err = VerifyAuditLog(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAppendAuditRecordsConcurrently(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, appendAuditRecords(path, []AuditRecord{{Operation: AuditResolve, Repo: "https://github.com/foo/bar", Tag: fmt.Sprintf("v1.%d.0", i)}}))
+		}(i)
+	}
+	wg.Wait()
+
+	count, _, err := VerifyAuditLog(path)
+	require.NoError(t, err)
+	assert.Equal(t, 20, count)
+}
+
+func TestFetchWritesAuditLog(t *testing.T) {
+	t.Parallel()
+
+	artifactory := newFakeArtifactory(t, "api-key")
+	defer artifactory.Close()
+
+	destPath := t.TempDir()
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:           artifactory.URL + "/artifactory/tools/tool",
+		Source:            SourceArtifactory,
+		ArtifactRepoAuth:  ArtifactRepoAuth{ApiKey: "api-key"},
+		TagConstraint:     "~>1.0",
+		ReleaseAsset:      "tool_linux_amd64",
+		LocalDownloadPath: destPath,
+		AuditLog:          auditLog,
+		ToolVersion:       "v1.2.3",
+	})
+	require.NoError(t, err)
+	_, err = fetcher.Fetch(context.Background(), io.Discard)
+	require.NoError(t, err)
+
+	records := readAuditLog(t, auditLog)
+	require.Len(t, records, 2)
+	assert.Equal(t, AuditResolve, records[0].Operation)
+	assert.Equal(t, "~>1.0", records[0].TagConstraint)
+	assert.Equal(t, "v1.1.0", records[0].Tag)
+	assert.Equal(t, "v1.2.3", records[0].ToolVersion)
+	assert.NotEmpty(t, records[0].Time)
+
+	assert.Equal(t, AuditDownload, records[1].Operation)
+	assert.Equal(t, FetchedReleaseAsset, records[1].Kind)
+	assert.Equal(t, filepath.Join(destPath, "tool_linux_amd64"), records[1].Path)
+	assert.Equal(t, sha256Hex([]byte("new tool")), records[1].Sha256)
+	assert.Equal(t, int64(len("new tool")), records[1].Size)
+
+	// A fetch that fails writes nothing
+	fetcher, err = NewFetcher(Options{
+		RepoUrl:           artifactory.URL + "/artifactory/tools/tool",
+		Source:            SourceArtifactory,
+		ArtifactRepoAuth:  ArtifactRepoAuth{ApiKey: "api-key"},
+		TagConstraint:     "~>9.0",
+		ReleaseAsset:      "tool_linux_amd64",
+		LocalDownloadPath: destPath,
+		AuditLog:          auditLog,
+	})
+	require.NoError(t, err)
+	_, err = fetcher.Fetch(context.Background(), io.Discard)
+	require.Error(t, err)
+	count, _, err := VerifyAuditLog(auditLog)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,50 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The schemes the GitHub token can be sent with in the Authorization header
+const (
+	AuthSchemeAuto   = "auto"   // Bearer for the kinds of tokens that are usually sent that way, and token otherwise
+	AuthSchemeToken  = "token"  // Authorization: token <token>
+	AuthSchemeBearer = "bearer" // Authorization: Bearer <token>
+)
+
+// The prefixes of GitHub tokens that are sent with the Bearer scheme in AuthSchemeAuto: fine-grained personal access
+// tokens, OAuth tokens (including those from the device flow), and GitHub App user and installation tokens. For more
+// info, see:
+// https://docs.github.com/en/authentication/keeping-your-account-and-data-secure/about-authentication-to-github#githubs-token-formats
+var bearerTokenPrefixes = []string{"github_pat_", "gho_", "ghu_", "ghs_"}
+
+// Return an error if scheme is not one of the AuthScheme constants. An empty scheme means AuthSchemeAuto.
+func ValidateAuthScheme(scheme string) error {
+	switch scheme {
+	case "", AuthSchemeAuto, AuthSchemeToken, AuthSchemeBearer:
+		return nil
+	default:
+		return fmt.Errorf("Unknown auth scheme \"%s\". Must be one of: %s, %s, %s.", scheme, AuthSchemeAuto, AuthSchemeBearer, AuthSchemeToken)
+	}
+}
+
+// Return the value of the Authorization header that sends the given GitHub token with the given scheme (one of the
+// AuthScheme constants, or empty for AuthSchemeAuto)
+func authorizationHeader(token string, scheme string) string {
+	if scheme == AuthSchemeBearer || ((scheme == AuthSchemeAuto || scheme == "") && isBearerToken(token)) {
+		return "Bearer " + token
+	}
+	return "token " + token
+}
+
+// Return whether the given token is of a kind that's usually sent with the Bearer scheme: a token with one of the
+// bearerTokenPrefixes, or a JSON Web Token, such as the ones GitHub Apps authenticate as themselves with, which GitHub
+// only accepts with the Bearer scheme
+func isBearerToken(token string) bool {
+	for _, prefix := range bearerTokenPrefixes {
+		if strings.HasPrefix(token, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// The names that release assets commonly use for each operating system, keyed by its runtime.GOOS value
+var assetOSNames = map[string][]string{
+	"darwin":  {"darwin", "macos", "mac", "osx", "apple"},
+	"linux":   {"linux"},
+	"windows": {"windows", "win", "win32", "win64"},
+	"freebsd": {"freebsd"},
+	"openbsd": {"openbsd"},
+	"netbsd":  {"netbsd"},
+	"solaris": {"solaris", "sunos"},
+	"android": {"android"},
+}
+
+// The names that release assets commonly use for each architecture, keyed by its runtime.GOARCH value. Note that
+// x86_64 and x86-64 are normalized to amd64 before matching, as x86 on its own means 386.
+var assetArchNames = map[string][]string{
+	"amd64":   {"amd64", "x64", "64bit"},
+	"386":     {"386", "i386", "i686", "x86", "32bit"},
+	"arm64":   {"arm64", "aarch64", "armv8"},
+	"arm":     {"arm", "armv5", "armv6", "armv7", "armhf", "armel"},
+	"ppc64le": {"ppc64le"},
+	"s390x":   {"s390x"},
+	"riscv64": {"riscv64"},
+}
+
+// Release assets with these extensions describe other assets, rather than being something you'd want to install
+var assetMetadataExtensions = []string{
+	".sha256", ".sha512", ".sha1", ".md5", ".sig", ".asc", ".pem", ".crt", ".cert", ".sbom", ".spdx", ".intoto.jsonl",
+	".txt", ".json", ".yaml", ".yml",
+}
+
+var assetNameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// The operating system and architecture to pick a release asset for with the AutoAsset option
+type assetPlatform struct {
+	os   string // A runtime.GOOS value
+	arch string // A runtime.GOARCH value
+}
+
+// Return the platform described by the OS and Arch options, defaulting to the platform fetch is running on
+func (options Options) assetPlatform() assetPlatform {
+	platform := assetPlatform{os: strings.ToLower(options.OS), arch: strings.ToLower(options.Arch)}
+	if platform.os == "" {
+		platform.os = runtime.GOOS
+	}
+	if platform.arch == "" {
+		platform.arch = runtime.GOARCH
+	}
+	return platform
+}
+
+// Return the given release assets that best match the platform. An asset is a candidate if its name mentions the
+// platform's operating system (or, for Windows, ends in .exe) and doesn't mention another architecture. Candidates
+// that name the platform's architecture beat those that name no architecture at all (e.g. macOS universal binaries).
+// Checksums, signatures, and other metadata files are never candidates.
+func (platform assetPlatform) bestAssets(assets [](*GitHubReleaseAsset)) [](*GitHubReleaseAsset) {
+	var best [](*GitHubReleaseAsset)
+	bestScore := 0
+
+	for _, asset := range assets {
+		score := platform.score(asset.Name)
+		if score == 0 || score < bestScore {
+			continue
+		}
+		if score > bestScore {
+			best = nil
+			bestScore = score
+		}
+		best = append(best, asset)
+	}
+
+	return best
+}
+
+// Return how well an asset with the given name matches the platform: 2 if it names the platform's OS and
+// architecture, 1 if it names the OS but no architecture, and 0 if it's not a candidate at all
+func (platform assetPlatform) score(assetName string) int {
+	name := strings.ToLower(assetName)
+	for _, extension := range assetMetadataExtensions {
+		if strings.HasSuffix(name, extension) {
+			return 0
+		}
+	}
+
+	name = strings.NewReplacer("x86_64", "amd64", "x86-64", "amd64").Replace(name)
+	words := map[string]bool{}
+	for _, word := range assetNameSeparators.Split(name, -1) {
+		words[word] = true
+	}
+
+	oses := namedPlatforms(words, assetOSNames)
+	if strings.HasSuffix(name, ".exe") {
+		oses["windows"] = true
+	}
+	if !oses[platform.os] {
+		return 0
+	}
+
+	arches := namedPlatforms(words, assetArchNames)
+	switch {
+	case arches[platform.arch]:
+		return 2
+	case len(arches) == 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Return the keys of the given names map that have at least one name in words
+func namedPlatforms(words map[string]bool, names map[string][]string) map[string]bool {
+	named := map[string]bool{}
+	for platform, aliases := range names {
+		for _, alias := range aliases {
+			if words[alias] {
+				named[platform] = true
+			}
+		}
+	}
+	return named
+}
+
+// Return an error saying that the AutoAsset option couldn't settle on a single asset, listing the candidates
+func ambiguousAutoAssetError(platform assetPlatform, tag string, assets [](*GitHubReleaseAsset)) error {
+	var names []string
+	for _, asset := range assets {
+		names = append(names, asset.Name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("Found %d release assets in release %s that match %s/%s equally well:\n\t%s\nUse --release-asset-pick-by to pick one of them, or --release-asset to name the one you want.", len(assets), tag, platform.os, platform.arch, strings.Join(names, "\n\t"))
+}
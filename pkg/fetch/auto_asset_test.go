@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssetPlatformBestAssets(t *testing.T) {
+	t.Parallel()
+
+	names := []string{
+		"tool_1.2.3_checksums.txt",
+		"tool_darwin_all.tar.gz",
+		"tool_linux_amd64.tar.gz",
+		"tool_linux_amd64.tar.gz.sha256",
+		"tool_linux_386.tar.gz",
+		"tool-1.2.3-aarch64-unknown-linux-gnu.tar.gz",
+		"tool-1.2.3-x86_64-pc-windows-msvc.zip",
+		"tool_macos_arm64.zip",
+		"tool.exe",
+	}
+	var assets [](*GitHubReleaseAsset)
+	for _, name := range names {
+		assets = append(assets, &GitHubReleaseAsset{Name: name})
+	}
+
+	cases := []struct {
+		platform      assetPlatform
+		expectedNames []string
+	}{
+		{assetPlatform{os: "linux", arch: "amd64"}, []string{"tool_linux_amd64.tar.gz"}},
+		{assetPlatform{os: "linux", arch: "386"}, []string{"tool_linux_386.tar.gz"}},
+		{assetPlatform{os: "linux", arch: "arm64"}, []string{"tool-1.2.3-aarch64-unknown-linux-gnu.tar.gz"}},
+		{assetPlatform{os: "darwin", arch: "arm64"}, []string{"tool_macos_arm64.zip"}},
+		{assetPlatform{os: "darwin", arch: "amd64"}, []string{"tool_darwin_all.tar.gz"}},
+		{assetPlatform{os: "windows", arch: "amd64"}, []string{"tool-1.2.3-x86_64-pc-windows-msvc.zip"}},
+		{assetPlatform{os: "windows", arch: "386"}, []string{"tool.exe"}},
+		{assetPlatform{os: "freebsd", arch: "amd64"}, nil},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.platform.os+"_"+tc.platform.arch, func(t *testing.T) {
+			t.Parallel()
+
+			var names []string
+			for _, asset := range tc.platform.bestAssets(assets) {
+				names = append(names, asset.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestReleaseAssetMatcherMatchAutoAsset(t *testing.T) {
+	t.Parallel()
+
+	release := GitHubReleaseApiResponse{Assets: []GitHubReleaseAsset{
+		{Name: "tool_linux_amd64.tar.gz", Size: 3000},
+		{Name: "tool_linux_x86_64.zip", Size: 4000},
+		{Name: "tool_linux_arm64.tar.gz", Size: 3000},
+	}}
+	platform := assetPlatform{os: "linux", arch: "amd64"}
+
+	_, err := releaseAssetMatcher{platform: &platform}.match(GetProjectLogger(), release, "v1.0.0")
+	assert.EqualError(t, err, "Found 2 release assets in release v1.0.0 that match linux/amd64 equally well:\n\ttool_linux_amd64.tar.gz\n\ttool_linux_x86_64.zip\nUse --release-asset-pick-by to pick one of them, or --release-asset to name the one you want.")
+
+	assets, err := releaseAssetMatcher{platform: &platform, pickBy: AssetOrderSize}.match(GetProjectLogger(), release, "v1.0.0")
+	assert.NoError(t, err)
+	if assert.Len(t, assets, 1) {
+		assert.Equal(t, "tool_linux_x86_64.zip", assets[0].Name)
+	}
+
+	windows := assetPlatform{os: "windows", arch: "amd64"}
+	_, err = releaseAssetMatcher{platform: &windows}.match(GetProjectLogger(), release, "v1.0.0")
+	assert.EqualError(t, err, "Could not find assets matching windows/amd64 in release v1.0.0")
+}
@@ -0,0 +1,273 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Matches the URL of a repo on a Bitbucket Server (or Data Center) instance, e.g.
+// https://bitbucket.mycompany.com/projects/OPS/repos/modules, which may be served under a context path, e.g.
+// https://mycompany.com/bitbucket/projects/OPS/repos/modules. GitHub URLs never have "repos" as their third segment,
+// so these can't be mistaken for GitHub URLs.
+var bitbucketServerRepoUrlRegex = regexp.MustCompile(`^(https?://[^/?#]+(?:/[^?#]*?)?)/projects/([^/?#]+)/repos/([^/?#]+)/?(?:[?#].*)?$`)
+
+// Matches the HTTPS clone URL of a repo on a Bitbucket Server instance, e.g.
+// https://bitbucket.mycompany.com/scm/ops/modules.git. This is only accepted with the bitbucket-dc source, as it looks
+// just like the URL of a GitHub repo whose owner is named scm.
+var bitbucketServerCloneUrlRegex = regexp.MustCompile(`^(https?://[^/?#]+(?:/[^?#]*?)?)/scm/([^/?#]+)/([^/?#]+?)(?:\.git)?/?$`)
+
+// The most tags Bitbucket Server returns in one page
+const bitbucketServerTagsPageLimit = 1000
+
+// A repo on a Bitbucket Server instance, whose REST API is entirely different from GitHub's, and Bitbucket Cloud's
+type BitbucketServerRepo struct {
+	Url        string // The URL of the repo
+	BaseUrl    string // The URL of the Bitbucket Server instance, including its context path, if any
+	ProjectKey string // The key of the project the repo is in, e.g. OPS
+	Slug       string // The slug of the repo, e.g. modules
+	Token      string // An HTTP access token or personal access token, sent as a Bearer token
+}
+
+// Parse repoUrl as the URL of a Bitbucket Server repo. Returns nil if it isn't one.
+func ParseBitbucketServerRepo(repoUrl string, token string) *BitbucketServerRepo {
+	matches := bitbucketServerRepoUrlRegex.FindStringSubmatch(repoUrl)
+	if matches == nil {
+		return nil
+	}
+	return &BitbucketServerRepo{Url: repoUrl, BaseUrl: matches[1], ProjectKey: matches[2], Slug: matches[3], Token: token}
+}
+
+// Parse repoUrl as the URL or HTTPS clone URL of a Bitbucket Server repo, for the bitbucket-dc source
+func ParseBitbucketDataCenterRepo(repoUrl string, token string) (*BitbucketServerRepo, error) {
+	if repo := ParseBitbucketServerRepo(repoUrl, token); repo != nil {
+		return repo, nil
+	}
+	matches := bitbucketServerCloneUrlRegex.FindStringSubmatch(repoUrl)
+	if matches == nil {
+		return nil, fmt.Errorf("%s is not the URL of a Bitbucket Data Center repo. Use its URL, e.g. https://bitbucket.mycompany.com/projects/OPS/repos/modules, or its HTTPS clone URL, e.g. https://bitbucket.mycompany.com/scm/ops/modules.git.", repoUrl)
+	}
+	// Project keys are always upper case, but clone URLs have them in lower case
+	return &BitbucketServerRepo{Url: repoUrl, BaseUrl: matches[1], ProjectKey: strings.ToUpper(matches[2]), Slug: matches[3], Token: token}, nil
+}
+
+// Return the URL of the given path under the repo in the REST API, e.g. tags
+func (repo *BitbucketServerRepo) apiUrl(path string, query url.Values) string {
+	apiUrl := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/%s", repo.BaseUrl, url.PathEscape(repo.ProjectKey), url.PathEscape(repo.Slug), path)
+	if len(query) > 0 {
+		apiUrl += "?" + query.Encode()
+	}
+	return apiUrl
+}
+
+// Send a GET request for the given URL of the REST API, accepting the given media type, and return the response if it
+// succeeded. Bitbucket Server only accepts tokens with the Bearer scheme, so unlike GitHub requests, this doesn't depend
+// on the auth scheme. The error code of a FetchError for a response that didn't succeed is its status code.
+func (repo *BitbucketServerRepo) get(ctx context.Context, requestUrl string, accept string) (*http.Response, *FetchError) {
+	request, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	request.Header.Set("Accept", accept)
+	if repo.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+repo.Token)
+	}
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, newError(resp.StatusCode, fmt.Sprintf("Request to Bitbucket Server repo %s failed with HTTP Response %d: %s", repo.Url, resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return resp, nil
+}
+
+// A page of the tags of a Bitbucket Server repo
+type bitbucketServerTagsPage struct {
+	Values []struct {
+		DisplayId    string `json:"displayId"`
+		LatestCommit string `json:"latestCommit"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// Fetch the tags of the given Bitbucket Server repo, as fetchTags does for a GitHub repo: the tags that are versions
+// (or that can be coerced into one, if looseSemver is set), and the SHA of the commit every tag points to
+func fetchBitbucketServerTags(ctx context.Context, repo *BitbucketServerRepo, looseSemver bool) ([]string, map[string]string, *FetchError) {
+	var tags []string
+	tagCommits := map[string]string{}
+
+	start := 0
+	for {
+		query := url.Values{"start": {fmt.Sprint(start)}, "limit": {fmt.Sprint(bitbucketServerTagsPageLimit)}}
+		resp, fetchErr := repo.get(ctx, repo.apiUrl("tags", query), "application/json")
+		if fetchErr != nil {
+			return tags, tagCommits, fetchErr
+		}
+		var page bitbucketServerTagsPage
+		err := json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return tags, tagCommits, wrapError(fmt.Errorf("Could not parse the tags of Bitbucket Server repo %s: %s", repo.Url, err))
+		}
+
+		for _, tag := range page.Values {
+			tagCommits[tag.DisplayId] = tag.LatestCommit
+			if _, err := parseTagVersion(tag.DisplayId, looseSemver); err == nil {
+				tags = append(tags, tag.DisplayId)
+			}
+		}
+
+		// A next page that doesn't move forward would otherwise loop forever
+		if page.IsLastPage || page.NextPageStart <= start {
+			return tags, tagCommits, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// Return the URL of a zip archive of the repo at the given git ref. The archive has a single top-level directory, as
+// GitHub's archives do, so that it can be extracted the same way.
+func (repo *BitbucketServerRepo) archiveUrl(gitHubCommit GitHubCommit) (string, error) {
+	var at string
+	// Ordering matters in this conditional, as it does in MakeGitHubZipFileRequest
+	switch {
+	case gitHubCommit.CommitSha != "":
+		at = gitHubCommit.CommitSha
+	case gitHubCommit.BranchName != "":
+		at = "refs/heads/" + gitHubCommit.BranchName
+	case gitHubCommit.GitTag != "":
+		at = "refs/tags/" + gitHubCommit.GitTag
+	case gitHubCommit.GitRef != "":
+		at = gitHubCommit.GitRef
+	default:
+		return "", fmt.Errorf("The commit sha, tag, and branch name are all empty")
+	}
+	return repo.apiUrl("archive", url.Values{"at": {at}, "format": {"zip"}, "prefix": {repo.Slug + "/"}}), nil
+}
+
+// Download a zip archive of the given Bitbucket Server repo at the given git ref to a temporary file, and return its
+// path, along with a function that deletes it
+func downloadBitbucketServerArchive(ctx context.Context, repo *BitbucketServerRepo, gitHubCommit GitHubCommit, withProgress bool) (string, func(), error) {
+	archiveUrl, err := repo.archiveUrl(gitHubCommit)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-bitbucket")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	resp, fetchErr := repo.get(ctx, archiveUrl, "application/zip")
+	if fetchErr != nil {
+		cleanup()
+		return "", nil, fetchErr
+	}
+	archivePath := filepath.Join(tempDir, "repo.zip")
+	if fetchErr := writeResonseToDisk(resp, archivePath, withProgress); fetchErr != nil {
+		cleanup()
+		return "", nil, fetchErr
+	}
+	return archivePath, cleanup, nil
+}
+
+// Create a Fetcher for the given Bitbucket Server repo
+func newBitbucketServerFetcher(options Options, logger *logrus.Entry, bitbucket *BitbucketServerRepo) (*Fetcher, error) {
+	if err := validateBitbucketServerOptions(options); err != nil {
+		return nil, err
+	}
+	repo := GitHubRepo{Url: bitbucket.Url, Owner: bitbucket.ProjectKey, Name: bitbucket.Slug, Token: bitbucket.Token}
+	return &Fetcher{options: options, logger: logger, repo: repo, bitbucket: bitbucket}, nil
+}
+
+// Return an error if options ask for anything a Bitbucket Server repo doesn't have. Bitbucket Server has no releases,
+// so only source paths can be downloaded from it.
+func validateBitbucketServerOptions(options Options) error {
+	switch {
+	case options.releaseAssetRegex() != "" || options.AutoAsset:
+		return fmt.Errorf("Bitbucket Server repos have no releases, so release assets can't be downloaded from %s. Use --source-path instead.", options.RepoUrl)
+	case len(options.SourceFiles) > 0:
+		return fmt.Errorf("Single source files can't be downloaded from Bitbucket Server repo %s. Use --source-path instead.", options.RepoUrl)
+	case options.CheckImmutableTag || options.RequireImmutableTag:
+		return fmt.Errorf("Tag immutability can't be checked in Bitbucket Server repo %s.", options.RepoUrl)
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for Bitbucket Server repo %s.", options.RepoUrl)
+	case options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("The commit a ref points to can only be resolved in GitHub repos, not in Bitbucket Server repo %s.", options.RepoUrl)
+	case options.RequireSignedRef:
+		return fmt.Errorf("The signatures of tags and commits can only be checked in GitHub repos, not in Bitbucket Server repo %s.", options.RepoUrl)
+	case options.IncludeDrafts:
+		return fmt.Errorf("Bitbucket Server repos have no releases, so %s has no draft releases to include.", options.RepoUrl)
+	}
+	return nil
+}
+
+// Download the source paths in the Fetcher's options from its Bitbucket Server repo, as Fetch does for a GitHub repo
+func (fetcher *Fetcher) fetchFromBitbucketServer(ctx context.Context) (*Result, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+	repo := fetcher.bitbucket
+
+	start := time.Now()
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Tag: resolvedTag.Tag, TagCommitSha: resolvedTag.CommitSha}
+	result.Timings.Resolve = time.Since(start)
+
+	sourcePaths := options.sourcePaths()
+	if len(sourcePaths) == 0 {
+		result.Timings.Total = time.Since(start)
+		return result, nil
+	}
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	downloadStart := time.Now()
+	gitHubCommit := fetcher.gitHubCommit(resolvedTag.Tag)
+	logger.Infof("Downloading %s of Bitbucket Server repo %s ...\n", gitHubCommit.ref(), repo.Url)
+	archivePath, cleanup, err := downloadBitbucketServerArchive(ctx, repo, gitHubCommit, options.WithProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	extractStart := time.Now()
+	for _, sourcePath := range sourcePaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		logger.Infof("Extracting files from <repo>%s to %s ...\n", sourcePath, options.LocalDownloadPath)
+		fileCount, err := extractFiles(archivePath, sourcePath, options.LocalDownloadPath, extractOptions)
+		logger.Infof("%d file(s) extracted\n", fileCount)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while extracting files from Bitbucket Server zip file: %s", err)
+		}
+	}
+	result.Timings.Extract = time.Since(extractStart)
+	result.Timings.Download = time.Since(downloadStart)
+
+	logger.Infof("Download and file extraction complete.\n")
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
@@ -0,0 +1,220 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBitbucketServerRepo(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		repoUrl  string
+		expected *BitbucketServerRepo
+	}{
+		{"https://bitbucket.mycompany.com/projects/OPS/repos/modules", &BitbucketServerRepo{BaseUrl: "https://bitbucket.mycompany.com", ProjectKey: "OPS", Slug: "modules"}},
+		{"https://bitbucket.mycompany.com/projects/OPS/repos/modules/browse?at=refs/heads/main", nil},
+		{"https://mycompany.com/bitbucket/projects/OPS/repos/modules/", &BitbucketServerRepo{BaseUrl: "https://mycompany.com/bitbucket", ProjectKey: "OPS", Slug: "modules"}},
+		{"http://localhost:7990/projects/OPS/repos/modules", &BitbucketServerRepo{BaseUrl: "http://localhost:7990", ProjectKey: "OPS", Slug: "modules"}},
+		{"https://github.com/gruntwork-io/fetch", nil},
+		{"https://ghe.mycompany.com/projects/repos", nil},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			t.Parallel()
+
+			repo := ParseBitbucketServerRepo(tc.repoUrl, "token")
+			if tc.expected == nil {
+				assert.Nil(t, repo)
+				return
+			}
+			tc.expected.Url = tc.repoUrl
+			tc.expected.Token = "token"
+			assert.Equal(t, tc.expected, repo)
+		})
+	}
+}
+
+func TestParseBitbucketDataCenterRepo(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		repoUrl  string
+		expected *BitbucketServerRepo
+	}{
+		{"https://bitbucket.mycompany.com/projects/OPS/repos/modules", &BitbucketServerRepo{BaseUrl: "https://bitbucket.mycompany.com", ProjectKey: "OPS", Slug: "modules"}},
+		{"https://bitbucket.mycompany.com/scm/ops/modules.git", &BitbucketServerRepo{BaseUrl: "https://bitbucket.mycompany.com", ProjectKey: "OPS", Slug: "modules"}},
+		{"https://mycompany.com/bitbucket/scm/ops/modules", &BitbucketServerRepo{BaseUrl: "https://mycompany.com/bitbucket", ProjectKey: "OPS", Slug: "modules"}},
+		{"https://bitbucket.mycompany.com/scm/ops", nil},
+		{"https://github.com/gruntwork-io/fetch", nil},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			t.Parallel()
+
+			repo, err := ParseBitbucketDataCenterRepo(tc.repoUrl, "token")
+			if tc.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tc.expected.Url = tc.repoUrl
+			tc.expected.Token = "token"
+			assert.Equal(t, tc.expected, repo)
+		})
+	}
+}
+
+// A fake Bitbucket Server instance with a single repo, OPS/modules, which serves its tags two to a page
+type fakeBitbucketServer struct {
+	t       *testing.T
+	tags    map[string]string
+	order   []string
+	archive []byte
+	ats     []string
+}
+
+func (server *fakeBitbucketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer bitbucket-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/rest/api/1.0/projects/OPS/repos/modules/tags":
+		start := 0
+		if r.URL.Query().Get("start") != "" {
+			require.NoError(server.t, json.Unmarshal([]byte(r.URL.Query().Get("start")), &start))
+		}
+		end := start + 2
+		if end > len(server.order) {
+			end = len(server.order)
+		}
+		var values []map[string]string
+		for _, tag := range server.order[start:end] {
+			values = append(values, map[string]string{"displayId": tag, "latestCommit": server.tags[tag]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"values": values, "isLastPage": end == len(server.order), "nextPageStart": end})
+	case "/rest/api/1.0/projects/OPS/repos/modules/archive":
+		assert.Equal(server.t, "zip", r.URL.Query().Get("format"))
+		assert.Equal(server.t, "modules/", r.URL.Query().Get("prefix"))
+		server.ats = append(server.ats, r.URL.Query().Get("at"))
+		w.Write(server.archive)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestFetchFromBitbucketServer(t *testing.T) {
+	t.Parallel()
+
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for _, name := range []string{"modules/", "modules/vpc/", "modules/vpc/main.tf", "modules/README.md"} {
+		writer, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		if name[len(name)-1] != '/' {
+			_, err = io.WriteString(writer, "contents of "+name)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, zipWriter.Close())
+
+	testCases := []struct {
+		name    string
+		source  string
+		urlPath string
+	}{
+		{"url", "", "/projects/OPS/repos/modules"},
+		{"clone-url", SourceBitbucketDc, "/scm/ops/modules.git"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fake := &fakeBitbucketServer{
+				t:       t,
+				tags:    map[string]string{"v1.0.0": "aaa", "v1.1.0": "bbb", "release-candidate": "ccc", "v2.0.0": "ddd"},
+				order:   []string{"v2.0.0", "v1.1.0", "release-candidate", "v1.0.0"},
+				archive: archive.Bytes(),
+			}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:           server.URL + tc.urlPath,
+				Source:            tc.source,
+				TagConstraint:     "~>1.0",
+				SourcePaths:       []string{"/vpc"},
+				BitbucketToken:    "bitbucket-token",
+				LocalDownloadPath: destPath,
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			require.NoError(t, err)
+			assert.Equal(t, "v1.1.0", result.Tag)
+			assert.Equal(t, "bbb", result.TagCommitSha)
+			assert.Equal(t, []string{"refs/tags/v1.1.0"}, fake.ats)
+
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "main.tf"))
+			require.NoError(t, err)
+			assert.Equal(t, "contents of modules/vpc/main.tf", string(contents))
+			assert.NoFileExists(t, filepath.Join(destPath, "README.md"))
+		})
+	}
+}
+
+func TestBitbucketServerRejectsReleaseAssets(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewFetcher(Options{RepoUrl: "https://bitbucket.mycompany.com/projects/OPS/repos/modules", TagConstraint: "v1.0.0", ReleaseAsset: "tool_.*"})
+	assert.Error(t, err)
+	_, err = NewFetcher(Options{RepoUrl: "https://bitbucket.mycompany.com/scm/ops/modules.git", Source: SourceBitbucketDc, TagConstraint: "v1.0.0", ReleaseAsset: "tool_.*"})
+	assert.Error(t, err)
+}
+
+func TestBitbucketDataCenterSourceRejectsOtherUrls(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewFetcher(Options{RepoUrl: "https://github.com/foo/bar", Source: SourceBitbucketDc, TagConstraint: "v1.0.0", SourcePaths: []string{"/"}})
+	assert.Error(t, err)
+}
+
+func TestBitbucketServerErrorsNameTheRepo(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&fakeBitbucketServer{t: t})
+	defer server.Close()
+
+	repo := ParseBitbucketServerRepo(server.URL+"/projects/OPS/repos/modules", "wrong-token")
+	_, _, fetchErr := fetchBitbucketServerTags(context.Background(), repo, false)
+	require.NotNil(t, fetchErr)
+	assert.Equal(t, http.StatusUnauthorized, fetchErr.errorCode)
+	assert.Contains(t, fetchErr.Error(), "Bitbucket Server repo "+repo.Url)
+	assert.NotContains(t, fetchErr.Error(), "GitHub")
+}
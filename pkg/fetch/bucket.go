@@ -0,0 +1,359 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The URL schemes of the buckets release assets can be mirrored to
+const (
+	bucketSchemeS3  = "s3"
+	bucketSchemeGcs = "gs"
+)
+
+// How long the presigned URLs that S3 mirrors are read with are valid for
+const s3MirrorUrlExpiry = 15 * time.Minute
+
+// An S3 or GCS bucket that releases are mirrored to, for availability. A mirror is laid out as <prefix>/<tag>/<asset>,
+// so the "tags" of a mirror are the prefixes directly under its prefix, and the "release assets" of a tag are the
+// objects directly under that, e.g. s3://mirror/terraform/v1.5.7/terraform_1.5.7_linux_amd64.zip.
+type BucketMirror struct {
+	Url    string // e.g. s3://mirror/terraform or gs://mirror/terraform
+	Scheme string // bucketSchemeS3 or bucketSchemeGcs
+	Bucket string
+	Prefix string // The prefix tags are listed under, without a leading or trailing slash. May be empty.
+	Region string // The AWS region of an S3 bucket
+
+	// The scheme and host to send requests to. If empty, the standard endpoint of S3 or GCS is used. This is only
+	// overridden in tests.
+	endpoint string
+
+	// The credentials requests are signed or authorized with, looked up on the first request
+	awsCredentials *AwsCredentials
+	googleToken    string
+}
+
+// An object in a BucketMirror
+type bucketObject struct {
+	Key       string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// Return true if the given repo URL is the s3:// or gs:// URL of a BucketMirror
+func IsBucketMirrorUrl(repoUrl string) bool {
+	return strings.HasPrefix(repoUrl, bucketSchemeS3+"://") || strings.HasPrefix(repoUrl, bucketSchemeGcs+"://")
+}
+
+// Parse the s3://bucket/prefix or gs://bucket/prefix URL of a mirror. The region of an S3 bucket is read from the
+// AWS_REGION or AWS_DEFAULT_REGION env vars.
+func ParseBucketMirror(repoUrl string) (*BucketMirror, error) {
+	scheme, location, _ := strings.Cut(repoUrl, "://")
+	bucket, prefix, _ := strings.Cut(location, "/")
+	if !IsBucketMirrorUrl(repoUrl) || bucket == "" {
+		return nil, fmt.Errorf("The mirror %s must be a URL of the form s3://bucket/prefix or gs://bucket/prefix.", repoUrl)
+	}
+	mirror := &BucketMirror{Url: strings.TrimSuffix(repoUrl, "/"), Scheme: scheme, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+	if scheme == bucketSchemeS3 {
+		mirror.Region = getAwsRegion("")
+	}
+	return mirror, nil
+}
+
+// Return the name the mirror's releases go by, which is the last part of its prefix, or its bucket if it has none
+func (mirror *BucketMirror) name() string {
+	if mirror.Prefix == "" {
+		return mirror.Bucket
+	}
+	return path.Base(mirror.Prefix)
+}
+
+// Return the key of the object with the given name in the release with the given tag
+func (mirror *BucketMirror) objectKey(tag string, name string) string {
+	return path.Join(mirror.Prefix, tag, name)
+}
+
+// Return the s3:// or gs:// URL of the object with the given key
+func (mirror *BucketMirror) objectUrl(key string) string {
+	return fmt.Sprintf("%s://%s/%s", mirror.Scheme, mirror.Bucket, key)
+}
+
+func (mirror *BucketMirror) description() string {
+	return "mirror " + mirror.Url
+}
+
+func (mirror *BucketMirror) assetUrl(tag string, name string) string {
+	return mirror.objectUrl(mirror.objectKey(tag, name))
+}
+
+// Return the tags of the mirror that are valid versions
+func (mirror *BucketMirror) listTags(ctx context.Context, looseSemver bool) ([]string, error) {
+	prefix := ""
+	if mirror.Prefix != "" {
+		prefix = mirror.Prefix + "/"
+	}
+	prefixes, _, err := mirror.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, tagPrefix := range prefixes {
+		tag := strings.TrimSuffix(strings.TrimPrefix(tagPrefix, prefix), "/")
+		if _, err := parseTagVersion(tag, looseSemver); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// Return the objects of the release with the given tag as a release, so that its assets can be matched as those of a
+// GitHub release are
+func (mirror *BucketMirror) release(ctx context.Context, tag string) (GitHubReleaseApiResponse, error) {
+	release := GitHubReleaseApiResponse{Name: tag}
+	_, objects, err := mirror.list(ctx, mirror.objectKey(tag, "")+"/")
+	if err != nil {
+		return release, err
+	}
+	if len(objects) == 0 {
+		return release, fmt.Errorf("The mirror %s has no release %s: there are no objects under %s.", mirror.Url, tag, mirror.objectUrl(mirror.objectKey(tag, "")+"/"))
+	}
+	for _, object := range objects {
+		// Tools that create "folders" in a bucket do so with an empty object named after the folder
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		release.Assets = append(release.Assets, GitHubReleaseAsset{Url: object.Key, Name: path.Base(object.Key), Size: object.Size, UpdatedAt: object.UpdatedAt})
+	}
+	return release, nil
+}
+
+// List the prefixes and objects directly under the given prefix, following every page of results
+func (mirror *BucketMirror) list(ctx context.Context, prefix string) ([]string, []bucketObject, error) {
+	if mirror.Scheme == bucketSchemeGcs {
+		return mirror.listGcs(ctx, prefix)
+	}
+	return mirror.listS3(ctx, prefix)
+}
+
+// A page of the response to an S3 ListObjectsV2 request. For more info, see:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html
+type s3ListPage struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+func (mirror *BucketMirror) listS3(ctx context.Context, prefix string) ([]string, []bucketObject, error) {
+	var prefixes []string
+	var objects []bucketObject
+	continuationToken := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix, "delimiter": "/"}
+		if continuationToken != "" {
+			query["continuation-token"] = continuationToken
+		}
+		request, err := mirror.newRequest(ctx, "", query)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, fetchErr := mirror.send(request)
+		if fetchErr != nil {
+			return nil, nil, fetchErr
+		}
+		var page s3ListPage
+		err = xml.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Could not parse the objects in %s: %s", mirror.objectUrl(prefix), err)
+		}
+
+		for _, commonPrefix := range page.CommonPrefixes {
+			prefixes = append(prefixes, commonPrefix.Prefix)
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, bucketObject{Key: object.Key, Size: object.Size, UpdatedAt: object.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return prefixes, objects, nil
+		}
+		continuationToken = page.NextContinuationToken
+	}
+}
+
+// A page of the response to a GCS objects list request. For more info, see:
+// https://cloud.google.com/storage/docs/json_api/v1/objects/list
+type gcsListPage struct {
+	NextPageToken string   `json:"nextPageToken"`
+	Prefixes      []string `json:"prefixes"`
+	Items         []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"` // GCS sends sizes as strings, as they may not fit in a JSON number
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+}
+
+func (mirror *BucketMirror) listGcs(ctx context.Context, prefix string) ([]string, []bucketObject, error) {
+	var prefixes []string
+	var objects []bucketObject
+	pageToken := ""
+	for {
+		query := map[string]string{"prefix": prefix, "delimiter": "/"}
+		if pageToken != "" {
+			query["pageToken"] = pageToken
+		}
+		request, err := mirror.newRequest(ctx, "", query)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, fetchErr := mirror.send(request)
+		if fetchErr != nil {
+			return nil, nil, fetchErr
+		}
+		var page gcsListPage
+		err = json.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Could not parse the objects in %s: %s", mirror.objectUrl(prefix), err)
+		}
+
+		prefixes = append(prefixes, page.Prefixes...)
+		for _, item := range page.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			objects = append(objects, bucketObject{Key: item.Name, Size: size, UpdatedAt: item.Updated})
+		}
+		if page.NextPageToken == "" {
+			return prefixes, objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Return a GET request for the object with the given key, or to list the bucket if key is empty, with the given query
+// parameters, signed with or authorized by the mirror's credentials
+func (mirror *BucketMirror) newRequest(ctx context.Context, key string, query map[string]string) (*http.Request, error) {
+	if mirror.Scheme == bucketSchemeGcs {
+		if mirror.googleToken == "" {
+			token, err := getGoogleAccessToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			mirror.googleToken = token
+		}
+		endpoint := mirror.endpoint
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		requestUrl := fmt.Sprintf("%s/storage/v1/b/%s/o", endpoint, url.PathEscape(mirror.Bucket))
+		if key != "" {
+			requestUrl += "/" + url.PathEscape(key)
+		}
+		values := url.Values{}
+		for name, value := range query {
+			values.Set(name, value)
+		}
+		request, err := http.NewRequestWithContext(ctx, "GET", requestUrl+"?"+values.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+mirror.googleToken)
+		return request, nil
+	}
+
+	if mirror.awsCredentials == nil {
+		creds, err := getAwsCredentials(ctx, "Reading the S3 mirror "+mirror.Url)
+		if err != nil {
+			return nil, err
+		}
+		mirror.awsCredentials = &creds
+	}
+	signer := S3Publisher{Location: S3Location{Bucket: mirror.Bucket}, Region: mirror.Region, Credentials: *mirror.awsCredentials, UrlExpiry: s3MirrorUrlExpiry, endpoint: mirror.endpoint}
+	presignedUrl, err := signer.presignWithQuery("GET", key, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, "GET", presignedUrl, nil)
+}
+
+// Send the given request, and return the body of its response if it succeeded
+func (mirror *BucketMirror) send(request *http.Request) (io.ReadCloser, *FetchError) {
+	resp, err := newHttpClient(request.Context()).Do(request)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, newError(failedToDownloadFile, fmt.Sprintf("Request to the mirror %s failed with HTTP Response %d: %s", mirror.Url, resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return resp.Body, nil
+}
+
+// Download the object with the given key to destPath. With noCache set, caches along the way are asked not to serve a
+// stored copy, as downloadUrl does.
+func (mirror *BucketMirror) download(ctx context.Context, key string, destPath string, withProgress bool, noCache bool) *FetchError {
+	query := map[string]string{}
+	if mirror.Scheme == bucketSchemeGcs {
+		query["alt"] = "media"
+	}
+	request, err := mirror.newRequest(ctx, key, query)
+	if err != nil {
+		return wrapError(err)
+	}
+	if noCache {
+		request.Header.Set("Cache-Control", "no-cache")
+	}
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return newError(failedToDownloadFile, fmt.Sprintf("Failed to download %s. Received HTTP Response %d.", mirror.objectUrl(key), resp.StatusCode))
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+func (mirror *BucketMirror) downloadAsset(ctx context.Context, tag string, name string, destPath string, withProgress bool, noCache bool) *FetchError {
+	return mirror.download(ctx, mirror.objectKey(tag, name), destPath, withProgress, noCache)
+}
+
+// Return an error if options ask for anything a mirror doesn't have. A mirror only has the release assets of each tag,
+// so source paths and files, commits and branches, and the checks that need a GitHub repo can't be used with one.
+func validateBucketMirrorOptions(options Options) error {
+	switch {
+	case len(options.SourcePaths) > 0 || len(options.SourceFiles) > 0:
+		return fmt.Errorf("A mirror only has release assets, so source paths and files can't be downloaded from %s.", options.RepoUrl)
+	case options.CommitSha != "" || options.BranchName != "" || options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("A mirror only has tags, so --commit, --branch, --resolve-ref, and --expected-commit-sha can't be used with %s.", options.RepoUrl)
+	case options.releaseAssetRegex() == "" && !options.AutoAsset && !options.AllReleaseAssets:
+		return fmt.Errorf("A mirror only has release assets, so one must be selected with --release-asset, --auto-asset, or --all-release-assets to download from %s.", options.RepoUrl)
+	case options.VerifyWithRepoKey || options.CosignVerify || options.CheckImmutableTag || options.RequireImmutableTag || options.RequireSignedRef:
+		return fmt.Errorf("Only checksums and package signatures can be verified for release assets downloaded from a mirror, as the other checks need a GitHub repo.")
+	case options.DownloadConnections > 1:
+		return fmt.Errorf("Release assets can't be downloaded from a mirror over several connections.")
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for release assets downloaded from a mirror.")
+	case options.LocalDownloadPath == StdoutDownloadPath:
+		return fmt.Errorf("Release assets downloaded from a mirror can't be streamed to stdout. Use --stdout to print one once it's verified.")
+	case options.AssetSink != nil:
+		return fmt.Errorf("Release assets downloaded from a mirror can't be streamed to an AssetSink.")
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBucketMirror(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		repoUrl        string
+		expectedScheme string
+		expectedBucket string
+		expectedPrefix string
+		expectedName   string
+	}{
+		{"s3://mirror/releases/terraform", "s3", "mirror", "releases/terraform", "terraform"},
+		{"gs://mirror/terraform/", "gs", "mirror", "terraform", "terraform"},
+		{"s3://terraform-mirror", "s3", "terraform-mirror", "", "terraform-mirror"},
+		{"s3://", "", "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			t.Parallel()
+			mirror, err := ParseBucketMirror(tc.repoUrl)
+			if tc.expectedBucket == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedScheme, mirror.Scheme)
+			assert.Equal(t, tc.expectedBucket, mirror.Bucket)
+			assert.Equal(t, tc.expectedPrefix, mirror.Prefix)
+			assert.Equal(t, tc.expectedName, mirror.name())
+		})
+	}
+}
+
+func TestValidateBucketMirrorOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"release-asset", Options{TagConstraint: "~>1.0", ReleaseAsset: "tool"}, true},
+		{"auto-asset", Options{TagConstraint: "~>1.0", AutoAsset: true}, true},
+		{"no-asset", Options{TagConstraint: "~>1.0"}, false},
+		{"source-path", Options{TagConstraint: "~>1.0", ReleaseAsset: "tool", SourcePaths: []string{"/"}}, false},
+		{"branch", Options{BranchName: "main", ReleaseAsset: "tool"}, false},
+		{"cosign", Options{TagConstraint: "~>1.0", ReleaseAsset: "tool", CosignVerify: true}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.options.RepoUrl = "s3://mirror/tool"
+			err := validateBucketMirrorOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+// The objects in the fake mirrors below, keyed by name, under the prefix tool
+var fakeMirrorObjects = map[string]string{
+	"tool/v1.4.0/tool_linux_amd64":      "old tool",
+	"tool/v1.5.7/tool_linux_amd64":      "new tool",
+	"tool/v1.5.7/tool_darwin_arm64":     "new tool for mac",
+	"tool/v1.5.7/SHA256SUMS":            sha256Hex([]byte("new tool")) + "  tool_linux_amd64\n",
+	"tool/v2.0.0-beta/tool_linux_amd64": "beta tool",
+	"tool/latest/tool_linux_amd64":      "not a version",
+}
+
+// Return the prefixes and objects directly under prefix in fakeMirrorObjects, sorted by name
+func listFakeMirror(prefix string) ([]string, []string) {
+	var prefixes []string
+	var objects []string
+	seen := map[string]bool{}
+	for key := range fakeMirrorObjects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if rest := strings.TrimPrefix(key, prefix); strings.Contains(rest, "/") {
+			commonPrefix := prefix + rest[:strings.Index(rest, "/")+1]
+			if !seen[commonPrefix] {
+				seen[commonPrefix] = true
+				prefixes = append(prefixes, commonPrefix)
+			}
+		} else {
+			objects = append(objects, key)
+		}
+	}
+	sort.Strings(prefixes)
+	sort.Strings(objects)
+	return prefixes, objects
+}
+
+// A fake S3 bucket serving fakeMirrorObjects, which lists one prefix per page to exercise pagination
+func newFakeS3Mirror(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "AWS4-HMAC-SHA256", query.Get("X-Amz-Algorithm"))
+		assert.True(t, strings.HasPrefix(query.Get("X-Amz-Credential"), "AKIDEXAMPLE/"))
+		assert.NotEmpty(t, query.Get("X-Amz-Signature"))
+
+		if r.URL.Path != "/" {
+			contents, ok := fakeMirrorObjects[strings.TrimPrefix(r.URL.Path, "/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(contents))
+			return
+		}
+
+		assert.Equal(t, "2", query.Get("list-type"))
+		assert.Equal(t, "/", query.Get("delimiter"))
+		prefixes, objects := listFakeMirror(query.Get("prefix"))
+		var page strings.Builder
+		page.WriteString("<ListBucketResult>")
+		// Serve a page per prefix, with the objects on the last one
+		start := 0
+		fmt.Sscan(query.Get("continuation-token"), &start)
+		if start < len(prefixes) {
+			fmt.Fprintf(&page, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", prefixes[start])
+		}
+		if start+1 < len(prefixes) {
+			fmt.Fprintf(&page, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", start+1)
+		} else {
+			for _, key := range objects {
+				fmt.Fprintf(&page, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(fakeMirrorObjects[key]))
+			}
+		}
+		page.WriteString("</ListBucketResult>")
+		w.Write([]byte(page.String()))
+	}))
+}
+
+// A fake GCS bucket serving fakeMirrorObjects
+func newFakeGcsMirror(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gcs-token", r.Header.Get("Authorization"))
+
+		if key := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/mirror/o/"); key != r.URL.Path {
+			assert.Equal(t, "media", r.URL.Query().Get("alt"))
+			contents, ok := fakeMirrorObjects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(contents))
+			return
+		}
+
+		require.Equal(t, "/storage/v1/b/mirror/o", r.URL.Path)
+		prefixes, objects := listFakeMirror(r.URL.Query().Get("prefix"))
+		page := gcsListPage{Prefixes: prefixes}
+		for _, key := range objects {
+			page.Items = append(page.Items, struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"`
+				Updated time.Time `json:"updated"`
+			}{Name: key, Size: fmt.Sprint(len(fakeMirrorObjects[key]))})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestFetchFromBucketMirror(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "gcs-token")
+
+	s3Server := newFakeS3Mirror(t)
+	defer s3Server.Close()
+	gcsServer := newFakeGcsMirror(t)
+	defer gcsServer.Close()
+
+	testCases := []struct {
+		repoUrl      string
+		endpoint     string
+		checksumFile string
+		tag          string
+		valid        bool
+	}{
+		{"s3://mirror/tool", s3Server.URL, "SHA256SUMS", "~>1.4", true},
+		{"gs://mirror/tool", gcsServer.URL, "SHA256SUMS", "~>1.4", true},
+		{"s3://mirror/tool", s3Server.URL, "", "v1.4.0", true},
+		{"s3://mirror/tool", s3Server.URL, "MISSING", "~>1.4", false},
+		{"gs://mirror/tool", gcsServer.URL, "", "v3.0.0", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s@%s", tc.repoUrl, tc.tag), func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:                  tc.repoUrl,
+				TagConstraint:            tc.tag,
+				ReleaseAsset:             "tool_linux_amd64",
+				ReleaseAssetChecksumFile: tc.checksumFile,
+				LocalDownloadPath:        destPath,
+			})
+			require.NoError(t, err)
+			fetcher.mirror.endpoint = tc.endpoint
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			expectedTag := "v1.5.7"
+			if tc.tag == "v1.4.0" {
+				expectedTag = "v1.4.0"
+			}
+			assert.Equal(t, expectedTag, result.Tag)
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "tool_linux_amd64"))
+			require.NoError(t, err)
+			assert.Equal(t, fakeMirrorObjects["tool/"+expectedTag+"/tool_linux_amd64"], string(contents))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+)
+
+// The most bytes the HTTP responses to the requests made with a context may add up to, as set with the
+// MaxDownloadSize option, so that one fetch can't take more than its share of a run's bandwidth or disk
+type downloadBudget struct {
+	limit uint64
+	used  uint64
+}
+
+type downloadBudgetKey struct{}
+
+// Return a context whose HTTP requests may download at most limit bytes between them
+func withDownloadBudget(ctx context.Context, limit uint64) context.Context {
+	return context.WithValue(ctx, downloadBudgetKey{}, &downloadBudget{limit: limit})
+}
+
+// Return the download budget of the given context, or nil if it has none
+func downloadBudgetFrom(ctx context.Context) *downloadBudget {
+	budget, _ := ctx.Value(downloadBudgetKey{}).(*downloadBudget)
+	return budget
+}
+
+// Count n more bytes against the budget, and return an error if that takes it over its limit
+func (budget *downloadBudget) spend(n uint64) error {
+	if atomic.AddUint64(&budget.used, n) > budget.limit {
+		return budget.exceeded()
+	}
+	return nil
+}
+
+func (budget *downloadBudget) exceeded() error {
+	return fmt.Errorf("The downloads exceeded the size budget of %s.", humanize.IBytes(budget.limit))
+}
+
+// An http.RoundTripper that counts the bodies of responses against the download budget of their request's context, if
+// any. A response whose Content-Length alone would exceed the budget fails before any of it is read.
+type budgetTransport struct {
+	transport http.RoundTripper
+}
+
+func (transport budgetTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	roundTripper := transport.transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	resp, err := roundTripper.RoundTrip(request)
+	budget := downloadBudgetFrom(request.Context())
+	if err != nil || budget == nil {
+		return resp, err
+	}
+	if resp.ContentLength > 0 && atomic.LoadUint64(&budget.used)+uint64(resp.ContentLength) > budget.limit {
+		resp.Body.Close()
+		return nil, budget.exceeded()
+	}
+	resp.Body = &budgetReader{body: resp.Body, budget: budget}
+	return resp, nil
+}
+
+// A response body whose reads are counted against a download budget
+type budgetReader struct {
+	body   io.ReadCloser
+	budget *downloadBudget
+}
+
+func (reader *budgetReader) Read(p []byte) (int, error) {
+	n, err := reader.body.Read(p)
+	if n > 0 {
+		if budgetErr := reader.budget.spend(uint64(n)); budgetErr != nil {
+			return n, budgetErr
+		}
+	}
+	return n, err
+}
+
+func (reader *budgetReader) Close() error {
+	return reader.body.Close()
+}
@@ -0,0 +1,82 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadBudget(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/streamed" {
+			// Without a Content-Length, the budget can only be enforced as the body is read
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		path          string
+		limit         uint64
+		expectedError string
+	}{
+		{"within-budget", "/sized", 2000, ""},
+		{"content-length-over-budget", "/sized", 500, "The downloads exceeded the size budget of 500 B."},
+		{"streamed-over-budget", "/streamed", 500, "The downloads exceeded the size budget of 500 B."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := withDownloadBudget(context.Background(), tc.limit)
+			request, err := http.NewRequestWithContext(ctx, "GET", server.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			resp, err := newHttpClient(ctx).Do(request)
+			if err == nil {
+				defer resp.Body.Close()
+				_, err = ioutil.ReadAll(resp.Body)
+			}
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(Options{
+		Url:               server.URL + "/tool",
+		LocalDownloadPath: t.TempDir(),
+		Timeout:           50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	_, err = fetcher.Fetch(context.Background(), io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "The fetch did not finish within its timeout of 50ms")
+}
@@ -0,0 +1,64 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The name and contents of the file that marks a directory as a cache, following the Cache Directory Tagging
+// Specification (https://bford.info/cachedir/), so that backup tools skip it and PurgeCache knows it's safe to delete
+const cacheDirTagName = "CACHEDIR.TAG"
+const cacheDirTagContents = "Signature: 8a477f597d28d172789f06886806bc55\n" +
+	"# This file is a cache directory tag created by fetch (https://github.com/gruntwork-io/fetch).\n" +
+	"# For information about cache directory tags, see: https://bford.info/cachedir/\n"
+
+// Return the directory fetch caches repo archives and release assets in by default: fetch under the user's cache
+// directory, e.g. ~/.cache/fetch on Linux
+func DefaultCacheDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCacheDir, "fetch"), nil
+}
+
+// Mark the given cache directory as a cache, unless it already is
+func markCacheDir(dir string) error {
+	tagPath := filepath.Join(dir, cacheDirTagName)
+	if _, err := os.Stat(tagPath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tagPath, []byte(cacheDirTagContents), 0644)
+}
+
+// Delete the given cache directory and everything cached in it, and return the total size of the files deleted. To
+// guard against deleting a directory that was passed as the cache directory by mistake, only directories fetch has
+// marked as a cache are deleted. A cache directory that doesn't exist is already purged.
+func PurgeCache(dir string) (int64, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, cacheDirTagName)); err != nil {
+		return 0, fmt.Errorf("%s has no %s file, so it doesn't look like a fetch cache and won't be purged. If you're sure it's safe to, delete it yourself.", dir, cacheDirTagName)
+	}
+
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, os.RemoveAll(dir)
+}
@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeCache(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	repo := GitHubRepo{BaseUrl: "github.com", Owner: "gruntwork-io", Name: "fetch"}
+	commitSha := "8f1cbc1bbcb3f1c2ac5dbbec1ec4a7a3fd23ab87"
+
+	zipFilePath := filepath.Join(t.TempDir(), "repo.zip")
+	require.NoError(t, ioutil.WriteFile(zipFilePath, []byte("not really a zip file"), 0644))
+
+	// Caching anything marks the directory as a cache
+	_, err := archiveCache{dir: cacheDir}.put(repo, commitSha, zipFilePath)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cacheDir, cacheDirTagName))
+
+	size, err := PurgeCache(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("not really a zip file")+len(cacheDirTagContents)), size)
+	assert.NoDirExists(t, cacheDir)
+
+	// Purging a cache that doesn't exist does nothing
+	size, err = PurgeCache(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestPurgeCacheRefusesUnmarkedDirectory(t *testing.T) {
+	t.Parallel()
+
+	notACache := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(notACache, "important.txt"), []byte("keep me"), 0644))
+
+	_, err := PurgeCache(notACache)
+	assert.Error(t, err)
+	assert.FileExists(t, filepath.Join(notACache, "important.txt"))
+}
+
+func TestAssetStoreMarksPersistentCache(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	assetPath := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("tool"), 0755))
+
+	store := openAssetStore(cacheDir, LinkModeCopy)
+	require.NoError(t, store.add("https://api.github.com/repos/foo/bar/releases/assets/1", assetPath))
+	assert.FileExists(t, filepath.Join(cacheDir, cacheDirTagName))
+
+	// A temporary store isn't a cache, so isn't marked as one
+	tempStore, err := newAssetStore(LinkModeCopy)
+	require.NoError(t, err)
+	defer tempStore.close()
+	require.NoError(t, tempStore.add("https://api.github.com/repos/foo/bar/releases/assets/1", assetPath))
+	_, err = os.Stat(filepath.Join(filepath.Dir(tempStore.dir), cacheDirTagName))
+	assert.True(t, os.IsNotExist(err))
+}
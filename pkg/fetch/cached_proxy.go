@@ -0,0 +1,284 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The options of a cached proxy, which serves verified release assets from a cache shared by every client
+type CachedProxyOptions struct {
+	// The options every request is fetched with, apart from the repo, tag, release asset, and GitHub token, which come
+	// from the request. Sets the cache directory, how release assets are verified, and how to connect to GitHub.
+	Base Options
+
+	// The URL of the GitHub instance that release assets are fetched from, e.g. https://github.com
+	UpstreamUrl string
+}
+
+// An http.Handler that serves release assets at paths of the same form as GitHub's own download URLs:
+//
+//	GET /<owner>/<repo>/releases/download/<tag>/<asset>
+//
+// Each asset is served from the cache in the Base options' ArchiveCacheDir, or else downloaded into it first, and is
+// only served once it has been verified. Every request is fetched with the GitHub token in its own Authorization header
+// ("token <token>" or "Bearer <token>"), or anonymously if it has none, and never with the token of another request:
+// API responses are cached separately for each token, and an asset is only served from the cache once GitHub has
+// returned the release it's in to the request's token.
+type CachedProxy struct {
+	options CachedProxyOptions
+	logger  *logrus.Entry
+
+	// The connection every request is fetched over, made once from the Base options, so that requests share its pool of
+	// idle connections rather than each making their own
+	connection *connection
+}
+
+// The path that reports whether a cached proxy is up, for health checks
+const cachedProxyHealthPath = "/healthz"
+
+// Tags are used as-is in GitHub API paths, so the tags in requests are limited to characters that can't change which
+// API is called
+var cachedProxyTagRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// Checksums of the requested release asset can be passed in these query parameters. The asset then has to match one of
+// them as well as pass the verification set in the Base options, which the query never changes.
+const (
+	cachedProxyChecksumParam     = "checksum"
+	cachedProxyChecksumAlgoParam = "checksum-algo"
+)
+
+func NewCachedProxy(logger *logrus.Entry, options CachedProxyOptions) (*CachedProxy, error) {
+	options.UpstreamUrl = strings.TrimSuffix(options.UpstreamUrl, "/")
+	options.Base.LinkMode = LinkModeHardlink
+	conn, err := newConnection(logger, options.Base.Connection, options.Base.WaitForRateLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedProxy{options: options, logger: logger, connection: conn}, nil
+}
+
+// Return an error if the given options can't be used to serve verified release assets from a cache
+func ValidateCachedProxyOptions(options CachedProxyOptions) error {
+	if options.Base.ArchiveCacheDir == "" {
+		return fmt.Errorf("A cached proxy requires a cache directory to serve release assets from.")
+	}
+	if _, err := url.ParseRequestURI(options.UpstreamUrl); err != nil {
+		return fmt.Errorf("The upstream URL %s is not a valid URL: %s", options.UpstreamUrl, err)
+	}
+	return nil
+}
+
+// Serve the given cached proxy at the given address (e.g. 127.0.0.1:8080) until ctx is canceled
+func ServeCachedProxy(ctx context.Context, logger *logrus.Entry, address string, proxy *CachedProxy) error {
+	server := &http.Server{Addr: address, Handler: proxy, ReadHeaderTimeout: 30 * time.Second}
+
+	shutdownErrs := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		// Let the requests in flight finish, but don't wait forever for slow clients
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdownErrs <- server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Serving release assets from the cache in %s at http://%s\n", proxy.options.Base.ArchiveCacheDir, address)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErrs
+}
+
+// A request for a release asset, as parsed from the request's path and headers
+type cachedProxyRequest struct {
+	owner     string
+	repo      string
+	tag       string
+	assetName string
+	token     string
+}
+
+func (proxy *CachedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Only GET and HEAD requests are supported.", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == cachedProxyHealthPath {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+
+	request, err := parseCachedProxyRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, checksums, err := proxy.fetchOptions(request, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-cached-proxy")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+	options.LocalDownloadPath = tempDir
+
+	assetPath, err := proxy.fetch(r.Context(), options, checksums)
+	if err != nil {
+		proxy.logger.Warnf("Could not serve release asset %s of %s/%s@%s: %s\n", request.assetName, request.owner, request.repo, request.tag, err)
+		http.Error(w, err.Error(), cachedProxyErrorStatus(err))
+		return
+	}
+
+	file, err := os.Open(assetPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	proxy.logger.Infof("Serving release asset %s of %s/%s@%s\n", request.assetName, request.owner, request.repo, request.tag)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": request.assetName}))
+	http.ServeContent(w, r, request.assetName, info.ModTime(), file)
+}
+
+// Fetch and verify the release asset the given options select, check that it matches one of the given checksums from
+// the request, if there are any, and return the path it was downloaded to
+func (proxy *CachedProxy) fetch(ctx context.Context, options Options, checksums map[string]bool) (string, error) {
+	fetcher, err := newFetcherWithConnection(options, proxy.connection)
+	if err != nil {
+		return "", err
+	}
+	result, err := fetcher.Fetch(ctx, io.Discard)
+	if err != nil {
+		return "", err
+	}
+	// Assets that fail to download are logged rather than returned as errors, so they're only noticed as missing here
+	if len(result.AssetPaths) != 1 {
+		return "", fmt.Errorf("Could not download release asset %s.", strings.TrimSuffix(strings.TrimPrefix(options.ReleaseAsset, "^"), "$"))
+	}
+	if len(checksums) > 0 {
+		if fetchErr := verifyChecksumOfReleaseAsset(proxy.logger, result.AssetPaths[0], checksums, "", false, options.UpgradeWeakChecksums); fetchErr != nil {
+			return "", fetchErr
+		}
+	}
+	return result.AssetPaths[0], nil
+}
+
+// Parse the repo, tag, and release asset from the path of the given request, and the GitHub token from its
+// Authorization header
+func parseCachedProxyRequest(r *http.Request) (cachedProxyRequest, error) {
+	var request cachedProxyRequest
+
+	// Split the escaped path, so that an encoded slash can't be used to add segments
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	if len(segments) != 6 || segments[2] != "releases" || segments[3] != "download" {
+		return request, fmt.Errorf("Release assets must be requested at /<owner>/<repo>/releases/download/<tag>/<asset>.")
+	}
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return request, fmt.Errorf("Could not parse the request path: %s", err)
+		}
+		segments[i] = unescaped
+	}
+
+	request.owner, request.repo, request.tag, request.assetName = segments[0], segments[1], segments[4], segments[5]
+	if !isValidGitHubName(request.owner) || !isValidGitHubName(request.repo) {
+		return request, fmt.Errorf("%s/%s is not a valid GitHub repo.", request.owner, request.repo)
+	}
+	if !cachedProxyTagRegex.MatchString(request.tag) {
+		return request, fmt.Errorf("Unsupported tag \"%s\". Tags may only contain letters, digits, and the characters . _ + -", request.tag)
+	}
+	if request.assetName == "" || strings.ContainsAny(request.assetName, "/\\") || request.assetName == "." || request.assetName == ".." {
+		return request, fmt.Errorf("\"%s\" is not a valid release asset name.", request.assetName)
+	}
+
+	token, err := parseAuthorizationToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return request, err
+	}
+	request.token = token
+	return request, nil
+}
+
+// Return the GitHub token in the given Authorization header, which may be empty
+func parseAuthorizationToken(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	for _, scheme := range []string{"token ", "bearer "} {
+		if len(header) > len(scheme) && strings.EqualFold(header[:len(scheme)], scheme) {
+			return strings.TrimSpace(header[len(scheme):]), nil
+		}
+	}
+	return "", fmt.Errorf("The Authorization header must be of the form \"token <GitHub token>\" or \"Bearer <GitHub token>\".")
+}
+
+// Return the options to fetch the given request with, and the checksums passed in its query, each prefixed with its
+// algorithm. These are kept apart from the checksums in the Base options, so that the asset has to match both.
+func (proxy *CachedProxy) fetchOptions(request cachedProxyRequest, query url.Values) (Options, map[string]bool, error) {
+	options := proxy.options.Base
+	options.RepoUrl = fmt.Sprintf("%s/%s/%s", proxy.options.UpstreamUrl, request.owner, request.repo)
+	// The tag was requested exactly, so it's never matched as a version constraint
+	options.TagConstraint = "=" + request.tag
+	options.GitRef = ""
+	options.ReleaseAsset = "^" + regexp.QuoteMeta(request.assetName) + "$"
+	options.GithubToken = request.token
+	options.Logger = proxy.logger
+
+	// The checksum-algo parameter only applies to the checksums in the query, never to those in the Base options
+	checksums := map[string]bool{}
+	for _, value := range query[cachedProxyChecksumParam] {
+		algorithm, checksum := ParseChecksum(value, query.Get(cachedProxyChecksumAlgoParam))
+		if algorithm == "" {
+			return options, nil, fmt.Errorf("The checksum %s has no algorithm prefix (e.g. sha256:<checksum>), so the \"%s\" query parameter must be set.", value, cachedProxyChecksumAlgoParam)
+		}
+		if _, err := GetHasher(algorithm); err != nil {
+			return options, nil, err
+		}
+		checksums[fmt.Sprintf("%s:%s", algorithm, checksum)] = true
+	}
+
+	if len(checksums) == 0 && len(options.ReleaseAssetChecksums) == 0 && options.ReleaseAssetChecksumFile == "" && !options.VerifyWithRepoKey && !options.CosignVerify && options.PackageSigningKey == "" {
+		return options, nil, fmt.Errorf("Release assets are only served once verified, but nothing to verify %s against is configured. Pass its checksum in the \"%s\" query parameter.", request.assetName, cachedProxyChecksumParam)
+	}
+	return options, checksums, nil
+}
+
+// Return the HTTP status to respond with when fetching a release asset failed with the given error
+func cachedProxyErrorStatus(err error) int {
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) {
+		return http.StatusBadGateway
+	}
+	switch fetchErr.errorCode {
+	case invalidGithubTokenOrAccessDenied, accessForbidden, repoDoesNotExistOrAccessDenied, githubApiRateLimitExceeded:
+		return fetchErr.errorCode
+	case githubRepoUrlMalformedOrNotParseable:
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
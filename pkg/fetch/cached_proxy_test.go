@@ -0,0 +1,217 @@
+package fetch
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCachedProxyRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path          string
+		authorization string
+		expected      cachedProxyRequest
+		expectErr     bool
+	}{
+		{"/foo/bar/releases/download/v1.2.3/tool_linux_amd64.tar.gz", "", cachedProxyRequest{"foo", "bar", "v1.2.3", "tool_linux_amd64.tar.gz", ""}, false},
+		{"/foo/bar/releases/download/v1.2.3/tool%20v1.zip", "token abc", cachedProxyRequest{"foo", "bar", "v1.2.3", "tool v1.zip", "abc"}, false},
+		{"/foo/bar/releases/download/v1.2.3/tool.zip", "Bearer abc", cachedProxyRequest{"foo", "bar", "v1.2.3", "tool.zip", "abc"}, false},
+		{"/foo/bar/releases/download/v1.2.3/tool.zip", "Basic YWJjOmRlZg==", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/v1.2.3", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/latest/v1.2.3/tool.zip", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/v1.2.3/tool.zip/extra", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/v1%2F..%2F..%2Fissues/tool.zip", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/..%2F..%2Fissues/tool.zip", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/v1.2.3/..", "", cachedProxyRequest{}, true},
+		{"/foo/bar/releases/download/v1.2.3/a%2Fb", "", cachedProxyRequest{}, true},
+		{"/foo/../releases/download/v1.2.3/tool.zip", "", cachedProxyRequest{}, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			request, err := parseCachedProxyRequest(r)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, request)
+		})
+	}
+}
+
+func TestCachedProxyFetchesEachRequestWithItsOwnToken(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{
+		Base:        Options{ArchiveCacheDir: "/tmp/fetch-cache", GithubToken: "daemon-token", ReleaseAssetChecksumFile: "SHA256SUMS"},
+		UpstreamUrl: "https://github.com/",
+	})
+	require.NoError(t, err)
+
+	first, _, err := proxy.fetchOptions(cachedProxyRequest{"foo", "bar", "v1.2.3", "tool (1).zip", "first-token"}, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, "first-token", first.GithubToken)
+	assert.Equal(t, "https://github.com/foo/bar", first.RepoUrl)
+	assert.Equal(t, "=v1.2.3", first.TagConstraint)
+	assert.Equal(t, `^tool \(1\)\.zip$`, first.ReleaseAsset)
+	assert.Equal(t, "/tmp/fetch-cache", first.ArchiveCacheDir)
+
+	anonymous, _, err := proxy.fetchOptions(cachedProxyRequest{"foo", "bar", "v1.2.3", "tool.zip", ""}, url.Values{})
+	require.NoError(t, err)
+	assert.Empty(t, anonymous.GithubToken)
+}
+
+func TestCachedProxyRequiresVerification(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{
+		Base:        Options{ArchiveCacheDir: "/tmp/fetch-cache", ReleaseAssetChecksums: map[string]bool{"sha256:aaa": true}},
+		UpstreamUrl: "https://github.com",
+	})
+	require.NoError(t, err)
+	request := cachedProxyRequest{"foo", "bar", "v1.2.3", "tool.zip", ""}
+
+	// The checksums in the query are checked on their own, rather than as alternatives to those in the Base options
+	options, checksums, err := proxy.fetchOptions(request, url.Values{"checksum": {"sha256:BBB"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"sha256:aaa": true}, options.ReleaseAssetChecksums)
+	assert.Equal(t, map[string]bool{"sha256:bbb": true}, checksums)
+
+	_, _, err = proxy.fetchOptions(request, url.Values{"checksum": {"bbb"}})
+	assert.Error(t, err)
+
+	options, checksums, err = proxy.fetchOptions(request, url.Values{"checksum": {"bbb"}, "checksum-algo": {"md5"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"md5:bbb": true}, checksums)
+	assert.Empty(t, options.ReleaseAssetChecksumAlgo)
+
+	unverified, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{Base: Options{ArchiveCacheDir: "/tmp/fetch-cache"}, UpstreamUrl: "https://github.com"})
+	require.NoError(t, err)
+	_, _, err = unverified.fetchOptions(request, url.Values{})
+	assert.Error(t, err)
+	_, checksums, err = unverified.fetchOptions(request, url.Values{"checksum": {"sha256:bbb"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"sha256:bbb": true}, checksums)
+}
+
+func TestCachedProxyServesAssetsThatPassEveryVerification(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeGitHubRelease(t, map[string]string{"tool_linux_amd64": "linux tool"})
+	defer server.Close()
+	toolMd5 := md5.Sum([]byte("linux tool"))
+
+	testCases := []struct {
+		name           string
+		baseChecksums  map[string]bool
+		query          string
+		expectedStatus int
+	}{
+		{"base-and-query-match", map[string]bool{"sha256:" + sha256Hex([]byte("linux tool")): true}, "checksum=sha256:" + sha256Hex([]byte("linux tool")), http.StatusOK},
+		{"only-query-matches", map[string]bool{"sha256:" + sha256Hex([]byte("other tool")): true}, "checksum=sha256:" + sha256Hex([]byte("linux tool")), http.StatusBadGateway},
+		{"only-base-matches", map[string]bool{"sha256:" + sha256Hex([]byte("linux tool")): true}, "checksum=sha256:" + sha256Hex([]byte("other tool")), http.StatusBadGateway},
+		// The algorithm in the query doesn't change how the Base checksums, which have no algorithm prefix, are read
+		{"query-algorithm", map[string]bool{sha256Hex([]byte("linux tool")): true}, "checksum-algo=md5&checksum=" + hex.EncodeToString(toolMd5[:]), http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proxy, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{
+				Base: Options{
+					ArchiveCacheDir:          t.TempDir(),
+					GithubApiVersion:         "v3",
+					ReleaseAssetChecksums:    tc.baseChecksums,
+					ReleaseAssetChecksumAlgo: "sha256",
+					Connection:               ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+				},
+				UpstreamUrl: server.URL,
+			})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			proxy.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo/bar/releases/download/v1.0.0/tool_linux_amd64?"+tc.query, nil))
+			require.Equal(t, tc.expectedStatus, recorder.Code, recorder.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, "linux tool", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestCachedProxyRejectsRequestsWithoutFetching(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{Base: Options{ArchiveCacheDir: t.TempDir()}, UpstreamUrl: "https://github.com"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK},
+		{http.MethodPost, "/foo/bar/releases/download/v1.2.3/tool.zip", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/foo/bar", http.StatusBadRequest},
+		{http.MethodGet, "/foo/bar/releases/download/v1.2.3/tool.zip", http.StatusBadRequest}, // Nothing to verify it against
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		proxy.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.expected, recorder.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestCachedProxyMakesItsConnectionOnce(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := NewCachedProxy(GetProjectLogger(), CachedProxyOptions{Base: Options{ArchiveCacheDir: t.TempDir()}, UpstreamUrl: "https://github.com"})
+	require.NoError(t, err)
+	require.NotNil(t, proxy.connection)
+
+	// Every request is fetched over the proxy's connection, rather than one of its own
+	options, _, err := proxy.fetchOptions(cachedProxyRequest{"foo", "bar", "v1.2.3", "tool.zip", ""}, url.Values{"checksum": {"sha256:aaa"}})
+	require.NoError(t, err)
+	first, err := newFetcherWithConnection(options, proxy.connection)
+	require.NoError(t, err)
+	second, err := newFetcherWithConnection(options, proxy.connection)
+	require.NoError(t, err)
+	assert.Same(t, proxy.connection, first.connection)
+	assert.Same(t, proxy.connection, second.connection)
+
+	// Connection options that are invalid are an error when the proxy starts, rather than on every request
+	_, err = NewCachedProxy(GetProjectLogger(), CachedProxyOptions{
+		Base:        Options{ArchiveCacheDir: t.TempDir(), Connection: ConnectionOptions{AuthScheme: "digest"}},
+		UpstreamUrl: "https://github.com",
+	})
+	assert.Error(t, err)
+}
+
+func TestCachedProxyErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, http.StatusNotFound, cachedProxyErrorStatus(newError(repoDoesNotExistOrAccessDenied, "Not Found")))
+	assert.Equal(t, http.StatusUnauthorized, cachedProxyErrorStatus(&explainedFetchError{"bad token", newError(invalidGithubTokenOrAccessDenied, "Bad credentials")}))
+	assert.Equal(t, http.StatusBadGateway, cachedProxyErrorStatus(newError(checksumDoesNotMatch, "mismatch")))
+	assert.Equal(t, http.StatusBadGateway, cachedProxyErrorStatus(fmt.Errorf("Could not find assets")))
+}
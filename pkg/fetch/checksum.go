@@ -0,0 +1,450 @@
+package fetch
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Checksum algorithms that are no longer collision resistant, so a matching checksum doesn't rule out an asset that was
+// crafted to collide with the original
+var weakChecksumAlgorithms = map[string]bool{"md5": true, "sha1": true}
+
+// The algorithm to compute a checksum with, so that a weak checksum can be replaced by it
+const upgradedChecksumAlgorithm = "sha256"
+
+// Verify that the checksum of the release asset at assetPath matches one of the checksums in checksumMap. Each
+// checksum may be prefixed with the algorithm used to compute it (e.g. "sha512:abcd..."); checksums without a prefix are
+// assumed to use the given algorithm. If the checksum that matches uses a weak algorithm, a warning is logged, with the
+// asset's sha256 checksum if upgradeWeak is set.
+func verifyChecksumOfReleaseAsset(logger *logrus.Entry, assetPath string, checksumMap map[string]bool, algorithm string, withProgress bool, upgradeWeak bool) *FetchError {
+	computedChecksums, fetchErr := findChecksumMismatch(logger, assetPath, checksumMap, algorithm, withProgress, upgradeWeak)
+	if fetchErr != nil || computedChecksums == nil {
+		return fetchErr
+	}
+	return newChecksumMismatchError(assetPath, checksumMap, computedChecksums, "")
+}
+
+// Downloads the release asset at the given path again, bypassing any caches along the way
+type releaseAssetRedownloader func(assetPath string) *FetchError
+
+// Verify the checksum of the release asset at assetPath as verifyChecksumOfReleaseAsset does, but if it doesn't match,
+// download the asset again with redownload (unless it's nil) and check once more before failing. A download corrupted
+// in transit, e.g. by a misbehaving proxy or cache, then doesn't fail the fetch, and when the checksum still doesn't
+// match, the error says whether the two downloads were identical (a genuine mismatch) or not (corruption in transit).
+func verifyChecksumOfReleaseAssetWithRetry(logger *logrus.Entry, assetPath string, checksumMap map[string]bool, algorithm string, withProgress bool, upgradeWeak bool, redownload releaseAssetRedownloader) *FetchError {
+	firstChecksums, fetchErr := findChecksumMismatch(logger, assetPath, checksumMap, algorithm, withProgress, upgradeWeak)
+	if fetchErr != nil || firstChecksums == nil {
+		return fetchErr
+	}
+	if redownload == nil {
+		return newChecksumMismatchError(assetPath, checksumMap, firstChecksums, "")
+	}
+
+	logger.Warnf("Checksum of release asset %s did not match. Downloading it again, bypassing caches, in case the download was corrupted.\n", assetPath)
+	if fetchErr := redownload(assetPath); fetchErr != nil {
+		return newChecksumMismatchError(assetPath, checksumMap, firstChecksums, fmt.Sprintf("Downloading it again to rule out a corrupted download failed: %s", fetchErr))
+	}
+
+	secondChecksums, fetchErr := findChecksumMismatch(logger, assetPath, checksumMap, algorithm, withProgress, upgradeWeak)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	if secondChecksums == nil {
+		logger.Warnf("The first download of release asset %s was corrupted (got %s). Downloading it again fixed it.\n", assetPath, strings.Join(firstChecksums, ", "))
+		return nil
+	}
+	if !reflect.DeepEqual(firstChecksums, secondChecksums) {
+		return newError(checksumDoesNotMatch, fmt.Sprintf("The checksum of release asset %s did not match any of %s, and it changed between two downloads (from %s to %s). This means the download is most likely being corrupted in transit (e.g. by a proxy or cache), rather than that the asset doesn't match. Try again later, or from another network.", assetPath, sortedKeys(checksumMap), strings.Join(firstChecksums, ", "), strings.Join(secondChecksums, ", ")))
+	}
+	return newChecksumMismatchError(assetPath, checksumMap, secondChecksums, "A second download, bypassing caches, had the same checksum, so this is not a corrupted download.")
+}
+
+// Compute the checksums of the release asset at assetPath with the algorithms of the checksums in checksumMap, and
+// return nil if one of them matches, or otherwise the computed checksums, each prefixed with its algorithm
+func findChecksumMismatch(logger *logrus.Entry, assetPath string, checksumMap map[string]bool, algorithm string, withProgress bool, upgradeWeak bool) ([]string, *FetchError) {
+	started := time.Now()
+
+	expectedChecksums := groupChecksumsByAlgorithm(checksumMap, algorithm)
+
+	// Sort the algorithms so the computed checksums in the error message are in a predictable order
+	algorithms := make([]string, 0, len(expectedChecksums))
+	for checksumAlgorithm := range expectedChecksums {
+		algorithms = append(algorithms, checksumAlgorithm)
+	}
+	sort.Strings(algorithms)
+
+	var progress *logrus.Entry
+	if withProgress {
+		progress = logger
+	}
+
+	var computedChecksums []string
+	for _, checksumAlgorithm := range algorithms {
+		computedChecksum, err := computeChecksum(assetPath, checksumAlgorithm, progress)
+		if err != nil {
+			return nil, newError(errorWhileComputingChecksum, err.Error())
+		}
+		if expectedChecksums[checksumAlgorithm][computedChecksum] {
+			logger.Infof("Release asset %s checksum verified for %s in %s\n", checksumAlgorithm, assetPath, time.Since(started).Round(time.Millisecond))
+			if weakChecksumAlgorithms[checksumAlgorithm] {
+				var upgradedChecksum string
+				if upgradeWeak {
+					upgradedChecksum, err = computeChecksum(assetPath, upgradedChecksumAlgorithm, nil)
+					if err != nil {
+						return nil, newError(errorWhileComputingChecksum, err.Error())
+					}
+				}
+				warnOfWeakChecksum(logger, assetPath, checksumAlgorithm, upgradedChecksum)
+			}
+			return nil, nil
+		}
+		computedChecksums = append(computedChecksums, fmt.Sprintf("%s:%s", checksumAlgorithm, computedChecksum))
+	}
+	return computedChecksums, nil
+}
+
+// Warn that the release asset at assetPath was verified with a checksum computed with the given weak algorithm. The
+// warning carries the asset and algorithm as fields, so that log aggregators can find every use of a weak checksum, and
+// the asset's sha256 checksum, if upgradedChecksum isn't empty, so the checksum can be replaced with it.
+func warnOfWeakChecksum(logger *logrus.Entry, assetPath string, algorithm string, upgradedChecksum string) {
+	fields := logrus.Fields{"asset": filepath.Base(assetPath), "checksum_algorithm": algorithm}
+	if upgradedChecksum == "" {
+		logger.WithFields(fields).Warnf("Release asset %s was verified with a %s checksum, which is no longer collision resistant. Replace it with a %s checksum, which --upgrade-weak-checksums prints.\n", assetPath, algorithm, upgradedChecksumAlgorithm)
+		return
+	}
+	fields["upgraded_checksum"] = fmt.Sprintf("%s:%s", upgradedChecksumAlgorithm, upgradedChecksum)
+	logger.WithFields(fields).Warnf("Release asset %s was verified with a %s checksum, which is no longer collision resistant. Replace it with its %s checksum, %s:%s.\n", assetPath, algorithm, upgradedChecksumAlgorithm, upgradedChecksumAlgorithm, upgradedChecksum)
+}
+
+// Return the error for a release asset whose computed checksums match none of those in checksumMap, with the given
+// note, if any, appended
+func newChecksumMismatchError(assetPath string, checksumMap map[string]bool, computedChecksums []string, note string) *FetchError {
+	details := fmt.Sprintf("Expected to checksum value to be one of %s, but instead got %s for Release Asset at %s. This means that either you are using the wrong checksum value in your call to fetch, (e.g. did you update the version of the module you're installing but not the checksum?) or that someone has replaced the asset with a potentially dangerous one and you should be very careful about proceeding.", sortedKeys(checksumMap), strings.Join(computedChecksums, ", "), assetPath)
+	if note != "" {
+		details = fmt.Sprintf("%s %s", details, note)
+	}
+	return newError(checksumDoesNotMatch, details)
+}
+
+// Return the keys of the given map, sorted
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Split a checksum of the form "algorithm:checksum" into its algorithm and checksum. Checksums without an algorithm
+// prefix use defaultAlgorithm. Both parts are lowercased, as hex checksums are case insensitive.
+func ParseChecksum(value string, defaultAlgorithm string) (string, string) {
+	if algorithm, checksum, found := strings.Cut(value, ":"); found {
+		return strings.ToLower(algorithm), strings.ToLower(checksum)
+	}
+	return defaultAlgorithm, strings.ToLower(value)
+}
+
+// Group the given checksums by the algorithm used to compute them, so each algorithm only has to be computed once
+func groupChecksumsByAlgorithm(checksumMap map[string]bool, defaultAlgorithm string) map[string]map[string]bool {
+	grouped := map[string]map[string]bool{}
+	for value := range checksumMap {
+		algorithm, checksum := ParseChecksum(value, defaultAlgorithm)
+		if grouped[algorithm] == nil {
+			grouped[algorithm] = map[string]bool{}
+		}
+		grouped[algorithm][checksum] = true
+	}
+	return grouped
+}
+
+// Verify each of the given release assets against its entry in a checksums file, such as the SHA256SUMS or
+// checksums.txt published by many releases. checksumFile is either the name of an asset in the release or a URL. If
+// algorithm is empty, it's inferred from the length of the checksums in the file. Assets that don't match are
+// downloaded again with redownload, if it's not nil, as verifyChecksumOfReleaseAssetWithRetry does.
+func verifyReleaseAssetsWithChecksumFile(ctx context.Context, logger *logrus.Entry, repo GitHubRepo, tag string, checksumFile string, algorithm string, assetPaths []string, withProgress bool, upgradeWeak bool, redownload releaseAssetRedownloader) error {
+	checksums, err := loadChecksumFile(ctx, repo, tag, checksumFile)
+	if err != nil {
+		return err
+	}
+	return verifyReleaseAssetsWithChecksums(logger, checksums, checksumFile, algorithm, assetPaths, withProgress, upgradeWeak, redownload)
+}
+
+// Verify each of the given release assets against its entry in checksums, as loaded from checksumFile, as
+// verifyReleaseAssetsWithChecksumFile does
+func verifyReleaseAssetsWithChecksums(logger *logrus.Entry, checksums map[string]string, checksumFile string, algorithm string, assetPaths []string, withProgress bool, upgradeWeak bool, redownload releaseAssetRedownloader) error {
+	for _, assetPath := range assetPaths {
+		assetName := filepath.Base(assetPath)
+
+		// The regex passed to --release-asset may have matched the checksum file itself
+		if assetName == checksumFile {
+			continue
+		}
+
+		checksum, checksumAlgorithm, fetchErr := findChecksumInFile(checksums, checksumFile, assetName, algorithm)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		if fetchErr := verifyChecksumOfReleaseAssetWithRetry(logger, assetPath, map[string]bool{checksum: true}, checksumAlgorithm, withProgress, upgradeWeak, redownload); fetchErr != nil {
+			return fetchErr
+		}
+	}
+
+	return nil
+}
+
+// Download and parse the given checksum file, which is either the name of an asset in the release with the given tag
+// or a URL, and return the checksums in it keyed by file name
+func loadChecksumFile(ctx context.Context, repo GitHubRepo, tag string, checksumFile string) (map[string]string, error) {
+	tempDir, err := ioutil.TempDir("", "fetch-checksums")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	checksumFilePath := filepath.Join(tempDir, "checksums.txt")
+	if fetchErr := downloadChecksumFile(ctx, repo, tag, checksumFile, checksumFilePath); fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	contents, err := ioutil.ReadFile(checksumFilePath)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := parseChecksumFile(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse checksum file %s: %s", checksumFile, err)
+	}
+	return checksums, nil
+}
+
+// Return the checksum of the given release asset in the checksums loaded from checksumFile, along with the algorithm
+// used to compute it: the given algorithm, or if that's empty, the one inferred from the length of the checksum
+func findChecksumInFile(checksums map[string]string, checksumFile string, assetName string, algorithm string) (string, string, *FetchError) {
+	checksum, found := checksums[assetName]
+	if !found {
+		return "", "", newError(checksumDoesNotMatch, fmt.Sprintf("The checksum file %s has no entry for release asset %s.", checksumFile, assetName))
+	}
+	if algorithm == "" {
+		algorithm = getChecksumAlgorithmForLength(len(checksum))
+	}
+	return checksum, algorithm, nil
+}
+
+// Computes the checksums of everything written to it with the algorithms of a set of expected checksums, so that
+// content streamed elsewhere, rather than written to a file that can be hashed afterwards, can still be verified
+type streamHasher struct {
+	checksumMap map[string]bool
+	expected    map[string]map[string]bool
+	algorithms  []string
+	hashers     map[string]hash.Hash
+	upgradeWeak bool
+
+	// What the content was streamed to, for the error if it doesn't match. If empty, it's stdout.
+	destination string
+}
+
+// Return a streamHasher for the checksums in checksumMap, which may be prefixed with their algorithm as in
+// verifyChecksumOfReleaseAsset. If upgradeWeak is set, the sha256 checksum is computed too, so that it can be printed if
+// a weak checksum matches.
+func newStreamHasher(checksumMap map[string]bool, algorithm string, upgradeWeak bool) (*streamHasher, error) {
+	hasher := &streamHasher{
+		checksumMap: checksumMap,
+		expected:    groupChecksumsByAlgorithm(checksumMap, algorithm),
+		hashers:     map[string]hash.Hash{},
+		upgradeWeak: upgradeWeak,
+	}
+	for checksumAlgorithm := range hasher.expected {
+		algorithmHasher, err := GetHasher(checksumAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		hasher.algorithms = append(hasher.algorithms, checksumAlgorithm)
+		hasher.hashers[checksumAlgorithm] = algorithmHasher
+	}
+	sort.Strings(hasher.algorithms)
+	if upgradeWeak && hasher.hashers[upgradedChecksumAlgorithm] == nil {
+		hasher.hashers[upgradedChecksumAlgorithm] = sha256.New()
+	}
+	return hasher, nil
+}
+
+func (hasher *streamHasher) Write(p []byte) (int, error) {
+	for _, algorithmHasher := range hasher.hashers {
+		algorithmHasher.Write(p)
+	}
+	return len(p), nil
+}
+
+// Verify that the checksum of everything written so far matches one of the expected checksums. By the time this can
+// be checked, the content has already been streamed, so the error tells whoever reads the stream to discard it.
+func (hasher *streamHasher) verify(logger *logrus.Entry, assetName string) *FetchError {
+	var computedChecksums []string
+	for _, algorithm := range hasher.algorithms {
+		computedChecksum := hasherToString(hasher.hashers[algorithm])
+		if hasher.expected[algorithm][computedChecksum] {
+			logger.Infof("Release asset %s checksum verified for %s\n", algorithm, assetName)
+			if weakChecksumAlgorithms[algorithm] {
+				var upgradedChecksum string
+				if hasher.upgradeWeak {
+					upgradedChecksum = hasherToString(hasher.hashers[upgradedChecksumAlgorithm])
+				}
+				warnOfWeakChecksum(logger, assetName, algorithm, upgradedChecksum)
+			}
+			return nil
+		}
+		computedChecksums = append(computedChecksums, fmt.Sprintf("%s:%s", algorithm, computedChecksum))
+	}
+	destination := hasher.destination
+	if destination == "" {
+		destination = "stdout"
+	}
+	return newChecksumMismatchError(assetName, hasher.checksumMap, computedChecksums, fmt.Sprintf("The release asset was already streamed to %s, so discard that output.", destination))
+}
+
+// Download the checksum file to destPath. The checksum file is either a URL or the name of an asset in the release
+// with the given tag.
+func downloadChecksumFile(ctx context.Context, repo GitHubRepo, tag string, checksumFile string, destPath string) *FetchError {
+	if strings.HasPrefix(checksumFile, "https://") || strings.HasPrefix(checksumFile, "http://") {
+		request, err := http.NewRequestWithContext(ctx, "GET", checksumFile, nil)
+		if err != nil {
+			return wrapError(err)
+		}
+		resp, err := newHttpClient(ctx).Do(request)
+		if err != nil {
+			return wrapError(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return newError(failedToDownloadFile, fmt.Sprintf("Failed to download checksum file at the url %s. Received HTTP Response %d.", checksumFile, resp.StatusCode))
+		}
+		return writeResonseToDisk(resp, destPath, false)
+	}
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, repo, tag)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == checksumFile {
+			return downloadReleaseAsset(ctx, repo, &asset, destPath, false)
+		}
+	}
+	return newError(failedToDownloadFile, fmt.Sprintf("Could not find checksum file %s in release %s", checksumFile, tag))
+}
+
+// Parse a checksum file in the format written by sha256sum and similar tools, where each line is a checksum followed
+// by the file name (prefixed with "*" for files checksummed in binary mode). Returns a map of file name to checksum.
+func parseChecksumFile(contents string) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d is not of the form \"<checksum>  <file name>\"", i+1)
+		}
+
+		fileName := strings.TrimPrefix(fields[1], "*")
+		checksums[filepath.Base(fileName)] = strings.ToLower(fields[0])
+	}
+
+	return checksums, nil
+}
+
+// Guess the algorithm used to compute a hex-encoded checksum from its length. sha3-256 and blake2b-256 checksums are
+// the same length as sha256 ones, so those require --release-asset-checksum-algo to be set explicitly.
+func getChecksumAlgorithmForLength(length int) string {
+	switch length {
+	case 32:
+		return "md5"
+	case 40:
+		return "sha1"
+	case 128:
+		return "sha512"
+	default:
+		return "sha256"
+	}
+}
+
+// Compute the checksum of the file at the given path. If progress isn't nil, the progress of hashing the file is
+// reported to its output, as hashing a multi-GB file can take a while.
+func computeChecksum(filePath string, algorithm string, progress *logrus.Entry) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher, err := GetHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var reader io.Reader = file
+	if progress != nil {
+		info, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		counter := newWriteCounter(progress, "Verifying checksum", info.Size())
+		reader = io.TeeReader(file, counter)
+		defer counter.finish()
+	}
+
+	_, err = io.Copy(hasher, reader)
+	if err != nil {
+		return "", err
+	}
+
+	return hasherToString(hasher), nil
+}
+
+// Return a hasher instance, the common interface used by all Golang hashing functions
+func GetHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "sha3-256":
+		return sha3.New256(), nil
+	case "blake2b-256":
+		// This only errors if a key longer than 64 bytes is passed in
+		return blake2b.New256(nil)
+	default:
+		return nil, fmt.Errorf("The checksum algorithm \"%s\" is not supported", algorithm)
+	}
+}
+
+// Convert a hasher instance to the string value of that hasher
+func hasherToString(hasher hash.Hash) string {
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -0,0 +1,424 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL = "https://github.com/gruntwork-io/health-checker"
+const SAMPLE_RELEASE_ASSET_VERSION = "v0.0.2"
+const SAMPLE_RELEASE_ASSET_NAME = "health-checker_linux_amd64"
+
+// Checksums can be computed by running "shasum -a [256|512] /path/to/file" on any UNIX system
+const SAMPLE_RELEASE_ASSET_CHECKSUM_SHA256 = "4314590d802760c29a532e2ef22689d4656d184b3daa63f96bc8b8f76f5d22f0"
+const SAMPLE_RELEASE_ASSET_CHECKSUM_SHA512 = "28d9e487c1001e3c28d915c9edd3ed37632f10b923bd94d4d9ac6d28c0af659abbe2456da167763d51def2182fef01c3f73c67edf527d4ed1389a28ba10db332"
+
+var SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256 = map[string]bool{
+	"4314590d802760c29a532e2ef22689d4656d184b3daa63f96bc8b8f76f5d22f0": true,
+	"de31de3055a2374d3bb38d847323e39821265ae611d990cffaa6f80a11ad2609": true,
+}
+
+var SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256_NO_MATCH = map[string]bool{
+	"XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX": true,
+	"YYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYY": true,
+}
+
+func TestVerifyReleaseAsset(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	assetPaths, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: SAMPLE_RELEASE_ASSET_NAME}, newDestRoot(tmpDir, false), githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false, 0, 1, nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to download release asset: %s", fetchErr)
+	}
+
+	if len(assetPaths) != 1 {
+		t.Fatalf("Incorrect number of release assets: %d", len(assetPaths))
+	}
+
+	checksumSha256, fetchErr := computeChecksum(assetPaths[0], "sha256", nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to compute file checksum: %s", fetchErr)
+	}
+
+	checksumSha512, fetchErr := computeChecksum(assetPaths[0], "sha512", nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to compute file checksum: %s", fetchErr)
+	}
+
+	assert.Equal(t, SAMPLE_RELEASE_ASSET_CHECKSUM_SHA256, checksumSha256, "SHA256 checksum of sample asset failed to match.")
+	assert.Equal(t, SAMPLE_RELEASE_ASSET_CHECKSUM_SHA512, checksumSha512, "SHA512 checksum of sample asset failed to match.")
+}
+
+func TestVerifyChecksumOfReleaseAsset(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	assetPaths, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: SAMPLE_RELEASE_ASSET_REGEX}, newDestRoot(tmpDir, false), githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false, 0, 1, nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to download release asset: %s", fetchErr)
+	}
+
+	if len(assetPaths) != 2 {
+		t.Fatalf("Incorrect number of release assets: %d", len(assetPaths))
+	}
+
+	for _, assetPath := range assetPaths {
+		checksumErr := verifyChecksumOfReleaseAsset(logger, assetPath, SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256, "sha256", false, false)
+		if checksumErr != nil {
+			t.Fatalf("Expected downloaded asset to match one of %d checksums: %s", len(SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256), checksumErr)
+		}
+	}
+
+	for _, assetPath := range assetPaths {
+		checksumErr := verifyChecksumOfReleaseAsset(logger, assetPath, SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256_NO_MATCH, "sha256", false, false)
+		if checksumErr == nil {
+			t.Fatalf("Expected downloaded asset to not match any checksums")
+		}
+	}
+}
+
+func TestComputeChecksumWithProgress(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := mkTempDir(t)
+	filePath := filepath.Join(tmpDir, "hello.txt")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte("hello"), 0644))
+
+	// Progress is reported to the output of the logger it's given
+	var progress bytes.Buffer
+	for _, logger := range []*logrus.Entry{nil, GetProjectLoggerWithWriter(&progress)} {
+		checksum, err := computeChecksum(filePath, "sha256", logger)
+		require.NoError(t, err)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", checksum)
+	}
+	assert.Contains(t, progress.String(), "Verifying checksum")
+}
+
+func TestComputeChecksumAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	filePath := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte("hello"), 0644))
+
+	testCases := []struct {
+		algorithm string
+		expected  string
+	}{
+		{"sha1", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{"md5", "5d41402abc4b2a76b9719d911017c592"},
+		{"sha3-256", "3338be694f50c5f338814986cdf0686453a888b84f424d792af4b9202398f392"},
+		{"blake2b-256", "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.algorithm, func(t *testing.T) {
+			t.Parallel()
+
+			checksum, err := computeChecksum(filePath, tc.algorithm, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, checksum)
+		})
+	}
+}
+
+func TestVerifyChecksumWithAlgorithmPrefixes(t *testing.T) {
+	t.Parallel()
+
+	logger := GetProjectLogger()
+	filePath := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte("hello"), 0644))
+
+	testCases := []struct {
+		name      string
+		checksums map[string]bool
+		algorithm string
+		expectErr bool
+	}{
+		{"prefixed-only", map[string]bool{"sha1:AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D": true}, "", false},
+		{"mixed-match-default", map[string]bool{"md5:XXXX": true, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824": true}, "sha256", false},
+		{"mixed-match-prefix", map[string]bool{"md5:5d41402abc4b2a76b9719d911017c592": true, "XXXX": true}, "sha512", false},
+		{"no-match", map[string]bool{"md5:XXXX": true, "sha3-256:YYYY": true}, "sha256", true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := verifyChecksumOfReleaseAsset(logger, filePath, tc.checksums, tc.algorithm, false, false)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumWithRetry(t *testing.T) {
+	t.Parallel()
+
+	logger := GetProjectLogger()
+	// The sha256 checksum of "hello"
+	checksums := map[string]bool{"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824": true}
+
+	testCases := []struct {
+		name             string
+		redownloadedWith string
+		redownloadErr    *FetchError
+		expectErr        string
+	}{
+		{"corrupted-download", "hello", nil, ""},
+		{"genuine-mismatch", "corrupt", nil, "had the same checksum"},
+		{"changed-between-downloads", "corrupted differently", nil, "changed between two downloads"},
+		{"redownload-failed", "", newError(failedToDownloadFile, "no network"), "no network"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filePath := filepath.Join(t.TempDir(), "hello.txt")
+			require.NoError(t, ioutil.WriteFile(filePath, []byte("corrupt"), 0644))
+
+			redownloads := 0
+			redownload := func(assetPath string) *FetchError {
+				redownloads++
+				if tc.redownloadErr != nil {
+					return tc.redownloadErr
+				}
+				require.NoError(t, ioutil.WriteFile(assetPath, []byte(tc.redownloadedWith), 0644))
+				return nil
+			}
+
+			err := verifyChecksumOfReleaseAssetWithRetry(logger, filePath, checksums, "sha256", false, false, redownload)
+			assert.Equal(t, 1, redownloads)
+			if tc.expectErr == "" {
+				assert.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				assert.Contains(t, err.Error(), tc.expectErr)
+			}
+		})
+	}
+}
+
+func TestVerifyLocalReleaseAssetsNeverRedownloads(t *testing.T) {
+	t.Parallel()
+
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:                  SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL,
+		ReleaseAssetChecksums:    SAMPLE_RELEASE_ASSET_CHECKSUMS_SHA256_NO_MATCH,
+		ReleaseAssetChecksumAlgo: "sha256",
+	})
+	require.NoError(t, err)
+
+	assetPath := filepath.Join(t.TempDir(), SAMPLE_RELEASE_ASSET_NAME)
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("corrupt"), 0644))
+
+	err = fetcher.VerifyLocalReleaseAssets(context.Background(), SAMPLE_RELEASE_ASSET_VERSION, []string{assetPath})
+	require.Error(t, err)
+
+	// The local copy must not have been replaced with a fresh download
+	contents, readErr := ioutil.ReadFile(assetPath)
+	require.NoError(t, readErr)
+	assert.Equal(t, "corrupt", string(contents))
+}
+
+func TestStreamHasher(t *testing.T) {
+	t.Parallel()
+
+	logger := GetProjectLogger()
+
+	testCases := []struct {
+		name      string
+		checksums map[string]bool
+		algorithm string
+		expectErr bool
+	}{
+		{"default-algorithm", map[string]bool{"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824": true}, "sha256", false},
+		{"prefixed", map[string]bool{"md5:XXXX": true, "sha1:aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d": true}, "", false},
+		{"no-match", map[string]bool{"md5:XXXX": true, "YYYY": true}, "sha256", true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			hasher, err := newStreamHasher(tc.checksums, tc.algorithm, false)
+			require.NoError(t, err)
+
+			// Write in pieces, as a stream would be
+			_, err = hasher.Write([]byte("hel"))
+			require.NoError(t, err)
+			_, err = hasher.Write([]byte("lo"))
+			require.NoError(t, err)
+
+			fetchErr := hasher.verify(logger, "hello.txt")
+			if tc.expectErr {
+				require.NotNil(t, fetchErr)
+				assert.Contains(t, fetchErr.Error(), "discard that output")
+			} else {
+				assert.Nil(t, fetchErr)
+			}
+		})
+	}
+
+	_, err := newStreamHasher(map[string]bool{"crc32:XXXX": true}, "", false)
+	assert.Error(t, err)
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	t.Parallel()
+
+	contents := `# Checksums for v0.0.1
+2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824  hello.txt
+486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7 *dist/world.txt
+
+`
+	checksums, err := parseChecksumFile(contents)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"hello.txt": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		"world.txt": "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7",
+	}, checksums)
+
+	_, err = parseChecksumFile("not a checksum file line")
+	assert.Error(t, err)
+}
+
+func TestVerifyReleaseAssetsWithChecksumFileUrl(t *testing.T) {
+	t.Parallel()
+
+	logger := GetProjectLogger()
+	tmpDir := t.TempDir()
+	helloPath := filepath.Join(tmpDir, "hello.txt")
+	worldPath := filepath.Join(tmpDir, "world.txt")
+	require.NoError(t, ioutil.WriteFile(helloPath, []byte("hello"), 0644))
+	require.NoError(t, ioutil.WriteFile(worldPath, []byte("world"), 0644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  hello.txt")
+		fmt.Fprintln(w, "7d793037a0760186574b0282f2f435e7  world.txt")
+	}))
+	defer server.Close()
+
+	// Mixed checksum lengths are each verified with the algorithm matching their length
+	assert.NoError(t, verifyReleaseAssetsWithChecksumFile(context.Background(), logger, GitHubRepo{}, "v0.0.1", server.URL, "", []string{helloPath, worldPath}, false, false, nil))
+
+	otherPath := filepath.Join(tmpDir, "other.txt")
+	require.NoError(t, ioutil.WriteFile(otherPath, []byte("other"), 0644))
+	assert.Error(t, verifyReleaseAssetsWithChecksumFile(context.Background(), logger, GitHubRepo{}, "v0.0.1", server.URL, "", []string{otherPath}, false, false, nil))
+}
+
+func mkTempDir(t *testing.T) string {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %s", err)
+	}
+
+	return tmpDir
+}
+
+func TestWeakChecksumWarning(t *testing.T) {
+	t.Parallel()
+
+	filePath := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte("hello"), 0644))
+	const helloSha256 = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	testCases := []struct {
+		name             string
+		checksums        map[string]bool
+		upgradeWeak      bool
+		expectWarning    bool
+		expectedUpgraded string
+	}{
+		{"md5", map[string]bool{"md5:5d41402abc4b2a76b9719d911017c592": true}, false, true, ""},
+		{"sha1-upgraded", map[string]bool{"sha1:aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d": true}, true, true, helloSha256},
+		{"sha256", map[string]bool{helloSha256: true}, true, false, ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, verify := range []func(logger *logrus.Entry) *FetchError{
+				func(logger *logrus.Entry) *FetchError {
+					return verifyChecksumOfReleaseAsset(logger, filePath, tc.checksums, "", false, tc.upgradeWeak)
+				},
+				func(logger *logrus.Entry) *FetchError {
+					hasher, err := newStreamHasher(tc.checksums, "", tc.upgradeWeak)
+					require.NoError(t, err)
+					_, err = hasher.Write([]byte("hello"))
+					require.NoError(t, err)
+					return hasher.verify(logger, "hello.txt")
+				},
+			} {
+				var output bytes.Buffer
+				logger := logrus.New()
+				logger.SetOutput(&output)
+				logger.SetFormatter(&logrus.JSONFormatter{})
+				require.Nil(t, verify(logrus.NewEntry(logger)))
+
+				var warning map[string]interface{}
+				for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+					var entry map[string]interface{}
+					require.NoError(t, json.Unmarshal([]byte(line), &entry))
+					if entry["level"] == "warning" {
+						warning = entry
+					}
+				}
+				if !tc.expectWarning {
+					assert.Nil(t, warning)
+					continue
+				}
+				require.NotNil(t, warning)
+				assert.Equal(t, "hello.txt", warning["asset"])
+				if tc.expectedUpgraded == "" {
+					assert.NotContains(t, warning, "upgraded_checksum")
+				} else {
+					assert.Equal(t, tc.expectedUpgraded, warning["upgraded_checksum"])
+					assert.Contains(t, warning["msg"], tc.expectedUpgraded)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Release assets smaller than this are always downloaded over a single connection, since the extra requests would
+// cost more than downloading them in parallel saves
+const minChunkedDownloadSize = 8 * 1024 * 1024
+
+// A byte range of a file, from start to end inclusive, as in an HTTP Range header
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+// Split a file of the given size into at most the given number of contiguous byte ranges of roughly equal length
+func splitIntoRanges(size int64, count int) []byteRange {
+	if count < 1 {
+		count = 1
+	}
+	if int64(count) > size {
+		count = int(size)
+	}
+
+	var ranges []byteRange
+	chunkSize := size / int64(count)
+	for i := 0; i < count; i++ {
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+		if i == count-1 {
+			end = size - 1
+		}
+		ranges = append(ranges, byteRange{start, end})
+	}
+	return ranges
+}
+
+// Download the given release asset to destPath over the given number of connections at once, each of which fetches
+// its own byte range of the asset. This speeds up downloads of very large assets when a single connection can't use
+// all the available bandwidth. Assets smaller than minChunkedDownloadSize, and assets served by a server that ignores
+// range requests, are downloaded over a single connection instead.
+func downloadReleaseAssetInChunks(ctx context.Context, repo GitHubRepo, asset *GitHubReleaseAsset, destPath string, connections int, withProgress bool) *FetchError {
+	if connections < 2 || asset.Size < minChunkedDownloadSize {
+		return downloadReleaseAsset(ctx, repo, asset, destPath, withProgress)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ranges := splitIntoRanges(asset.Size, connections)
+
+	// Request the first range on its own, so that if the server sends the whole asset instead, it can be written as is
+	firstResp, fetchErr := requestReleaseAssetRange(ctx, repo, asset.Id, ranges[0])
+	if fetchErr != nil {
+		return fetchErr
+	}
+	if firstResp.StatusCode != http.StatusPartialContent {
+		if fetchErr := verifyReleaseAssetBody(firstResp, asset); fetchErr != nil {
+			return fetchErr
+		}
+		return writeResonseToDisk(firstResp, destPath, withProgress)
+	}
+
+	out, err := createDownloadFile(destPath)
+	if err != nil {
+		firstResp.Body.Close()
+		return wrapError(err)
+	}
+	if err := out.Truncate(asset.Size); err != nil {
+		firstResp.Body.Close()
+		out.Close()
+		os.Remove(destPath)
+		return wrapError(err)
+	}
+
+	var progress io.Writer = io.Discard
+	if withProgress {
+		counter := newWriteCounter(loggerFrom(ctx), "Downloading", asset.Size)
+		defer counter.finish()
+		progress = &lockedWriter{writer: counter}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan *FetchError, len(ranges))
+	for i, chunk := range ranges {
+		// The following is necessary to make sure these values don't get updated due to concurrency
+		i, chunk := i, chunk
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp := firstResp
+			if i > 0 {
+				var fetchErr *FetchError
+				if resp, fetchErr = requestReleaseAssetRange(ctx, repo, asset.Id, chunk); fetchErr != nil {
+					errs <- fetchErr
+					cancel()
+					return
+				}
+			}
+			if fetchErr := writeRangeResponse(resp, out, chunk, progress); fetchErr != nil {
+				errs <- fetchErr
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	closeErr := out.Close()
+	// The first error is the one that canceled the other connections, so it's the one worth reporting
+	if fetchErr, ok := <-errs; ok {
+		os.Remove(destPath)
+		return fetchErr
+	}
+	if closeErr != nil {
+		os.Remove(destPath)
+		return wrapError(closeErr)
+	}
+	return nil
+}
+
+// Request the given byte range of the release asset with the given ID
+func requestReleaseAssetRange(ctx context.Context, repo GitHubRepo, assetId int, chunk byteRange) (*http.Response, *FetchError) {
+	url := createGitHubRepoUrlForPath(repo, fmt.Sprintf("releases/assets/%d", assetId))
+	return callGitHubApi(ctx, repo, url, map[string]string{
+		"Accept": "application/octet-stream",
+		"Range":  fmt.Sprintf("bytes=%d-%d", chunk.start, chunk.end),
+	})
+}
+
+// Write the body of the given response to a range request at the start of the given byte range of out, checking that
+// the server sent exactly that range
+func writeRangeResponse(resp *http.Response, out *os.File, chunk byteRange, progress io.Writer) *FetchError {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return newError(failedToDownloadFile, fmt.Sprintf("Expected a partial response for bytes %d-%d, but got status %d. The server stopped honoring range requests; try again without --download-connections.", chunk.start, chunk.end, resp.StatusCode))
+	}
+	// A server may answer with a different range than the one asked for, which would end up in the wrong part of the file
+	start, end, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || start != chunk.start || end != chunk.end {
+		return newError(failedToDownloadFile, fmt.Sprintf("Expected bytes %d-%d, but the server sent the range \"%s\". Try again without --download-connections.", chunk.start, chunk.end, resp.Header.Get("Content-Range")))
+	}
+
+	written, err := io.Copy(&offsetWriter{file: out, offset: chunk.start}, io.TeeReader(io.LimitReader(resp.Body, chunk.length()), progress))
+	if err != nil {
+		return wrapError(err)
+	}
+	if written != chunk.length() {
+		return newError(failedToDownloadFile, fmt.Sprintf("Expected %d bytes for bytes %d-%d, but only got %d.", chunk.length(), chunk.start, chunk.end, written))
+	}
+	return nil
+}
+
+// Parse the first and last byte positions out of the given Content-Range header of a partial response, which has the
+// form "bytes <first>-<last>/<size>", where the size may be "*" if it's unknown
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	var start, end int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &end); err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// Writes to a file sequentially from the given offset, so that several can write to different parts of the same file
+// at once
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// Serializes writes to a writer that isn't safe for concurrent use, such as a writeCounter
+type lockedWriter struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.writer.Write(p)
+}
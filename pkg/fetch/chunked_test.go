@@ -0,0 +1,164 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitIntoRanges(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		size     int64
+		count    int
+		expected []byteRange
+	}{
+		{"one range", 10, 1, []byteRange{{0, 9}}},
+		{"even split", 10, 2, []byteRange{{0, 4}, {5, 9}}},
+		{"remainder in last range", 10, 3, []byteRange{{0, 2}, {3, 5}, {6, 9}}},
+		{"more ranges than bytes", 2, 4, []byteRange{{0, 0}, {1, 1}}},
+		{"no ranges requested", 10, 0, []byteRange{{0, 9}}},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, splitIntoRanges(tc.size, tc.count))
+		})
+	}
+}
+
+func TestOffsetWritersAssembleFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "asset")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	contents := "0123456789"
+	for _, chunk := range []byteRange{{6, 9}, {0, 2}, {3, 5}} {
+		writer := &offsetWriter{file: file, offset: chunk.start}
+		// Write in two pieces to check that the offset advances
+		_, err := writer.Write([]byte(contents[chunk.start : chunk.start+1]))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(contents[chunk.start+1 : chunk.end+1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(written))
+}
+
+func TestParseContentRange(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		contentRange  string
+		expectedStart int64
+		expectedEnd   int64
+		expectedOk    bool
+	}{
+		{"bytes 0-9/100", 0, 9, true},
+		{"bytes 50-99/*", 50, 99, true},
+		{"bytes 0-9", 0, 0, false},
+		{"bytes */100", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.contentRange, func(t *testing.T) {
+			t.Parallel()
+			start, end, ok := parseContentRange(tc.contentRange)
+			assert.Equal(t, tc.expectedOk, ok)
+			if tc.expectedOk {
+				assert.Equal(t, tc.expectedStart, start)
+				assert.Equal(t, tc.expectedEnd, end)
+			}
+		})
+	}
+}
+
+func TestDownloadReleaseAssetInChunksChecksContentRange(t *testing.T) {
+	t.Parallel()
+
+	contents := bytes.Repeat([]byte("0123456789abcdef"), minChunkedDownloadSize/16)
+
+	cases := []struct {
+		name string
+		// The range the server says it sent in response to a request for bytes start-end
+		contentRange func(start int64, end int64) (int64, int64)
+		expectErr    bool
+	}{
+		{"honest", func(start int64, end int64) (int64, int64) { return start, end }, false},
+		{"shifted", func(start int64, end int64) (int64, int64) { return start + 16, end + 16 }, true},
+		{"from-start", func(start int64, end int64) (int64, int64) { return 0, end - start }, true},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				var start, end int64
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				sentStart, sentEnd := tc.contentRange(start, end)
+				if sentEnd >= int64(len(contents)) {
+					sentStart, sentEnd = sentStart-16, sentEnd-16
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", sentStart, sentEnd, len(contents)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(contents[sentStart : sentEnd+1])
+			}))
+			defer server.Close()
+
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:          server.URL + "/foo/bar",
+				GithubApiVersion: "v3",
+				Connection:       ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+			})
+			require.NoError(t, err)
+
+			destPath := filepath.Join(t.TempDir(), "asset")
+			asset := &GitHubReleaseAsset{Id: 1, Name: "asset", Size: int64(len(contents))}
+			fetchErr := downloadReleaseAssetInChunks(fetcher.withConnection(context.Background()), fetcher.repo, asset, destPath, 4, false)
+			if tc.expectErr {
+				require.NotNil(t, fetchErr)
+				assert.NoFileExists(t, destPath)
+				return
+			}
+			require.Nil(t, fetchErr)
+			written, err := ioutil.ReadFile(destPath)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(contents, written))
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package fetch
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The endpoints that the credentials of the role fetch runs as are read from on AWS, and of the service account it runs
+// as on Google Cloud. These are only overridden in tests.
+var (
+	awsStsEndpoint              = "https://sts.amazonaws.com"
+	awsContainerCredentialsHost = "http://169.254.170.2"
+	awsInstanceMetadataEndpoint = "http://169.254.169.254"
+	googleTokenEndpoint         = "https://oauth2.googleapis.com/token"
+	googleMetadataEndpoint      = "http://metadata.google.internal"
+)
+
+// The OAuth scope of the Google access tokens fetch requests, which is only enough to read buckets
+const googleStorageReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// Return a client for the link-local metadata endpoints of cloud providers. Unlike every other HTTP request fetch makes,
+// requests to these must never go through a proxy, and they time out quickly, as they can't be reached off the cloud.
+func newMetadataClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{}, Timeout: 2 * time.Second}
+}
+
+// Return AWS credentials from the standard AWS environment variables, or else those of the IAM role fetch runs as: the
+// role of an EKS service account (AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE), of an ECS task, or of an EC2 instance.
+// The error if there are none begins with purpose, which says what they're needed for.
+func getAwsCredentials(ctx context.Context, purpose string) (AwsCredentials, error) {
+	if creds, err := getAwsCredentialsFromEnv(purpose); err == nil {
+		return creds, nil
+	}
+
+	var creds AwsCredentials
+	var err error
+	switch {
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "":
+		creds, err = getAwsWebIdentityCredentials(ctx)
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "":
+		creds, err = getAwsContainerCredentials(ctx)
+	case strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true"):
+		err = errors.New("the EC2 instance metadata service is disabled by AWS_EC2_METADATA_DISABLED")
+	default:
+		creds, err = getAwsInstanceCredentials(ctx)
+	}
+	if err != nil {
+		return creds, fmt.Errorf("%s requires AWS credentials, either in the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables or from the IAM role fetch runs as, but the role's credentials could not be read: %s", purpose, err)
+	}
+	return creds, nil
+}
+
+// The credentials of an IAM role as the ECS and EC2 metadata endpoints return them
+type awsRoleCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+}
+
+func (roleCreds awsRoleCredentials) credentials() (AwsCredentials, error) {
+	if roleCreds.AccessKeyId == "" || roleCreds.SecretAccessKey == "" {
+		return AwsCredentials{}, errors.New("the response had no access key")
+	}
+	return AwsCredentials{AccessKeyId: roleCreds.AccessKeyId, SecretAccessKey: roleCreds.SecretAccessKey, SessionToken: roleCreds.Token}, nil
+}
+
+// Exchange the web identity token of an EKS service account for the credentials of the role in AWS_ROLE_ARN with
+// AssumeRoleWithWebIdentity, which needs no credentials of its own
+func getAwsWebIdentityCredentials(ctx context.Context) (AwsCredentials, error) {
+	token, err := ioutil.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "fetch"
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", awsStsEndpoint+"/", strings.NewReader(query.Encode()))
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := readCredentialsResponse(newHttpClient(ctx), request)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+
+	var response struct {
+		Credentials struct {
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return AwsCredentials{}, err
+	}
+	roleCreds := awsRoleCredentials{AccessKeyId: response.Credentials.AccessKeyId, SecretAccessKey: response.Credentials.SecretAccessKey, Token: response.Credentials.SessionToken}
+	return roleCreds.credentials()
+}
+
+// Read the credentials of the role of the ECS task fetch runs in from the container credentials endpoint
+func getAwsContainerCredentials(ctx context.Context) (AwsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if endpoint == "" {
+		endpoint = awsContainerCredentialsHost + os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		request.Header.Set("Authorization", token)
+	}
+	body, err := readCredentialsResponse(newMetadataClient(), request)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	var roleCreds awsRoleCredentials
+	if err := json.Unmarshal(body, &roleCreds); err != nil {
+		return AwsCredentials{}, err
+	}
+	return roleCreds.credentials()
+}
+
+// Read the credentials of the role of the EC2 instance fetch runs on from the instance metadata service, with a
+// session token as IMDSv2 requires
+func getAwsInstanceCredentials(ctx context.Context) (AwsCredentials, error) {
+	client := newMetadataClient()
+
+	request, err := http.NewRequestWithContext(ctx, "PUT", awsInstanceMetadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := readCredentialsResponse(client, request)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+
+	get := func(path string) ([]byte, error) {
+		request, err := http.NewRequestWithContext(ctx, "GET", awsInstanceMetadataEndpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return readCredentialsResponse(client, request)
+	}
+	roles, err := get("")
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return AwsCredentials{}, errors.New("the EC2 instance has no IAM role")
+	}
+	body, err := get(role)
+	if err != nil {
+		return AwsCredentials{}, err
+	}
+	var roleCreds awsRoleCredentials
+	if err := json.Unmarshal(body, &roleCreds); err != nil {
+		return AwsCredentials{}, err
+	}
+	return roleCreds.credentials()
+}
+
+// Return an access token for Google Cloud Storage from Application Default Credentials, as the Google Cloud SDKs find
+// them: a token in GOOGLE_OAUTH_ACCESS_TOKEN, the credentials file in GOOGLE_APPLICATION_CREDENTIALS or the one that
+// "gcloud auth application-default login" writes, or else the service account of the GCE instance, GKE workload, or
+// Cloud Run service fetch runs as.
+func getGoogleAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	credentialsPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if credentialsPath == "" {
+		if path := googleWellKnownCredentialsPath(); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				credentialsPath = path
+			}
+		}
+	}
+	if credentialsPath != "" {
+		token, err := getGoogleCredentialsFileToken(ctx, credentialsPath)
+		if err != nil {
+			return "", fmt.Errorf("Could not get a Google access token with the credentials in %s: %s", credentialsPath, err)
+		}
+		return token, nil
+	}
+
+	token, err := getGoogleMetadataToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Google Cloud Storage requires Application Default Credentials: set GOOGLE_APPLICATION_CREDENTIALS, run \"gcloud auth application-default login\", or run on Google Cloud with a service account, whose token could not be read: %s", err)
+	}
+	return token, nil
+}
+
+// Return the path of the credentials file that "gcloud auth application-default login" writes, or an empty string if
+// it can't be worked out
+func googleWellKnownCredentialsPath() string {
+	configDir := os.Getenv("CLOUDSDK_CONFIG")
+	if configDir == "" {
+		if runtime.GOOS == "windows" {
+			configDir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			configDir = filepath.Join(home, ".config", "gcloud")
+		} else {
+			return ""
+		}
+	}
+	return filepath.Join(configDir, "application_default_credentials.json")
+}
+
+// The fields of a Google credentials file that fetch uses, for a service account key or the credentials of a user
+type googleCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenUri     string `json:"token_uri"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Exchange the credentials in the given file for an access token: a JWT signed with the key of a service account, or
+// the refresh token of a user
+func getGoogleCredentialsFileToken(ctx context.Context, path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var credentials googleCredentialsFile
+	if err := json.Unmarshal(contents, &credentials); err != nil {
+		return "", err
+	}
+
+	tokenUri := credentials.TokenUri
+	if tokenUri == "" {
+		tokenUri = googleTokenEndpoint
+	}
+	var form url.Values
+	switch credentials.Type {
+	case "service_account":
+		assertion, err := credentials.signedJwt(tokenUri, time.Now())
+		if err != nil {
+			return "", err
+		}
+		form = url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	case "authorized_user":
+		form = url.Values{"grant_type": {"refresh_token"}, "client_id": {credentials.ClientId}, "client_secret": {credentials.ClientSecret}, "refresh_token": {credentials.RefreshToken}}
+	default:
+		return "", fmt.Errorf("credentials of type \"%s\" aren't supported. Use a service account key or \"gcloud auth application-default login\".", credentials.Type)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", tokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return readGoogleTokenResponse(newHttpClient(ctx), request)
+}
+
+// Return a JWT asserting the identity of the service account, signed with its private key, for the given token URI
+func (credentials googleCredentialsFile) signedJwt(tokenUri string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return "", errors.New("the service account has no PEM private key")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("could not parse the service account's private key: %s", err)
+		}
+	}
+	key, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("the service account's private key is not an RSA key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   credentials.ClientEmail,
+		"scope": googleStorageReadOnlyScope,
+		"aud":   tokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Read an access token for the service account fetch runs as on Google Cloud from the metadata server
+func getGoogleMetadataToken(ctx context.Context) (string, error) {
+	endpoint := googleMetadataEndpoint
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		endpoint = "http://" + host
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint+"/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(googleStorageReadOnlyScope), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	return readGoogleTokenResponse(newMetadataClient(), request)
+}
+
+// Send the given request for a Google access token, and return the token in the response
+func readGoogleTokenResponse(client *http.Client, request *http.Request) (string, error) {
+	body, err := readCredentialsResponse(client, request)
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	if response.AccessToken == "" {
+		return "", errors.New("the response had no access token")
+	}
+	return response.AccessToken, nil
+}
+
+// Send the given request for credentials with client, and return the body of the response if it succeeded
+func readCredentialsResponse(client *http.Client, request *http.Request) ([]byte, error) {
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned HTTP %d: %s", request.Method, request.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
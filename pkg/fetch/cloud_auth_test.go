@@ -0,0 +1,142 @@
+package fetch
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestGetAwsInstanceCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			assert.Equal(t, "PUT", r.Method)
+			w.Write([]byte("imds-token"))
+		case "/latest/meta-data/iam/security-credentials/":
+			assert.Equal(t, "imds-token", r.Header.Get("X-aws-ec2-metadata-token"))
+			w.Write([]byte("mirror-reader\n"))
+		case "/latest/meta-data/iam/security-credentials/mirror-reader":
+			assert.Equal(t, "imds-token", r.Header.Get("X-aws-ec2-metadata-token"))
+			w.Write([]byte(`{"AccessKeyId": "ASIAEXAMPLE", "SecretAccessKey": "secret", "Token": "session"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defaultEndpoint := awsInstanceMetadataEndpoint
+	awsInstanceMetadataEndpoint = server.URL
+	defer func() { awsInstanceMetadataEndpoint = defaultEndpoint }()
+
+	creds, err := getAwsCredentials(context.Background(), "Reading the mirror")
+	require.NoError(t, err)
+	assert.Equal(t, AwsCredentials{AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}, creds)
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	_, err = getAwsCredentials(context.Background(), "Reading the mirror")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Reading the mirror requires AWS credentials")
+}
+
+func TestGetGoogleCredentialsFileToken(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		switch r.Form.Get("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			require.Len(t, parts, 3)
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			require.NoError(t, err)
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+			claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+			require.NoError(t, err)
+			var claims map[string]interface{}
+			require.NoError(t, json.Unmarshal(claimsJson, &claims))
+			assert.Equal(t, "reader@project.iam.gserviceaccount.com", claims["iss"])
+			assert.Equal(t, googleStorageReadOnlyScope, claims["scope"])
+			w.Write([]byte(`{"access_token": "service-account-token"}`))
+		case "refresh_token":
+			assert.Equal(t, "user-refresh-token", r.Form.Get("refresh_token"))
+			w.Write([]byte(`{"access_token": "user-token"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		credentials   googleCredentialsFile
+		expectedToken string
+	}{
+		{
+			"service-account",
+			googleCredentialsFile{
+				Type:        "service_account",
+				ClientEmail: "reader@project.iam.gserviceaccount.com",
+				PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+				TokenUri:    server.URL,
+			},
+			"service-account-token",
+		},
+		{
+			"authorized-user",
+			googleCredentialsFile{Type: "authorized_user", ClientId: "client", ClientSecret: "secret", RefreshToken: "user-refresh-token", TokenUri: server.URL},
+			"user-token",
+		},
+		{
+			"external-account",
+			googleCredentialsFile{Type: "external_account", TokenUri: server.URL},
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents, err := json.Marshal(tc.credentials)
+			require.NoError(t, err)
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			require.NoError(t, ioutil.WriteFile(path, contents, 0600))
+
+			token, err := getGoogleCredentialsFileToken(context.Background(), path)
+			if tc.expectedToken == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedToken, token)
+		})
+	}
+}
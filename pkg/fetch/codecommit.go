@@ -0,0 +1,428 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The version of the CodeCommit API that requests are sent to, which prefixes the name of every operation
+const codeCommitApiVersion = "CodeCommit_20150413"
+
+// Matches the HTTPS clone URL of a CodeCommit repo, e.g. https://git-codecommit.us-east-1.amazonaws.com/v1/repos/modules
+var codeCommitHttpsUrlRegex = regexp.MustCompile(`^https://git-codecommit(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/v1/repos/([\w.-]+)/?$`)
+
+// Matches the URL of a CodeCommit repo as git-remote-codecommit takes it, e.g. codecommit::us-east-1://modules, or
+// codecommit://modules to use the default region
+var codeCommitGrcUrlRegex = regexp.MustCompile(`^codecommit:(?::([a-z0-9-]+):)?//([\w.-]+)$`)
+
+// Matches the name of a CodeCommit repo
+var codeCommitRepoNameRegex = regexp.MustCompile(`^[\w.-]+$`)
+
+// A repo in AWS CodeCommit, which is read with SigV4-signed calls to the CodeCommit API
+type CodeCommitRepo struct {
+	Url         string // The URL or name of the repo, as given
+	Region      string
+	Name        string
+	Credentials AwsCredentials
+
+	// The scheme and host to send requests to. If empty, the CodeCommit endpoint for the region is used. This is only
+	// overridden in tests.
+	endpoint string
+}
+
+// Return true if repoUrl is the URL of a CodeCommit repo, so that it's downloaded from CodeCommit without the Source
+// option having to say so
+func isCodeCommitRepoUrl(repoUrl string) bool {
+	return codeCommitHttpsUrlRegex.MatchString(repoUrl) || codeCommitGrcUrlRegex.MatchString(repoUrl)
+}
+
+// Parse repoUrl as a CodeCommit repo: its HTTPS clone URL, its codecommit:// URL, or just its name. The region in the
+// URL, if any, takes precedence over the given region, which defaults to the one in the AWS_REGION env var.
+func ParseCodeCommitRepo(repoUrl string, region string) (*CodeCommitRepo, error) {
+	var name string
+	if matches := codeCommitHttpsUrlRegex.FindStringSubmatch(repoUrl); matches != nil {
+		region, name = matches[1], matches[2]
+	} else if matches := codeCommitGrcUrlRegex.FindStringSubmatch(repoUrl); matches != nil {
+		if matches[1] != "" {
+			region = matches[1]
+		}
+		name = matches[2]
+	} else if codeCommitRepoNameRegex.MatchString(repoUrl) {
+		name = repoUrl
+	} else {
+		return nil, fmt.Errorf("CodeCommit repo %s must be an HTTPS clone URL (e.g. https://git-codecommit.us-east-1.amazonaws.com/v1/repos/modules), a codecommit:// URL, or the name of the repo.", repoUrl)
+	}
+
+	creds, err := getAwsCredentialsFromEnv(fmt.Sprintf("Downloading from CodeCommit repo %s", name))
+	if err != nil {
+		return nil, err
+	}
+	return &CodeCommitRepo{Url: repoUrl, Region: getAwsRegion(region), Name: name, Credentials: creds}, nil
+}
+
+// Return the HTTPS URL the repo is cloned from with git, which is the closest thing it has to a download URL
+func (repo *CodeCommitRepo) cloneUrl() string {
+	return fmt.Sprintf("https://git-codecommit.%s.amazonaws.com/v1/repos/%s", repo.Region, repo.Name)
+}
+
+// A folder in a CodeCommit repo, as returned by GetFolder
+type codeCommitFolder struct {
+	CommitId   string `json:"commitId"`
+	SubFolders []struct {
+		AbsolutePath string `json:"absolutePath"`
+	} `json:"subFolders"`
+	Files []codeCommitFolderFile `json:"files"`
+	// Symbolic links are listed with the same fields as files, and their contents are their targets
+	SymbolicLinks []codeCommitFolderFile `json:"symbolicLinks"`
+}
+
+// A file in a codeCommitFolder
+type codeCommitFolderFile struct {
+	AbsolutePath string `json:"absolutePath"`
+	FileMode     string `json:"fileMode"` // EXECUTABLE, NORMAL, or SYMLINK
+}
+
+// A file in a CodeCommit repo, as returned by GetFile
+type codeCommitFile struct {
+	CommitId    string `json:"commitId"`
+	FileMode    string `json:"fileMode"`
+	FileContent []byte `json:"fileContent"` // Base64 encoded in the response, which encoding/json decodes
+}
+
+// An error response from the CodeCommit API
+type codeCommitError struct {
+	Operation  string `json:"-"`
+	StatusCode int    `json:"-"`
+	Type       string `json:"__type"` // Prefixed with the namespace of the API, e.g. com.amazonaws.codecommit#FileDoesNotExistException
+	Message    string `json:"message"`
+}
+
+func (err *codeCommitError) Error() string {
+	return fmt.Sprintf("CodeCommit %s returned HTTP %d: %s %s", err.Operation, err.StatusCode, err.errorType(), err.Message)
+}
+
+// Return the type of the error without its namespace, e.g. FileDoesNotExistException
+func (err *codeCommitError) errorType() string {
+	return err.Type[strings.LastIndex(err.Type, "#")+1:]
+}
+
+// Call the given operation of the CodeCommit API, e.g. GetFolder, with the given input, and decode its output into
+// output. For the API reference, see: https://docs.aws.amazon.com/codecommit/latest/APIReference/Welcome.html
+func (repo *CodeCommitRepo) call(ctx context.Context, operation string, input map[string]string, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	endpoint := repo.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://codecommit.%s.amazonaws.com", repo.Region)
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", codeCommitApiVersion+"."+operation)
+	repo.sign(request, body, time.Now())
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &codeCommitError{Operation: operation, StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+// Sign the given request to the CodeCommit API, which has the given body, with AWS Signature Version 4. For more info,
+// see: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (repo *CodeCommitRepo) sign(request *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", now.Format("20060102"), repo.Region)
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if repo.Credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", repo.Credentials.SessionToken)
+	}
+
+	// Every header set above is signed, in the sorted order SigV4 requires, along with the host
+	signedHeaders := []string{"content-type", "host", "x-amz-date"}
+	if repo.Credentials.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+	signedHeaders = append(signedHeaders, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, header := range signedHeaders {
+		value := request.Header.Get(header)
+		if header == "host" {
+			value = request.URL.Host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", header, strings.TrimSpace(value))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		sha256Hex(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hmacSha256(awsSigningKey(repo.Credentials.SecretAccessKey, now, repo.Region, "codecommit"), stringToSign)
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x", repo.Credentials.AccessKeyId, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// Return the SHA of the commit at the tip of the given branch
+func (repo *CodeCommitRepo) getBranchCommit(ctx context.Context, branch string) (string, error) {
+	var output struct {
+		Branch struct {
+			CommitId string `json:"commitId"`
+		} `json:"branch"`
+	}
+	if err := repo.call(ctx, "GetBranch", map[string]string{"repositoryName": repo.Name, "branchName": branch}, &output); err != nil {
+		return "", err
+	}
+	return output.Branch.CommitId, nil
+}
+
+// Write every file under the given folder of the repo at the given commit specifier (a commit, branch, tag, or other
+// ref) to zipWriter, under the given top-level directory, and return the SHA of the commit they were read from. If the
+// folder is actually a file, as a source path may be, the file is written instead.
+func (repo *CodeCommitRepo) addFolderToZip(ctx context.Context, zipWriter *zip.Writer, topLevelDir string, commitSpecifier string, folderPath string) (string, error) {
+	var folder codeCommitFolder
+	if err := repo.call(ctx, "GetFolder", map[string]string{"repositoryName": repo.Name, "commitSpecifier": commitSpecifier, "folderPath": folderPath}, &folder); err != nil {
+		if apiErr, ok := err.(*codeCommitError); ok && apiErr.errorType() == "FolderDoesNotExistException" {
+			return repo.addFileToZip(ctx, zipWriter, topLevelDir, commitSpecifier, folderPath)
+		}
+		return "", err
+	}
+	// Read everything else from the same commit, in case a branch moves while the folder is being downloaded
+	commitId := folder.CommitId
+
+	// The top-level directory is the first entry of the archive, so it's only added once
+	if folderPath != "/" {
+		if _, err := zipWriter.CreateHeader(&zip.FileHeader{Name: path.Join(topLevelDir, folderPath) + "/", Method: zip.Store}); err != nil {
+			return "", err
+		}
+	}
+	for _, file := range append(folder.Files, folder.SymbolicLinks...) {
+		if _, err := repo.addFileToZip(ctx, zipWriter, topLevelDir, commitId, file.AbsolutePath); err != nil {
+			return "", err
+		}
+	}
+	for _, subFolder := range folder.SubFolders {
+		if _, err := repo.addFolderToZip(ctx, zipWriter, topLevelDir, commitId, subFolder.AbsolutePath); err != nil {
+			return "", err
+		}
+	}
+	return commitId, nil
+}
+
+// Write the given file of the repo at the given commit specifier to zipWriter, under the given top-level directory, and
+// return the SHA of the commit it was read from
+func (repo *CodeCommitRepo) addFileToZip(ctx context.Context, zipWriter *zip.Writer, topLevelDir string, commitSpecifier string, filePath string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var file codeCommitFile
+	if err := repo.call(ctx, "GetFile", map[string]string{"repositoryName": repo.Name, "commitSpecifier": commitSpecifier, "filePath": filePath}, &file); err != nil {
+		return "", err
+	}
+	header := &zip.FileHeader{Name: path.Join(topLevelDir, filePath), Method: zip.Deflate}
+	header.SetMode(codeCommitFileMode(file.FileMode))
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(file.FileContent); err != nil {
+		return "", err
+	}
+	return file.CommitId, nil
+}
+
+// Return the mode of a file in a zip archive that corresponds to the given CodeCommit file mode
+func codeCommitFileMode(fileMode string) os.FileMode {
+	switch fileMode {
+	case "EXECUTABLE":
+		return 0755
+	case "SYMLINK":
+		return os.ModeSymlink | 0777
+	default:
+		return 0644
+	}
+}
+
+// Return the commit specifier to download from CodeCommit: the commit or branch in gitHubCommit, or otherwise its tag
+// or ref. A branch is resolved to the commit at its tip, so that every source path is downloaded from the same commit.
+func (repo *CodeCommitRepo) commitSpecifier(ctx context.Context, gitHubCommit GitHubCommit) (string, error) {
+	// Ordering matters in this conditional, as it does in MakeGitHubZipFileRequest
+	switch {
+	case gitHubCommit.CommitSha != "":
+		return gitHubCommit.CommitSha, nil
+	case gitHubCommit.BranchName != "":
+		return repo.getBranchCommit(ctx, gitHubCommit.BranchName)
+	case gitHubCommit.GitTag != "":
+		return gitHubCommit.GitTag, nil
+	case gitHubCommit.GitRef != "":
+		return gitHubCommit.GitRef, nil
+	default:
+		return "", fmt.Errorf("The commit sha, tag, and branch name are all empty")
+	}
+}
+
+// Download the given source paths of the repo at the given commit to a zip archive laid out like a GitHub repo
+// archive, with a single top-level directory, so that it can be extracted the same way. Return its path, along with a
+// function that deletes it, and the SHA of the commit it was downloaded from.
+func downloadCodeCommitArchive(ctx context.Context, repo *CodeCommitRepo, gitHubCommit GitHubCommit, sourcePaths []string) (string, func(), string, error) {
+	commitSpecifier, err := repo.commitSpecifier(ctx, gitHubCommit)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-codecommit")
+	if err != nil {
+		return "", nil, "", err
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+	archivePath := filepath.Join(tempDir, "repo.zip")
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		cleanup()
+		return "", nil, "", err
+	}
+	defer archive.Close()
+
+	zipWriter := zip.NewWriter(archive)
+	if _, err := zipWriter.CreateHeader(&zip.FileHeader{Name: repo.Name + "/", Method: zip.Store}); err != nil {
+		cleanup()
+		return "", nil, "", err
+	}
+	for _, sourcePath := range sourcePaths {
+		folderPath := strings.Trim(sourcePath, "/")
+		if folderPath == "" {
+			folderPath = "/"
+		}
+		// Pin the commit the first folder was read from for the rest, as addFolderToZip does within a folder
+		if commitSpecifier, err = repo.addFolderToZip(ctx, zipWriter, repo.Name, commitSpecifier, folderPath); err != nil {
+			cleanup()
+			return "", nil, "", fmt.Errorf("Error occurred while downloading %s from CodeCommit repo %s: %s", sourcePath, repo.Name, err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		cleanup()
+		return "", nil, "", err
+	}
+	return archivePath, cleanup, commitSpecifier, nil
+}
+
+// Return an error if options ask for anything a CodeCommit repo doesn't have. CodeCommit has no releases, so only
+// source paths can be downloaded from it, and has no API to list tags, so only a specific tag can be downloaded.
+func validateCodeCommitOptions(options Options) error {
+	tagConstraint := options.TagConstraint
+	if options.GitRef != "" {
+		tagConstraint = options.GitRef
+	}
+	specific, _ := isTagConstraintSpecificTag(tagConstraint)
+
+	switch {
+	case options.releaseAssetRegex() != "" || options.AutoAsset:
+		return fmt.Errorf("CodeCommit repos have no releases, so release assets can't be downloaded from %s. Use --source-path instead.", options.RepoUrl)
+	case len(options.SourceFiles) > 0:
+		return fmt.Errorf("Single source files can't be downloaded from CodeCommit repo %s. Use --source-path instead.", options.RepoUrl)
+	case tagConstraint != "" && !specific:
+		return fmt.Errorf("The tags of CodeCommit repo %s can't be listed, so --tag must be a specific tag, not the constraint \"%s\".", options.RepoUrl, tagConstraint)
+	case tagConstraint == "" && options.CommitSha == "" && options.BranchName == "":
+		return fmt.Errorf("CodeCommit repo %s has no tags to download the latest of, so one of --tag, --branch, --commit, or --ref is required.", options.RepoUrl)
+	case options.CheckImmutableTag || options.RequireImmutableTag:
+		return fmt.Errorf("Tag immutability can't be checked in CodeCommit repo %s.", options.RepoUrl)
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for CodeCommit repo %s.", options.RepoUrl)
+	case options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("The commit a ref points to can only be resolved in GitHub repos, not in CodeCommit repo %s.", options.RepoUrl)
+	case options.RequireSignedRef:
+		return fmt.Errorf("The signatures of tags and commits can only be checked in GitHub repos, not in CodeCommit repo %s.", options.RepoUrl)
+	}
+	return nil
+}
+
+// Download the source paths in the Fetcher's options from its CodeCommit repo, as Fetch does for a GitHub repo
+func (fetcher *Fetcher) fetchFromCodeCommit(ctx context.Context) (*Result, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+	repo := fetcher.codeCommit
+
+	start := time.Now()
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Tag: resolvedTag.Tag}
+	result.Timings.Resolve = time.Since(start)
+
+	sourcePaths := options.sourcePaths()
+	if len(sourcePaths) == 0 {
+		result.Timings.Total = time.Since(start)
+		return result, nil
+	}
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	downloadStart := time.Now()
+	gitHubCommit := fetcher.gitHubCommit(resolvedTag.Tag)
+	logger.Infof("Downloading %s of CodeCommit repo %s in %s ...\n", gitHubCommit.ref(), repo.Name, repo.Region)
+	archivePath, cleanup, commitId, err := downloadCodeCommitArchive(ctx, repo, gitHubCommit, sourcePaths)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if gitHubCommit.CommitSha == "" && gitHubCommit.BranchName == "" {
+		result.TagCommitSha = commitId
+	}
+
+	extractStart := time.Now()
+	for _, sourcePath := range sourcePaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		logger.Infof("Extracting files from <repo>%s to %s ...\n", sourcePath, options.LocalDownloadPath)
+		fileCount, err := extractFiles(archivePath, sourcePath, options.LocalDownloadPath, extractOptions)
+		logger.Infof("%d file(s) extracted\n", fileCount)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while extracting files downloaded from CodeCommit: %s", err)
+		}
+	}
+	result.Timings.Extract = time.Since(extractStart)
+	result.Timings.Download = time.Since(downloadStart)
+
+	logger.Infof("Download and file extraction complete.\n")
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestParseCodeCommitRepo(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	testCases := []struct {
+		repoUrl        string
+		expectedRegion string
+		expectedName   string
+	}{
+		{"https://git-codecommit.us-east-2.amazonaws.com/v1/repos/modules", "us-east-2", "modules"},
+		{"https://git-codecommit-fips.us-gov-west-1.amazonaws.com/v1/repos/infra.modules/", "us-gov-west-1", "infra.modules"},
+		{"codecommit::ap-southeast-2://modules", "ap-southeast-2", "modules"},
+		{"codecommit://modules", "eu-west-1", "modules"},
+		{"modules", "eu-west-1", "modules"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			repo, err := ParseCodeCommitRepo(tc.repoUrl, "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRegion, repo.Region)
+			assert.Equal(t, tc.expectedName, repo.Name)
+			assert.Equal(t, "AKIDEXAMPLE", repo.Credentials.AccessKeyId)
+		})
+	}
+
+	_, err := ParseCodeCommitRepo("https://github.com/foo/bar", "")
+	assert.Error(t, err)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	_, err = ParseCodeCommitRepo("modules", "")
+	assert.Error(t, err)
+}
+
+func TestIsCodeCommitRepoUrl(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isCodeCommitRepoUrl("https://git-codecommit.us-east-1.amazonaws.com/v1/repos/modules"))
+	assert.True(t, isCodeCommitRepoUrl("codecommit::us-east-1://modules"))
+	assert.False(t, isCodeCommitRepoUrl("modules"))
+	assert.False(t, isCodeCommitRepoUrl("https://github.com/foo/bar"))
+}
+
+func TestValidateCodeCommitOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"specific-tag", Options{TagConstraint: "v1.0.0", SourcePaths: []string{"/"}}, true},
+		{"branch", Options{BranchName: "main", SourcePaths: []string{"/"}}, true},
+		{"ref", Options{GitRef: "refs/heads/main", SourcePaths: []string{"/"}}, true},
+		{"tag-constraint", Options{TagConstraint: "~>1.0", SourcePaths: []string{"/"}}, false},
+		{"no-ref", Options{SourcePaths: []string{"/"}}, false},
+		{"release-asset", Options{TagConstraint: "v1.0.0", ReleaseAsset: "tool"}, false},
+		{"source-file", Options{TagConstraint: "v1.0.0", SourceFiles: []string{"README.md"}}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateCodeCommitOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+// A fake CodeCommit API with a single repo, modules, whose main branch is at commit c0ffee
+type fakeCodeCommitApi struct {
+	t     *testing.T
+	files map[string]codeCommitFile
+}
+
+func (api *fakeCodeCommitApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assert.True(api.t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(api.t, r.Header.Get("Authorization"), "/us-east-1/codecommit/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature=")
+
+	var input map[string]string
+	require.NoError(api.t, json.NewDecoder(r.Body).Decode(&input))
+	assert.Equal(api.t, "modules", input["repositoryName"])
+
+	writeError := func(errType string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(codeCommitError{Type: "com.amazonaws.codecommit#" + errType, Message: "not found"})
+	}
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "CodeCommit_20150413.GetBranch":
+		assert.Equal(api.t, "main", input["branchName"])
+		w.Write([]byte(`{"branch": {"branchName": "main", "commitId": "c0ffee"}}`))
+	case "CodeCommit_20150413.GetFolder":
+		assert.Equal(api.t, "c0ffee", input["commitSpecifier"])
+		folder := codeCommitFolder{CommitId: "c0ffee"}
+		found := false
+		for filePath, file := range api.files {
+			dir := filepath.Dir(filePath)
+			if dir == "." {
+				dir = "/"
+			}
+			if strings.HasPrefix(filePath, input["folderPath"]+"/") || input["folderPath"] == "/" {
+				found = true
+			}
+			if dir == input["folderPath"] {
+				folder.Files = append(folder.Files, codeCommitFolderFile{AbsolutePath: filePath, FileMode: file.FileMode})
+			} else if filepath.Dir(dir) == input["folderPath"] || (input["folderPath"] == "/" && filepath.Dir(dir) == ".") {
+				folder.SubFolders = append(folder.SubFolders, struct {
+					AbsolutePath string `json:"absolutePath"`
+				}{dir})
+			}
+		}
+		if !found {
+			writeError("FolderDoesNotExistException")
+			return
+		}
+		json.NewEncoder(w).Encode(folder)
+	case "CodeCommit_20150413.GetFile":
+		assert.Equal(api.t, "c0ffee", input["commitSpecifier"])
+		file, ok := api.files[input["filePath"]]
+		if !ok {
+			writeError("FileDoesNotExistException")
+			return
+		}
+		file.CommitId = "c0ffee"
+		json.NewEncoder(w).Encode(file)
+	default:
+		writeError("InvalidActionException")
+	}
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestFetchFromCodeCommit(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	api := &fakeCodeCommitApi{t: t, files: map[string]codeCommitFile{
+		"README.md":             {FileMode: "NORMAL", FileContent: []byte("readme")},
+		"modules/vpc/main.tf":   {FileMode: "NORMAL", FileContent: []byte("vpc")},
+		"modules/vpc/bin/setup": {FileMode: "EXECUTABLE", FileContent: []byte("#!/bin/sh")},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		sourcePath    string
+		expectedFiles map[string]string
+	}{
+		{"folder", "/modules/vpc", map[string]string{"main.tf": "vpc", "bin/setup": "#!/bin/sh"}},
+		{"file", "/README.md", map[string]string{"": "readme"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := filepath.Join(t.TempDir(), "dest")
+			if tc.name == "folder" {
+				require.NoError(t, os.MkdirAll(destPath, 0755))
+			}
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:             "modules",
+				Source:              SourceCodeCommit,
+				BranchName:          "main",
+				SourcePaths:         []string{tc.sourcePath},
+				PreservePermissions: true,
+				LocalDownloadPath:   destPath,
+			})
+			require.NoError(t, err)
+			fetcher.codeCommit.endpoint = server.URL
+
+			_, err = fetcher.Fetch(context.Background(), io.Discard)
+			require.NoError(t, err)
+			for name, expected := range tc.expectedFiles {
+				contents, err := ioutil.ReadFile(filepath.Join(destPath, name))
+				require.NoError(t, err)
+				assert.Equal(t, expected, string(contents))
+			}
+			if tc.name == "folder" {
+				info, err := os.Stat(filepath.Join(destPath, "bin", "setup"))
+				require.NoError(t, err)
+				assert.NotZero(t, info.Mode()&0100, "bin/setup should be executable")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// How fetch connects to GitHub and the other servers it downloads from, e.g. GitHub Enterprise Server instances with
+// certificates signed by an internal CA
+type ConnectionOptions struct {
+	Proxy                 string // If set, every HTTP request goes through this proxy rather than the one in HTTPS_PROXY
+	CaCert                string // The path of a PEM bundle of CA certificates to trust in addition to the system's
+	ClientCert            string // The path of a PEM client certificate to present to servers that require one
+	ClientKey             string // The path of the PEM private key of ClientCert
+	InsecureSkipTlsVerify bool   // Don't verify server certificates at all. Only meant for testing.
+	AuthScheme            string // One of the AuthScheme constants. How the GitHub token is sent. Defaults to AuthSchemeAuto.
+}
+
+// The URL schemes a proxy set with the Proxy option can have
+var proxySchemes = []string{"http", "https", "socks5"}
+
+// How the HTTP requests of a Fetcher are sent, as set by its Connection and WaitForRateLimit options. Each Fetcher
+// attaches its own connection to the context of everything it does, so Fetchers with different options can be used at
+// the same time.
+type connection struct {
+	// The transport every request goes through. If it's nil, http.DefaultTransport is used, which sends requests
+	// through the proxy set in the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY env vars, if any.
+	transport http.RoundTripper
+
+	// One of the AuthScheme constants. How the GitHub token is sent.
+	authScheme string
+
+	// If true, calls to the GitHub API that hit the rate limit will sleep until the rate limit resets and then be
+	// retried, rather than failing immediately.
+	waitForRateLimit bool
+}
+
+// The connection of a context that has none: http.DefaultTransport, the default auth scheme, and no waiting for rate
+// limits
+var defaultConnection = &connection{authScheme: AuthSchemeAuto}
+
+type connectionKey struct{}
+
+// Parse the given proxy URL (e.g. http://proxy.example.com:3128 or socks5://127.0.0.1:1080). Returns nil if proxy is
+// empty.
+func ParseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("The proxy must be a URL of the form <scheme>://<host>:<port> (e.g. http://proxy.example.com:3128).")
+	}
+	for _, scheme := range proxySchemes {
+		if proxyUrl.Scheme == scheme {
+			return proxyUrl, nil
+		}
+	}
+	return nil, fmt.Errorf("Unsupported proxy scheme \"%s\". Must be one of: %s.", proxyUrl.Scheme, strings.Join(proxySchemes, ", "))
+}
+
+// Return a connection that sends requests as the given options describe, warning with logger (or the project logger, if
+// it's nil) if it doesn't verify certificates
+func newConnection(logger *logrus.Entry, options ConnectionOptions, waitForRateLimit bool) (*connection, error) {
+	if err := ValidateAuthScheme(options.AuthScheme); err != nil {
+		return nil, err
+	}
+	transport, err := newConnectionTransport(options, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	if options.InsecureSkipTlsVerify {
+		if logger == nil {
+			logger = GetProjectLogger()
+		}
+		logger.Warnf("Not verifying the TLS certificates of any server, so downloads could be intercepted. Use a CA bundle instead if the server's certificate is signed by an internal CA.\n")
+	}
+	return &connection{transport: transport, authScheme: options.AuthScheme, waitForRateLimit: waitForRateLimit}, nil
+}
+
+// Return a context whose HTTP requests are sent over the given connection. A nil connection leaves ctx as it is.
+func withConnection(ctx context.Context, conn *connection) context.Context {
+	if conn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, connectionKey{}, conn)
+}
+
+// Return the connection of the given context, or defaultConnection if it has none
+func connectionFrom(ctx context.Context) *connection {
+	if conn, ok := ctx.Value(connectionKey{}).(*connection); ok {
+		return conn
+	}
+	return defaultConnection
+}
+
+// Return a transport that connects as the given options describe, reading the NO_PROXY env var with getenv. Returns nil
+// if the options don't change anything from http.DefaultTransport.
+func newConnectionTransport(options ConnectionOptions, getenv func(string) string) (http.RoundTripper, error) {
+	proxyUrl, err := ParseProxy(options.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTlsConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	if proxyUrl == nil && tlsConfig == nil {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyUrl != nil {
+		transport.Proxy = proxyFunc(proxyUrl, getenv)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// Return a function for http.Transport.Proxy that sends requests through the given proxy, except requests to the hosts
+// excluded by the NO_PROXY env var, as read with getenv, and to localhost
+func proxyFunc(proxyUrl *url.URL, getenv func(string) string) func(*http.Request) (*url.URL, error) {
+	noProxy := getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = getenv("no_proxy")
+	}
+	config := httpproxy.Config{HTTPProxy: proxyUrl.String(), HTTPSProxy: proxyUrl.String(), NoProxy: noProxy}
+	proxyForUrl := config.ProxyFunc()
+
+	return func(request *http.Request) (*url.URL, error) {
+		return proxyForUrl(request.URL)
+	}
+}
+
+// Return the TLS config for the given options, or nil if they don't change the defaults
+func newTlsConfig(options ConnectionOptions) (*tls.Config, error) {
+	if options.CaCert == "" && options.ClientCert == "" && options.ClientKey == "" && !options.InsecureSkipTlsVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: options.InsecureSkipTlsVerify}
+
+	if options.CaCert != "" {
+		caCerts, err := ioutil.ReadFile(options.CaCert)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the CA certificates in %s: %s", options.CaCert, err)
+		}
+		// Trust the internal CA in addition to the usual ones, so that servers with public certificates still work
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("No PEM-encoded CA certificates were found in %s.", options.CaCert)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.ClientCert != "" || options.ClientKey != "" {
+		if options.ClientCert == "" || options.ClientKey == "" {
+			return nil, fmt.Errorf("A client certificate and its private key must be set together.")
+		}
+		cert, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load the client certificate in %s with the private key in %s: %s", options.ClientCert, options.ClientKey, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Return a new HTTP client for requests made with the given context. Every HTTP request fetch makes should be sent with
+// one of these, so that it goes through the proxy and trusts the CA certificates of the context's connection, and so
+// that its response counts against the download budget of its context, if any.
+func newHttpClient(ctx context.Context) *http.Client {
+	return &http.Client{Transport: budgetTransport{connectionFrom(ctx).transport}}
+}
+
+// Return a new HTTP client, as newHttpClient does, that follows redirects without sending the given headers to any other
+// host. Go already drops the Authorization header when it's redirected to another host, but not custom headers that
+// hold credentials, such as an API key.
+func newHttpClientWithoutCrossHostHeaders(ctx context.Context, headers ...string) *http.Client {
+	client := newHttpClient(ctx)
+	client.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		if request.URL.Host != via[0].URL.Host {
+			for _, header := range headers {
+				request.Header.Del(header)
+			}
+		}
+		return nil
+	}
+	return client
+}
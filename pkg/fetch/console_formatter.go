@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ANSI escape codes for the colors ConsoleFormatter uses
+const (
+	ansiReset  = "\x1b[0m"
+	ansiGray   = "\x1b[90m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// The fields every fetch logger sets, which would only add noise to each line of console output
+var consoleHiddenFields = map[string]bool{"binary": true, "version": true}
+
+// A logrus formatter for people watching fetch in a terminal. Each entry is written on one line as aligned columns of
+// the time, the level, and the message, followed by any fields as key=value pairs, e.g.:
+//
+//	14:03:27 INFO  Resolved tag "v0.4.1" to commit 2f1e4c9
+//	14:03:29 WARN  Checksum of release asset /tmp/fetch/fetch_linux_amd64 did not match. Downloading it again, ...
+type ConsoleFormatter struct {
+	Colors bool // Color each level and the field keys with ANSI escape codes
+}
+
+func (formatter *ConsoleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	buf := entry.Buffer
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+
+	buf.WriteString(formatter.colorize(ansiGray, entry.Time.Format("15:04:05")))
+	buf.WriteString(" ")
+	buf.WriteString(formatter.colorize(levelColor(entry.Level), fmt.Sprintf("%-5s", levelLabel(entry.Level))))
+	buf.WriteString(" ")
+	// Most messages end with a newline, which would leave a blank line between entries
+	buf.WriteString(strings.TrimRight(entry.Message, "\n"))
+
+	keys := make([]string, 0, len(entry.Data))
+	for key, value := range entry.Data {
+		if consoleHiddenFields[key] || value == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fmt.Sprint(entry.Data[key])
+		if strings.ContainsAny(value, " \t\n\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		buf.WriteString(" ")
+		buf.WriteString(formatter.colorize(levelColor(entry.Level), key))
+		buf.WriteString("=")
+		buf.WriteString(value)
+	}
+
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Wrap text in the given color, if colors are on
+func (formatter *ConsoleFormatter) colorize(color string, text string) string {
+	if !formatter.Colors {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// Return the label of the given level, which is at most 5 characters long so that the messages line up
+func levelLabel(level logrus.Level) string {
+	if level == logrus.WarnLevel {
+		return "WARN"
+	}
+	return strings.ToUpper(level.String())
+}
+
+func levelColor(level logrus.Level) string {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return ansiGray
+	case logrus.InfoLevel:
+		return ansiCyan
+	case logrus.WarnLevel:
+		return ansiYellow
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return ansiRed
+	default:
+		return ansiBlue
+	}
+}
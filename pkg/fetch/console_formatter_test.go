@@ -0,0 +1,47 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleFormatter(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 3, 1, 14, 3, 27, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		colors   bool
+		level    logrus.Level
+		message  string
+		data     logrus.Fields
+		expected string
+	}{
+		{"info", false, logrus.InfoLevel, "Download complete.\n", nil, "14:03:27 INFO  Download complete.\n"},
+		{"warn is shortened", false, logrus.WarnLevel, "Retrying", nil, "14:03:27 WARN  Retrying\n"},
+		{"error", false, logrus.ErrorLevel, "Failed", nil, "14:03:27 ERROR Failed\n"},
+		{"fields are sorted and quoted", false, logrus.InfoLevel, "Picked", logrus.Fields{"path": "a b", "asset": "x.zip"}, "14:03:27 INFO  Picked asset=x.zip path=\"a b\"\n"},
+		{"logger fields are hidden", false, logrus.InfoLevel, "Picked", logrus.Fields{"binary": "fetch", "version": "", "empty": ""}, "14:03:27 INFO  Picked\n"},
+		{"colors", true, logrus.WarnLevel, "Retrying", logrus.Fields{"try": 2}, "\x1b[90m14:03:27\x1b[0m \x1b[33mWARN \x1b[0m Retrying \x1b[33mtry\x1b[0m=2\n"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := &logrus.Entry{Time: at, Level: tc.level, Message: tc.message, Data: tc.data}
+			formatted, err := (&ConsoleFormatter{Colors: tc.colors}).Format(entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(formatted))
+		})
+	}
+}
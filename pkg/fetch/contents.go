@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// How source paths are downloaded from the repo
+const (
+	DownloadStrategyZipball  = "zipball"  // Download an archive of the whole repo and extract the source paths from it
+	DownloadStrategyContents = "contents" // Walk each source path with the contents API and download its files one by one
+	DownloadStrategyAuto     = "auto"     // The contents API for several source paths below the repo root, or else the zipball
+)
+
+var downloadStrategies = map[string]bool{DownloadStrategyZipball: true, DownloadStrategyContents: true, DownloadStrategyAuto: true}
+
+// Return an error if strategy is not one of the DownloadStrategy constants. An empty strategy means
+// DownloadStrategyZipball.
+func ValidateDownloadStrategy(strategy string) error {
+	if strategy == "" || downloadStrategies[strategy] {
+		return nil
+	}
+
+	var strategies []string
+	for name := range downloadStrategies {
+		strategies = append(strategies, name)
+	}
+	sort.Strings(strategies)
+	return fmt.Errorf("Unknown download strategy \"%s\". Must be one of: %s.", strategy, strings.Join(strategies, ", "))
+}
+
+// Return true if the given source paths should be downloaded with the contents API rather than extracted from the
+// repo's zipball, according to the given strategy
+func usesContentsApi(strategy string, sourcePaths []string, options ExtractOptions) bool {
+	switch strategy {
+	case DownloadStrategyContents:
+		return true
+	case DownloadStrategyAuto:
+		// The contents API knows nothing of file permissions and symlinks, so only the zipball can preserve them
+		if options.PreservePermissions || options.PreserveSymlinks || len(sourcePaths) < 2 {
+			return false
+		}
+		for _, sourcePath := range sourcePaths {
+			if strings.Trim(sourcePath, "/") == "" {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// An entry in a listing of a directory in a repo, as returned by the contents API
+type GitHubContentsEntry struct {
+	Type string `json:"type"` // One of "file", "dir", "symlink", or "submodule"
+	Name string `json:"name"`
+	Path string `json:"path"` // The path of the entry relative to the root of the repo
+}
+
+// List the entries of the directory at the given path in the repo, as of the given git reference. If the path is a
+// file rather than a directory, the only entry returned is the file itself, and the returned bool is false.
+func ListRepoContents(ctx context.Context, repo GitHubRepo, ref string, dirPath string) ([]GitHubContentsEntry, bool, *FetchError) {
+	resp, err := callGitHubApi(ctx, repo, repoFileApiPath(repo, ref, dirPath), map[string]string{})
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	body, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, false, wrapError(readErr)
+	}
+
+	// Directories are listed as an array of entries, and anything else as a single object
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var entries []GitHubContentsEntry
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, false, wrapError(err)
+		}
+		return entries, true, nil
+	}
+
+	var entry GitHubContentsEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, false, wrapError(err)
+	}
+	return []GitHubContentsEntry{entry}, false, nil
+}
+
+// Download the files at each of the given source paths in the repo, as of the given git reference, to the local
+// download path with the contents API, and record them in manifest if it's not nil
+func (fetcher *Fetcher) downloadSourcePathsWithContentsApi(ctx context.Context, sourcePaths []string, ref string, options ExtractOptions, manifest *SbomLiteManifest) error {
+	destPath := fetcher.options.LocalDownloadPath
+
+	for _, sourcePath := range sourcePaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fetcher.logger.Infof("Downloading files from <repo>%s to %s with the contents API ...\n", sourcePath, destPath)
+
+		fileCount, err := downloadRepoContents(ctx, fetcher.repo, ref, sourcePath, destPath, options, manifest)
+		plural := ""
+		if fileCount != 1 {
+			plural = "s"
+		}
+		fetcher.logger.Infof("%d file%s downloaded\n", fileCount, plural)
+		if err != nil {
+			return fmt.Errorf("Error occurred while downloading files from <repo>%s: %s", sourcePath, err)
+		}
+	}
+
+	fetcher.logger.Infof("Download complete.\n")
+	return nil
+}
+
+// Download the files at the given source path in the repo to localPath, walking directories recursively. Just like
+// extracting the source path from the zipball, the files in a directory are written below localPath, while a single
+// file is written to localPath itself. Returns the number of files downloaded.
+func downloadRepoContents(ctx context.Context, repo GitHubRepo, ref string, sourcePath string, localPath string, options ExtractOptions, manifest *SbomLiteManifest) (int, error) {
+	entries, isDir, fetchErr := ListRepoContents(ctx, repo, ref, sourcePath)
+	if fetchErr != nil {
+		return 0, fetchErr
+	}
+
+	root := newDestRoot(localPath, options.FollowDestSymlinks)
+	if !isDir {
+		if err := os.MkdirAll(filepath.Dir(localPath), options.getDirMode()); err != nil {
+			return 0, err
+		}
+		return 1, downloadRepoFileToRoot(ctx, repo, ref, entries[0].Path, root, "", options, manifest)
+	}
+
+	if err := root.MkdirAll("", options.getDirMode()); err != nil {
+		return 0, err
+	}
+	return downloadRepoDir(ctx, repo, ref, entries, root, "", options, map[string]string{}, manifest)
+}
+
+// Download the files in the given directory listing into the directory with the given name in root, and then walk
+// its subdirectories. Submodules are skipped, just as they're missing from the zipball. Each file is written under the
+// name options map it to, and recorded in claimed, keyed by that name. Returns the number of files downloaded.
+func downloadRepoDir(ctx context.Context, repo GitHubRepo, ref string, entries []GitHubContentsEntry, root destRoot, dirName string, options ExtractOptions, claimed map[string]string, manifest *SbomLiteManifest) (int, error) {
+	fileCount := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return fileCount, err
+		}
+
+		name := path.Join(dirName, entry.Name)
+		switch entry.Type {
+		case "file", "symlink":
+			destName, keep := options.destName(name, false)
+			if !keep {
+				continue
+			}
+			if err := claimDestName(claimed, entry.Path, destName); err != nil {
+				return fileCount, err
+			}
+			if len(options.Renames) > 0 {
+				if err := root.MkdirAll(path.Dir(destName), options.getDirMode()); err != nil {
+					return fileCount, err
+				}
+			}
+			if err := downloadRepoFileToRoot(ctx, repo, ref, entry.Path, root, destName, options, manifest); err != nil {
+				return fileCount, err
+			}
+			fileCount++
+		case "dir":
+			subEntries, _, fetchErr := ListRepoContents(ctx, repo, ref, entry.Path)
+			if fetchErr != nil {
+				return fileCount, fetchErr
+			}
+			if destName, keep := options.destName(name, true); keep {
+				if err := root.MkdirAll(destName, options.getDirMode()); err != nil {
+					return fileCount, err
+				}
+			}
+			subCount, err := downloadRepoDir(ctx, repo, ref, subEntries, root, name, options, claimed, manifest)
+			fileCount += subCount
+			if err != nil {
+				return fileCount, err
+			}
+		}
+	}
+	return fileCount, nil
+}
+
+// Download the file at the given path in the repo to the file with the given name in root, streaming it to disk so
+// that large files are never held in memory
+func downloadRepoFileToRoot(ctx context.Context, repo GitHubRepo, ref string, repoFilePath string, root destRoot, name string, options ExtractOptions, manifest *SbomLiteManifest) error {
+	resp, fetchErr := openRepoFile(ctx, repo, ref, repoFilePath)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	defer resp.Body.Close()
+
+	mode := options.FileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("Failed to write file: %s", err)
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return fmt.Errorf("Failed to write file %s: %s", repoFilePath, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("Failed to write file %s: %s", repoFilePath, err)
+	}
+
+	if manifest != nil {
+		return manifest.addSourceFile(repo, ref, repoFilePath, root.localPath(name))
+	}
+	return nil
+}
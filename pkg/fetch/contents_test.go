@@ -0,0 +1,47 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDownloadStrategy(t *testing.T) {
+	t.Parallel()
+
+	for _, strategy := range []string{"", DownloadStrategyZipball, DownloadStrategyContents, DownloadStrategyAuto} {
+		assert.NoError(t, ValidateDownloadStrategy(strategy), strategy)
+	}
+	assert.EqualError(t, ValidateDownloadStrategy("git"), "Unknown download strategy \"git\". Must be one of: auto, contents, zipball.")
+}
+
+func TestUsesContentsApi(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		strategy    string
+		sourcePaths []string
+		options     ExtractOptions
+		expected    bool
+	}{
+		{"default", "", []string{"/modules/a", "/modules/b"}, ExtractOptions{}, false},
+		{"zipball", DownloadStrategyZipball, []string{"/modules/a", "/modules/b"}, ExtractOptions{}, false},
+		{"contents", DownloadStrategyContents, []string{"/"}, ExtractOptions{}, true},
+		{"auto-several-paths", DownloadStrategyAuto, []string{"/modules/a", "/modules/b"}, ExtractOptions{}, true},
+		{"auto-one-path", DownloadStrategyAuto, []string{"/modules/a"}, ExtractOptions{}, false},
+		{"auto-repo-root", DownloadStrategyAuto, []string{"/modules/a", "/"}, ExtractOptions{}, false},
+		{"auto-preserve-permissions", DownloadStrategyAuto, []string{"/modules/a", "/modules/b"}, ExtractOptions{PreservePermissions: true}, false},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, usesContentsApi(tc.strategy, tc.sourcePaths, tc.options))
+		})
+	}
+}
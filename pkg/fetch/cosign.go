@@ -0,0 +1,455 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The OIDs of the certificate extensions Fulcio uses to record the OIDC issuer of the identity a certificate was issued
+// to. The first is deprecated in favor of the second, but older certificates only have the first. For more info, see:
+// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
+var fulcioIssuerV1Oid = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+var fulcioIssuerV2Oid = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+
+// Where "cosign initialize" caches the Sigstore trust root, which is used if no trust root is passed in explicitly
+const sigstoreTrustRootDir = ".sigstore/root/targets"
+const defaultFulcioRootFileName = "fulcio_v1.crt.pem"
+const defaultRekorPublicKeyFileName = "rekor.pub"
+
+// The constraints and trust root used to verify release assets signed keylessly with cosign
+type CosignVerifyOptions struct {
+	// The identity (e.g. an email address or a GitHub Actions workflow URL) the signing certificate must be issued to
+	CertificateIdentity string
+
+	// The OIDC issuer (e.g. https://token.actions.githubusercontent.com) that must have vouched for the identity
+	CertificateOidcIssuer string
+
+	// The paths of the Fulcio root (and intermediate) certificates and the Rekor public key. If empty, the copies
+	// cached by "cosign initialize" are used.
+	FulcioRootPath     string
+	RekorPublicKeyPath string
+
+	// The Rekor instance to look up transparency log entries in, for signatures that aren't published as a bundle
+	RekorUrl string
+}
+
+// The bundle written by "cosign sign-blob --bundle", which holds everything needed to verify a keyless signature
+// offline
+type CosignBundle struct {
+	Base64Signature string             `json:"base64Signature"`
+	Cert            string             `json:"cert"`
+	RekorBundle     *CosignRekorBundle `json:"rekorBundle"`
+}
+
+// The proof that a signature was recorded in the Rekor transparency log
+type CosignRekorBundle struct {
+	SignedEntryTimestamp string             `json:"SignedEntryTimestamp"`
+	Payload              CosignRekorPayload `json:"Payload"`
+}
+
+// The Rekor log entry a Signed Entry Timestamp covers. The fields are in alphabetical order, so marshalling this
+// struct produces the canonical JSON that Rekor signs.
+type CosignRekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// A log entry as returned by the Rekor API. For more info, see: https://www.sigstore.dev/swagger/#/entries
+type rekorLogEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// The parts of a Rekor hashedrekord entry that tie it to a specific signature of a specific artifact
+type rekorHashedRekordEntry struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content string `json:"content"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// The trust root used to verify keyless signatures
+type sigstoreTrustRoot struct {
+	roots          *x509.CertPool
+	intermediates  *x509.CertPool
+	rekorPublicKey *ecdsa.PublicKey
+}
+
+// Verify that each of the given release assets was signed keylessly with cosign by the identity and issuer in options.
+// For each asset, the release must contain either a cosign bundle (<asset>.bundle), which is verified fully offline
+// including its Rekor transparency log entry, or a signature and certificate (<asset>.sig plus <asset>.pem or
+// <asset>.crt), in which case the transparency log entry is looked up in Rekor.
+func verifyReleaseAssetsWithCosign(ctx context.Context, logger *logrus.Entry, repo GitHubRepo, tag string, assetPaths []string, options CosignVerifyOptions) error {
+	trustRoot, err := loadSigstoreTrustRoot(options)
+	if err != nil {
+		return err
+	}
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, repo, tag)
+	if fetchErr != nil {
+		return fetchErr
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-cosign")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	downloadAsset := func(name string) ([]byte, bool, error) {
+		for _, asset := range release.Assets {
+			if asset.Name == name {
+				path := filepath.Join(tempDir, asset.Name)
+				if fetchErr := downloadReleaseAsset(ctx, repo, &asset, path, false); fetchErr != nil {
+					return nil, true, fetchErr
+				}
+				contents, err := ioutil.ReadFile(path)
+				return contents, true, err
+			}
+		}
+		return nil, false, nil
+	}
+
+	for _, assetPath := range assetPaths {
+		assetName := filepath.Base(assetPath)
+		if isSignatureFile(assetName) {
+			continue
+		}
+
+		asset, err := ioutil.ReadFile(assetPath)
+		if err != nil {
+			return err
+		}
+
+		bundleJson, found, err := downloadAsset(assetName + ".bundle")
+		if err != nil {
+			return err
+		}
+		if found {
+			var bundle CosignBundle
+			if err := json.Unmarshal(bundleJson, &bundle); err != nil {
+				return fmt.Errorf("Failed to parse cosign bundle %s.bundle: %s", assetName, err)
+			}
+			if err := verifyCosignBundle(asset, bundle, trustRoot, options); err != nil {
+				return newError(signatureDoesNotMatch, fmt.Sprintf("cosign verification of release asset %s failed: %s", assetPath, err))
+			}
+			logger.Infof("Release asset cosign signature and transparency log entry verified for %s\n", assetPath)
+			continue
+		}
+
+		signature, found, err := downloadAsset(assetName + ".sig")
+		if err != nil {
+			return err
+		}
+		if !found {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("Could not find a cosign bundle or signature for release asset %s in release %s. Expected an asset named %s.bundle or %s.sig.", assetName, tag, assetName, assetName))
+		}
+
+		var certificate []byte
+		for _, ext := range []string{".pem", ".crt"} {
+			if certificate, found, err = downloadAsset(assetName + ext); err != nil {
+				return err
+			} else if found {
+				break
+			}
+		}
+		if !found {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("Could not find the signing certificate for release asset %s in release %s. Expected an asset named %s.pem or %s.crt.", assetName, tag, assetName, assetName))
+		}
+
+		bundle := CosignBundle{Base64Signature: string(bytes.TrimSpace(signature)), Cert: string(certificate)}
+		rekorBundle, err := lookupRekorBundle(ctx, options.RekorUrl, asset, bundle.Base64Signature, trustRoot.rekorPublicKey)
+		if err != nil {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("cosign verification of release asset %s failed: %s", assetPath, err))
+		}
+		bundle.RekorBundle = rekorBundle
+
+		if err := verifyCosignBundle(asset, bundle, trustRoot, options); err != nil {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("cosign verification of release asset %s failed: %s", assetPath, err))
+		}
+		logger.Infof("Release asset cosign signature and transparency log entry verified for %s\n", assetPath)
+	}
+
+	return nil
+}
+
+// Verify the given keyless cosign signature of asset: the signing certificate must chain to the Fulcio root and be
+// issued to the expected identity by the expected issuer, the signature must match the asset, and the Rekor log entry
+// must be signed by Rekor, match the signature, and have been made while the certificate was valid.
+func verifyCosignBundle(asset []byte, bundle CosignBundle, trustRoot sigstoreTrustRoot, options CosignVerifyOptions) error {
+	cert, err := parseCosignCertificate(bundle.Cert)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64 encoded: %s", err)
+	}
+
+	if bundle.RekorBundle == nil {
+		return fmt.Errorf("bundle has no Rekor transparency log entry")
+	}
+	if err := verifyRekorBundle(*bundle.RekorBundle, asset, signature, trustRoot.rekorPublicKey); err != nil {
+		return err
+	}
+
+	// Fulcio certificates are only valid for a few minutes, so they're checked against the time the signature was
+	// recorded in the transparency log rather than the current time
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         trustRoot.roots,
+		Intermediates: trustRoot.intermediates,
+		CurrentTime:   time.Unix(bundle.RekorBundle.Payload.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("signing certificate is not trusted: %s", err)
+	}
+
+	if err := checkCertificateIdentity(cert, options); err != nil {
+		return err
+	}
+
+	return verifySignatureWithPublicKey(cert.PublicKey, asset, signature)
+}
+
+// Verify the Signed Entry Timestamp of the given Rekor bundle, and that the log entry it covers is for the given
+// signature of the given asset
+func verifyRekorBundle(rekorBundle CosignRekorBundle, asset []byte, signature []byte, rekorPublicKey *ecdsa.PublicKey) error {
+	set, err := base64.StdEncoding.DecodeString(rekorBundle.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("Rekor signed entry timestamp is not base64 encoded: %s", err)
+	}
+
+	canonicalPayload, err := json.Marshal(rekorBundle.Payload)
+	if err != nil {
+		return err
+	}
+	payloadDigest := sha256.Sum256(canonicalPayload)
+	if !ecdsa.VerifyASN1(rekorPublicKey, payloadDigest[:], set) {
+		return fmt.Errorf("Rekor signed entry timestamp is invalid")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(rekorBundle.Payload.Body)
+	if err != nil {
+		return fmt.Errorf("Rekor entry body is not base64 encoded: %s", err)
+	}
+	var entry rekorHashedRekordEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return fmt.Errorf("Failed to parse Rekor entry: %s", err)
+	}
+
+	assetDigest := sha256.Sum256(asset)
+	if entry.Kind != "hashedrekord" || entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(assetDigest[:]) {
+		return fmt.Errorf("Rekor entry is not for this release asset")
+	}
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(signature) {
+		return fmt.Errorf("Rekor entry is not for this signature")
+	}
+	return nil
+}
+
+// Look up the Rekor transparency log entry for the given signature of the given asset, and return it in the same form
+// as the Rekor bundle cosign writes with --bundle
+func lookupRekorBundle(ctx context.Context, rekorUrl string, asset []byte, base64Signature string, rekorPublicKey *ecdsa.PublicKey) (*CosignRekorBundle, error) {
+	signature, err := base64.StdEncoding.DecodeString(base64Signature)
+	if err != nil {
+		return nil, fmt.Errorf("signature is not base64 encoded: %s", err)
+	}
+
+	assetDigest := sha256.Sum256(asset)
+	query, err := json.Marshal(map[string]string{"hash": "sha256:" + hex.EncodeToString(assetDigest[:])})
+	if err != nil {
+		return nil, err
+	}
+
+	var uuids []string
+	if err := callRekorApi(ctx, rekorUrl+"/api/v1/index/retrieve", "POST", query, &uuids); err != nil {
+		return nil, err
+	}
+
+	for _, uuid := range uuids {
+		entries := map[string]rekorLogEntry{}
+		if err := callRekorApi(ctx, rekorUrl+"/api/v1/log/entries/"+uuid, "GET", nil, &entries); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			rekorBundle := CosignRekorBundle{
+				SignedEntryTimestamp: entry.Verification.SignedEntryTimestamp,
+				Payload: CosignRekorPayload{
+					Body:           entry.Body,
+					IntegratedTime: entry.IntegratedTime,
+					LogID:          entry.LogID,
+					LogIndex:       entry.LogIndex,
+				},
+			}
+			// The same asset may have been signed more than once, so find the entry for this signature
+			if verifyRekorBundle(rekorBundle, asset, signature, rekorPublicKey) == nil {
+				return &rekorBundle, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no entry for this signature was found in the Rekor transparency log at %s", rekorUrl)
+}
+
+// Call the Rekor API at the given URL and decode the JSON response into result
+func callRekorApi(ctx context.Context, url string, method string, body []byte, result interface{}) error {
+	resp, fetchErr := callGitHubApiRawWithBody(ctx, url, method, "", map[string]string{"Content-Type": "application/json", "Accept": "application/json"}, body)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Check that the given Fulcio certificate was issued to the identity and by the issuer in options
+func checkCertificateIdentity(cert *x509.Certificate, options CosignVerifyOptions) error {
+	var identities []string
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	identityMatches := false
+	for _, identity := range identities {
+		if identity == options.CertificateIdentity {
+			identityMatches = true
+		}
+	}
+	if !identityMatches {
+		return fmt.Errorf("signing certificate was issued to %v, not %s", identities, options.CertificateIdentity)
+	}
+
+	issuer := getCertificateOidcIssuer(cert)
+	if issuer != options.CertificateOidcIssuer {
+		return fmt.Errorf("signing certificate identity was issued by %q, not %s", issuer, options.CertificateOidcIssuer)
+	}
+	return nil
+}
+
+// Return the OIDC issuer recorded in the given Fulcio certificate, or an empty string if there isn't one
+func getCertificateOidcIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(fulcioIssuerV2Oid) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+	// The deprecated extension holds the raw issuer rather than a DER-encoded string
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(fulcioIssuerV1Oid) {
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+// Parse a signing certificate as written by cosign, which base64 encodes the PEM in bundles (and in older versions, in
+// --output-certificate files as well)
+func parseCosignCertificate(value string) (*x509.Certificate, error) {
+	certPem := []byte(value)
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(certPem))); err == nil {
+		certPem = decoded
+	}
+
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse signing certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// Load the Fulcio certificates and Rekor public key to verify keyless signatures against
+func loadSigstoreTrustRoot(options CosignVerifyOptions) (sigstoreTrustRoot, error) {
+	fulcioRootPath := options.FulcioRootPath
+	rekorPublicKeyPath := options.RekorPublicKeyPath
+	if fulcioRootPath == "" || rekorPublicKeyPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return sigstoreTrustRoot{}, err
+		}
+		if fulcioRootPath == "" {
+			fulcioRootPath = filepath.Join(homeDir, sigstoreTrustRootDir, defaultFulcioRootFileName)
+		}
+		if rekorPublicKeyPath == "" {
+			rekorPublicKeyPath = filepath.Join(homeDir, sigstoreTrustRootDir, defaultRekorPublicKeyFileName)
+		}
+	}
+
+	trustRoot := sigstoreTrustRoot{roots: x509.NewCertPool(), intermediates: x509.NewCertPool()}
+
+	fulcioPem, err := ioutil.ReadFile(fulcioRootPath)
+	if err != nil {
+		return trustRoot, fmt.Errorf("Failed to read Fulcio root certificate: %s. Run \"cosign initialize\" or pass --cosign-fulcio-root.", err)
+	}
+	for block, rest := pem.Decode(fulcioPem); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return trustRoot, fmt.Errorf("Failed to parse Fulcio certificate in %s: %s", fulcioRootPath, err)
+		}
+		// Self-signed certificates are roots, and anything else is an intermediate
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			trustRoot.roots.AddCert(cert)
+		} else {
+			trustRoot.intermediates.AddCert(cert)
+		}
+	}
+
+	rekorPem, err := ioutil.ReadFile(rekorPublicKeyPath)
+	if err != nil {
+		return trustRoot, fmt.Errorf("Failed to read Rekor public key: %s. Run \"cosign initialize\" or pass --cosign-rekor-public-key.", err)
+	}
+	block, _ := pem.Decode(rekorPem)
+	if block == nil {
+		return trustRoot, fmt.Errorf("The Rekor public key %s is not PEM encoded", rekorPublicKeyPath)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return trustRoot, fmt.Errorf("Failed to parse Rekor public key %s: %s", rekorPublicKeyPath, err)
+	}
+	rekorPublicKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return trustRoot, fmt.Errorf("The Rekor public key %s is not an ECDSA key", rekorPublicKeyPath)
+	}
+	trustRoot.rekorPublicKey = rekorPublicKey
+
+	return trustRoot, nil
+}
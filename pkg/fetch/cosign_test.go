@@ -0,0 +1,262 @@
+package fetch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCosignIdentity = "releases@example.com"
+const testCosignIssuer = "https://accounts.example.com"
+
+// A fake Sigstore deployment: a Fulcio root CA, a Rekor signing key, and a certificate issued to testCosignIdentity
+type testSigstore struct {
+	trustRoot sigstoreTrustRoot
+	rekorKey  *ecdsa.PrivateKey
+	leafKey   *ecdsa.PrivateKey
+	leafPem   []byte
+	issuedAt  time.Time
+}
+
+func newTestSigstore(t *testing.T) testSigstore {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Hour)
+
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio-root"},
+		NotBefore:             issuedAt.Add(-24 * time.Hour),
+		NotAfter:              issuedAt.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDer, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	rootCert, err := x509.ParseCertificate(rootDer)
+	require.NoError(t, err)
+
+	issuerExtension, err := asn1.Marshal(testCosignIssuer)
+	require.NoError(t, err)
+
+	// Like Fulcio certificates, the leaf is only valid for a few minutes
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       issuedAt,
+		NotAfter:        issuedAt.Add(10 * time.Minute),
+		EmailAddresses:  []string{testCosignIdentity},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerV2Oid, Value: issuerExtension}},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, leafTemplate, rootCert, &leafKey.PublicKey, rootKey)
+	require.NoError(t, err)
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	return testSigstore{
+		trustRoot: sigstoreTrustRoot{roots: roots, intermediates: x509.NewCertPool(), rekorPublicKey: &rekorKey.PublicKey},
+		rekorKey:  rekorKey,
+		leafKey:   leafKey,
+		leafPem:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDer}),
+		issuedAt:  issuedAt,
+	}
+}
+
+// Sign the given asset with the leaf certificate and record the signature in the fake Rekor, returning the bundle cosign
+// would write with --bundle
+func (sigstore testSigstore) sign(t *testing.T, asset []byte) CosignBundle {
+	digest := sha256.Sum256(asset)
+	signature, err := ecdsa.SignASN1(rand.Reader, sigstore.leafKey, digest[:])
+	require.NoError(t, err)
+
+	var entry rekorHashedRekordEntry
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest[:])
+	entry.Spec.Signature.Content = base64.StdEncoding.EncodeToString(signature)
+	body, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	payload := CosignRekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: sigstore.issuedAt.Add(time.Minute).Unix(),
+		LogID:          "test-log",
+		LogIndex:       42,
+	}
+	canonicalPayload, err := json.Marshal(payload)
+	require.NoError(t, err)
+	payloadDigest := sha256.Sum256(canonicalPayload)
+	set, err := ecdsa.SignASN1(rand.Reader, sigstore.rekorKey, payloadDigest[:])
+	require.NoError(t, err)
+
+	return CosignBundle{
+		Base64Signature: base64.StdEncoding.EncodeToString(signature),
+		Cert:            base64.StdEncoding.EncodeToString(sigstore.leafPem),
+		RekorBundle: &CosignRekorBundle{
+			SignedEntryTimestamp: base64.StdEncoding.EncodeToString(set),
+			Payload:              payload,
+		},
+	}
+}
+
+// Like sign, but record the signature in the fake Rekor at the given time
+func (sigstore testSigstore) signAt(t *testing.T, asset []byte, integratedTime time.Time) CosignBundle {
+	sigstore.issuedAt = integratedTime.Add(-time.Minute)
+	return sigstore.sign(t, asset)
+}
+
+func TestVerifyCosignBundle(t *testing.T) {
+	t.Parallel()
+
+	sigstore := newTestSigstore(t)
+	asset := []byte("release asset contents")
+	validOptions := CosignVerifyOptions{CertificateIdentity: testCosignIdentity, CertificateOidcIssuer: testCosignIssuer}
+
+	cases := []struct {
+		name        string
+		asset       []byte
+		modify      func(bundle *CosignBundle)
+		options     CosignVerifyOptions
+		expectedErr string
+	}{
+		{"valid", asset, func(bundle *CosignBundle) {}, validOptions, ""},
+		{"wrong-identity", asset, func(bundle *CosignBundle) {}, CosignVerifyOptions{CertificateIdentity: "attacker@example.com", CertificateOidcIssuer: testCosignIssuer}, "signing certificate was issued to"},
+		{"wrong-issuer", asset, func(bundle *CosignBundle) {}, CosignVerifyOptions{CertificateIdentity: testCosignIdentity, CertificateOidcIssuer: "https://other.example.com"}, "signing certificate identity was issued by"},
+		{"tampered-asset", []byte("tampered contents"), func(bundle *CosignBundle) {}, validOptions, "Rekor entry is not for this release asset"},
+		{"bad-set", asset, func(bundle *CosignBundle) { bundle.RekorBundle.Payload.LogIndex++ }, validOptions, "Rekor signed entry timestamp is invalid"},
+		{"no-rekor-bundle", asset, func(bundle *CosignBundle) { bundle.RekorBundle = nil }, validOptions, "no Rekor transparency log entry"},
+		{"logged-after-certificate-expired", asset, func(bundle *CosignBundle) {
+			*bundle = sigstore.signAt(t, asset, sigstore.issuedAt.Add(time.Hour))
+		}, validOptions, "signing certificate is not trusted"},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bundle := sigstore.sign(t, asset)
+			tc.modify(&bundle)
+
+			err := verifyCosignBundle(tc.asset, bundle, sigstore.trustRoot, tc.options)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestLookupRekorBundle(t *testing.T) {
+	t.Parallel()
+
+	sigstore := newTestSigstore(t)
+	asset := []byte("release asset contents")
+	bundle := sigstore.sign(t, asset)
+
+	// A second signature of the same asset, which the lookup has to skip over
+	otherBundle := sigstore.sign(t, asset)
+
+	assetDigest := sha256.Sum256(asset)
+	entries := map[string]CosignRekorBundle{"other-uuid": *otherBundle.RekorBundle, "uuid": *bundle.RekorBundle}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v1/index/retrieve":
+			var query map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+			if query["hash"] != "sha256:"+hex.EncodeToString(assetDigest[:]) {
+				fmt.Fprint(w, "[]")
+				return
+			}
+			fmt.Fprint(w, `["other-uuid", "uuid"]`)
+		case r.Method == "GET" && len(r.URL.Path) > len("/api/v1/log/entries/"):
+			uuid := r.URL.Path[len("/api/v1/log/entries/"):]
+			rekorBundle, ok := entries[uuid]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			entry := rekorLogEntry{
+				Body:           rekorBundle.Payload.Body,
+				IntegratedTime: rekorBundle.Payload.IntegratedTime,
+				LogID:          rekorBundle.Payload.LogID,
+				LogIndex:       rekorBundle.Payload.LogIndex,
+			}
+			entry.Verification.SignedEntryTimestamp = rekorBundle.SignedEntryTimestamp
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]rekorLogEntry{uuid: entry}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	rekorBundle, err := lookupRekorBundle(context.Background(), server.URL, asset, bundle.Base64Signature, sigstore.trustRoot.rekorPublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, *bundle.RekorBundle, *rekorBundle)
+
+	_, err = lookupRekorBundle(context.Background(), server.URL, []byte("some other asset"), bundle.Base64Signature, sigstore.trustRoot.rekorPublicKey)
+	assert.Error(t, err)
+}
+
+func TestGetCertificateOidcIssuer(t *testing.T) {
+	t.Parallel()
+
+	v2Value, err := asn1.Marshal(testCosignIssuer)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name       string
+		extensions []pkix.Extension
+		expected   string
+	}{
+		{"v2", []pkix.Extension{{Id: fulcioIssuerV2Oid, Value: v2Value}}, testCosignIssuer},
+		{"v1", []pkix.Extension{{Id: fulcioIssuerV1Oid, Value: []byte(testCosignIssuer)}}, testCosignIssuer},
+		{"v2-preferred", []pkix.Extension{{Id: fulcioIssuerV1Oid, Value: []byte("https://old.example.com")}, {Id: fulcioIssuerV2Oid, Value: v2Value}}, testCosignIssuer},
+		{"none", nil, ""},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cert := &x509.Certificate{Extensions: tc.extensions}
+			assert.Equal(t, tc.expected, getCertificateOidcIssuer(cert))
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The places a GitHub token can be looked up in when none is given, the way go-getter and git itself find credentials
+const (
+	CredentialSourceNetrc = "netrc"
+	CredentialSourceGit   = "git"
+)
+
+// The env var that overrides the path of the netrc file, as it does for curl and the go command
+const envVarNetrc = "NETRC"
+
+// Return an error if any of sources is not one of the CredentialSource constants
+func ValidateCredentialSources(sources []string) error {
+	for _, source := range sources {
+		switch source {
+		case CredentialSourceNetrc, CredentialSourceGit:
+		default:
+			return fmt.Errorf("Unknown credential source \"%s\". Must be one of: %s, %s.", source, CredentialSourceGit, CredentialSourceNetrc)
+		}
+	}
+	return nil
+}
+
+// Look up a GitHub token for the given host, such as github.com, in each of the given credential sources in turn, and
+// return the first one found, or an empty string if none of them has one. This lets fetch use the credentials git
+// already uses on a developer's laptop, rather than a token that has to be exported first.
+func LookupCredential(ctx context.Context, logger *logrus.Entry, host string, sources []string) (string, error) {
+	for _, source := range sources {
+		var token string
+		var err error
+		switch source {
+		case CredentialSourceNetrc:
+			token, err = readNetrcPassword(netrcPath(), host)
+		case CredentialSourceGit:
+			token, err = gitCredentialFill(ctx, logger, host)
+		default:
+			return "", ValidateCredentialSources([]string{source})
+		}
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			logger.Debugf("Using the GitHub token for %s from %s", host, source)
+			return token, nil
+		}
+		logger.Debugf("Found no GitHub token for %s in %s", host, source)
+	}
+	return "", nil
+}
+
+// Return the path of the netrc file: $NETRC if it's set, or else ~/.netrc (~/_netrc on Windows, as curl and git use)
+func netrcPath() string {
+	if path := os.Getenv(envVarNetrc); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		path := filepath.Join(home, "_netrc")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// Return the password of the entry for the given host in the netrc file at the given path, or of its default entry if
+// it has none, or an empty string if the file doesn't exist or has no such entry
+func readNetrcPassword(path string, host string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error reading netrc file %s: %s", path, err)
+	}
+
+	entries, err := parseNetrc(string(contents))
+	if err != nil {
+		return "", fmt.Errorf("Error parsing netrc file %s: %s", path, err)
+	}
+	var defaultPassword string
+	for _, entry := range entries {
+		if entry.machine == host {
+			return entry.password, nil
+		}
+		if entry.isDefault && defaultPassword == "" {
+			defaultPassword = entry.password
+		}
+	}
+	return defaultPassword, nil
+}
+
+// A machine or default entry of a netrc file
+type netrcEntry struct {
+	machine   string
+	isDefault bool
+	login     string
+	password  string
+}
+
+// Parse the entries of a netrc file, per the format documented in ftp(1). Macro definitions are skipped, as fetch has
+// no use for them.
+func parseNetrc(contents string) ([]netrcEntry, error) {
+	var entries []netrcEntry
+	var current *netrcEntry
+	inMacro := false
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// A macro definition runs until the next blank line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine", "default":
+				entries = append(entries, netrcEntry{isDefault: fields[i] == "default"})
+				current = &entries[len(entries)-1]
+				if current.isDefault {
+					continue
+				}
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+				continue
+			case "login", "password", "account":
+			default:
+				return nil, fmt.Errorf("unexpected token \"%s\"", fields[i])
+			}
+
+			if i+1 == len(fields) {
+				return nil, fmt.Errorf("missing value for \"%s\"", fields[i])
+			}
+			if current == nil {
+				return nil, fmt.Errorf("\"%s\" must follow a machine or default entry", fields[i])
+			}
+			i++
+			switch fields[i-1] {
+			case "machine":
+				current.machine = fields[i]
+			case "login":
+				current.login = fields[i]
+			case "password":
+				current.password = fields[i]
+			}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Ask git for the password it would use for the given host over HTTPS, with "git credential fill", which consults every
+// credential helper git is configured with, such as the macOS keychain, the Git Credential Manager, or "gh auth
+// setup-git". git is told never to prompt, so an empty string is returned if no helper has a credential for the host,
+// or if git isn't installed.
+func gitCredentialFill(ctx context.Context, logger *logrus.Entry, host string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		logger.Debugf("git credential fill found no credential for %s: %s %s", host, err, strings.TrimSpace(stderr.String()))
+		return "", nil
+	}
+
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "password=") {
+			return strings.TrimPrefix(line, "password="), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,113 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadNetrcPassword(t *testing.T) {
+	t.Parallel()
+
+	netrc := `# Credentials for git and curl
+machine github.com
+  login octocat
+  password ghp_github
+
+machine ghe.mycompany.com login octocat password ghp_ghes
+
+macdef init
+password not-a-token
+machine not-a-machine
+
+default login anonymous password ghp_default
+`
+	netrcPath := filepath.Join(t.TempDir(), ".netrc")
+	require.NoError(t, ioutil.WriteFile(netrcPath, []byte(netrc), 0600))
+
+	testCases := []struct {
+		host     string
+		expected string
+	}{
+		{"github.com", "ghp_github"},
+		{"ghe.mycompany.com", "ghp_ghes"},
+		{"not-a-machine", "ghp_default"},
+		{"gitlab.com", "ghp_default"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.host, func(t *testing.T) {
+			t.Parallel()
+			password, err := readNetrcPassword(netrcPath, tc.host)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, password)
+		})
+	}
+}
+
+func TestReadNetrcPasswordMissingOrInvalid(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	password, err := readNetrcPassword(filepath.Join(tmpDir, "does-not-exist"), "github.com")
+	require.NoError(t, err)
+	assert.Empty(t, password)
+
+	noDefaultPath := filepath.Join(tmpDir, "no-default")
+	require.NoError(t, ioutil.WriteFile(noDefaultPath, []byte("machine github.com password ghp_github\n"), 0600))
+	password, err = readNetrcPassword(noDefaultPath, "ghe.mycompany.com")
+	require.NoError(t, err)
+	assert.Empty(t, password)
+
+	invalidPath := filepath.Join(tmpDir, "invalid")
+	require.NoError(t, ioutil.WriteFile(invalidPath, []byte("machine github.com password\n"), 0600))
+	_, err = readNetrcPassword(invalidPath, "github.com")
+	assert.Error(t, err)
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestLookupCredential(t *testing.T) {
+	tmpDir := t.TempDir()
+	netrcPath := filepath.Join(tmpDir, ".netrc")
+	require.NoError(t, ioutil.WriteFile(netrcPath, []byte("machine github.com password ghp_netrc\n"), 0600))
+	gitConfigPath := filepath.Join(tmpDir, "gitconfig")
+	gitConfig := "[credential]\n\thelper = \"!f() { test \\\"$1\\\" = get && echo username=octocat && echo password=ghp_git; }; f\"\n"
+	require.NoError(t, ioutil.WriteFile(gitConfigPath, []byte(gitConfig), 0600))
+
+	t.Setenv(envVarNetrc, netrcPath)
+	t.Setenv("GIT_CONFIG_GLOBAL", gitConfigPath)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	testCases := []struct {
+		name     string
+		host     string
+		sources  []string
+		expected string
+	}{
+		{"netrc", "github.com", []string{CredentialSourceNetrc}, "ghp_netrc"},
+		{"git", "github.com", []string{CredentialSourceGit}, "ghp_git"},
+		{"netrc-first", "github.com", []string{CredentialSourceNetrc, CredentialSourceGit}, "ghp_netrc"},
+		{"git-first", "github.com", []string{CredentialSourceGit, CredentialSourceNetrc}, "ghp_git"},
+		{"netrc-falls-through-to-git", "ghe.mycompany.com", []string{CredentialSourceNetrc, CredentialSourceGit}, "ghp_git"},
+		{"not-found", "ghe.mycompany.com", []string{CredentialSourceNetrc}, ""},
+		{"no-sources", "github.com", nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := LookupCredential(context.Background(), GetProjectLogger(), tc.host, tc.sources)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, token)
+		})
+	}
+
+	_, err := LookupCredential(context.Background(), GetProjectLogger(), "github.com", []string{"keychain"})
+	assert.Error(t, err)
+}
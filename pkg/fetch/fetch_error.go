@@ -1,4 +1,4 @@
-package main
+package fetch
 
 import "fmt"
 
@@ -32,3 +32,17 @@ func wrapError(err error) *FetchError {
 		err:       err,
 	}
 }
+
+// An error with a friendlier message than the FetchError that caused it, which can still be found with errors.As
+type explainedFetchError struct {
+	message string
+	cause   *FetchError
+}
+
+func (e *explainedFetchError) Error() string {
+	return e.message
+}
+
+func (e *explainedFetchError) Unwrap() error {
+	return e.cause
+}
@@ -1,12 +1,16 @@
-package main
+package fetch
 
 const invalidTagConstraintExpression = 100
 
 const githubRepoUrlMalformedOrNotParseable = 300
 
 const invalidGithubTokenOrAccessDenied = 401
+const accessForbidden = 403
 const repoDoesNotExistOrAccessDenied = 404
+const unsupportedMediaType = 415
+const githubApiRateLimitExceeded = 429
 
 const failedToDownloadFile = 500
 const checksumDoesNotMatch = 510
 const errorWhileComputingChecksum = 520
+const signatureDoesNotMatch = 530
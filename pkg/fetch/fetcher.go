@@ -0,0 +1,1705 @@
+// Package fetch downloads files, folders, and release assets from a specific git commit, branch, or tag of public and
+// private GitHub repos. It is the library behind the fetch CLI, so other tools can fetch from GitHub, resolve tag
+// constraints, and verify checksums and signatures exactly as the CLI does.
+//
+// To fetch everything described by a set of Options in one go, as the CLI does:
+//
+//	fetcher, err := fetch.NewFetcher(fetch.Options{
+//		RepoUrl:           "https://github.com/gruntwork-io/fetch",
+//		TagConstraint:     "~> 0.4",
+//		ReleaseAsset:      "fetch_linux_amd64",
+//		LocalDownloadPath: "/tmp/fetch",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := fetcher.Fetch(ctx, os.Stdout)
+//
+// The individual steps, such as ResolveTag and DownloadReleaseAssets, are also available on Fetcher.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The number of release assets downloaded at once if Options.MaxConcurrentDownloads is not set
+const DefaultMaxConcurrentDownloads = 4
+
+// The LocalDownloadPath that streams the single matching release asset to the writer passed to Fetch, rather than
+// downloading it to disk
+const StdoutDownloadPath = "-"
+
+// The options for a single fetch. Each field corresponds to the fetch CLI flag of the same name; see the README for
+// full details.
+type Options struct {
+	RepoUrl                  string
+	Source                   string // One of the Source constants. What kind of repo RepoUrl is. Empty means SourceAuto.
+	Url                      string // Instead of a repo, download the file at this plain HTTP(S) URL, e.g. from a vendor's download site
+	GitRef                   string
+	CommitSha                string
+	BranchName               string
+	TagConstraint            string
+	Channel                  string // One of the Channel constants. Narrows the tags TagConstraint is matched against.
+	IncludePrereleases       bool   // Match pre-release versions, e.g. v1.2.3-rc.1, against TagConstraint by their version core
+	IncludeDrafts            bool   // Also match the tags of draft GitHub releases, which GithubToken must have push access to see
+	LooseSemver              bool
+	TagPrefix                string // Only match tags with this prefix, e.g. modules/vpc/, against TagConstraint, with the prefix stripped
+	ResolveRef               bool   // Look up the full SHA of the commit that's downloaded from, and report it in Result.CommitSha
+	ExpectedCommitSha        string // If set, fail unless the resolved tag points to the commit with this full SHA
+	RequireSignedRef         bool   // Fail unless the tag, or the commit that's downloaded from, has a signature GitHub verified
+	SignedRefKey             string // An armored PGP public key that the RequireSignedRef signature must be made with, checked locally
+	GithubToken              string
+	SourcePaths              []string
+	SourceFiles              []string
+	DownloadStrategy         string // One of the DownloadStrategy constants. How SourcePaths are downloaded.
+	ReleaseAsset             string // May use the placeholders in ReleaseAssetTemplateVars, e.g. tool_{{.OS}}_{{.Arch}}
+	AutoAsset                bool   // Instead of ReleaseAsset, download the one asset whose name best matches OS and Arch
+	OS                       string // The {{.OS}} in ReleaseAsset, and the OS AutoAsset looks for. Defaults to runtime.GOOS.
+	Arch                     string // The {{.Arch}} in ReleaseAsset, and the arch AutoAsset looks for. Defaults to runtime.GOARCH.
+	ReleaseAssetPickBy       string // One of the AssetOrder constants, or empty to download every matching asset
+	AllReleaseAssets         bool   // Download every release asset, regardless of ReleaseAsset
+	MinAssetSize             string // Skip matching release assets smaller than this size (e.g. "1KB"). See ParseAssetSize.
+	MaxAssetSize             string // Skip matching release assets larger than this size (e.g. "500MiB"). See ParseAssetSize.
+	JoinParts                bool   // Join the parts of release assets split into several files, e.g. tool.zip.001 and tool.zip.002
+	ReleaseAssetChecksums    map[string]bool
+	ReleaseAssetChecksumAlgo string
+	ReleaseAssetChecksumFile string
+	UpgradeWeakChecksums     bool // When a release asset matches an md5 or sha1 checksum, log its sha256 checksum to replace it with
+	VerifyWithRepoKey        bool
+	CosignVerify             bool
+	CosignVerifyOptions      CosignVerifyOptions
+	PackageSigningKey        string // An armored PGP public key that .deb and .rpm release assets must carry an embedded signature from
+	CheckImmutableTag        bool   // Warn if the tag, or its release assets, could be replaced upstream
+	RequireImmutableTag      bool   // Fail, instead of warning, if the tag or its release assets could be replaced upstream
+	Stdout                   bool
+	LocalDownloadPath        string    // Or StdoutDownloadPath, to stream a single release asset rather than download it
+	AssetSink                AssetSink // If set, stream release assets to the writers it returns rather than download them
+	VerifyBeforeStdout       bool      // When streaming to stdout, buffer and verify the release asset before writing any of it
+	GithubApiVersion         string
+	GhesVersion              string
+	WithProgress             bool
+	MaxConcurrentDownloads   int // If not positive, DefaultMaxConcurrentDownloads is used
+	DownloadConnections      int // Download each large release asset over this many connections at once. If not above 1, one is used.
+	WaitForRateLimit         bool
+	Connection               ConnectionOptions
+	ArtifactRepoAuth         ArtifactRepoAuth // The credentials to read an Artifactory or Nexus repository with
+	GitlabToken              string           // The access token to read a GitLab package registry with. Defaults to the job token in GitLab CI.
+	BitbucketToken           string           // The HTTP access token or personal access token to read a Bitbucket Server repo with
+	ArchiveCacheDir          string           // If set, repo archives are cached here by commit SHA, and release assets by their contents
+	LinkMode                 string           // One of the LinkMode constants. How release assets are placed from the cache.
+	Unpack                   bool
+	UnpackInclude            []string // Globs (e.g. "bin/*") of the archive members Unpack writes. If empty, it writes them all.
+	UnpackBinary             bool     // Unpack only the binary found in each archive, as Install does, named after BinaryName
+	KeepArchive              bool
+	Install                  bool   // Install the binary in the single matched release asset into InstallDir
+	InstallDir               string // Required with Install. Usually a directory on the PATH.
+	BinaryName               string // The name to install or unpack the binary as. Defaults to its own name without platform suffixes.
+	PreservePermissions      bool
+	PreserveSymlinks         bool
+	StripComponents          int      // Strip this many leading path components from the files extracted from the repo
+	Flatten                  bool     // Write the files extracted from the repo under their base names, without their directories
+	Renames                  []string // Renames of the form "src=dest" of downloaded files and release assets. See ParseRenames.
+	FollowDestSymlinks       bool
+	DirMode                  string
+	FileMode                 string
+	PublishS3                string
+	PublishS3Region          string
+	PublishS3UrlExpiry       time.Duration
+	EmitSbomLite             string
+	SbomLiteSigningKey       string
+	RecordChecksums          bool          // Record the SHA256 checksum of each downloaded file in the Result
+	AuditLog                 string        // If set, append a hash-chained record of what the fetch resolved and downloaded to this file
+	Timeout                  time.Duration // If positive, fail the fetch if it takes longer than this
+	MaxDownloadSize          uint64        // If positive, fail the fetch if its HTTP responses add up to more bytes than this
+	ManifestJournal          string        // Only used by RunManifest. If set, record each entry that succeeds here, so a re-run skips it.
+
+	// The version of fetch recorded in --emit-sbom-lite manifests
+	ToolVersion string
+
+	// Project logger. If nil, GetProjectLogger() is used.
+	Logger *logrus.Entry
+
+	// The locale of the friendlier explanations of common errors, e.g. "ja" or "de_DE.UTF-8". See SupportedLocales. If
+	// empty, they're in English.
+	Locale string
+}
+
+// The outcome of a successful fetch
+type Result struct {
+	// The tag that was resolved from the tag constraint, if any
+	Tag string
+
+	// The SHA of the commit the resolved tag points to, if known
+	TagCommitSha string
+
+	// The full SHA of the commit the tag, branch, or commit that was downloaded from resolved to. Only set with the
+	// ResolveRef option.
+	CommitSha string
+
+	// The local paths of the release assets that were downloaded, if any
+	AssetPaths []string
+
+	// The path the binary was installed to, if the Install option is set
+	InstalledPath string
+
+	// The presigned URLs of the release assets published with the PublishS3 option, if any
+	PresignedUrls []string
+
+	// Every file that was downloaded from the repo with the SourceFiles option or as a release asset. Release assets
+	// are recorded before they're unpacked, which may delete them.
+	Files []FetchedFile
+
+	// How long each step of the fetch took
+	Timings Timings
+}
+
+// The kinds of FetchedFile
+const (
+	FetchedSourceFile   = "source-file"
+	FetchedReleaseAsset = "release-asset"
+)
+
+// A single file downloaded by a fetch
+type FetchedFile struct {
+	Kind   string // FetchedSourceFile or FetchedReleaseAsset
+	Path   string // The local path it was downloaded to, or the name of a release asset streamed to an AssetSink
+	Size   int64  // The size in bytes
+	Sha256 string // The SHA256 checksum, if the RecordChecksums or AuditLog option is set
+
+	// The metadata of a release asset that's a .deb or .rpm package
+	Package *PackageMetadata
+}
+
+// How long the steps of a fetch took. Steps that didn't run take no time.
+type Timings struct {
+	Resolve  time.Duration // Resolving the tag
+	Download time.Duration // Downloading the source paths, source files, and release assets, and joining asset parts
+	Extract  time.Duration // Extracting the source paths from the repo archive, which is part of Download
+	Verify   time.Duration // Verifying the release assets
+	Total    time.Duration // The whole fetch, including publishing, installing, and unpacking
+}
+
+// Record the file at the given path as downloaded, computing its checksum if checksums are recorded
+func (fetcher *Fetcher) newFetchedFile(kind string, filePath string) (FetchedFile, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return FetchedFile{}, err
+	}
+
+	file := FetchedFile{Kind: kind, Path: filePath, Size: info.Size()}
+	if fetcher.options.checksumsRecorded() {
+		if file.Sha256, err = computeChecksum(filePath, "sha256", nil); err != nil {
+			return FetchedFile{}, err
+		}
+	}
+	if kind == FetchedReleaseAsset {
+		// A release asset that merely looks like a package shouldn't fail a fetch that doesn't verify packages
+		if file.Package, err = ReadPackageMetadata(filePath); err != nil {
+			fetcher.logger.Warnf("%s\n", err)
+		}
+	}
+	return file, nil
+}
+
+// Return the release channel TagConstraint is matched in. Without the Channel option, pre-releases are matched as they
+// are on the any channel if IncludePrereleases is set, and by the rules of go-version otherwise, which only match
+// a pre-release against a constraint on a pre-release of the same version.
+func (options Options) tagChannel() string {
+	if options.Channel == "" && options.IncludePrereleases {
+		return ChannelAny
+	}
+	return options.Channel
+}
+
+// Return the source paths to download. If no release asset and no source paths or files are specified, then by
+// default, all the source files are downloaded from the repo.
+func (options Options) sourcePaths() []string {
+	if len(options.SourcePaths) == 0 && len(options.SourceFiles) == 0 && options.releaseAssetRegex() == "" && !options.AutoAsset {
+		return []string{"/"}
+	}
+	return options.SourcePaths
+}
+
+// Return true if the SHA256 checksum of each downloaded file is recorded in the Result, which the audit log needs too
+func (options Options) checksumsRecorded() bool {
+	return options.RecordChecksums || options.AuditLog != ""
+}
+
+// Return the regex matching the release assets to download, which matches every asset if AllReleaseAssets is set.
+// Returns an empty string if no release assets should be downloaded.
+func (options Options) releaseAssetRegex() string {
+	if options.AllReleaseAssets {
+		return ".*"
+	}
+	return options.ReleaseAsset
+}
+
+// Fetcher downloads from a single GitHub repo according to its Options. Create one with NewFetcher.
+type Fetcher struct {
+	options  Options
+	logger   *logrus.Entry
+	instance GitHubInstance
+	repo     GitHubRepo
+
+	// If set, the repo is on a Bitbucket Server instance rather than GitHub, and only its source paths can be fetched
+	bitbucket *BitbucketServerRepo
+
+	// If set, the repo is in CodeCommit rather than GitHub, and only its source paths can be fetched
+	codeCommit *CodeCommitRepo
+
+	// If set, the repo is a Go module whose source is downloaded from a module proxy, and only its source paths can be
+	// fetched
+	goModule *GoModule
+
+	// If set, the repo is a bucket that releases are mirrored to rather than a GitHub repo, and only its release assets
+	// can be fetched
+	mirror *BucketMirror
+
+	// If set, the repo is a folder of an Artifactory or Nexus repository that releases are published to rather than a
+	// GitHub repo, and only its release assets can be fetched
+	artifactRepo *ArtifactRepository
+
+	// If set, the repo is a package in the Generic Packages registry of a GitLab project rather than a GitHub repo, and
+	// only the files of its versions can be fetched, as release assets
+	gitlabPackage *GitlabPackageRegistry
+
+	// If set, release assets are shared through this store with the other Fetchers that use it. Otherwise, the store in
+	// the ArchiveCacheDir option is used, if that's set.
+	assetStore *assetStore
+
+	// How the Fetcher's HTTP requests are sent, as set by the Connection and WaitForRateLimit options. Each exported
+	// method attaches it to its context. If nil, defaultConnection is used.
+	connection *connection
+
+	// The full SHA of the commit to download source paths and files from, once it's resolved with the ResolveRef option
+	commitSha string
+}
+
+// A git tag resolved from the GitRef or TagConstraint option
+type ResolvedTag struct {
+	// The tag name. Empty if no tag constraint was given and the repo has no tags.
+	Tag string
+
+	// The SHA of the commit the tag points to, as listed by the GitHub tags API. Empty if the tag is not in that list.
+	CommitSha string
+}
+
+type AssetDownloadResult struct {
+	assetPath string
+	err       error
+}
+
+// Create a Fetcher for the repo in options
+func NewFetcher(options Options) (*Fetcher, error) {
+	conn, err := newConnection(options.Logger, options.Connection, options.WaitForRateLimit)
+	if err != nil {
+		return nil, err
+	}
+	return newFetcherWithConnection(options, conn)
+}
+
+// Create a Fetcher for the repo in options, as NewFetcher does, whose HTTP requests are sent over the given connection
+// rather than a new one made from the Connection and WaitForRateLimit options. Fetchers that share a connection share
+// its transport, and with it, its pool of idle connections.
+func newFetcherWithConnection(options Options, conn *connection) (*Fetcher, error) {
+	if err := ValidateLocale(options.Locale); err != nil {
+		return nil, err
+	}
+
+	logger := options.Logger
+	if logger == nil {
+		logger = GetProjectLogger()
+	}
+
+	fetcher, err := newFetcher(options, logger)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.connection = conn
+	return fetcher, nil
+}
+
+// Return ctx with the Fetcher's connection and logger attached, so that the HTTP requests made with it are sent as the
+// Fetcher's options say, and what happens along the way is logged with the Fetcher's logger
+func (fetcher *Fetcher) withConnection(ctx context.Context) context.Context {
+	return withLogger(withConnection(ctx, fetcher.connection), fetcher.logger)
+}
+
+// Create a Fetcher for the repo in options, without its connection
+func newFetcher(options Options, logger *logrus.Entry) (*Fetcher, error) {
+	if options.Url != "" {
+		if err := validateUrlOptions(options); err != nil {
+			return nil, err
+		}
+		return &Fetcher{options: options, logger: logger}, nil
+	}
+
+	if err := ValidateSource(options.Source); err != nil {
+		return nil, err
+	}
+	if options.Source == SourceGoProxy {
+		if err := validateGoModuleOptions(options); err != nil {
+			return nil, err
+		}
+		goModule, err := ParseGoModule(options.RepoUrl)
+		if err != nil {
+			return nil, err
+		}
+		repo := GitHubRepo{Url: goModule.Path, Name: goModule.name()}
+		return &Fetcher{options: options, logger: logger, repo: repo, goModule: goModule}, nil
+	}
+	if options.Source == SourceArtifactory || options.Source == SourceNexus {
+		if err := validateArtifactRepoOptions(options); err != nil {
+			return nil, err
+		}
+		artifactRepo, err := ParseArtifactRepository(options.RepoUrl, options.Source, options.ArtifactRepoAuth)
+		if err != nil {
+			return nil, err
+		}
+		repo := GitHubRepo{Url: artifactRepo.Url, Name: artifactRepo.name()}
+		return &Fetcher{options: options, logger: logger, repo: repo, artifactRepo: artifactRepo}, nil
+	}
+	if options.Source == SourceGitlabPackage {
+		if err := validateArtifactRepoOptions(options); err != nil {
+			return nil, err
+		}
+		gitlabPackage, err := ParseGitlabPackageRegistry(options.RepoUrl, options.GitlabToken, "")
+		if err != nil {
+			return nil, err
+		}
+		if gitlabPackage.Token == "" {
+			gitlabPackage.JobToken = gitlabJobToken(gitlabPackage.ApiUrl, os.Getenv)
+		}
+		repo := GitHubRepo{Url: gitlabPackage.Url, Name: gitlabPackage.PackageName}
+		return &Fetcher{options: options, logger: logger, repo: repo, gitlabPackage: gitlabPackage}, nil
+	}
+	if options.Source == SourceBitbucketDc {
+		bitbucket, err := ParseBitbucketDataCenterRepo(options.RepoUrl, options.BitbucketToken)
+		if err != nil {
+			return nil, err
+		}
+		return newBitbucketServerFetcher(options, logger, bitbucket)
+	}
+	if options.Source == SourceCodeCommit || isCodeCommitRepoUrl(options.RepoUrl) {
+		if err := validateCodeCommitOptions(options); err != nil {
+			return nil, err
+		}
+		codeCommit, err := ParseCodeCommitRepo(options.RepoUrl, "")
+		if err != nil {
+			return nil, err
+		}
+		repo := GitHubRepo{Url: codeCommit.Url, Name: codeCommit.Name}
+		return &Fetcher{options: options, logger: logger, repo: repo, codeCommit: codeCommit}, nil
+	}
+
+	if IsBucketMirrorUrl(options.RepoUrl) {
+		if err := validateBucketMirrorOptions(options); err != nil {
+			return nil, err
+		}
+		mirror, err := ParseBucketMirror(options.RepoUrl)
+		if err != nil {
+			return nil, err
+		}
+		repo := GitHubRepo{Url: mirror.Url, Name: mirror.name()}
+		return &Fetcher{options: options, logger: logger, repo: repo, mirror: mirror}, nil
+	}
+
+	if bitbucket := ParseBitbucketServerRepo(options.RepoUrl, options.BitbucketToken); bitbucket != nil {
+		return newBitbucketServerFetcher(options, logger, bitbucket)
+	}
+
+	instance, fetchErr := ParseUrlIntoGithubInstance(logger, options.RepoUrl, options.GithubApiVersion)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	if instance.ApiUrl != "api.github.com" {
+		instance.EnterpriseVersion = options.GhesVersion
+	}
+
+	repo, fetchErr := ParseUrlIntoGitHubRepo(options.RepoUrl, options.GithubToken, instance)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("Error occurred while parsing GitHub URL: %s", fetchErr)
+	}
+	if options.ExpectedCommitSha != "" && !fullCommitShaRegex.MatchString(strings.ToLower(options.ExpectedCommitSha)) {
+		return nil, fmt.Errorf("The expected commit SHA \"%s\" must be the full 40 or 64 character SHA of a commit, as an abbreviated SHA could match more than one.", options.ExpectedCommitSha)
+	}
+	if options.IncludeDrafts {
+		if repo.Token == "" {
+			return nil, fmt.Errorf("Draft releases of %s can only be seen with a GitHub token that has push access to the repo.", options.RepoUrl)
+		}
+		repo.IncludeDrafts = true
+	}
+
+	if options.AssetSink != nil {
+		if err := validateAssetSinkOptions(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Fetcher{options: options, logger: logger, instance: instance, repo: repo}, nil
+}
+
+// Run every step of the fetch described by the Fetcher's options: resolve the tag, download the source paths and
+// release assets, verify them, and then publish, record, and unpack them as requested. With the Stdout option, the
+// contents of the release asset are written to writer, as are any presigned URLs from the PublishS3 option. If
+// LocalDownloadPath is StdoutDownloadPath, the release asset is streamed to writer instead, and nothing else is done.
+// With the AssetSink option, the release assets are streamed to it instead, and only their checksums are verified.
+// The fetch fails if it takes longer than the Timeout option, or downloads more than the MaxDownloadSize option.
+func (fetcher *Fetcher) Fetch(ctx context.Context, writer io.Writer) (*Result, error) {
+	ctx = fetcher.withConnection(ctx)
+	if fetcher.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fetcher.options.Timeout)
+		defer cancel()
+	}
+	if fetcher.options.MaxDownloadSize > 0 {
+		ctx = withDownloadBudget(ctx, fetcher.options.MaxDownloadSize)
+	}
+
+	result, err := fetcher.fetch(ctx, writer)
+	if err != nil && fetcher.options.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("The fetch did not finish within its timeout of %s: %s", fetcher.options.Timeout, err)
+	}
+	if err == nil && fetcher.options.AuditLog != "" {
+		if err := appendAuditRecords(fetcher.options.AuditLog, fetcher.auditRecords(result, time.Now())); err != nil {
+			return nil, err
+		}
+	}
+	return result, err
+}
+
+func (fetcher *Fetcher) fetch(ctx context.Context, writer io.Writer) (*Result, error) {
+	if fetcher.bitbucket != nil {
+		return fetcher.fetchFromBitbucketServer(ctx)
+	}
+	if fetcher.codeCommit != nil {
+		return fetcher.fetchFromCodeCommit(ctx)
+	}
+	if fetcher.goModule != nil {
+		return fetcher.fetchFromGoProxy(ctx)
+	}
+	if releases := fetcher.releaseStore(); releases != nil {
+		return fetcher.fetchFromReleaseStore(ctx, writer, releases)
+	}
+	if fetcher.options.Url != "" {
+		return fetcher.fetchFromUrl(ctx, writer)
+	}
+
+	options := fetcher.options
+	logger := fetcher.logger
+	repo := fetcher.repo
+
+	start := time.Now()
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	desiredTag := resolvedTag.Tag
+
+	result := &Result{Tag: desiredTag, TagCommitSha: resolvedTag.CommitSha}
+	if options.pinsCommit() {
+		commitSha, err := fetcher.resolveCommitSha(ctx, resolvedTag)
+		if err != nil {
+			return nil, err
+		}
+		if options.ResolveRef {
+			result.CommitSha = commitSha
+		}
+	}
+	result.Timings.Resolve = time.Since(start)
+
+	// If applicable, check that what the tag points to can't be replaced upstream before trusting anything from it
+	if options.CheckImmutableTag || options.RequireImmutableTag {
+		if err := fetcher.checkImmutableTag(ctx, desiredTag); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.LocalDownloadPath == StdoutDownloadPath {
+		if err := fetcher.StreamReleaseAsset(ctx, desiredTag, writer); err != nil {
+			return nil, err
+		}
+		result.Timings.Total = time.Since(start)
+		return result, nil
+	}
+
+	sourcePaths := options.sourcePaths()
+
+	// If applicable, record everything downloaded in this run so it can be written out as a manifest
+	var manifest *SbomLiteManifest
+	if options.EmitSbomLite != "" {
+		manifest = newSbomLiteManifest(repo, desiredTag, resolvedTag.CommitSha, options.BranchName, options.CommitSha, options.ToolVersion, time.Now())
+	}
+
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Download any requested source files
+	downloadStart := time.Now()
+	if result.Timings.Extract, err = fetcher.downloadSourcePaths(ctx, sourcePaths, resolvedTag, extractOptions, manifest); err != nil {
+		return nil, err
+	}
+	sourceFilePaths, err := fetcher.downloadSourceFiles(ctx, options.SourceFiles, desiredTag, manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, sourceFilePath := range sourceFilePaths {
+		file, err := fetcher.newFetchedFile(FetchedSourceFile, sourceFilePath)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	// If applicable, stream the requested release assets to the caller's sink, which leaves nothing on disk for the
+	// steps below
+	if options.AssetSink != nil {
+		files, err := fetcher.StreamReleaseAssets(ctx, desiredTag, options.AssetSink)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, files...)
+		result.Timings.Download = time.Since(downloadStart)
+		result.Timings.Total = time.Since(start)
+		return result, nil
+	}
+
+	// Download the requested release assets
+	assetPaths, err := fetcher.DownloadReleaseAssets(ctx, desiredTag)
+	if err != nil {
+		return nil, err
+	}
+
+	// If applicable, join the parts of multi-part release assets, so that it's the joined assets that get verified
+	if options.JoinParts {
+		if assetPaths, err = joinReleaseAssetParts(logger, assetPaths); err != nil {
+			return nil, err
+		}
+	}
+	result.Timings.Download = time.Since(downloadStart)
+
+	verifyStart := time.Now()
+	if err := fetcher.VerifyReleaseAssets(ctx, desiredTag, assetPaths); err != nil {
+		return nil, err
+	}
+	result.Timings.Verify = time.Since(verifyStart)
+
+	// If applicable, rename the release assets once they're verified, as checksum files and signatures refer to them by
+	// their original names
+	if len(options.Renames) > 0 {
+		if assetPaths, err = renameReleaseAssets(logger, assetPaths, newDestRoot(options.LocalDownloadPath, options.FollowDestSymlinks), options.Renames); err != nil {
+			return nil, err
+		}
+	}
+	result.AssetPaths = assetPaths
+
+	// Record the release assets now, while they're sure to still be on disk
+	for _, assetPath := range assetPaths {
+		file, err := fetcher.newFetchedFile(FetchedReleaseAsset, assetPath)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	// If applicable, publish the verified release assets to S3
+	if options.PublishS3 != "" {
+		if result.PresignedUrls, err = publishToS3(ctx, logger, options, assetPaths, writer); err != nil {
+			return nil, err
+		}
+	}
+
+	// If applicable, write the manifest before unpacking, which may delete the release assets it covers
+	if manifest != nil {
+		if err := manifest.addReleaseAssets(repo, desiredTag, assetPaths); err != nil {
+			return nil, err
+		}
+		if err := manifest.write(options.EmitSbomLite, options.SbomLiteSigningKey); err != nil {
+			return nil, err
+		}
+		logger.Infof("Wrote manifest of %d artifacts to %s\n", len(manifest.Artifacts), options.EmitSbomLite)
+	}
+
+	if result.InstalledPath, err = fetcher.useReleaseAssets(ctx, writer, assetPaths, repo.Name, extractOptions); err != nil {
+		return nil, err
+	}
+
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
+
+// Write the given verified release assets to writer, install the binary in them, or unpack them, as the Stdout,
+// Install, and Unpack options ask. repoName is what the binary is looked for by when there's no BinaryName option.
+// Returns the path the binary was installed to, if any.
+func (fetcher *Fetcher) useReleaseAssets(ctx context.Context, writer io.Writer, assetPaths []string, repoName string, extractOptions ExtractOptions) (string, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+	var installedPath string
+
+	if options.Stdout {
+		// Print to stdout only if a single asset was downloaded
+		if len(assetPaths) == 1 {
+			dat, err := os.ReadFile(assetPaths[0])
+			if err != nil {
+				return "", err
+			}
+			writer.Write(dat) // This should be stdout
+		} else {
+
+			if len(assetPaths) > 1 {
+				logger.Warn("Multiple assets were downloaded. Ignoring --stdout")
+			} else {
+				logger.Warn("No assets were downloaded. Ignoring --stdout")
+			}
+
+		}
+	}
+
+	// If applicable, install the binary in the release asset now that it has been verified
+	if options.Install {
+		if len(assetPaths) != 1 {
+			return "", fmt.Errorf("Installing requires exactly one release asset, but %d were downloaded. Use a more specific --release-asset or --release-asset-pick-by.", len(assetPaths))
+		}
+		var err error
+		installedPath, err = installReleaseAsset(logger, assetPaths[0], options.InstallDir, options.BinaryName, repoName, extractOptions)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// If applicable, unpack the release assets now that they've been verified
+	if options.Unpack {
+		unpackOptions := extractOptions
+		unpackOptions.Include = options.UnpackInclude
+		for _, assetPath := range assetPaths {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			if options.UnpackBinary {
+				if err := unpackReleaseAssetBinary(logger, assetPath, options.LocalDownloadPath, options.BinaryName, repoName, options.KeepArchive, unpackOptions); err != nil {
+					return "", err
+				}
+			} else if err := unpackReleaseAsset(logger, assetPath, options.LocalDownloadPath, options.KeepArchive, unpackOptions); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return installedPath, nil
+}
+
+// Resolve the git tag to download, based on the GitRef or TagConstraint option. If the option is a specific tag, it
+// is used as-is; otherwise, the repo's tags are fetched and the latest tag that satisfies the tag constraint is
+// returned, along with the SHA of the commit it points to.
+//
+// The tags are also listed for a specific tag, or when downloading from a commit or branch, but only to look up the
+// commit the tag points to. If they can't be listed, e.g. because the tags API is blocked, the fetch carries on without
+// that commit, rather than failing for want of something it doesn't need.
+func (fetcher *Fetcher) ResolveTag(ctx context.Context) (ResolvedTag, error) {
+	ctx = fetcher.withConnection(ctx)
+	if err := ctx.Err(); err != nil {
+		return ResolvedTag{}, err
+	}
+
+	options := fetcher.options
+
+	var specific bool
+	var desiredTag string
+	var tagConstraint string
+
+	if options.GitRef != "" {
+		specific, desiredTag = isTagConstraintSpecificTag(options.GitRef)
+		tagConstraint = options.GitRef
+	} else {
+		specific, desiredTag = isTagConstraintSpecificTag(options.TagConstraint)
+		tagConstraint = options.TagConstraint
+	}
+
+	if specific && desiredTag == LatestReleaseTag {
+		if options.TagPrefix != "" {
+			return ResolvedTag{}, fmt.Errorf("The latest release of a repo is picked from all of its releases, so \"%s\" can't be resolved among the tags with prefix %s.", LatestReleaseTag, options.TagPrefix)
+		}
+		return fetcher.resolveLatestRelease(ctx)
+	}
+	// A specific tag is given without the prefix, though one that already has it is taken as-is
+	if specific && options.TagPrefix != "" && !strings.HasPrefix(desiredTag, options.TagPrefix) {
+		desiredTag = options.TagPrefix + desiredTag
+	}
+
+	// Get the tags for the given repo
+	tags, tagCommits, err := fetcher.listTags(ctx)
+	if err != nil {
+		exactReference := fetcher.exactReference(specific, desiredTag, tagConstraint)
+		if exactReference == "" || ctx.Err() != nil {
+			return ResolvedTag{}, err
+		}
+		fetcher.logger.Warnf("Could not list the tags of repo %s/%s, so the commit the tag points to won't be known. Continuing anyway, as they aren't needed to download %s. The error was: %s\n", fetcher.repo.Owner, fetcher.repo.Name, exactReference, err)
+		return ResolvedTag{Tag: desiredTag}, nil
+	}
+
+	if !specific {
+		if options.TagPrefix != "" {
+			tags = tagsWithPrefix(options.TagPrefix, tags, tagCommits, options.LooseSemver)
+		}
+
+		// Find the specific release that matches the latest version constraint
+		latestTag, err := getLatestAcceptableTag(tagConstraint, tags, options.LooseSemver, options.tagChannel())
+		if err != nil {
+			if err.errorCode == invalidTagConstraintExpression {
+				return ResolvedTag{}, errors.New(getErrorMessage(options.Locale, invalidTagConstraintExpression, err.details))
+			} else {
+				return ResolvedTag{}, fmt.Errorf("Error occurred while computing latest tag that satisfies version contraint expression: %s", err)
+			}
+		}
+		desiredTag = latestTag
+		if options.TagPrefix != "" && latestTag != "" {
+			desiredTag = options.TagPrefix + latestTag
+		}
+	}
+
+	resolvedTag := ResolvedTag{Tag: desiredTag, CommitSha: tagCommits[desiredTag]}
+	if resolvedTag.Tag != "" && resolvedTag.CommitSha != "" {
+		fetcher.logger.Infof("Resolved tag \"%s\" to commit %s\n", resolvedTag.Tag, resolvedTag.CommitSha)
+	}
+	return resolvedTag, nil
+}
+
+// Resolve the tag of the latest release of the Fetcher's GitHub repo, as GitHub picks it, without listing the repo's
+// tags. The commit the tag points to is looked up on its own, and if that fails, the fetch carries on without it, as it
+// does for a specific tag.
+func (fetcher *Fetcher) resolveLatestRelease(ctx context.Context) (ResolvedTag, error) {
+	if fetcher.codeCommit != nil || fetcher.goModule != nil || fetcher.bitbucket != nil || fetcher.releaseStore() != nil {
+		return ResolvedTag{}, fmt.Errorf("Only GitHub repos have a latest release, so \"%s\" can't be resolved in %s.", LatestReleaseTag, fetcher.options.RepoUrl)
+	}
+
+	release, fetchErr := GetLatestGitHubRelease(ctx, fetcher.repo)
+	if fetchErr != nil {
+		if fetchErr.errorCode == repoDoesNotExistOrAccessDenied {
+			return ResolvedTag{}, fmt.Errorf("Repo %s/%s has no latest release, or doesn't exist. Drafts and pre-releases are never the latest release. The error was: %s", fetcher.repo.Owner, fetcher.repo.Name, fetchErr)
+		}
+		return ResolvedTag{}, fmt.Errorf("Error occurred while getting the latest release of GitHub repo %s/%s: %s", fetcher.repo.Owner, fetcher.repo.Name, fetchErr)
+	}
+	fetcher.logger.Infof("Resolved \"%s\" to tag \"%s\"\n", LatestReleaseTag, release.TagName)
+
+	resolvedTag := ResolvedTag{Tag: release.TagName}
+	commitSha, fetchErr := GetCommitSha(ctx, fetcher.repo, release.TagName)
+	if fetchErr != nil {
+		if ctx.Err() != nil {
+			return ResolvedTag{}, ctx.Err()
+		}
+		fetcher.logger.Warnf("Could not look up the commit tag \"%s\" points to, so it won't be known. Continuing anyway. The error was: %s\n", release.TagName, fetchErr)
+		return resolvedTag, nil
+	}
+	resolvedTag.CommitSha = commitSha
+	fetcher.logger.Infof("Resolved tag \"%s\" to commit %s\n", resolvedTag.Tag, resolvedTag.CommitSha)
+	return resolvedTag, nil
+}
+
+// List the tags of the Fetcher's repo, along with the SHA of the commit each points to
+func (fetcher *Fetcher) listTags(ctx context.Context) ([]string, map[string]string, error) {
+	// CodeCommit has no API to list tags, which is why only specific tags can be fetched from it
+	if fetcher.codeCommit != nil {
+		return nil, nil, nil
+	}
+	// A Go module's versions are its tags, and have no commits
+	if fetcher.goModule != nil {
+		versions, err := fetcher.goModule.listVersions(ctx, fetcher.options.LooseSemver)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error occurred while listing the versions of Go module %s: %s", fetcher.goModule.Path, err)
+		}
+		return versions, map[string]string{}, nil
+	}
+	// A mirror or artifact repository has no commits, so only its tags are listed
+	if releases := fetcher.releaseStore(); releases != nil {
+		tags, err := releases.listTags(ctx, fetcher.options.LooseSemver)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error occurred while getting tags from %s: %s", releases.description(), err)
+		}
+		return tags, map[string]string{}, nil
+	}
+	if fetcher.bitbucket != nil {
+		tags, tagCommits, fetchErr := fetchBitbucketServerTags(ctx, fetcher.bitbucket, fetcher.options.LooseSemver)
+		if fetchErr != nil {
+			return nil, nil, fmt.Errorf("Error occurred while getting tags from Bitbucket Server repo %s: %s", fetcher.bitbucket.Url, fetchErr)
+		}
+		return tags, tagCommits, nil
+	}
+
+	tags, tagCommits, fetchErr := fetchTags(ctx, fetcher.repo, fetcher.options.LooseSemver, newApiResponseCache(fetcher.options.ArchiveCacheDir))
+	if fetchErr == nil && fetcher.repo.IncludeDrafts {
+		tags, fetchErr = fetcher.addDraftTags(ctx, tags)
+	}
+	if fetchErr == nil {
+		return tags, tagCommits, nil
+	}
+	switch fetchErr.errorCode {
+	case invalidGithubTokenOrAccessDenied, repoDoesNotExistOrAccessDenied, githubApiRateLimitExceeded:
+		return nil, nil, &explainedFetchError{getErrorMessage(fetcher.options.Locale, fetchErr.errorCode, fetchErr.details), fetchErr}
+	default:
+		return nil, nil, fmt.Errorf("Error occurred while getting tags from GitHub repo: %s", fetchErr)
+	}
+}
+
+// Return the given tags of the Fetcher's GitHub repo along with the tags of its draft releases that are versions. The
+// tags of drafts don't exist until they're published, so they point to no commit yet.
+func (fetcher *Fetcher) addDraftTags(ctx context.Context, tags []string) ([]string, *FetchError) {
+	drafts, fetchErr := fetchDraftReleases(ctx, fetcher.repo)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	if len(drafts) == 0 {
+		fetcher.logger.Warnf("Found no draft releases in repo %s/%s. Drafts are only listed for GitHub tokens with push access to the repo.\n", fetcher.repo.Owner, fetcher.repo.Name)
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		seen[tag] = true
+	}
+	for _, draft := range drafts {
+		if _, err := parseTagVersion(draft.TagName, fetcher.options.LooseSemver); err != nil || seen[draft.TagName] {
+			continue
+		}
+		seen[draft.TagName] = true
+		tags = append(tags, draft.TagName)
+	}
+	return tags, nil
+}
+
+// Return a description of what's being downloaded, such as tag "v1.2.3", if it's exact, so the repo's tags don't
+// need to be listed to resolve it: a specific tag, or a commit or branch without a tag constraint. Otherwise, return an
+// empty string.
+func (fetcher *Fetcher) exactReference(specific bool, desiredTag string, tagConstraint string) string {
+	switch {
+	case specific:
+		return fmt.Sprintf("tag \"%s\"", desiredTag)
+	case tagConstraint != "":
+		return ""
+	case fetcher.options.CommitSha != "":
+		return fmt.Sprintf("commit \"%s\"", fetcher.options.CommitSha)
+	case fetcher.options.BranchName != "":
+		return fmt.Sprintf("branch \"%s\"", fetcher.options.BranchName)
+	default:
+		return ""
+	}
+}
+
+// Check whether what's downloaded from the given tag could be replaced upstream: if the fetch downloads release assets,
+// the tag's release must be immutable, and otherwise a ruleset protecting the tag from updates and deletions will do.
+// If not, warn, or with the RequireImmutableTag option, return an error.
+func (fetcher *Fetcher) checkImmutableTag(ctx context.Context, tag string) error {
+	downloadsReleaseAssets := fetcher.options.releaseAssetRegex() != "" || fetcher.options.AutoAsset
+
+	immutability, fetchErr := CheckTagImmutability(ctx, fetcher.repo, tag)
+	if fetchErr != nil {
+		return fmt.Errorf("Error occurred while checking whether tag \"%s\" is immutable: %s", tag, fetchErr)
+	}
+
+	if immutability.IsImmutable(downloadsReleaseAssets) {
+		if immutability.ImmutableRelease {
+			fetcher.logger.Infof("The release for tag \"%s\" is immutable\n", tag)
+		} else {
+			fetcher.logger.Infof("Tag \"%s\" is protected from updates and deletions by ruleset(s) %s\n", tag, strings.Join(immutability.ProtectingRulesets, ", "))
+		}
+		return nil
+	}
+
+	message := immutability.describeMutable(tag, downloadsReleaseAssets)
+	if fetcher.options.RequireImmutableTag {
+		return fmt.Errorf("%s Drop --require-immutable-tag to fetch it anyway.", message)
+	}
+	fetcher.logger.Warnf("%s\n", message)
+	return nil
+}
+
+// Download the source paths in the Fetcher's options from the given tag (or from the commit or branch in the options,
+// which take precedence) to the local download path. If no source paths are set, nothing is downloaded.
+func (fetcher *Fetcher) DownloadSourcePaths(ctx context.Context, tag string) error {
+	ctx = fetcher.withConnection(ctx)
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return err
+	}
+	_, err = fetcher.downloadSourcePaths(ctx, fetcher.options.SourcePaths, ResolvedTag{Tag: tag}, extractOptions, nil)
+	return err
+}
+
+// Download the single files in the SourceFiles option from the given tag (or from the commit or branch in the options,
+// which take precedence) to the local download path, each under its base name
+func (fetcher *Fetcher) DownloadSourceFiles(ctx context.Context, tag string) error {
+	ctx = fetcher.withConnection(ctx)
+	_, err := fetcher.downloadSourceFiles(ctx, fetcher.options.SourceFiles, tag, nil)
+	return err
+}
+
+// Download the release assets matching the ReleaseAsset option (or every asset, with the AllReleaseAssets option, or
+// the asset for the current platform, with the AutoAsset option) from the release with the given tag to the local
+// download path. Returns the paths of the downloaded assets.
+func (fetcher *Fetcher) DownloadReleaseAssets(ctx context.Context, tag string) ([]string, error) {
+	ctx = fetcher.withConnection(ctx)
+	matcher, err := fetcher.releaseAssetMatcher(tag)
+	if err != nil {
+		return nil, err
+	}
+	store := fetcher.assetStore
+	if store == nil && fetcher.options.ArchiveCacheDir != "" {
+		store = openAssetStore(fetcher.options.ArchiveCacheDir, fetcher.options.LinkMode)
+	}
+	return downloadReleaseAssets(ctx, fetcher.logger, matcher, newDestRoot(fetcher.options.LocalDownloadPath, fetcher.options.FollowDestSymlinks), fetcher.repo, tag, fetcher.options.WithProgress, fetcher.options.MaxConcurrentDownloads, fetcher.options.DownloadConnections, store)
+}
+
+// Stream the single release asset matching the ReleaseAsset or AutoAsset option from the release with the given tag
+// straight to writer, without writing it to disk. Returns an error if the options match no assets, or more than one.
+// The asset is hashed as it's streamed and checked against the ReleaseAssetChecksums and ReleaseAssetChecksumFile
+// options, so a mismatch is only reported once all of it has been written. With the VerifyBeforeStdout option, the
+// asset is instead downloaded to a temporary file and fully verified before any of it is written.
+func (fetcher *Fetcher) StreamReleaseAsset(ctx context.Context, tag string, writer io.Writer) error {
+	ctx = fetcher.withConnection(ctx)
+	matcher, err := fetcher.releaseAssetMatcher(tag)
+	if err != nil {
+		return err
+	}
+	if !matcher.selectsAssets() {
+		return fmt.Errorf("Streaming to stdout requires a release asset to be selected.")
+	}
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, fetcher.repo, tag)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	assets, err := matcher.match(fetcher.logger, release, tag)
+	if err != nil {
+		return err
+	}
+	if len(assets) != 1 {
+		return fmt.Errorf("Streaming to stdout requires exactly one release asset, but %d matched. Use a more specific --release-asset or --release-asset-pick-by.", len(assets))
+	}
+
+	asset := assets[0]
+
+	if fetcher.options.VerifyBeforeStdout {
+		return fetcher.streamVerifiedReleaseAsset(ctx, tag, asset, writer)
+	}
+
+	// Hash the asset as it's streamed, so that it can be verified once it has been
+	checksums, err := fetcher.streamChecksums(ctx, tag)
+	if err != nil {
+		return err
+	}
+	hashers, err := fetcher.streamHashers(checksums, asset.Name)
+	if err != nil {
+		return err
+	}
+	writers := []io.Writer{writer}
+	for _, hasher := range hashers {
+		writers = append(writers, hasher)
+	}
+
+	fetcher.logger.Infof("Streaming release asset %s to stdout\n", asset.Name)
+	if fetchErr := streamReleaseAsset(ctx, fetcher.repo, asset, io.MultiWriter(writers...)); fetchErr != nil {
+		return fetchErr
+	}
+	for _, hasher := range hashers {
+		if fetchErr := hasher.verify(fetcher.logger, asset.Name); fetchErr != nil {
+			return fetchErr
+		}
+	}
+	return nil
+}
+
+// Return the checksums in the checksum file of the ReleaseAssetChecksumFile option, keyed by file name, or nil if it's
+// not set
+func (fetcher *Fetcher) streamChecksums(ctx context.Context, tag string) (map[string]string, error) {
+	if fetcher.options.ReleaseAssetChecksumFile == "" {
+		return nil, nil
+	}
+	return loadChecksumFile(ctx, fetcher.repo, tag, fetcher.options.ReleaseAssetChecksumFile)
+}
+
+// Return a streamHasher for each of the sets of checksums the given release asset must match according to the
+// ReleaseAssetChecksums option and the checksums from the ReleaseAssetChecksumFile option, as streamChecksums returns
+func (fetcher *Fetcher) streamHashers(checksums map[string]string, assetName string) ([]*streamHasher, error) {
+	options := fetcher.options
+	var hashers []*streamHasher
+
+	if len(options.ReleaseAssetChecksums) > 0 {
+		hasher, err := newStreamHasher(options.ReleaseAssetChecksums, options.ReleaseAssetChecksumAlgo, options.UpgradeWeakChecksums)
+		if err != nil {
+			return nil, err
+		}
+		hashers = append(hashers, hasher)
+	}
+
+	if options.ReleaseAssetChecksumFile != "" {
+		checksum, algorithm, fetchErr := findChecksumInFile(checksums, options.ReleaseAssetChecksumFile, assetName, options.ReleaseAssetChecksumAlgo)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		hasher, err := newStreamHasher(map[string]bool{checksum: true}, algorithm, options.UpgradeWeakChecksums)
+		if err != nil {
+			return nil, err
+		}
+		hashers = append(hashers, hasher)
+	}
+
+	return hashers, nil
+}
+
+// Download the given release asset to a temporary file, verify it as VerifyReleaseAssets would, and only then copy it
+// to writer, so that nothing is written if the asset fails verification
+func (fetcher *Fetcher) streamVerifiedReleaseAsset(ctx context.Context, tag string, asset *GitHubReleaseAsset, writer io.Writer) error {
+	tempDir, err := ioutil.TempDir("", "fetch-stdout")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Keep the asset's own name, which is what its entries in checksum files and its signatures are looked up by
+	assetPath := filepath.Join(tempDir, asset.Name)
+	fetcher.logger.Infof("Downloading release asset %s to verify it before streaming it to stdout\n", asset.Name)
+	if fetchErr := downloadReleaseAsset(ctx, fetcher.repo, asset, assetPath, fetcher.options.WithProgress); fetchErr != nil {
+		return fetchErr
+	}
+
+	if err := fetcher.VerifyReleaseAssets(ctx, tag, []string{assetPath}); err != nil {
+		return err
+	}
+
+	file, err := os.Open(assetPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fetcher.logger.Infof("Streaming release asset %s to stdout\n", asset.Name)
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// Verify the given release assets, downloaded from the release with the given tag, against the checksums, checksum
+// file, and signatures requested in the Fetcher's options
+func (fetcher *Fetcher) VerifyReleaseAssets(ctx context.Context, tag string, assetPaths []string) error {
+	ctx = fetcher.withConnection(ctx)
+	// Release assets whose checksum doesn't match are downloaded once more before giving up, in case they were corrupted
+	return fetcher.verifyReleaseAssets(ctx, tag, assetPaths, fetcher.releaseAssetRedownloader(ctx, tag))
+}
+
+// Verify local copies of release assets of the release with the given tag, which weren't necessarily downloaded by
+// fetch, as VerifyReleaseAssets does. Unlike VerifyReleaseAssets, copies whose checksum doesn't match are never
+// downloaded again, so the verification fails instead of replacing them.
+func (fetcher *Fetcher) VerifyLocalReleaseAssets(ctx context.Context, tag string, assetPaths []string) error {
+	ctx = fetcher.withConnection(ctx)
+	return fetcher.verifyReleaseAssets(ctx, tag, assetPaths, nil)
+}
+
+// Verify the given release assets, downloading any whose checksum doesn't match once more with redownload, unless it's
+// nil
+func (fetcher *Fetcher) verifyReleaseAssets(ctx context.Context, tag string, assetPaths []string, redownload releaseAssetRedownloader) error {
+	options := fetcher.options
+	logger := fetcher.logger
+	repo := fetcher.repo
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// If applicable, verify the release asset
+	if len(options.ReleaseAssetChecksums) > 0 {
+		for _, assetPath := range assetPaths {
+			fetchErr := verifyChecksumOfReleaseAssetWithRetry(logger, assetPath, options.ReleaseAssetChecksums, options.ReleaseAssetChecksumAlgo, options.WithProgress, options.UpgradeWeakChecksums, redownload)
+			if fetchErr != nil {
+				return fetchErr
+			}
+		}
+	}
+
+	// If applicable, verify the release assets against a published checksum file
+	if options.ReleaseAssetChecksumFile != "" {
+		if err := verifyReleaseAssetsWithChecksumFile(ctx, logger, repo, tag, options.ReleaseAssetChecksumFile, options.ReleaseAssetChecksumAlgo, assetPaths, options.WithProgress, options.UpgradeWeakChecksums, redownload); err != nil {
+			return err
+		}
+	}
+
+	// If applicable, verify the release asset signatures with the key published in the repo
+	if options.VerifyWithRepoKey {
+		if err := verifyReleaseAssetSignatures(ctx, logger, repo, tag, assetPaths); err != nil {
+			return err
+		}
+	}
+
+	// If applicable, verify the release assets were signed keylessly with cosign by the expected identity
+	if options.CosignVerify {
+		if err := verifyReleaseAssetsWithCosign(ctx, logger, repo, tag, assetPaths, options.CosignVerifyOptions); err != nil {
+			return err
+		}
+	}
+
+	// If applicable, verify the signatures embedded in .deb and .rpm release assets
+	if options.PackageSigningKey != "" {
+		if err := verifyPackageSignatures(logger, options.PackageSigningKey, assetPaths); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the options for extracting files, based on the Fetcher's options
+func (fetcher *Fetcher) extractOptions() (ExtractOptions, error) {
+	dirMode, err := ParseFileMode("dir-mode", fetcher.options.DirMode)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+	fileMode, err := ParseFileMode("file-mode", fetcher.options.FileMode)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+	renames, err := ParseRenames(fetcher.options.Renames)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+
+	return ExtractOptions{
+		PreservePermissions: fetcher.options.PreservePermissions,
+		PreserveSymlinks:    fetcher.options.PreserveSymlinks,
+		DirMode:             dirMode,
+		FileMode:            fileMode,
+		StripComponents:     fetcher.options.StripComponents,
+		Flatten:             fetcher.options.Flatten,
+		Renames:             renames,
+		FollowDestSymlinks:  fetcher.options.FollowDestSymlinks,
+	}, nil
+}
+
+// Return the commit to download source files from: the commit or branch in the options, or otherwise the given tag
+func (fetcher *Fetcher) gitHubCommit(tag string) GitHubCommit {
+	// We want to respect the GitHubCommit Hierarchy of "CommitSha > GitTag > BranchName"
+	// Note that CommitSha or BranchName may be blank here if the user did not specify values for these.
+	// If the user specified no value for GitTag, ResolveTag still gave us some value
+	// So we can guarantee (at least logically) that this struct instance is in a valid state right now.
+	commit := GitHubCommit{
+		Repo:       fetcher.repo,
+		GitRef:     tag,
+		GitTag:     tag,
+		BranchName: fetcher.options.BranchName,
+		CommitSha:  fetcher.options.CommitSha,
+	}
+	// Once the commit is resolved with the ResolveRef option, it's downloaded by its SHA
+	if fetcher.commitSha != "" {
+		commit.CommitSha = fetcher.commitSha
+	}
+	return commit
+}
+
+// Return true if the options need the full SHA of the commit that's downloaded from to be looked up before downloading
+func (options Options) pinsCommit() bool {
+	return options.ResolveRef || options.ExpectedCommitSha != "" || options.RequireSignedRef
+}
+
+// Look up the full SHA of the commit the given tag, or the commit or branch in the options, points to, for the
+// ResolveRef, ExpectedCommitSha, and RequireSignedRef options, and check it as they require. Source paths and files
+// are then downloaded from that commit, rather than by tag or branch, so that the commit that's reported or checked is
+// the one that was downloaded, even if the tag or branch moves in the meantime.
+func (fetcher *Fetcher) resolveCommitSha(ctx context.Context, resolvedTag ResolvedTag) (string, error) {
+	fetcher.commitSha = ""
+	gitHubCommit := fetcher.gitHubCommit(resolvedTag.Tag)
+	commitSha, fetchErr := fetcher.archiveCommitSha(ctx, gitHubCommit, resolvedTag.CommitSha)
+	if fetchErr != nil {
+		return "", fmt.Errorf("Error occurred while resolving git reference \"%s\" of GitHub repo %s/%s to a commit: %s", gitHubCommit.ref(), fetcher.repo.Owner, fetcher.repo.Name, fetchErr)
+	}
+	fetcher.logger.Infof("Resolved git reference \"%s\" to commit %s\n", gitHubCommit.ref(), commitSha)
+	// A tag that points anywhere else may have been moved since the expected SHA was pinned, so nothing is downloaded
+	if expected := fetcher.options.ExpectedCommitSha; expected != "" && !strings.EqualFold(commitSha, expected) {
+		return "", fmt.Errorf("Git reference \"%s\" of GitHub repo %s/%s points to commit %s, but commit %s was expected. The tag may have been moved to another commit since it was pinned.", gitHubCommit.ref(), fetcher.repo.Owner, fetcher.repo.Name, commitSha, expected)
+	}
+	if fetcher.options.RequireSignedRef {
+		if err := fetcher.checkRefSignatures(ctx, resolvedTag.Tag, commitSha); err != nil {
+			return "", err
+		}
+	}
+	fetcher.commitSha = commitSha
+	return commitSha, nil
+}
+
+// Download the specified source files from the given repo, and return how long extracting them from the repo archive took
+func (fetcher *Fetcher) downloadSourcePaths(ctx context.Context, sourcePaths []string, resolvedTag ResolvedTag, extractOptions ExtractOptions, manifest *SbomLiteManifest) (time.Duration, error) {
+	if len(sourcePaths) == 0 {
+		return 0, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	logger := fetcher.logger
+	githubRepo := fetcher.repo
+	destPath := fetcher.options.LocalDownloadPath
+	latestTag := resolvedTag.Tag
+
+	gitHubCommit := fetcher.gitHubCommit(latestTag)
+
+	// Download that release as a .zip file
+
+	// Ordering matters in this conditional
+	// GitRef needs to be the fallback and therefore must be last
+	// See https://github.com/gruntwork-io/fetch/issues/87 for an example
+	if gitHubCommit.CommitSha != "" {
+		logger.Infof("Downloading git commit \"%s\" of %s ...\n", gitHubCommit.CommitSha, githubRepo.Url)
+	} else if gitHubCommit.BranchName != "" {
+		logger.Infof("Downloading latest commit from branch \"%s\" of %s ...\n", gitHubCommit.BranchName, githubRepo.Url)
+	} else if gitHubCommit.GitTag != "" {
+		logger.Infof("Downloading tag \"%s\" of %s ...\n", latestTag, githubRepo.Url)
+	} else if gitHubCommit.GitRef != "" {
+		logger.Infof("Downloading git reference \"%s\" of %s ...\n", gitHubCommit.GitRef, githubRepo.Url)
+	} else {
+		return 0, fmt.Errorf("The commit sha, tag, and branch name are all empty")
+	}
+
+	if usesContentsApi(fetcher.options.DownloadStrategy, sourcePaths, extractOptions) {
+		return 0, fetcher.downloadSourcePathsWithContentsApi(ctx, sourcePaths, gitHubCommit.ref(), extractOptions, manifest)
+	}
+
+	localZipFilePath, cleanup, err := fetcher.downloadArchive(ctx, gitHubCommit, resolvedTag.CommitSha)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	if manifest != nil {
+		if err := manifest.addSourceArchive(gitHubCommit, localZipFilePath, fetcher.instance); err != nil {
+			return 0, fmt.Errorf("Error occurred while recording zip file in manifest: %s", err)
+		}
+	}
+
+	// Unzip and move the files we need to our destination
+	extractStart := time.Now()
+	for _, sourcePath := range sourcePaths {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		logger.Infof("Extracting files from <repo>%s to %s ...\n", sourcePath, destPath)
+
+		fileCount, err := extractFiles(localZipFilePath, sourcePath, destPath, extractOptions)
+		plural := ""
+		if fileCount != 1 {
+			plural = "s"
+		}
+		logger.Infof("%d file%s extracted\n", fileCount, plural)
+		if err != nil {
+			return 0, fmt.Errorf("Error occurred while extracting files from GitHub zip file: %s", err.Error())
+		}
+
+	}
+
+	extractTime := time.Since(extractStart)
+
+	logger.Infof("Download and file extraction complete.\n")
+	return extractTime, nil
+}
+
+// Download each of the given single files from the repo with the contents API into the local download path, under its
+// base name, record them in manifest if it's not nil, and return the local paths they were downloaded to
+func (fetcher *Fetcher) downloadSourceFiles(ctx context.Context, sourceFiles []string, tag string, manifest *SbomLiteManifest) ([]string, error) {
+	if len(sourceFiles) == 0 {
+		return nil, nil
+	}
+
+	renames, err := ParseRenames(fetcher.options.Renames)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := fetcher.gitHubCommit(tag).ref()
+	if ref == "" {
+		return nil, fmt.Errorf("The commit sha, tag, and branch name are all empty")
+	}
+
+	destPath := fetcher.options.LocalDownloadPath
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+	for _, sourceFile := range sourceFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// The file is written under its base name (unless it's renamed), so make sure that can't be used to write
+		// outside of destPath
+		root := newDestRoot(destPath, fetcher.options.FollowDestSymlinks)
+		name := renamePath(path.Base(strings.Trim(sourceFile, "/")), renames)
+		if err := root.MkdirAll(path.Dir(name), 0755); err != nil {
+			return nil, err
+		}
+		filePath := root.localPath(name)
+
+		fetcher.logger.Infof("Downloading file <repo>/%s at \"%s\" to %s ...\n", strings.Trim(sourceFile, "/"), ref, filePath)
+		resp, fetchErr := openRepoFile(ctx, fetcher.repo, ref, sourceFile)
+		if fetchErr == nil {
+			fetchErr = writeResponseToRoot(resp, root, name, fetcher.options.WithProgress)
+		}
+		if fetchErr != nil {
+			return nil, fmt.Errorf("Error occurred while downloading file %s from the repo: %s", sourceFile, fetchErr)
+		}
+
+		if manifest != nil {
+			if err := manifest.addSourceFile(fetcher.repo, ref, sourceFile, filePath); err != nil {
+				return nil, fmt.Errorf("Error occurred while recording file %s in manifest: %s", sourceFile, err)
+			}
+		}
+		filePaths = append(filePaths, filePath)
+	}
+	return filePaths, nil
+}
+
+// Rename each of the given release assets, downloaded to root, that one of the given renames (see ParseRenames)
+// applies to, and return the paths of all of the assets afterwards
+func renameReleaseAssets(logger *logrus.Entry, assetPaths []string, root destRoot, renameValues []string) ([]string, error) {
+	renames, err := ParseRenames(renameValues)
+	if err != nil {
+		return nil, err
+	}
+
+	var renamedPaths []string
+	for _, assetPath := range assetPaths {
+		assetName := filepath.Base(assetPath)
+		name, found := renames[assetName]
+		if !found {
+			renamedPaths = append(renamedPaths, assetPath)
+			continue
+		}
+
+		if err := root.MkdirAll(path.Dir(name), 0755); err != nil {
+			return nil, err
+		}
+		renamedPath := root.localPath(name)
+		logger.Infof("Renaming release asset %s to %s\n", assetName, renamedPath)
+		if err := root.Rename(assetName, name); err != nil {
+			return nil, fmt.Errorf("Failed to rename release asset %s to %s: %s", assetPath, renamedPath, err)
+		}
+		renamedPaths = append(renamedPaths, renamedPath)
+	}
+	return renamedPaths, nil
+}
+
+// Return a releaseAssetRedownloader that downloads the asset of the release with the given tag that has the same name
+// as the file at the given path to that path again, bypassing caches. If the asset is in the asset store, the store is
+// updated with the new download, so a corrupted copy isn't reused.
+func (fetcher *Fetcher) releaseAssetRedownloader(ctx context.Context, tag string) releaseAssetRedownloader {
+	return func(assetPath string) *FetchError {
+		release, fetchErr := GetGitHubReleaseInfo(ctx, fetcher.repo, tag)
+		if fetchErr != nil {
+			return fetchErr
+		}
+
+		assetName := filepath.Base(assetPath)
+		for _, asset := range release.Assets {
+			if asset.Name != assetName {
+				continue
+			}
+			// The asset may be a hardlink or symlink to the copy in the asset store, which mustn't be overwritten in place
+			if err := os.Remove(assetPath); err != nil && !os.IsNotExist(err) {
+				return wrapError(err)
+			}
+			if fetchErr := redownloadReleaseAsset(ctx, fetcher.repo, &asset, assetPath, fetcher.options.WithProgress); fetchErr != nil {
+				return fetchErr
+			}
+
+			store := fetcher.assetStore
+			if store == nil && fetcher.options.ArchiveCacheDir != "" {
+				store = openAssetStore(fetcher.options.ArchiveCacheDir, fetcher.options.LinkMode)
+			}
+			if store != nil {
+				return wrapError(store.add(releaseAssetUrl(fetcher.repo, &asset), assetPath))
+			}
+			return nil
+		}
+		return newError(failedToDownloadFile, fmt.Sprintf("Release %s has no asset named %s.", tag, assetName))
+	}
+}
+
+// Download any matching files that were uploaded as release assets to the specified GitHub release. The files that the
+// matcher selects are downloaded by a pool of maxConcurrentDownloads go routines (or DefaultMaxConcurrentDownloads, if
+// it's not positive), each of which downloads large assets over the given number of connections at once. If any of the
+// downloads fail, an error will be returned. It is possible that only some of the matching assets were downloaded. For
+// those that succeeded, the path they were downloaded to will be passed back along with the error. If store is not nil,
+// assets already in it are placed in dest instead of being downloaded, and downloaded assets are added to it. Returns
+// the paths where the release assets were downloaded.
+func downloadReleaseAssets(ctx context.Context, logger *logrus.Entry, matcher releaseAssetMatcher, dest destRoot, githubRepo GitHubRepo, tag string, withProgress bool, maxConcurrentDownloads int, connections int, store *assetStore) ([]string, error) {
+	var err error
+	var assetPaths []string
+
+	if !matcher.selectsAssets() {
+		return assetPaths, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	release, releaseInfoErr := GetGitHubReleaseInfo(ctx, githubRepo, tag)
+	if releaseInfoErr != nil {
+		return nil, releaseInfoErr
+	}
+
+	assets, err := matcher.match(logger, release, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queue up every asset for a fixed number of workers so that a release with dozens of assets doesn't saturate the
+	// network or trip GitHub's abuse detection. The workers take the largest assets first, so that when there are more
+	// assets than workers, a big asset doesn't end up as the lone straggler at the end of the run, which minimizes the
+	// total wall-clock time of downloading all assets.
+	sortAssetsBySizeDescending(assets)
+	if maxConcurrentDownloads <= 0 {
+		maxConcurrentDownloads = DefaultMaxConcurrentDownloads
+	}
+	queue := make(chan *GitHubReleaseAsset, len(assets))
+	for _, asset := range assets {
+		queue <- asset
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	results := make(chan AssetDownloadResult, len(assets))
+
+	for i := 0; i < maxConcurrentDownloads && i < len(assets); i++ {
+		wg.Add(1)
+		go func() {
+			// Signal the WaitGroup once this worker has run out of assets to download
+			defer wg.Done()
+
+			for asset := range queue {
+				results <- downloadReleaseAssetToDir(ctx, logger, githubRepo, asset, dest, withProgress, connections, store)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+	logger.Infof("Download of release assets complete\n")
+
+	var errorStrs []string
+	for result := range results {
+		if result.err != nil {
+			errorStrs = append(errorStrs, fmt.Sprintf("%s: %s", result.assetPath, result.err))
+		} else {
+			assetPaths = append(assetPaths, result.assetPath)
+		}
+	}
+
+	if numErrors := len(errorStrs); numErrors > 0 {
+		logger.Errorf("%d errors while downloading assets:\n\t%s", numErrors, strings.Join(errorStrs, "\n\t"))
+	}
+
+	// Downloads that were interrupted have already removed their partial files, so only report the cancellation
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return assetPaths, ctxErr
+	}
+
+	return assetPaths, err
+}
+
+// Download a single release asset into dest, for use by the workers in downloadReleaseAssets
+func downloadReleaseAssetToDir(ctx context.Context, logger *logrus.Entry, githubRepo GitHubRepo, asset *GitHubReleaseAsset, dest destRoot, withProgress bool, connections int, store *assetStore) AssetDownloadResult {
+	// Asset names come from the GitHub API, so make sure they can't be used to write outside of dest
+	assetPath, err := dest.filePath(asset.Name)
+	if err != nil {
+		return AssetDownloadResult{path.Join(dest.dir, asset.Name), err}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return AssetDownloadResult{assetPath, err}
+	}
+
+	if store != nil {
+		placed, err := store.place(releaseAssetUrl(githubRepo, asset), assetPath)
+		if err != nil {
+			return AssetDownloadResult{assetPath, err}
+		}
+		if placed {
+			logger.Infof("Reused release asset %s, which was already downloaded, at %s\n", asset.Name, assetPath)
+			return AssetDownloadResult{assetPath, nil}
+		}
+	}
+
+	logger.Infof("Downloading release asset %s to %s\n", asset.Name, assetPath)
+	if downloadErr := downloadReleaseAssetInChunks(ctx, githubRepo, asset, assetPath, connections, withProgress); downloadErr != nil {
+		logger.Infof("Download failed for %s: %s\n", asset.Name, downloadErr)
+		return AssetDownloadResult{assetPath, downloadErr}
+	}
+
+	logger.Infof("Downloaded %s\n", assetPath)
+	if store != nil {
+		if err := store.add(releaseAssetUrl(githubRepo, asset), assetPath); err != nil {
+			return AssetDownloadResult{assetPath, err}
+		}
+	}
+	return AssetDownloadResult{assetPath, nil}
+}
+
+func findAssetsInRelease(assetRegex string, release GitHubReleaseApiResponse) ([](*GitHubReleaseAsset), error) {
+	var matches [](*GitHubReleaseAsset)
+
+	pattern, err := regexp.Compile(assetRegex)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse provided release asset regex: %s", err.Error())
+	}
+
+	for _, asset := range release.Assets {
+		matched := pattern.MatchString(asset.Name)
+		if matched {
+			assetRef := asset
+			matches = append(matches, &assetRef)
+		} else if asset.Name == assetRegex {
+			// Sometimes the actual asset name contains regex symbols that could mess up matching.
+			// Perform a direct comparison as a last resort.
+			assetRef := asset
+			matches = append(matches, &assetRef)
+		}
+	}
+
+	return matches, nil
+}
+
+// Upload the given release assets to the S3 location in options.PublishS3 and write a presigned download URL for each
+// one to the given writer, one per line. Returns the presigned URLs.
+func publishToS3(ctx context.Context, logger *logrus.Entry, options Options, assetPaths []string, writer io.Writer) ([]string, error) {
+	location, err := ParseS3Location(options.PublishS3)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := getAwsCredentialsFromEnv("The --publish-s3 flag")
+	if err != nil {
+		return nil, err
+	}
+
+	publisher := S3Publisher{
+		Location:    location,
+		Region:      getAwsRegion(options.PublishS3Region),
+		Credentials: creds,
+		UrlExpiry:   options.PublishS3UrlExpiry,
+	}
+
+	presignedUrls, err := publishReleaseAssetsToS3(ctx, logger, publisher, assetPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, presignedUrl := range presignedUrls {
+		fmt.Fprintln(writer, presignedUrl)
+	}
+	return presignedUrls, nil
+}
+
+// Sort the given assets in place so that the largest assets come first. Assets of equal size keep their relative order.
+func sortAssetsBySizeDescending(assets [](*GitHubReleaseAsset)) {
+	sort.SliceStable(assets, func(i, j int) bool {
+		return assets[i].Size > assets[j].Size
+	})
+}
+
+// Delete the given zip file.
+// Download the zip archive of the given commit, or reuse it from the archive cache, if the ArchiveCacheDir option is
+// set and some earlier fetch downloaded the same commit, whatever branch or tag it was fetched by. tagCommitSha is the
+// commit the commit's tag points to, if known, which saves looking it up. Returns the path of the archive and a
+// function to call when done with it.
+func (fetcher *Fetcher) downloadArchive(ctx context.Context, gitHubCommit GitHubCommit, tagCommitSha string) (string, func(), error) {
+	logger := fetcher.logger
+	repo := fetcher.repo
+
+	if fetcher.options.ArchiveCacheDir == "" {
+		localZipFilePath, err := downloadGithubZipFile(ctx, logger, gitHubCommit, repo.Token, fetcher.instance)
+		if err != nil {
+			return "", nil, fmt.Errorf("Error occurred while downloading zip file from GitHub repo: %s", err)
+		}
+		return localZipFilePath, func() { cleanupZipFile(localZipFilePath) }, nil
+	}
+
+	commitSha, fetchErr := fetcher.archiveCommitSha(ctx, gitHubCommit, tagCommitSha)
+	if fetchErr != nil {
+		return "", nil, fmt.Errorf("Error occurred while looking up the commit to download from GitHub repo: %s", fetchErr)
+	}
+
+	cache := archiveCache{dir: fetcher.options.ArchiveCacheDir}
+	if cachedPath, ok := cache.get(repo, commitSha); ok {
+		logger.Infof("Using cached archive of commit %s at %s\n", commitSha, cachedPath)
+		return cachedPath, func() {}, nil
+	}
+
+	// Download the commit itself, rather than the branch or tag, so that the archive matches its cache key even if the
+	// branch moves in the meantime
+	commitToDownload := gitHubCommit
+	commitToDownload.CommitSha = commitSha
+	localZipFilePath, err := downloadGithubZipFile(ctx, logger, commitToDownload, repo.Token, fetcher.instance)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error occurred while downloading zip file from GitHub repo: %s", err)
+	}
+	defer cleanupZipFile(localZipFilePath)
+
+	cachedPath, cacheErr := cache.put(repo, commitSha, localZipFilePath)
+	if cacheErr != nil {
+		return "", nil, fmt.Errorf("Error occurred while caching zip file in %s: %s", fetcher.options.ArchiveCacheDir, cacheErr)
+	}
+	logger.Debugf("Cached archive of commit %s at %s\n", commitSha, cachedPath)
+	return cachedPath, func() {}, nil
+}
+
+// Return the full SHA of the given commit, looking it up with the GitHub API unless it's already known. The commit's
+// fields take the same precedence as in MakeGitHubZipFileRequest.
+func (fetcher *Fetcher) archiveCommitSha(ctx context.Context, gitHubCommit GitHubCommit, tagCommitSha string) (string, *FetchError) {
+	var ref string
+	switch {
+	case gitHubCommit.CommitSha != "":
+		if fullCommitShaRegex.MatchString(gitHubCommit.CommitSha) {
+			return gitHubCommit.CommitSha, nil
+		}
+		ref = gitHubCommit.CommitSha
+	case gitHubCommit.BranchName != "":
+		ref = gitHubCommit.BranchName
+	case gitHubCommit.GitTag != "":
+		if tagCommitSha != "" {
+			return tagCommitSha, nil
+		}
+		ref = gitHubCommit.GitTag
+	default:
+		ref = gitHubCommit.GitRef
+	}
+
+	commitSha, err := GetCommitSha(ctx, fetcher.repo, ref)
+	if err != nil {
+		return "", err
+	}
+	if !fullCommitShaRegex.MatchString(commitSha) {
+		return "", wrapError(fmt.Errorf("Expected the GitHub API to return the SHA of the commit %s points to, but got \"%s\"", ref, commitSha))
+	}
+	return commitSha, nil
+}
+
+func cleanupZipFile(localZipFilePath string) error {
+	err := os.Remove(localZipFilePath)
+	if err != nil {
+		return fmt.Errorf("Failed to delete local zip file at %s", localZipFilePath)
+	}
+
+	return nil
+}
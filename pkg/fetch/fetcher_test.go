@@ -0,0 +1,267 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Expect to download 2 assets:
+// - health-checker_linux_386
+// - health-checker_linux_amd64
+const SAMPLE_RELEASE_ASSET_REGEX = "health-checker_linux_[a-z0-9]+"
+
+func TestDownloadReleaseAssets(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	assetPaths, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: SAMPLE_RELEASE_ASSET_REGEX}, newDestRoot(tmpDir, false), githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false, 0, 1, nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to download release asset: %s", fetchErr)
+	}
+
+	if len(assetPaths) != 2 {
+		t.Fatalf("Expected to download 2 assets, not %d", len(assetPaths))
+	}
+
+	for _, assetPath := range assetPaths {
+		if _, err := os.Stat(assetPath); os.IsNotExist(err) {
+			t.Fatalf("Downloaded file should exist at %s", assetPath)
+		} else {
+			fmt.Printf("Verified the downloaded asset exists at %s\n", assetPath)
+		}
+	}
+}
+
+func TestDownloadReleaseAssetsWithRegexCharacters(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	const githubRepoUrl = "https://github.com/gruntwork-io/fetch-test-public"
+	const releaseAsset = "hello+world.txt"
+	const assetVersion = "v0.0.4"
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(githubRepoUrl, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	assetPaths, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: releaseAsset}, newDestRoot(tmpDir, false), githubRepo, assetVersion, false, 0, 1, nil)
+	if fetchErr != nil {
+		t.Fatalf("Failed to download release asset: %s", fetchErr)
+	}
+
+	if len(assetPaths) != 1 {
+		t.Fatalf("Expected to download 1 assets, not %d", len(assetPaths))
+	}
+
+	assetPath := assetPaths[0]
+
+	if _, err := os.Stat(assetPath); os.IsNotExist(err) {
+		t.Fatalf("Downloaded file should exist at %s", assetPath)
+	} else {
+		fmt.Printf("Verified the downloaded asset exists at %s\n", assetPath)
+	}
+}
+
+func TestInvalidReleaseAssetsRegex(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	_, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: "*"}, newDestRoot(tmpDir, false), githubRepo, SAMPLE_RELEASE_ASSET_VERSION, false, 0, 1, nil)
+	if fetchErr == nil {
+		t.Fatalf("Expected error for invalid regex")
+	}
+}
+
+func TestInvalidReleaseAssetTag(t *testing.T) {
+	tmpDir := mkTempDir(t)
+	logger := GetProjectLogger()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	githubRepo, err := ParseUrlIntoGitHubRepo(SAMPLE_RELEASE_ASSET_GITHUB_REPO_URL, "", testInst)
+	if err != nil {
+		t.Fatalf("Failed to parse sample release asset GitHub URL into Fetch GitHubRepo struct: %s", err)
+	}
+
+	_, fetchErr := downloadReleaseAssets(context.Background(), logger, releaseAssetMatcher{regex: SAMPLE_RELEASE_ASSET_REGEX}, newDestRoot(tmpDir, false), githubRepo, "6.6.6", false, 0, 1, nil)
+	assert.Error(t, fetchErr)
+}
+
+func TestSortAssetsBySizeDescending(t *testing.T) {
+	t.Parallel()
+
+	assets := [](*GitHubReleaseAsset){
+		{Name: "small", Size: 10},
+		{Name: "large", Size: 1000},
+		{Name: "medium-1", Size: 100},
+		{Name: "medium-2", Size: 100},
+	}
+
+	sortAssetsBySizeDescending(assets)
+
+	var names []string
+	for _, asset := range assets {
+		names = append(names, asset.Name)
+	}
+	assert.Equal(t, []string{"large", "medium-1", "medium-2", "small"}, names)
+}
+
+func TestDownloadReleaseAssetsLargestFirst(t *testing.T) {
+	t.Parallel()
+
+	release := GitHubReleaseApiResponse{Id: 1, TagName: "v1.0.0", Assets: []GitHubReleaseAsset{
+		{Id: 1, Name: "small", Size: 10},
+		{Id: 2, Name: "large", Size: 1000},
+		{Id: 3, Name: "medium", Size: 100},
+	}}
+
+	var lock sync.Mutex
+	var downloaded []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/repos/foo/bar/releases/tags/v1.0.0":
+			json.NewEncoder(w).Encode(release)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"):
+			var id int
+			fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"), &id)
+			lock.Lock()
+			downloaded = append(downloaded, release.Assets[id-1].Name)
+			lock.Unlock()
+			w.Write([]byte(release.Assets[id-1].Name))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// With fewer workers than assets, the workers take the assets from the queue largest first
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:                server.URL + "/foo/bar",
+		GithubApiVersion:       "v3",
+		AllReleaseAssets:       true,
+		MaxConcurrentDownloads: 1,
+		LocalDownloadPath:      t.TempDir(),
+		Connection:             ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+	})
+	require.NoError(t, err)
+	assetPaths, err := fetcher.DownloadReleaseAssets(context.Background(), "v1.0.0")
+	require.NoError(t, err)
+	assert.Len(t, assetPaths, 3)
+	assert.Equal(t, []string{"large", "medium", "small"}, downloaded)
+}
+
+func TestRenameReleaseAssets(t *testing.T) {
+	t.Parallel()
+
+	destPath := t.TempDir()
+	var assetPaths []string
+	for _, name := range []string{"terragrunt_linux_amd64", "SHA256SUMS"} {
+		assetPath := filepath.Join(destPath, name)
+		assert.NoError(t, ioutil.WriteFile(assetPath, []byte(name), 0644))
+		assetPaths = append(assetPaths, assetPath)
+	}
+
+	renamedPaths, err := renameReleaseAssets(GetProjectLogger(), assetPaths, newDestRoot(destPath, false), []string{"terragrunt_linux_amd64=bin/terragrunt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(destPath, "bin", "terragrunt"), filepath.Join(destPath, "SHA256SUMS")}, renamedPaths)
+	assert.FileExists(t, filepath.Join(destPath, "bin", "terragrunt"))
+	assert.NoFileExists(t, filepath.Join(destPath, "terragrunt_linux_amd64"))
+}
+
+func TestNewFetchedFile(t *testing.T) {
+	t.Parallel()
+
+	filePath := filepath.Join(t.TempDir(), "hello.txt")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte("hello world\n"), 0644))
+
+	withoutChecksum, err := (&Fetcher{}).newFetchedFile(FetchedReleaseAsset, filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, FetchedFile{Kind: FetchedReleaseAsset, Path: filePath, Size: 12}, withoutChecksum)
+
+	withChecksum, err := (&Fetcher{options: Options{RecordChecksums: true}}).newFetchedFile(FetchedSourceFile, filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447", withChecksum.Sha256)
+}
+
+func TestReleaseAssetRegex(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", Options{}.releaseAssetRegex())
+	assert.Equal(t, "foo_.*", Options{ReleaseAsset: "foo_.*"}.releaseAssetRegex())
+	assert.Equal(t, ".*", Options{AllReleaseAssets: true}.releaseAssetRegex())
+}
+
+func TestResolveTagWhenTagsCannotBeListed(t *testing.T) {
+	t.Parallel()
+
+	// Nothing listens on this port, so listing the tags fails
+	repo := GitHubRepo{BaseUrl: "github.com", ApiUrl: "127.0.0.1:1", Owner: "foo", Name: "bar"}
+
+	testCases := []struct {
+		name      string
+		options   Options
+		expected  ResolvedTag
+		expectErr bool
+	}{
+		{"exact-tag", Options{TagConstraint: "v0.1.0"}, ResolvedTag{Tag: "v0.1.0"}, false},
+		{"exact-ref", Options{GitRef: "=v0.1.0"}, ResolvedTag{Tag: "v0.1.0"}, false},
+		{"commit", Options{CommitSha: "abc123"}, ResolvedTag{}, false},
+		{"branch", Options{BranchName: "main"}, ResolvedTag{}, false},
+		{"tag-constraint", Options{TagConstraint: "~>0.1.0"}, ResolvedTag{}, true},
+		{"commit-with-tag-constraint", Options{CommitSha: "abc123", TagConstraint: ">=0.1.0"}, ResolvedTag{}, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fetcher := &Fetcher{options: tc.options, logger: GetProjectLogger(), repo: repo}
+			resolvedTag, err := fetcher.ResolveTag(context.Background())
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, resolvedTag)
+		})
+	}
+}
@@ -0,0 +1,437 @@
+package fetch
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Download the zip file at the given URL to a temporary local directory.
+// Returns the absolute path to the downloaded zip file.
+// IMPORTANT: You must call "defer os.RemoveAll(dir)" in the calling function when done with the downloaded zip file!
+// If the download fails or is canceled, the temporary directory is removed before returning.
+func downloadGithubZipFile(ctx context.Context, logger *logrus.Entry, gitHubCommit GitHubCommit, gitHubToken string, instance GitHubInstance) (string, *FetchError) {
+
+	var zipFilePath string
+
+	// Create a temp directory
+	// Note that ioutil.TempDir has a peculiar interface. We need not specify any meaningful values to achieve our
+	// goal of getting a temporary directory.
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return zipFilePath, wrapError(err)
+	}
+	downloaded := false
+	defer func() {
+		if !downloaded {
+			os.RemoveAll(tempDir)
+		}
+	}()
+
+	// Download the zip file, possibly using the GitHub oAuth Token
+	httpClient := newHttpClient(ctx)
+	req, err := makeGitHubZipFileRequest(ctx, gitHubCommit, gitHubToken, instance)
+	if err != nil {
+		return zipFilePath, wrapError(err)
+	}
+
+	logger.Debugf("Performing HTTP request to download GitHub ZIP Archive: %s", req.URL)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return zipFilePath, wrapError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return zipFilePath, newError(failedToDownloadFile, fmt.Sprintf("Failed to download file at the url %s. Received HTTP Response %d.", req.URL.String(), resp.StatusCode))
+	}
+	if resp.Header.Get("Content-Type") != "application/zip" {
+		return zipFilePath, newError(failedToDownloadFile, fmt.Sprintf("Failed to download file at the url %s. Expected HTTP Response's \"Content-Type\" header to be \"application/zip\", but was \"%s\"", req.URL.String(), resp.Header.Get("Content-Type")))
+	}
+
+	// Stream the contents of the downloaded file straight to disk, as the archives of large repos may not fit in memory
+	logger.Debugf("Writing ZIP Archive to temporary path: %s", tempDir)
+	out, err := os.Create(filepath.Join(tempDir, "repo.zip"))
+	if err != nil {
+		return zipFilePath, wrapError(err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return zipFilePath, wrapError(err)
+	}
+
+	zipFilePath = filepath.Join(tempDir, "repo.zip")
+	downloaded = true
+
+	return zipFilePath, nil
+}
+
+func shouldExtractPathInZip(pathPrefix string, zipPath *zip.File) bool {
+	//
+	// We need to return true (i.e extract file) based on the following conditions:
+	//
+	// The current archive item is a directory.
+	//     Archive item's path name will always be appended with a "/", so we use
+	//     this fact to ensure we are working with a full directory name.
+	//     Extract the file if (pathPrefix + "/") is a prefix in path name
+	//
+	// The current archive item is a file.
+	// 		There are two things possible here:
+	//		1  User specified a filename that is an exact match for the current archive file,
+	//         we need to extract this file.
+	//      2  The current archive filename is not a exact match to the user supplied filename.
+	//		   Check if (pathPrefix + "/") is a prefix in f.Name, if yes, we extract this file.
+
+	zipPathIsFile := !zipPath.FileInfo().IsDir()
+	return (zipPathIsFile && zipPath.Name == pathPrefix) || strings.Index(zipPath.Name, pathPrefix+"/") == 0
+}
+
+// Options that control how files are extracted from a repo's zip archive
+type ExtractOptions struct {
+	// If true, apply the Unix permission bits stored in the zip archive (e.g. the executable bit on scripts) to each
+	// extracted file. Otherwise, files are written with mode 0644.
+	PreservePermissions bool
+
+	// If true, recreate symbolic links stored in the zip archive as symbolic links on disk. Otherwise, each symbolic
+	// link is written as a regular file containing the link target.
+	PreserveSymlinks bool
+
+	// The mode with which to create directories. If zero, directories are created with mode 0777. Either way, the
+	// process umask is applied.
+	DirMode os.FileMode
+
+	// The mode with which to create files. If zero, the default mode (or the mode stored in the archive, if preserving
+	// permissions) is used. Either way, the process umask is applied.
+	FileMode os.FileMode
+
+	// Globs (e.g. "bin/*") of the members to write when unpacking a release asset archive. A member is written if its
+	// path, or the path of any directory it's in, matches one of the globs. If empty, every member is written. Only
+	// applies to release asset archives, not to files extracted from the repo.
+	Include []string
+
+	// The number of leading path components to strip from the name of each file extracted from the repo, relative to
+	// the source path it's extracted from, as with tar's --strip-components. Files and directories with no more
+	// components than this are skipped. Only applies to files extracted from the repo, not to release asset archives.
+	StripComponents int
+
+	// If true, write every file extracted from the repo directly into the local path under its base name, rather than
+	// recreating the directories it's in. Only applies to files extracted from the repo, not to release asset archives.
+	Flatten bool
+
+	// Renames, as parsed by ParseRenames, of the files and directories extracted from the repo, applied after
+	// StripComponents and Flatten. Only applies to files extracted from the repo, not to release asset archives.
+	Renames map[string]string
+
+	// If true, write through symbolic links that already exist in the local path, even if they point outside of it.
+	// Otherwise, any write that would go through such a link is refused.
+	FollowDestSymlinks bool
+}
+
+// Parse renames of the form "src=dest", where src is the path, relative to the local download path, that a file or
+// directory would otherwise be written to, and dest is the path to write it to instead. Returns the renames keyed by src.
+func ParseRenames(values []string) (map[string]string, error) {
+	renames := map[string]string{}
+	for _, value := range values {
+		src, dest, found := strings.Cut(value, "=")
+		src = path.Clean(strings.Trim(src, "/"))
+		dest = path.Clean(strings.Trim(dest, "/"))
+		if !found || src == "." || dest == "." {
+			return nil, fmt.Errorf("The rename \"%s\" must be of the form src=dest (e.g. tool_linux_amd64=tool).", value)
+		}
+		if dest == ".." || strings.HasPrefix(dest, "../") {
+			return nil, fmt.Errorf("The rename \"%s\" would write %s outside of the local download path.", value, src)
+		}
+		if _, exists := renames[src]; exists {
+			return nil, fmt.Errorf("%s is renamed more than once.", src)
+		}
+		renames[src] = dest
+	}
+	return renames, nil
+}
+
+// Return the given slash-separated name with the first of the given renames that matches it, or the directory it's in
+// closest to it, applied
+func renamePath(name string, renames map[string]string) string {
+	for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if dest, found := renames[dir]; found {
+			return path.Join(dest, strings.TrimPrefix(name, dir))
+		}
+	}
+	return name
+}
+
+// Return the slash-separated name, relative to the local path, under which the file or directory extracted from the
+// repo with the given name is written, according to the StripComponents, Flatten, and Renames options, or false if it
+// should be skipped
+func (options ExtractOptions) destName(name string, isDir bool) (string, bool) {
+	name, keep := options.stripComponents(name)
+	if !keep || name == "" {
+		return name, keep
+	}
+
+	name = strings.Trim(name, "/")
+	if options.Flatten {
+		if isDir {
+			return "", false
+		}
+		name = path.Base(name)
+	}
+	return renamePath(name, options.Renames), true
+}
+
+// Record that the file extracted from srcName is written under name, and return an error if another file already is,
+// as can happen when flattening or renaming files
+func claimDestName(claimed map[string]string, srcName string, name string) error {
+	if otherSrcName, found := claimed[name]; found {
+		return fmt.Errorf("Both %s and %s would be written to %s. Use --rename to give one of them another name.", otherSrcName, srcName, name)
+	}
+	claimed[name] = srcName
+	return nil
+}
+
+// Strip the leading path components from the given slash-separated name according to the StripComponents option, and
+// return the name that's left, or false if nothing is left, in which case the file or directory should be skipped. An
+// empty name, which refers to the local path itself, is never stripped.
+func (options ExtractOptions) stripComponents(name string) (string, bool) {
+	if options.StripComponents <= 0 || name == "" {
+		return name, true
+	}
+	components := strings.Split(strings.Trim(name, "/"), "/")
+	if len(components) <= options.StripComponents {
+		return "", false
+	}
+	return path.Join(components[options.StripComponents:]...), true
+}
+
+// Return the mode with which directories should be created
+func (options ExtractOptions) getDirMode() os.FileMode {
+	if options.DirMode != 0 {
+		return options.DirMode
+	}
+	return 0777
+}
+
+// Parse a file mode passed in as an octal string (e.g. "0750"). An empty string parses to zero, meaning the default.
+func ParseFileMode(optionName string, value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("The --%s value \"%s\" must be an octal permission mode between 0001 and 0777 (e.g. 0750).", optionName, value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Return the mode with which the given zip archive entry should be written to disk. Note that the process umask is
+// still applied when the file is created, just as it would be for any other file.
+func getExtractedFileMode(f *zip.File, options ExtractOptions) os.FileMode {
+	if options.FileMode != 0 {
+		return options.FileMode
+	}
+	if options.PreservePermissions {
+		// Archives created on systems without Unix permissions (e.g. Windows) have no mode bits, so fall back to
+		// the default in that case
+		if mode := f.Mode().Perm(); mode != 0 {
+			return mode
+		}
+	}
+	return 0644
+}
+
+// Decompress the file at zipFileAbsPath and move only those files under filesToExtractFromZipPath to localPath
+func extractFiles(zipFilePath, filesToExtractFromZipPath, localPath string, options ExtractOptions) (int, error) {
+
+	// Open the zip file for reading.
+	r, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	// pathPrefix represents the portion of the local file path we will ignore when copying the file to localPath
+	// E.g. full path = fetch-test-public-0.0.3/folder/file1.txt
+	//      path prefix = fetch-test-public-0.0.3
+	//      file that will eventually get written = <localPath>/folder/file1.txt
+
+	if len(r.File) == 0 {
+		return 0, fmt.Errorf("The zip file %s is empty", zipFilePath)
+	}
+
+	// By convention, the first file in the zip file is the top-level directory
+	pathPrefix := r.File[0].Name
+
+	// Add the path from which we will extract files to the path prefix so we can exclude the appropriate files
+	pathPrefix = filepath.Join(pathPrefix, filesToExtractFromZipPath)
+
+	// Every write goes through root, so that no entry can be written outside of localPath
+	root := newDestRoot(localPath, options.FollowDestSymlinks)
+
+	// Count the number of files (not directories) unpacked
+	fileCount := 0
+
+	// The entries each file is written from, by the name it's written under
+	claimed := map[string]string{}
+
+	// Iterate through the files in the archive,
+	// printing some of their contents.
+	for _, f := range r.File {
+
+		// check if current archive file needs to be extracted
+		if shouldExtractPathInZip(pathPrefix, f) {
+
+			// The name of the entry relative to localPath. This is empty if a single file is being extracted, in which
+			// case localPath is the path of the file itself.
+			name, keep := options.destName(strings.TrimPrefix(strings.TrimPrefix(f.Name, pathPrefix), "/"), f.FileInfo().IsDir())
+			if !keep {
+				continue
+			}
+
+			if f.FileInfo().IsDir() {
+				// Create a directory
+				if err := root.MkdirAll(name, options.getDirMode()); err != nil {
+					return fileCount, err
+				}
+				continue
+			}
+
+			if err := claimDestName(claimed, f.Name, name); err != nil {
+				return fileCount, err
+			}
+			// Renamed files may be written to directories the archive doesn't have
+			if len(options.Renames) > 0 && name != "" {
+				if err := root.MkdirAll(path.Dir(name), options.getDirMode()); err != nil {
+					return fileCount, err
+				}
+			}
+
+			if options.PreserveSymlinks && f.Mode()&os.ModeSymlink != 0 {
+				if err := extractSymlink(f, root, name); err != nil {
+					return fileCount, err
+				}
+				fileCount++
+			} else {
+				// Write the file
+				if err := extractFile(f, root, name, getExtractedFileMode(f, options)); err != nil {
+					return fileCount, err
+				}
+				fileCount++
+			}
+		}
+	}
+
+	return fileCount, nil
+}
+
+// Stream the contents of the given zip archive entry to the file with the given name in root, so that large files are
+// never held in memory
+func extractFile(f *zip.File, root destRoot, name string, mode os.FileMode) error {
+	readCloser, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("Failed to open file %s: %s", f.Name, err)
+	}
+	defer readCloser.Close()
+
+	out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("Failed to write file: %s", err)
+	}
+
+	if _, err := io.Copy(out, readCloser); err != nil {
+		out.Close()
+		return fmt.Errorf("Failed to write file: %s", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("Failed to write file: %s", err)
+	}
+	return nil
+}
+
+// The longest symbolic link target we're willing to read from a zip archive. This is well above PATH_MAX on any
+// common OS, and protects against a malicious archive posing a huge file as a symlink.
+const maxSymlinkTargetLength = 4096
+
+// Recreate the symbolic link stored in the given zip archive entry with the given name in root. Links that are absolute
+// or that would point outside of root are rejected, as they could be used to read or overwrite files outside the
+// destination.
+func extractSymlink(f *zip.File, root destRoot, name string) error {
+	readCloser, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("Failed to open file %s: %s", f.Name, err)
+	}
+	defer readCloser.Close()
+
+	targetBytes, err := ioutil.ReadAll(io.LimitReader(readCloser, maxSymlinkTargetLength+1))
+	if err != nil {
+		return fmt.Errorf("Failed to read symbolic link %s: %s", f.Name, err)
+	}
+	if len(targetBytes) > maxSymlinkTargetLength {
+		return fmt.Errorf("Symbolic link %s has a target longer than %d bytes", f.Name, maxSymlinkTargetLength)
+	}
+	target := string(targetBytes)
+
+	// If a single symlink is being extracted, the root is the path of the link itself
+	if name == "" {
+		name = filepath.Base(root.dir)
+		root = newDestRoot(filepath.Dir(root.dir), root.followSymlinks)
+	}
+
+	// Anything that already exists at the link path is replaced, just as we would overwrite a regular file
+	return root.Symlink(target, name)
+}
+
+// Return true if the symbolic link at linkPath with the given target resolves to a path within root
+func isSymlinkTargetWithinRoot(root string, linkPath string, target string) bool {
+	if target == "" || filepath.IsAbs(target) {
+		return false
+	}
+
+	resolved := filepath.Join(filepath.Dir(linkPath), target)
+	relPath, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return false
+	}
+	return relPath != ".." && !strings.HasPrefix(relPath, ".."+string(os.PathSeparator))
+}
+
+// Return an HTTP request that will fetch the given GitHub repo's zip file for the given tag, possibly with the gitHubOAuthToken in the header
+// Respects the GitHubCommit hierachy as defined in the code comments for GitHubCommit (e.g. GitTag > CommitSha)
+func MakeGitHubZipFileRequest(gitHubCommit GitHubCommit, gitHubToken string, instance GitHubInstance) (*http.Request, error) {
+	return makeGitHubZipFileRequest(context.Background(), gitHubCommit, gitHubToken, instance)
+}
+
+// Same as MakeGitHubZipFileRequest, but the request is made with the given context, and sends the token with the auth
+// scheme of the context's connection
+func makeGitHubZipFileRequest(ctx context.Context, gitHubCommit GitHubCommit, gitHubToken string, instance GitHubInstance) (*http.Request, error) {
+	var request *http.Request
+
+	// This represents either a commit, branch, or git tag
+	gitRef := gitHubCommit.ref()
+	if gitRef == "" {
+		return request, fmt.Errorf("Neither a GitCommitSha nor a GitTag nor a BranchName were specified so impossible to identify a specific commit to download.")
+	}
+
+	url := fmt.Sprintf("https://%s/repos/%s/%s/zipball/%s", instance.ApiUrl, gitHubCommit.Repo.Owner, gitHubCommit.Repo.Name, gitRef)
+
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return request, wrapError(err)
+	}
+
+	if gitHubToken != "" {
+		request.Header.Set("Authorization", authorizationHeader(gitHubToken, connectionFrom(ctx).authScheme))
+	}
+
+	return request, nil
+}
@@ -0,0 +1,866 @@
+package fetch
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Although other tests besides those in this file require this env var, this init() func will cover all tests.
+func init() {
+	if os.Getenv("GITHUB_OAUTH_TOKEN") == "" {
+		fmt.Println("ERROR: These tests require that env var GITHUB_OAUTH_TOKEN be set to a GitHub Personal Access Token.")
+		fmt.Println("See the tests cases to see which GitHub repos the oAuth token needs access to.")
+		os.Exit(1)
+	}
+}
+
+func TestDownloadGitTagZipFile(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	enterpriseGitHubExample := GitHubInstance{
+		BaseUrl: "github.acme.com",
+		ApiUrl:  "github.acme.com/api/v3",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		gitTag      string
+		githubToken string
+	}{
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "v0.0.1", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-private", "v0.0.2", os.Getenv("GITHUB_OAUTH_TOKEN")},
+		{enterpriseGitHubExample, "temp-internal-org", "bash-commons", "v0.0.4", os.Getenv("GITHUB_OAUTH_TOKEN")},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		gitHubCommits := []GitHubCommit{
+			// Test as a GitTag
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitTag: tc.gitTag,
+			},
+			// Test as a GitRef
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.gitTag,
+			},
+		}
+		for _, gitHubCommit := range gitHubCommits {
+			zipFilePath, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+
+			defer os.RemoveAll(zipFilePath)
+
+			// We don't have a running instance of GitHub Enterprise against which to validate tests as we do for GitHub public,
+			// so this test will only validate that fetch attempted to download from the expected URL. The download itself
+			// will fail.
+
+			githubEnterpriseDownloadUrl := fmt.Sprintf("https://%s/repos/%s/%s/zipball/%s", tc.instance.ApiUrl, tc.repoOwner, tc.repoName, tc.gitTag)
+
+			// TODO: The awkwardness of this test makes it clear that a better structure for this program would be to refactor
+			// the downloadGithubZipFile() function to a function called downloadGithubFile() that would accept a URL as a
+			// param. We could then test explicitly that the URL is as expected, which would make GitHub Enterprise test cases
+			// simpler to handle.
+
+			if err != nil && strings.Contains(err.Error(), "no such host") {
+				if strings.Contains(err.Error(), githubEnterpriseDownloadUrl) {
+					t.Logf("Found expected download URL %s. Download itself failed as expected because no GitHub Enterprise instance exists at the given URL.", githubEnterpriseDownloadUrl)
+					return
+				} else {
+					t.Fatalf("Attempted to download from URL other than the expected download URL of %s. Full error: %s", githubEnterpriseDownloadUrl, err.Error())
+				}
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to download file: %s", err)
+			}
+
+			if _, err := os.Stat(zipFilePath); os.IsNotExist(err) {
+				t.Fatalf("Downloaded file doesn't exist at the expected path of %s", zipFilePath)
+			}
+		}
+	}
+}
+
+func TestDownloadGitBranchZipFile(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		branchName  string
+		githubToken string
+	}{
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "sample-branch", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-private", "sample-branch", os.Getenv("GITHUB_OAUTH_TOKEN")},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		gitHubCommits := []GitHubCommit{
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				BranchName: tc.branchName,
+			},
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.branchName,
+			},
+		}
+		for _, gitHubCommit := range gitHubCommits {
+			zipFilePath, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+			defer os.RemoveAll(zipFilePath)
+			if err != nil {
+				t.Fatalf("Failed to download file: %s", err)
+			}
+
+			if _, err := os.Stat(zipFilePath); os.IsNotExist(err) {
+				t.Fatalf("Downloaded file doesn't exist at the expected path of %s", zipFilePath)
+			}
+		}
+	}
+}
+
+func TestDownloadBadGitBranchZipFile(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		branchName  string
+		githubToken string
+	}{
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "branch-that-doesnt-exist", ""},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		gitHubCommits := []GitHubCommit{
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				BranchName: tc.branchName,
+			},
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.branchName,
+			},
+		}
+		for _, gitHubCommit := range gitHubCommits {
+			zipFilePath, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+			defer os.RemoveAll(zipFilePath)
+			if err == nil {
+				t.Fatalf("Expected that attempt to download repo %s/%s for branch \"%s\" would fail, but received no error.", tc.repoOwner, tc.repoName, tc.branchName)
+			}
+		}
+	}
+}
+
+func TestDownloadGitCommitFile(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		commitSha   string
+		githubToken string
+	}{
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "d2de34edb4c6564e0674b3f390b3b1fb0468183a", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "57752e7f1df0acbd3c1e61545d5c4d0e87699d84", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "f32a08313e30f116a1f5617b8b68c11f1c1dbb61", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-private", "676cfb92b54d33538c756c7a9479bfc3f6b44de2", os.Getenv("GITHUB_OAUTH_TOKEN")},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		GitHubCommits := []GitHubCommit{
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				CommitSha: tc.commitSha,
+			},
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.commitSha,
+			},
+		}
+		for _, gitHubCommit := range GitHubCommits {
+			zipFilePath, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+			defer os.RemoveAll(zipFilePath)
+			if err != nil {
+				t.Fatalf("Failed to download file: %s", err)
+			}
+
+			if _, err := os.Stat(zipFilePath); os.IsNotExist(err) {
+				t.Fatalf("Downloaded file doesn't exist at the expected path of %s", zipFilePath)
+			}
+		}
+	}
+}
+
+func TestDownloadBadGitCommitFile(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		commitSha   string
+		githubToken string
+	}{
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "hello-world", ""},
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "i-am-a-non-existent-commit", ""},
+		// remove a single letter from the beginning of an otherwise legit commit sha
+		// interestingly, through testing I found that GitHub will attempt to find the right commit sha if you
+		// truncate the end of it.
+		{publicGitHub, "gruntwork-io", "fetch-test-public", "7752e7f1df0acbd3c1e61545d5c4d0e87699d84", ""},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		gitHubCommits := []GitHubCommit{
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				CommitSha: tc.commitSha,
+			},
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.commitSha,
+			},
+		}
+		for _, gitHubCommit := range gitHubCommits {
+			zipFilePath, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+			defer os.RemoveAll(zipFilePath)
+			if err == nil {
+				t.Fatalf("Expected that attempt to download repo %s/%s at commmit sha \"%s\" would fail, but received no error.", tc.repoOwner, tc.repoName, tc.commitSha)
+			}
+		}
+	}
+}
+
+func TestDownloadZipFileWithBadRepoValues(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance    GitHubInstance
+		repoOwner   string
+		repoName    string
+		gitTag      string
+		githubToken string
+	}{
+		{publicGitHub, "https://github.com/gruntwork-io/fetch-test-public/archive/does-not-exist.zip", "MyNameIsWhat", "x.y.z", ""},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		gitHubCommits := []GitHubCommit{
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitTag: tc.gitTag,
+			},
+			GitHubCommit{
+				Repo: GitHubRepo{
+					Owner: tc.repoOwner,
+					Name:  tc.repoName,
+				},
+				GitRef: tc.gitTag,
+			},
+		}
+		for _, gitHubCommit := range gitHubCommits {
+
+			_, err := downloadGithubZipFile(context.Background(), logger, gitHubCommit, tc.githubToken, tc.instance)
+			if err == nil && err.errorCode != 500 {
+				t.Fatalf("Expected error for bad repo values: %s/%s:%s", tc.repoOwner, tc.repoName, tc.gitTag)
+			}
+		}
+	}
+}
+
+func TestExtractFiles(t *testing.T) {
+	t.Parallel()
+
+	publicGitHub := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		instance          GitHubInstance
+		localFilePath     string
+		filePathToExtract string
+		expectedNumFiles  int
+		nonemptyFiles     []string
+	}{
+		{publicGitHub, "test-fixtures/fetch-test-public-0.0.1.zip", "/", 1, nil},
+		{publicGitHub, "test-fixtures/fetch-test-public-0.0.2.zip", "/", 2, nil},
+		{publicGitHub, "test-fixtures/fetch-test-public-0.0.3.zip", "/", 4, []string{"/README.md"}},
+		{publicGitHub, "test-fixtures/fetch-test-public-0.0.3.zip", "/folder", 2, nil},
+		{publicGitHub, "test-fixtures/fetch-test-public-0.0.4.zip", "/aaa", 2, []string{"/hello.txt", "/subaaa/subhello.txt"}},
+	}
+
+	for _, tc := range cases {
+		// Create a temp directory
+		tempDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatalf("Failed to create temp directory: %s", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		fileCount, err := extractFiles(tc.localFilePath, tc.filePathToExtract, tempDir, ExtractOptions{})
+		if err != nil {
+			t.Fatalf("Failed to extract files: %s", err)
+		}
+
+		if fileCount != tc.expectedNumFiles {
+			t.Fatalf("Expected to extract %d files, extracted %d instead", tc.expectedNumFiles, fileCount)
+		}
+
+		// Count the number of files in the directory
+		var numFiles int
+		filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+			if !info.IsDir() {
+				numFiles++
+			}
+			return nil
+		})
+
+		if numFiles != tc.expectedNumFiles {
+			t.Fatalf("While extracting %s, expected to find %d file(s), but found %d. Local path = %s", tc.localFilePath, tc.expectedNumFiles, numFiles, tempDir)
+		}
+
+		// Ensure that files declared to be non-empty are in fact non-empty
+		filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+			relativeFilename := strings.TrimPrefix(path, tempDir)
+
+			if !info.IsDir() && stringInSlice(relativeFilename, tc.nonemptyFiles) {
+				if info.Size() == 0 {
+					t.Fatalf("Expected %s in %s to have non-zero file size, but found file size = %d.\n", relativeFilename, tc.localFilePath, info.Size())
+				}
+			}
+			return nil
+		})
+
+	}
+}
+
+func TestExtractFilesExtractFile(t *testing.T) {
+	// Create a temp directory
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	zipFilePath := "test-fixtures/fetch-test-public-0.0.4.zip"
+	filePathToExtract := "zzz.txt"
+	localFileName := "/localzzz.txt"
+	expectedFileCount := 1
+	localPathName := filepath.Join(tempDir, localFileName)
+	fileCount, err := extractFiles(zipFilePath, filePathToExtract, localPathName, ExtractOptions{})
+
+	if err != nil {
+		t.Fatalf("Failed to extract files: %s", err)
+	}
+
+	if fileCount != expectedFileCount {
+		t.Fatalf("Expected to extract %d files, extracted %d instead", expectedFileCount, fileCount)
+	}
+
+	filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+		relativeFilename := strings.TrimPrefix(path, tempDir)
+
+		if !info.IsDir() {
+			if relativeFilename != localFileName {
+				t.Fatalf("Expected local file %s to be created, but not found.\n", localFileName)
+			}
+		}
+		return nil
+	})
+}
+
+func TestExtractFilesPreservePermissions(t *testing.T) {
+	t.Parallel()
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	zipFilePath := filepath.Join(tempDir, "repo.zip")
+	zipFile, err := os.Create(zipFilePath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(zipFile)
+	// By convention, the first entry in the zip file is the top-level directory, so this must be an ordered list
+	entries := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{"repo-v1/", os.ModeDir | 0755},
+		{"repo-v1/script.sh", 0755},
+		{"repo-v1/README.md", 0644},
+	}
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name}
+		header.SetMode(entry.mode)
+		_, err := zipWriter.CreateHeader(header)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, zipFile.Close())
+
+	cases := []struct {
+		name                string
+		preservePermissions bool
+		expectedScriptMode  os.FileMode
+	}{
+		{"preserve", true, 0755},
+		{"default", false, 0644},
+	}
+
+	for _, tc := range cases {
+		localPath := filepath.Join(tempDir, tc.name)
+		_, err := extractFiles(zipFilePath, "/", localPath, ExtractOptions{PreservePermissions: tc.preservePermissions})
+		require.NoError(t, err)
+
+		// Compare only the owner bits, as the group and other bits depend on the umask of the test process
+		scriptInfo, err := os.Stat(filepath.Join(localPath, "script.sh"))
+		require.NoError(t, err)
+		require.Equal(t, tc.expectedScriptMode&0700, scriptInfo.Mode().Perm()&0700)
+
+		readmeInfo, err := os.Stat(filepath.Join(localPath, "README.md"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), readmeInfo.Mode().Perm()&0700)
+	}
+}
+
+func TestExtractFilesDirAndFileMode(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	zipFilePath := filepath.Join(tempDir, "repo.zip")
+	zipFile, err := os.Create(zipFilePath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(zipFile)
+	for _, name := range []string{"repo-v1/", "repo-v1/sub/", "repo-v1/sub/file.txt"} {
+		_, err := zipWriter.Create(name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, zipFile.Close())
+
+	localPath := filepath.Join(tempDir, "out")
+	_, err = extractFiles(zipFilePath, "/", localPath, ExtractOptions{DirMode: 0750, FileMode: 0600})
+	require.NoError(t, err)
+
+	// The umask may remove further bits, but never add any
+	dirInfo, err := os.Stat(filepath.Join(localPath, "sub"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0), dirInfo.Mode().Perm()&^0750)
+	assert.Equal(t, os.FileMode(0700), dirInfo.Mode().Perm()&0700)
+
+	fileInfo, err := os.Stat(filepath.Join(localPath, "sub", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+}
+
+func TestExtractFilesStripComponents(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	zipFilePath := filepath.Join(tempDir, "repo.zip")
+	zipFile, err := os.Create(zipFilePath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(zipFile)
+	for _, name := range []string{"repo-v1/", "repo-v1/modules/", "repo-v1/modules/README.md", "repo-v1/modules/vpc/", "repo-v1/modules/vpc/main.tf", "repo-v1/modules/vpc/sub/", "repo-v1/modules/vpc/sub/vars.tf"} {
+		_, err := zipWriter.Create(name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, zipFile.Close())
+
+	localPath := filepath.Join(tempDir, "out")
+	fileCount, err := extractFiles(zipFilePath, "/modules", localPath, ExtractOptions{StripComponents: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fileCount)
+
+	assert.FileExists(t, filepath.Join(localPath, "main.tf"))
+	assert.FileExists(t, filepath.Join(localPath, "sub", "vars.tf"))
+	assert.NoFileExists(t, filepath.Join(localPath, "README.md"))
+	assert.NoDirExists(t, filepath.Join(localPath, "vpc"))
+}
+
+func TestExtractOptionsStripComponents(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		stripComponents int
+		expectedName    string
+		expectedKeep    bool
+	}{
+		{"a/b/c.txt", 0, "a/b/c.txt", true},
+		{"a/b/c.txt", 1, "b/c.txt", true},
+		{"a/b/c.txt", 2, "c.txt", true},
+		{"a/b/c.txt", 3, "", false},
+		{"a/b/", 1, "b", true},
+		{"a/", 1, "", false},
+		{"", 2, "", true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(fmt.Sprintf("%s-%d", tc.name, tc.stripComponents), func(t *testing.T) {
+			t.Parallel()
+
+			name, keep := ExtractOptions{StripComponents: tc.stripComponents}.stripComponents(tc.name)
+			assert.Equal(t, tc.expectedName, name)
+			assert.Equal(t, tc.expectedKeep, keep)
+		})
+	}
+}
+
+func TestExtractFilesFlattenAndRename(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	zipFilePath := filepath.Join(tempDir, "repo.zip")
+	zipFile, err := os.Create(zipFilePath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(zipFile)
+	for _, name := range []string{"repo-v1/", "repo-v1/vpc/", "repo-v1/vpc/main.tf", "repo-v1/vpc/outputs.tf", "repo-v1/rds/", "repo-v1/rds/main.tf"} {
+		_, err := zipWriter.Create(name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, zipFile.Close())
+
+	// Both main.tf files would be flattened to the same name
+	_, err = extractFiles(zipFilePath, "/", filepath.Join(tempDir, "collision"), ExtractOptions{Flatten: true})
+	assert.Error(t, err)
+
+	localPath := filepath.Join(tempDir, "out")
+	renames, err := ParseRenames([]string{"main.tf=vpc.tf"})
+	require.NoError(t, err)
+	fileCount, err := extractFiles(zipFilePath, "/vpc", localPath, ExtractOptions{Flatten: true, Renames: renames})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fileCount)
+	assert.FileExists(t, filepath.Join(localPath, "vpc.tf"))
+	assert.FileExists(t, filepath.Join(localPath, "outputs.tf"))
+
+	// Renaming a folder renames everything in it, into folders the archive doesn't have
+	localPath = filepath.Join(tempDir, "renamed")
+	renames, err = ParseRenames([]string{"rds=modules/database"})
+	require.NoError(t, err)
+	_, err = extractFiles(zipFilePath, "/", localPath, ExtractOptions{Renames: renames})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(localPath, "modules", "database", "main.tf"))
+	assert.FileExists(t, filepath.Join(localPath, "vpc", "main.tf"))
+	assert.NoDirExists(t, filepath.Join(localPath, "rds"))
+}
+
+func TestParseRenames(t *testing.T) {
+	t.Parallel()
+
+	renames, err := ParseRenames([]string{"tool_linux_amd64=tool", "/modules/vpc/=network/", "a=b/c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tool_linux_amd64": "tool", "modules/vpc": "network", "a": "b/c"}, renames)
+
+	for _, invalid := range []string{"tool", "=tool", "tool=", "tool=../tool", "tool=a/../../tool"} {
+		_, err := ParseRenames([]string{invalid})
+		assert.Error(t, err, "rename %q", invalid)
+	}
+
+	_, err = ParseRenames([]string{"tool=a", "tool=b"})
+	assert.Error(t, err)
+}
+
+func TestRenamePath(t *testing.T) {
+	t.Parallel()
+
+	renames := map[string]string{"vpc": "network", "vpc/main.tf": "vpc.tf", "README.md": "docs/README.md"}
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"vpc/main.tf", "vpc.tf"},
+		{"vpc/outputs.tf", "network/outputs.tf"},
+		{"vpc", "network"},
+		{"vpcs/main.tf", "vpcs/main.tf"},
+		{"README.md", "docs/README.md"},
+		{"rds/README.md", "rds/README.md"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, renamePath(tc.name, renames))
+		})
+	}
+}
+
+func TestExtractFilesEmptyZip(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	zipFilePath := filepath.Join(tempDir, "repo.zip")
+	zipFile, err := os.Create(zipFilePath)
+	require.NoError(t, err)
+	require.NoError(t, zip.NewWriter(zipFile).Close())
+	require.NoError(t, zipFile.Close())
+
+	_, err = extractFiles(zipFilePath, "/", filepath.Join(tempDir, "out"), ExtractOptions{})
+	assert.Error(t, err)
+}
+
+// Zip entry names and symlink targets come from the archive, so no matter what they are, extracting them must never
+// panic or write anything outside of the local path
+func FuzzExtractFilesEntryName(f *testing.F) {
+	f.Add("file.txt", "file.txt")
+	f.Add("sub/dir/file.txt", "../file.txt")
+	f.Add("../../evil.sh", "/etc/passwd")
+	f.Add("/abs/evil.sh", "../../../../tmp")
+	f.Add("sub/../../evil.sh", "sub/../..")
+	f.Add("", "")
+
+	f.Fuzz(func(t *testing.T, entryName string, symlinkTarget string) {
+		tempDir := t.TempDir()
+
+		zipFilePath := filepath.Join(tempDir, "repo.zip")
+		zipFile, err := os.Create(zipFilePath)
+		require.NoError(t, err)
+		zipWriter := zip.NewWriter(zipFile)
+		_, err = zipWriter.Create("repo-v1/")
+		require.NoError(t, err)
+		_, err = zipWriter.Create("repo-v1/" + entryName)
+		require.NoError(t, err)
+		header := &zip.FileHeader{Name: "repo-v1/link-" + entryName}
+		header.SetMode(os.ModeSymlink | 0777)
+		linkWriter, err := zipWriter.CreateHeader(header)
+		require.NoError(t, err)
+		// A name ending in a slash is a directory, which can't have contents
+		if !strings.HasSuffix(header.Name, "/") {
+			_, err = linkWriter.Write([]byte(symlinkTarget))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zipWriter.Close())
+		require.NoError(t, zipFile.Close())
+
+		localPath := filepath.Join(tempDir, "out")
+		// Errors are expected for malicious names, as long as nothing escapes
+		extractFiles(zipFilePath, "/", localPath, ExtractOptions{PreserveSymlinks: true})
+
+		err = filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == tempDir || path == zipFilePath || path == localPath {
+				return nil
+			}
+			if !strings.HasPrefix(path, localPath+string(os.PathSeparator)) {
+				return fmt.Errorf("%s was written outside of %s", path, localPath)
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				if !isSymlinkTargetWithinRoot(localPath, path, target) {
+					return fmt.Errorf("symbolic link %s points to %s outside of %s", path, target, localPath)
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestParseFileMode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value     string
+		expected  os.FileMode
+		expectErr bool
+	}{
+		{"", 0, false},
+		{"0750", 0750, false},
+		{"640", 0640, false},
+		{"0", 0, true},
+		{"1777", 0, true},
+		{"rwxr-x---", 0, true},
+		{"0789", 0, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.value, func(t *testing.T) {
+			t.Parallel()
+
+			mode, err := ParseFileMode("dir-mode", tc.value)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, mode)
+			}
+		})
+	}
+}
+
+func TestExtractFilesPreserveSymlinks(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		linkTarget    string
+		expectedError bool
+	}{
+		{"relative-link-within-root", "README.md", false},
+		{"relative-link-into-subdir", "docs/../README.md", false},
+		{"relative-link-escaping-root", "../../etc/passwd", true},
+		{"absolute-link", "/etc/passwd", true},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			zipFilePath := filepath.Join(tempDir, "repo.zip")
+			zipFile, err := os.Create(zipFilePath)
+			require.NoError(t, err)
+			zipWriter := zip.NewWriter(zipFile)
+
+			dirHeader := &zip.FileHeader{Name: "repo-v1/"}
+			dirHeader.SetMode(os.ModeDir | 0755)
+			_, err = zipWriter.CreateHeader(dirHeader)
+			require.NoError(t, err)
+
+			readme, err := zipWriter.Create("repo-v1/README.md")
+			require.NoError(t, err)
+			_, err = readme.Write([]byte("hello"))
+			require.NoError(t, err)
+
+			linkHeader := &zip.FileHeader{Name: "repo-v1/link"}
+			linkHeader.SetMode(os.ModeSymlink | 0777)
+			link, err := zipWriter.CreateHeader(linkHeader)
+			require.NoError(t, err)
+			_, err = link.Write([]byte(tc.linkTarget))
+			require.NoError(t, err)
+
+			require.NoError(t, zipWriter.Close())
+			require.NoError(t, zipFile.Close())
+
+			localPath := filepath.Join(tempDir, "out")
+			_, err = extractFiles(zipFilePath, "/", localPath, ExtractOptions{PreserveSymlinks: true})
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			target, err := os.Readlink(filepath.Join(localPath, "link"))
+			require.NoError(t, err)
+			require.Equal(t, tc.linkTarget, target)
+		})
+	}
+}
+
+// Return ture if the given slice contains the given string
+func stringInSlice(s string, slice []string) bool {
+	for _, val := range slice {
+		if val == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// The REST API version fetch targets when the GitHub instance supports calendar-based API versioning. For more info,
+// see: https://docs.github.com/en/rest/overview/api-versions
+const githubRestApiVersion = "2022-11-28"
+
+// The first GitHub Enterprise Server release that supports the X-GitHub-Api-Version header
+var minGhesVersionWithApiVersionHeader = version.Must(version.NewVersion("3.9"))
+
+// Parse the GitHub Enterprise Server version passed in via --ghes-version. An empty string means the version is unknown,
+// in which case fetch assumes the oldest API behavior it supports.
+func ParseGhesVersion(ghesVersion string) (*version.Version, error) {
+	if ghesVersion == "" {
+		return nil, nil
+	}
+
+	v, err := version.NewVersion(ghesVersion)
+	if err != nil {
+		return nil, fmt.Errorf("The --ghes-version value \"%s\" is not a valid GitHub Enterprise Server version: %s", ghesVersion, err)
+	}
+	return v, nil
+}
+
+// Return true if the given repo is hosted on GitHub Enterprise Server rather than github.com
+func isGitHubEnterprise(repo GitHubRepo) bool {
+	return repo.ApiUrl != "api.github.com"
+}
+
+// Return the headers that select the API version for calls to the given repo's GitHub instance. github.com and newer
+// GitHub Enterprise Server releases understand the X-GitHub-Api-Version header, whereas older releases only support
+// versioning through the Accept header.
+func getApiVersionHeaders(repo GitHubRepo) map[string]string {
+	if supportsApiVersionHeader(repo) {
+		return map[string]string{
+			"Accept":               "application/vnd.github+json",
+			"X-GitHub-Api-Version": githubRestApiVersion,
+		}
+	}
+
+	return map[string]string{
+		"Accept": "application/vnd.github.v3+json",
+	}
+}
+
+// Return true if the given repo's GitHub instance supports the X-GitHub-Api-Version header
+func supportsApiVersionHeader(repo GitHubRepo) bool {
+	if !isGitHubEnterprise(repo) {
+		return true
+	}
+
+	ghesVersion, err := ParseGhesVersion(repo.EnterpriseVersion)
+	if err != nil || ghesVersion == nil {
+		return false
+	}
+	return ghesVersion.GreaterThanOrEqual(minGhesVersionWithApiVersionHeader)
+}
+
+// Merge the API version headers for the given repo with the given custom headers. Custom headers take precedence, as
+// some calls (e.g. release asset downloads) need a specific Accept header.
+func withApiVersionHeaders(repo GitHubRepo, customHeaders map[string]string) map[string]string {
+	headers := getApiVersionHeaders(repo)
+	for headerName, headerValue := range customHeaders {
+		headers[headerName] = headerValue
+	}
+	return headers
+}
+
+// Add a hint to errors returned by GitHub Enterprise Server for endpoints or media types that older releases don't
+// support, since the raw 404 or 415 response on its own is rarely enough to figure out what went wrong.
+func addGhesCompatibilityHint(repo GitHubRepo, err *FetchError) *FetchError {
+	if err == nil || !isGitHubEnterprise(repo) {
+		return err
+	}
+
+	if err.errorCode != repoDoesNotExistOrAccessDenied && err.errorCode != unsupportedMediaType {
+		return err
+	}
+
+	ghesVersion := repo.EnterpriseVersion
+	if ghesVersion == "" {
+		ghesVersion = "unknown"
+	}
+
+	return &FetchError{
+		errorCode: err.errorCode,
+		details:   fmt.Sprintf("%s (GitHub Enterprise Server version: %s. Older GitHub Enterprise Server releases may not support this API endpoint or media type; make sure --ghes-version and --github-api-version match your instance.)", err.details, ghesVersion),
+		err:       err.err,
+	}
+}
@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetApiVersionHeaders(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name                  string
+		repo                  GitHubRepo
+		expectedVersionHeader bool
+	}{
+		{"github.com", GitHubRepo{ApiUrl: "api.github.com"}, true},
+		{"ghes-unknown-version", GitHubRepo{ApiUrl: "ghe.mycompany.com/api/v3"}, false},
+		{"ghes-3.8", GitHubRepo{ApiUrl: "ghe.mycompany.com/api/v3", EnterpriseVersion: "3.8"}, false},
+		{"ghes-3.9", GitHubRepo{ApiUrl: "ghe.mycompany.com/api/v3", EnterpriseVersion: "3.9"}, true},
+		{"ghes-3.10.2", GitHubRepo{ApiUrl: "ghe.mycompany.com/api/v3", EnterpriseVersion: "3.10.2"}, true},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			headers := getApiVersionHeaders(tc.repo)
+			_, hasVersionHeader := headers["X-GitHub-Api-Version"]
+			assert.Equal(t, tc.expectedVersionHeader, hasVersionHeader)
+			assert.NotEmpty(t, headers["Accept"])
+		})
+	}
+}
+
+func TestWithApiVersionHeadersPrefersCustomHeaders(t *testing.T) {
+	t.Parallel()
+
+	headers := withApiVersionHeaders(GitHubRepo{ApiUrl: "api.github.com"}, map[string]string{"Accept": "application/octet-stream"})
+	assert.Equal(t, "application/octet-stream", headers["Accept"])
+	assert.Equal(t, githubRestApiVersion, headers["X-GitHub-Api-Version"])
+}
+
+func TestAddGhesCompatibilityHint(t *testing.T) {
+	t.Parallel()
+
+	githubRepo := GitHubRepo{ApiUrl: "api.github.com"}
+	ghesRepo := GitHubRepo{ApiUrl: "ghe.mycompany.com/api/v3", EnterpriseVersion: "2.22"}
+
+	notFound := newError(repoDoesNotExistOrAccessDenied, "not found")
+	assert.Equal(t, notFound, addGhesCompatibilityHint(githubRepo, notFound))
+	assert.Contains(t, addGhesCompatibilityHint(ghesRepo, notFound).details, "2.22")
+	assert.Equal(t, repoDoesNotExistOrAccessDenied, addGhesCompatibilityHint(ghesRepo, notFound).errorCode)
+
+	unauthorized := newError(invalidGithubTokenOrAccessDenied, "unauthorized")
+	assert.Equal(t, unauthorized, addGhesCompatibilityHint(ghesRepo, unauthorized))
+
+	assert.Nil(t, addGhesCompatibilityHint(ghesRepo, nil))
+}
+
+func TestParseGhesVersion(t *testing.T) {
+	t.Parallel()
+
+	v, err := ParseGhesVersion("")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	v, err = ParseGhesVersion("3.9.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "3.9.1", v.String())
+
+	_, err = ParseGhesVersion("not-a-version")
+	assert.Error(t, err)
+}
@@ -0,0 +1,795 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+type GitHubRepo struct {
+	Url               string // The URL of the GitHub repo
+	BaseUrl           string // The Base URL of the GitHub Instance
+	ApiUrl            string // The API Url of the GitHub Instance
+	EnterpriseVersion string // The GitHub Enterprise Server version of the GitHub Instance, if known
+	Owner             string // The GitHub account name under which the repo exists
+	Name              string // The GitHub repo name
+	Token             string // The personal access token to access this repo (if it's a private repo)
+	IncludeDrafts     bool   // Whether to also look for draft releases, which can only be found by listing the repo's releases
+}
+
+type GitHubInstance struct {
+	BaseUrl           string
+	ApiUrl            string
+	EnterpriseVersion string // Only set for GitHub Enterprise Server instances, and only if passed in via --ghes-version
+}
+
+// Represents a specific git commit.
+// Note that code using GitHub Commit should respect the following hierarchy:
+// - CommitSha > BranchName > GitTag
+// - Example: GitTag and BranchName are both specified; use the GitTag
+// - Example: GitTag and CommitSha are both specified; use the CommitSha
+// - Example: BranchName alone is specified; use BranchName
+type GitHubCommit struct {
+	Repo       GitHubRepo // The GitHub repo where this release lives
+	GitRef     string     // The git reference
+	GitTag     string     // The specific git tag for this release
+	BranchName string     // If specified, indicates that this commit should be the latest commit on the given branch
+	CommitSha  string     // If specified, indicates that this commit should be exactly this Git Commit SHA.
+}
+
+// Return the commit SHA, branch, or git tag that identifies this commit, or an empty string if none are set
+func (gitHubCommit GitHubCommit) ref() string {
+	// Ordering matters in this conditional
+	// GitRef needs to be the fallback and therefore must be last
+	// See https://github.com/gruntwork-io/fetch/issues/87 for an example
+	if gitHubCommit.CommitSha != "" {
+		return gitHubCommit.CommitSha
+	} else if gitHubCommit.BranchName != "" {
+		return gitHubCommit.BranchName
+	} else if gitHubCommit.GitTag != "" {
+		return gitHubCommit.GitTag
+	}
+	return gitHubCommit.GitRef
+}
+
+// Modeled directly after the api.github.com response
+type GitHubTagsApiResponse struct {
+	Name       string // The tag name
+	ZipBallUrl string // The URL where a ZIP of the release can be downloaded
+	TarballUrl string // The URL where a Tarball of the release can be downloaded
+	Commit     GitHubTagsCommitApiResponse
+}
+
+// Modeled directly after the api.github.com response
+type GitHubTagsCommitApiResponse struct {
+	Sha string // The SHA of the commit associated with a given tag
+	Url string // The URL at which additional API information can be found for the given commit
+}
+
+// Modeled directly after the api.github.com response (but only includes the fields we care about). For more info, see:
+// https://developer.github.com/v3/repos/releases/#get-a-release-by-tag-name
+type GitHubReleaseApiResponse struct {
+	Id        int
+	Url       string
+	UploadUrl string `json:"upload_url"`
+	Name      string
+	TagName   string `json:"tag_name"`
+	Immutable bool   // Set for immutable releases, whose tag and assets can't be changed
+	Draft     bool   // Set for draft releases, which are only listed for tokens with push access to the repo
+	Assets    []GitHubReleaseAsset
+}
+
+// The "assets" portion of the GitHubReleaseApiResponse. Modeled directly after the api.github.com response (but only
+// includes the fields we care about). For more info, see:
+// https://developer.github.com/v3/repos/releases/#get-a-release-by-tag-name
+type GitHubReleaseAsset struct {
+	Id            int
+	Url           string
+	Name          string
+	Size          int64
+	UpdatedAt     time.Time `json:"updated_at"`
+	DownloadCount int       `json:"download_count"`
+}
+
+func ParseUrlIntoGithubInstance(logger *logrus.Entry, repoUrl string, apiv string) (GitHubInstance, *FetchError) {
+	var instance GitHubInstance
+
+	u, err := url.Parse(repoUrl)
+	if err != nil {
+		return instance, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("GitHub Repo URL %s is malformed.", repoUrl))
+	}
+
+	baseUrl := u.Host
+	apiUrl := "api.github.com"
+	if baseUrl != "github.com" && baseUrl != "www.github.com" {
+		logger.Infof("Assuming GitHub Enterprise since the provided url (%s) does not appear to be for GitHub.com\n", repoUrl)
+		apiUrl = baseUrl + "/api/" + apiv
+	}
+
+	instance = GitHubInstance{
+		BaseUrl: baseUrl,
+		ApiUrl:  apiUrl,
+	}
+
+	return instance, nil
+}
+
+// Fetch all SemVer tags from the given GitHub repo. If looseSemver is set, tags that can be coerced into a version (see
+// parseTagVersion) are included too. Also returns the SHA of the commit each tag in the repo points to, keyed by tag
+// name, including the tags that are not versions.
+func FetchTags(ctx context.Context, githubRepoUrl string, githubToken string, instance GitHubInstance, looseSemver bool) ([]string, map[string]string, *FetchError) {
+	repo, err := ParseUrlIntoGitHubRepo(githubRepoUrl, githubToken, instance)
+	if err != nil {
+		return nil, map[string]string{}, wrapError(err)
+	}
+	return fetchTags(ctx, repo, looseSemver, nil)
+}
+
+// Fetch the tags of the given repo as FetchTags does. Each page of tags is cached in the given cache, if it's not nil,
+// so that fetching the tags of a repo whose tags haven't changed doesn't count against the rate limit.
+func fetchTags(ctx context.Context, repo GitHubRepo, looseSemver bool, cache *apiResponseCache) ([]string, map[string]string, *FetchError) {
+	var tagsString []string
+	tagCommits := map[string]string{}
+
+	// Set per_page to 100, which is the max, to reduce network calls
+	tagsUrl := formatUrl(repo, createGitHubRepoUrlForPath(repo, "tags?per_page=100"))
+	visitedUrls := map[string]bool{}
+	for tagsUrl != "" {
+		visitedUrls[tagsUrl] = true
+
+		jsonResp, links, err := getGitHubApiConditionally(ctx, cache, repo, tagsUrl)
+		if err != nil {
+			return tagsString, tagCommits, err
+		}
+
+		// Extract the JSON into our array of gitHubTagsCommitApiResponse's
+		var tags []GitHubTagsApiResponse
+		if err := json.Unmarshal(jsonResp, &tags); err != nil {
+			return tagsString, tagCommits, wrapError(err)
+		}
+
+		for _, tag := range tags {
+			tagCommits[tag.Name] = tag.Commit.Sha
+
+			// Skip tags that are not semantically versioned so that they don't cause errors. (issue #75)
+			if _, err := parseTagVersion(tag.Name, looseSemver); err == nil {
+				tagsString = append(tagsString, tag.Name)
+			}
+		}
+
+		// Get paginated tags (issue #26 and #46)
+		nextUrl := getNextUrl(links)
+		if nextUrl != "" && !isSameOrigin(tagsUrl, nextUrl) {
+			return tagsString, tagCommits, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("Refusing to follow the next page link %s, which is not on the same host as %s", nextUrl, tagsUrl))
+		}
+		// A next page link pointing back at a page we've already fetched would otherwise loop forever
+		if visitedUrls[nextUrl] {
+			break
+		}
+		tagsUrl = nextUrl
+	}
+
+	return tagsString, tagCommits, nil
+}
+
+// Convert a URL into a GitHubRepo struct
+func ParseUrlIntoGitHubRepo(url string, token string, instance GitHubInstance) (GitHubRepo, *FetchError) {
+	var gitHubRepo GitHubRepo
+
+	regex, regexErr := regexp.Compile("https?://(?:www\\.)?" + regexp.QuoteMeta(instance.BaseUrl) + "/(.+?)/(.+?)(?:$|\\?|#|/)")
+	if regexErr != nil {
+		return gitHubRepo, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("GitHub Repo URL %s is malformed.", url))
+	}
+
+	matches := regex.FindStringSubmatch(url)
+	if len(matches) != 3 {
+		return gitHubRepo, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("GitHub Repo URL %s could not be parsed correctly", url))
+	}
+
+	// The owner and name end up in the path of every API call, so anything that isn't a valid GitHub name (such as
+	// ".." or an encoded slash) could be used to call an API other than the one intended
+	if !isValidGitHubName(matches[1]) || !isValidGitHubName(matches[2]) {
+		return gitHubRepo, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("GitHub Repo URL %s does not contain a valid owner and repo name", url))
+	}
+
+	gitHubRepo = GitHubRepo{
+		Url:               url,
+		BaseUrl:           instance.BaseUrl,
+		ApiUrl:            instance.ApiUrl,
+		EnterpriseVersion: instance.EnterpriseVersion,
+		Owner:             matches[1],
+		Name:              matches[2],
+		Token:             token,
+	}
+
+	return gitHubRepo, nil
+}
+
+// Download the release asset with the given id and return its body
+func DownloadReleaseAsset(ctx context.Context, repo GitHubRepo, assetId int, destPath string, withProgress bool) *FetchError {
+	return downloadReleaseAsset(ctx, repo, &GitHubReleaseAsset{Id: assetId}, destPath, withProgress)
+}
+
+// Download the given release asset to destPath, as DownloadReleaseAsset does, aborting the download as soon as the
+// server sends more than the asset's size
+func downloadReleaseAsset(ctx context.Context, repo GitHubRepo, asset *GitHubReleaseAsset, destPath string, withProgress bool) *FetchError {
+	url := createGitHubRepoUrlForPath(repo, fmt.Sprintf("releases/assets/%d", asset.Id))
+	resp, err := callGitHubApi(ctx, repo, url, map[string]string{"Accept": "application/octet-stream"})
+	if err != nil {
+		return err
+	}
+	if err := verifyReleaseAssetBody(resp, asset); err != nil {
+		return err
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+// Download the given release asset again, as downloadReleaseAsset does, but asking every cache along the way to fetch it
+// afresh and the server not to compress it, so that a copy corrupted in a cache or by a transparent decompression isn't
+// served again
+func redownloadReleaseAsset(ctx context.Context, repo GitHubRepo, asset *GitHubReleaseAsset, destPath string, withProgress bool) *FetchError {
+	url := createGitHubRepoUrlForPath(repo, fmt.Sprintf("releases/assets/%d", asset.Id))
+	resp, err := callGitHubApi(ctx, repo, url, map[string]string{
+		"Accept":          "application/octet-stream",
+		"Accept-Encoding": "identity",
+		"Cache-Control":   "no-cache",
+		"Pragma":          "no-cache",
+	})
+	if err != nil {
+		return err
+	}
+	if err := verifyReleaseAssetBody(resp, asset); err != nil {
+		return err
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+// Download the release asset with the given ID straight to writer, without writing it to disk
+func StreamReleaseAsset(ctx context.Context, repo GitHubRepo, assetId int, writer io.Writer) *FetchError {
+	return streamReleaseAsset(ctx, repo, &GitHubReleaseAsset{Id: assetId}, writer)
+}
+
+// Download the given release asset straight to writer, as StreamReleaseAsset does, aborting the download as soon as the
+// server sends more than the asset's size, before any of the excess is written
+func streamReleaseAsset(ctx context.Context, repo GitHubRepo, asset *GitHubReleaseAsset, writer io.Writer) *FetchError {
+	url := createGitHubRepoUrlForPath(repo, fmt.Sprintf("releases/assets/%d", asset.Id))
+	resp, err := callGitHubApi(ctx, repo, url, map[string]string{"Accept": "application/octet-stream"})
+	if err != nil {
+		return err
+	}
+	if err := verifyReleaseAssetBody(resp, asset); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Wrap the body of the given response to a download of the given release asset in a VerifyingReader, so that the
+// download is aborted as soon as the server sends more than the size the GitHub API listed for the asset, rather than
+// after all of it has been written. The asset's size is unknown if it's not positive, in which case the body is left as
+// it is.
+func verifyReleaseAssetBody(resp *http.Response, asset *GitHubReleaseAsset) *FetchError {
+	if asset.Size <= 0 {
+		return nil
+	}
+	verifier, err := NewVerifyingReader(resp.Body, fmt.Sprintf("Release asset %s", asset.Name), VerifyOptions{MaxSize: asset.Size, Logger: loggerFrom(resp.Request.Context())})
+	if err != nil {
+		resp.Body.Close()
+		return wrapError(err)
+	}
+	resp.Body = verifier
+	return nil
+}
+
+// Download the file at the given path in the repo, as of the given git reference, to destPath with the contents API.
+// This transfers just the one file, rather than an archive of the whole repo, but only works for files of up to 100MB.
+func DownloadRepoFile(ctx context.Context, repo GitHubRepo, ref string, filePath string, destPath string, withProgress bool) *FetchError {
+	resp, err := openRepoFile(ctx, repo, ref, filePath)
+	if err != nil {
+		return err
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+// Request the raw contents of the file at the given path in the repo, as of the given git reference, with the
+// contents API. The caller must close the response body.
+func openRepoFile(ctx context.Context, repo GitHubRepo, ref string, filePath string) (*http.Response, *FetchError) {
+	return callGitHubApi(ctx, repo, repoFileApiPath(repo, ref, filePath), map[string]string{"Accept": "application/vnd.github.v3.raw"})
+}
+
+// Return the contents API path of the file or directory at the given path in the repo, as of the given git reference
+func repoFileApiPath(repo GitHubRepo, ref string, filePath string) string {
+	filePath = strings.Trim(filePath, "/")
+	if filePath == "" {
+		return createGitHubRepoUrlForPath(repo, fmt.Sprintf("contents?ref=%s", url.QueryEscape(ref)))
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(filePath, "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	return createGitHubRepoUrlForPath(repo, fmt.Sprintf("contents/%s?ref=%s", strings.Join(segments, "/"), url.QueryEscape(ref)))
+}
+
+// The tag constraint that resolves to the latest release of a GitHub repo, as GitHub itself picks it: the most recent
+// release that's neither a draft nor a pre-release, unless another one was marked as the latest
+const LatestReleaseTag = "latest-release"
+
+// Get information about the GitHub release with the given tag
+func GetGitHubReleaseInfo(ctx context.Context, repo GitHubRepo, tag string) (GitHubReleaseApiResponse, *FetchError) {
+	release, err := getGitHubRelease(ctx, repo, fmt.Sprintf("releases/tags/%s", tag))
+	// The tag of a draft release isn't created until it's published, so drafts can't be found by tag
+	if err != nil && err.errorCode == repoDoesNotExistOrAccessDenied && repo.IncludeDrafts {
+		return findDraftRelease(ctx, repo, tag, err)
+	}
+	return release, err
+}
+
+// Get information about the latest release of the given GitHub repo. This takes a single request, however many tags
+// the repo has.
+func GetLatestGitHubRelease(ctx context.Context, repo GitHubRepo) (GitHubReleaseApiResponse, *FetchError) {
+	return getGitHubRelease(ctx, repo, "releases/latest")
+}
+
+// Get the GitHub release at the given path of the repos API of the given repo
+func getGitHubRelease(ctx context.Context, repo GitHubRepo, path string) (GitHubReleaseApiResponse, *FetchError) {
+	release := GitHubReleaseApiResponse{}
+
+	url := createGitHubRepoUrlForPath(repo, path)
+	resp, err := callGitHubApi(ctx, repo, url, map[string]string{})
+	if err != nil {
+		return release, err
+	}
+
+	// Convert the response body to a byte array
+	buf := new(bytes.Buffer)
+	_, goErr := buf.ReadFrom(resp.Body)
+	if goErr != nil {
+		return release, wrapError(goErr)
+	}
+	jsonResp := buf.Bytes()
+
+	if err := json.Unmarshal(jsonResp, &release); err != nil {
+		return release, wrapError(err)
+	}
+
+	return release, nil
+}
+
+// Return the draft releases of the given repo. GitHub only lists drafts to tokens with push access to the repo, so for
+// any other token, there are none.
+func fetchDraftReleases(ctx context.Context, repo GitHubRepo) ([]GitHubReleaseApiResponse, *FetchError) {
+	var drafts []GitHubReleaseApiResponse
+
+	releasesUrl := formatUrl(repo, createGitHubRepoUrlForPath(repo, "releases?per_page=100"))
+	visitedUrls := map[string]bool{}
+	for releasesUrl != "" {
+		visitedUrls[releasesUrl] = true
+
+		jsonResp, links, err := getGitHubApiConditionally(ctx, nil, repo, releasesUrl)
+		if err != nil {
+			return drafts, err
+		}
+
+		var releases []GitHubReleaseApiResponse
+		if err := json.Unmarshal(jsonResp, &releases); err != nil {
+			return drafts, wrapError(err)
+		}
+		for _, release := range releases {
+			if release.Draft && release.TagName != "" {
+				drafts = append(drafts, release)
+			}
+		}
+
+		nextUrl := getNextUrl(links)
+		if nextUrl != "" && !isSameOrigin(releasesUrl, nextUrl) {
+			return drafts, newError(githubRepoUrlMalformedOrNotParseable, fmt.Sprintf("Refusing to follow the next page link %s, which is not on the same host as %s", nextUrl, releasesUrl))
+		}
+		if visitedUrls[nextUrl] {
+			break
+		}
+		releasesUrl = nextUrl
+	}
+
+	return drafts, nil
+}
+
+// Return the draft release of the given repo with the given tag, or notFoundErr if there is none
+func findDraftRelease(ctx context.Context, repo GitHubRepo, tag string, notFoundErr *FetchError) (GitHubReleaseApiResponse, *FetchError) {
+	drafts, err := fetchDraftReleases(ctx, repo)
+	if err != nil {
+		return GitHubReleaseApiResponse{}, err
+	}
+	for _, draft := range drafts {
+		if draft.TagName == tag {
+			return draft, nil
+		}
+	}
+	return GitHubReleaseApiResponse{}, notFoundErr
+}
+
+// Get the full SHA of the commit that the given branch, tag, or (possibly abbreviated) commit SHA points to
+func GetCommitSha(ctx context.Context, repo GitHubRepo, ref string) (string, *FetchError) {
+	url := createGitHubRepoUrlForPath(repo, fmt.Sprintf("commits/%s", ref))
+	resp, err := callGitHubApi(ctx, repo, url, map[string]string{"Accept": "application/vnd.github.sha"})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	if _, goErr := buf.ReadFrom(resp.Body); goErr != nil {
+		return "", wrapError(goErr)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Craft a URL for the GitHub repos API of the form repos/:owner/:repo/:path
+func createGitHubRepoUrlForPath(repo GitHubRepo, path string) string {
+	return fmt.Sprintf("repos/%s/%s/%s", repo.Owner, repo.Name, path)
+}
+
+var nextLinkRegex = regexp.MustCompile(`<(.+?)>;\s*rel="next"`)
+
+// Get the next page URL from the given link header returned by the GitHub API. If there is no next page, return an
+// empty string. The link header is expected to be of the form:
+//
+// <url>; rel="next", <url>; rel="last"
+func getNextUrl(links string) string {
+	if len(links) == 0 {
+		return ""
+	}
+
+	for _, link := range strings.Split(links, ",") {
+		urlMatches := nextLinkRegex.FindStringSubmatch(link)
+		if len(urlMatches) == 2 {
+			// The header comes from the server, so ignore anything that isn't an absolute URL we could request
+			nextUrl := strings.TrimSpace(urlMatches[1])
+			if parsedUrl, err := url.Parse(nextUrl); err != nil || !parsedUrl.IsAbs() || parsedUrl.Host == "" {
+				return ""
+			}
+			return nextUrl
+		}
+	}
+
+	return ""
+}
+
+// Return true if the given URL points at the same scheme and host as currentUrl. Pagination links come from the server,
+// and following one to another host would send it the GitHub token.
+func isSameOrigin(currentUrl string, nextUrl string) bool {
+	current, err := url.Parse(currentUrl)
+	if err != nil {
+		return false
+	}
+	next, err := url.Parse(nextUrl)
+	if err != nil {
+		return false
+	}
+	return next.Scheme == current.Scheme && next.Host == current.Host
+}
+
+// Return true if the given name is a valid GitHub user, organization, or repo name
+func isValidGitHubName(name string) bool {
+	return githubNameRegex.MatchString(name) && name != "." && name != ".."
+}
+
+var githubNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Format a URL for calling the GitHub API for the given repo and path
+func formatUrl(repo GitHubRepo, path string) string {
+	return fmt.Sprintf("https://"+repo.ApiUrl+"/%s", path)
+}
+
+// Call the GitHub API at the given path and return the HTTP response
+func callGitHubApi(ctx context.Context, repo GitHubRepo, path string, customHeaders map[string]string) (*http.Response, *FetchError) {
+	resp, err := callGitHubApiRaw(ctx, formatUrl(repo, path), "GET", repo.Token, withApiVersionHeaders(repo, customHeaders))
+
+	// Some GitHub Enterprise Server releases reject the newer API versioning headers, so degrade gracefully to the
+	// legacy v3 media type rather than failing outright
+	if err != nil && err.errorCode == unsupportedMediaType && supportsApiVersionHeader(repo) {
+		loggerFrom(ctx).Warnf("GitHub returned HTTP 415 for %s. Retrying with the legacy v3 media type.\n", path)
+		legacyHeaders := map[string]string{"Accept": "application/vnd.github.v3+json"}
+		for headerName, headerValue := range customHeaders {
+			legacyHeaders[headerName] = headerValue
+		}
+		resp, err = callGitHubApiRaw(ctx, formatUrl(repo, path), "GET", repo.Token, legacyHeaders)
+	}
+
+	return resp, addGhesCompatibilityHint(repo, err)
+}
+
+// The most times a call to the GitHub API is retried after waiting out a rate limit, before it fails
+const maxRateLimitRetries = 3
+
+// How long to wait before retrying a call that hit a rate limit when GitHub doesn't say when it resets. GitHub asks
+// clients to wait at least a minute in that case.
+const defaultRateLimitWait = time.Minute
+
+// Call the GitHub API at the given URL, using the given HTTP method, and passing the given token and headers, and
+// return the response
+func callGitHubApiRaw(ctx context.Context, url string, method string, token string, customHeaders map[string]string) (*http.Response, *FetchError) {
+	return callGitHubApiRawWithBody(ctx, url, method, token, customHeaders, nil)
+}
+
+// Same as callGitHubApiRaw, but also sends the given request body, if it's not nil. The body is passed as a byte slice,
+// rather than a reader, so the request can be resent if we have to wait out the rate limit.
+func callGitHubApiRawWithBody(ctx context.Context, url string, method string, token string, customHeaders map[string]string, body []byte) (*http.Response, *FetchError) {
+	conn := connectionFrom(ctx)
+	httpClient := newHttpClient(ctx)
+
+	for retries := 0; ; retries++ {
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		if token != "" {
+			request.Header.Set("Authorization", authorizationHeader(token, conn.authScheme))
+		}
+
+		for headerName, headerValue := range customHeaders {
+			request.Header.Set(headerName, headerValue)
+		}
+
+		resp, err := httpClient.Do(request)
+
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		// Anything other than a 2xx is an error. Most calls expect a 200 OK, but creating a resource returns a 201 Created.
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		// Convert the resp.Body to a string
+		buf := new(bytes.Buffer)
+		_, goErr := buf.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if goErr != nil {
+			return nil, wrapError(goErr)
+		}
+		respBody := buf.String()
+
+		resetTime, isRateLimited := getRateLimitReset(resp, respBody, time.Now())
+		if !isRateLimited {
+			// We leverage the HTTP Response Code as our ErrorCode here.
+			return nil, newError(resp.StatusCode, fmt.Sprintf("Received HTTP Response %d while fetching releases for GitHub URL %s. Full HTTP response: %s", resp.StatusCode, url, respBody))
+		}
+		if !conn.waitForRateLimit {
+			return nil, newError(githubApiRateLimitExceeded, fmt.Sprintf("Received HTTP Response %d while calling GitHub URL %s because the GitHub API rate limit has been exhausted. The rate limit resets at %s.", resp.StatusCode, url, resetTime.Format(time.RFC3339)))
+		}
+		if retries >= maxRateLimitRetries {
+			return nil, newError(githubApiRateLimitExceeded, fmt.Sprintf("Received HTTP Response %d while calling GitHub URL %s because the GitHub API rate limit has been exhausted, even after waiting for it to reset %d times.", resp.StatusCode, url, retries))
+		}
+
+		waitDuration := time.Until(resetTime)
+		loggerFrom(ctx).Warnf("GitHub API rate limit exhausted. Waiting %s until it resets at %s ...\n", waitDuration.Round(time.Second), resetTime.Format(time.RFC3339))
+		timer := time.NewTimer(waitDuration)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, wrapError(ctx.Err())
+		}
+	}
+}
+
+// Check whether the given HTTP response, whose body is respBody, indicates that a GitHub API rate limit has been hit.
+// If so, return the time at which to retry, and true. That's the time in the Retry-After header, if any, or else the
+// time the primary rate limit resets at, as reported by the X-RateLimit-Reset header. A secondary rate limit, which
+// GitHub applies to bursts of requests, may have neither, in which case the call is retried after
+// defaultRateLimitWait. For more info, see:
+// https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+func getRateLimitReset(resp *http.Response, respBody string, now time.Time) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	exhausted := resp.Header.Get("X-RateLimit-Remaining") == "0"
+	secondary := strings.Contains(strings.ToLower(respBody), "secondary rate limit")
+	// A 403 is also what GitHub returns when the token can't access something, which isn't worth waiting for
+	if resp.StatusCode == http.StatusForbidden && retryAfter == "" && !exhausted && !secondary {
+		return time.Time{}, false
+	}
+
+	if retryAfter != "" {
+		if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil && seconds >= 0 {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+		if retryTime, err := http.ParseTime(retryAfter); err == nil {
+			return retryTime, true
+		}
+	}
+
+	if exhausted {
+		if resetEpoch, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(resetEpoch, 0), true
+		}
+	}
+
+	return now.Add(defaultRateLimitWait), true
+}
+
+// How often progress is logged when logs are structured, as each update is a log entry of its own rather than a line
+// that's rewritten in place
+const structuredProgressInterval = 5 * time.Second
+
+type writeCounter struct {
+	action  string // the action in progress, e.g. "Downloading"
+	written uint64
+	total   uint64 // 0 if the total size is unknown
+	suffix  string // contains " / SIZE MB" if size is known, otherwise empty
+	started time.Time
+
+	// Where progress is printed to, which is where the project's logs go
+	out io.Writer
+
+	// If set, logs are structured, so progress is logged to this logger as entries with fields, rather than printed
+	logger     *logrus.Entry
+	lastLogged time.Time
+}
+
+// Return a writeCounter that reports progress to the output of the given logger
+func newWriteCounter(logger *logrus.Entry, action string, total int64) *writeCounter {
+	counter := &writeCounter{action: action, started: time.Now()}
+	if total > 0 {
+		counter.total = uint64(total)
+		counter.suffix = fmt.Sprintf(" / %s", humanize.Bytes(uint64(total)))
+	}
+	counter.out = logger.Logger.Out
+	if isStructuredLog(logger) {
+		counter.logger = logger
+	}
+	return counter
+}
+
+func (wc *writeCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	wc.written += uint64(n)
+	if wc.logger != nil {
+		wc.logProgress()
+	} else {
+		wc.PrintProgress()
+	}
+	return n, nil
+}
+
+// Log the progress as an entry with the action, bytes written, total bytes, and estimated seconds remaining as fields,
+// at most once every structuredProgressInterval, and once more when the total is reached
+func (wc *writeCounter) logProgress() {
+	done := wc.total > 0 && wc.written >= wc.total
+	if !done && time.Since(wc.lastLogged) < structuredProgressInterval {
+		return
+	}
+	wc.lastLogged = time.Now()
+
+	fields := logrus.Fields{"action": wc.action, "bytes": wc.written}
+	if wc.total > 0 {
+		fields["total_bytes"] = wc.total
+	}
+	if remaining := wc.remaining(); remaining > 0 {
+		fields["eta_seconds"] = int64(remaining.Round(time.Second).Seconds())
+	}
+	wc.logger.WithFields(fields).Infof("%s... %s%s", wc.action, humanize.Bytes(wc.written), wc.suffix)
+}
+
+// Write the newline that ends the progress line once the action is done, unless progress is logged instead
+func (wc *writeCounter) finish() {
+	if wc.logger == nil {
+		fmt.Fprintln(wc.out)
+	}
+}
+
+func (wc writeCounter) PrintProgress() {
+	// Clear the line by using a character return to go back to the start and remove
+	// the remaining characters by filling it with spaces
+	fmt.Fprintf(wc.out, "\r%s", strings.Repeat(" ", 50))
+
+	// Return again and print current status of download
+	// We use the humanize package to print the bytes in a meaningful way (e.g. 10 MB)
+	fmt.Fprintf(wc.out, "\r%s... %s%s%s", wc.action, humanize.Bytes(wc.written), wc.suffix, wc.eta())
+}
+
+// Estimate the time remaining based on the average rate so far. Returns an empty string if the total size is unknown.
+func (wc writeCounter) eta() string {
+	remaining := wc.remaining()
+	if remaining == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (ETA %s)", remaining.Round(time.Second))
+}
+
+// Estimate the time remaining based on the average rate so far, or 0 if the total size is unknown or has been reached
+func (wc writeCounter) remaining() time.Duration {
+	if wc.total == 0 || wc.written == 0 || wc.written >= wc.total {
+		return 0
+	}
+
+	elapsed := time.Since(wc.started)
+	return time.Duration(float64(elapsed) * float64(wc.total-wc.written) / float64(wc.written))
+}
+
+// Write the body of the given HTTP response to disk at the given path. If the body can't be read in full, e.g. because
+// the request's context was canceled, the partially written file is removed.
+func writeResonseToDisk(resp *http.Response, destPath string, withProgress bool) *FetchError {
+	defer resp.Body.Close()
+
+	out, err := createDownloadFile(destPath)
+	if err != nil {
+		return wrapError(err)
+	}
+	if err := copyResponse(resp, out, withProgress); err != nil {
+		os.Remove(destPath)
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Write the body of the given HTTP response to the file with the given name in root. Just like writeResonseToDisk, any
+// file already there is removed first, and the partially written file is removed if the body can't be read in full.
+func writeResponseToRoot(resp *http.Response, root destRoot, name string, withProgress bool) *FetchError {
+	defer resp.Body.Close()
+
+	if err := root.Remove(name); err != nil && !os.IsNotExist(err) {
+		return wrapError(err)
+	}
+	out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return wrapError(err)
+	}
+	if err := copyResponse(resp, out, withProgress); err != nil {
+		root.Remove(name)
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Create the file at path to write a download to. Any file already there is removed first rather than truncated, since
+// it may be a hardlink or symlink to the copy of an earlier download in an assetStore, which mustn't change.
+func createDownloadFile(path string) (*os.File, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// Copy the body of the given HTTP response to out, and close out
+func copyResponse(resp *http.Response, out *os.File, withProgress bool) error {
+	var readCloser io.Reader
+	if withProgress {
+		counter := newWriteCounter(loggerFrom(resp.Request.Context()), "Downloading", resp.ContentLength)
+		defer counter.finish()
+		readCloser = io.TeeReader(resp.Body, counter)
+	} else {
+		readCloser = resp.Body
+	}
+	_, err := io.Copy(out, readCloser)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
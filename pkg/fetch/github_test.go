@@ -0,0 +1,955 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetListOfReleasesFromGitHubRepo(t *testing.T) {
+	t.Parallel()
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		repoUrl          string
+		firstReleaseTag  string
+		lastReleaseTag   string
+		expectedNumTags  int
+		gitHubOAuthToken string
+		testInst         GitHubInstance
+	}{
+		// Test on a public repo whose sole purpose is to be a test fixture for this tool
+		{"https://github.com/gruntwork-io/fetch-test-public", "v0.0.1", "v0.0.4", 4, "", testInst},
+
+		// Private repo equivalent
+		{"https://github.com/gruntwork-io/fetch-test-private", "v0.0.2", "v0.0.2", 1, os.Getenv("GITHUB_OAUTH_TOKEN"), testInst},
+	}
+
+	for _, tc := range cases {
+		releases, tagCommits, err := FetchTags(context.Background(), tc.repoUrl, tc.gitHubOAuthToken, testInst, false)
+		if err != nil {
+			t.Fatalf("error fetching releases: %s", err)
+		}
+
+		if len(releases) != 0 && tc.firstReleaseTag == "" {
+			t.Fatalf("expected empty list of releases for repo %s, but got first release = %s", tc.repoUrl, releases[0])
+		}
+
+		if len(releases) == 0 && tc.firstReleaseTag != "" {
+			t.Fatalf("expected non-empty list of releases for repo %s, but no releases were found", tc.repoUrl)
+		}
+
+		if len(releases) != tc.expectedNumTags {
+			t.Fatalf("expected %d releases, but got %d", tc.expectedNumTags, len(releases))
+		}
+
+		if releases[len(releases)-1] != tc.firstReleaseTag {
+			t.Fatalf("error parsing github releases for repo %s. expected first release = %s, actual = %s", tc.repoUrl, tc.firstReleaseTag, releases[len(releases)-1])
+		}
+
+		if releases[0] != tc.lastReleaseTag {
+			t.Fatalf("error parsing github releases for repo %s. expected first release = %s, actual = %s", tc.repoUrl, tc.lastReleaseTag, releases[0])
+		}
+
+		for _, release := range releases {
+			if len(tagCommits[release]) != 40 {
+				t.Fatalf("expected a commit SHA for tag %s of repo %s, but got %q", release, tc.repoUrl, tagCommits[release])
+			}
+		}
+	}
+}
+
+func TestGetNextPath(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		links       string
+		expectedUrl string
+	}{
+		{"next-and-last-urls", `<https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>; rel="next", <https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=3>; rel="last"`, "https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2"},
+		{"next-and-last-urls-no-whitespace", `<https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>;rel="next",<https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=3>;rel="last"`, "https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2"},
+		{"next-and-last-urls-extra-whitespace", `    <https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>;      rel="next",     <https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=3>   ;    rel="last"`, "https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2"},
+		{"first-and-next-urls", `<https://api.github.com/repos/123456789/example-repo/tags?page=1>; rel="first", <https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>; rel="next"`, "https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2"},
+		{"next-only", `<https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>; rel="next"`, "https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2"},
+		{"first-and-last-urls", `<https://api.github.com/repos/123456789/example-repo/tags?page=1>; rel="first", <https://api.github.com/repos/123456789/example-repo/tags?per_page=100&page=2>; rel="last"`, ""},
+		{"empty", ``, ""},
+		{"garbage", `junk not related to links header at all`, ""},
+		{"relative-url", `</repos/123456789/example-repo/tags?page=2>; rel="next"`, ""},
+		{"unparseable-url", `<https://api.github.com/%zz>; rel="next"`, ""},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			nextUrl := getNextUrl(tc.links)
+			require.Equal(t, tc.expectedUrl, nextUrl)
+		})
+	}
+
+}
+
+func TestRepoFileApiPath(t *testing.T) {
+	t.Parallel()
+
+	repo := GitHubRepo{Owner: "foo", Name: "bar"}
+
+	cases := []struct {
+		name         string
+		ref          string
+		filePath     string
+		expectedPath string
+	}{
+		{"relative-path", "main", "scripts/install.sh", "repos/foo/bar/contents/scripts/install.sh?ref=main"},
+		{"absolute-path", "v1.0.0", "/scripts/install.sh", "repos/foo/bar/contents/scripts/install.sh?ref=v1.0.0"},
+		{"escaped-path", "main", "/docs/read me#1.md", "repos/foo/bar/contents/docs/read%20me%231.md?ref=main"},
+		{"repo-root", "main", "/", "repos/foo/bar/contents?ref=main"},
+		{"escaped-ref", "feature/a&b", "README.md", "repos/foo/bar/contents/README.md?ref=feature%2Fa%26b"},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expectedPath, repoFileApiPath(repo, tc.ref, tc.filePath))
+		})
+	}
+}
+
+func TestGetRateLimitReset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	cases := []struct {
+		name              string
+		statusCode        int
+		remaining         string
+		reset             string
+		retryAfter        string
+		body              string
+		expectedLimited   bool
+		expectedResetTime time.Time
+	}{
+		{"exhausted-403", http.StatusForbidden, "0", "1700000000", "", "", true, time.Unix(1700000000, 0)},
+		{"exhausted-429", http.StatusTooManyRequests, "0", "1700000000", "", "", true, time.Unix(1700000000, 0)},
+		{"exhausted-no-reset", http.StatusForbidden, "0", "", "", "", true, now.Add(defaultRateLimitWait)},
+		{"retry-after-403", http.StatusForbidden, "10", "1700000000", "30", "", true, now.Add(30 * time.Second)},
+		{"retry-after-date", http.StatusTooManyRequests, "", "", "Sun, 13 Sep 2020 12:26:40 GMT", "", true, time.Unix(1600000000, 0)},
+		{"secondary-403", http.StatusForbidden, "10", "1700000000", "", `{"message": "You have exceeded a secondary rate limit."}`, true, now.Add(defaultRateLimitWait)},
+		{"no-headers-429", http.StatusTooManyRequests, "", "", "", "", true, now.Add(defaultRateLimitWait)},
+		{"not-exhausted-403", http.StatusForbidden, "10", "1700000000", "", "", false, time.Time{}},
+		{"no-headers-403", http.StatusForbidden, "", "", "", "", false, time.Time{}},
+		{"ok", http.StatusOK, "0", "1700000000", "", "", false, time.Time{}},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{StatusCode: tc.statusCode, Header: http.Header{}}
+			if tc.remaining != "" {
+				resp.Header.Set("X-RateLimit-Remaining", tc.remaining)
+			}
+			if tc.reset != "" {
+				resp.Header.Set("X-RateLimit-Reset", tc.reset)
+			}
+			if tc.retryAfter != "" {
+				resp.Header.Set("Retry-After", tc.retryAfter)
+			}
+
+			resetTime, limited := getRateLimitReset(resp, tc.body, now)
+			require.Equal(t, tc.expectedLimited, limited)
+			require.True(t, tc.expectedResetTime.Equal(resetTime), resetTime.String())
+		})
+	}
+}
+
+func TestCallGitHubApiGivesUpWaitingForRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	// The waits are logged with the logger of the context, such as the one in a Fetcher's options
+	var logs bytes.Buffer
+	logger := GetProjectLoggerWithWriter(&logs)
+	waitingCtx := withLogger(withConnection(context.Background(), &connection{waitForRateLimit: true}), logger)
+	_, err := callGitHubApiRaw(waitingCtx, server.URL, "GET", "", nil)
+	require.NotNil(t, err)
+	assert.Equal(t, githubApiRateLimitExceeded, err.errorCode)
+	assert.Equal(t, int32(maxRateLimitRetries+1), atomic.LoadInt32(&requests))
+	assert.Contains(t, logs.String(), "GitHub API rate limit exhausted")
+
+	// Waiting stops as soon as the context is done, rather than when the rate limit resets
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer slowServer.Close()
+	ctx, cancel := context.WithTimeout(waitingCtx, 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = callGitHubApiRaw(ctx, slowServer.URL, "GET", "", nil)
+	require.NotNil(t, err)
+	assert.Less(t, time.Since(started), 10*time.Second)
+}
+
+func TestWriteCounterPrintsProgressToOut(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	counter := &writeCounter{action: "Verifying checksum", total: 2048, suffix: " / 2.0 kB", started: time.Now(), out: &out}
+	_, err := counter.Write(make([]byte, 2048))
+	require.NoError(t, err)
+	counter.finish()
+
+	assert.True(t, strings.HasSuffix(out.String(), "\rVerifying checksum... 2.0 kB / 2.0 kB\n"), out.String())
+}
+
+func TestWriteCounterLogsStructuredProgress(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	logger.Formatter = &logrus.JSONFormatter{}
+	counter := &writeCounter{action: "Downloading", total: 10, started: time.Now(), logger: logrus.NewEntry(logger)}
+
+	// The first write is logged, the second is within the interval of it, and the last reaches the total
+	for _, size := range []int{4, 4, 2} {
+		_, err := counter.Write(make([]byte, size))
+		require.NoError(t, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "Downloading", entry["action"])
+	assert.Equal(t, float64(10), entry["bytes"])
+	assert.Equal(t, float64(10), entry["total_bytes"])
+	assert.NotContains(t, entry, "eta_seconds")
+}
+
+func TestWriteResponseToDiskRemovesPartialFileWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	// Send part of the body, then hang until the client gives up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, fetchErr := callGitHubApiRaw(ctx, server.URL, "GET", "", map[string]string{})
+	require.Nil(t, fetchErr)
+	cancel()
+
+	destPath := filepath.Join(t.TempDir(), "asset")
+	require.NotNil(t, writeResonseToDisk(resp, destPath, false))
+	require.False(t, fileExists(destPath))
+}
+
+func TestCallGitHubApiRawCanceled(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("Expected no request to be sent with a canceled context")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, fetchErr := callGitHubApiRaw(ctx, server.URL, "GET", "", map[string]string{})
+	require.NotNil(t, fetchErr)
+}
+
+func TestParseUrlIntoGithubInstance(t *testing.T) {
+	t.Parallel()
+
+	ghTestInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+	wwwGhTestInst := GitHubInstance{
+		BaseUrl: "www.github.com",
+		ApiUrl:  "api.github.com",
+	}
+	gheTestInst := GitHubInstance{
+		BaseUrl: "ghe.mycompany.com",
+		ApiUrl:  "ghe.mycompany.com/api/v3",
+	}
+	wwwGheTestInst := GitHubInstance{
+		BaseUrl: "www.ghe.mycompany.com",
+		ApiUrl:  "www.ghe.mycompany.com/api/v3",
+	}
+	myCoTestInst := GitHubInstance{
+		BaseUrl: "mycogithub.com",
+		ApiUrl:  "mycogithub.com/api/v3",
+	}
+	wwwMyCoTestInst := GitHubInstance{
+		BaseUrl: "www.mycogithub.com",
+		ApiUrl:  "www.mycogithub.com/api/v3",
+	}
+	localTestInst := GitHubInstance{
+		BaseUrl: "mycogithub.local",
+		ApiUrl:  "mycogithub.local/api/v3",
+	}
+	netTestInst := GitHubInstance{
+		BaseUrl: "mycogithub.net",
+		ApiUrl:  "mycogithub.net/api/v3",
+	}
+
+	cases := []struct {
+		repoUrl      string
+		apiv         string
+		expectedInst GitHubInstance
+	}{
+		{"http://www.github.com/gruntwork-io/script-modules/", "", wwwGhTestInst},
+		{"https://www.github.com/gruntwork-io/script-modules/", "", wwwGhTestInst},
+		{"http://github.com/gruntwork-io/script-modules/", "", ghTestInst},
+		{"http://www.ghe.mycompany.com/gruntwork-io/script-modules", "v3", wwwGheTestInst},
+		{"https://www.ghe.mycompany.com/gruntwork-io/script-modules", "v3", wwwGheTestInst},
+		{"http://ghe.mycompany.com/gruntwork-io/script-modules", "v3", gheTestInst},
+		{"http://www.mycogithub.com/gruntwork-io/script-modules", "v3", wwwMyCoTestInst},
+		{"https://www.mycogithub.com/gruntwork-io/script-modules", "v3", wwwMyCoTestInst},
+		{"http://mycogithub.com/gruntwork-io/script-modules", "v3", myCoTestInst},
+		{"http://mycogithub.local/gruntwork-io/script-modules", "v3", localTestInst},
+		{"http://mycogithub.net/gruntwork-io/script-modules", "v3", netTestInst},
+	}
+
+	for _, tc := range cases {
+		logger := GetProjectLogger()
+		inst, err := ParseUrlIntoGithubInstance(logger, tc.repoUrl, tc.apiv)
+		if err != nil {
+			t.Fatalf("error extracting url %s into a GitHubRepo struct: %s", tc.repoUrl, err)
+		}
+
+		if inst.BaseUrl != tc.expectedInst.BaseUrl {
+			t.Fatalf("while parsing %s, expected base url %s, received %s", tc.repoUrl, tc.expectedInst.BaseUrl, inst.BaseUrl)
+		}
+
+		if inst.ApiUrl != tc.expectedInst.ApiUrl {
+			t.Fatalf("while parsing %s, expected api url %s, received %s", tc.repoUrl, tc.expectedInst.ApiUrl, inst.ApiUrl)
+		}
+	}
+}
+
+func TestParseUrlIntoGitHubRepo(t *testing.T) {
+	t.Parallel()
+	ghTestInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+	gheTestInst := GitHubInstance{
+		BaseUrl: "ghe.mycompany.com",
+		ApiUrl:  "ghe.mycompany.com/api/v3",
+	}
+	myCoTestInst := GitHubInstance{
+		BaseUrl: "mycogithub.com",
+		ApiUrl:  "mycogithub.com/api/v3",
+	}
+
+	cases := []struct {
+		repoUrl  string
+		owner    string
+		name     string
+		token    string
+		testInst GitHubInstance
+	}{
+		{"https://github.com/brikis98/ping-play", "brikis98", "ping-play", "", ghTestInst},
+		{"http://github.com/brikis98/ping-play", "brikis98", "ping-play", "", ghTestInst},
+		{"https://github.com/gruntwork-io/script-modules", "gruntwork-io", "script-modules", "", ghTestInst},
+		{"http://github.com/gruntwork-io/script-modules", "gruntwork-io", "script-modules", "", ghTestInst},
+		{"http://www.github.com/gruntwork-io/script-modules", "gruntwork-io", "script-modules", "", ghTestInst},
+		{"http://www.github.com/gruntwork-io/script-modules/", "gruntwork-io", "script-modules", "", ghTestInst},
+		{"http://www.github.com/gruntwork-io/script-modules?foo=bar", "gruntwork-io", "script-modules", "token", ghTestInst},
+		{"http://www.github.com/gruntwork-io/script-modules?foo=bar&foo=baz", "gruntwork-io", "script-modules", "token", ghTestInst},
+		{"http://www.ghe.mycompany.com/gruntwork-io/script-modules?foo=bar&foo=baz", "gruntwork-io", "script-modules", "token", gheTestInst},
+		{"https://www.ghe.mycompany.com/gruntwork-io/script-modules?foo=bar&foo=baz", "gruntwork-io", "script-modules", "token", gheTestInst},
+		{"http://ghe.mycompany.com/gruntwork-io/script-modules?foo=bar&foo=baz", "gruntwork-io", "script-modules", "token", gheTestInst},
+		{"http://mycogithub.com/gruntwork-io/script-modules?foo=bar&foo=baz", "gruntwork-io", "script-modules", "token", myCoTestInst},
+	}
+
+	for _, tc := range cases {
+		repo, err := ParseUrlIntoGitHubRepo(tc.repoUrl, tc.token, tc.testInst)
+		if err != nil {
+			t.Fatalf("error extracting url %s into a GitHubRepo struct: %s", tc.repoUrl, err)
+		}
+
+		if repo.Owner != tc.owner {
+			t.Fatalf("while extracting %s, expected owner %s, received %s", tc.repoUrl, tc.owner, repo.Owner)
+		}
+
+		if repo.Name != tc.name {
+			t.Fatalf("while extracting %s, expected name %s, received %s", tc.repoUrl, tc.name, repo.Name)
+		}
+
+		if repo.Url != tc.repoUrl {
+			t.Fatalf("while extracting %s, expected url %s, received %s", tc.repoUrl, tc.repoUrl, repo.Url)
+		}
+
+		if repo.Token != tc.token {
+			t.Fatalf("while extracting %s, expected token %s, received %s", tc.repoUrl, tc.token, repo.Token)
+		}
+	}
+}
+
+func TestParseUrlThrowsErrorOnMalformedUrl(t *testing.T) {
+	t.Parallel()
+	testInst := GitHubInstance{}
+
+	cases := []struct {
+		repoUrl string
+	}{
+		{"https://githubb.com/brikis98/ping-play"},
+		{"github.com/brikis98/ping-play"},
+		{"curl://github.com/brikis98/ping-play"},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseUrlIntoGitHubRepo(tc.repoUrl, "", testInst)
+		if err == nil {
+			t.Fatalf("Expected error on malformed url %s, but no error was received.", tc.repoUrl)
+		}
+	}
+}
+
+func TestParseUrlIntoGitHubRepoRejectsInvalidNames(t *testing.T) {
+	t.Parallel()
+	ghTestInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		repoUrl string
+	}{
+		{"https://github.com/../ping-play"},
+		{"https://github.com/brikis98/.."},
+		{"https://github.com/brikis98/ping%2Fplay"},
+		{"https://github.com/brikis 98/ping-play"},
+		{"https://githubxcom/brikis98/ping-play"},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseUrlIntoGitHubRepo(tc.repoUrl, "", ghTestInst)
+		if err == nil {
+			t.Fatalf("Expected error on invalid url %s, but no error was received.", tc.repoUrl)
+		}
+	}
+}
+
+func TestIsSameOrigin(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		currentUrl string
+		nextUrl    string
+		expected   bool
+	}{
+		{"same-host", "https://api.github.com/repos/foo/bar/tags", "https://api.github.com/repos/foo/bar/tags?page=2", true},
+		{"different-host", "https://api.github.com/repos/foo/bar/tags", "https://evil.example.com/repos/foo/bar/tags?page=2", false},
+		{"different-scheme", "https://api.github.com/repos/foo/bar/tags", "http://api.github.com/repos/foo/bar/tags?page=2", false},
+		{"different-port", "https://ghe.mycompany.com/api/v3/repos/foo/bar/tags", "https://ghe.mycompany.com:8443/api/v3/repos/foo/bar/tags", false},
+		{"userinfo-trick", "https://api.github.com/repos/foo/bar/tags", "https://api.github.com@evil.example.com/repos", false},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, isSameOrigin(tc.currentUrl, tc.nextUrl))
+		})
+	}
+}
+
+func FuzzGetNextUrl(f *testing.F) {
+	f.Add(`<https://api.github.com/repos/foo/bar/tags?per_page=100&page=2>; rel="next", <https://api.github.com/repos/foo/bar/tags?per_page=100&page=3>; rel="last"`)
+	f.Add(`<https://api.github.com/repos/foo/bar/tags?page=1>; rel="first"`)
+	f.Add(`<>; rel="next"`)
+	f.Add(`<<<>>>;;rel="next",,`)
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, links string) {
+		nextUrl := getNextUrl(links)
+		if nextUrl == "" {
+			return
+		}
+		parsedUrl, err := url.Parse(nextUrl)
+		require.NoError(t, err)
+		require.True(t, parsedUrl.IsAbs())
+		require.NotEmpty(t, parsedUrl.Host)
+	})
+}
+
+func FuzzParseUrlIntoGitHubRepo(f *testing.F) {
+	f.Add("https://github.com/gruntwork-io/fetch")
+	f.Add("http://www.github.com/gruntwork-io/fetch/?foo=bar#baz")
+	f.Add("https://ghe.mycompany.com/gruntwork-io/fetch")
+	f.Add("https://ghe.(my|company).com/gruntwork-io/fetch")
+	f.Add("https://github.com/../..")
+	f.Add("://")
+
+	f.Fuzz(func(t *testing.T, repoUrl string) {
+		instance, fetchErr := ParseUrlIntoGithubInstance(GetProjectLogger(), repoUrl, "v3")
+		if fetchErr != nil {
+			return
+		}
+
+		repo, fetchErr := ParseUrlIntoGitHubRepo(repoUrl, "", instance)
+		if fetchErr != nil {
+			return
+		}
+		require.True(t, isValidGitHubName(repo.Owner), "invalid owner %q", repo.Owner)
+		require.True(t, isValidGitHubName(repo.Name), "invalid name %q", repo.Name)
+	})
+}
+
+func TestGetGitHubReleaseInfo(t *testing.T) {
+	t.Parallel()
+
+	token := os.Getenv("GITHUB_OAUTH_TOKEN")
+
+	expectedFetchTestPrivateRelease := GitHubReleaseApiResponse{
+		Id:        3064041,
+		Url:       "https://api.github.com/repos/gruntwork-io/fetch-test-private/releases/3064041",
+		UploadUrl: "https://uploads.github.com/repos/gruntwork-io/fetch-test-private/releases/3064041/assets{?name,label}",
+		Name:      "v0.0.2",
+		Assets: []GitHubReleaseAsset{
+			{
+				Id:   1872521,
+				Url:  "https://api.github.com/repos/gruntwork-io/fetch-test-private/releases/assets/1872521",
+				Name: "test-asset.png",
+			},
+		},
+	}
+
+	expectedFetchTestPublicRelease := GitHubReleaseApiResponse{
+		Id:        3065803,
+		Url:       "https://api.github.com/repos/gruntwork-io/fetch-test-public/releases/3065803",
+		UploadUrl: "https://uploads.github.com/repos/gruntwork-io/fetch-test-public/releases/3065803/assets{?name,label}",
+		Name:      "v0.0.3",
+		Assets:    []GitHubReleaseAsset{},
+	}
+
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		repoUrl   string
+		repoToken string
+		tag       string
+		expected  GitHubReleaseApiResponse
+	}{
+		{"https://github.com/gruntwork-io/fetch-test-private", token, "v0.0.2", expectedFetchTestPrivateRelease},
+		{"https://github.com/gruntwork-io/fetch-test-public", "", "v0.0.3", expectedFetchTestPublicRelease},
+	}
+
+	for _, tc := range cases {
+		repo, err := ParseUrlIntoGitHubRepo(tc.repoUrl, tc.repoToken, testInst)
+		if err != nil {
+			t.Fatalf("Failed to parse %s into GitHub URL due to error: %s", tc.repoUrl, err.Error())
+		}
+
+		resp, err := GetGitHubReleaseInfo(context.Background(), repo, tc.tag)
+		if err != nil {
+			t.Fatalf("Failed to fetch GitHub release info for repo %s due to error: %s", tc.repoToken, err.Error())
+		}
+
+		// Asset sizes aren't known ahead of time, so just make sure they were populated before comparing the rest
+		for i := range resp.Assets {
+			require.True(t, resp.Assets[i].Size > 0, "Expected asset %s to have a non-zero size", resp.Assets[i].Name)
+			resp.Assets[i].Size = 0
+		}
+
+		if !reflect.DeepEqual(tc.expected, resp) {
+			t.Fatalf("Expected GitHub release %v but got GitHub release %v", tc.expected, resp)
+		}
+	}
+}
+
+func TestDownloadGitHubPulicReleaseAsset(t *testing.T) {
+	t.Parallel()
+
+	token := os.Getenv("GITHUB_OAUTH_TOKEN")
+
+	testInst := GitHubInstance{
+		BaseUrl: "github.com",
+		ApiUrl:  "api.github.com",
+	}
+
+	cases := []struct {
+		repoUrl   string
+		repoToken string
+		tag       string
+		assetId   int
+		progress  bool
+	}{
+		{"https://github.com/gruntwork-io/fetch-test-private", token, "v0.0.2", 1872521, false},
+		{"https://github.com/gruntwork-io/fetch-test-public", "", "v0.0.2", 1872641, false},
+		{"https://github.com/gruntwork-io/fetch-test-public", "", "v0.0.2", 1872641, true},
+	}
+
+	for _, tc := range cases {
+		repo, err := ParseUrlIntoGitHubRepo(tc.repoUrl, tc.repoToken, testInst)
+		if err != nil {
+			t.Fatalf("Failed to parse %s into GitHub URL due to error: %s", tc.repoUrl, err.Error())
+		}
+
+		tmpFile, tmpErr := ioutil.TempFile("", "test-download-release-asset")
+		if tmpErr != nil {
+			t.Fatalf("Failed to create temp file due to error: %s", tmpErr.Error())
+		}
+
+		if err := DownloadReleaseAsset(context.Background(), repo, tc.assetId, tmpFile.Name(), tc.progress); err != nil {
+			t.Fatalf("Failed to download asset %d to %s from GitHub URL %s due to error: %s", tc.assetId, tmpFile.Name(), tc.repoUrl, err.Error())
+		}
+
+		defer os.Remove(tmpFile.Name())
+
+		if !fileExists(tmpFile.Name()) {
+			t.Fatalf("Got no errors downloading asset %d to %s from GitHub URL %s, but %s does not exist!", tc.assetId, tmpFile.Name(), tc.repoUrl, tmpFile.Name())
+		}
+	}
+}
+
+func TestDownloadReleaseAssetStopsAtItsSize(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("the asset, followed by a whole lot more than the release says it has"))
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:          server.URL + "/foo/bar",
+		GithubApiVersion: "v3",
+		Connection:       ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+	})
+	require.NoError(t, err)
+	ctx := fetcher.withConnection(context.Background())
+
+	asset := &GitHubReleaseAsset{Id: 1, Name: "tool", Size: int64(len("the asset"))}
+	destPath := filepath.Join(t.TempDir(), "tool")
+	fetchErr := downloadReleaseAsset(ctx, fetcher.repo, asset, destPath, false)
+	require.NotNil(t, fetchErr)
+	assert.Contains(t, fetchErr.Error(), "larger than the maximum size of 9 bytes")
+	assert.NoFileExists(t, destPath)
+
+	// Nothing past the asset's size is streamed
+	var streamed bytes.Buffer
+	require.NotNil(t, streamReleaseAsset(ctx, fetcher.repo, asset, &streamed))
+	assert.LessOrEqual(t, streamed.Len(), len("the asset"))
+
+	// An asset whose size isn't known is downloaded in full
+	fetchErr = downloadReleaseAsset(ctx, fetcher.repo, &GitHubReleaseAsset{Id: 1, Name: "tool"}, destPath, false)
+	require.Nil(t, fetchErr)
+	assert.FileExists(t, destPath)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// A fake GitHub repo foo/bar with tags v1.0.0 and v1.1.0-rc.1, and a draft release of v1.2.0, which only the given
+// token can see. Every release has a single asset, tool, whose contents are its tag. The latest release is v1.0.0.
+// Each request to list the tags is counted in tagListings.
+func newFakeGitHubRepoWithDrafts(t *testing.T, token string, tagListings *int32) *httptest.Server {
+	releases := []GitHubReleaseApiResponse{
+		{Id: 3, TagName: "v1.2.0", Draft: true, Assets: []GitHubReleaseAsset{{Id: 3, Name: "tool", Size: 6}}},
+		{Id: 2, TagName: "v1.1.0-rc.1", Assets: []GitHubReleaseAsset{{Id: 2, Name: "tool", Size: 11}}},
+		{Id: 1, TagName: "v1.0.0", Assets: []GitHubReleaseAsset{{Id: 1, Name: "tool", Size: 6}}},
+	}
+	authorized := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == authorizationHeader(token, AuthSchemeAuto)
+	}
+
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/repos/foo/bar/tags":
+			atomic.AddInt32(tagListings, 1)
+			w.Write([]byte(`[{"name": "v1.1.0-rc.1", "commit": {"sha": "bbbb"}}, {"name": "v1.0.0", "commit": {"sha": "aaaa"}}]`))
+		case r.URL.Path == "/api/v3/repos/foo/bar/releases/latest":
+			json.NewEncoder(w).Encode(releases[2])
+		case r.URL.Path == "/api/v3/repos/foo/bar/commits/v1.0.0":
+			assert.Equal(t, "application/vnd.github.sha", r.Header.Get("Accept"))
+			w.Write([]byte("aaaa"))
+		case r.URL.Path == "/api/v3/repos/foo/bar/releases":
+			var visible []GitHubReleaseApiResponse
+			for _, release := range releases {
+				if !release.Draft || authorized(r) {
+					visible = append(visible, release)
+				}
+			}
+			json.NewEncoder(w).Encode(visible)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/tags/"):
+			tag := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/tags/")
+			for _, release := range releases {
+				if release.TagName == tag && !release.Draft {
+					json.NewEncoder(w).Encode(release)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"):
+			var id int
+			fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"), &id)
+			release := releases[len(releases)-id]
+			if release.Draft && !authorized(r) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(release.TagName))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFetchWithPrereleasesAndDrafts(t *testing.T) {
+	t.Parallel()
+
+	var tagListings int32
+	server := newFakeGitHubRepoWithDrafts(t, "push-token", &tagListings)
+	defer server.Close()
+	caCert := writeTestServerCaCert(t, server)
+
+	testCases := []struct {
+		name               string
+		tagConstraint      string
+		includePrereleases bool
+		includeDrafts      bool
+		token              string
+		expectedTag        string
+	}{
+		{"default", "~>1.0", false, false, "", "v1.0.0"},
+		{"prerelease-constraint", "~>1.1.0-rc.1", false, false, "", "v1.1.0-rc.1"},
+		{"include-prereleases", "~>1.0", true, false, "", "v1.1.0-rc.1"},
+		{"include-drafts", "~>1.0", false, true, "push-token", "v1.2.0"},
+		{"include-drafts-without-push-access", "~>1.0", false, true, "read-token", "v1.0.0"},
+		{"exact-draft", "v1.2.0", false, true, "push-token", "v1.2.0"},
+		{"exact-draft-not-included", "v1.2.0", false, false, "push-token", ""},
+		{"include-drafts-without-token", "~>1.0", false, true, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:            server.URL + "/foo/bar",
+				GithubApiVersion:   "v3",
+				GithubToken:        tc.token,
+				TagConstraint:      tc.tagConstraint,
+				IncludePrereleases: tc.includePrereleases,
+				IncludeDrafts:      tc.includeDrafts,
+				ReleaseAsset:       "tool",
+				LocalDownloadPath:  destPath,
+				Connection:         ConnectionOptions{CaCert: caCert},
+			})
+			if err == nil {
+				var result *Result
+				if result, err = fetcher.Fetch(context.Background(), io.Discard); err == nil {
+					assert.Equal(t, tc.expectedTag, result.Tag)
+				}
+			}
+			if tc.expectedTag == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "tool"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTag, string(contents))
+		})
+	}
+}
+
+func TestFetchLatestRelease(t *testing.T) {
+	t.Parallel()
+
+	var tagListings int32
+	server := newFakeGitHubRepoWithDrafts(t, "", &tagListings)
+	defer server.Close()
+
+	destPath := t.TempDir()
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:           server.URL + "/foo/bar",
+		GithubApiVersion:  "v3",
+		TagConstraint:     LatestReleaseTag,
+		ReleaseAsset:      "tool",
+		LocalDownloadPath: destPath,
+		Connection:        ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+	})
+	require.NoError(t, err)
+
+	result, err := fetcher.Fetch(context.Background(), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", result.Tag)
+	assert.Equal(t, "aaaa", result.TagCommitSha)
+	assert.Zero(t, atomic.LoadInt32(&tagListings), "the tags should not be listed")
+	contents, err := ioutil.ReadFile(filepath.Join(destPath, "tool"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", string(contents))
+}
+
+// The SHAs of the commits tag v1.0.0 and branch main of the repo of newFakeGitHubRepoWithCommits point to
+const (
+	fakeTagCommitSha    = "0123456789abcdef0123456789abcdef01234567"
+	fakeBranchCommitSha = "89abcdef0123456789abcdef0123456789abcdef"
+)
+
+// A fake GitHub repo foo/bar with tag v1.0.0 at fakeTagCommitSha, whose release has a single asset, tool, and branch
+// main at fakeBranchCommitSha
+func newFakeGitHubRepoWithCommits(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/tags":
+			fmt.Fprintf(w, `[{"name": "v1.0.0", "commit": {"sha": "%s"}}]`, fakeTagCommitSha)
+		case "/api/v3/repos/foo/bar/releases/tags/v1.0.0":
+			json.NewEncoder(w).Encode(GitHubReleaseApiResponse{Id: 1, TagName: "v1.0.0", Assets: []GitHubReleaseAsset{{Id: 1, Name: "tool", Size: 6}}})
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write([]byte("v1.0.0"))
+		case "/api/v3/repos/foo/bar/commits/main":
+			assert.Equal(t, "application/vnd.github.sha", r.Header.Get("Accept"))
+			w.Write([]byte(fakeBranchCommitSha))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFetchResolveRef(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeGitHubRepoWithCommits(t)
+	defer server.Close()
+	caCert := writeTestServerCaCert(t, server)
+
+	fetcher, err := NewFetcher(Options{
+		RepoUrl:           server.URL + "/foo/bar",
+		GithubApiVersion:  "v3",
+		TagConstraint:     "~>1.0",
+		ResolveRef:        true,
+		ReleaseAsset:      "tool",
+		LocalDownloadPath: t.TempDir(),
+		Connection:        ConnectionOptions{CaCert: caCert},
+	})
+	require.NoError(t, err)
+	result, err := fetcher.Fetch(context.Background(), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", result.Tag)
+	assert.Equal(t, fakeTagCommitSha, result.CommitSha)
+
+	fetcher, err = NewFetcher(Options{
+		RepoUrl:           server.URL + "/foo/bar",
+		GithubApiVersion:  "v3",
+		BranchName:        "main",
+		ResolveRef:        true,
+		SourcePaths:       []string{"/modules"},
+		LocalDownloadPath: t.TempDir(),
+		Connection:        ConnectionOptions{CaCert: caCert},
+	})
+	require.NoError(t, err)
+	plan, err := fetcher.Plan(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, fakeBranchCommitSha, plan.CommitSha)
+	assert.Equal(t, fakeBranchCommitSha, plan.Ref)
+}
+
+func TestFetchExpectedCommitSha(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeGitHubRepoWithCommits(t)
+	defer server.Close()
+	caCert := writeTestServerCaCert(t, server)
+
+	testCases := []struct {
+		name              string
+		tagConstraint     string
+		expectedCommitSha string
+		expectError       bool
+	}{
+		{"matches", "~>1.0", fakeTagCommitSha, false},
+		{"matches-exact-tag", "v1.0.0", fakeTagCommitSha, false},
+		{"matches-uppercase", "~>1.0", strings.ToUpper(fakeTagCommitSha), false},
+		{"moved", "~>1.0", fakeBranchCommitSha, true},
+		{"abbreviated", "~>1.0", fakeTagCommitSha[:7], true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:           server.URL + "/foo/bar",
+				GithubApiVersion:  "v3",
+				TagConstraint:     tc.tagConstraint,
+				ExpectedCommitSha: tc.expectedCommitSha,
+				ReleaseAsset:      "tool",
+				LocalDownloadPath: destPath,
+				Connection:        ConnectionOptions{CaCert: caCert},
+			})
+			if err == nil {
+				_, err = fetcher.Fetch(context.Background(), io.Discard)
+			}
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.False(t, fileExists(filepath.Join(destPath, "tool")), "nothing should be downloaded")
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, fileExists(filepath.Join(destPath, "tool")))
+		})
+	}
+}
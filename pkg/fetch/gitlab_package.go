@@ -0,0 +1,285 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The headers GitLab reads access tokens and CI/CD job tokens from
+const (
+	gitlabTokenHeader    = "PRIVATE-TOKEN"
+	gitlabJobTokenHeader = "JOB-TOKEN"
+)
+
+// A package in the Generic Packages registry of a GitLab project, which many projects publish their binaries to rather
+// than link from their releases. The "tags" of the registry are the versions of the package, and the "release assets"
+// of a tag are the files of that version.
+type GitlabPackageRegistry struct {
+	Url         string // The URL of the package, as files are uploaded to it, e.g. https://gitlab.com/api/v4/projects/1234/packages/generic/tool
+	ApiUrl      string // The URL of the GitLab API, e.g. https://gitlab.com/api/v4
+	Project     string // The ID or the full path of the project, e.g. 1234 or group/subgroup/tool
+	PackageName string
+	Token       string // A personal, project, or group access token
+	JobToken    string // A CI/CD job token, which is used if Token isn't set
+
+	// The IDs of the package's versions, listed on the first request that needs them
+	packageIds map[string]int
+}
+
+// Parse the URL of a package in the Generic Packages registry of a GitLab project, which has the form
+// https://<host>[/<context-path>]/api/v4/projects/<id or url-encoded path>/packages/generic/<package>, the same URL its
+// files are uploaded to
+func ParseGitlabPackageRegistry(repoUrl string, token string, jobToken string) (*GitlabPackageRegistry, error) {
+	const form = "https://<host>/api/v4/projects/<id or url-encoded path>/packages/generic/<package>"
+
+	parsedUrl, err := url.Parse(repoUrl)
+	if err != nil || (parsedUrl.Scheme != "https" && parsedUrl.Scheme != "http") || parsedUrl.Host == "" {
+		return nil, fmt.Errorf("The GitLab package %s must be a URL of the form %s.", repoUrl, form)
+	}
+	contextPath, projectPackage, foundProjects := strings.Cut(parsedUrl.EscapedPath(), "/api/v4/projects/")
+	escapedProject, packageName, foundPackage := strings.Cut(projectPackage, "/packages/generic/")
+	packageName = strings.TrimSuffix(packageName, "/")
+	if !foundProjects || !foundPackage || escapedProject == "" || packageName == "" || strings.Contains(packageName, "/") {
+		return nil, fmt.Errorf("The GitLab package %s must be a URL of the form %s.", repoUrl, form)
+	}
+	project, err := url.PathUnescape(escapedProject)
+	if err != nil {
+		return nil, fmt.Errorf("The GitLab package %s must be a URL of the form %s.", repoUrl, form)
+	}
+	if packageName, err = url.PathUnescape(packageName); err != nil {
+		return nil, fmt.Errorf("The GitLab package %s must be a URL of the form %s.", repoUrl, form)
+	}
+
+	return &GitlabPackageRegistry{
+		Url:         strings.TrimSuffix(repoUrl, "/"),
+		ApiUrl:      parsedUrl.Scheme + "://" + parsedUrl.Host + contextPath + "/api/v4",
+		Project:     project,
+		PackageName: packageName,
+		Token:       token,
+		JobToken:    jobToken,
+	}, nil
+}
+
+// Return the CI/CD job token of the GitLab CI job fetch runs in, as read with getenv, if the job runs on the instance
+// whose API is at apiUrl. A job token is never sent to any other instance.
+func gitlabJobToken(apiUrl string, getenv func(string) string) string {
+	jobToken := getenv("CI_JOB_TOKEN")
+	if jobToken == "" {
+		return ""
+	}
+	jobApiUrl, err := url.Parse(getenv("CI_API_V4_URL"))
+	if err != nil {
+		return ""
+	}
+	parsedApiUrl, err := url.Parse(apiUrl)
+	if err != nil || jobApiUrl.Host != parsedApiUrl.Host {
+		return ""
+	}
+	return jobToken
+}
+
+func (registry *GitlabPackageRegistry) description() string {
+	return fmt.Sprintf("GitLab package %s of project %s", registry.PackageName, registry.Project)
+}
+
+// Return the URL of the registry's project in the GitLab API
+func (registry *GitlabPackageRegistry) projectUrl() string {
+	return registry.ApiUrl + "/projects/" + url.PathEscape(registry.Project)
+}
+
+func (registry *GitlabPackageRegistry) assetUrl(tag string, name string) string {
+	return fmt.Sprintf("%s/packages/generic/%s/%s/%s", registry.projectUrl(), url.PathEscape(registry.PackageName), url.PathEscape(tag), url.PathEscape(name))
+}
+
+// A package in the response to a GitLab list project packages request. For more info, see:
+// https://docs.gitlab.com/ee/api/packages.html#for-a-project
+type gitlabPackage struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// A file in the response to a GitLab list package files request. For more info, see:
+// https://docs.gitlab.com/ee/api/packages.html#list-package-files
+type gitlabPackageFile struct {
+	Id        int       `json:"id"`
+	FileName  string    `json:"file_name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Return the versions of the package that are valid versions
+func (registry *GitlabPackageRegistry) listTags(ctx context.Context, looseSemver bool) ([]string, error) {
+	packageIds, err := registry.listPackageIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for version := range packageIds {
+		if _, err := parseTagVersion(version, looseSemver); err == nil {
+			tags = append(tags, version)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// Return the ID of each version of the package that can be downloaded, listing them on the first call
+func (registry *GitlabPackageRegistry) listPackageIds(ctx context.Context) (map[string]int, error) {
+	if registry.packageIds != nil {
+		return registry.packageIds, nil
+	}
+
+	// The package_name filter also matches packages whose names merely contain it, so the names are checked here
+	query := url.Values{"package_type": {"generic"}, "package_name": {registry.PackageName}, "per_page": {"100"}}
+	packageIds := map[string]int{}
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		var packages []gitlabPackage
+		nextPage, fetchErr := registry.getJson(ctx, registry.projectUrl()+"/packages?"+query.Encode(), &packages)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		for _, pkg := range packages {
+			// Packages that are hidden, still being uploaded, or failed to upload have another status. A version that was
+			// published more than once is read from the newest package.
+			if pkg.Name != registry.PackageName || (pkg.Status != "" && pkg.Status != "default") {
+				continue
+			}
+			if existing, ok := packageIds[pkg.Version]; !ok || pkg.Id > existing {
+				packageIds[pkg.Version] = pkg.Id
+			}
+		}
+		page = nextPage
+	}
+	registry.packageIds = packageIds
+	return packageIds, nil
+}
+
+// Return the files of the given version of the package as a release, so that they can be matched as the assets of a
+// GitHub release are
+func (registry *GitlabPackageRegistry) release(ctx context.Context, tag string) (GitHubReleaseApiResponse, error) {
+	release := GitHubReleaseApiResponse{Name: tag}
+	packageIds, err := registry.listPackageIds(ctx)
+	if err != nil {
+		return release, err
+	}
+	packageId, ok := packageIds[tag]
+	if !ok {
+		return release, fmt.Errorf("The %s has no version %s.", registry.description(), tag)
+	}
+
+	// A file that's uploaded again is added alongside the old one, and downloads get the newest one
+	newest := map[string]gitlabPackageFile{}
+	var names []string
+	query := url.Values{"per_page": {"100"}}
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		var files []gitlabPackageFile
+		nextPage, fetchErr := registry.getJson(ctx, fmt.Sprintf("%s/packages/%d/package_files?%s", registry.projectUrl(), packageId, query.Encode()), &files)
+		if fetchErr != nil {
+			return release, fetchErr
+		}
+		for _, file := range files {
+			existing, seen := newest[file.FileName]
+			if !seen {
+				names = append(names, file.FileName)
+			}
+			if !seen || file.Id > existing.Id {
+				newest[file.FileName] = file
+			}
+		}
+		page = nextPage
+	}
+
+	for _, name := range names {
+		file := newest[name]
+		release.Assets = append(release.Assets, GitHubReleaseAsset{Id: file.Id, Url: registry.assetUrl(tag, name), Name: name, Size: file.Size, UpdatedAt: file.CreatedAt})
+	}
+	return release, nil
+}
+
+// Return a GET request for the given URL, with the registry's token
+func (registry *GitlabPackageRegistry) newRequest(ctx context.Context, requestUrl string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if registry.Token != "" {
+		request.Header.Set(gitlabTokenHeader, registry.Token)
+	} else if registry.JobToken != "" {
+		request.Header.Set(gitlabJobTokenHeader, registry.JobToken)
+	}
+	return request, nil
+}
+
+// Return an HTTP client that follows redirects, which GitLab sends to serve package files from object storage,
+// without sending the token to any other host
+func (registry *GitlabPackageRegistry) httpClient(ctx context.Context) *http.Client {
+	return newHttpClientWithoutCrossHostHeaders(ctx, gitlabTokenHeader, gitlabJobTokenHeader)
+}
+
+// Send a GET request for the given URL of the GitLab API, decode the JSON in its response into v, and return the
+// number of the next page of results, or an empty string if this was the last. The error code of a FetchError for a
+// response that didn't succeed is its status code.
+func (registry *GitlabPackageRegistry) getJson(ctx context.Context, requestUrl string, v interface{}) (string, *FetchError) {
+	request, err := registry.newRequest(ctx, requestUrl)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	resp, err := registry.httpClient(ctx).Do(request)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", newError(resp.StatusCode, fmt.Sprintf("Request to the %s failed with HTTP Response %d: %s", registry.description(), resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", wrapError(fmt.Errorf("Could not parse the response of the GitLab API to %s: %s", requestUrl, err))
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+// Download the file with the given name of the given version of the package to destPath. With noCache set, caches
+// along the way are asked not to serve a stored copy, as downloadUrl does.
+func (registry *GitlabPackageRegistry) downloadAsset(ctx context.Context, tag string, name string, destPath string, withProgress bool, noCache bool) *FetchError {
+	assetUrl := registry.assetUrl(tag, name)
+	var resp *http.Response
+	for refreshed := false; ; refreshed = true {
+		request, err := registry.newRequest(ctx, assetUrl)
+		if err != nil {
+			return wrapError(err)
+		}
+		if noCache {
+			request.Header.Set("Cache-Control", "no-cache")
+		}
+		if resp, err = registry.httpClient(ctx).Do(request); err != nil {
+			return wrapError(err)
+		}
+		// GitLab redirects to a signed URL of the file in object storage, which is only valid for a short time. If it
+		// had expired by the time it was requested, e.g. because the clocks disagree or the connection is slow, ask
+		// GitLab to sign a new one.
+		if refreshed || resp.StatusCode != http.StatusForbidden || resp.Request.URL.Host == request.URL.Host {
+			break
+		}
+		resp.Body.Close()
+		loggerFrom(ctx).Infof("Object storage denied the signed URL that %s redirected to, as it does once the URL expires. Asking GitLab for a new one.\n", assetUrl)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return newError(failedToDownloadFile, fmt.Sprintf("Failed to download %s. Received HTTP Response %d.", assetUrl, resp.StatusCode))
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
@@ -0,0 +1,252 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitlabPackageRegistry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		repoUrl             string
+		expectedApiUrl      string
+		expectedProject     string
+		expectedPackageName string
+	}{
+		{"https://gitlab.com/api/v4/projects/1234/packages/generic/tool", "https://gitlab.com/api/v4", "1234", "tool"},
+		{"https://gitlab.example.com/api/v4/projects/group%2Fsubgroup%2Ftool/packages/generic/tool/", "https://gitlab.example.com/api/v4", "group/subgroup/tool", "tool"},
+		{"https://example.com/gitlab/api/v4/projects/group/tool/packages/generic/tool-cli", "https://example.com/gitlab/api/v4", "group/tool", "tool-cli"},
+		{"https://gitlab.com/api/v4/projects/1234/packages/generic/tool/1.0.0", "", "", ""},
+		{"https://gitlab.com/group/tool/-/packages/5", "", "", ""},
+		{"gitlab.com/api/v4/projects/1234/packages/generic/tool", "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.repoUrl, func(t *testing.T) {
+			t.Parallel()
+			registry, err := ParseGitlabPackageRegistry(tc.repoUrl, "", "")
+			if tc.expectedProject == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedApiUrl, registry.ApiUrl)
+			assert.Equal(t, tc.expectedProject, registry.Project)
+			assert.Equal(t, tc.expectedPackageName, registry.PackageName)
+		})
+	}
+}
+
+func TestGitlabJobToken(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"CI_JOB_TOKEN": "job-token", "CI_API_V4_URL": "https://gitlab.example.com/api/v4"}
+	getenv := func(name string) string { return env[name] }
+	assert.Equal(t, "job-token", gitlabJobToken("https://gitlab.example.com/api/v4", getenv))
+	assert.Empty(t, gitlabJobToken("https://gitlab.com/api/v4", getenv))
+	assert.Empty(t, gitlabJobToken("https://gitlab.example.com/api/v4", func(string) string { return "" }))
+}
+
+// A fake GitLab instance with the generic package tool in project group/tool, which requires the given access token,
+// lists one package per page to exercise pagination, and redirects downloads to the given object storage server
+func newFakeGitlab(t *testing.T, token string, storage *httptest.Server) *httptest.Server {
+	type fakePackage struct {
+		gitlabPackage
+		files []gitlabPackageFile
+	}
+	packages := []fakePackage{
+		{gitlabPackage{Id: 1, Name: "tool", Version: "1.0.0", Status: "default"}, []gitlabPackageFile{{Id: 10, FileName: "tool_linux_amd64", Size: 8}}},
+		{gitlabPackage{Id: 2, Name: "tool", Version: "1.1.0", Status: "default"}, []gitlabPackageFile{
+			{Id: 20, FileName: "tool_linux_amd64", Size: 3},
+			{Id: 21, FileName: "SHA256SUMS", Size: 82},
+			{Id: 22, FileName: "tool_linux_amd64", Size: 8},
+		}},
+		{gitlabPackage{Id: 3, Name: "tool", Version: "1.2.0", Status: "processing"}, nil},
+		{gitlabPackage{Id: 4, Name: "tool-docs", Version: "9.0.0", Status: "default"}, nil},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/group%2Ftool/packages", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "generic", r.URL.Query().Get("package_type"))
+		page := 1
+		if r.URL.Query().Get("page") == "2" {
+			page = 2
+		}
+		// Two packages per page
+		if page == 1 {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		var pagePackages []gitlabPackage
+		for _, pkg := range packages[(page-1)*2 : page*2] {
+			pagePackages = append(pagePackages, pkg.gitlabPackage)
+		}
+		json.NewEncoder(w).Encode(pagePackages)
+	})
+	mux.HandleFunc("/api/v4/projects/group%2Ftool/packages/2/package_files", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(packages[1].files)
+	})
+	mux.HandleFunc("/api/v4/projects/group%2Ftool/packages/1/package_files", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(packages[0].files)
+	})
+	mux.HandleFunc("/api/v4/projects/group%2Ftool/packages/generic/tool/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, storage.URL+"/"+strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/group%2Ftool/packages/generic/tool/"), http.StatusFound)
+	})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(gitlabTokenHeader) != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestFetchFromGitlabPackageRegistry(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"/1.0.0/tool_linux_amd64": "old tool",
+		"/1.1.0/tool_linux_amd64": "new tool",
+		"/1.1.0/SHA256SUMS":       sha256Hex([]byte("new tool")) + "  tool_linux_amd64\n",
+	}
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(gitlabTokenHeader))
+		contents, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(contents))
+	}))
+	defer storage.Close()
+	gitlab := newFakeGitlab(t, "glpat-token", storage)
+	defer gitlab.Close()
+
+	testCases := []struct {
+		name         string
+		token        string
+		tag          string
+		checksumFile string
+		expectedTag  string
+	}{
+		{"constraint", "glpat-token", "~>1.0", "SHA256SUMS", "1.1.0"},
+		{"exact", "glpat-token", "1.0.0", "", "1.0.0"},
+		{"processing-version", "glpat-token", "1.2.0", "", ""},
+		{"wrong-token", "wrong", "~>1.0", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:                  gitlab.URL + "/api/v4/projects/group%2Ftool/packages/generic/tool",
+				Source:                   SourceGitlabPackage,
+				GitlabToken:              tc.token,
+				TagConstraint:            tc.tag,
+				ReleaseAsset:             "tool_linux_amd64",
+				ReleaseAssetChecksumFile: tc.checksumFile,
+				LocalDownloadPath:        destPath,
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if tc.expectedTag == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTag, result.Tag)
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "tool_linux_amd64"))
+			require.NoError(t, err)
+			assert.Equal(t, files["/"+tc.expectedTag+"/tool_linux_amd64"], string(contents))
+		})
+	}
+}
+
+func TestGitlabPackageRegistryReleaseUsesNewestFiles(t *testing.T) {
+	t.Parallel()
+
+	storage := httptest.NewServer(http.NotFoundHandler())
+	defer storage.Close()
+	gitlab := newFakeGitlab(t, "glpat-token", storage)
+	defer gitlab.Close()
+
+	registry, err := ParseGitlabPackageRegistry(gitlab.URL+"/api/v4/projects/group%2Ftool/packages/generic/tool", "glpat-token", "")
+	require.NoError(t, err)
+	tags, err := registry.listTags(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0", "1.1.0"}, tags)
+
+	release, err := registry.release(context.Background(), "1.1.0")
+	require.NoError(t, err)
+	require.Len(t, release.Assets, 2)
+	assert.Equal(t, GitHubReleaseAsset{Id: 22, Url: registry.assetUrl("1.1.0", "tool_linux_amd64"), Name: "tool_linux_amd64", Size: 8, UpdatedAt: time.Time{}}, release.Assets[0])
+	assert.Equal(t, "SHA256SUMS", release.Assets[1].Name)
+}
+
+func TestGitlabPackageRegistryRefreshesExpiredStorageUrls(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		denials   int32
+		expectErr bool
+	}{
+		{"valid", 0, false},
+		{"expired-once", 1, false},
+		{"always-denied", 100, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Object storage denies the signed URLs GitLab redirects to once they expire
+			var requests int32
+			storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tc.denials {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte("new tool"))
+			}))
+			defer storage.Close()
+			gitlab := newFakeGitlab(t, "glpat-token", storage)
+			defer gitlab.Close()
+
+			registry, err := ParseGitlabPackageRegistry(gitlab.URL+"/api/v4/projects/group%2Ftool/packages/generic/tool", "glpat-token", "")
+			require.NoError(t, err)
+			destPath := filepath.Join(t.TempDir(), "tool_linux_amd64")
+			fetchErr := registry.downloadAsset(context.Background(), "1.1.0", "tool_linux_amd64", destPath, false, false)
+			if tc.expectErr {
+				require.NotNil(t, fetchErr)
+				// The URL is only refreshed once, rather than for as long as it's denied
+				assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+				return
+			}
+			require.Nil(t, fetchErr)
+			contents, err := ioutil.ReadFile(destPath)
+			require.NoError(t, err)
+			assert.Equal(t, "new tool", string(contents))
+		})
+	}
+}
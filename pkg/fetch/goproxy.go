@@ -0,0 +1,355 @@
+package fetch
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The module proxy Go modules are downloaded from when the GOPROXY env var doesn't name one, as for the go command
+const defaultGoProxy = "https://proxy.golang.org"
+
+// The version a module proxy resolves to the latest release of a module, or its latest pseudo-version if it has none
+const goModuleLatestVersion = "latest"
+
+// Matches a Go module path, e.g. golang.org/x/net or github.com/foo/bar/v2
+var goModulePathRegex = regexp.MustCompile(`^[A-Za-z0-9.-]+\.[A-Za-z]+(/[\w.~+-]+)*$`)
+
+// Matches the major version suffix of a Go module path, e.g. the v2 of github.com/foo/bar/v2
+var majorVersionSuffixRegex = regexp.MustCompile(`^v[0-9]+$`)
+
+// A Go module, whose source is downloaded as a zip from a module proxy rather than from its repo, so that it can be
+// vendored without git access. The module proxy protocol is described at https://go.dev/ref/mod#goproxy-protocol.
+type GoModule struct {
+	Path  string // The module path, e.g. golang.org/x/net
+	Proxy string // The base URL of the module proxy, e.g. https://proxy.golang.org
+}
+
+// Parse modulePath as the path of a Go module, which is downloaded from the first module proxy in the GOPROXY env var
+func ParseGoModule(modulePath string) (*GoModule, error) {
+	modulePath = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(modulePath, "https://"), "http://"), "/")
+	if !goModulePathRegex.MatchString(modulePath) {
+		return nil, fmt.Errorf("%s is not a Go module path, such as golang.org/x/net.", modulePath)
+	}
+	proxy, err := goProxyUrl(os.Getenv("GOPROXY"))
+	if err != nil {
+		return nil, err
+	}
+	return &GoModule{Path: modulePath, Proxy: proxy}, nil
+}
+
+// Return the first module proxy in goproxy, a value of the GOPROXY env var such as "https://proxy.golang.org,direct".
+// Returns defaultGoProxy if goproxy is empty.
+func goProxyUrl(goproxy string) (string, error) {
+	if goproxy == "" {
+		return defaultGoProxy, nil
+	}
+	for _, entry := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, "https://") || strings.HasPrefix(entry, "http://") {
+			return strings.TrimSuffix(entry, "/"), nil
+		}
+	}
+	return "", fmt.Errorf("GOPROXY=%s names no module proxy to download Go modules from. fetch can't download them directly from their repos.", goproxy)
+}
+
+// Escape a module path or version for a module proxy URL, which replaces each upper case letter with an exclamation mark
+// followed by the letter in lower case, so that paths differing only in case don't collide on case-insensitive disks
+func escapeGoModulePath(modulePath string) string {
+	var escaped strings.Builder
+	for _, r := range modulePath {
+		if 'A' <= r && r <= 'Z' {
+			escaped.WriteRune('!')
+			r += 'a' - 'A'
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// Return the URL of the module's proxy with any credentials in it redacted, for logs and errors
+func (module *GoModule) redactedProxy() string {
+	proxyUrl, err := url.Parse(module.Proxy)
+	if err != nil {
+		return module.Proxy
+	}
+	return proxyUrl.Redacted()
+}
+
+// Return the URL of the given endpoint of the module on its proxy, e.g. "v/list" or "latest"
+func (module *GoModule) url(endpoint string) string {
+	return fmt.Sprintf("%s/%s/@%s", module.Proxy, escapeGoModulePath(module.Path), endpoint)
+}
+
+// Return the URL of the zip of the source of the given version of the module
+func (module *GoModule) zipUrl(version string) string {
+	return module.url(fmt.Sprintf("v/%s.zip", escapeGoModulePath(version)))
+}
+
+// Send a GET request to the given URL on the module's proxy. The caller must close the response body.
+func (module *GoModule) get(ctx context.Context, url string) (*http.Response, *FetchError) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		// Proxies answer 404 or 410 for modules and versions they don't have, with the reason in the body
+		return nil, newError(failedToDownloadFile, fmt.Sprintf("Request to Go module proxy %s for module %s failed with HTTP Response %d: %s", module.redactedProxy(), module.Path, resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return resp, nil
+}
+
+// List the released versions of the module, leaving out pseudo-versions, which the proxy doesn't list
+func (module *GoModule) listVersions(ctx context.Context, looseSemver bool) ([]string, error) {
+	resp, fetchErr := module.get(ctx, module.url("v/list"))
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, line := range strings.Split(string(body), "\n") {
+		moduleVersion := strings.TrimSpace(line)
+		if _, err := parseTagVersion(moduleVersion, looseSemver); moduleVersion != "" && err == nil {
+			versions = append(versions, moduleVersion)
+		}
+	}
+	return versions, nil
+}
+
+// Return the version the proxy resolves "latest" to: the latest release, or if there is none, the latest pseudo-version
+func (module *GoModule) latest(ctx context.Context) (string, error) {
+	resp, fetchErr := module.get(ctx, module.url(goModuleLatestVersion))
+	if fetchErr != nil {
+		return "", fetchErr
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Version string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("Could not parse the latest version of Go module %s from proxy %s: %s", module.Path, module.redactedProxy(), err)
+	}
+	if info.Version == "" {
+		return "", fmt.Errorf("Go module proxy %s returned no latest version of module %s.", module.redactedProxy(), module.Path)
+	}
+	return info.Version, nil
+}
+
+// Download the zip of the source of the given version of the module, and return the path of a copy of it that starts
+// with an entry for the folder its files are in, as extractFiles expects of a repo archive, along with a function that
+// deletes both
+func (module *GoModule) downloadArchive(ctx context.Context, version string, withProgress bool) (string, func(), error) {
+	tempDir, err := ioutil.TempDir("", "fetch-goproxy")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	zipPath := filepath.Join(tempDir, "module.zip")
+	resp, fetchErr := module.get(ctx, module.zipUrl(version))
+	if fetchErr != nil {
+		cleanup()
+		return "", nil, fetchErr
+	}
+	if fetchErr := writeResonseToDisk(resp, zipPath, withProgress); fetchErr != nil {
+		cleanup()
+		return "", nil, fetchErr
+	}
+
+	archivePath := filepath.Join(tempDir, "repo.zip")
+	if err := addGoModuleFolderEntries(zipPath, archivePath, module.Path+"@"+version+"/"); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("Error occurred while reading the zip of Go module %s@%s: %s", module.Path, version, err)
+	}
+	return archivePath, cleanup, nil
+}
+
+// Copy the module zip at zipPath to archivePath, preceded by an entry for folder, which is the module@version/ prefix
+// that every file in a module zip starts with, and with an entry for each folder before the first file in it. Module
+// zips have no entries for folders, but a GitHub zipball does, and extracting a source path creates the folders from them.
+func addGoModuleFolderEntries(zipPath string, archivePath string, folder string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	zipWriter := zip.NewWriter(archive)
+	added := map[string]bool{}
+	addFolder := func(name string) error {
+		added[name] = true
+		_, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		return err
+	}
+	if err := addFolder(folder); err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		if !strings.HasPrefix(file.Name, folder) {
+			return fmt.Errorf("the file %s is not in %s", file.Name, folder)
+		}
+
+		// Add the folders the file is in that haven't been added yet, outermost first
+		relativePath := strings.TrimPrefix(file.Name, folder)
+		for i, char := range relativePath {
+			if char != '/' {
+				continue
+			}
+			if name := folder + relativePath[:i+1]; !added[name] {
+				if err := addFolder(name); err != nil {
+					return err
+				}
+			}
+		}
+		if err := zipWriter.Copy(file); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// Return an error if options ask for anything a Go module doesn't have. A module proxy only serves the source of
+// released versions, so only source paths can be downloaded from it, and only from versions, not commits or branches.
+func validateGoModuleOptions(options Options) error {
+	switch {
+	case options.releaseAssetRegex() != "" || options.AutoAsset:
+		return fmt.Errorf("Go modules have no releases, so release assets can't be downloaded from %s. Use --source-path instead.", options.RepoUrl)
+	case len(options.SourceFiles) > 0:
+		return fmt.Errorf("Single source files can't be downloaded from Go module %s. Use --source-path instead.", options.RepoUrl)
+	case options.CommitSha != "" || options.BranchName != "":
+		return fmt.Errorf("A Go module proxy serves versions, not commits or branches, so --commit and --branch can't be used with Go module %s.", options.RepoUrl)
+	case options.CheckImmutableTag || options.RequireImmutableTag:
+		return fmt.Errorf("Tag immutability can't be checked for Go module %s. The versions on a module proxy can't be changed once published.", options.RepoUrl)
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for Go module %s.", options.RepoUrl)
+	case options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("A Go module proxy serves versions, not commits, so the commit of Go module %s can't be resolved.", options.RepoUrl)
+	case options.RequireSignedRef:
+		return fmt.Errorf("A Go module proxy serves versions, not commits, so the signature of Go module %s can't be checked.", options.RepoUrl)
+	}
+	return nil
+}
+
+// Resolve the version of the Fetcher's Go module to download from the GitRef or TagConstraint option: a specific
+// version, a constraint matched against the versions the proxy lists, or, if neither is set or it's "latest", the
+// version the proxy resolves as latest
+func (fetcher *Fetcher) resolveGoModuleVersion(ctx context.Context) (string, error) {
+	constraint := fetcher.options.TagConstraint
+	if fetcher.options.GitRef != "" {
+		constraint = fetcher.options.GitRef
+	}
+	if constraint == "" || constraint == goModuleLatestVersion {
+		return fetcher.goModule.latest(ctx)
+	}
+
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return "", err
+	}
+	if resolvedTag.Tag == "" {
+		return "", fmt.Errorf("No version of Go module %s on proxy %s matches \"%s\".", fetcher.goModule.Path, fetcher.goModule.redactedProxy(), constraint)
+	}
+	return resolvedTag.Tag, nil
+}
+
+// Download the source paths in the Fetcher's options from the source of its Go module, as Fetch does for a GitHub repo
+func (fetcher *Fetcher) fetchFromGoProxy(ctx context.Context) (*Result, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+	module := fetcher.goModule
+
+	start := time.Now()
+	moduleVersion, err := fetcher.resolveGoModuleVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Tag: moduleVersion}
+	result.Timings.Resolve = time.Since(start)
+
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	downloadStart := time.Now()
+	logger.Infof("Downloading Go module %s@%s from %s ...\n", module.Path, moduleVersion, module.redactedProxy())
+	archivePath, cleanup, err := module.downloadArchive(ctx, moduleVersion, options.WithProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	extractStart := time.Now()
+	for _, sourcePath := range options.sourcePaths() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		logger.Infof("Extracting files from <module>%s to %s ...\n", sourcePath, options.LocalDownloadPath)
+		fileCount, err := extractFiles(archivePath, sourcePath, options.LocalDownloadPath, extractOptions)
+		logger.Infof("%d file(s) extracted\n", fileCount)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while extracting files downloaded from Go module proxy %s: %s", module.redactedProxy(), err)
+		}
+	}
+	result.Timings.Extract = time.Since(extractStart)
+	result.Timings.Download = time.Since(downloadStart)
+
+	logger.Infof("Download and file extraction complete.\n")
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
+
+// Return what fetchFromGoProxy would download, without downloading it
+func (fetcher *Fetcher) planGoModule(ctx context.Context) (*FetchPlan, error) {
+	moduleVersion, err := fetcher.resolveGoModuleVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &FetchPlan{Tag: moduleVersion, Ref: moduleVersion}
+	zipUrl := fetcher.goModule.zipUrl(moduleVersion)
+	if parsedUrl, err := url.Parse(zipUrl); err == nil {
+		zipUrl = parsedUrl.Redacted()
+	}
+	for _, sourcePath := range fetcher.options.sourcePaths() {
+		plan.SourcePaths = append(plan.SourcePaths, PlannedDownload{Name: sourcePath, Url: zipUrl, Destination: fetcher.options.LocalDownloadPath})
+	}
+	return plan, nil
+}
+
+// Return the name a Go module's source is known by, which is the last element of its path that isn't a major version
+// suffix, e.g. net for golang.org/x/net and bar for github.com/foo/bar/v2
+func (module *GoModule) name() string {
+	name := path.Base(module.Path)
+	if majorVersionSuffixRegex.MatchString(name) && path.Dir(module.Path) != "." {
+		return path.Base(path.Dir(module.Path))
+	}
+	return name
+}
@@ -0,0 +1,194 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoProxyUrl(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		goproxy     string
+		expectedUrl string
+	}{
+		{"", "https://proxy.golang.org"},
+		{"https://proxy.golang.org,direct", "https://proxy.golang.org"},
+		{"https://goproxy.example.com/|https://proxy.golang.org", "https://goproxy.example.com"},
+		{"direct", ""},
+		{"off", ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.goproxy, func(t *testing.T) {
+			t.Parallel()
+			proxyUrl, err := goProxyUrl(tc.goproxy)
+			if tc.expectedUrl == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedUrl, proxyUrl)
+		})
+	}
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestGoModule(t *testing.T) {
+	t.Setenv("GOPROXY", "")
+
+	testCases := []struct {
+		modulePath      string
+		expectedName    string
+		expectedListUrl string
+	}{
+		{"golang.org/x/net", "net", "https://proxy.golang.org/golang.org/x/net/@v/list"},
+		{"github.com/Foo/Bar/v2", "Bar", "https://proxy.golang.org/github.com/!foo/!bar/v2/@v/list"},
+		{"https://example.com/tool/", "tool", "https://proxy.golang.org/example.com/tool/@v/list"},
+		{"not a module", "", ""},
+		{"modules", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.modulePath, func(t *testing.T) {
+			module, err := ParseGoModule(tc.modulePath)
+			if tc.expectedName == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedName, module.name())
+			assert.Equal(t, tc.expectedListUrl, module.url("v/list"))
+		})
+	}
+}
+
+// Return a module zip of the given version of example.com/Tool with the given files, as a module proxy serves it
+func newGoModuleZip(t *testing.T, moduleVersion string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, contents := range files {
+		writer, err := zipWriter.Create(fmt.Sprintf("example.com/Tool@%s/%s", moduleVersion, name))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestFetchFromGoProxy(t *testing.T) {
+	zips := map[string][]byte{
+		"v1.1.0":                             newGoModuleZip(t, "v1.1.0", map[string]string{"go.mod": "module example.com/Tool\n", "cmd/main.go": "package main // v1.1.0\n"}),
+		"v1.2.0":                             newGoModuleZip(t, "v1.2.0", map[string]string{"go.mod": "module example.com/Tool\n", "cmd/main.go": "package main // v1.2.0\n"}),
+		"v2.0.0-20240101000000-abcdefabcdef": newGoModuleZip(t, "v2.0.0-20240101000000-abcdefabcdef", map[string]string{"go.mod": "module example.com/Tool\n", "cmd/main.go": "package main // pseudo\n"}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "user:secret", user+":"+password)
+		switch r.URL.Path {
+		case "/example.com/!tool/@v/list":
+			w.Write([]byte("v1.1.0\nv1.2.0\nv1.3.0-rc.1\n"))
+		case "/example.com/!tool/@latest":
+			w.Write([]byte(`{"Version": "v2.0.0-20240101000000-abcdefabcdef", "Time": "2024-01-01T00:00:00Z"}`))
+		default:
+			for moduleVersion, contents := range zips {
+				if r.URL.Path == "/example.com/!tool/@v/"+moduleVersion+".zip" {
+					w.Write(contents)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found: unknown revision"))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GOPROXY", "http://user:secret@"+server.Listener.Addr().String()+",direct")
+
+	testCases := []struct {
+		tag             string
+		expectedVersion string
+	}{
+		{"~>1.1", "v1.2.0"},
+		{"v1.1.0", "v1.1.0"},
+		{"latest", "v2.0.0-20240101000000-abcdefabcdef"},
+		{"", "v2.0.0-20240101000000-abcdefabcdef"},
+		{"v9.9.9", ""},
+		{"~>3.0", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tag, func(t *testing.T) {
+			// The download path doesn't exist yet, and module zips have no folder entries to create it from
+			destPath := filepath.Join(t.TempDir(), "tool")
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:           "example.com/Tool",
+				Source:            SourceGoProxy,
+				TagConstraint:     tc.tag,
+				SourcePaths:       []string{"/cmd"},
+				LocalDownloadPath: destPath,
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if tc.expectedVersion == "" {
+				require.Error(t, err)
+				assert.NotContains(t, err.Error(), "secret")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedVersion, result.Tag)
+
+			contents, err := ioutil.ReadFile(filepath.Join(destPath, "main.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(contents), "package main")
+			assert.NoFileExists(t, filepath.Join(destPath, "go.mod"))
+		})
+	}
+}
+
+func TestValidateGoModuleOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"source-path", Options{TagConstraint: "~>1.0", SourcePaths: []string{"/"}}, true},
+		{"release-asset", Options{TagConstraint: "~>1.0", ReleaseAsset: "tool"}, false},
+		{"source-file", Options{TagConstraint: "~>1.0", SourceFiles: []string{"/go.mod"}}, false},
+		{"branch", Options{BranchName: "main"}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.options.RepoUrl = "example.com/tool"
+			err := validateGoModuleOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The rules a ruleset must enforce on a tag to stop it being replaced. A tag that can be deleted can be recreated to
+// point at another commit, so blocking updates alone isn't enough.
+var tagProtectionRules = []string{"update", "deletion"}
+
+// A repository ruleset. Modeled directly after the api.github.com response (but only includes the fields we care
+// about). For more info, see: https://docs.github.com/en/rest/repos/rules#get-a-repository-ruleset
+type gitHubRuleset struct {
+	Id          int
+	Name        string
+	Target      string
+	Enforcement string
+	Conditions  struct {
+		RefName struct {
+			Include []string
+			Exclude []string
+		} `json:"ref_name"`
+	}
+	Rules []struct {
+		Type string
+	}
+}
+
+// Whether the release for a tag, or the tag itself, is protected from being replaced upstream
+type TagImmutability struct {
+	// The release for the tag is immutable, which locks both the tag and the release assets
+	ImmutableRelease bool
+
+	// The names of the active rulesets that block the tag from being updated or deleted
+	ProtectingRulesets []string
+}
+
+// Return true if what a fetch downloads from the tag can't be silently replaced upstream. Release assets can be
+// replaced even when the tag itself can't be, so if the fetch downloads release assets, only an immutable release
+// counts.
+func (immutability TagImmutability) IsImmutable(downloadsReleaseAssets bool) bool {
+	if immutability.ImmutableRelease {
+		return true
+	}
+	return !downloadsReleaseAssets && len(immutability.ProtectingRulesets) > 0
+}
+
+// Explain why the given tag isn't immutable, for a fetch that does or doesn't download release assets
+func (immutability TagImmutability) describeMutable(tag string, downloadsReleaseAssets bool) string {
+	if downloadsReleaseAssets && len(immutability.ProtectingRulesets) > 0 {
+		return fmt.Sprintf("Tag \"%s\" is protected by ruleset(s) %s, but its release isn't immutable, so its release assets could still be replaced upstream.", tag, strings.Join(immutability.ProtectingRulesets, ", "))
+	}
+	return fmt.Sprintf("Tag \"%s\" has no immutable release and isn't protected from updates and deletions by any active ruleset, so what it points to could be replaced upstream.", tag)
+}
+
+// Check whether the release for the given tag is immutable, and which of the repo's active rulesets, if any, block the
+// tag from being updated or deleted. Rulesets the token isn't allowed to read, and rulesets on GitHub Enterprise Server
+// releases that don't support them, are treated as absent.
+func CheckTagImmutability(ctx context.Context, repo GitHubRepo, tag string) (TagImmutability, *FetchError) {
+	immutability := TagImmutability{}
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, repo, tag)
+	if fetchErr != nil && fetchErr.errorCode != repoDoesNotExistOrAccessDenied {
+		return immutability, fetchErr
+	}
+	immutability.ImmutableRelease = fetchErr == nil && release.Immutable
+
+	rulesets, fetchErr := getTagRulesets(ctx, repo)
+	if fetchErr != nil {
+		return immutability, fetchErr
+	}
+	for _, ruleset := range rulesets {
+		if ruleset.protectsTag(tag) {
+			immutability.ProtectingRulesets = append(immutability.ProtectingRulesets, ruleset.Name)
+		}
+	}
+
+	return immutability, nil
+}
+
+// Get the full details of every active ruleset that targets tags in the given repo, including rulesets inherited from
+// its organization
+func getTagRulesets(ctx context.Context, repo GitHubRepo) ([]gitHubRuleset, *FetchError) {
+	var summaries []gitHubRuleset
+	if fetchErr := getGitHubJson(ctx, repo, createGitHubRepoUrlForPath(repo, "rulesets?includes_parents=true&targets=tag&per_page=100"), &summaries); fetchErr != nil {
+		if fetchErr.errorCode == accessForbidden || fetchErr.errorCode == repoDoesNotExistOrAccessDenied {
+			loggerFrom(ctx).Debugf("Could not read the rulesets of %s, so treating them as absent: %s\n", repo.Url, fetchErr)
+			return nil, nil
+		}
+		return nil, fetchErr
+	}
+
+	var rulesets []gitHubRuleset
+	for _, summary := range summaries {
+		// The list only has a summary of each ruleset, without its conditions or rules
+		if summary.Target != "tag" || summary.Enforcement != "active" {
+			continue
+		}
+		var ruleset gitHubRuleset
+		if fetchErr := getGitHubJson(ctx, repo, createGitHubRepoUrlForPath(repo, fmt.Sprintf("rulesets/%d?includes_parents=true", summary.Id)), &ruleset); fetchErr != nil {
+			return nil, fetchErr
+		}
+		rulesets = append(rulesets, ruleset)
+	}
+	return rulesets, nil
+}
+
+// Return true if this ruleset is active, applies to the given tag, and blocks it from being both updated and deleted
+func (ruleset gitHubRuleset) protectsTag(tag string) bool {
+	if ruleset.Target != "tag" || ruleset.Enforcement != "active" {
+		return false
+	}
+
+	refName := "refs/tags/" + tag
+	if !refNameMatchesAny(refName, ruleset.Conditions.RefName.Include) || refNameMatchesAny(refName, ruleset.Conditions.RefName.Exclude) {
+		return false
+	}
+
+	for _, required := range tagProtectionRules {
+		found := false
+		for _, rule := range ruleset.Rules {
+			if rule.Type == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Return true if the given full ref name (e.g. refs/tags/v1.0.0) matches any of the given ruleset ref name patterns,
+// in which * matches anything but a /, ** matches anything, and ~ALL matches every ref
+func refNameMatchesAny(refName string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == "~ALL" {
+			return true
+		}
+		if refNamePatternToRegexp(pattern).MatchString(refName) {
+			return true
+		}
+	}
+	return false
+}
+
+func refNamePatternToRegexp(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// Call the GitHub API at the given path and decode the JSON response into result
+func getGitHubJson(ctx context.Context, repo GitHubRepo, path string, result interface{}) *FetchError {
+	resp, fetchErr := callGitHubApi(ctx, repo, path, map[string]string{})
+	if fetchErr != nil {
+		return fetchErr
+	}
+	defer resp.Body.Close()
+
+	return wrapError(json.NewDecoder(resp.Body).Decode(result))
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefNameMatchesAny(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		refName  string
+		patterns []string
+		expected bool
+	}{
+		{"refs/tags/v1.0.0", []string{"~ALL"}, true},
+		{"refs/tags/v1.0.0", []string{"refs/tags/v*"}, true},
+		{"refs/tags/v1.0.0", []string{"refs/tags/release-*"}, false},
+		{"refs/tags/release/v1.0.0", []string{"refs/tags/*"}, false},
+		{"refs/tags/release/v1.0.0", []string{"refs/tags/**"}, true},
+		{"refs/tags/v1.0.0", []string{"refs/tags/v?.0.0"}, true},
+		{"refs/tags/v1.0.0", []string{"refs/tags/v1.0.0"}, true},
+		{"refs/tags/v1x0x0", []string{"refs/tags/v1.0.0"}, false},
+		{"refs/tags/v1.0.0", []string{"~DEFAULT_BRANCH"}, false},
+		{"refs/tags/v1.0.0", nil, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.refName, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, refNameMatchesAny(tc.refName, tc.patterns))
+		})
+	}
+}
+
+func TestRulesetProtectsTag(t *testing.T) {
+	t.Parallel()
+
+	// A ruleset as returned by the GitHub API, with fields fetch doesn't use left out
+	var ruleset gitHubRuleset
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": 42,
+		"name": "Protect release tags",
+		"target": "tag",
+		"enforcement": "active",
+		"conditions": {"ref_name": {"include": ["refs/tags/v*"], "exclude": ["refs/tags/v*-rc*"]}},
+		"rules": [{"type": "update"}, {"type": "deletion"}, {"type": "creation"}]
+	}`), &ruleset))
+
+	assert.True(t, ruleset.protectsTag("v1.0.0"))
+	assert.False(t, ruleset.protectsTag("v1.0.0-rc1"))
+	assert.False(t, ruleset.protectsTag("release-1.0.0"))
+
+	evaluateOnly := ruleset
+	evaluateOnly.Enforcement = "evaluate"
+	assert.False(t, evaluateOnly.protectsTag("v1.0.0"))
+
+	updateOnly := ruleset
+	updateOnly.Rules = ruleset.Rules[:1]
+	assert.False(t, updateOnly.protectsTag("v1.0.0"))
+}
+
+func TestTagImmutabilityIsImmutable(t *testing.T) {
+	t.Parallel()
+
+	immutableRelease := TagImmutability{ImmutableRelease: true}
+	assert.True(t, immutableRelease.IsImmutable(true))
+	assert.True(t, immutableRelease.IsImmutable(false))
+
+	// A ruleset protects the tag, but not the release assets, which can still be deleted and uploaded again
+	protectedTag := TagImmutability{ProtectingRulesets: []string{"Protect release tags"}}
+	assert.False(t, protectedTag.IsImmutable(true))
+	assert.True(t, protectedTag.IsImmutable(false))
+	assert.Contains(t, protectedTag.describeMutable("v1.0.0", true), "Protect release tags")
+
+	unprotected := TagImmutability{}
+	assert.False(t, unprotected.IsImmutable(true))
+	assert.False(t, unprotected.IsImmutable(false))
+}
@@ -0,0 +1,252 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Words that appear in platform suffixes of binary names but name neither an OS nor an architecture, such as the
+// vendor and ABI parts of Rust target triples (e.g. x86_64-unknown-linux-gnu)
+var platformSuffixWords = map[string]bool{"unknown": true, "pc": true, "gnu": true, "musl": true, "msvc": true, "static": true}
+
+var versionWordRegex = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*$`)
+
+// Extensions of files that archives often mark as executable, but that are never the binary being looked for
+var nonBinaryExtensions = map[string]bool{
+	".sh": true, ".bash": true, ".zsh": true, ".fish": true, ".ps1": true, ".bat": true, ".cmd": true, ".py": true,
+	".rb": true, ".pl": true, ".md": true, ".txt": true, ".json": true, ".yaml": true, ".yml": true, ".toml": true,
+}
+
+// Install the binary in the given release asset into installDir: if the asset is an archive, it's unpacked into a
+// staging directory and the binary is picked out of it. The binary is made executable and copied into installDir under
+// binaryName, or under its own name with any platform and version suffixes stripped if binaryName is empty. The copy
+// lands atomically, so installDir never holds a partially written binary. Returns the path of the installed binary.
+func installReleaseAsset(logger *logrus.Entry, assetPath string, installDir string, binaryName string, repoName string, options ExtractOptions) (string, error) {
+	binaryPath := assetPath
+
+	if getArchiveExtension(assetPath) != "" {
+		stagingDir, err := ioutil.TempDir("", "fetch-install-")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(stagingDir)
+
+		if _, err := unpackArchive(assetPath, stagingDir, options); err != nil {
+			return "", fmt.Errorf("Error occurred while unpacking release asset %s: %s", assetPath, err)
+		}
+		binaryPath, err = findBinaryInDir(stagingDir, binaryName, repoName)
+		if err != nil {
+			return "", fmt.Errorf("Could not find the binary to install in release asset %s: %s", filepath.Base(assetPath), err)
+		}
+	}
+
+	if binaryName == "" {
+		binaryName = stripPlatformSuffix(filepath.Base(binaryPath))
+	}
+	installPath := filepath.Join(installDir, binaryName)
+
+	logger.Infof("Installing %s to %s ...\n", filepath.Base(binaryPath), installPath)
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		return "", err
+	}
+	if err := copyExecutableAtomically(binaryPath, installPath); err != nil {
+		return "", fmt.Errorf("Error occurred while installing %s to %s: %s", filepath.Base(binaryPath), installPath, err)
+	}
+	return installPath, nil
+}
+
+// Unpack the release asset at the given path into a staging directory, and place just the binary found in it in
+// destPath, exactly as installReleaseAsset does. Unless keepArchive is true, the archive is deleted afterwards.
+func unpackReleaseAssetBinary(logger *logrus.Entry, assetPath string, destPath string, binaryName string, repoName string, keepArchive bool, options ExtractOptions) error {
+	if getArchiveExtension(assetPath) == "" {
+		logger.Infof("Not unpacking %s as it is not a recognized archive format\n", assetPath)
+		return nil
+	}
+
+	if _, err := installReleaseAsset(logger, assetPath, destPath, binaryName, repoName, options); err != nil {
+		return err
+	}
+
+	if !keepArchive {
+		if err := os.Remove(assetPath); err != nil {
+			return fmt.Errorf("Failed to delete release asset archive %s after unpacking: %s", assetPath, err)
+		}
+	}
+	return nil
+}
+
+// Return the path of the binary among the files in dir: the file named binaryName (ignoring platform suffixes), if
+// it's set, or otherwise the only executable file. If there are several, the executable named after the repo is
+// picked, or failing that, the largest executable that isn't a script or document, since the other executables in
+// release archives are usually small helper scripts.
+func findBinaryInDir(dir string, binaryName string, repoName string) (string, error) {
+	var files []string
+	var executables []string
+	sizes := map[string]int64{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		files = append(files, path)
+		sizes[path] = info.Size()
+		if info.Mode()&0111 != 0 || strings.HasSuffix(strings.ToLower(path), ".exe") {
+			executables = append(executables, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if binaryName != "" {
+		if path, ok := findFileNamed(files, binaryName); ok {
+			return path, nil
+		}
+		return "", fmt.Errorf("it has no file named %s. Files:\n\t%s", binaryName, strings.Join(relativePaths(dir, files), "\n\t"))
+	}
+
+	if len(executables) == 1 {
+		return executables[0], nil
+	}
+	if len(executables) == 0 && len(files) == 1 {
+		// Archives such as a single gzipped binary don't record permissions
+		return files[0], nil
+	}
+	if path, ok := findFileNamed(executables, repoName); ok {
+		return path, nil
+	}
+	if len(executables) == 0 {
+		return "", fmt.Errorf("it has no executable files. Use --binary-name to pick one of these files:\n\t%s", strings.Join(relativePaths(dir, files), "\n\t"))
+	}
+	if path, ok := findLargestBinary(executables, sizes); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("it has %d executable files. Use --binary-name to pick one of them:\n\t%s", len(executables), strings.Join(relativePaths(dir, executables), "\n\t"))
+}
+
+// Return the first of the given paths whose base name is name, once platform suffixes and any .exe extension are
+// stripped from both
+func findFileNamed(paths []string, name string) (string, bool) {
+	want := strings.TrimSuffix(stripPlatformSuffix(name), ".exe")
+	for _, path := range paths {
+		if strings.TrimSuffix(stripPlatformSuffix(filepath.Base(path)), ".exe") == want {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Return the largest of the given executables, ignoring scripts and documents, or false if there's no single largest
+func findLargestBinary(executables []string, sizes map[string]int64) (string, bool) {
+	largest := ""
+	tied := false
+	for _, path := range executables {
+		if nonBinaryExtensions[strings.ToLower(filepath.Ext(path))] {
+			continue
+		}
+		if largest == "" || sizes[path] > sizes[largest] {
+			largest = path
+			tied = false
+		} else if sizes[path] == sizes[largest] {
+			tied = true
+		}
+	}
+	return largest, largest != "" && !tied
+}
+
+// Return the given paths relative to dir, sorted
+func relativePaths(dir string, paths []string) []string {
+	var relPaths []string
+	for _, path := range paths {
+		if relPath, err := filepath.Rel(dir, path); err == nil {
+			path = relPath
+		}
+		relPaths = append(relPaths, filepath.ToSlash(path))
+	}
+	sort.Strings(relPaths)
+	return relPaths
+}
+
+// Strip the platform and version suffixes from the given binary name, e.g. tool_1.2.3_linux_amd64 becomes tool and
+// tool-x86_64-pc-windows-msvc.exe becomes tool.exe. Names that are nothing but a platform are returned as-is.
+func stripPlatformSuffix(name string) string {
+	extension := ""
+	if strings.HasSuffix(strings.ToLower(name), ".exe") {
+		extension = name[len(name)-len(".exe"):]
+		name = name[:len(name)-len(".exe")]
+	}
+
+	normalized := strings.NewReplacer("x86_64", "amd64", "x86-64", "amd64").Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(normalized, func(r rune) bool { return r == '_' || r == '-' })
+
+	// Find the first word of the suffix, then walk back over any version that precedes it
+	suffixStart := len(words)
+	for i, word := range words {
+		if isPlatformWord(word) {
+			suffixStart = i
+			break
+		}
+	}
+	for suffixStart > 0 && suffixStart < len(words) && versionWordRegex.MatchString(words[suffixStart-1]) {
+		suffixStart--
+	}
+	if suffixStart == 0 || suffixStart == len(words) {
+		return name + extension
+	}
+
+	// Cut the original name, rather than the normalized one, to keep its case and separators
+	cut := 0
+	for i := 0; i < suffixStart; i++ {
+		cut = strings.Index(normalized[cut:], words[i]) + cut + len(words[i])
+	}
+	return name[:cut] + extension
+}
+
+// Return true if the given lower case word names an OS or architecture, or is otherwise part of a platform suffix
+func isPlatformWord(word string) bool {
+	if platformSuffixWords[word] {
+		return true
+	}
+	words := map[string]bool{word: true}
+	return len(namedPlatforms(words, assetOSNames)) > 0 || len(namedPlatforms(words, assetArchNames)) > 0
+}
+
+// Copy the file at srcPath to destPath with its executable bits set, replacing any file already at destPath in a
+// single rename. The copy is written to a temporary file next to destPath first, so the rename never crosses file
+// systems.
+func copyExecutableAtomically(srcPath string, destPath string) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(destPath), "."+filepath.Base(destPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), destPath)
+}
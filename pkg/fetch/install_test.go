@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripPlatformSuffix(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"terraform", "terraform"},
+		{"tool_linux_amd64", "tool"},
+		{"tool_1.2.3_darwin_arm64", "tool"},
+		{"tool-v1.2.3-x86_64-unknown-linux-musl", "tool"},
+		{"tool-x86_64-pc-windows-msvc.exe", "tool.exe"},
+		{"My-Tool_Linux_x86_64", "My-Tool"},
+		{"kube-score_1.16.1", "kube-score_1.16.1"},
+		{"linux_amd64", "linux_amd64"},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, stripPlatformSuffix(tc.name), tc.name)
+	}
+}
+
+func TestFindBinaryInDirLargestExecutable(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "install.sh"), []byte("a very long install script"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "helper"), []byte("helper"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "kubectl-plugin"), []byte("the binary"), 0755))
+
+	// Nothing is named after the repo, so the largest executable that isn't a script wins
+	binaryPath, err := findBinaryInDir(dir, "", "tool")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "kubectl-plugin"), binaryPath)
+
+	// Executables of the same size can't be told apart
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other"), []byte("the binary"), 0755))
+	_, err = findBinaryInDir(dir, "", "tool")
+	assert.Error(t, err)
+}
+
+func TestUnpackReleaseAssetBinary(t *testing.T) {
+	t.Parallel()
+
+	destDir := t.TempDir()
+	assetPath := filepath.Join(destDir, "tool_1.2.3_linux_amd64.tar.gz")
+	writeTestTar(t, assetPath, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, map[string]string{
+		"tool_1.2.3_linux_amd64/README.md": "docs",
+		"tool_1.2.3_linux_amd64/tool":      "binary",
+	})
+
+	require.NoError(t, unpackReleaseAssetBinary(GetProjectLogger(), assetPath, destDir, "", "tool", false, ExtractOptions{}))
+
+	// Only the binary is left, with the archive deleted
+	entries, err := os.ReadDir(destDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "tool", entries[0].Name())
+}
+
+func TestInstallReleaseAssetBinary(t *testing.T) {
+	t.Parallel()
+
+	assetPath := filepath.Join(t.TempDir(), "tool_linux_amd64")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("binary"), 0644))
+	installDir := filepath.Join(t.TempDir(), "bin")
+
+	installedPath, err := installReleaseAsset(GetProjectLogger(), assetPath, installDir, "", "tool", ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(installDir, "tool"), installedPath)
+
+	info, err := os.Stat(installedPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Nothing but the binary should be left in the install dir
+	entries, err := os.ReadDir(installDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestInstallReleaseAssetArchive(t *testing.T) {
+	t.Parallel()
+
+	// Every file in the tarball is executable, so the binary has to be picked by name
+	assetPath := filepath.Join(t.TempDir(), "tool_1.2.3_linux_amd64.tar.gz")
+	writeTestTar(t, assetPath, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, map[string]string{
+		"tool_1.2.3_linux_amd64/README.md": "tool_1.2.3_linux_amd64/README.md",
+		"tool_1.2.3_linux_amd64/tool":      "tool_1.2.3_linux_amd64/tool",
+		"tool_1.2.3_linux_amd64/helper":    "tool_1.2.3_linux_amd64/helper",
+	})
+
+	cases := []struct {
+		name          string
+		binaryName    string
+		expectedName  string
+		expectedError bool
+	}{
+		{"repo-name", "", "tool", false},
+		{"binary-name", "helper", "helper", false},
+		{"missing-binary-name", "other", "", true},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			installDir := t.TempDir()
+			installedPath, err := installReleaseAsset(GetProjectLogger(), assetPath, installDir, tc.binaryName, "tool", ExtractOptions{})
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(installDir, tc.expectedName), installedPath)
+
+			contents, err := ioutil.ReadFile(installedPath)
+			require.NoError(t, err)
+			assert.Equal(t, "tool_1.2.3_linux_amd64/"+tc.expectedName, string(contents))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/gruntwork-io/go-commons/logging"
+	"github.com/sirupsen/logrus"
+)
+
+const DEFAULT_LOG_LEVEL = logrus.InfoLevel
+
+// The formatter of loggers returned by GetProjectLogger. If it's nil, the one set with logging.SetGlobalLogFormatter
+// is used.
+var logFormatter logrus.Formatter
+var logFormatterLock = sync.Mutex{}
+
+// Where loggers returned by GetProjectLogger, and progress, are written to. If it's nil, they're written to stderr.
+var logOutput io.Writer
+
+// GetProjectLogger returns a logging instance for this project
+func GetProjectLogger() *logrus.Entry {
+	logger := logging.GetLogger("fetch", "")
+
+	logFormatterLock.Lock()
+	defer logFormatterLock.Unlock()
+	if logFormatter != nil {
+		logger.Logger.Formatter = logFormatter
+	}
+	if logOutput != nil {
+		logger.Logger.Out = logOutput
+	}
+	return logger
+}
+
+// GetProjectLoggerWithWriter creates a logger around the given output stream
+func GetProjectLoggerWithWriter(writer io.Writer) *logrus.Entry {
+	logger := GetProjectLogger()
+	logger.Logger.Out = writer
+	return logger
+}
+
+// SetLogOutput sets where loggers returned by GetProjectLogger from now on, and the progress of downloads and checksum
+// verification, are written to. Set it to nil to go back to stderr.
+func SetLogOutput(writer io.Writer) {
+	logFormatterLock.Lock()
+	defer logFormatterLock.Unlock()
+	logOutput = writer
+}
+
+// SetLogFormatter sets the formatter of loggers returned by GetProjectLogger from now on, overriding the one set with
+// logging.SetGlobalLogFormatter. Set it to nil to go back to that one.
+func SetLogFormatter(formatter logrus.Formatter) {
+	logFormatterLock.Lock()
+	defer logFormatterLock.Unlock()
+	logFormatter = formatter
+}
+
+// Return true if the given logger writes structured entries, such as JSON, which a line of progress can't be rewritten
+// in place in, and whose fields log processors can read on their own
+func isStructuredLog(logger *logrus.Entry) bool {
+	_, structured := logger.Logger.Formatter.(*logrus.JSONFormatter)
+	return structured
+}
+
+type loggerKey struct{}
+
+// Return a context whose calls log with the given logger, such as the one in a Fetcher's options. A nil logger leaves
+// ctx as it is.
+func withLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Return the logger of the given context, or the project logger if it has none
+func loggerFrom(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	return GetProjectLogger()
+}
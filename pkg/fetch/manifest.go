@@ -0,0 +1,364 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// A list of fetches to run in one go, as read from a manifest file by LoadManifest. This is what the
+// "fetch manifest" command runs.
+type Manifest struct {
+	Hosts   map[string]ManifestHost `json:"hosts,omitempty"` // Keyed by host, e.g. github.com or ghe.mycompany.com
+	Entries []ManifestEntry         `json:"entries"`
+}
+
+// Where to read the GitHub token for the entries that download from a host from, so that a manifest that downloads from
+// github.com and a GitHub Enterprise Server instance in one run can authenticate to each with the right token. Entries
+// whose host isn't in the manifest's hosts use the token fetch was run with.
+type ManifestHost struct {
+	TokenEnv  string `json:"tokenEnv,omitempty"`  // The env var holding the token
+	TokenFile string `json:"tokenFile,omitempty"` // The file holding the token, as --github-oauth-token-file reads it
+}
+
+// A single fetch in a Manifest. Each field corresponds to the fetch CLI flag with the same meaning.
+type ManifestEntry struct {
+	Repo                  string   `json:"repo,omitempty"`
+	Url                   string   `json:"url,omitempty"` // Instead of Repo, a plain HTTP(S) URL to download a file from
+	Ref                   string   `json:"ref,omitempty"`
+	Tag                   string   `json:"tag,omitempty"`
+	Branch                string   `json:"branch,omitempty"`
+	Commit                string   `json:"commit,omitempty"`
+	SourcePaths           []string `json:"sourcePaths,omitempty"`
+	SourceFiles           []string `json:"sourceFiles,omitempty"`
+	StripComponents       int      `json:"stripComponents,omitempty"`
+	ReleaseAsset          string   `json:"releaseAsset,omitempty"`
+	ReleaseAssetChecksums []string `json:"releaseAssetChecksums,omitempty"` // Each must have an algorithm prefix, e.g. sha256:abcd...
+	Unpack                bool     `json:"unpack,omitempty"`
+	UnpackInclude         []string `json:"unpackInclude,omitempty"`
+	Timeout               string   `json:"timeout,omitempty"` // A duration, e.g. 5m, overriding --entry-timeout for this entry
+	MaxSize               string   `json:"maxSize,omitempty"` // A size, e.g. 500MiB, overriding --entry-max-size for this entry
+	Destination           string   `json:"destination"`
+}
+
+// Read and validate the manifest at the given path, which is JSON, or YAML if the path ends in .yaml or .yml
+func LoadManifest(path string) (*Manifest, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isYamlManifest(path) {
+		if contents, err = yamlManifestToJson(contents); err != nil {
+			return nil, err
+		}
+	}
+	return parseManifest(contents)
+}
+
+func parseManifest(contents []byte) (*Manifest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	var manifest Manifest
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("Could not parse manifest: %s", err)
+	}
+
+	if len(manifest.Entries) == 0 {
+		return nil, fmt.Errorf("The manifest has no entries.")
+	}
+	// Hosts are case insensitive, so they're matched against the hosts of entries in lowercase
+	hosts := make(map[string]ManifestHost, len(manifest.Hosts))
+	for host, hostConfig := range manifest.Hosts {
+		if err := hostConfig.validate(host); err != nil {
+			return nil, fmt.Errorf("Manifest host %s is invalid: %s", host, err)
+		}
+		hosts[strings.ToLower(host)] = hostConfig
+	}
+	manifest.Hosts = hosts
+	for i, entry := range manifest.Entries {
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("Manifest entry %d is invalid: %s", i+1, err)
+		}
+	}
+	return &manifest, nil
+}
+
+func (entry ManifestEntry) validate() error {
+	if (entry.Repo == "") == (entry.Url == "") {
+		return fmt.Errorf("exactly one of \"repo\" or \"url\" is required")
+	}
+	if entry.Destination == "" {
+		return fmt.Errorf("\"destination\" is required")
+	}
+	if _, err := entry.timeout(); err != nil {
+		return err
+	}
+	if _, err := entry.maxSize(); err != nil {
+		return err
+	}
+	if entry.Url != "" {
+		return entry.validateUrl()
+	}
+	if entry.Ref == "" && entry.Tag == "" && entry.Branch == "" && entry.Commit == "" {
+		return fmt.Errorf("one of \"ref\", \"tag\", \"branch\", or \"commit\" is required")
+	}
+	if entry.ReleaseAsset != "" && entry.Tag == "" {
+		return fmt.Errorf("\"releaseAsset\" can only be used with \"tag\"")
+	}
+	if entry.StripComponents < 0 {
+		return fmt.Errorf("\"stripComponents\" must not be negative")
+	}
+	return entry.validateChecksumsAndUnpack()
+}
+
+// Validate an entry that downloads a file from a URL, which can be unpacked and verified by checksum like a release
+// asset, but has nothing to select from a repo
+func (entry ManifestEntry) validateUrl() error {
+	if _, _, err := parseArtifactUrl(entry.Url); err != nil {
+		return err
+	}
+	if entry.Ref != "" || entry.Tag != "" || entry.Branch != "" || entry.Commit != "" || len(entry.SourcePaths) > 0 || len(entry.SourceFiles) > 0 || entry.ReleaseAsset != "" || entry.StripComponents != 0 {
+		return fmt.Errorf("\"url\" can only be used with \"releaseAssetChecksums\", \"unpack\", and \"unpackInclude\"")
+	}
+	return entry.validateChecksumsAndUnpack()
+}
+
+// Return the entry's timeout, or 0 if it doesn't override the one fetch was run with
+func (entry ManifestEntry) timeout() (time.Duration, error) {
+	if entry.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(entry.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("\"timeout\" must be a positive duration, e.g. 90s or 5m")
+	}
+	return timeout, nil
+}
+
+// Return the entry's size budget in bytes, or 0 if it doesn't override the one fetch was run with
+func (entry ManifestEntry) maxSize() (uint64, error) {
+	if entry.MaxSize == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(entry.MaxSize)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("\"maxSize\" must be a positive number of bytes, optionally with a unit, e.g. 64KB or 1.5GiB")
+	}
+	return size, nil
+}
+
+func (entry ManifestEntry) validateChecksumsAndUnpack() error {
+	if len(entry.UnpackInclude) > 0 && !entry.Unpack {
+		return fmt.Errorf("\"unpackInclude\" can only be used with \"unpack\"")
+	}
+	if err := ValidateUnpackInclude(entry.UnpackInclude); err != nil {
+		return err
+	}
+	for _, checksum := range entry.ReleaseAssetChecksums {
+		algorithm, _ := ParseChecksum(checksum, "")
+		if algorithm == "" {
+			return fmt.Errorf("checksum %s has no algorithm prefix (e.g. sha256:%s)", checksum, checksum)
+		}
+		if _, err := GetHasher(algorithm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hostConfig ManifestHost) validate(host string) error {
+	if host == "" || strings.ContainsAny(host, "/:") {
+		return fmt.Errorf("it must be a host name, e.g. github.com, without a scheme or path")
+	}
+	if (hostConfig.TokenEnv == "") == (hostConfig.TokenFile == "") {
+		return fmt.Errorf("exactly one of \"tokenEnv\" or \"tokenFile\" is required")
+	}
+	if hostConfig.TokenFile == "-" {
+		return fmt.Errorf("\"tokenFile\" can't be stdin")
+	}
+	return nil
+}
+
+// Return the GitHub token to use for the given host, read from the env var or file in hostConfig
+func (hostConfig ManifestHost) token(host string) (string, error) {
+	if hostConfig.TokenFile != "" {
+		return ReadTokenFile(hostConfig.TokenFile, nil)
+	}
+	token := strings.TrimSpace(os.Getenv(hostConfig.TokenEnv))
+	if token == "" {
+		return "", fmt.Errorf("The env var %s, which the manifest reads the token for %s from, is not set.", hostConfig.TokenEnv, host)
+	}
+	return token, nil
+}
+
+// Return the GitHub token for each host in the manifest's hosts that its entries download from, so that a token that's
+// missing fails the run before anything is downloaded, while hosts no entry uses don't need a token at all
+func (manifest *Manifest) hostTokens() (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, entry := range manifest.Entries {
+		host := RepoUrlHost(entry.Repo)
+		hostConfig, ok := manifest.Hosts[host]
+		if _, resolved := tokens[host]; !ok || resolved {
+			continue
+		}
+		token, err := hostConfig.token(host)
+		if err != nil {
+			return nil, err
+		}
+		tokens[host] = token
+	}
+	return tokens, nil
+}
+
+// Return the host of the given repo URL, e.g. github.com for https://www.github.com/foo/bar, or an empty string if it
+// has none
+func RepoUrlHost(repoUrl string) string {
+	parsed, err := url.Parse(repoUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+}
+
+// Return the repo or URL this entry downloads from, to name it by in logs and errors
+func (entry ManifestEntry) source() string {
+	if entry.Url != "" {
+		return entry.Url
+	}
+	return entry.Repo
+}
+
+// Return the Options for the fetch this entry describes. The settings that entries don't have, such as the GitHub
+// token, come from base.
+func (entry ManifestEntry) options(base Options) Options {
+	options := base
+	options.RepoUrl = entry.Repo
+	options.Url = entry.Url
+	options.GitRef = entry.Ref
+	options.TagConstraint = entry.Tag
+	options.BranchName = entry.Branch
+	options.CommitSha = entry.Commit
+	options.SourcePaths = entry.SourcePaths
+	options.SourceFiles = entry.SourceFiles
+	options.StripComponents = entry.StripComponents
+	options.ReleaseAsset = entry.ReleaseAsset
+	options.Unpack = entry.Unpack
+	options.UnpackInclude = entry.UnpackInclude
+	options.LocalDownloadPath = entry.Destination
+	// Both were checked when the manifest was parsed
+	if timeout, _ := entry.timeout(); timeout > 0 {
+		options.Timeout = timeout
+	}
+	if maxSize, _ := entry.maxSize(); maxSize > 0 {
+		options.MaxDownloadSize = maxSize
+	}
+
+	options.ReleaseAssetChecksums = nil
+	if len(entry.ReleaseAssetChecksums) > 0 {
+		options.ReleaseAssetChecksums = map[string]bool{}
+		for _, checksum := range entry.ReleaseAssetChecksums {
+			options.ReleaseAssetChecksums[checksum] = true
+		}
+	}
+	return options
+}
+
+// Run each fetch in the manifest in turn, with the settings that entries don't have taken from base. A release asset
+// needed by several entries is only downloaded once, and then placed in the destination of each of the others
+// according to base.LinkMode. Stops at the first entry that fails, and returns the results of the entries that
+// succeeded along with the error. With base.ManifestJournal set, the entries that succeeded in an earlier run that
+// failed are skipped, and their results are read from the journal instead.
+func RunManifest(ctx context.Context, manifest *Manifest, base Options, writer io.Writer) ([]*Result, error) {
+	logger := base.Logger
+	if logger == nil {
+		logger = GetProjectLogger()
+	}
+
+	var store *assetStore
+	if base.ArchiveCacheDir != "" {
+		store = openAssetStore(base.ArchiveCacheDir, base.LinkMode)
+	} else if base.LinkMode == LinkModeSymlink {
+		return nil, fmt.Errorf("The %s link mode needs a cache directory that outlives the run, so ArchiveCacheDir must be set.", LinkModeSymlink)
+	} else {
+		var err error
+		if store, err = newAssetStore(base.LinkMode); err != nil {
+			return nil, err
+		}
+	}
+	defer store.close()
+
+	hostTokens, err := manifest.hostTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *manifestJournal
+	if base.ManifestJournal != "" {
+		if journal, err = openManifestJournal(base.ManifestJournal); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*Result
+	for i, entry := range manifest.Entries {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		if journal != nil {
+			result, err := journal.completedResult(entry)
+			if err != nil {
+				return results, err
+			}
+			if result != nil {
+				logger.Infof("Skipping manifest entry %d of %d: %s, which already succeeded according to the journal %s\n", i+1, len(manifest.Entries), entry.source(), journal.path)
+				results = append(results, result)
+				continue
+			}
+		}
+
+		logger.Infof("Fetching manifest entry %d of %d: %s\n", i+1, len(manifest.Entries), entry.source())
+		options := entry.options(base)
+		if token, ok := hostTokens[RepoUrlHost(entry.Repo)]; ok {
+			options.GithubToken = token
+		}
+		result, err := runManifestEntry(ctx, logger, options, store, writer)
+		if err != nil {
+			return results, fmt.Errorf("Error occurred while fetching manifest entry %d (%s): %s", i+1, entry.source(), err)
+		}
+		results = append(results, result)
+		if journal != nil {
+			if err := journal.record(entry, result); err != nil {
+				return results, fmt.Errorf("Error writing the manifest journal %s: %s", journal.path, err)
+			}
+		}
+	}
+
+	if journal != nil {
+		if err := journal.remove(); err != nil {
+			return results, fmt.Errorf("Error deleting the manifest journal %s: %s", journal.path, err)
+		}
+	}
+	return results, nil
+}
+
+func runManifestEntry(ctx context.Context, logger *logrus.Entry, options Options, store *assetStore, writer io.Writer) (*Result, error) {
+	options.Logger = logger
+	fetcher, err := NewFetcher(options)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.assetStore = store
+	return fetcher.Fetch(ctx, writer)
+}
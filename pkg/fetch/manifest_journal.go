@@ -0,0 +1,123 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// A single line of a manifest journal, written once an entry has been fetched and verified
+type manifestJournalRecord struct {
+	Entry  string  `json:"entry"` // The fingerprint of the entry. See ManifestEntry.fingerprint.
+	Result *Result `json:"result"`
+}
+
+// A record of the entries of a manifest that were fetched and verified, written with the ManifestJournal option, so
+// that re-running a manifest that failed part way through skips the entries that already succeeded and resumes from
+// the one that failed. The journal is appended to as each entry succeeds, so it survives the run being killed, and is
+// deleted once every entry has succeeded, so the next run fetches everything afresh.
+type manifestJournal struct {
+	path    string
+	results map[string]*Result // Keyed by the fingerprint of the entry
+}
+
+// Return the fingerprint of the entry, which is the SHA256 checksum of its JSON. Changing anything about an entry, such
+// as its tag or destination, changes its fingerprint, so it's fetched again rather than skipped.
+func (entry ManifestEntry) fingerprint() (string, error) {
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Read the manifest journal at the given path, if it exists. A journal that doesn't exist yet has no entries.
+func openManifestJournal(path string) (*manifestJournal, error) {
+	journal := &manifestJournal{path: path, results: map[string]*Result{}}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(contents, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record manifestJournalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// The last line may have been cut short by the run being killed as it was written, in which case that
+			// entry is just fetched again
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("The manifest journal %s is corrupt at line %d: %s. Delete it to fetch every entry again.", path, i+1, err)
+		}
+		journal.results[record.Entry] = record.Result
+	}
+	return journal, nil
+}
+
+// Return the result the given entry was fetched with in an earlier run, or nil if it has to be fetched. An entry whose
+// destination no longer exists is fetched again, since whatever it downloaded is gone.
+func (journal *manifestJournal) completedResult(entry ManifestEntry) (*Result, error) {
+	fingerprint, err := entry.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	result, ok := journal.results[fingerprint]
+	if !ok {
+		return nil, nil
+	}
+	if _, err := os.Stat(entry.Destination); err != nil {
+		return nil, nil
+	}
+	if result == nil {
+		result = &Result{}
+	}
+	return result, nil
+}
+
+// Record that the given entry was fetched with the given result
+func (journal *manifestJournal) record(entry ManifestEntry, result *Result) error {
+	fingerprint, err := entry.fingerprint()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(manifestJournalRecord{Entry: fingerprint, Result: result})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(journal.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// The record is what lets the next run skip the entry, so make sure it's on disk before moving on to the next one
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	journal.results[fingerprint] = result
+	return nil
+}
+
+// Delete the journal, once every entry in the manifest has succeeded
+func (journal *manifestJournal) remove() error {
+	if err := os.Remove(journal.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunManifestWithJournal(t *testing.T) {
+	t.Parallel()
+
+	var toolRequests, failingRequests int32
+	failing := int32(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tool":
+			atomic.AddInt32(&toolRequests, 1)
+			w.Write([]byte("tool"))
+		case "/flaky":
+			atomic.AddInt32(&failingRequests, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte("flaky"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	destPath := t.TempDir()
+	manifest := &Manifest{Entries: []ManifestEntry{
+		{Url: server.URL + "/tool", Destination: filepath.Join(destPath, "tool")},
+		{Url: server.URL + "/flaky", Destination: filepath.Join(destPath, "flaky")},
+	}}
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	base := Options{LinkMode: LinkModeCopy, ManifestJournal: journalPath}
+
+	results, err := RunManifest(context.Background(), manifest, base, io.Discard)
+	require.Error(t, err)
+	assert.Len(t, results, 1)
+	assert.FileExists(t, journalPath)
+
+	// The entry that succeeded is skipped, with the result it had in the first run
+	atomic.StoreInt32(&failing, 0)
+	results, err = RunManifest(context.Background(), manifest, base, io.Discard)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, []string{filepath.Join(destPath, "tool", "tool")}, results[0].AssetPaths)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&toolRequests))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&failingRequests))
+
+	// Once every entry succeeded, the journal is gone, so the next run fetches everything again
+	assert.NoFileExists(t, journalPath)
+	_, err = RunManifest(context.Background(), manifest, base, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&toolRequests))
+}
+
+func TestManifestJournalSkipsOnlyUnchangedEntries(t *testing.T) {
+	t.Parallel()
+
+	destPath := t.TempDir()
+	entry := ManifestEntry{Repo: "https://github.com/foo/bar", Tag: "v1.0.0", Destination: destPath}
+	journal, err := openManifestJournal(filepath.Join(t.TempDir(), "journal"))
+	require.NoError(t, err)
+	require.NoError(t, journal.record(entry, &Result{Tag: "v1.0.0"}))
+
+	reopened, err := openManifestJournal(journal.path)
+	require.NoError(t, err)
+	result, err := reopened.completedResult(entry)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "v1.0.0", result.Tag)
+
+	changed := entry
+	changed.Tag = "v1.1.0"
+	result, err = reopened.completedResult(changed)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	require.NoError(t, os.Remove(destPath))
+	result, err = reopened.completedResult(entry)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestOpenManifestJournal(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		contents      string
+		expectedCount int
+		expectError   bool
+	}{
+		{"empty", "", 0, false},
+		{"complete", "{\"entry\": \"a\", \"result\": {}}\n{\"entry\": \"b\", \"result\": {}}\n", 2, false},
+		{"cut-short", "{\"entry\": \"a\", \"result\": {}}\n{\"entry\": \"b\", \"res", 1, false},
+		{"corrupt", "{\"entry\": \"a\", \"res\n{\"entry\": \"b\", \"result\": {}}\n", 0, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "journal")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.contents), 0644))
+			journal, err := openManifestJournal(path)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, journal.results, tc.expectedCount)
+		})
+	}
+}
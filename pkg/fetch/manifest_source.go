@@ -0,0 +1,165 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matches a manifest in a GitHub repo, written as <host>/<owner>/<repo>//<path>?ref=<ref> with an optional https://
+// prefix, e.g. github.com/org/tooling//bootstrap.yaml?ref=v2.1.0. The host must contain a dot, so that a local path with
+// a double slash in it isn't mistaken for one.
+var remoteManifestRegex = regexp.MustCompile(`^(?:https://)?([^/]+\.[^/]+)/([^/]+)/([^/]+)//([^?]+)(?:\?(.*))?$`)
+
+// A manifest file in a GitHub repo, which lets a manifest be versioned, and managed centrally, like any other file
+type RemoteManifest struct {
+	RepoUrl string // e.g. https://github.com/org/tooling
+	Path    string // The path of the manifest in the repo, e.g. bootstrap.yaml
+	Ref     string // The git ref the manifest is read at, expressed as it would be with the --ref flag
+}
+
+// Parse source as a manifest in a GitHub repo (see remoteManifestRegex). Returns nil if source is a local path instead.
+func ParseRemoteManifest(source string) (*RemoteManifest, error) {
+	matches := remoteManifestRegex.FindStringSubmatch(source)
+	if matches == nil {
+		return nil, nil
+	}
+
+	query, err := url.ParseQuery(matches[5])
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse the query of manifest %s: %s", source, err)
+	}
+	for key := range query {
+		if key != "ref" {
+			return nil, fmt.Errorf("Unknown query parameter \"%s\" in manifest %s. Only \"ref\" is supported.", key, source)
+		}
+	}
+	ref := query.Get("ref")
+	if ref == "" {
+		return nil, fmt.Errorf("The manifest %s must be pinned to a git ref with ?ref=<tag, branch, or commit>, e.g. ?ref=v1.0.0.", source)
+	}
+
+	return &RemoteManifest{
+		RepoUrl: fmt.Sprintf("https://%s/%s/%s", matches[1], matches[2], matches[3]),
+		Path:    matches[4],
+		Ref:     ref,
+	}, nil
+}
+
+// Read and validate the manifest at source, which is either a local path or a manifest in a GitHub repo (see
+// ParseRemoteManifest), which is downloaded with the GitHub token and connection settings in base. If checksum (e.g.
+// sha256:abcd...) is set, the manifest must match it before anything in it is run.
+func LoadManifestFrom(ctx context.Context, source string, checksum string, base Options) (*Manifest, error) {
+	remote, err := ParseRemoteManifest(source)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestPath := source
+	if remote != nil {
+		tempDir, err := ioutil.TempDir("", "fetch-manifest")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		if manifestPath, err = downloadRemoteManifest(ctx, remote, tempDir, base); err != nil {
+			return nil, err
+		}
+	}
+
+	if checksum != "" {
+		if err := verifyManifestChecksum(manifestPath, checksum); err != nil {
+			return nil, err
+		}
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hosts of a manifest say which env vars and files to send as tokens, and to which hosts, so anyone who can
+	// change a manifest that's read from a repo could otherwise have any secret on this machine sent to a host of their
+	// choosing
+	if remote != nil && checksum == "" && len(manifest.Hosts) > 0 {
+		return nil, fmt.Errorf("The manifest %s sets \"hosts\", which read tokens from env vars and files, so it must be pinned with --manifest-checksum before it's run.", source)
+	}
+	return manifest, nil
+}
+
+// Download the given manifest into destDir, and return the path it was downloaded to
+func downloadRemoteManifest(ctx context.Context, remote *RemoteManifest, destDir string, base Options) (string, error) {
+	options := Options{
+		RepoUrl:           remote.RepoUrl,
+		GitRef:            remote.Ref,
+		SourceFiles:       []string{remote.Path},
+		LocalDownloadPath: destDir,
+		GithubToken:       base.GithubToken,
+		GithubApiVersion:  base.GithubApiVersion,
+		GhesVersion:       base.GhesVersion,
+		Connection:        base.Connection,
+		WaitForRateLimit:  base.WaitForRateLimit,
+		ArchiveCacheDir:   base.ArchiveCacheDir,
+		Logger:            base.Logger,
+	}
+	fetcher, err := NewFetcher(options)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fetcher.Fetch(ctx, io.Discard); err != nil {
+		return "", fmt.Errorf("Could not download manifest %s from %s at %s: %s", remote.Path, remote.RepoUrl, remote.Ref, err)
+	}
+	return filepath.Join(destDir, path.Base(strings.Trim(remote.Path, "/"))), nil
+}
+
+// Return an error if the manifest at the given path doesn't match the given checksum, which must have an algorithm
+// prefix (e.g. sha256:abcd...)
+func verifyManifestChecksum(manifestPath string, checksum string) error {
+	if err := ValidateManifestChecksum(checksum); err != nil {
+		return err
+	}
+	algorithm, expected := ParseChecksum(checksum, "")
+	actual, err := computeChecksum(manifestPath, algorithm, nil)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return newError(checksumDoesNotMatch, fmt.Sprintf("The %s checksum of the manifest is %s, which does not match %s.", algorithm, actual, expected))
+	}
+	return nil
+}
+
+// Return an error if the given manifest checksum has no algorithm prefix (e.g. sha256:abcd...) or an unsupported one
+func ValidateManifestChecksum(checksum string) error {
+	algorithm, _ := ParseChecksum(checksum, "")
+	if algorithm == "" {
+		return fmt.Errorf("The manifest checksum %s has no algorithm prefix (e.g. sha256:%s).", checksum, checksum)
+	}
+	_, err := GetHasher(algorithm)
+	return err
+}
+
+// Return whether the manifest at the given path is written in YAML rather than JSON
+func isYamlManifest(manifestPath string) bool {
+	ext := strings.ToLower(filepath.Ext(manifestPath))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Convert a YAML manifest into the equivalent JSON, so it's validated exactly as a JSON manifest is
+func yamlManifestToJson(contents []byte) ([]byte, error) {
+	var manifest interface{}
+	if err := yaml.Unmarshal(contents, &manifest); err != nil {
+		return nil, fmt.Errorf("Could not parse manifest: %s", err)
+	}
+	return json.Marshal(manifest)
+}
@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemoteManifest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source    string
+		expected  *RemoteManifest
+		expectErr bool
+	}{
+		{"github.com/org/tooling//bootstrap.yaml?ref=v2.1.0", &RemoteManifest{"https://github.com/org/tooling", "bootstrap.yaml", "v2.1.0"}, false},
+		{"https://ghe.mycompany.com/org/tooling//manifests/ci.json?ref=main", &RemoteManifest{"https://ghe.mycompany.com/org/tooling", "manifests/ci.json", "main"}, false},
+		{"github.com/org/tooling//bootstrap.yaml", nil, true},
+		{"github.com/org/tooling//bootstrap.yaml?ref=v2.1.0&checksum=abc", nil, true},
+		{"fetch.json", nil, false},
+		{"/etc/fetch/manifest.json", nil, false},
+		{"configs/ci//fetch.json", nil, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+
+			remote, err := ParseRemoteManifest(tc.source)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, remote)
+		})
+	}
+}
+
+func TestLoadManifestFromLocalPathVerifiesChecksum(t *testing.T) {
+	t.Parallel()
+
+	contents := []byte(`{"entries": [{"repo": "https://github.com/foo/bar", "tag": "v1.0.0", "releaseAsset": "bar", "destination": "/tmp/bar"}]}`)
+	manifestPath := filepath.Join(t.TempDir(), "fetch.json")
+	require.NoError(t, os.WriteFile(manifestPath, contents, 0644))
+	sha := sha256.Sum256(contents)
+
+	manifest, err := LoadManifestFrom(context.Background(), manifestPath, "sha256:"+hex.EncodeToString(sha[:]), Options{})
+	require.NoError(t, err)
+	assert.Len(t, manifest.Entries, 1)
+
+	_, err = LoadManifestFrom(context.Background(), manifestPath, "sha256:0000", Options{})
+	assert.Error(t, err)
+
+	_, err = LoadManifestFrom(context.Background(), manifestPath, "0000", Options{})
+	assert.Error(t, err)
+}
+
+func TestLoadYamlManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "bootstrap.yaml")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`
+entries:
+  - repo: https://github.com/foo/bar
+    tag: "~>1.0"
+    releaseAsset: bar_linux_amd64
+    releaseAssetChecksums: ["sha256:abcd"]
+    destination: /tmp/bar
+`), 0644))
+
+	manifest, err := LoadManifest(manifestPath)
+	require.NoError(t, err)
+	require.Len(t, manifest.Entries, 1)
+	assert.Equal(t, "~>1.0", manifest.Entries[0].Tag)
+	assert.Equal(t, []string{"sha256:abcd"}, manifest.Entries[0].ReleaseAssetChecksums)
+
+	// Unknown fields are rejected in YAML just as they are in JSON
+	unknownPath := filepath.Join(dir, "unknown.yml")
+	require.NoError(t, os.WriteFile(unknownPath, []byte("entries:\n  - repo: r\n    tag: v1\n    dest: /tmp\n"), 0644))
+	_, err = LoadManifest(unknownPath)
+	assert.Error(t, err)
+}
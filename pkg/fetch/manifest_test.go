@@ -0,0 +1,119 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseManifest(t *testing.T) {
+	t.Parallel()
+
+	manifest, err := parseManifest([]byte(`{
+		"entries": [
+			{"repo": "https://github.com/foo/bar", "tag": "~>1.0", "releaseAsset": "bar_linux_amd64", "releaseAssetChecksums": ["sha256:ABCD"], "destination": "/tmp/one"},
+			{"repo": "https://github.com/foo/baz", "branch": "main", "sourcePaths": ["/modules"], "timeout": "90s", "maxSize": "1MiB", "destination": "/tmp/two"},
+			{"url": "https://example.com/tool_1.0.0.zip", "releaseAssetChecksums": ["sha256:ABCD"], "unpack": true, "destination": "/tmp/three"}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Len(t, manifest.Entries, 3)
+
+	options := manifest.Entries[0].options(Options{GithubToken: "token", LocalDownloadPath: "/tmp/ignored", Timeout: time.Minute, MaxDownloadSize: 1000})
+	assert.Equal(t, "https://github.com/foo/bar", options.RepoUrl)
+	assert.Equal(t, "~>1.0", options.TagConstraint)
+	assert.Equal(t, "bar_linux_amd64", options.ReleaseAsset)
+	assert.Equal(t, map[string]bool{"sha256:ABCD": true}, options.ReleaseAssetChecksums)
+	assert.Equal(t, "/tmp/one", options.LocalDownloadPath)
+	assert.Equal(t, "token", options.GithubToken)
+	assert.Equal(t, time.Minute, options.Timeout)
+	assert.Equal(t, uint64(1000), options.MaxDownloadSize)
+
+	options = manifest.Entries[1].options(Options{Timeout: time.Minute, MaxDownloadSize: 1000})
+	assert.Equal(t, "main", options.BranchName)
+	assert.Equal(t, 90*time.Second, options.Timeout)
+	assert.Equal(t, uint64(1<<20), options.MaxDownloadSize)
+	assert.Equal(t, []string{"/modules"}, options.SourcePaths)
+	assert.Nil(t, options.ReleaseAssetChecksums)
+
+	options = manifest.Entries[2].options(Options{})
+	assert.Equal(t, "", options.RepoUrl)
+	assert.Equal(t, "https://example.com/tool_1.0.0.zip", options.Url)
+	assert.True(t, options.Unpack)
+}
+
+func TestParseManifestInvalid(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		contents      string
+		expectedError string
+	}{
+		{"not-json", `entries:`, "Could not parse manifest: invalid character 'e' looking for beginning of value"},
+		{"unknown-field", `{"entries": [{"repo": "r", "tag": "v1", "dest": "/tmp"}]}`, "Could not parse manifest: json: unknown field \"dest\""},
+		{"no-entries", `{"entries": []}`, "The manifest has no entries."},
+		{"no-repo-or-url", `{"entries": [{"tag": "v1", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: exactly one of \"repo\" or \"url\" is required"},
+		{"repo-and-url", `{"entries": [{"repo": "r", "url": "https://example.com/tool.zip", "tag": "v1", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: exactly one of \"repo\" or \"url\" is required"},
+		{"url-with-tag", `{"entries": [{"url": "https://example.com/tool.zip", "tag": "v1", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: \"url\" can only be used with \"releaseAssetChecksums\", \"unpack\", and \"unpackInclude\""},
+		{"url-not-http", `{"entries": [{"url": "ftp://example.com/tool.zip", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: The URL ftp://example.com/tool.zip must be a plain http:// or https:// URL."},
+		{"invalid-timeout", `{"entries": [{"repo": "r", "tag": "v1", "timeout": "5", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: \"timeout\" must be a positive duration, e.g. 90s or 5m"},
+		{"invalid-max-size", `{"entries": [{"repo": "r", "tag": "v1", "maxSize": "lots", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: \"maxSize\" must be a positive number of bytes, optionally with a unit, e.g. 64KB or 1.5GiB"},
+		{"no-destination", `{"entries": [{"repo": "r", "tag": "v1"}]}`, "Manifest entry 1 is invalid: \"destination\" is required"},
+		{"no-ref", `{"entries": [{"repo": "r", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: one of \"ref\", \"tag\", \"branch\", or \"commit\" is required"},
+		{"release-asset-without-tag", `{"entries": [{"repo": "r", "branch": "main", "releaseAsset": "a", "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: \"releaseAsset\" can only be used with \"tag\""},
+		{"unpack-include-without-unpack", `{"entries": [{"repo": "r", "tag": "v1", "releaseAsset": "a.tgz", "unpackInclude": ["bin/*"], "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: \"unpackInclude\" can only be used with \"unpack\""},
+		{"host-with-scheme", `{"hosts": {"https://github.com": {"tokenEnv": "TOKEN"}}, "entries": [{"repo": "r", "tag": "v1", "destination": "/tmp"}]}`, "Manifest host https://github.com is invalid: it must be a host name, e.g. github.com, without a scheme or path"},
+		{"host-without-token", `{"hosts": {"github.com": {}}, "entries": [{"repo": "r", "tag": "v1", "destination": "/tmp"}]}`, "Manifest host github.com is invalid: exactly one of \"tokenEnv\" or \"tokenFile\" is required"},
+		{"host-with-both-tokens", `{"hosts": {"github.com": {"tokenEnv": "TOKEN", "tokenFile": "/tmp/token"}}, "entries": [{"repo": "r", "tag": "v1", "destination": "/tmp"}]}`, "Manifest host github.com is invalid: exactly one of \"tokenEnv\" or \"tokenFile\" is required"},
+		{"host-token-from-stdin", `{"hosts": {"github.com": {"tokenFile": "-"}}, "entries": [{"repo": "r", "tag": "v1", "destination": "/tmp"}]}`, "Manifest host github.com is invalid: \"tokenFile\" can't be stdin"},
+		{"checksum-without-algorithm", `{"entries": [{"repo": "r", "tag": "v1", "releaseAssetChecksums": ["abcd"], "destination": "/tmp"}]}`, "Manifest entry 1 is invalid: checksum abcd has no algorithm prefix (e.g. sha256:abcd)"},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseManifest([]byte(tc.contents))
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+// This test sets an env var, so it can't run in parallel with other tests
+func TestManifestHostTokens(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "ghes-token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("ghes-token\n"), 0600))
+	t.Setenv("FETCH_TEST_GITHUB_COM_TOKEN", "github-token")
+
+	manifest, err := parseManifest([]byte(fmt.Sprintf(`{
+		"hosts": {
+			"GitHub.com": {"tokenEnv": "FETCH_TEST_GITHUB_COM_TOKEN"},
+			"ghe.mycompany.com": {"tokenFile": %q},
+			"unused.mycompany.com": {"tokenEnv": "FETCH_TEST_UNSET_TOKEN"}
+		},
+		"entries": [
+			{"repo": "https://www.github.com/foo/bar", "tag": "v1", "destination": "/tmp/one"},
+			{"repo": "https://ghe.mycompany.com/foo/bar", "tag": "v1", "destination": "/tmp/two"},
+			{"repo": "https://other.mycompany.com/foo/bar", "tag": "v1", "destination": "/tmp/three"}
+		]
+	}`, tokenFile)))
+	require.NoError(t, err)
+
+	tokens, err := manifest.hostTokens()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"github.com": "github-token", "ghe.mycompany.com": "ghes-token"}, tokens)
+
+	manifest.Entries = append(manifest.Entries, ManifestEntry{Repo: "https://unused.mycompany.com/foo/bar", Tag: "v1", Destination: "/tmp/four"})
+	_, err = manifest.hostTokens()
+	assert.EqualError(t, err, "The env var FETCH_TEST_UNSET_TOKEN, which the manifest reads the token for unused.mycompany.com from, is not set.")
+}
@@ -0,0 +1,190 @@
+package fetch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The locales fetch's friendlier error messages are written in
+const (
+	LocaleEnglish  = "en"
+	LocaleJapanese = "ja"
+	LocaleGerman   = "de"
+)
+
+// The friendlier messages of the FetchErrors that getErrorMessage explains, by locale and then error code. Each is a
+// format string for the details of the FetchError. Every locale must have a message for every error code English has.
+var errorMessageCatalog = map[string]map[int]string{
+	LocaleEnglish: {
+		invalidTagConstraintExpression: `
+The --tag value you entered is not a valid constraint expression.
+See https://github.com/gruntwork-io/fetch#version-constraint-operators for examples.
+
+Underlying error message:
+%s
+`,
+		invalidGithubTokenOrAccessDenied: `
+Received an HTTP 401 Response when attempting to query the repo for its tags.
+
+This means that either your GitHub oAuth Token is invalid, or that the token is valid but is being used to request access
+to either a public repo or a private repo to which you don't have access.
+
+Underlying error message:
+%s
+`,
+		repoDoesNotExistOrAccessDenied: `
+Received an HTTP 404 Response when attempting to query the repo for its tags.
+
+This means that either no GitHub repo exists at the URL provided, or that you don't have permission to access it.
+If the URL is correct, you may need to pass in a --github-oauth-token.
+
+Underlying error message:
+%s
+`,
+		githubApiRateLimitExceeded: `
+The GitHub API rate limit has been exhausted.
+
+Unauthenticated requests have a much lower rate limit than authenticated ones, so consider passing in a
+--github-oauth-token. Alternatively, re-run fetch with --wait-for-rate-limit to wait until the rate limit resets.
+
+Underlying error message:
+%s
+`,
+	},
+	LocaleJapanese: {
+		invalidTagConstraintExpression: `
+入力された --tag の値は有効なバージョン制約式ではありません。
+例については https://github.com/gruntwork-io/fetch#version-constraint-operators を参照してください。
+
+元のエラーメッセージ:
+%s
+`,
+		invalidGithubTokenOrAccessDenied: `
+リポジトリのタグを取得しようとしたところ、HTTP 401 レスポンスを受け取りました。
+
+GitHub OAuth トークンが無効であるか、トークンは有効でも、公開リポジトリ、またはアクセス権のない非公開リポジトリへの
+アクセスに使われています。
+
+元のエラーメッセージ:
+%s
+`,
+		repoDoesNotExistOrAccessDenied: `
+リポジトリのタグを取得しようとしたところ、HTTP 404 レスポンスを受け取りました。
+
+指定された URL に GitHub リポジトリが存在しないか、アクセスする権限がありません。
+URL が正しい場合は、--github-oauth-token を指定する必要があるかもしれません。
+
+元のエラーメッセージ:
+%s
+`,
+		githubApiRateLimitExceeded: `
+GitHub API のレート制限に達しました。
+
+認証されていないリクエストのレート制限は認証済みのリクエストよりもはるかに低いため、--github-oauth-token の指定を
+検討してください。または、--wait-for-rate-limit を付けて fetch を再実行すると、レート制限がリセットされるまで待機します。
+
+元のエラーメッセージ:
+%s
+`,
+	},
+	LocaleGerman: {
+		invalidTagConstraintExpression: `
+Der eingegebene --tag-Wert ist kein gültiger Versionsausdruck.
+Beispiele finden Sie unter https://github.com/gruntwork-io/fetch#version-constraint-operators.
+
+Ursprüngliche Fehlermeldung:
+%s
+`,
+		invalidGithubTokenOrAccessDenied: `
+Beim Abfragen der Tags des Repos wurde eine HTTP-401-Antwort empfangen.
+
+Das bedeutet, dass entweder Ihr GitHub-OAuth-Token ungültig ist oder dass das Token zwar gültig ist, aber für den Zugriff
+auf ein öffentliches Repo oder auf ein privates Repo verwendet wird, auf das Sie keinen Zugriff haben.
+
+Ursprüngliche Fehlermeldung:
+%s
+`,
+		repoDoesNotExistOrAccessDenied: `
+Beim Abfragen der Tags des Repos wurde eine HTTP-404-Antwort empfangen.
+
+Das bedeutet, dass entweder unter der angegebenen URL kein GitHub-Repo existiert oder dass Sie keine Berechtigung für den
+Zugriff darauf haben. Wenn die URL korrekt ist, müssen Sie möglicherweise ein --github-oauth-token angeben.
+
+Ursprüngliche Fehlermeldung:
+%s
+`,
+		githubApiRateLimitExceeded: `
+Das Rate-Limit der GitHub-API ist ausgeschöpft.
+
+Nicht authentifizierte Anfragen haben ein viel niedrigeres Rate-Limit als authentifizierte. Geben Sie daher ggf. ein
+--github-oauth-token an. Alternativ können Sie fetch mit --wait-for-rate-limit erneut ausführen, um zu warten, bis das
+Rate-Limit zurückgesetzt wird.
+
+Ursprüngliche Fehlermeldung:
+%s
+`,
+	},
+}
+
+// ValidateLocale returns an error if the given locale of fetch's friendlier error messages, e.g. "ja" or "de_DE.UTF-8",
+// isn't one of SupportedLocales. An empty locale is English.
+func ValidateLocale(locale string) error {
+	if locale == "" {
+		return nil
+	}
+	if _, supported := ParseLocale(locale); !supported {
+		return fmt.Errorf("Unsupported locale \"%s\". Must be one of: %s.", locale, strings.Join(SupportedLocales(), ", "))
+	}
+	return nil
+}
+
+// SupportedLocales returns the locales fetch's friendlier error messages can be written in, sorted
+func SupportedLocales() []string {
+	var locales []string
+	for locale := range errorMessageCatalog {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// ParseLocale returns the language of the given locale, which may be a POSIX locale such as ja_JP.UTF-8 or a language
+// tag such as de-CH, and whether there are messages in that language
+func ParseLocale(locale string) (string, bool) {
+	language := strings.ToLower(strings.TrimSpace(locale))
+	for _, separator := range []string{".", "@", "_", "-"} {
+		language, _, _ = strings.Cut(language, separator)
+	}
+	_, supported := errorMessageCatalog[language]
+	return language, supported
+}
+
+// LocaleFromEnv returns the locale of the environment, as read with getenv, from the first of the LC_ALL, LC_MESSAGES,
+// and LANG env vars that is set, as POSIX does, or LocaleEnglish if there are no messages in its language
+func LocaleFromEnv(getenv func(string) string) string {
+	for _, envVar := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := getenv(envVar); value != "" {
+			if language, supported := ParseLocale(value); supported {
+				return language
+			}
+			return LocaleEnglish
+		}
+	}
+	return LocaleEnglish
+}
+
+// Return the friendlier message of a FetchError with the given error code and details, in the given locale (English, if
+// it's empty or has no messages), or an empty string if it has none
+func getErrorMessage(locale string, errorCode int, errorDetails string) string {
+	language, supported := ParseLocale(locale)
+	if !supported {
+		language = LocaleEnglish
+	}
+
+	message, ok := errorMessageCatalog[language][errorCode]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(message, errorDetails)
+}
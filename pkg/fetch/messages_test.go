@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMessageCatalogIsComplete(t *testing.T) {
+	t.Parallel()
+
+	for locale, messages := range errorMessageCatalog {
+		for errorCode := range errorMessageCatalog[LocaleEnglish] {
+			assert.Contains(t, messages[errorCode], "%s", "locale %s has no message for error code %d", locale, errorCode)
+		}
+		assert.Len(t, messages, len(errorMessageCatalog[LocaleEnglish]), "locale %s", locale)
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		locale           string
+		expectedLanguage string
+		supported        bool
+	}{
+		{"en", LocaleEnglish, true},
+		{"ja_JP.UTF-8", LocaleJapanese, true},
+		{"de_DE@euro", LocaleGerman, true},
+		{"de-CH", LocaleGerman, true},
+		{"JA", LocaleJapanese, true},
+		{"fr_FR.UTF-8", "fr", false},
+		{"C.UTF-8", "c", false},
+		{"", "", false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.locale, func(t *testing.T) {
+			t.Parallel()
+			language, supported := ParseLocale(tc.locale)
+			assert.Equal(t, tc.expectedLanguage, language)
+			assert.Equal(t, tc.supported, supported)
+		})
+	}
+}
+
+func TestLocaleFromEnv(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		expectedLocale string
+	}{
+		{"none", map[string]string{}, LocaleEnglish},
+		{"lang", map[string]string{"LANG": "ja_JP.UTF-8"}, LocaleJapanese},
+		{"lc-messages-over-lang", map[string]string{"LC_MESSAGES": "de_DE.UTF-8", "LANG": "ja_JP.UTF-8"}, LocaleGerman},
+		{"lc-all-over-everything", map[string]string{"LC_ALL": "ja_JP.UTF-8", "LC_MESSAGES": "de_DE.UTF-8"}, LocaleJapanese},
+		{"unsupported", map[string]string{"LC_ALL": "C", "LANG": "de_DE.UTF-8"}, LocaleEnglish},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			getenv := func(name string) string { return tc.env[name] }
+			assert.Equal(t, tc.expectedLocale, LocaleFromEnv(getenv))
+		})
+	}
+}
+
+func TestGetErrorMessageUsesLocale(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, getErrorMessage("", githubApiRateLimitExceeded, "API rate limit exceeded"), "The GitHub API rate limit has been exhausted")
+
+	message := getErrorMessage("ja_JP.UTF-8", githubApiRateLimitExceeded, "API rate limit exceeded")
+	assert.Contains(t, message, "GitHub API のレート制限に達しました")
+	assert.Contains(t, message, "API rate limit exceeded")
+
+	assert.Contains(t, getErrorMessage("de", repoDoesNotExistOrAccessDenied, "Not Found"), "HTTP-404-Antwort")
+	assert.Empty(t, getErrorMessage("de", failedToDownloadFile, "details"))
+}
+
+func TestValidateLocale(t *testing.T) {
+	t.Parallel()
+
+	for _, locale := range []string{"", "en", "ja_JP.UTF-8", "de-CH"} {
+		assert.NoError(t, ValidateLocale(locale), locale)
+	}
+	assert.Error(t, ValidateLocale("fr"))
+
+	_, err := NewFetcher(Options{RepoUrl: "https://github.com/foo/bar", Locale: "fr"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,507 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The media types of the OCI artifact that release assets are exported as. For more info, see:
+// https://github.com/opencontainers/image-spec/blob/main/manifest.md
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociEmptyConfigMediaType = "application/vnd.oci.empty.v1+json"
+	ociLayerMediaType       = "application/octet-stream"
+
+	// The artifact type of every artifact exported by fetch, which tools such as "oras discover" show
+	OciArtifactType = "application/vnd.gruntwork.fetch.release.v1"
+)
+
+// The annotations fetch records on an exported artifact and its layers. The org.opencontainers.image.title annotation
+// on each layer is the file name that tools such as "oras pull" write the layer to.
+const (
+	ociAnnotationTitle    = "org.opencontainers.image.title"
+	ociAnnotationSource   = "org.opencontainers.image.source"
+	ociAnnotationVersion  = "org.opencontainers.image.version"
+	ociAnnotationRevision = "org.opencontainers.image.revision"
+	ociAnnotationCreated  = "org.opencontainers.image.created"
+)
+
+// The config of an exported artifact is the empty JSON object, as the OCI image spec recommends for artifacts that
+// aren't container images
+var ociEmptyConfig = []byte("{}")
+
+// The repository and tag rules of the OCI distribution spec. For more info, see:
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+var ociRepositoryRegex = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+var ociTagRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// A reference to a repository in an OCI registry, and optionally a tag in it, e.g. ghcr.io/org/tool:v1.0.0
+type OciReference struct {
+	Registry   string // e.g. ghcr.io or localhost:5000
+	Repository string // e.g. org/tool
+	Tag        string // e.g. v1.0.0. May be empty.
+}
+
+func (ref OciReference) String() string {
+	if ref.Tag == "" {
+		return ref.Registry + "/" + ref.Repository
+	}
+	return ref.Registry + "/" + ref.Repository + ":" + ref.Tag
+}
+
+// Parse a reference of the form <registry>/<repository>[:<tag>]. The registry must always be given, as fetch has no
+// default registry to fall back to.
+func ParseOciReference(reference string) (OciReference, error) {
+	if strings.Contains(reference, "@") {
+		return OciReference{}, fmt.Errorf("The OCI reference %s includes a digest, but artifacts can only be pushed to a tag.", reference)
+	}
+
+	slash := strings.Index(reference, "/")
+	if slash < 0 {
+		return OciReference{}, fmt.Errorf("The OCI reference %s must start with a registry, e.g. ghcr.io/org/tool:v1.0.0.", reference)
+	}
+	registry, rest := reference[:slash], reference[slash+1:]
+	if !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return OciReference{}, fmt.Errorf("The OCI reference %s must start with a registry, e.g. ghcr.io/org/tool:v1.0.0.", reference)
+	}
+
+	repository, tag := rest, ""
+	if colon := strings.LastIndex(rest, ":"); colon >= 0 {
+		repository, tag = rest[:colon], rest[colon+1:]
+		if !ociTagRegex.MatchString(tag) {
+			return OciReference{}, fmt.Errorf("\"%s\" is not a valid OCI tag. Tags may only contain letters, digits, and the characters . _ - and may not start with . or -", tag)
+		}
+	}
+	if !ociRepositoryRegex.MatchString(repository) {
+		return OciReference{}, fmt.Errorf("\"%s\" is not a valid OCI repository. Repositories may only contain lowercase letters, digits, and separators.", repository)
+	}
+
+	return OciReference{Registry: registry, Repository: repository, Tag: tag}, nil
+}
+
+// The options for OciExport. Source describes the repo, tag constraint, release assets, and verification to export.
+type OciExportOptions struct {
+	Source Options
+
+	// The OCI reference to push the artifact to, e.g. ghcr.io/org/tool:v1.0.0. The tag defaults to the resolved release
+	// tag.
+	Reference string
+
+	// The credentials to push with, if the registry requires them
+	RegistryUsername string
+	RegistryPassword string
+
+	// Connect to the registry over plain HTTP rather than HTTPS. Only meant for local registries.
+	PlainHttp bool
+}
+
+// The artifact pushed by OciExport
+type OciExportResult struct {
+	Reference OciReference // The reference the artifact was pushed to, with the tag it was pushed with
+	Digest    string       // The digest of the artifact's manifest, e.g. sha256:abcd...
+}
+
+// Download and verify the release assets matching options.Source.ReleaseAsset from the release matching
+// options.Source.TagConstraint, and push them to an OCI registry as the layers of a single artifact. The artifact
+// records the repo, tag, and commit it came from in the standard org.opencontainers.image annotations, and each layer
+// keeps the SHA256 digest of the release asset it holds, so the artifact can be traced back to the release. This is
+// what the "fetch oci-export" command runs.
+func OciExport(ctx context.Context, logger *logrus.Entry, options OciExportOptions) (OciExportResult, error) {
+	ref, err := ParseOciReference(options.Reference)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-oci-export")
+	if err != nil {
+		return OciExportResult{}, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceOptions := options.Source
+	sourceOptions.LocalDownloadPath = tempDir
+	sourceOptions.Logger = logger
+	source, err := NewFetcher(sourceOptions)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+	// The registry is reached over the same connection as the source, as the Connection options describe
+	ctx = source.withConnection(ctx)
+
+	resolvedTag, err := source.ResolveTag(ctx)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+	if ref.Tag == "" {
+		if !ociTagRegex.MatchString(resolvedTag.Tag) {
+			return OciExportResult{}, fmt.Errorf("The release tag %s is not a valid OCI tag, so a tag must be given in the OCI reference.", resolvedTag.Tag)
+		}
+		ref.Tag = resolvedTag.Tag
+	}
+
+	assetPaths, err := source.DownloadReleaseAssets(ctx, resolvedTag.Tag)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+	if len(assetPaths) == 0 {
+		return OciExportResult{}, fmt.Errorf("No release assets were downloaded from release %s of %s", resolvedTag.Tag, source.repo.Url)
+	}
+	if err := source.VerifyReleaseAssets(ctx, resolvedTag.Tag, assetPaths); err != nil {
+		return OciExportResult{}, err
+	}
+
+	// Sort the layers by file name, so that exporting the same release twice gives the same layers
+	sort.Slice(assetPaths, func(i, j int) bool { return filepath.Base(assetPaths[i]) < filepath.Base(assetPaths[j]) })
+	layers, err := ociLayers(assetPaths)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+	annotations := map[string]string{
+		ociAnnotationSource:  source.repo.Url,
+		ociAnnotationVersion: resolvedTag.Tag,
+		ociAnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+	if resolvedTag.CommitSha != "" {
+		annotations[ociAnnotationRevision] = resolvedTag.CommitSha
+	}
+	manifest, err := json.Marshal(newOciManifest(layers, annotations))
+	if err != nil {
+		return OciExportResult{}, err
+	}
+
+	registry := newOciRegistry(ref, options.RegistryUsername, options.RegistryPassword, options.PlainHttp)
+	if err := registry.pushBlob(ctx, ociEmptyConfigDescriptor(), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(ociEmptyConfig)), nil
+	}); err != nil {
+		return OciExportResult{}, err
+	}
+	for i, layer := range layers {
+		assetPath := assetPaths[i]
+		logger.Infof("Pushing %s to %s\n", filepath.Base(assetPath), ref)
+		if err := registry.pushBlob(ctx, layer, func() (io.ReadCloser, error) { return os.Open(assetPath) }); err != nil {
+			return OciExportResult{}, err
+		}
+	}
+	digest, err := registry.pushManifest(ctx, manifest)
+	if err != nil {
+		return OciExportResult{}, err
+	}
+
+	logger.Infof("Exported %d release assets from %s (%s) to %s@%s\n", len(assetPaths), source.repo.Url, resolvedTag.Tag, ref, digest)
+	return OciExportResult{Reference: ref, Digest: digest}, nil
+}
+
+// A reference to a blob or manifest in an OCI registry
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// An OCI image manifest, which lists the config and layers of an artifact
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+func newOciManifest(layers []ociDescriptor, annotations map[string]string) ociManifest {
+	return ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		ArtifactType:  OciArtifactType,
+		Config:        ociEmptyConfigDescriptor(),
+		Layers:        layers,
+		Annotations:   annotations,
+	}
+}
+
+func ociEmptyConfigDescriptor() ociDescriptor {
+	return ociDescriptor{MediaType: ociEmptyConfigMediaType, Digest: ociDigest(ociEmptyConfig), Size: int64(len(ociEmptyConfig))}
+}
+
+// Return a layer for each of the given files, titled with its file name
+func ociLayers(filePaths []string) ([]ociDescriptor, error) {
+	var layers []ociDescriptor
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		checksum, err := computeChecksum(filePath, "sha256", nil)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, ociDescriptor{
+			MediaType:   ociLayerMediaType,
+			Digest:      "sha256:" + checksum,
+			Size:        info.Size(),
+			Annotations: map[string]string{ociAnnotationTitle: filepath.Base(filePath)},
+		})
+	}
+	return layers, nil
+}
+
+func ociDigest(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// A client for pushing to a single repository of an OCI registry, over the API in the OCI distribution spec. For more
+// info, see: https://github.com/opencontainers/distribution-spec/blob/main/spec.md#push
+type ociRegistry struct {
+	ref      OciReference
+	baseUrl  string
+	username string
+	password string
+
+	// How requests are authorized, once the registry has asked for credentials: with a token from the registry's token
+	// service, or with the username and password
+	bearerToken string
+	basicAuth   bool
+}
+
+func newOciRegistry(ref OciReference, username string, password string, plainHttp bool) *ociRegistry {
+	scheme := "https"
+	if plainHttp {
+		scheme = "http"
+	}
+	return &ociRegistry{
+		ref:      ref,
+		baseUrl:  fmt.Sprintf("%s://%s/v2/%s", scheme, ref.Registry, ref.Repository),
+		username: username,
+		password: password,
+	}
+}
+
+// Push a blob with the given descriptor, whose contents are read with open, unless the registry already has it
+func (registry *ociRegistry) pushBlob(ctx context.Context, descriptor ociDescriptor, open func() (io.ReadCloser, error)) error {
+	blobUrl := fmt.Sprintf("%s/blobs/%s", registry.baseUrl, descriptor.Digest)
+	resp, err := registry.do(ctx, http.MethodHead, blobUrl, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	uploadsUrl := registry.baseUrl + "/blobs/uploads/"
+	resp, err = registry.do(ctx, http.MethodPost, uploadsUrl, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return ociRegistryError(resp, uploadsUrl)
+	}
+	uploadUrl, err := ociUploadUrl(resp.Request.URL, resp.Header.Get("Location"), descriptor.Digest)
+	if err != nil {
+		return err
+	}
+
+	resp, err = registry.do(ctx, http.MethodPut, uploadUrl, open, descriptor.Size, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return ociRegistryError(resp, uploadUrl)
+	}
+	return nil
+}
+
+// Push the given manifest to the tag of the registry's reference, and return its digest
+func (registry *ociRegistry) pushManifest(ctx context.Context, manifest []byte) (string, error) {
+	manifestUrl := fmt.Sprintf("%s/manifests/%s", registry.baseUrl, registry.ref.Tag)
+	open := func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(manifest)), nil }
+	resp, err := registry.do(ctx, http.MethodPut, manifestUrl, open, int64(len(manifest)), map[string]string{"Content-Type": ociManifestMediaType})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", ociRegistryError(resp, manifestUrl)
+	}
+	return ociDigest(manifest), nil
+}
+
+// Return the URL to finish a blob upload at, given the URL the upload was started at and the Location it returned,
+// which may be relative and may already have a query
+func ociUploadUrl(requestUrl *url.URL, location string, digest string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("The registry did not return a Location to upload blob %s to.", digest)
+	}
+	uploadUrl, err := requestUrl.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("The registry returned an invalid Location to upload blob %s to: %s", digest, err)
+	}
+	query := uploadUrl.Query()
+	query.Set("digest", digest)
+	uploadUrl.RawQuery = query.Encode()
+	return uploadUrl.String(), nil
+}
+
+// Send a request to the registry, authorizing it as the registry asks if it's rejected with a 401. The body, if any, is
+// read with open, so that it can be sent again.
+func (registry *ociRegistry) do(ctx context.Context, method string, requestUrl string, open func() (io.ReadCloser, error), size int64, headers map[string]string) (*http.Response, error) {
+	resp, err := registry.send(ctx, method, requestUrl, open, size, headers)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := registry.authorize(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+	return registry.send(ctx, method, requestUrl, open, size, headers)
+}
+
+func (registry *ociRegistry) send(ctx context.Context, method string, requestUrl string, open func() (io.ReadCloser, error), size int64, headers map[string]string) (*http.Response, error) {
+	var body io.ReadCloser
+	if open != nil {
+		var err error
+		if body, err = open(); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, requestUrl, body)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	request.ContentLength = size
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	if registry.bearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+registry.bearerToken)
+	} else if registry.basicAuth {
+		request.SetBasicAuth(registry.username, registry.password)
+	}
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while calling %s: %s", requestUrl, err)
+	}
+	return resp, nil
+}
+
+// Matches the parameters of a WWW-Authenticate challenge, e.g. realm="https://ghcr.io/token"
+var ociChallengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Set how requests are authorized, from the WWW-Authenticate header of a response the registry rejected. Registries
+// either ask for the username and password directly (Basic), or for a token from their token service (Bearer). For
+// more info, see: https://distribution.github.io/distribution/spec/auth/token/
+func (registry *ociRegistry) authorize(ctx context.Context, challenge string) error {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	switch scheme {
+	case "basic":
+		if registry.username == "" {
+			return fmt.Errorf("The registry %s requires a username and password to push to %s.", registry.ref.Registry, registry.ref.Repository)
+		}
+		if registry.basicAuth {
+			return fmt.Errorf("The registry %s rejected the username and password. Check that they can push to %s.", registry.ref.Registry, registry.ref.Repository)
+		}
+		registry.basicAuth = true
+		return nil
+	case "bearer":
+		if registry.bearerToken != "" {
+			return fmt.Errorf("The registry %s denied access to push to %s. Check that the registry username and password can push to it.", registry.ref.Registry, registry.ref.Repository)
+		}
+	default:
+		return fmt.Errorf("The registry %s asked for unsupported authorization \"%s\".", registry.ref.Registry, challenge)
+	}
+
+	params := map[string]string{}
+	for _, match := range ociChallengeParamRegex.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("The registry %s asked for a token without saying where to get one: %s", registry.ref.Registry, challenge)
+	}
+	tokenUrl, err := url.Parse(params["realm"])
+	if err != nil {
+		return fmt.Errorf("The registry %s returned an invalid token URL: %s", registry.ref.Registry, err)
+	}
+	query := tokenUrl.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull,push", registry.ref.Repository))
+	tokenUrl.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+	if registry.username != "" {
+		request.SetBasicAuth(registry.username, registry.password)
+	}
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return fmt.Errorf("Error occurred while getting a token from %s: %s", tokenUrl.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ociRegistryError(resp, tokenUrl.Redacted())
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("Could not parse the token returned by %s: %s", tokenUrl.Redacted(), err)
+	}
+	registry.bearerToken = token.Token
+	if registry.bearerToken == "" {
+		registry.bearerToken = token.AccessToken
+	}
+	if registry.bearerToken == "" {
+		return fmt.Errorf("The token service at %s returned no token.", tokenUrl.Redacted())
+	}
+	return nil
+}
+
+// Return an error describing the given unexpected response from a registry, including the errors in its body, if any
+func ociRegistryError(resp *http.Response, requestUrl string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errs struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &errs) == nil && len(errs.Errors) > 0 {
+		var messages []string
+		for _, e := range errs.Errors {
+			messages = append(messages, fmt.Sprintf("%s: %s", e.Code, e.Message))
+		}
+		return newError(resp.StatusCode, fmt.Sprintf("Received HTTP Response %d from %s: %s", resp.StatusCode, requestUrl, strings.Join(messages, "; ")))
+	}
+	return newError(resp.StatusCode, fmt.Sprintf("Received HTTP Response %d from %s. Full HTTP response: %s", resp.StatusCode, requestUrl, body))
+}
@@ -0,0 +1,160 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOciReference(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		reference string
+		expected  OciReference
+		expectErr bool
+	}{
+		{"ghcr.io/org/tool:v1.0.0", OciReference{"ghcr.io", "org/tool", "v1.0.0"}, false},
+		{"ghcr.io/org/team/tool", OciReference{"ghcr.io", "org/team/tool", ""}, false},
+		{"localhost:5000/tool:latest", OciReference{"localhost:5000", "tool", "latest"}, false},
+		{"localhost/tool", OciReference{"localhost", "tool", ""}, false},
+		{"org/tool:v1.0.0", OciReference{}, true},
+		{"tool", OciReference{}, true},
+		{"ghcr.io/org/tool@sha256:abcd", OciReference{}, true},
+		{"ghcr.io/Org/tool:v1", OciReference{}, true},
+		{"ghcr.io/org/tool:v1+build", OciReference{}, true},
+		{"ghcr.io/org/tool:", OciReference{}, true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.reference, func(t *testing.T) {
+			t.Parallel()
+			ref, err := ParseOciReference(tc.reference)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ref)
+			assert.Equal(t, tc.reference, ref.String())
+		})
+	}
+}
+
+// A registry that implements just enough of the OCI distribution spec to push to, and only accepts requests with a
+// token from its own token service
+type fakeOciRegistry struct {
+	mutex     sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	scopes    []string
+}
+
+func (registry *fakeOciRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if r.URL.Path == "/token" {
+		registry.scopes = append(registry.scopes, r.URL.Query().Get("scope"))
+		fmt.Fprint(w, `{"token": "registry-token"}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer registry-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="fake",scope="repository:org/tool:pull"`, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/org/tool/blobs/sha256:"):
+		if _, ok := registry.blobs[strings.TrimPrefix(r.URL.Path, "/v2/org/tool/blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/org/tool/blobs/uploads/":
+		w.Header().Set("Location", "/v2/org/tool/blobs/uploads/1?state=abc")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && r.URL.Path == "/v2/org/tool/blobs/uploads/1":
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("state") != "abc" || ociDigest(body) != r.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors": [{"code": "DIGEST_INVALID", "message": "digest did not match"}]}`)
+			return
+		}
+		registry.blobs[r.URL.Query().Get("digest")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v2/org/tool/manifests/"):
+		body, _ := ioutil.ReadAll(r.Body)
+		registry.manifests[strings.TrimPrefix(r.URL.Path, "/v2/org/tool/manifests/")] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestOciRegistryPushesArtifactWithRegistryToken(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeOciRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	assetPath := filepath.Join(tempDir, "tool_linux_amd64")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("release asset"), 0644))
+	layers, err := ociLayers([]string{assetPath})
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	assert.Equal(t, ociDigest([]byte("release asset")), layers[0].Digest)
+	assert.Equal(t, "tool_linux_amd64", layers[0].Annotations[ociAnnotationTitle])
+
+	ref := OciReference{Registry: strings.TrimPrefix(server.URL, "http://"), Repository: "org/tool", Tag: "v1.0.0"}
+	registry := newOciRegistry(ref, "", "", true)
+	ctx := context.Background()
+
+	require.NoError(t, registry.pushBlob(ctx, ociEmptyConfigDescriptor(), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(ociEmptyConfig)), nil
+	}))
+	require.NoError(t, registry.pushBlob(ctx, layers[0], func() (io.ReadCloser, error) { return os.Open(assetPath) }))
+	// A blob the registry already has isn't uploaded again
+	require.NoError(t, registry.pushBlob(ctx, layers[0], func() (io.ReadCloser, error) {
+		return nil, fmt.Errorf("the blob should not have been uploaded again")
+	}))
+
+	manifest, err := json.Marshal(newOciManifest(layers, map[string]string{ociAnnotationVersion: "v1.0.0"}))
+	require.NoError(t, err)
+	digest, err := registry.pushManifest(ctx, manifest)
+	require.NoError(t, err)
+	assert.Equal(t, ociDigest(manifest), digest)
+
+	assert.Equal(t, []byte("release asset"), fake.blobs[layers[0].Digest])
+	assert.Equal(t, ociEmptyConfig, fake.blobs[ociEmptyConfigDescriptor().Digest])
+	assert.Equal(t, manifest, fake.manifests["v1.0.0"])
+	assert.Equal(t, []string{"repository:org/tool:pull,push"}, fake.scopes)
+}
+
+func TestOciRegistryErrorIncludesRegistryErrors(t *testing.T) {
+	t.Parallel()
+
+	resp := &http.Response{
+		StatusCode: http.StatusForbidden,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"errors": [{"code": "DENIED", "message": "requested access to the resource is denied"}]}`)),
+	}
+	err := ociRegistryError(resp, "https://ghcr.io/v2/org/tool/manifests/v1.0.0")
+	assert.Contains(t, err.Error(), "DENIED: requested access to the resource is denied")
+}
@@ -0,0 +1,503 @@
+package fetch
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/openpgp"
+)
+
+// The formats of the OS packages whose metadata fetch reads, and whose embedded signatures it can verify
+const (
+	PackageFormatDeb = "deb"
+	PackageFormatRpm = "rpm"
+)
+
+// The metadata of a .deb or .rpm package, as dpkg and rpm report it
+type PackageMetadata struct {
+	Format  string // One of the PackageFormat constants
+	Name    string
+	Version string // The full version, e.g. 1:2.4.1-3 for a .deb, or 1:2.4.1-3.el9 (epoch:version-release) for a .rpm
+	Arch    string
+}
+
+// Return the format of the package at the given path, based on its extension, or an empty string if it isn't a package
+func packageFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".deb":
+		return PackageFormatDeb
+	case ".rpm":
+		return PackageFormatRpm
+	default:
+		return ""
+	}
+}
+
+// Read the metadata of the .deb or .rpm package at the given path. Returns nil if the path isn't a package.
+func ReadPackageMetadata(path string) (*PackageMetadata, error) {
+	format := packageFormat(path)
+	if format == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if format == PackageFormatDeb {
+		deb, err := readDebPackage(file)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read .deb package %s: %s", path, err)
+		}
+		metadata, err := deb.metadata()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read .deb package %s: %s", path, err)
+		}
+		return metadata, nil
+	}
+	rpm, err := readRpmPackage(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read .rpm package %s: %s", path, err)
+	}
+	return rpm.metadata(), nil
+}
+
+// Return an error if the armored PGP public key ring at the given path can't be read
+func ValidatePackageSigningKey(path string) error {
+	_, err := readPackageSigningKey(path)
+	return err
+}
+
+func readPackageSigningKey(path string) (openpgp.EntityList, error) {
+	armoredKeyRing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armoredKeyRing))
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse the PGP public key in %s: %s", path, err)
+	}
+	return keyRing, nil
+}
+
+// Verify that each .deb and .rpm package among the given release assets carries an embedded signature made with a key
+// in the armored PGP public key ring at keyRingPath. Other release assets are skipped.
+func verifyPackageSignatures(logger *logrus.Entry, keyRingPath string, assetPaths []string) error {
+	keyRing, err := readPackageSigningKey(keyRingPath)
+	if err != nil {
+		return err
+	}
+
+	verified := 0
+	for _, assetPath := range assetPaths {
+		format := packageFormat(assetPath)
+		if format == "" {
+			continue
+		}
+		if err := verifyPackageSignature(keyRing, assetPath, format); err != nil {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("The embedded signature of package %s could not be verified: %s", filepath.Base(assetPath), err))
+		}
+		logger.Infof("Verified the embedded signature of package %s\n", filepath.Base(assetPath))
+		verified++
+	}
+	if verified == 0 {
+		logger.Warnf("None of the release assets are .deb or .rpm packages, so there were no embedded package signatures to verify.\n")
+	}
+	return nil
+}
+
+func verifyPackageSignature(keyRing openpgp.EntityList, assetPath string, format string) error {
+	file, err := os.Open(assetPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if format == PackageFormatDeb {
+		deb, err := readDebPackage(file)
+		if err != nil {
+			return err
+		}
+		return deb.verifySignature(keyRing)
+	}
+	rpm, err := readRpmPackage(file)
+	if err != nil {
+		return err
+	}
+	return rpm.verifySignature(keyRing)
+}
+
+// Check the given detached signature of signed, which may be armored, against the given key ring
+func checkPackageSignature(keyRing openpgp.EntityList, signed io.Reader, signature []byte) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, signed, bytes.NewReader(signature))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyRing, signed, bytes.NewReader(signature))
+	}
+	return err
+}
+
+// A .deb package, which is an ar archive of a debian-binary version file, a control tarball, a data tarball, and
+// optionally signatures. For more info, see: https://manpages.debian.org/deb.5
+type debPackage struct {
+	file    io.ReaderAt
+	members []arMember
+}
+
+// A member of an ar archive, whose contents are at the given offset of the archive
+type arMember struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+const arMagic = "!<arch>\n"
+const arHeaderSize = 60
+
+// The ar member that debsigs writes the origin signature of a package to. It's a detached signature of the
+// debian-binary, control, and data members, concatenated in that order.
+const debOriginSignatureMember = "_gpgorigin"
+
+func readDebPackage(file io.ReaderAt) (*debPackage, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := file.ReadAt(magic, 0); err != nil || string(magic) != arMagic {
+		return nil, fmt.Errorf("not an ar archive")
+	}
+
+	deb := &debPackage{file: file}
+	offset := int64(len(arMagic))
+	for {
+		header := make([]byte, arHeaderSize)
+		n, err := file.ReadAt(header, offset)
+		if n == 0 && err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("truncated ar member header at offset %d", offset)
+		}
+		if string(header[58:60]) != "`\n" {
+			return nil, fmt.Errorf("malformed ar member header at offset %d", offset)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("malformed ar member size at offset %d", offset)
+		}
+		// GNU ar ends names with a slash
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		deb.members = append(deb.members, arMember{name: name, offset: offset + arHeaderSize, size: size})
+
+		// Members are aligned to an even offset
+		offset += arHeaderSize + size + size%2
+	}
+
+	if len(deb.members) == 0 || deb.members[0].name != "debian-binary" {
+		return nil, fmt.Errorf("the first member of a .deb package must be debian-binary")
+	}
+	return deb, nil
+}
+
+// Return the member whose name has the given prefix, e.g. control.tar for control.tar.xz
+func (deb *debPackage) member(prefix string) (arMember, bool) {
+	for _, member := range deb.members {
+		if strings.HasPrefix(member.name, prefix) {
+			return member, true
+		}
+	}
+	return arMember{}, false
+}
+
+func (deb *debPackage) reader(member arMember) *io.SectionReader {
+	return io.NewSectionReader(deb.file, member.offset, member.size)
+}
+
+func (deb *debPackage) metadata() (*PackageMetadata, error) {
+	member, ok := deb.member("control.tar")
+	if !ok {
+		return nil, fmt.Errorf("the package has no control.tar member")
+	}
+	control, err := deb.readControlFile(member)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := parseDebControlFields(control)
+	if fields["Package"] == "" || fields["Version"] == "" {
+		return nil, fmt.Errorf("the control file of the package has no Package or Version field")
+	}
+	return &PackageMetadata{Format: PackageFormatDeb, Name: fields["Package"], Version: fields["Version"], Arch: fields["Architecture"]}, nil
+}
+
+// Return the contents of the control file in the given control tarball, which may be uncompressed, or compressed with
+// gzip, xz, or zstd
+func (deb *debPackage) readControlFile(member arMember) ([]byte, error) {
+	var decompressed io.ReadCloser
+	var err error
+	switch strings.TrimPrefix(member.name, "control.tar") {
+	case "":
+		decompressed = ioutil.NopCloser(deb.reader(member))
+	case ".gz":
+		decompressed, err = gzip.NewReader(deb.reader(member))
+	case ".xz":
+		var xzReader *xz.Reader
+		xzReader, err = xz.NewReader(deb.reader(member))
+		decompressed = ioutil.NopCloser(xzReader)
+	case ".zst":
+		decompressed, err = newCommandReader(deb.reader(member), "zstd", "-d", "-c")
+	default:
+		return nil, fmt.Errorf("unsupported control tarball %s", member.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+
+	tarReader := tar.NewReader(decompressed)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the control tarball of the package has no control file")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimPrefix(header.Name, "./") == "control" {
+			return ioutil.ReadAll(io.LimitReader(tarReader, 1024*1024))
+		}
+	}
+}
+
+// Parse the fields of a Debian control file, e.g. "Package: foo". Continuation lines are ignored, as none of the
+// fields fetch reads span several lines.
+func parseDebControlFields(control []byte) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(control))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			fields[line[:i]] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return fields
+}
+
+func (deb *debPackage) verifySignature(keyRing openpgp.EntityList) error {
+	signatureMember, ok := deb.member(debOriginSignatureMember)
+	if !ok {
+		return fmt.Errorf("the package has no %s signature. Packages must be signed with debsigs.", debOriginSignatureMember)
+	}
+	signature, err := ioutil.ReadAll(deb.reader(signatureMember))
+	if err != nil {
+		return err
+	}
+
+	var signed []io.Reader
+	for _, prefix := range []string{"debian-binary", "control.tar", "data.tar"} {
+		member, ok := deb.member(prefix)
+		if !ok {
+			return fmt.Errorf("the package has no %s member", prefix)
+		}
+		signed = append(signed, deb.reader(member))
+	}
+	return checkPackageSignature(keyRing, io.MultiReader(signed...), signature)
+}
+
+// A .rpm package, which is a lead, a signature header, a header, and a compressed payload. For more info, see:
+// https://rpm-software-management.github.io/rpm/manual/format_v4.html
+type rpmPackage struct {
+	file          io.ReaderAt
+	size          int64
+	signatureTags map[int32]rpmTag
+	headerTags    map[int32]rpmTag
+	headerOffset  int64 // Where the header starts, which is where signatures of the header start
+	payloadOffset int64 // Where the header ends and the payload starts
+}
+
+// The raw value of an RPM header tag
+type rpmTag struct {
+	dataType uint32
+	count    uint32
+	data     []byte // The rest of the header's data store, starting at the tag's value
+}
+
+const rpmLeadSize = 96
+const rpmLeadMagic = "\xed\xab\xee\xdb"
+const rpmHeaderMagic = "\x8e\xad\xe8\x01"
+
+// The RPM header tags and data types fetch reads
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagArch    = 1022
+
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeI18nString  = 9
+	rpmTypeStringArray = 8
+)
+
+// The signature header tags of the PGP signatures of the header alone, and of the header and payload together
+var rpmHeaderSignatureTags = []int32{268, 267}             // RSA, DSA
+var rpmHeaderAndPayloadSignatureTags = []int32{1002, 1005} // PGP, GPG
+
+func readRpmPackage(file io.ReaderAt) (*rpmPackage, error) {
+	lead := make([]byte, rpmLeadSize)
+	if _, err := file.ReadAt(lead, 0); err != nil || string(lead[:4]) != rpmLeadMagic {
+		return nil, fmt.Errorf("not an rpm package")
+	}
+
+	signatureTags, signatureEnd, err := readRpmHeader(file, rpmLeadSize)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature header: %s", err)
+	}
+	// The header is aligned to 8 bytes after the signature header
+	headerOffset := signatureEnd + (8-signatureEnd%8)%8
+	headerTags, headerEnd, err := readRpmHeader(file, headerOffset)
+	if err != nil {
+		return nil, fmt.Errorf("malformed header: %s", err)
+	}
+
+	size, err := readerSize(file)
+	if err != nil {
+		return nil, err
+	}
+	return &rpmPackage{file: file, size: size, signatureTags: signatureTags, headerTags: headerTags, headerOffset: headerOffset, payloadOffset: headerEnd}, nil
+}
+
+// Read the RPM header at the given offset, and return its tags and the offset it ends at
+func readRpmHeader(file io.ReaderAt, offset int64) (map[int32]rpmTag, int64, error) {
+	intro := make([]byte, 16)
+	if _, err := file.ReadAt(intro, offset); err != nil {
+		return nil, 0, err
+	}
+	if string(intro[:4]) != rpmHeaderMagic {
+		return nil, 0, fmt.Errorf("bad magic at offset %d", offset)
+	}
+	indexCount := binary.BigEndian.Uint32(intro[8:12])
+	dataSize := binary.BigEndian.Uint32(intro[12:16])
+	// Real headers have at most a few hundred tags and a few megabytes of data
+	if indexCount > 65536 || dataSize > 256*1024*1024 {
+		return nil, 0, fmt.Errorf("header too large")
+	}
+
+	index := make([]byte, 16*int(indexCount))
+	if _, err := file.ReadAt(index, offset+16); err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, dataSize)
+	if _, err := file.ReadAt(data, offset+16+int64(len(index))); err != nil {
+		return nil, 0, err
+	}
+
+	tags := map[int32]rpmTag{}
+	for i := 0; i < int(indexCount); i++ {
+		entry := index[16*i : 16*i+16]
+		tagOffset := binary.BigEndian.Uint32(entry[8:12])
+		if tagOffset > dataSize {
+			return nil, 0, fmt.Errorf("tag value out of bounds")
+		}
+		tags[int32(binary.BigEndian.Uint32(entry[0:4]))] = rpmTag{
+			dataType: binary.BigEndian.Uint32(entry[4:8]),
+			count:    binary.BigEndian.Uint32(entry[12:16]),
+			data:     data[tagOffset:],
+		}
+	}
+	return tags, offset + 16 + int64(len(index)) + int64(dataSize), nil
+}
+
+// Return the value of the given string tag, or the first value of a string array or internationalized string tag
+func (tag rpmTag) string() string {
+	if tag.dataType != rpmTypeString && tag.dataType != rpmTypeI18nString && tag.dataType != rpmTypeStringArray {
+		return ""
+	}
+	if end := bytes.IndexByte(tag.data, 0); end >= 0 {
+		return string(tag.data[:end])
+	}
+	return ""
+}
+
+// Return the value of the given binary tag, such as a signature
+func (tag rpmTag) bytes() []byte {
+	if tag.dataType != rpmTypeBin || int(tag.count) > len(tag.data) {
+		return nil
+	}
+	return tag.data[:tag.count]
+}
+
+func (rpm *rpmPackage) metadata() *PackageMetadata {
+	version := rpm.headerTags[rpmTagVersion].string()
+	if release := rpm.headerTags[rpmTagRelease].string(); release != "" {
+		version += "-" + release
+	}
+	if epoch, ok := rpm.headerTags[rpmTagEpoch]; ok && epoch.dataType == rpmTypeInt32 && len(epoch.data) >= 4 {
+		version = fmt.Sprintf("%d:%s", binary.BigEndian.Uint32(epoch.data[:4]), version)
+	}
+	return &PackageMetadata{
+		Format:  PackageFormatRpm,
+		Name:    rpm.headerTags[rpmTagName].string(),
+		Version: version,
+		Arch:    rpm.headerTags[rpmTagArch].string(),
+	}
+}
+
+// Verify every PGP signature in the signature header. At least one is required.
+func (rpm *rpmPackage) verifySignature(keyRing openpgp.EntityList) error {
+	verified := 0
+	for _, signatureTag := range rpmHeaderSignatureTags {
+		if signature := rpm.signatureTags[signatureTag].bytes(); signature != nil {
+			header := io.NewSectionReader(rpm.file, rpm.headerOffset, rpm.payloadOffset-rpm.headerOffset)
+			if err := checkPackageSignature(keyRing, header, signature); err != nil {
+				return err
+			}
+			verified++
+		}
+	}
+	for _, signatureTag := range rpmHeaderAndPayloadSignatureTags {
+		if signature := rpm.signatureTags[signatureTag].bytes(); signature != nil {
+			headerAndPayload := io.NewSectionReader(rpm.file, rpm.headerOffset, rpm.size-rpm.headerOffset)
+			if err := checkPackageSignature(keyRing, headerAndPayload, signature); err != nil {
+				return err
+			}
+			verified++
+		}
+	}
+	if verified == 0 {
+		return fmt.Errorf("the package has no PGP signature. Packages must be signed with rpmsign.")
+	}
+	return nil
+}
+
+// Return the size of the given file
+func readerSize(file io.ReaderAt) (int64, error) {
+	if stater, ok := file.(interface{ Stat() (os.FileInfo, error) }); ok {
+		info, err := stater.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	if sized, ok := file.(interface{ Size() int64 }); ok {
+		return sized.Size(), nil
+	}
+	return 0, fmt.Errorf("could not determine the size of the package")
+}
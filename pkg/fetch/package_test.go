@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestReadPackageMetadata(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	debPath := filepath.Join(tmpDir, "tool_1.2.0-1_amd64.deb")
+	require.NoError(t, ioutil.WriteFile(debPath, newTestDeb(t, nil), 0644))
+	rpmPath := filepath.Join(tmpDir, "tool-1.2.0-1.el9.x86_64.rpm")
+	require.NoError(t, ioutil.WriteFile(rpmPath, newTestRpm(t, nil), 0644))
+	otherPath := filepath.Join(tmpDir, "tool_linux_amd64.tar.gz")
+	require.NoError(t, ioutil.WriteFile(otherPath, []byte("not a package"), 0644))
+	brokenPath := filepath.Join(tmpDir, "broken.deb")
+	require.NoError(t, ioutil.WriteFile(brokenPath, []byte("not a package"), 0644))
+
+	deb, err := ReadPackageMetadata(debPath)
+	require.NoError(t, err)
+	assert.Equal(t, &PackageMetadata{Format: PackageFormatDeb, Name: "tool", Version: "1:1.2.0-1", Arch: "amd64"}, deb)
+
+	rpm, err := ReadPackageMetadata(rpmPath)
+	require.NoError(t, err)
+	assert.Equal(t, &PackageMetadata{Format: PackageFormatRpm, Name: "tool", Version: "2:1.2.0-1.el9", Arch: "x86_64"}, rpm)
+
+	other, err := ReadPackageMetadata(otherPath)
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	_, err = ReadPackageMetadata(brokenPath)
+	assert.Error(t, err)
+}
+
+func TestVerifyPackageSignatures(t *testing.T) {
+	t.Parallel()
+
+	signer, err := openpgp.NewEntity("fetch", "test", "fetch@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "test", "other@example.com", nil)
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	var armoredKey bytes.Buffer
+	require.NoError(t, writeArmoredPublicKey(&armoredKey, signer))
+	keyPath := filepath.Join(tmpDir, "packages.asc")
+	require.NoError(t, ioutil.WriteFile(keyPath, armoredKey.Bytes(), 0644))
+
+	testCases := []struct {
+		name      string
+		contents  []byte
+		expectErr bool
+	}{
+		{"signed.deb", newTestDeb(t, signer), false},
+		{"unsigned.deb", newTestDeb(t, nil), true},
+		{"signed-by-other.deb", newTestDeb(t, other), true},
+		{"signed.rpm", newTestRpm(t, signer), false},
+		{"unsigned.rpm", newTestRpm(t, nil), true},
+		{"signed-by-other.rpm", newTestRpm(t, other), true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assetPath := filepath.Join(tmpDir, tc.name)
+			require.NoError(t, ioutil.WriteFile(assetPath, tc.contents, 0644))
+			// Release assets that aren't packages are skipped
+			otherAssetPath := filepath.Join(tmpDir, tc.name+".txt")
+			require.NoError(t, ioutil.WriteFile(otherAssetPath, []byte("checksums"), 0644))
+
+			err := verifyPackageSignatures(GetProjectLogger(), keyPath, []string{assetPath, otherAssetPath})
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadDebPackageTamperedData(t *testing.T) {
+	t.Parallel()
+
+	signer, err := openpgp.NewEntity("fetch", "test", "fetch@example.com", nil)
+	require.NoError(t, err)
+
+	contents := newTestDeb(t, signer)
+	deb, err := readDebPackage(bytes.NewReader(contents))
+	require.NoError(t, err)
+	require.NoError(t, deb.verifySignature(openpgp.EntityList{signer}))
+
+	data, ok := deb.member("data.tar")
+	require.True(t, ok)
+	contents[data.offset] ^= 0xff
+	assert.Error(t, deb.verifySignature(openpgp.EntityList{signer}))
+}
+
+// Return a .deb package of a tool, signed with debsigs' _gpgorigin signature by signer, unless it's nil
+func newTestDeb(t *testing.T, signer *openpgp.Entity) []byte {
+	control := newTestTarGz(t, "./control", "Package: tool\nVersion: 1:1.2.0-1\nArchitecture: amd64\nDescription: A tool\n long description\n")
+	data := newTestTarGz(t, "./usr/bin/tool", "#!/bin/sh\necho tool\n")
+	members := []struct {
+		name     string
+		contents []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	}
+	if signer != nil {
+		var signature bytes.Buffer
+		signed := bytes.Join([][]byte{members[0].contents, control, data}, nil)
+		require.NoError(t, openpgp.ArmoredDetachSign(&signature, signer, bytes.NewReader(signed), nil))
+		members = append(members, struct {
+			name     string
+			contents []byte
+		}{debOriginSignatureMember, signature.Bytes()})
+	}
+
+	var deb bytes.Buffer
+	deb.WriteString(arMagic)
+	for _, member := range members {
+		fmt.Fprintf(&deb, "%-16s%-12s%-6s%-6s%-8s%-10d`\n", member.name+"/", "0", "0", "0", "100644", len(member.contents))
+		deb.Write(member.contents)
+		if len(member.contents)%2 == 1 {
+			deb.WriteString("\n")
+		}
+	}
+	return deb.Bytes()
+}
+
+func newTestTarGz(t *testing.T, name string, contents string) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}))
+	_, err := tarWriter.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+// An RPM header tag to write with newTestRpmHeader
+type testRpmTag struct {
+	tag      int32
+	dataType uint32
+	value    []byte
+	count    uint32
+}
+
+// Return a .rpm package of a tool, whose header and payload are signed by signer, unless it's nil
+func newTestRpm(t *testing.T, signer *openpgp.Entity) []byte {
+	epoch := make([]byte, 4)
+	binary.BigEndian.PutUint32(epoch, 2)
+	header := newTestRpmHeader([]testRpmTag{
+		{rpmTagName, rpmTypeString, []byte("tool\x00"), 1},
+		{rpmTagVersion, rpmTypeString, []byte("1.2.0\x00"), 1},
+		{rpmTagRelease, rpmTypeString, []byte("1.el9\x00"), 1},
+		{rpmTagEpoch, rpmTypeInt32, epoch, 1},
+		{rpmTagArch, rpmTypeString, []byte("x86_64\x00"), 1},
+	})
+	payload := []byte("compressed cpio payload")
+
+	var signatureTags []testRpmTag
+	if signer != nil {
+		var headerSignature, headerAndPayloadSignature bytes.Buffer
+		require.NoError(t, openpgp.DetachSign(&headerSignature, signer, bytes.NewReader(header), nil))
+		require.NoError(t, openpgp.DetachSign(&headerAndPayloadSignature, signer, bytes.NewReader(append(append([]byte{}, header...), payload...)), nil))
+		signatureTags = []testRpmTag{
+			{268, rpmTypeBin, headerSignature.Bytes(), uint32(headerSignature.Len())},
+			{1002, rpmTypeBin, headerAndPayloadSignature.Bytes(), uint32(headerAndPayloadSignature.Len())},
+		}
+	}
+	signatureHeader := newTestRpmHeader(signatureTags)
+
+	var rpm bytes.Buffer
+	lead := make([]byte, rpmLeadSize)
+	copy(lead, rpmLeadMagic)
+	rpm.Write(lead)
+	rpm.Write(signatureHeader)
+	rpm.Write(make([]byte, (8-len(signatureHeader)%8)%8))
+	rpm.Write(header)
+	rpm.Write(payload)
+	return rpm.Bytes()
+}
+
+func newTestRpmHeader(tags []testRpmTag) []byte {
+	var index, data bytes.Buffer
+	for _, tag := range tags {
+		binary.Write(&index, binary.BigEndian, tag.tag)
+		binary.Write(&index, binary.BigEndian, tag.dataType)
+		binary.Write(&index, binary.BigEndian, uint32(data.Len()))
+		binary.Write(&index, binary.BigEndian, tag.count)
+		data.Write(tag.value)
+	}
+
+	var header bytes.Buffer
+	header.WriteString(rpmHeaderMagic)
+	header.Write(make([]byte, 4))
+	binary.Write(&header, binary.BigEndian, uint32(len(tags)))
+	binary.Write(&header, binary.BigEndian, uint32(data.Len()))
+	header.Write(index.Bytes())
+	header.Write(data.Bytes())
+	return header.Bytes()
+}
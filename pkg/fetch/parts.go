@@ -0,0 +1,304 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The ways release assets are commonly split into parts, each of which captures the name of the joined asset and the
+// number of the part: tool.tar.gz.part1 (or .part-1), tool.tar.gz.001 (as written by split -d and 7-Zip), and the
+// .z01, .z02, ... parts of a split zip file, whose final part is tool.zip itself.
+var assetPartRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`^(.+)\.part-?([0-9]+)$`),
+	regexp.MustCompile(`^(.+)\.([0-9]{3})$`),
+}
+var splitZipPartRegex = regexp.MustCompile(`^(.+)\.[zZ]([0-9]{2,})$`)
+
+// A release asset that was split into parts
+type assetParts struct {
+	name      string         // The name of the joined asset
+	splitZip  bool           // True if the parts are those of a split zip file, rather than plain chunks of bytes
+	pathsByNo map[int]string // The paths of the downloaded parts, by part number
+}
+
+// Join the parts of any multi-part release assets among the given downloaded assets into a single file each, in the
+// same directory, and delete the parts. Returns the paths of the joined assets, along with those of the assets that
+// weren't split.
+func joinReleaseAssetParts(logger *logrus.Entry, assetPaths []string) ([]string, error) {
+	partsByName := map[string]*assetParts{}
+	var joinedPaths []string
+
+	for _, assetPath := range assetPaths {
+		parts, partNo := findAssetParts(partsByName, assetPath)
+		if parts == nil {
+			joinedPaths = append(joinedPaths, assetPath)
+			continue
+		}
+		parts.pathsByNo[partNo] = assetPath
+	}
+
+	// The final part of a split zip file looks like any other zip file, so pick it out now that every part is known
+	var remainingPaths []string
+	for _, assetPath := range joinedPaths {
+		name := filepath.Base(assetPath)
+		if parts, ok := partsByName[filepath.Join(filepath.Dir(assetPath), name)]; ok && parts.splitZip {
+			// The final part comes after the highest numbered .zNN part
+			parts.pathsByNo[parts.lastPartNo()+1] = assetPath
+			continue
+		}
+		remainingPaths = append(remainingPaths, assetPath)
+	}
+	joinedPaths = remainingPaths
+
+	var names []string
+	for name := range partsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parts := partsByName[name]
+		partPaths, err := parts.orderedPaths()
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Infof("Joining %d parts into %s ...\n", len(partPaths), name)
+		if err := joinFiles(partPaths, name, parts.splitZip); err != nil {
+			return nil, fmt.Errorf("Error occurred while joining the parts of %s: %s", filepath.Base(name), err)
+		}
+		for _, partPath := range partPaths {
+			// The final part of a split zip file has already been replaced by the joined file
+			if partPath == name {
+				continue
+			}
+			if err := os.Remove(partPath); err != nil {
+				return nil, err
+			}
+		}
+		joinedPaths = append(joinedPaths, name)
+	}
+	return joinedPaths, nil
+}
+
+// If the asset at the given path is a part of a multi-part asset, return the assetParts it belongs to (adding it to
+// partsByName if it's the first part seen) along with its part number
+func findAssetParts(partsByName map[string]*assetParts, assetPath string) (*assetParts, int) {
+	fileName := filepath.Base(assetPath)
+
+	splitZip := false
+	var match []string
+	if match = splitZipPartRegex.FindStringSubmatch(fileName); match != nil {
+		splitZip = true
+		match[1] += ".zip"
+	} else {
+		for _, regex := range assetPartRegexes {
+			if match = regex.FindStringSubmatch(fileName); match != nil {
+				break
+			}
+		}
+	}
+	if match == nil {
+		return nil, 0
+	}
+
+	partNo, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil, 0
+	}
+
+	name := filepath.Join(filepath.Dir(assetPath), match[1])
+	parts, ok := partsByName[name]
+	if !ok {
+		parts = &assetParts{name: name, splitZip: splitZip, pathsByNo: map[int]string{}}
+		partsByName[name] = parts
+	}
+	return parts, partNo
+}
+
+// Return the highest part number among the downloaded parts
+func (parts *assetParts) lastPartNo() int {
+	last := 0
+	for partNo := range parts.pathsByNo {
+		if partNo > last {
+			last = partNo
+		}
+	}
+	return last
+}
+
+// Return the paths of the parts in order, or an error if any part is missing
+func (parts *assetParts) orderedPaths() ([]string, error) {
+	var partNos []int
+	for partNo := range parts.pathsByNo {
+		partNos = append(partNos, partNo)
+	}
+	sort.Ints(partNos)
+
+	// Parts are numbered from either 0 or 1
+	first := 1
+	if partNos[0] == 0 {
+		first = 0
+	}
+	var paths []string
+	for i, partNo := range partNos {
+		if partNo != first+i {
+			return nil, fmt.Errorf("Part %d of %s was not downloaded. Make sure --release-asset matches every part.", first+i, filepath.Base(parts.name))
+		}
+		paths = append(paths, parts.pathsByNo[partNo])
+	}
+	if parts.splitZip && filepath.Base(paths[len(paths)-1]) != filepath.Base(parts.name) {
+		return nil, fmt.Errorf("The final part of %s, %s itself, was not downloaded. Make sure --release-asset matches every part.", filepath.Base(parts.name), filepath.Base(parts.name))
+	}
+	return paths, nil
+}
+
+// Concatenate the files at the given paths into destPath. If splitZip is true, the files are the parts of a split zip
+// file, so its central directory is then rewritten into that of a single zip file. The parts are joined in a temporary
+// file that's then renamed to destPath, as destPath may be one of the parts.
+func joinFiles(partPaths []string, destPath string, splitZip bool) error {
+	out, err := ioutil.TempFile(filepath.Dir(destPath), filepath.Base(destPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	var partOffsets []int64
+	var offset int64
+	for _, partPath := range partPaths {
+		partOffsets = append(partOffsets, offset)
+		written, err := appendFile(out, partPath)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		offset += written
+	}
+
+	if splitZip {
+		if err := unsplitZip(out, offset, partOffsets); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), destPath)
+}
+
+func appendFile(out io.Writer, path string) (int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	return io.Copy(out, in)
+}
+
+// Signatures and sizes of the zip records that unsplitZip rewrites
+const (
+	zipEndOfCentralDirSignature  = 0x06054b50
+	zipEndOfCentralDirSize       = 22
+	zipCentralDirHeaderSignature = 0x02014b50
+	zipCentralDirHeaderSize      = 46
+)
+
+// Rewrite the central directory of the split zip file whose parts were concatenated into file, which is size bytes
+// long, into that of a single zip file. In a split zip, each entry is located by the number of the part (or "disk")
+// it's in and its offset within that part, so every offset is rebased onto the start of the part it's relative to,
+// using partOffsets, and every disk number is set to 0.
+func unsplitZip(file *os.File, size int64, partOffsets []int64) error {
+	// The end of central directory record is at the very end of the file, unless the archive has a comment
+	tailSize := int64(zipEndOfCentralDirSize + 0xffff)
+	if tailSize > size {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := file.ReadAt(tail, size-tailSize); err != nil {
+		return err
+	}
+	endOffset := -1
+	for i := len(tail) - zipEndOfCentralDirSize; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:]) == zipEndOfCentralDirSignature {
+			endOffset = i
+			break
+		}
+	}
+	if endOffset < 0 {
+		return fmt.Errorf("the final part is not a zip file")
+	}
+	end := tail[endOffset : endOffset+zipEndOfCentralDirSize]
+
+	partOffset := func(disk uint16) (int64, error) {
+		if int(disk) >= len(partOffsets) {
+			return 0, fmt.Errorf("the zip file refers to part %d, but only %d parts were joined", int(disk)+1, len(partOffsets))
+		}
+		return partOffsets[disk], nil
+	}
+
+	entryCount := binary.LittleEndian.Uint16(end[10:])
+	dirSize := binary.LittleEndian.Uint32(end[12:])
+	dirOffset := binary.LittleEndian.Uint32(end[16:])
+	if entryCount == 0xffff || dirSize == 0xffffffff || dirOffset == 0xffffffff {
+		return fmt.Errorf("split zip64 files are not supported")
+	}
+	dirPartOffset, err := partOffset(binary.LittleEndian.Uint16(end[6:]))
+	if err != nil {
+		return err
+	}
+	dirStart := dirPartOffset + int64(dirOffset)
+
+	dir := make([]byte, dirSize)
+	if _, err := file.ReadAt(dir, dirStart); err != nil {
+		return err
+	}
+	for pos, i := 0, 0; i < int(entryCount); i++ {
+		if pos+zipCentralDirHeaderSize > len(dir) || binary.LittleEndian.Uint32(dir[pos:]) != zipCentralDirHeaderSignature {
+			return fmt.Errorf("the central directory is corrupt")
+		}
+		header := dir[pos : pos+zipCentralDirHeaderSize]
+
+		localOffset := binary.LittleEndian.Uint32(header[42:])
+		if localOffset == 0xffffffff {
+			return fmt.Errorf("split zip64 files are not supported")
+		}
+		entryPartOffset, err := partOffset(binary.LittleEndian.Uint16(header[34:]))
+		if err != nil {
+			return err
+		}
+		rebased := entryPartOffset + int64(localOffset)
+		if rebased > 0xffffffff {
+			return fmt.Errorf("split zip files larger than 4GB are not supported")
+		}
+		binary.LittleEndian.PutUint16(header[34:], 0)
+		binary.LittleEndian.PutUint32(header[42:], uint32(rebased))
+
+		pos += zipCentralDirHeaderSize + int(binary.LittleEndian.Uint16(header[28:])) + int(binary.LittleEndian.Uint16(header[30:])) + int(binary.LittleEndian.Uint16(header[32:]))
+	}
+	if _, err := file.WriteAt(dir, dirStart); err != nil {
+		return err
+	}
+
+	if dirStart > 0xffffffff {
+		return fmt.Errorf("split zip files larger than 4GB are not supported")
+	}
+	binary.LittleEndian.PutUint16(end[4:], 0)
+	binary.LittleEndian.PutUint16(end[6:], 0)
+	binary.LittleEndian.PutUint16(end[8:], entryCount)
+	binary.LittleEndian.PutUint32(end[16:], uint32(dirStart))
+	_, err = file.WriteAt(end, size-tailSize+int64(endOffset))
+	return err
+}
@@ -0,0 +1,109 @@
+package fetch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinReleaseAssetParts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"tool.tar.gz.part1": "one-",
+		"tool.tar.gz.part2": "two-",
+		"tool.tar.gz.part3": "three",
+		"data.bin.001":      "first-",
+		"data.bin.002":      "second",
+		"checksums.txt":     "checksums",
+	}
+	var assetPaths []string
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		assetPaths = append(assetPaths, path)
+	}
+
+	joinedPaths, err := joinReleaseAssetParts(GetProjectLogger(), assetPaths)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "checksums.txt"), filepath.Join(dir, "data.bin"), filepath.Join(dir, "tool.tar.gz")}, joinedPaths)
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "tool.tar.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, "one-two-three", string(contents))
+
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "first-second", string(contents))
+
+	// The parts are deleted once joined
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestJoinReleaseAssetPartsMissingPart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var assetPaths []string
+	for _, name := range []string{"tool.part1", "tool.part3"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(name), 0644))
+		assetPaths = append(assetPaths, path)
+	}
+
+	_, err := joinReleaseAssetParts(GetProjectLogger(), assetPaths)
+	assert.EqualError(t, err, "Part 2 of tool was not downloaded. Make sure --release-asset matches every part.")
+}
+
+func TestJoinReleaseAssetPartsSplitZip(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("zip"); err != nil {
+		t.Skip("The zip command is not installed")
+	}
+
+	// Random data doesn't compress, so the archive is big enough to be split into several 64KB parts
+	srcDir := t.TempDir()
+	random := rand.New(rand.NewSource(1))
+	toolContents := make([]byte, 150*1024)
+	random.Read(toolContents)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, "tool"), toolContents, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, "README.md"), []byte("readme"), 0644))
+
+	assetDir := t.TempDir()
+	cmd := exec.Command("zip", "-q", "-s", "64k", filepath.Join(assetDir, "tool.zip"), "tool", "README.md")
+	cmd.Dir = srcDir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	entries, err := os.ReadDir(assetDir)
+	require.NoError(t, err)
+	require.Greater(t, len(entries), 2)
+	var assetPaths []string
+	for _, entry := range entries {
+		assetPaths = append(assetPaths, filepath.Join(assetDir, entry.Name()))
+	}
+
+	joinedPaths, err := joinReleaseAssetParts(GetProjectLogger(), assetPaths)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(assetDir, "tool.zip")}, joinedPaths)
+
+	destDir := t.TempDir()
+	fileCount, err := unpackArchive(joinedPaths[0], destDir, ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fileCount)
+
+	unpacked, err := ioutil.ReadFile(filepath.Join(destDir, "tool"))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(toolContents, unpacked))
+}
@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// What a fetch would download, and where to, as worked out by Plan without downloading anything
+type FetchPlan struct {
+	Tag           string            `json:"tag,omitempty"`
+	TagCommitSha  string            `json:"tagCommitSha,omitempty"`
+	CommitSha     string            `json:"commitSha,omitempty"` // The full SHA of the commit, looked up with the ResolveRef option
+	Ref           string            `json:"ref,omitempty"`       // The git reference source paths and files are downloaded from
+	SourcePaths   []PlannedDownload `json:"sourcePaths,omitempty"`
+	SourceFiles   []PlannedDownload `json:"sourceFiles,omitempty"`
+	ReleaseAssets []PlannedDownload `json:"releaseAssets,omitempty"`
+}
+
+// A single download in a FetchPlan
+type PlannedDownload struct {
+	Name        string `json:"name"`           // The source path, source file, or release asset name
+	Url         string `json:"url"`            // The URL it's downloaded from
+	Size        int64  `json:"size,omitempty"` // The size in bytes, if known before downloading (only for release assets)
+	Destination string `json:"destination"`    // The local path it's written to, or StdoutDownloadPath
+}
+
+// Resolve the tag, look up the release, and match its assets just as Fetch would, and return what Fetch would then
+// download, without downloading or writing anything. Useful for debugging tag constraints and release asset regexes.
+func (fetcher *Fetcher) Plan(ctx context.Context) (*FetchPlan, error) {
+	ctx = fetcher.withConnection(ctx)
+	options := fetcher.options
+	if options.Url != "" {
+		return fetcher.planUrl()
+	}
+	if releases := fetcher.releaseStore(); releases != nil {
+		return fetcher.planReleaseStore(ctx, releases)
+	}
+	if fetcher.goModule != nil {
+		return fetcher.planGoModule(ctx)
+	}
+
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &FetchPlan{Tag: resolvedTag.Tag, TagCommitSha: resolvedTag.CommitSha}
+	if options.pinsCommit() {
+		commitSha, err := fetcher.resolveCommitSha(ctx, resolvedTag)
+		if err != nil {
+			return nil, err
+		}
+		if options.ResolveRef {
+			plan.CommitSha = commitSha
+		}
+	}
+
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	sourcePaths := options.sourcePaths()
+	if len(sourcePaths) > 0 || len(options.SourceFiles) > 0 {
+		gitHubCommit := fetcher.gitHubCommit(resolvedTag.Tag)
+		plan.Ref = gitHubCommit.ref()
+
+		contentsApi := usesContentsApi(options.DownloadStrategy, sourcePaths, extractOptions)
+		for _, sourcePath := range sourcePaths {
+			url := formatUrl(fetcher.repo, repoFileApiPath(fetcher.repo, plan.Ref, sourcePath))
+			if fetcher.bitbucket != nil {
+				if url, err = fetcher.bitbucket.archiveUrl(gitHubCommit); err != nil {
+					return nil, err
+				}
+			} else if fetcher.codeCommit != nil {
+				url = fetcher.codeCommit.cloneUrl()
+			} else if !contentsApi {
+				request, err := MakeGitHubZipFileRequest(gitHubCommit, fetcher.repo.Token, fetcher.instance)
+				if err != nil {
+					return nil, err
+				}
+				url = request.URL.String()
+			}
+			plan.SourcePaths = append(plan.SourcePaths, PlannedDownload{Name: sourcePath, Url: url, Destination: options.LocalDownloadPath})
+		}
+
+		for _, sourceFile := range options.SourceFiles {
+			name := renamePath(path.Base(strings.Trim(sourceFile, "/")), extractOptions.Renames)
+			plan.SourceFiles = append(plan.SourceFiles, PlannedDownload{
+				Name:        sourceFile,
+				Url:         formatUrl(fetcher.repo, repoFileApiPath(fetcher.repo, plan.Ref, sourceFile)),
+				Destination: filepath.Join(options.LocalDownloadPath, filepath.FromSlash(name)),
+			})
+		}
+	}
+
+	matcher, err := fetcher.releaseAssetMatcher(resolvedTag.Tag)
+	if err != nil {
+		return nil, err
+	}
+	if matcher.selectsAssets() {
+		release, fetchErr := GetGitHubReleaseInfo(ctx, fetcher.repo, resolvedTag.Tag)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		assets, err := matcher.match(fetcher.logger, release, resolvedTag.Tag)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			destination := options.LocalDownloadPath
+			if destination != StdoutDownloadPath {
+				destination = filepath.Join(destination, filepath.FromSlash(renamePath(asset.Name, extractOptions.Renames)))
+			}
+			plan.ReleaseAssets = append(plan.ReleaseAssets, PlannedDownload{
+				Name:        asset.Name,
+				Url:         releaseAssetUrl(fetcher.repo, asset),
+				Size:        asset.Size,
+				Destination: destination,
+			})
+		}
+	}
+
+	return plan, nil
+}
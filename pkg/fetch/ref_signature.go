@@ -0,0 +1,139 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// GitHub's verification of the signature of a git tag or commit object. Modeled directly after the api.github.com
+// response (but only includes the fields we care about). For more info, see:
+// https://docs.github.com/en/rest/git/commits#signature-verification-object
+type SignatureVerification struct {
+	Verified  bool
+	Reason    string // Why the signature is or isn't verified, e.g. valid, unsigned, or unknown_key
+	Signature string // The armored signature, if the object is signed
+	Payload   string // The contents of the object that were signed
+}
+
+// Return true if the object has a signature that GitHub verified, or with keyRing set, a PGP signature made with one of
+// its keys, whatever GitHub made of it
+func (verification *SignatureVerification) isVerified(keyRing openpgp.EntityList) bool {
+	if verification == nil {
+		return false
+	}
+	if keyRing == nil {
+		return verification.Verified
+	}
+	return verification.Signature != "" && checkPackageSignature(keyRing, strings.NewReader(verification.Payload), []byte(verification.Signature)) == nil
+}
+
+// A git tag or commit object, as returned by the git database API. For more info, see:
+// https://docs.github.com/en/rest/git/tags#get-a-tag and https://docs.github.com/en/rest/git/commits#get-a-commit-object
+type gitHubGitObject struct {
+	Sha          string
+	Verification SignatureVerification
+}
+
+// A git reference, as returned by the git database API. For more info, see:
+// https://docs.github.com/en/rest/git/refs#get-a-reference
+type gitHubGitRef struct {
+	Object struct {
+		Type string // tag for an annotated tag, or commit for a lightweight tag
+		Sha  string
+	}
+}
+
+// The signatures of a tag, and of the commit it points to
+type RefSignatures struct {
+	// Only set if the tag is an annotated tag, as a lightweight tag has no object of its own to sign
+	TagSignature *SignatureVerification
+
+	CommitSignature SignatureVerification
+}
+
+// Explain why neither the given tag nor the given commit has a signature that passes isVerified
+func (signatures RefSignatures) describeUnverified(tag string, commitSha string, keyRingPath string) string {
+	why := func(verification *SignatureVerification) string {
+		switch {
+		case keyRingPath == "":
+			return "GitHub's verdict: " + verification.Reason
+		case verification.Signature == "":
+			return "unsigned"
+		default:
+			return "not a PGP signature made with a key in " + keyRingPath
+		}
+	}
+
+	message := fmt.Sprintf("Commit %s has no verified signature (%s)", commitSha, why(&signatures.CommitSignature))
+	switch {
+	case tag == "":
+		return message + "."
+	case signatures.TagSignature == nil:
+		return fmt.Sprintf("%s, and tag \"%s\" is a lightweight tag, which can't be signed.", message, tag)
+	default:
+		return fmt.Sprintf("%s, and neither does tag \"%s\" (%s).", message, tag, why(signatures.TagSignature))
+	}
+}
+
+// Check that the given tag, or the commit with the given SHA that's downloaded from, has a signature GitHub verified,
+// or with the SignedRefKey option, a signature made with that key, for the RequireSignedRef option. The tag is only
+// checked if it's what's downloaded from, rather than the commit or branch in the options.
+func (fetcher *Fetcher) checkRefSignatures(ctx context.Context, tag string, commitSha string) error {
+	if fetcher.options.CommitSha != "" || fetcher.options.BranchName != "" {
+		tag = ""
+	}
+	var keyRing openpgp.EntityList
+	if fetcher.options.SignedRefKey != "" {
+		var err error
+		if keyRing, err = readPackageSigningKey(fetcher.options.SignedRefKey); err != nil {
+			return err
+		}
+	}
+
+	signatures, fetchErr := CheckRefSignatures(ctx, fetcher.repo, tag, commitSha)
+	if fetchErr != nil {
+		return fmt.Errorf("Error occurred while checking the signatures of commit %s: %s", commitSha, fetchErr)
+	}
+
+	switch {
+	case signatures.TagSignature.isVerified(keyRing):
+		fetcher.logger.Infof("Tag \"%s\" has a verified signature\n", tag)
+	case signatures.CommitSignature.isVerified(keyRing):
+		fetcher.logger.Infof("Commit %s has a verified signature\n", commitSha)
+	default:
+		return newError(signatureDoesNotMatch, fmt.Sprintf("%s Drop --require-signed-ref to fetch it anyway.", signatures.describeUnverified(tag, commitSha, fetcher.options.SignedRefKey)))
+	}
+	return nil
+}
+
+// Get GitHub's verification of the signatures of the given tag and of the commit with the given SHA. If tag is empty,
+// or isn't a tag in the repo (e.g. because it's a branch), only the commit's signature is returned.
+func CheckRefSignatures(ctx context.Context, repo GitHubRepo, tag string, commitSha string) (RefSignatures, *FetchError) {
+	signatures := RefSignatures{}
+
+	if tag != "" {
+		var ref gitHubGitRef
+		fetchErr := getGitHubJson(ctx, repo, createGitHubRepoUrlForPath(repo, "git/ref/tags/"+tag), &ref)
+		if fetchErr != nil && fetchErr.errorCode != repoDoesNotExistOrAccessDenied {
+			return signatures, fetchErr
+		}
+		if fetchErr == nil && ref.Object.Type == "tag" {
+			var tagObject gitHubGitObject
+			if fetchErr := getGitHubJson(ctx, repo, createGitHubRepoUrlForPath(repo, "git/tags/"+ref.Object.Sha), &tagObject); fetchErr != nil {
+				return signatures, fetchErr
+			}
+			signatures.TagSignature = &tagObject.Verification
+		}
+	}
+
+	var commit gitHubGitObject
+	if fetchErr := getGitHubJson(ctx, repo, createGitHubRepoUrlForPath(repo, "git/commits/"+commitSha), &commit); fetchErr != nil {
+		return signatures, fetchErr
+	}
+	signatures.CommitSignature = commit.Verification
+
+	return signatures, nil
+}
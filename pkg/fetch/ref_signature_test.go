@@ -0,0 +1,123 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestRequireSignedRef(t *testing.T) {
+	t.Parallel()
+
+	const (
+		unsignedCommit = "1111111111111111111111111111111111111111"
+		signedCommit   = "2222222222222222222222222222222222222222"
+		otherCommit    = "3333333333333333333333333333333333333333"
+	)
+
+	signer, err := openpgp.NewEntity("fetch", "test", "fetch@example.com", nil)
+	require.NoError(t, err)
+	var armoredKey bytes.Buffer
+	require.NoError(t, writeArmoredPublicKey(&armoredKey, signer))
+	keyPath := filepath.Join(t.TempDir(), "signing-key.asc")
+	require.NoError(t, ioutil.WriteFile(keyPath, armoredKey.Bytes(), 0644))
+
+	// A tag signed with a key GitHub doesn't know, so it can only be verified locally
+	const locallySignedPayload = "object 1111111111111111111111111111111111111111\ntype commit\ntag v1.2.0\n"
+	var locallySignedSignature bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&locallySignedSignature, signer, strings.NewReader(locallySignedPayload), nil))
+
+	// The commit each ref points to, and the signature GitHub reports for each annotated tag (by tag) and commit
+	commits := map[string]string{"v1.0.0": unsignedCommit, "v1.1.0": signedCommit, "v1.2.0": unsignedCommit, "v1.3.0": otherCommit, "main": signedCommit}
+	tagSignatures := map[string]SignatureVerification{
+		"v1.0.0": {Verified: true, Reason: "valid", Signature: "-----BEGIN SSH SIGNATURE-----", Payload: "tag v1.0.0"},
+		"v1.2.0": {Verified: false, Reason: "unknown_key", Signature: locallySignedSignature.String(), Payload: locallySignedPayload},
+	}
+	commitSignatures := map[string]SignatureVerification{
+		unsignedCommit: {Verified: false, Reason: "unsigned"},
+		signedCommit:   {Verified: true, Reason: "valid", Signature: "-----BEGIN PGP SIGNATURE-----", Payload: "tree"},
+		otherCommit:    {Verified: false, Reason: "unsigned"},
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/foo/bar/")
+		switch {
+		case path == "tags":
+			var tags []string
+			for _, tag := range []string{"v1.3.0", "v1.2.0", "v1.1.0", "v1.0.0"} {
+				tags = append(tags, fmt.Sprintf(`{"name": "%s", "commit": {"sha": "%s"}}`, tag, commits[tag]))
+			}
+			w.Write([]byte("[" + strings.Join(tags, ",") + "]"))
+		case strings.HasPrefix(path, "commits/"):
+			w.Write([]byte(commits[strings.TrimPrefix(path, "commits/")]))
+		case strings.HasPrefix(path, "git/ref/tags/"):
+			tag := strings.TrimPrefix(path, "git/ref/tags/")
+			objectType, objectSha := "commit", commits[tag]
+			if _, annotated := tagSignatures[tag]; annotated {
+				objectType, objectSha = "tag", "tag-"+tag
+			}
+			fmt.Fprintf(w, `{"ref": "refs/tags/%s", "object": {"type": "%s", "sha": "%s"}}`, tag, objectType, objectSha)
+		case strings.HasPrefix(path, "git/tags/tag-"):
+			json.NewEncoder(w).Encode(gitHubGitObject{Sha: path, Verification: tagSignatures[strings.TrimPrefix(path, "git/tags/tag-")]})
+		case strings.HasPrefix(path, "git/commits/"):
+			sha := strings.TrimPrefix(path, "git/commits/")
+			json.NewEncoder(w).Encode(gitHubGitObject{Sha: sha, Verification: commitSignatures[sha]})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	caCert := writeTestServerCaCert(t, server)
+
+	testCases := []struct {
+		name        string
+		tag         string
+		branch      string
+		key         string
+		expectError bool
+	}{
+		{"signed-tag", "v1.0.0", "", "", false},
+		{"signed-commit", "v1.1.0", "", "", false},
+		{"unverified-tag-and-commit", "v1.2.0", "", "", true},
+		{"lightweight-tag-unsigned-commit", "v1.3.0", "", "", true},
+		{"branch", "", "main", "", false},
+		{"local-key", "v1.2.0", "", keyPath, false},
+		{"local-key-other-signer", "v1.0.0", "", keyPath, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:           server.URL + "/foo/bar",
+				GithubApiVersion:  "v3",
+				TagConstraint:     tc.tag,
+				BranchName:        tc.branch,
+				RequireSignedRef:  true,
+				SignedRefKey:      tc.key,
+				SourcePaths:       []string{"/modules"},
+				LocalDownloadPath: t.TempDir(),
+				Connection:        ConnectionOptions{CaCert: caCert},
+			})
+			require.NoError(t, err)
+			plan, err := fetcher.Plan(context.Background())
+			if tc.expectError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "Drop --require-signed-ref")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, commits[tc.tag+tc.branch], plan.Ref)
+		})
+	}
+}
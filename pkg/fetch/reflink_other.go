@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fetch
+
+import (
+	"errors"
+	"os"
+)
+
+// Reflinks are only supported on Linux, so this always returns an error, and the caller falls back to a regular copy
+func cloneFile(src *os.File, dest *os.File) error {
+	return errors.New("reflinks are not supported on this platform")
+}
@@ -0,0 +1,192 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A place other than a GitHub repo that releases are published to, laid out as a folder of tags, each of which holds
+// the release assets of that tag, such as a BucketMirror, an ArtifactRepository, or a GitlabPackageRegistry. Only release assets can be fetched
+// from one, and they're verified and used just as those of a GitHub release are.
+type releaseStore interface {
+	// A description of the store for messages, e.g. "mirror s3://mirror/terraform"
+	description() string
+
+	// Return the tags of the store that are valid versions
+	listTags(ctx context.Context, looseSemver bool) ([]string, error)
+
+	// Return the release assets of the given tag as a release, so that they can be matched as those of a GitHub release
+	// are
+	release(ctx context.Context, tag string) (GitHubReleaseApiResponse, error)
+
+	// Return the URL of the release asset with the given name in the release with the given tag
+	assetUrl(tag string, name string) string
+
+	// Download the release asset with the given name in the release with the given tag to destPath. With noCache set,
+	// caches along the way are asked not to serve a stored copy, as downloadUrl does.
+	downloadAsset(ctx context.Context, tag string, name string, destPath string, withProgress bool, noCache bool) *FetchError
+}
+
+// Return the release store the Fetcher's repo is, or nil if it isn't one
+func (fetcher *Fetcher) releaseStore() releaseStore {
+	switch {
+	case fetcher.mirror != nil:
+		return fetcher.mirror
+	case fetcher.artifactRepo != nil:
+		return fetcher.artifactRepo
+	case fetcher.gitlabPackage != nil:
+		return fetcher.gitlabPackage
+	default:
+		return nil
+	}
+}
+
+// Download the release assets in the Fetcher's options from the given release store, and verify, publish, and use
+// them, as Fetch does for a GitHub release
+func (fetcher *Fetcher) fetchFromReleaseStore(ctx context.Context, writer io.Writer, releases releaseStore) (*Result, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+
+	start := time.Now()
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tag := resolvedTag.Tag
+	result := &Result{Tag: tag}
+	result.Timings.Resolve = time.Since(start)
+
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+	assets, err := fetcher.matchReleaseStoreAssets(ctx, releases, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(options.LocalDownloadPath, 0755); err != nil {
+		return nil, err
+	}
+	store := fetcher.assetStore
+	if store == nil && options.ArchiveCacheDir != "" {
+		store = openAssetStore(options.ArchiveCacheDir, options.LinkMode)
+	}
+
+	// Assets are downloaded by name, so that those whose checksums don't match can be downloaded again
+	downloadStart := time.Now()
+	download := func(assetPath string, noCache bool) *FetchError {
+		return releases.downloadAsset(ctx, tag, filepath.Base(assetPath), assetPath, options.WithProgress, noCache)
+	}
+	keyOf := func(assetPath string) string {
+		return releases.assetUrl(tag, filepath.Base(assetPath))
+	}
+	destRoot := newDestRoot(options.LocalDownloadPath, options.FollowDestSymlinks)
+	var assetPaths []string
+	for _, asset := range assets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		assetPath, err := destRoot.filePath(asset.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := placeOrDownloadArtifact(logger, store, keyOf(assetPath), assetPath, download); err != nil {
+			return nil, err
+		}
+		assetPaths = append(assetPaths, assetPath)
+	}
+	if options.JoinParts {
+		if assetPaths, err = joinReleaseAssetParts(logger, assetPaths); err != nil {
+			return nil, err
+		}
+	}
+	result.Timings.Download = time.Since(downloadStart)
+
+	var checksums map[string]string
+	if options.ReleaseAssetChecksumFile != "" {
+		if checksums, err = loadReleaseStoreChecksumFile(ctx, releases, tag, options.ReleaseAssetChecksumFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := fetcher.useArtifacts(ctx, writer, result, assetPaths, checksums, artifactRedownloader(store, keyOf, download), fetcher.repo.Name, extractOptions); err != nil {
+		return nil, err
+	}
+
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
+
+// Return the release assets in the release of the given store with the given tag that the Fetcher's options select
+func (fetcher *Fetcher) matchReleaseStoreAssets(ctx context.Context, releases releaseStore, tag string) ([]*GitHubReleaseAsset, error) {
+	matcher, err := fetcher.releaseAssetMatcher(tag)
+	if err != nil {
+		return nil, err
+	}
+	release, err := releases.release(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	return matcher.match(fetcher.logger, release, tag)
+}
+
+// Return what fetchFromReleaseStore would download from the given store, and where to, as Plan does for a GitHub repo
+func (fetcher *Fetcher) planReleaseStore(ctx context.Context, releases releaseStore) (*FetchPlan, error) {
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+	assets, err := fetcher.matchReleaseStoreAssets(ctx, releases, resolvedTag.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &FetchPlan{Tag: resolvedTag.Tag}
+	for _, asset := range assets {
+		plan.ReleaseAssets = append(plan.ReleaseAssets, PlannedDownload{
+			Name:        asset.Name,
+			Url:         releases.assetUrl(resolvedTag.Tag, asset.Name),
+			Size:        asset.Size,
+			Destination: filepath.Join(fetcher.options.LocalDownloadPath, filepath.FromSlash(renamePath(asset.Name, extractOptions.Renames))),
+		})
+	}
+	return plan, nil
+}
+
+// Download and parse the given checksum file, which is either a URL or the name of a release asset in the release of
+// the given store with the given tag, as loadChecksumFile does for a GitHub release
+func loadReleaseStoreChecksumFile(ctx context.Context, releases releaseStore, tag string, checksumFile string) (map[string]string, error) {
+	if strings.HasPrefix(checksumFile, "https://") || strings.HasPrefix(checksumFile, "http://") {
+		return loadChecksumFile(ctx, GitHubRepo{}, tag, checksumFile)
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-checksums")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	checksumFilePath := filepath.Join(tempDir, "checksums.txt")
+	if fetchErr := releases.downloadAsset(ctx, tag, checksumFile, checksumFilePath, false, false); fetchErr != nil {
+		return nil, fetchErr
+	}
+	contents, err := ioutil.ReadFile(checksumFilePath)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := parseChecksumFile(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse checksum file %s: %s", checksumFile, err)
+	}
+	return checksums, nil
+}
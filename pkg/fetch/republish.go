@@ -0,0 +1,198 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The name of the checksums file uploaded alongside the republished release assets
+const republishChecksumsFileName = "SHA256SUMS"
+
+// The options for Republish. Source describes the repo, tag constraint, and release assets to copy.
+type RepublishOptions struct {
+	Source            Options
+	TargetRepoUrl     string
+	TargetTag         string
+	TargetGithubToken string
+}
+
+// Copy the release assets matching options.Source.ReleaseAsset from the release matching options.Source.TagConstraint
+// to a release in the target repo, along with a SHA256SUMS file covering them. This is what the "fetch republish"
+// command runs.
+func Republish(ctx context.Context, logger *logrus.Entry, options RepublishOptions) error {
+	tempDir, err := ioutil.TempDir("", "fetch-republish")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceOptions := options.Source
+	sourceOptions.LocalDownloadPath = tempDir
+	sourceOptions.Logger = logger
+	source, err := NewFetcher(sourceOptions)
+	if err != nil {
+		return err
+	}
+	// The target repo is reached over the same connection as the source, as the Connection options describe
+	ctx = source.withConnection(ctx)
+	sourceRepo := source.repo
+
+	targetInstance, fetchErr := ParseUrlIntoGithubInstance(logger, options.TargetRepoUrl, options.Source.GithubApiVersion)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	targetRepo, fetchErr := ParseUrlIntoGitHubRepo(options.TargetRepoUrl, options.TargetGithubToken, targetInstance)
+	if fetchErr != nil {
+		return fmt.Errorf("Error occurred while parsing GitHub URL: %s", fetchErr)
+	}
+
+	resolvedTag, err := source.ResolveTag(ctx)
+	if err != nil {
+		return err
+	}
+	tag := resolvedTag.Tag
+	targetTag := options.TargetTag
+	if targetTag == "" {
+		targetTag = tag
+	}
+
+	assetPaths, err := source.DownloadReleaseAssets(ctx, tag)
+	if err != nil {
+		return err
+	}
+	if len(assetPaths) == 0 {
+		return fmt.Errorf("No release assets were downloaded from release %s of %s", tag, sourceRepo.Url)
+	}
+
+	checksumsPath, err := writeChecksumsFile(assetPaths, filepath.Join(tempDir, republishChecksumsFileName))
+	if err != nil {
+		return err
+	}
+
+	release, fetchErr := getOrCreateGitHubRelease(ctx, logger, targetRepo, targetTag)
+	if fetchErr != nil {
+		return fmt.Errorf("Error occurred while looking up release %s of %s: %s", targetTag, targetRepo.Url, fetchErr)
+	}
+
+	for _, assetPath := range append(assetPaths, checksumsPath) {
+		logger.Infof("Uploading %s to release %s of %s\n", filepath.Base(assetPath), targetTag, targetRepo.Url)
+		if fetchErr := uploadGitHubReleaseAsset(ctx, targetRepo, release, assetPath); fetchErr != nil {
+			return fmt.Errorf("Error occurred while uploading %s: %s", filepath.Base(assetPath), fetchErr)
+		}
+	}
+
+	logger.Infof("Republished %d release assets from %s (%s) to %s (%s)\n", len(assetPaths), sourceRepo.Url, tag, targetRepo.Url, targetTag)
+	return nil
+}
+
+// Write a checksums file in the format used by the sha256sum tool to checksumsPath, covering each of the given files.
+// Returns the path of the checksums file.
+func writeChecksumsFile(filePaths []string, checksumsPath string) (string, error) {
+	var lines []string
+	for _, filePath := range filePaths {
+		checksum, err := computeChecksum(filePath, "sha256", nil)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", checksum, filepath.Base(filePath)))
+	}
+	sort.Strings(lines)
+
+	if err := ioutil.WriteFile(checksumsPath, []byte(strings.Join(lines, "")), 0644); err != nil {
+		return "", err
+	}
+	return checksumsPath, nil
+}
+
+// Get information about the GitHub release with the given tag, creating the release first if it doesn't exist
+func getOrCreateGitHubRelease(ctx context.Context, logger *logrus.Entry, repo GitHubRepo, tag string) (GitHubReleaseApiResponse, *FetchError) {
+	release, err := GetGitHubReleaseInfo(ctx, repo, tag)
+	if err == nil || err.errorCode != repoDoesNotExistOrAccessDenied {
+		return release, err
+	}
+
+	logger.Infof("Creating release %s in %s\n", tag, repo.Url)
+
+	body, goErr := json.Marshal(map[string]string{"tag_name": tag, "name": tag})
+	if goErr != nil {
+		return release, wrapError(goErr)
+	}
+
+	headers := withApiVersionHeaders(repo, map[string]string{"Content-Type": "application/json"})
+	resp, err := callGitHubApiRawWithBody(ctx, formatUrl(repo, createGitHubRepoUrlForPath(repo, "releases")), "POST", repo.Token, headers, body)
+	if err != nil {
+		return release, err
+	}
+	defer resp.Body.Close()
+
+	if goErr := json.NewDecoder(resp.Body).Decode(&release); goErr != nil {
+		return release, wrapError(goErr)
+	}
+	return release, nil
+}
+
+// Upload the file at assetPath as an asset of the given release. For more info, see:
+// https://docs.github.com/en/rest/releases/assets#upload-a-release-asset
+func uploadGitHubReleaseAsset(ctx context.Context, repo GitHubRepo, release GitHubReleaseApiResponse, assetPath string) *FetchError {
+	uploadUrl, err := getReleaseAssetUploadUrl(release, filepath.Base(assetPath))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	file, err := os.Open(assetPath)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", uploadUrl, file)
+	if err != nil {
+		return wrapError(err)
+	}
+	request.ContentLength = info.Size()
+	request.Header.Set("Content-Type", "application/octet-stream")
+	if repo.Token != "" {
+		request.Header.Set("Authorization", authorizationHeader(repo.Token, connectionFrom(ctx).authScheme))
+	}
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return newError(resp.StatusCode, fmt.Sprintf("Received HTTP Response %d while uploading release asset to %s. Full HTTP response: %s", resp.StatusCode, uploadUrl, respBody))
+	}
+	return nil
+}
+
+// The GitHub API returns the upload URL of a release as a URI template (e.g.
+// "https://uploads.github.com/repos/foo/bar/releases/1/assets{?name,label}"). Expand it for the given asset name.
+func getReleaseAssetUploadUrl(release GitHubReleaseApiResponse, assetName string) (string, error) {
+	if release.UploadUrl == "" {
+		return "", fmt.Errorf("Release %s has no upload URL", release.Name)
+	}
+
+	baseUrl := release.UploadUrl
+	if i := strings.Index(baseUrl, "{"); i >= 0 {
+		baseUrl = baseUrl[:i]
+	}
+	return fmt.Sprintf("%s?name=%s", baseUrl, url.QueryEscape(assetName)), nil
+}
@@ -0,0 +1,43 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReleaseAssetUploadUrl(t *testing.T) {
+	t.Parallel()
+
+	release := GitHubReleaseApiResponse{
+		Name:      "v0.0.1",
+		UploadUrl: "https://uploads.github.com/repos/foo/bar/releases/1/assets{?name,label}",
+	}
+
+	uploadUrl, err := getReleaseAssetUploadUrl(release, "bar linux+amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "https://uploads.github.com/repos/foo/bar/releases/1/assets?name=bar+linux%2Bamd64", uploadUrl)
+
+	_, err = getReleaseAssetUploadUrl(GitHubReleaseApiResponse{Name: "v0.0.1"}, "bar")
+	assert.Error(t, err)
+}
+
+func TestWriteChecksumsFile(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	helloPath := filepath.Join(tmpDir, "hello.txt")
+	worldPath := filepath.Join(tmpDir, "world.txt")
+	require.NoError(t, ioutil.WriteFile(helloPath, []byte("hello"), 0644))
+	require.NoError(t, ioutil.WriteFile(worldPath, []byte("world"), 0644))
+
+	checksumsPath, err := writeChecksumsFile([]string{worldPath, helloPath}, filepath.Join(tmpDir, republishChecksumsFileName))
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadFile(checksumsPath)
+	require.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  hello.txt\n486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7  world.txt\n", string(contents))
+}
@@ -0,0 +1,211 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A directory that fetch writes files into, such as the local download path. Every file, directory, and symbolic link
+// fetch creates in the destination is created through a destRoot using a name relative to the root, and the destRoot
+// refuses any name that would resolve outside of it: absolute names, names with ".." components that climb out of the
+// root, and names that go through a symbolic link pointing outside of the root. Names are resolved with os.Root, which
+// opens each component of the name relative to the directory before it, so a symbolic link swapped in while a file is
+// being written can't redirect the write either.
+type destRoot struct {
+	dir string
+
+	// If true, writes go through symbolic links that already exist in the destination, even if they point outside of
+	// it, as with the FollowDestSymlinks option. Only absolute names and names that climb out of the root are refused.
+	followSymlinks bool
+}
+
+func newDestRoot(dir string, followSymlinks bool) destRoot {
+	return destRoot{dir: dir, followSymlinks: followSymlinks}
+}
+
+// Return an error if the given name, which is relative to the root, is absolute or climbs out of the root with ".."
+// components. This only looks at the name itself: symbolic links are dealt with by os.Root as the name is opened.
+func (root destRoot) checkName(name string) error {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return fmt.Errorf("Refusing to write %s because it is an absolute path rather than a path within %s", name, root.dir)
+	}
+
+	relPath := filepath.Clean(filepath.FromSlash(name))
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("Refusing to write %s because it is outside of %s", name, root.dir)
+	}
+	return nil
+}
+
+// Return the local path of the file with the given name, which is relative to the root, for reporting where a file
+// that was written through the root ended up. An empty name refers to the root itself. The path mustn't be written to
+// directly, as nothing stops it from going through a symbolic link.
+func (root destRoot) localPath(name string) string {
+	return filepath.Join(root.dir, filepath.FromSlash(name))
+}
+
+// Open the directory the given name is resolved in as an os.Root, and return it along with the name relative to it. An
+// empty name refers to the root itself, which is opened in its parent directory, as when a single file is extracted to
+// the path the user asked for. The caller must close the returned os.Root.
+func (root destRoot) open(name string) (*os.Root, string, error) {
+	if err := root.checkName(name); err != nil {
+		return nil, "", err
+	}
+
+	dir := root.dir
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." {
+		dir, name = filepath.Dir(filepath.Clean(root.dir)), filepath.Base(filepath.Clean(root.dir))
+	}
+	osRoot, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	return osRoot, name, nil
+}
+
+// Create the directory with the given name, along with any parents that don't exist yet
+func (root destRoot) MkdirAll(name string, perm os.FileMode) error {
+	if err := os.MkdirAll(root.dir, perm); err != nil {
+		return fmt.Errorf("Failed to create local directory %s: %s", root.dir, err)
+	}
+	if err := root.checkName(name); err != nil {
+		return err
+	}
+	if root.followSymlinks {
+		if err := os.MkdirAll(root.localPath(name), perm); err != nil {
+			return fmt.Errorf("Failed to create local directory %s: %s", root.localPath(name), err)
+		}
+		return nil
+	}
+
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." {
+		return nil
+	}
+	osRoot, err := os.OpenRoot(root.dir)
+	if err != nil {
+		return err
+	}
+	defer osRoot.Close()
+	if err := osRoot.MkdirAll(name, perm); err != nil {
+		return fmt.Errorf("Failed to create local directory %s: %s", root.localPath(name), err)
+	}
+	return nil
+}
+
+// Open the file with the given name, just like os.OpenFile
+func (root destRoot) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if root.followSymlinks {
+		if err := root.checkName(name); err != nil {
+			return nil, err
+		}
+		return os.OpenFile(root.localPath(name), flag, perm)
+	}
+
+	osRoot, relName, err := root.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer osRoot.Close()
+	return osRoot.OpenFile(relName, flag, perm)
+}
+
+// Create a symbolic link with the given name pointing at target, replacing anything that already exists with that name.
+// The link is refused if its target is absolute or resolves outside of the root.
+func (root destRoot) Symlink(target string, name string) error {
+	linkPath := root.localPath(name)
+	if !isSymlinkTargetWithinRoot(root.dir, linkPath, target) {
+		return fmt.Errorf("Refusing to create symbolic link %s because its target %s points outside of %s", linkPath, target, root.dir)
+	}
+
+	// The link itself replaces whatever is at its path rather than writing through it, so only its parent directory is
+	// resolved through symbolic links
+	osRoot, relName, err := root.open(name)
+	if err != nil {
+		return err
+	}
+	defer osRoot.Close()
+
+	if _, err := osRoot.Lstat(relName); err == nil {
+		if err := osRoot.Remove(relName); err != nil {
+			return fmt.Errorf("Failed to replace existing file %s with symbolic link: %s", linkPath, err)
+		}
+	}
+
+	if err := osRoot.Symlink(target, relName); err != nil {
+		return fmt.Errorf("Failed to create symbolic link %s: %s", linkPath, err)
+	}
+	return nil
+}
+
+// Remove the file with the given name
+func (root destRoot) Remove(name string) error {
+	if root.followSymlinks {
+		if err := root.checkName(name); err != nil {
+			return err
+		}
+		return os.Remove(root.localPath(name))
+	}
+
+	osRoot, relName, err := root.open(name)
+	if err != nil {
+		return err
+	}
+	defer osRoot.Close()
+	return osRoot.Remove(relName)
+}
+
+// Rename the file with the given name to newName, both of which are relative to the root
+func (root destRoot) Rename(name string, newName string) error {
+	if err := root.checkName(name); err != nil {
+		return err
+	}
+	if err := root.checkName(newName); err != nil {
+		return err
+	}
+	if root.followSymlinks {
+		return os.Rename(root.localPath(name), root.localPath(newName))
+	}
+
+	osRoot, err := os.OpenRoot(root.dir)
+	if err != nil {
+		return err
+	}
+	defer osRoot.Close()
+	return osRoot.Rename(filepath.FromSlash(name), filepath.FromSlash(newName))
+}
+
+// Return the local path to download the file with the given name to, for downloads that write to a path rather than
+// through the root, such as release assets. The name must be a single file name, so the file is directly in the root
+// directory, where there's no directory between the root and the file for a symbolic link to redirect. Any symbolic
+// link already at the name itself is removed, so the download replaces it rather than writing through it.
+func (root destRoot) filePath(name string) (string, error) {
+	if err := root.checkName(name); err != nil {
+		return "", err
+	}
+	if name == "" || name == "." || strings.ContainsRune(name, '/') || strings.ContainsRune(name, os.PathSeparator) {
+		return "", fmt.Errorf("Refusing to write %s because it is not a file name within %s", name, root.dir)
+	}
+	if root.followSymlinks {
+		return root.localPath(name), nil
+	}
+
+	osRoot, err := os.OpenRoot(root.dir)
+	if os.IsNotExist(err) {
+		// Nothing exists in the destination yet, so there are no symbolic links to worry about
+		return root.localPath(name), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer osRoot.Close()
+	if info, err := osRoot.Lstat(name); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := osRoot.Remove(name); err != nil {
+			return "", fmt.Errorf("Failed to replace symbolic link %s: %s", root.localPath(name), err)
+		}
+	}
+	return root.localPath(name), nil
+}
@@ -0,0 +1,148 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestRootCheckName(t *testing.T) {
+	t.Parallel()
+
+	root := newDestRoot(t.TempDir(), false)
+
+	cases := []struct {
+		name      string
+		expectErr bool
+	}{
+		{"", false},
+		{"foo/bar.txt", false},
+		{"foo/../bar.txt", false},
+		{"../bar.txt", true},
+		{"foo/../../bar.txt", true},
+		{"/etc/passwd", true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := root.checkName(tc.name)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDestRootWrites(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	root := newDestRoot(rootDir, false)
+
+	require.NoError(t, root.MkdirAll("foo/bar", 0755))
+	file, err := root.OpenFile("foo/bar/baz.txt", os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// Symlinks within the root are allowed, and replace whatever is already at their path
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, "link"), []byte("existing"), 0644))
+	require.NoError(t, root.Symlink("foo/bar/baz.txt", "link"))
+	target, err := os.Readlink(filepath.Join(rootDir, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo/bar/baz.txt", target)
+
+	require.NoError(t, root.Rename("foo/bar/baz.txt", "foo/qux.txt"))
+	assert.FileExists(t, filepath.Join(rootDir, "foo", "qux.txt"))
+
+	assert.Error(t, root.Symlink("../../outside", "foo/escape"))
+	assert.Error(t, root.Symlink("/etc/passwd", "absolute"))
+	assert.Error(t, root.MkdirAll("../outside", 0755))
+	assert.Error(t, root.Rename("foo/qux.txt", "../qux.txt"))
+
+	_, err = root.OpenFile("../outside.txt", os.O_CREATE|os.O_WRONLY, 0644)
+	assert.Error(t, err)
+}
+
+func TestDestRootRefusesEscapingSymlinks(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "outside")
+	rootDir := filepath.Join(tempDir, "root")
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "sub"), 0755))
+	require.NoError(t, os.Symlink("sub", filepath.Join(rootDir, "inside-link")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(rootDir, "outside-link")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(rootDir, "outside-file-link")))
+	require.NoError(t, os.Symlink("does-not-exist", filepath.Join(rootDir, "dangling-link")))
+	require.NoError(t, os.Symlink("../outside/does-not-exist", filepath.Join(rootDir, "dangling-outside-link")))
+
+	// The destination itself being a symlink is fine, as that's the destination that was asked for
+	rootLink := filepath.Join(tempDir, "root-link")
+	require.NoError(t, os.Symlink(rootDir, rootLink))
+
+	cases := []struct {
+		name      string
+		root      string
+		file      string
+		expectErr bool
+	}{
+		{"regular-path", rootDir, "sub/file.txt", false},
+		{"link-inside-root", rootDir, "inside-link/file.txt", false},
+		{"root-is-link", rootLink, "inside-link/other.txt", false},
+		{"dangling-link-inside-root", rootDir, "dangling-link", false},
+		{"dir-link-outside-root", rootDir, "outside-link/file.txt", true},
+		{"file-link-outside-root", rootDir, "outside-file-link", true},
+		{"dangling-link-outside-root", rootDir, "dangling-outside-link", true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := newDestRoot(tc.root, false).OpenFile(tc.file, os.O_CREATE|os.O_WRONLY, 0644)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.NoError(t, file.Close())
+			}
+		})
+	}
+
+	entries, err := ioutil.ReadDir(outside)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDestRootFilePath(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	rootDir := filepath.Join(tempDir, "root")
+	require.NoError(t, os.MkdirAll(rootDir, 0755))
+	require.NoError(t, os.Symlink(filepath.Join(tempDir, "secret.txt"), filepath.Join(rootDir, "asset.zip")))
+	root := newDestRoot(rootDir, false)
+
+	// A symlink already at the name is removed, so that the download doesn't write through it
+	assetPath, err := root.filePath("asset.zip")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootDir, "asset.zip"), assetPath)
+	_, err = os.Lstat(assetPath)
+	assert.True(t, os.IsNotExist(err))
+
+	for _, name := range []string{"", ".", "..", "sub/asset.zip", "../asset.zip", "/etc/passwd"} {
+		_, err := root.filePath(name)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,257 @@
+package fetch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The bucket and key prefix that release assets are published to with --publish-s3
+type S3Location struct {
+	Bucket string
+	Prefix string
+}
+
+// AWS credentials used to sign S3 requests. These are read from the standard AWS environment variables.
+type AwsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Everything needed to sign requests against a single S3 bucket
+type S3Publisher struct {
+	Location    S3Location
+	Region      string
+	Credentials AwsCredentials
+	UrlExpiry   time.Duration
+
+	// The scheme and host to send requests to. If empty, the standard virtual-hosted S3 endpoint for the bucket is used.
+	// This is only overridden in tests.
+	endpoint string
+}
+
+// Parse a value of the form "bucket/prefix" (where the prefix is optional) into an S3Location
+func ParseS3Location(value string) (S3Location, error) {
+	value = strings.TrimPrefix(value, "s3://")
+	bucket, prefix, _ := strings.Cut(value, "/")
+	if bucket == "" {
+		return S3Location{}, fmt.Errorf("The --publish-s3 value \"%s\" must be of the form bucket/prefix.", value)
+	}
+	return S3Location{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// Read AWS credentials from the environment. Only static credentials (including temporary session credentials) are
+// supported. The error if they're missing begins with purpose, which says what they're needed for.
+func getAwsCredentialsFromEnv(purpose string) (AwsCredentials, error) {
+	creds := AwsCredentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyId == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("%s requires AWS credentials to be set in the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.", purpose)
+	}
+	return creds, nil
+}
+
+// Return the AWS region to use for S3, preferring the explicitly passed in region over the environment
+func getAwsRegion(region string) string {
+	if region != "" {
+		return region
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if region := os.Getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+// Upload each of the given release assets to S3 and return a presigned URL from which each one can be downloaded, in
+// the same order as assetPaths.
+func publishReleaseAssetsToS3(ctx context.Context, logger *logrus.Entry, publisher S3Publisher, assetPaths []string) ([]string, error) {
+	var presignedUrls []string
+
+	for _, assetPath := range assetPaths {
+		key := path.Join(publisher.Location.Prefix, path.Base(assetPath))
+
+		logger.Infof("Uploading %s to s3://%s/%s\n", assetPath, publisher.Location.Bucket, key)
+		if err := publisher.upload(ctx, assetPath, key); err != nil {
+			return presignedUrls, fmt.Errorf("Error occurred while uploading %s to S3: %s", assetPath, err)
+		}
+
+		presignedUrl, err := publisher.presign("GET", key, time.Now())
+		if err != nil {
+			return presignedUrls, err
+		}
+		presignedUrls = append(presignedUrls, presignedUrl)
+	}
+
+	return presignedUrls, nil
+}
+
+// Upload the file at filePath to the given key using a presigned PUT request
+func (publisher S3Publisher) upload(ctx context.Context, filePath string, key string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	presignedUrl, err := publisher.presign("PUT", key, time.Now())
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "PUT", presignedUrl, file)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = info.Size()
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Received HTTP Response %d while uploading to S3", resp.StatusCode)
+	}
+	return nil
+}
+
+// Create a presigned URL for the given HTTP method and object key using AWS Signature Version 4. For more info, see:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (publisher S3Publisher) presign(method string, key string, now time.Time) (string, error) {
+	return publisher.presignWithQuery(method, key, nil, now)
+}
+
+// Create a presigned URL as presign does, with the given query parameters, such as those of a ListObjectsV2 request,
+// signed along with it
+func (publisher S3Publisher) presignWithQuery(method string, key string, params map[string]string, now time.Time) (string, error) {
+	endpoint := publisher.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s", getS3Host(publisher.Location.Bucket, publisher.Region))
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), publisher.Region)
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    publisher.Credentials.AccessKeyId + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       fmt.Sprintf("%d", int64(publisher.UrlExpiry/time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if publisher.Credentials.SessionToken != "" {
+		query["X-Amz-Security-Token"] = publisher.Credentials.SessionToken
+	}
+	for name, value := range params {
+		query[name] = value
+	}
+
+	canonicalUri := "/" + awsUriEncode(key, false)
+	canonicalQuery := canonicalAwsQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalUri,
+		canonicalQuery,
+		"host:" + endpointUrl.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := awsSigningKey(publisher.Credentials.SecretAccessKey, now, publisher.Region, "s3")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", endpointUrl.Scheme, endpointUrl.Host, canonicalUri, canonicalQuery, signature), nil
+}
+
+// Return the virtual-hosted style S3 host name for the given bucket and region
+func getS3Host(bucket string, region string) string {
+	if region == "us-east-1" {
+		return fmt.Sprintf("%s.s3.amazonaws.com", bucket)
+	}
+	return fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region)
+}
+
+// Build the canonical query string used in AWS signatures: keys sorted, with both keys and values URI encoded
+func canonicalAwsQueryString(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, awsUriEncode(key, true)+"="+awsUriEncode(query[key], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// URI encode the given value the way AWS signatures expect: every byte except unreserved characters is percent
+// encoded, and "/" is only encoded if encodeSlash is true.
+func awsUriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		isUnreserved := (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~'
+		if isUnreserved || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// Derive the key that signs requests to the given AWS service in the given region on the day of now
+func awsSigningKey(secretAccessKey string, now time.Time, region string, service string) []byte {
+	signingKey := hmacSha256([]byte("AWS4"+secretAccessKey), now.UTC().Format("20060102"))
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, service)
+	return hmacSha256(signingKey, "aws4_request")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
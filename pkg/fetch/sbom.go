@@ -0,0 +1,193 @@
+package fetch
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The version of the --emit-sbom-lite manifest format. Bump this whenever a field is removed or changes meaning.
+const sbomLiteSchemaVersion = 1
+
+// The artifact types recorded in an --emit-sbom-lite manifest
+const sbomLiteSourceArchive = "source-archive"
+const sbomLiteSourceFile = "source-file"
+const sbomLiteReleaseAsset = "release-asset"
+
+// The extension of the detached signature written next to a signed manifest
+const sbomLiteSignatureExtension = ".sig"
+
+// A manifest of every artifact fetch downloaded in a single run, written with --emit-sbom-lite so that downstream
+// deployment systems can verify the exact inputs they were built from.
+type SbomLiteManifest struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	Tool          string             `json:"tool"`
+	ToolVersion   string             `json:"toolVersion"`
+	GeneratedAt   string             `json:"generatedAt"`
+	Repo          string             `json:"repo"`
+	Ref           SbomLiteRef        `json:"ref"`
+	Artifacts     []SbomLiteArtifact `json:"artifacts"`
+}
+
+// The git reference the artifacts in a manifest were fetched from. Only the fields that were used are set.
+type SbomLiteRef struct {
+	Tag       string `json:"tag,omitempty"`
+	TagCommit string `json:"tagCommit,omitempty"` // The commit the tag points to, which may differ from Commit
+	Branch    string `json:"branch,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+// A single artifact fetched during a run
+type SbomLiteArtifact struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Path   string `json:"path,omitempty"`
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+func newSbomLiteManifest(repo GitHubRepo, tag string, tagCommitSha string, branchName string, commitSha string, toolVersion string, now time.Time) *SbomLiteManifest {
+	return &SbomLiteManifest{
+		SchemaVersion: sbomLiteSchemaVersion,
+		Tool:          "fetch",
+		ToolVersion:   toolVersion,
+		GeneratedAt:   now.UTC().Format(time.RFC3339),
+		Repo:          repo.Url,
+		Ref: SbomLiteRef{
+			Tag:       tag,
+			TagCommit: tagCommitSha,
+			Branch:    branchName,
+			Commit:    commitSha,
+		},
+		Artifacts: []SbomLiteArtifact{},
+	}
+}
+
+// Record the zip archive of the repo that source files were extracted from. The archive itself is a temp file, so no
+// local path is recorded.
+func (manifest *SbomLiteManifest) addSourceArchive(gitHubCommit GitHubCommit, zipFilePath string, instance GitHubInstance) error {
+	// Build the request without a token, as the URL is all we need
+	req, err := MakeGitHubZipFileRequest(gitHubCommit, "", instance)
+	if err != nil {
+		return err
+	}
+
+	artifact, err := newSbomLiteArtifact(sbomLiteSourceArchive, filepath.Base(req.URL.Path), req.URL.String(), zipFilePath)
+	if err != nil {
+		return err
+	}
+	artifact.Path = ""
+
+	manifest.Artifacts = append(manifest.Artifacts, artifact)
+	return nil
+}
+
+// Record a single file that was downloaded from the repo with the contents API to filePath
+func (manifest *SbomLiteManifest) addSourceFile(repo GitHubRepo, ref string, repoFilePath string, filePath string) error {
+	fileUrl := formatUrl(repo, repoFileApiPath(repo, ref, repoFilePath))
+	artifact, err := newSbomLiteArtifact(sbomLiteSourceFile, strings.Trim(repoFilePath, "/"), fileUrl, filePath)
+	if err != nil {
+		return err
+	}
+	manifest.Artifacts = append(manifest.Artifacts, artifact)
+	return nil
+}
+
+// Record the given release assets, which were downloaded from the release with the given tag
+func (manifest *SbomLiteManifest) addReleaseAssets(repo GitHubRepo, tag string, assetPaths []string) error {
+	for _, assetPath := range assetPaths {
+		name := filepath.Base(assetPath)
+		assetUrl := fmt.Sprintf("https://%s/%s/%s/releases/download/%s/%s", repo.BaseUrl, repo.Owner, repo.Name, tag, name)
+
+		artifact, err := newSbomLiteArtifact(sbomLiteReleaseAsset, name, assetUrl, assetPath)
+		if err != nil {
+			return err
+		}
+		manifest.Artifacts = append(manifest.Artifacts, artifact)
+	}
+	return nil
+}
+
+func newSbomLiteArtifact(artifactType string, name string, artifactUrl string, filePath string) (SbomLiteArtifact, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return SbomLiteArtifact{}, err
+	}
+
+	checksum, err := computeChecksum(filePath, "sha256", nil)
+	if err != nil {
+		return SbomLiteArtifact{}, err
+	}
+
+	return SbomLiteArtifact{
+		Type:   artifactType,
+		Name:   name,
+		Url:    artifactUrl,
+		Path:   filePath,
+		Sha256: checksum,
+		Size:   info.Size(),
+	}, nil
+}
+
+// Write the manifest as JSON to manifestPath. If signingKeyPath is set, also sign the exact bytes written with the
+// Ed25519 private key at that path and write the base64-encoded signature to manifestPath + ".sig".
+func (manifest *SbomLiteManifest) write(manifestPath string, signingKeyPath string) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestBytes = append(manifestBytes, '\n')
+
+	if err := ioutil.WriteFile(manifestPath, manifestBytes, 0644); err != nil {
+		return fmt.Errorf("Failed to write manifest to %s: %s", manifestPath, err)
+	}
+
+	if signingKeyPath == "" {
+		return nil
+	}
+
+	privateKey, err := loadEd25519PrivateKey(signingKeyPath)
+	if err != nil {
+		return err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, manifestBytes))
+	signaturePath := manifestPath + sbomLiteSignatureExtension
+	if err := ioutil.WriteFile(signaturePath, []byte(signature+"\n"), 0644); err != nil {
+		return fmt.Errorf("Failed to write manifest signature to %s: %s", signaturePath, err)
+	}
+	return nil
+}
+
+// Load a PEM-encoded PKCS #8 Ed25519 private key, such as one generated with "openssl genpkey -algorithm ed25519"
+func loadEd25519PrivateKey(keyPath string) (ed25519.PrivateKey, error) {
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read signing key %s: %s", keyPath, err)
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("The signing key %s is not PEM encoded", keyPath)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse signing key %s: %s", keyPath, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("The signing key %s is not an Ed25519 private key", keyPath)
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSbomLiteManifestAddReleaseAssets(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	assetPath := filepath.Join(tmpDir, "hello.txt")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("hello"), 0644))
+
+	repo := GitHubRepo{Url: "https://github.com/foo/bar", BaseUrl: "github.com", Owner: "foo", Name: "bar"}
+	manifest := newSbomLiteManifest(repo, "v0.0.1", "7c3bd5b2c3c1d6b9e0c4a2fdb8e1b6a1c9d3e4f5", "", "", "v1.2.3", time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, manifest.addReleaseAssets(repo, "v0.0.1", []string{assetPath}))
+
+	assert.Equal(t, "2022-01-02T03:04:05Z", manifest.GeneratedAt)
+	assert.Equal(t, SbomLiteRef{Tag: "v0.0.1", TagCommit: "7c3bd5b2c3c1d6b9e0c4a2fdb8e1b6a1c9d3e4f5"}, manifest.Ref)
+	assert.Equal(t, []SbomLiteArtifact{{
+		Type:   sbomLiteReleaseAsset,
+		Name:   "hello.txt",
+		Url:    "https://github.com/foo/bar/releases/download/v0.0.1/hello.txt",
+		Path:   assetPath,
+		Sha256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Size:   5,
+	}}, manifest.Artifacts)
+}
+
+func TestSbomLiteManifestWriteSigned(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(tmpDir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600))
+
+	repo := GitHubRepo{Url: "https://github.com/foo/bar", BaseUrl: "github.com", Owner: "foo", Name: "bar"}
+	manifest := newSbomLiteManifest(repo, "", "", "main", "", "v1.2.3", time.Now())
+	manifestPath := filepath.Join(tmpDir, "sbom.json")
+	require.NoError(t, manifest.write(manifestPath, keyPath))
+
+	manifestBytes, err := ioutil.ReadFile(manifestPath)
+	require.NoError(t, err)
+	var parsed SbomLiteManifest
+	require.NoError(t, json.Unmarshal(manifestBytes, &parsed))
+	assert.Equal(t, *manifest, parsed)
+
+	signatureBytes, err := ioutil.ReadFile(manifestPath + sbomLiteSignatureExtension)
+	require.NoError(t, err)
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signatureBytes)))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(publicKey, manifestBytes, signature))
+}
+
+func TestLoadEd25519PrivateKeyInvalid(t *testing.T) {
+	t.Parallel()
+
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("not a key"), 0600))
+
+	_, err := loadEd25519PrivateKey(keyPath)
+	assert.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+// The well-known paths, relative to the root of the repo, at which repos commonly publish the public key used to sign
+// their release assets. The first one that exists at the release's tag is used.
+var repoSigningKeyPaths = []string{"cosign.pub", "signing-key.asc"}
+
+// A public key published in a repo for verifying the signatures of its release assets
+type RepoSigningKey struct {
+	Path     string
+	Contents []byte
+}
+
+// Return true if this is an armored PGP key rather than a PEM-encoded (e.g. cosign) public key
+func (key RepoSigningKey) isPgp() bool {
+	return strings.HasSuffix(key.Path, ".asc")
+}
+
+// Return the extensions that the signature of a release asset may have, relative to the asset name, in the order they
+// should be looked for. For example, the signature of foo.tar.gz is published as foo.tar.gz.sig.
+func (key RepoSigningKey) signatureExtensions() []string {
+	if key.isPgp() {
+		return []string{".asc", ".sig"}
+	}
+	return []string{".sig"}
+}
+
+// Verify the signature of each of the given release assets with the public key published in the repo at the given tag.
+// The signature of each asset must be published as another asset in the same release.
+func verifyReleaseAssetSignatures(ctx context.Context, logger *logrus.Entry, repo GitHubRepo, tag string, assetPaths []string) error {
+	key, fetchErr := getRepoSigningKey(ctx, repo, tag)
+	if fetchErr != nil {
+		return fetchErr
+	}
+	logger.Infof("Verifying release asset signatures with the public key at <repo>/%s of tag %s\n", key.Path, tag)
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, repo, tag)
+	if fetchErr != nil {
+		return fetchErr
+	}
+
+	tempDir, err := ioutil.TempDir("", "fetch-signatures")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, assetPath := range assetPaths {
+		assetName := filepath.Base(assetPath)
+
+		// The regex passed to --release-asset may have matched the signatures themselves, which don't need verifying
+		if isSignatureFile(assetName) {
+			continue
+		}
+
+		signatureAsset := findSignatureAsset(release, assetName, key)
+		if signatureAsset == nil {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("Could not find a signature for release asset %s in release %s. Expected an asset named %s%s.", assetName, tag, assetName, key.signatureExtensions()[0]))
+		}
+
+		signaturePath := filepath.Join(tempDir, signatureAsset.Name)
+		if fetchErr := downloadReleaseAsset(ctx, repo, signatureAsset, signaturePath, false); fetchErr != nil {
+			return fetchErr
+		}
+
+		signature, err := ioutil.ReadFile(signaturePath)
+		if err != nil {
+			return err
+		}
+
+		if err := verifyReleaseAssetSignature(key, assetPath, signature); err != nil {
+			return newError(signatureDoesNotMatch, fmt.Sprintf("The signature %s does not match release asset %s: %s. This means that either the asset was not signed with the key at <repo>/%s, or that someone has replaced the asset with a potentially dangerous one and you should be very careful about proceeding.", signatureAsset.Name, assetPath, err, key.Path))
+		}
+		logger.Infof("Release asset signature verified for %s\n", assetPath)
+	}
+
+	return nil
+}
+
+// Find the public key published in the repo at the given tag, trying each of the well-known paths in turn
+func getRepoSigningKey(ctx context.Context, repo GitHubRepo, tag string) (RepoSigningKey, *FetchError) {
+	for _, keyPath := range repoSigningKeyPaths {
+		contents, err := downloadRepoFile(ctx, repo, keyPath, tag)
+		if err == nil {
+			return RepoSigningKey{Path: keyPath, Contents: contents}, nil
+		}
+		if err.errorCode != repoDoesNotExistOrAccessDenied {
+			return RepoSigningKey{}, err
+		}
+	}
+
+	return RepoSigningKey{}, newError(signatureDoesNotMatch, fmt.Sprintf("Could not find a public key in %s at tag %s. Expected one of: %s", repo.Url, tag, strings.Join(repoSigningKeyPaths, ", ")))
+}
+
+// Download the contents of the file at the given path in the repo, at the given git ref, using the contents API. For
+// more info, see: https://docs.github.com/en/rest/repos/contents#get-repository-content
+func downloadRepoFile(ctx context.Context, repo GitHubRepo, filePath string, ref string) ([]byte, *FetchError) {
+	path := createGitHubRepoUrlForPath(repo, fmt.Sprintf("contents/%s?ref=%s", filePath, url.QueryEscape(ref)))
+	resp, err := callGitHubApi(ctx, repo, path, map[string]string{"Accept": "application/vnd.github.v3.raw"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contents, goErr := ioutil.ReadAll(resp.Body)
+	if goErr != nil {
+		return nil, wrapError(goErr)
+	}
+	return contents, nil
+}
+
+// Return the asset in the given release that holds the signature of the asset with the given name, or nil if there
+// isn't one
+func findSignatureAsset(release GitHubReleaseApiResponse, assetName string, key RepoSigningKey) *GitHubReleaseAsset {
+	for _, ext := range key.signatureExtensions() {
+		for _, asset := range release.Assets {
+			if asset.Name == assetName+ext {
+				asset := asset
+				return &asset
+			}
+		}
+	}
+	return nil
+}
+
+// Return true if the file with the given name looks like a detached signature or public key rather than an artifact
+func isSignatureFile(fileName string) bool {
+	for _, ext := range []string{".sig", ".asc", ".pem", ".pub", ".crt", ".bundle"} {
+		if strings.HasSuffix(fileName, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify the given detached signature of the file at assetPath using the given key
+func verifyReleaseAssetSignature(key RepoSigningKey, assetPath string, signature []byte) error {
+	if key.isPgp() {
+		return verifyPgpSignature(key.Contents, assetPath, signature)
+	}
+	return verifyCosignSignature(key.Contents, assetPath, signature)
+}
+
+// Verify a signature created with "cosign sign-blob", which is the base64-encoded signature of the file's SHA256
+// digest. ECDSA, RSA, and Ed25519 keys are supported.
+func verifyCosignSignature(publicKeyPem []byte, assetPath string, signature []byte) error {
+	block, _ := pem.Decode(publicKeyPem)
+	if block == nil {
+		return fmt.Errorf("public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %s", err)
+	}
+
+	asset, err := ioutil.ReadFile(assetPath)
+	if err != nil {
+		return err
+	}
+	return verifySignatureWithPublicKey(publicKey, asset, decodeCosignSignature(signature))
+}
+
+// cosign writes signatures base64-encoded, but also accept raw signatures
+func decodeCosignSignature(signature []byte) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		return decoded
+	}
+	return signature
+}
+
+// Verify the given signature of the given contents, as created by cosign: ECDSA and RSA signatures are of the SHA256
+// digest of the contents, whereas Ed25519 signatures are of the contents themselves.
+func verifySignatureWithPublicKey(publicKey crypto.PublicKey, contents []byte, signature []byte) error {
+	digest := sha256.Sum256(contents)
+
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("invalid RSA signature: %s", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(publicKey, contents, signature) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
+
+// Verify a detached PGP signature, either armored or binary, against an armored public key
+func verifyPgpSignature(armoredKeyRing []byte, assetPath string, signature []byte) error {
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armoredKeyRing))
+	if err != nil {
+		return fmt.Errorf("failed to parse PGP public key: %s", err)
+	}
+
+	asset, err := os.Open(assetPath)
+	if err != nil {
+		return err
+	}
+	defer asset.Close()
+
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, asset, bytes.NewReader(signature))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyRing, asset, bytes.NewReader(signature))
+	}
+	return err
+}
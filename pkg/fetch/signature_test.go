@@ -0,0 +1,134 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestVerifyCosignSignature(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	assetPath := filepath.Join(tmpDir, "asset.tar.gz")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("release asset contents"), 0644))
+	digest := sha256.Sum256([]byte("release asset contents"))
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	require.NoError(t, err)
+
+	ed25519PublicKey, ed25519PrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ed25519Signature := ed25519.Sign(ed25519PrivateKey, []byte("release asset contents"))
+
+	testCases := []struct {
+		name      string
+		publicKey interface{}
+		signature []byte
+		expectErr bool
+	}{
+		{"ecdsa-base64", &ecdsaKey.PublicKey, []byte(base64.StdEncoding.EncodeToString(ecdsaSignature) + "\n"), false},
+		{"ecdsa-raw", &ecdsaKey.PublicKey, ecdsaSignature, false},
+		{"ed25519", ed25519PublicKey, []byte(base64.StdEncoding.EncodeToString(ed25519Signature)), false},
+		{"wrong-key", ed25519PublicKey, []byte(base64.StdEncoding.EncodeToString(ecdsaSignature)), true},
+		{"tampered", &ecdsaKey.PublicKey, []byte(base64.StdEncoding.EncodeToString(append([]byte{}, ecdsaSignature[:len(ecdsaSignature)-1]...))), true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			publicKeyBytes, err := x509.MarshalPKIXPublicKey(tc.publicKey)
+			require.NoError(t, err)
+			key := RepoSigningKey{
+				Path:     "cosign.pub",
+				Contents: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}),
+			}
+
+			err = verifyReleaseAssetSignature(key, assetPath, tc.signature)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyPgpSignature(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	assetPath := filepath.Join(tmpDir, "asset.tar.gz")
+	require.NoError(t, ioutil.WriteFile(assetPath, []byte("release asset contents"), 0644))
+
+	entity, err := openpgp.NewEntity("fetch", "test", "fetch@example.com", nil)
+	require.NoError(t, err)
+
+	var armoredKey bytes.Buffer
+	require.NoError(t, writeArmoredPublicKey(&armoredKey, entity))
+	key := RepoSigningKey{Path: "signing-key.asc", Contents: armoredKey.Bytes()}
+
+	var armoredSignature bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&armoredSignature, entity, bytes.NewReader([]byte("release asset contents")), nil))
+	assert.NoError(t, verifyReleaseAssetSignature(key, assetPath, armoredSignature.Bytes()))
+
+	var binarySignature bytes.Buffer
+	require.NoError(t, openpgp.DetachSign(&binarySignature, entity, bytes.NewReader([]byte("release asset contents")), nil))
+	assert.NoError(t, verifyReleaseAssetSignature(key, assetPath, binarySignature.Bytes()))
+
+	var otherSignature bytes.Buffer
+	require.NoError(t, openpgp.DetachSign(&otherSignature, entity, bytes.NewReader([]byte("some other contents")), nil))
+	assert.Error(t, verifyReleaseAssetSignature(key, assetPath, otherSignature.Bytes()))
+}
+
+func TestFindSignatureAsset(t *testing.T) {
+	t.Parallel()
+
+	release := GitHubReleaseApiResponse{
+		Assets: []GitHubReleaseAsset{
+			{Id: 1, Name: "foo.tar.gz"},
+			{Id: 2, Name: "foo.tar.gz.sig"},
+			{Id: 3, Name: "foo.tar.gz.asc"},
+		},
+	}
+
+	cosignAsset := findSignatureAsset(release, "foo.tar.gz", RepoSigningKey{Path: "cosign.pub"})
+	require.NotNil(t, cosignAsset)
+	assert.Equal(t, 2, cosignAsset.Id)
+
+	pgpAsset := findSignatureAsset(release, "foo.tar.gz", RepoSigningKey{Path: "signing-key.asc"})
+	require.NotNil(t, pgpAsset)
+	assert.Equal(t, 3, pgpAsset.Id)
+
+	assert.Nil(t, findSignatureAsset(release, "bar.tar.gz", RepoSigningKey{Path: "cosign.pub"}))
+}
+
+func writeArmoredPublicKey(w io.Writer, entity *openpgp.Entity) error {
+	armorWriter, err := armor.Encode(w, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return err
+	}
+	if err := entity.Serialize(armorWriter); err != nil {
+		return err
+	}
+	return armorWriter.Close()
+}
@@ -0,0 +1,134 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// An AssetSink returns the writer to stream the given release asset to, rather than downloading it to a file, e.g. an
+// entry of a tar archive, an upload to S3, or an in-memory buffer. If the writer is also an io.Closer, it's closed once
+// the asset has been streamed to it, whether or not that succeeded, so a writer that must stay open for the next asset,
+// like a tar.Writer, should be returned wrapped in a type that only has Write.
+type AssetSink func(asset *GitHubReleaseAsset) (io.Writer, error)
+
+// Return an error if the given options can't be used with the AssetSink option, which leaves no files on disk for the
+// steps that work on them
+func validateAssetSinkOptions(options Options) error {
+	switch {
+	case options.releaseAssetRegex() == "" && !options.AutoAsset:
+		return fmt.Errorf("Streaming to an AssetSink requires a release asset to be selected.")
+	case options.LocalDownloadPath == StdoutDownloadPath:
+		return fmt.Errorf("Release assets can't be streamed both to stdout and to an AssetSink.")
+	case options.VerifyWithRepoKey || options.CosignVerify || options.PackageSigningKey != "":
+		return fmt.Errorf("Only checksums can be verified for release assets streamed to an AssetSink, as signatures are checked against files on disk.")
+	case options.JoinParts || len(options.Renames) > 0 || options.Stdout || options.PublishS3 != "" || options.EmitSbomLite != "" || options.Unpack || options.Install:
+		return fmt.Errorf("Release assets streamed to an AssetSink aren't written to disk, so they can't be joined, renamed, printed, published, recorded in a manifest, unpacked, or installed.")
+	}
+	return nil
+}
+
+// Stream every release asset matching the ReleaseAsset, AutoAsset, or AllReleaseAssets option from the release with the
+// given tag to the writer that sink returns for it, without writing any of them to disk. The assets are streamed one at
+// a time, in the order they matched, so that sink can write them all into one archive. Each is hashed as it's streamed
+// and checked against the ReleaseAssetChecksums and ReleaseAssetChecksumFile options, so a mismatch is only reported
+// once all of it has been written. Returns a FetchedFile for each asset, whose Path is the asset's name.
+func (fetcher *Fetcher) StreamReleaseAssets(ctx context.Context, tag string, sink AssetSink) ([]FetchedFile, error) {
+	ctx = fetcher.withConnection(ctx)
+	matcher, err := fetcher.releaseAssetMatcher(tag)
+	if err != nil {
+		return nil, err
+	}
+	if !matcher.selectsAssets() {
+		return nil, fmt.Errorf("Streaming to an AssetSink requires a release asset to be selected.")
+	}
+
+	release, fetchErr := GetGitHubReleaseInfo(ctx, fetcher.repo, tag)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	assets, err := matcher.match(fetcher.logger, release, tag)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := fetcher.streamChecksums(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FetchedFile
+	for _, asset := range assets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file, err := fetcher.streamReleaseAssetToSink(ctx, asset, checksums, sink)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Stream the given release asset to the writer sink returns for it, and verify it against the ReleaseAssetChecksums
+// option and the given checksums from the ReleaseAssetChecksumFile option
+func (fetcher *Fetcher) streamReleaseAssetToSink(ctx context.Context, asset *GitHubReleaseAsset, checksums map[string]string, sink AssetSink) (FetchedFile, error) {
+	file := FetchedFile{Kind: FetchedReleaseAsset, Path: asset.Name}
+
+	// The regex in the ReleaseAsset option may have matched the checksum file itself
+	var hashers []*streamHasher
+	if asset.Name != fetcher.options.ReleaseAssetChecksumFile {
+		var err error
+		if hashers, err = fetcher.streamHashers(checksums, asset.Name); err != nil {
+			return file, err
+		}
+	}
+
+	writer, err := sink(asset)
+	if err != nil {
+		return file, fmt.Errorf("Could not open the sink for release asset %s: %s", asset.Name, err)
+	}
+	counter := &countingWriter{}
+	writers := []io.Writer{writer, counter}
+	for _, hasher := range hashers {
+		hasher.destination = "its AssetSink"
+		writers = append(writers, hasher)
+	}
+	sha256Hasher := sha256.New()
+	if fetcher.options.checksumsRecorded() {
+		writers = append(writers, sha256Hasher)
+	}
+
+	fetcher.logger.Infof("Streaming release asset %s\n", asset.Name)
+	fetchErr := streamReleaseAsset(ctx, fetcher.repo, asset, io.MultiWriter(writers...))
+	if closer, ok := writer.(io.Closer); ok {
+		if err := closer.Close(); err != nil && fetchErr == nil {
+			return file, fmt.Errorf("Could not close the sink for release asset %s: %s", asset.Name, err)
+		}
+	}
+	if fetchErr != nil {
+		return file, fetchErr
+	}
+
+	for _, hasher := range hashers {
+		if fetchErr := hasher.verify(fetcher.logger, asset.Name); fetchErr != nil {
+			return file, fetchErr
+		}
+	}
+	file.Size = counter.written
+	if fetcher.options.checksumsRecorded() {
+		file.Sha256 = hasherToString(sha256Hasher)
+	}
+	return file, nil
+}
+
+// An io.Writer that only counts the bytes written to it
+type countingWriter struct {
+	written int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	writer.written += int64(len(p))
+	return len(p), nil
+}
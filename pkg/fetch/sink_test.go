@@ -0,0 +1,148 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAssetSinkOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"release-asset", Options{ReleaseAsset: "tool_.*", ReleaseAssetChecksumFile: "SHA256SUMS"}, true},
+		{"auto-asset", Options{AutoAsset: true}, true},
+		{"no-asset", Options{SourcePaths: []string{"/"}}, false},
+		{"stdout-path", Options{ReleaseAsset: "tool", LocalDownloadPath: StdoutDownloadPath}, false},
+		{"cosign", Options{ReleaseAsset: "tool", CosignVerify: true}, false},
+		{"unpack", Options{ReleaseAsset: "tool", Unpack: true}, false},
+		{"rename", Options{ReleaseAsset: "tool", Renames: []string{"tool=bin"}}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateAssetSinkOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+// A sink entry that records whether it was closed
+type bufferSink struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (sink *bufferSink) Close() error {
+	sink.closed = true
+	return nil
+}
+
+// Return a fake GitHub Enterprise Server with a release v1.0.0 of the repo foo/bar that has the given assets
+func newFakeGitHubRelease(t *testing.T, assets map[string]string) *httptest.Server {
+	var names []string
+	for name := range assets {
+		names = append(names, name)
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/repos/foo/bar/releases/tags/v1.0.0":
+			release := GitHubReleaseApiResponse{Id: 1, Name: "v1.0.0"}
+			for id, name := range names {
+				release.Assets = append(release.Assets, GitHubReleaseAsset{Id: id, Name: name, Size: int64(len(assets[name]))})
+			}
+			json.NewEncoder(w).Encode(release)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"):
+			var id int
+			fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/"), &id)
+			assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+			w.Write([]byte(assets[names[id]]))
+		case r.URL.Path == "/api/v3/repos/foo/bar/tags":
+			w.Write([]byte("[]"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFetchToAssetSink(t *testing.T) {
+	t.Parallel()
+
+	assets := map[string]string{
+		"tool_linux_amd64":  "linux tool",
+		"tool_darwin_arm64": "mac tool",
+		"SHA256SUMS":        sha256Hex([]byte("linux tool")) + "  tool_linux_amd64\n" + sha256Hex([]byte("mac tool")) + "  tool_darwin_arm64\n",
+	}
+	server := newFakeGitHubRelease(t, assets)
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		checksums map[string]bool
+		valid     bool
+	}{
+		{"checksum-file", nil, true},
+		{"checksum-mismatch", map[string]bool{sha256Hex([]byte("other tool")): true}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sinks := map[string]*bufferSink{}
+			fetcher, err := NewFetcher(Options{
+				RepoUrl:                  server.URL + "/foo/bar",
+				GithubApiVersion:         "v3",
+				TagConstraint:            "v1.0.0",
+				ReleaseAsset:             "tool_.*",
+				ReleaseAssetChecksumFile: "SHA256SUMS",
+				ReleaseAssetChecksums:    tc.checksums,
+				ReleaseAssetChecksumAlgo: "sha256",
+				RecordChecksums:          true,
+				Connection:               ConnectionOptions{CaCert: writeTestServerCaCert(t, server)},
+				AssetSink: func(asset *GitHubReleaseAsset) (io.Writer, error) {
+					sinks[asset.Name] = &bufferSink{}
+					return sinks[asset.Name], nil
+				},
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if !tc.valid {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "already streamed to its AssetSink")
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, result.Files, 2)
+			for _, file := range result.Files {
+				require.Contains(t, sinks, file.Path)
+				assert.Equal(t, assets[file.Path], sinks[file.Path].String())
+				assert.True(t, sinks[file.Path].closed)
+				assert.Equal(t, int64(len(assets[file.Path])), file.Size)
+				assert.Equal(t, sha256Hex([]byte(assets[file.Path])), file.Sha256)
+			}
+			assert.Empty(t, result.AssetPaths)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The kinds of repo the Source option can select
+const (
+	SourceAuto          = "auto"           // A GitHub or Bitbucket Server repo, or a CodeCommit repo with an HTTPS or codecommit:// URL, told apart by its URL
+	SourceBitbucketDc   = "bitbucket-dc"   // A Bitbucket Server or Data Center repo, which may also be given by its HTTPS clone URL
+	SourceCodeCommit    = "codecommit"     // An AWS CodeCommit repo, which may also be given by its name alone
+	SourceGoProxy       = "goproxy"        // A Go module, given by its module path, downloaded from the module proxy in GOPROXY
+	SourceArtifactory   = "artifactory"    // A folder of an Artifactory generic repo, with a folder of release assets for each version
+	SourceNexus         = "nexus"          // A folder of a Nexus raw repo, with a folder of release assets for each version
+	SourceGitlabPackage = "gitlab-package" // A package in the Generic Packages registry of a GitLab project, with files for each version
+)
+
+// Every Source constant, in the order they're listed in errors
+var sources = []string{SourceAuto, SourceBitbucketDc, SourceCodeCommit, SourceGoProxy, SourceArtifactory, SourceNexus, SourceGitlabPackage}
+
+// Return an error if source isn't one of the Source constants
+func ValidateSource(source string) error {
+	if source == "" {
+		return nil
+	}
+	for _, known := range sources {
+		if source == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown source \"%s\". Must be one of: %s.", source, strings.Join(sources, ", "))
+}
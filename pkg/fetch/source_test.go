@@ -0,0 +1,17 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSource(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range append([]string{""}, sources...) {
+		assert.NoError(t, ValidateSource(source), source)
+	}
+	assert.Error(t, ValidateSource("bitbucket"))
+	assert.Error(t, ValidateSource("GITHUB"))
+}
@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+func isTagConstraintSpecificTag(tagConstraint string) (bool, string) {
+	if len(tagConstraint) > 0 {
+		switch tagConstraint[0] {
+		// Check for a tagConstraint '='
+		case '=':
+			return true, strings.TrimSpace(tagConstraint[1:])
+
+		// Check for a tagConstraint without constraint specifier
+		// Neither of '!=', '>', '>=', '<', '<=', '~>' is prefixed before tag
+		case '>', '<', '!', '~':
+			return false, tagConstraint
+
+		default:
+			return true, strings.TrimSpace(tagConstraint)
+		}
+	}
+	return false, tagConstraint
+}
+
+// Matches a loosely-versioned tag: an optional prefix without digits, one to three numeric segments, and an optional
+// pre-release or build metadata suffix
+var looseSemverRegex = regexp.MustCompile(`^[^0-9]*([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?([-+].*)?$`)
+
+// Parse the given tag as a version. If looseSemver is set, tags that aren't valid versions are coerced into one using
+// the rules documented for --loose-semver:
+//
+//   - Anything before the first digit is dropped, so release-1.2.3 becomes 1.2.3 and version_2 becomes 2.
+//   - Missing minor and patch versions are 0, so 1.2 becomes 1.2.0 and 1 becomes 1.0.0.
+//   - A suffix starting with - or + is kept as the pre-release or build metadata, so rel-1.2-rc1 becomes 1.2.0-rc1.
+//   - Tags that still can't be parsed, such as those with no digits, are not versions.
+func parseTagVersion(tag string, looseSemver bool) (*version.Version, error) {
+	v, err := version.NewVersion(tag)
+	if err == nil || !looseSemver {
+		return v, err
+	}
+
+	matches := looseSemverRegex.FindStringSubmatch(tag)
+	if matches == nil {
+		return nil, fmt.Errorf("Tag %s can't be coerced into a version", tag)
+	}
+
+	segments := []string{matches[1], matches[2], matches[3]}
+	for i, segment := range segments {
+		if segment == "" {
+			segments[i] = "0"
+		}
+	}
+	return version.NewVersion(strings.Join(segments, ".") + matches[4])
+}
+
+// Return the tags with the given prefix, with the prefix stripped, out of the given tags that are versions and the
+// names of all the tags in tagCommits, so that e.g. modules/vpc/v1.2.3 is matched as v1.2.3. A repo's tags that aren't
+// versions, as prefixed tags aren't, are only in tagCommits. Tags whose remainder isn't a version are dropped.
+func tagsWithPrefix(prefix string, tags []string, tagCommits map[string]string, looseSemver bool) []string {
+	// The given tags come first, as they're in the order they were listed, which decides between coerced tags with the
+	// same version
+	names := append([]string{}, tags...)
+	var otherNames []string
+	for name := range tagCommits {
+		otherNames = append(otherNames, name)
+	}
+	sort.Strings(otherNames)
+	names = append(names, otherNames...)
+
+	var prefixedTags []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		tag := strings.TrimPrefix(name, prefix)
+		if !strings.HasPrefix(name, prefix) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if _, err := parseTagVersion(tag, looseSemver); err == nil {
+			prefixedTags = append(prefixedTags, tag)
+		}
+	}
+	return prefixedTags
+}
+
+// The release channels that can be selected with the Channel option. Each one picks the latest version out of a
+// different set of tags, so users can say which kind of release they want rather than writing constraints that match
+// pre-releases by hand.
+const (
+	ChannelStable  = "stable"  // Only final releases, e.g. v1.2.3
+	ChannelRc      = "rc"      // Final releases and release candidates, e.g. v1.2.3-rc1
+	ChannelBeta    = "beta"    // Final releases, release candidates, and betas, e.g. v1.2.3-beta.2
+	ChannelAny     = "any"     // Every version, including pre-releases of any kind, e.g. v1.2.3-alpha.1
+	ChannelNightly = "nightly" // The same as ChannelAny, e.g. v1.2.3-nightly.20220102
+)
+
+// Return an error if channel is not one of the Channel constants
+func ValidateChannel(channel string) error {
+	switch channel {
+	case ChannelStable, ChannelRc, ChannelBeta, ChannelAny, ChannelNightly:
+		return nil
+	default:
+		return fmt.Errorf("Unknown release channel \"%s\". Must be one of: %s, %s, %s, %s, %s.", channel, ChannelStable, ChannelRc, ChannelBeta, ChannelAny, ChannelNightly)
+	}
+}
+
+// Return true if the given version belongs to the given channel. Every version belongs to the empty channel.
+func isVersionInChannel(v *version.Version, channel string) bool {
+	prerelease := strings.ToLower(v.Prerelease())
+	switch channel {
+	case ChannelStable:
+		return prerelease == ""
+	case ChannelRc:
+		return prerelease == "" || strings.HasPrefix(prerelease, "rc")
+	case ChannelBeta:
+		return prerelease == "" || strings.HasPrefix(prerelease, "rc") || strings.HasPrefix(prerelease, "beta")
+	default:
+		return true
+	}
+}
+
+// Return the tag of the latest version that satisfies the given tag constraint (or the latest version overall, if
+// the constraint is empty). If a channel is given, only versions in that channel are considered, and pre-releases are
+// checked against the constraint by their version core, so that e.g. ~>1.2 matches 1.3.0-rc1 on the rc channel. A
+// pre-release also matches a constraint on a pre-release of the same version core, so that e.g. ~>1.4.0-beta matches
+// 1.4.0-beta.2 on any channel.
+func getLatestAcceptableTag(tagConstraint string, tags []string, looseSemver bool, channel string) (string, *FetchError) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	// Sort all tags
+	// Our use of the library go-version means that each tag will each be represented as a *version.Version
+	// go-version normalizes the versions so store off a mapping from the normalized version back to the original tag.
+	versions := []*version.Version{}
+	verToTag := make(map[*version.Version]string)
+	coercedTags := []string{}
+	for _, tag := range tags {
+		v, err := version.NewVersion(tag)
+		if err != nil && looseSemver {
+			coercedTags = append(coercedTags, tag)
+			continue
+		}
+		if err != nil {
+			return "", wrapError(err)
+		}
+
+		versions = append(versions, v)
+		verToTag[v] = tag
+	}
+
+	// When a coerced tag has the same version as another tag, prefer the tag that's a valid version as-is, and
+	// otherwise the first tag listed (GitHub lists the newest tags first)
+	seenVersions := make(map[string]bool)
+	for _, v := range versions {
+		seenVersions[v.String()] = true
+	}
+	for _, tag := range coercedTags {
+		v, err := parseTagVersion(tag, looseSemver)
+		if err != nil {
+			return "", wrapError(err)
+		}
+		if seenVersions[v.String()] {
+			continue
+		}
+		seenVersions[v.String()] = true
+
+		versions = append(versions, v)
+		verToTag[v] = tag
+	}
+	sort.Sort(version.Collection(versions))
+
+	if channel != "" {
+		var channelVersions []*version.Version
+		for _, v := range versions {
+			if isVersionInChannel(v, channel) {
+				channelVersions = append(channelVersions, v)
+			}
+		}
+		if len(channelVersions) == 0 {
+			return "", wrapError(fmt.Errorf("No tags are in the %s channel", channel))
+		}
+		versions = channelVersions
+	}
+
+	// If the tag constraint is empty, use the latest tag
+	if tagConstraint == "" {
+		return verToTag[versions[len(versions)-1]], nil
+	}
+
+	// Find the latest version that matches the given tag constraint
+	constraints, err := version.NewConstraint(tagConstraint)
+	if err != nil {
+		// Explicitly check for a malformed tag value so we can return a nice error to the user
+		if strings.Contains(err.Error(), "Malformed constraint") {
+			return "", newError(invalidTagConstraintExpression, err.Error())
+		} else {
+			return "", wrapError(err)
+		}
+	}
+
+	check := func(v *version.Version) bool {
+		if channel != "" && v.Prerelease() != "" {
+			return constraints.Check(v) || constraints.Check(v.Core())
+		}
+		return constraints.Check(v)
+	}
+
+	latestAcceptableVersion := versions[0]
+	for _, version := range versions {
+		if check(version) && version.GreaterThan(latestAcceptableVersion) {
+			latestAcceptableVersion = version
+		}
+	}
+
+	// check constraint against latest acceptable version
+	if !check(latestAcceptableVersion) {
+		return "", wrapError(errors.New("Tag does not exist"))
+	}
+
+	return verToTag[latestAcceptableVersion], nil
+}
@@ -0,0 +1,343 @@
+package fetch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetLatestAcceptableTag(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tagConstraint string
+		tags          []string
+		expectedTag   string
+	}{
+		{"1.0.7", []string{"1.0.7"}, "1.0.7"},
+
+		{"~> 1.0.0", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.2.3"}, "1.0.9"},
+		{"~> 1.0.7", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.2.3"}, "1.0.9"},
+		{"~> 1.1.0", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.2.3"}, "1.1.0"},
+		{"~> 1.1.1", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0"}, "1.1.3"},
+		{"~> 1.2.1", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0"}, "1.2.3"},
+		{"~> 1.1", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0", "1.3", "2.3"}, "1.4.0"},
+		{"~> 1.2", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0", "1.3", "2.3"}, "1.4.0"},
+		{"~> 1.3", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0"}, "1.4.0"},
+		{"~> 1.3", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0", "1.5"}, "1.5"},
+		{"~> 1.3", []string{"1.2", "1.3", "1.3.1"}, "1.3.1"},
+		{">= 1.3", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0"}, "2.1.0"},
+
+		{">= 1.5", []string{"1.1", "1.2", "1.3", "1.4", "2.0", "2.1"}, "2.1"},
+
+		{"v1.0.7", []string{"v1.0.7"}, "v1.0.7"},
+		{"v1.0.7", []string{}, ""},
+	}
+
+	for _, tc := range cases {
+		tag, err := getLatestAcceptableTag(tc.tagConstraint, tc.tags, false, "")
+		if err != nil {
+			t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+		}
+
+		if tag != tc.expectedTag {
+			t.Fatalf("Given constraint %s and tag list %v, expected %s, but received: %s", tc.tagConstraint, tc.tags, tc.expectedTag, tag)
+		}
+	}
+}
+
+func TestIsTagConstraintSpecificTag(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tagConstraint string
+		desiredTag    string
+		specific      bool
+	}{
+		{"1.0.7", "1.0.7", true},
+		{" 1.0.7	 ", "1.0.7", true},
+		{"=1.0.7", "1.0.7", true},
+		{"= 1.0.7", "1.0.7", true},
+
+		{"~> 1.0.0", "~> 1.0.0", false},
+		{"> 1.3", "> 1.3", false},
+		{">= 1.3", ">= 1.3", false},
+		{"<= 1.3", "<= 1.3", false},
+		{"< 1.3", "< 1.3", false},
+
+		{"v1.0.7", "v1.0.7", true},
+		{" v1.0.7	 ", "v1.0.7", true},
+		{"=v1.0.7", "v1.0.7", true},
+		{"= v1.0.7", "v1.0.7", true},
+	}
+
+	for _, tc := range cases {
+		specific, desiredTag := isTagConstraintSpecificTag(tc.tagConstraint)
+		if specific != tc.specific {
+			t.Fatalf("Given constraint: \"%s\", expected %t, but received %t", tc.tagConstraint, tc.specific, specific)
+		}
+		if desiredTag != tc.desiredTag {
+			t.Fatalf("Given constraint: \"%s\", expected result tag: \"%s\", but received \"%s\"", tc.tagConstraint, tc.desiredTag, desiredTag)
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagOnEmptyConstraint(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tagConstraint string
+		tags          []string
+		expectedTag   string
+	}{
+		{"", []string{"v0.0.1", "v0.0.2", "v0.0.3"}, "v0.0.3"},
+		{"", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.2.3"}, "1.2.3"},
+		{"", []string{"v0.5", "2.6", "1.4", "v1.0.8", "v3.1.4", "1.2.3"}, "v3.1.4"},
+		{"", []string{"v0.5", "2.6", "1.4", "v1.0.8", "v3.1.4", "1.2.3", "4.0"}, "4.0"},
+		{"", []string{"1.0.5", "1.0.6", "1.0.7", "1.0.8", "1.0.9", "1.1.0", "1.1.1", "1.1.2", "1.1.3", "1.2.3", "1.4.0", "2.0.0", "2.1.0"}, "2.1.0"},
+		{"", []string{}, ""},
+	}
+
+	for _, tc := range cases {
+		tag, err := getLatestAcceptableTag(tc.tagConstraint, tc.tags, false, "")
+		if err != nil {
+			t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+		}
+
+		if tag != tc.expectedTag {
+			t.Fatalf("Given constraint %s and tag list %v, expected %s, but received: %s", tc.tagConstraint, tc.tags, tc.expectedTag, tag)
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagOnMalformedConstraint(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tagConstraint string
+	}{
+		{"josh"},
+		{"plump elephants dancing in the night"},
+	}
+
+	for _, tc := range cases {
+		_, err := getLatestAcceptableTag(tc.tagConstraint, []string{"v0.0.1"}, false, "")
+		if err == nil {
+			t.Fatalf("Expected malformed constraint error, but received nothing.")
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagNoSuchTag(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		tagConstraint string
+		tags          []string
+	}{
+		{"~> 0.0.4", []string{"0.0.1", "0.0.2", "0.0.3"}},
+		{"> 0.0.4", []string{"0.0.1", "0.0.2", "0.0.3"}},
+	}
+
+	for _, tc := range cases {
+		_, err := getLatestAcceptableTag(tc.tagConstraint, tc.tags, false, "")
+		if err == nil {
+			t.Fatalf("Expected 'Tag does not exist' but received nothing")
+		}
+	}
+}
+
+func TestParseTagVersionLooseSemver(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tag             string
+		looseSemver     bool
+		expectedVersion string
+	}{
+		{"1.2.3", false, "1.2.3"},
+		{"v1.2.3", false, "1.2.3"},
+		{"release-1.2.3", false, ""},
+		{"release-1.2.3", true, "1.2.3"},
+		{"version_2", true, "2.0.0"},
+		{"rel-1.2", true, "1.2.0"},
+		{"rel-1.2-rc1", true, "1.2.0-rc1"},
+		{"foo/v3.1+build.5", true, "3.1.0+build.5"},
+		{"latest", true, ""},
+		{"release-1.2.3_final", true, ""},
+	}
+
+	for _, tc := range cases {
+		v, err := parseTagVersion(tc.tag, tc.looseSemver)
+		if tc.expectedVersion == "" {
+			if err == nil {
+				t.Fatalf("Expected tag %s with looseSemver=%t to not be a version, but got %s", tc.tag, tc.looseSemver, v)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse tag %s with looseSemver=%t: %s", tc.tag, tc.looseSemver, err)
+		}
+		if v.String() != tc.expectedVersion {
+			t.Fatalf("Expected tag %s with looseSemver=%t to be version %s, but got %s", tc.tag, tc.looseSemver, tc.expectedVersion, v)
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagLooseSemver(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tagConstraint string
+		tags          []string
+		expectedTag   string
+	}{
+		{"~> 1.2", []string{"release-1.3.0", "release-1.2.5", "release-2.0.0"}, "release-1.3.0"},
+		{"", []string{"release-1.3.0", "1.2.5", "rel-2"}, "rel-2"},
+		{">= 1.0", []string{"rel-1.4", "1.4.0", "release-1.4.0"}, "1.4.0"},
+		{">= 1.0", []string{"rel-1.4", "release-1.4.0"}, "rel-1.4"},
+		{"< 1.4", []string{"rel-1.4-rc1", "rel-1.3"}, "rel-1.3"},
+	}
+
+	for _, tc := range cases {
+		tag, err := getLatestAcceptableTag(tc.tagConstraint, tc.tags, true, "")
+		if err != nil {
+			t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+		}
+
+		if tag != tc.expectedTag {
+			t.Fatalf("Given constraint %s and tag list %v, expected %s, but received: %s", tc.tagConstraint, tc.tags, tc.expectedTag, tag)
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagWithChannel(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"v1.4.0-nightly.20220102", "v1.3.0-rc2", "v1.3.0-rc1", "v1.2.1", "v1.2.0", "v1.2.0-beta1"}
+
+	cases := []struct {
+		tagConstraint string
+		channel       string
+		expectedTag   string
+	}{
+		{"", ChannelStable, "v1.2.1"},
+		{"", ChannelRc, "v1.3.0-rc2"},
+		{"", ChannelNightly, "v1.4.0-nightly.20220102"},
+		{"~> 1.2", ChannelStable, "v1.2.1"},
+		{"~> 1.2", ChannelRc, "v1.3.0-rc2"},
+		{"~> 1.2.0", ChannelRc, "v1.2.1"},
+		{"< 1.2.1", ChannelNightly, "v1.2.0"},
+	}
+
+	for _, tc := range cases {
+		tag, err := getLatestAcceptableTag(tc.tagConstraint, tags, false, tc.channel)
+		if err != nil {
+			t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+		}
+
+		if tag != tc.expectedTag {
+			t.Fatalf("Given constraint %s and channel %s, expected %s, but received: %s", tc.tagConstraint, tc.channel, tc.expectedTag, tag)
+		}
+	}
+}
+
+func TestGetLatestAcceptableTagWithEmptyChannel(t *testing.T) {
+	t.Parallel()
+
+	_, err := getLatestAcceptableTag("", []string{"v1.3.0-rc1", "v1.3.0-beta1"}, false, ChannelStable)
+	if err == nil {
+		t.Fatalf("Expected an error for a channel without any tags, but received nothing.")
+	}
+}
+
+func TestGetLatestAcceptableTagWithPrereleaseConstraint(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"v1.5.0-beta.1", "v1.4.1-alpha.1", "v1.4.0-rc1", "v1.4.0-beta.2", "v1.4.0-beta.1", "v1.4.0-alpha.3", "v1.3.9"}
+
+	cases := []struct {
+		tagConstraint string
+		channel       string
+		expectedTag   string
+	}{
+		{"~> 1.4.0-beta", "", "v1.4.0-rc1"},
+		{"~> 1.4.0-beta", ChannelBeta, "v1.4.0-rc1"},
+		{"~> 1.4.0-beta", ChannelAny, "v1.4.0-rc1"},
+		{"< 1.4.0-rc1", ChannelBeta, "v1.4.0-beta.2"},
+		{"", ChannelBeta, "v1.5.0-beta.1"},
+		{"~> 1.4", ChannelBeta, "v1.5.0-beta.1"},
+		{"~> 1.4.0", ChannelStable, ""},
+		{"~> 1.4.0-alpha", ChannelRc, "v1.4.0-rc1"},
+	}
+
+	for _, tc := range cases {
+		tag, err := getLatestAcceptableTag(tc.tagConstraint, tags, false, tc.channel)
+		if tc.expectedTag == "" {
+			if err == nil {
+				t.Fatalf("Given constraint %s and channel %s, expected an error, but received: %s", tc.tagConstraint, tc.channel, tag)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+		}
+
+		if tag != tc.expectedTag {
+			t.Fatalf("Given constraint %s and channel %s, expected %s, but received: %s", tc.tagConstraint, tc.channel, tc.expectedTag, tag)
+		}
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	t.Parallel()
+
+	for _, channel := range []string{ChannelStable, ChannelRc, ChannelBeta, ChannelAny, ChannelNightly} {
+		if err := ValidateChannel(channel); err != nil {
+			t.Fatalf("Expected channel %s to be valid, but received: %s", channel, err)
+		}
+	}
+	if err := ValidateChannel("alpha"); err == nil {
+		t.Fatalf("Expected an error for an unknown channel, but received nothing.")
+	}
+}
+
+func TestTagsWithPrefix(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"v2.0.0", "v1.0.0"}
+	tagCommits := map[string]string{
+		"v2.0.0":                "aaaa",
+		"v1.0.0":                "bbbb",
+		"modules/vpc/v1.2.3":    "cccc",
+		"modules/vpc/v1.3.0":    "dddd",
+		"modules/vpc/latest":    "eeee",
+		"modules/vpc-ng/v9.0.0": "ffff",
+		"modules/eks/v5.0.0":    "0000",
+	}
+
+	cases := []struct {
+		prefix       string
+		expectedTags []string
+	}{
+		{"modules/vpc/", []string{"v1.2.3", "v1.3.0"}},
+		{"modules/vpc", nil},
+		{"modules/eks/", []string{"v5.0.0"}},
+		{"modules/rds/", nil},
+		{"v", []string{"2.0.0", "1.0.0"}},
+	}
+
+	for _, tc := range cases {
+		prefixedTags := tagsWithPrefix(tc.prefix, tags, tagCommits, false)
+		if !reflect.DeepEqual(prefixedTags, tc.expectedTags) {
+			t.Fatalf("Given prefix %s, expected tags %v, but received: %v", tc.prefix, tc.expectedTags, prefixedTags)
+		}
+	}
+
+	tag, err := getLatestAcceptableTag("~> 1.2", tagsWithPrefix("modules/vpc/", tags, tagCommits, false), false, "")
+	if err != nil {
+		t.Fatalf("Failed on call to getLatestAcceptableTag: %s", err.details)
+	}
+	if tag != "v1.3.0" {
+		t.Fatalf("Expected v1.3.0, but received: %s", tag)
+	}
+}
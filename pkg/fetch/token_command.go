@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Run the given shell command, such as "gh auth token" or "vault kv get -field=token secret/github", and return the
+// GitHub token it writes to stdout. This lets the token come from a credential helper at runtime, rather than live in
+// an env var or in CI config. The command's output is never logged, and only its stderr is included in errors.
+func RunTokenCommand(ctx context.Context, command string) (string, error) {
+	cmd := tokenCommand(ctx, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", fmt.Errorf("The token command \"%s\" failed: %s %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseToken(stdout.String(), fmt.Sprintf("The output of the token command \"%s\"", command))
+}
+
+// Read the GitHub token from the file at the given path, such as a secret a CI system or Kubernetes mounts as a file, or
+// from stdin if the path is "-". This keeps the token out of the process environment, where it can leak into logs and
+// is inherited by every child process.
+func ReadTokenFile(path string, stdin io.Reader) (string, error) {
+	if path == "-" {
+		contents, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("Error reading the GitHub token from stdin: %s", err)
+		}
+		return parseToken(string(contents), "The input on stdin")
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading the GitHub token file: %s", err)
+	}
+	return parseToken(string(contents), fmt.Sprintf("The token file %s", path))
+}
+
+// Return the single token in output, which is described by source in errors, ignoring the whitespace around it
+func parseToken(output string, source string) (string, error) {
+	token := strings.TrimSpace(output)
+	if token == "" {
+		return "", fmt.Errorf("%s did not contain a token.", source)
+	}
+	if strings.ContainsAny(token, " \t\r\n") {
+		return "", fmt.Errorf("%s contained more than a single token.", source)
+	}
+	return token, nil
+}
+
+// Return the command that runs the given command line with the platform's shell
+func tokenCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTokenCommand(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		command   string
+		expected  string
+		expectErr bool
+	}{
+		{"echo ghp_abc123", "ghp_abc123", false},
+		{"printf '  github_pat_abc123\\n\\n'", "github_pat_abc123", false},
+		{"true", "", true},
+		{"echo ghp_abc123 ghp_def456", "", true},
+		{"echo 'no credentials' >&2; exit 1", "", true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.command, func(t *testing.T) {
+			t.Parallel()
+			token, err := RunTokenCommand(context.Background(), tc.command)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, token)
+		})
+	}
+}
+
+func TestRunTokenCommandErrorIncludesStderr(t *testing.T) {
+	t.Parallel()
+
+	_, err := RunTokenCommand(context.Background(), "echo 'not logged in' >&2; exit 1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not logged in")
+}
+
+func TestReadTokenFile(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	testCases := []struct {
+		name      string
+		contents  string
+		expected  string
+		expectErr bool
+	}{
+		{"token", "ghp_abc123", "ghp_abc123", false},
+		{"trailing-newline", "github_pat_abc123\n", "github_pat_abc123", false},
+		{"empty", "\n", "", true},
+		{"two-tokens", "ghp_abc123\nghp_def456\n", "", true},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(tmpDir, tc.name)
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.contents), 0600))
+
+			for _, read := range []func() (string, error){
+				func() (string, error) { return ReadTokenFile(path, nil) },
+				func() (string, error) { return ReadTokenFile("-", strings.NewReader(tc.contents)) },
+			} {
+				token, err := read()
+				if tc.expectErr {
+					assert.Error(t, err)
+					continue
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, token)
+			}
+		})
+	}
+
+	_, err := ReadTokenFile(filepath.Join(tmpDir, "does-not-exist"), nil)
+	assert.Error(t, err)
+}
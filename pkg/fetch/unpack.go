@@ -0,0 +1,185 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Return the archive extension of the given file name (e.g. ".tar.gz"), or an empty string if no registered Unpacker
+// knows how to unpack the file.
+func getArchiveExtension(fileName string) string {
+	_, ext := findUnpacker(fileName)
+	return ext
+}
+
+// Return an error if any of the given --unpack-include globs is malformed
+func ValidateUnpackInclude(globs []string) error {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("Invalid unpack include glob \"%s\": %s", glob, err)
+		}
+	}
+	return nil
+}
+
+// Return true if the archive member with the given slash-separated name should be unpacked according to the Include
+// option: if it's empty, or if the member, or any directory it's in, matches one of its globs
+func (options ExtractOptions) includes(name string) bool {
+	if len(options.Include) == 0 {
+		return true
+	}
+
+	candidate := strings.Trim(path.Clean("/"+name), "/")
+	for {
+		for _, glob := range options.Include {
+			if matched, _ := path.Match(strings.Trim(glob, "/"), candidate); matched {
+				return true
+			}
+		}
+		slash := strings.LastIndex(candidate, "/")
+		if slash < 0 {
+			return false
+		}
+		candidate = candidate[:slash]
+	}
+}
+
+// Unpack the release asset at the given path into destPath, if it's an archive fetch knows how to unpack. Unless
+// keepArchive is true, the archive is deleted once it has been unpacked. Only the DirMode and FileMode of the given
+// options apply, as the permissions stored in release asset archives are always preserved.
+func unpackReleaseAsset(logger *logrus.Entry, assetPath string, destPath string, keepArchive bool, options ExtractOptions) error {
+	if getArchiveExtension(assetPath) == "" {
+		logger.Infof("Not unpacking %s as it is not a recognized archive format\n", assetPath)
+		return nil
+	}
+
+	logger.Infof("Unpacking %s to %s ...\n", assetPath, destPath)
+	fileCount, err := unpackArchive(assetPath, destPath, options)
+	if err != nil {
+		return fmt.Errorf("Error occurred while unpacking release asset %s: %s", assetPath, err)
+	}
+
+	plural := ""
+	if fileCount != 1 {
+		plural = "s"
+	}
+	logger.Infof("%d file%s unpacked\n", fileCount, plural)
+
+	if !keepArchive {
+		if err := os.Remove(assetPath); err != nil {
+			return fmt.Errorf("Failed to delete release asset archive %s after unpacking: %s", assetPath, err)
+		}
+	}
+
+	return nil
+}
+
+// Unpack the archive at archivePath into destPath with the registered Unpacker for its file extension. Returns the
+// number of files (not directories) unpacked.
+func unpackArchive(archivePath string, destPath string, options ExtractOptions) (int, error) {
+	unpacker, _ := findUnpacker(archivePath)
+	if unpacker == nil {
+		return 0, fmt.Errorf("The archive format of %s is not supported", archivePath)
+	}
+	return unpacker.Unpack(archivePath, newUnpackDest(destPath, options))
+}
+
+// Unpack every entry of the zip file at zipPath that options include into root
+func unpackZip(zipPath string, root destRoot, options ExtractOptions) (int, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	fileCount := 0
+	for _, f := range r.File {
+		if !options.includes(f.Name) {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			if err := root.MkdirAll(f.Name, options.getDirMode()); err != nil {
+				return fileCount, err
+			}
+			continue
+		}
+
+		readCloser, err := f.Open()
+		if err != nil {
+			return fileCount, fmt.Errorf("Failed to open file %s: %s", f.Name, err)
+		}
+		err = writeUnpackedFile(root, f.Name, readCloser, f.Mode().Perm(), options)
+		readCloser.Close()
+		if err != nil {
+			return fileCount, err
+		}
+		fileCount++
+	}
+
+	return fileCount, nil
+}
+
+// Unpack every regular file and directory in the given tar stream that options include into root
+func unpackTar(reader io.Reader, root destRoot, options ExtractOptions) (int, error) {
+	tarReader := tar.NewReader(reader)
+
+	fileCount := 0
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return fileCount, nil
+		}
+		if err != nil {
+			return fileCount, err
+		}
+		if !options.includes(header.Name) {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(header.Name, options.getDirMode()); err != nil {
+				return fileCount, err
+			}
+		case tar.TypeReg:
+			if err := writeUnpackedFile(root, header.Name, tarReader, os.FileMode(header.Mode).Perm(), options); err != nil {
+				return fileCount, err
+			}
+			fileCount++
+		}
+	}
+}
+
+// Write the contents of the given reader to a new file with the given name in root, creating any parent directories
+// as necessary. If options.FileMode is set, it takes precedence over the given mode.
+func writeUnpackedFile(root destRoot, name string, reader io.Reader, mode os.FileMode, options ExtractOptions) error {
+	if err := root.MkdirAll(filepath.Dir(name), options.getDirMode()); err != nil {
+		return err
+	}
+
+	if options.FileMode != 0 {
+		mode = options.FileMode
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("Failed to create file %s: %s", name, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return fmt.Errorf("Failed to write file %s: %s", name, err)
+	}
+	return nil
+}
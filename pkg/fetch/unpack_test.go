@@ -0,0 +1,289 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestGetArchiveExtension(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fileName    string
+		expectedExt string
+	}{
+		{"tool_linux_amd64.zip", ".zip"},
+		{"tool_linux_amd64.tar.gz", ".tar.gz"},
+		{"tool_linux_amd64.TGZ", ".tgz"},
+		{"tool_linux_amd64.tar.xz", ".tar.xz"},
+		{"tool_linux_amd64.tar.bz2", ".tar.bz2"},
+		{"tool_linux_amd64.gz", ".gz"},
+		{"tool_linux_amd64.tar", ".tar"},
+		{"tool_linux_amd64.tar.zst", ".tar.zst"},
+		{"tool_linux_amd64", ""},
+		{"tool_linux_amd64.exe", ""},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.expectedExt, getArchiveExtension(tc.fileName), "file name: %s", tc.fileName)
+	}
+}
+
+func TestUnpackArchive(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		archiveName   string
+		createArchive func(t *testing.T, path string)
+		expectedFiles []string
+	}{
+		{"zip", "tool.zip", createTestZip, []string{"bin/tool", "README.md"}},
+		{"tar.gz", "tool.tar.gz", createTestTarGz, []string{"bin/tool", "README.md"}},
+		{"tgz", "tool.tgz", createTestTarGz, []string{"bin/tool", "README.md"}},
+		{"tar.xz", "tool.tar.xz", createTestTarXz, []string{"bin/tool", "README.md"}},
+		{"gz", "tool.gz", createTestGz, []string{"tool"}},
+		{"tar", "tool.tar", createTestTar, []string{"bin/tool", "README.md"}},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			archiveDir := t.TempDir()
+			destDir := t.TempDir()
+			archivePath := filepath.Join(archiveDir, tc.archiveName)
+			tc.createArchive(t, archivePath)
+
+			fileCount, err := unpackArchive(archivePath, destDir, ExtractOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.expectedFiles), fileCount)
+
+			for _, expectedFile := range tc.expectedFiles {
+				assert.FileExists(t, filepath.Join(destDir, expectedFile))
+			}
+		})
+	}
+}
+
+func TestUnpackArchiveInclude(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		archiveName   string
+		createArchive func(t *testing.T, path string)
+	}{
+		{"zip", "tool.zip", createTestZip},
+		{"tar.gz", "tool.tar.gz", createTestTarGz},
+	}
+
+	for _, tc := range cases {
+		// The following is necessary to make sure tc's values don't
+		// get updated due to concurrency within the scope of t.Run(..) below
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			destDir := t.TempDir()
+			archivePath := filepath.Join(t.TempDir(), tc.archiveName)
+			tc.createArchive(t, archivePath)
+
+			fileCount, err := unpackArchive(archivePath, destDir, ExtractOptions{Include: []string{"bin/*"}})
+			require.NoError(t, err)
+			assert.Equal(t, 1, fileCount)
+			assert.FileExists(t, filepath.Join(destDir, "bin", "tool"))
+			assert.NoFileExists(t, filepath.Join(destDir, "README.md"))
+		})
+	}
+}
+
+func TestExtractOptionsIncludes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		include  []string
+		name     string
+		expected bool
+	}{
+		{nil, "README.md", true},
+		{[]string{"bin/*"}, "bin/tool", true},
+		{[]string{"bin/*"}, "./bin/tool", true},
+		{[]string{"bin/*"}, "bin/", false},
+		{[]string{"bin/*"}, "README.md", false},
+		{[]string{"bin/*"}, "docs/bin/tool", false},
+		{[]string{"bin"}, "bin/completions/tool.bash", true},
+		{[]string{"*/bin/*"}, "tool_1.0/bin/tool", true},
+		{[]string{"*.md", "bin/*"}, "README.md", true},
+		{[]string{"/bin/"}, "bin/tool", true},
+	}
+
+	for _, tc := range cases {
+		options := ExtractOptions{Include: tc.include}
+		assert.Equal(t, tc.expected, options.includes(tc.name), "include %v, name %s", tc.include, tc.name)
+	}
+}
+
+func TestUnpackReleaseAssetRemovesArchive(t *testing.T) {
+	t.Parallel()
+
+	logger := GetProjectLogger()
+	destDir := t.TempDir()
+	archivePath := filepath.Join(destDir, "tool.tar.gz")
+	createTestTarGz(t, archivePath)
+
+	require.NoError(t, unpackReleaseAsset(logger, archivePath, destDir, true, ExtractOptions{}))
+	assert.FileExists(t, archivePath)
+
+	require.NoError(t, unpackReleaseAsset(logger, archivePath, destDir, false, ExtractOptions{}))
+	assert.NoFileExists(t, archivePath)
+	assert.FileExists(t, filepath.Join(destDir, "bin", "tool"))
+}
+
+func TestUnpackArchiveRejectsPathTraversal(t *testing.T) {
+	t.Parallel()
+
+	archivePath := filepath.Join(t.TempDir(), "evil.tar.gz")
+	writeTestTar(t, archivePath, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, map[string]string{"../evil.sh": "echo evil"})
+
+	_, err := unpackArchive(archivePath, t.TempDir(), ExtractOptions{})
+	assert.Error(t, err)
+}
+
+var testArchiveContents = map[string]string{
+	"bin/tool":  "#!/bin/sh\necho hello\n",
+	"README.md": "# tool\n",
+}
+
+func createTestZip(t *testing.T, path string) {
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	for name, contents := range testArchiveContents {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+}
+
+func createTestTarGz(t *testing.T, path string) {
+	writeTestTar(t, path, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, testArchiveContents)
+}
+
+func createTestTarXz(t *testing.T, path string) {
+	writeTestTar(t, path, func(w io.Writer) io.WriteCloser {
+		xzWriter, err := xz.NewWriter(w)
+		require.NoError(t, err)
+		return xzWriter
+	}, testArchiveContents)
+}
+
+func createTestTar(t *testing.T, path string) {
+	writeTestTar(t, path, func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }, testArchiveContents)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func createTestGz(t *testing.T, path string) {
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+
+	gzipWriter := gzip.NewWriter(out)
+	_, err = gzipWriter.Write([]byte(testArchiveContents["bin/tool"]))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+}
+
+func writeTestTar(t *testing.T, path string, compress func(io.Writer) io.WriteCloser, contents map[string]string) {
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+
+	compressedWriter := compress(out)
+	tarWriter := tar.NewWriter(compressedWriter)
+	for name, body := range contents {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, compressedWriter.Close())
+}
+
+func TestUnpackArchiveTarZst(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("The zstd command is not installed")
+	}
+
+	archiveDir := t.TempDir()
+	tarPath := filepath.Join(archiveDir, "tool.tar")
+	createTestTar(t, tarPath)
+	archivePath := filepath.Join(archiveDir, "tool.tar.zst")
+	require.NoError(t, exec.Command("zstd", "-q", tarPath, "-o", archivePath).Run())
+
+	destDir := t.TempDir()
+	fileCount, err := unpackArchive(archivePath, destDir, ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fileCount)
+	assert.FileExists(t, filepath.Join(destDir, "bin", "tool"))
+}
+
+// Unpacks a made-up archive format, in which the archive is just the contents of a single file named "unpacked"
+type testUnpacker struct{}
+
+func (testUnpacker) Extensions() []string {
+	return []string{".fetch-test"}
+}
+
+func (testUnpacker) Unpack(archivePath string, dest *UnpackDest) (int, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return 1, dest.WriteFile("unpacked", file, 0644)
+}
+
+func TestRegisterUnpacker(t *testing.T) {
+	t.Parallel()
+
+	RegisterUnpacker(testUnpacker{})
+	assert.Equal(t, ".fetch-test", getArchiveExtension("tool.FETCH-TEST"))
+
+	archivePath := filepath.Join(t.TempDir(), "tool.fetch-test")
+	require.NoError(t, os.WriteFile(archivePath, []byte("contents"), 0644))
+
+	destDir := t.TempDir()
+	fileCount, err := unpackArchive(archivePath, destDir, ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fileCount)
+	assert.FileExists(t, filepath.Join(destDir, "unpacked"))
+}
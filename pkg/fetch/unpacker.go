@@ -0,0 +1,231 @@
+package fetch
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Unpacks the archives of one format, such as tarballs compressed with a particular algorithm. Register an Unpacker
+// with RegisterUnpacker to teach fetch a new archive format.
+type Unpacker interface {
+	// The file extensions of the archives this Unpacker handles, such as ".tar.gz", in lower case
+	Extensions() []string
+
+	// Unpack the archive at archivePath into dest, and return the number of files (not directories) unpacked
+	Unpack(archivePath string, dest *UnpackDest) (int, error)
+}
+
+// The directory an Unpacker writes to. Every write is checked, so that no archive entry can be written outside of the
+// directory (e.g. with a name like "../../etc/passwd", or through a symbolic link already in the directory).
+type UnpackDest struct {
+	root    destRoot
+	options ExtractOptions
+}
+
+func newUnpackDest(destPath string, options ExtractOptions) *UnpackDest {
+	return &UnpackDest{root: newDestRoot(destPath, options.FollowDestSymlinks), options: options}
+}
+
+// Return true if the archive member with the given slash-separated name should be unpacked, according to the Include
+// option. Mkdir and WriteFile skip members that aren't included, so Unpackers only need this to count files.
+func (dest *UnpackDest) Includes(name string) bool {
+	return dest.options.includes(name)
+}
+
+// Create the directory with the given slash-separated name, and any parents, in the destination, unless it isn't
+// included
+func (dest *UnpackDest) Mkdir(name string) error {
+	if !dest.Includes(name) {
+		return nil
+	}
+	return dest.root.MkdirAll(name, dest.options.getDirMode())
+}
+
+// Write the contents of reader to the file with the given slash-separated name in the destination, creating any
+// parent directories, unless it isn't included. The DirMode and FileMode options, if set, take precedence over mode.
+func (dest *UnpackDest) WriteFile(name string, reader io.Reader, mode os.FileMode) error {
+	if !dest.Includes(name) {
+		return nil
+	}
+	return writeUnpackedFile(dest.root, name, reader, mode, dest.options)
+}
+
+// Unpack the included members of the given tar stream into the destination, and return the number of files unpacked.
+// This is all that most Unpackers of compressed tarballs need once they've decompressed the archive.
+func (dest *UnpackDest) UnpackTar(reader io.Reader) (int, error) {
+	return unpackTar(reader, dest.root, dest.options)
+}
+
+var unpackersMutex sync.RWMutex
+var unpackers = map[string]Unpacker{}
+
+// Register the given Unpacker for each of its extensions, replacing any Unpacker already registered for them
+func RegisterUnpacker(unpacker Unpacker) {
+	unpackersMutex.Lock()
+	defer unpackersMutex.Unlock()
+
+	for _, ext := range unpacker.Extensions() {
+		unpackers[strings.ToLower(ext)] = unpacker
+	}
+}
+
+// Return the Unpacker for the given file name, along with the extension it was picked by, or nil if no registered
+// Unpacker handles it. The longest matching extension wins, so that a tarball such as "tool.tar.gz" isn't treated as a
+// single gzipped file.
+func findUnpacker(fileName string) (Unpacker, string) {
+	unpackersMutex.RLock()
+	defer unpackersMutex.RUnlock()
+
+	lowerName := strings.ToLower(fileName)
+	var found Unpacker
+	foundExt := ""
+	for ext, unpacker := range unpackers {
+		if strings.HasSuffix(lowerName, ext) && len(ext) > len(foundExt) {
+			found = unpacker
+			foundExt = ext
+		}
+	}
+	return found, foundExt
+}
+
+func init() {
+	RegisterUnpacker(zipUnpacker{})
+	RegisterUnpacker(gzipFileUnpacker{})
+	RegisterUnpacker(tarUnpacker{
+		extensions: []string{".tar"},
+		decompress: func(reader io.Reader) (io.ReadCloser, error) { return io.NopCloser(reader), nil },
+	})
+	RegisterUnpacker(tarUnpacker{
+		extensions: []string{".tar.gz", ".tgz"},
+		decompress: func(reader io.Reader) (io.ReadCloser, error) { return gzip.NewReader(reader) },
+	})
+	RegisterUnpacker(tarUnpacker{
+		extensions: []string{".tar.xz", ".txz"},
+		decompress: func(reader io.Reader) (io.ReadCloser, error) {
+			xzReader, err := xz.NewReader(reader)
+			return io.NopCloser(xzReader), err
+		},
+	})
+	RegisterUnpacker(tarUnpacker{
+		extensions: []string{".tar.bz2", ".tbz2"},
+		decompress: func(reader io.Reader) (io.ReadCloser, error) { return io.NopCloser(bzip2.NewReader(reader)), nil },
+	})
+	// There's no zstd decoder in the standard library, so this relies on the zstd CLI being installed
+	RegisterUnpacker(tarUnpacker{
+		extensions: []string{".tar.zst", ".tzst"},
+		decompress: func(reader io.Reader) (io.ReadCloser, error) { return newCommandReader(reader, "zstd", "-d", "-c") },
+	})
+}
+
+// Unpacks zip files
+type zipUnpacker struct{}
+
+func (zipUnpacker) Extensions() []string {
+	return []string{".zip"}
+}
+
+func (zipUnpacker) Unpack(archivePath string, dest *UnpackDest) (int, error) {
+	return unpackZip(archivePath, dest.root, dest.options)
+}
+
+// Unpacks a single gzipped file, such as tool.gz, into a file named without the .gz extension
+type gzipFileUnpacker struct{}
+
+func (gzipFileUnpacker) Extensions() []string {
+	return []string{".gz"}
+}
+
+func (gzipFileUnpacker) Unpack(archivePath string, dest *UnpackDest) (int, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer gzipReader.Close()
+
+	name := strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+	if !dest.Includes(name) {
+		return 0, nil
+	}
+	return 1, dest.WriteFile(name, gzipReader, 0644)
+}
+
+// Unpacks tarballs compressed with the algorithm that decompress undoes
+type tarUnpacker struct {
+	extensions []string
+	decompress func(reader io.Reader) (io.ReadCloser, error)
+}
+
+func (unpacker tarUnpacker) Extensions() []string {
+	return unpacker.extensions
+}
+
+func (unpacker tarUnpacker) Unpack(archivePath string, dest *UnpackDest) (int, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := unpacker.decompress(file)
+	if err != nil {
+		return 0, err
+	}
+
+	fileCount, err := dest.UnpackTar(reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	return fileCount, err
+}
+
+// Reads the output of an external command, such as a decompressor, that's fed the contents of a reader on stdin
+type commandReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *strings.Builder
+}
+
+// Start the given command with input on its stdin, and return a reader for its stdout. Closing the reader waits for
+// the command to exit, and returns an error if it failed.
+func newCommandReader(input io.Reader, name string, args ...string) (io.ReadCloser, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("Unpacking this archive format requires the %s command, which is not installed: %s", name, err)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = input
+	stderr := &strings.Builder{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+func (reader *commandReader) Close() error {
+	// Drain the output, so the command doesn't block writing to a pipe nobody reads if the caller stopped early
+	io.Copy(io.Discard, reader.ReadCloser)
+	if err := reader.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %s %s", reader.cmd.Path, err, strings.TrimSpace(reader.stderr.String()))
+	}
+	return nil
+}
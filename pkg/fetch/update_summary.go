@@ -0,0 +1,224 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The versions the entries of a manifest were last fetched at, as written to a versions file after each run of
+// "fetch manifest", so that the next run can report what it updated
+type ManifestVersions struct {
+	Entries []ManifestEntryVersion `json:"entries"`
+}
+
+// The version a single manifest entry was fetched at. Entries are identified by their repo and destination, so that
+// entries can be added, removed, or reordered between runs.
+type ManifestEntryVersion struct {
+	Repo        string            `json:"repo"`
+	Destination string            `json:"destination"`
+	Version     string            `json:"version"`          // The resolved tag, or else the commit, branch, ref, or URL of the entry
+	Tag         string            `json:"tag,omitempty"`    // The resolved tag, if the entry has a tag constraint
+	Commit      string            `json:"commit,omitempty"` // The SHA of the commit the resolved tag points to, if known
+	Checksums   map[string]string `json:"checksums,omitempty"`
+}
+
+// Read the versions file at the given path. A file that doesn't exist yet has no versions, as on the first run.
+func LoadManifestVersions(path string) (*ManifestVersions, error) {
+	contents, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ManifestVersions{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions ManifestVersions
+	if err := json.Unmarshal(contents, &versions); err != nil {
+		return nil, fmt.Errorf("Could not parse versions file %s: %s", path, err)
+	}
+	return &versions, nil
+}
+
+// Return the versions the entries of the manifest were fetched at, given the results RunManifest returned for them. The
+// checksums of the files each entry downloaded are only recorded if the RecordChecksums option was set.
+func NewManifestVersions(manifest *Manifest, results []*Result) *ManifestVersions {
+	versions := &ManifestVersions{Entries: []ManifestEntryVersion{}}
+	for i, result := range results {
+		entry := manifest.Entries[i]
+		version := ManifestEntryVersion{Repo: entry.Repo, Destination: entry.Destination, Version: entry.version(result)}
+		if entry.Tag != "" {
+			version.Tag = result.Tag
+			version.Commit = result.TagCommitSha
+		}
+		for _, file := range result.Files {
+			if file.Sha256 == "" {
+				continue
+			}
+			if version.Checksums == nil {
+				version.Checksums = map[string]string{}
+			}
+			version.Checksums[file.Path] = file.Sha256
+		}
+		versions.Entries = append(versions.Entries, version)
+	}
+	return versions
+}
+
+// Return the version this entry was fetched at: the tag its constraint resolved to, or else the commit, branch, ref, or
+// URL it names. The resolved tag of an entry without a tag constraint is just the latest tag in the repo, so it isn't used.
+func (entry ManifestEntry) version(result *Result) string {
+	switch {
+	case entry.Url != "":
+		return entry.Url
+	case entry.Tag != "":
+		return result.Tag
+	case entry.Commit != "":
+		return entry.Commit
+	case entry.Branch != "":
+		return entry.Branch
+	default:
+		return entry.Ref
+	}
+}
+
+// Write the versions to the file at the given path as JSON
+func (versions *ManifestVersions) Write(path string) error {
+	contents, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// Return the version of the entry with the given repo and destination, or nil if there is none
+func (versions *ManifestVersions) find(repo string, destination string) *ManifestEntryVersion {
+	for i, version := range versions.Entries {
+		if version.Repo == repo && version.Destination == destination {
+			return &versions.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Return true if this entry was fetched at a different version than previous, which is nil for an entry that's new
+func (version ManifestEntryVersion) isUpdateOf(previous *ManifestEntryVersion) bool {
+	if previous == nil || previous.Version != version.Version {
+		return true
+	}
+	// A tag that was moved, or a branch that has new commits, shows up as a change of commit or checksums
+	if previous.Commit != "" && version.Commit != "" && previous.Commit != version.Commit {
+		return true
+	}
+	for path, checksum := range version.Checksums {
+		if previousChecksum, ok := previous.Checksums[path]; ok && previousChecksum != checksum {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a markdown summary of the entries of current that were updated since previous, with their old and new
+// versions, links to their changelogs, and the checksums of the files they downloaded, to writer. This is meant to be
+// used as the description of an automated pull request that commits the updated files.
+func WriteUpdateSummary(writer io.Writer, previous *ManifestVersions, current *ManifestVersions) error {
+	var updated []ManifestEntryVersion
+	var previousVersions []*ManifestEntryVersion
+	for _, version := range current.Entries {
+		previousVersion := previous.find(version.Repo, version.Destination)
+		if version.isUpdateOf(previousVersion) {
+			updated = append(updated, version)
+			previousVersions = append(previousVersions, previousVersion)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("## Dependency updates\n\n")
+	if len(updated) == 0 {
+		fmt.Fprintf(&out, "All %d manifest entries are up to date.\n", len(current.Entries))
+		_, err := io.WriteString(writer, out.String())
+		return err
+	}
+
+	fmt.Fprintf(&out, "%d of %d manifest entries were updated.\n\n", len(updated), len(current.Entries))
+	out.WriteString("| Repo | Destination | Old version | New version | Changelog |\n")
+	out.WriteString("|------|-------------|-------------|-------------|-----------|\n")
+	for i, version := range updated {
+		oldVersion := "_new_"
+		if previousVersions[i] != nil {
+			oldVersion = markdownCode(previousVersions[i].Version)
+		}
+		fmt.Fprintf(&out, "| %s | %s | %s | %s | %s |\n", markdownRepoLink(version.Repo), markdownCode(version.Destination), oldVersion, markdownCode(version.Version), markdownChangelogLink(version.Repo, previousVersions[i], version))
+	}
+
+	var checksumLines []string
+	for _, version := range updated {
+		for path, checksum := range version.Checksums {
+			checksumLines = append(checksumLines, fmt.Sprintf("| %s | %s |\n", markdownCode(path), markdownCode(checksum)))
+		}
+	}
+	if len(checksumLines) > 0 {
+		sort.Strings(checksumLines)
+		out.WriteString("\n### Checksums\n\n")
+		out.WriteString("| File | SHA256 |\n")
+		out.WriteString("|------|--------|\n")
+		out.WriteString(strings.Join(checksumLines, ""))
+	}
+
+	_, err := io.WriteString(writer, out.String())
+	return err
+}
+
+// Return the given text as inline code in a table cell. Pipes would end the cell, even in code, so they're escaped.
+func markdownCode(text string) string {
+	return "`" + strings.ReplaceAll(strings.ReplaceAll(text, "`", "'"), "|", "\\|") + "`"
+}
+
+// Return the owner and name of the given GitHub repo, e.g. foo/bar, and the URL of the repo without anything after
+// them, or empty strings if it isn't a GitHub repo URL
+func gitHubRepoPath(repoUrl string) (string, string) {
+	if ParseBitbucketServerRepo(repoUrl, "") != nil {
+		return "", ""
+	}
+	parsed, err := url.Parse(repoUrl)
+	if err != nil || parsed.Host == "" {
+		return "", ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return "", ""
+	}
+	repoPath := segments[0] + "/" + segments[1]
+	return repoPath, fmt.Sprintf("%s://%s/%s", parsed.Scheme, parsed.Host, repoPath)
+}
+
+// Return a link to the given repo, labelled with its owner and name if it's a GitHub repo. Entries that download from a
+// URL have no repo, and their URL is their version instead.
+func markdownRepoLink(repoUrl string) string {
+	if repoUrl == "" {
+		return "-"
+	}
+	repoPath, baseUrl := gitHubRepoPath(repoUrl)
+	if repoPath == "" {
+		return repoUrl
+	}
+	return fmt.Sprintf("[%s](%s)", repoPath, baseUrl)
+}
+
+// Return a link to the changes between the old and new versions of a GitHub repo: a comparison of the two tags, or the
+// release notes of the new tag if there's no old one. There's nothing to link to for other versions, such as branches.
+func markdownChangelogLink(repoUrl string, previous *ManifestEntryVersion, current ManifestEntryVersion) string {
+	_, baseUrl := gitHubRepoPath(repoUrl)
+	if baseUrl == "" || current.Tag == "" {
+		return "-"
+	}
+	if previous == nil || previous.Tag == "" || previous.Tag == current.Tag {
+		return fmt.Sprintf("[Release notes](%s/releases/tag/%s)", baseUrl, url.PathEscape(current.Tag))
+	}
+	return fmt.Sprintf("[%s...%s](%s/compare/%s...%s)", previous.Tag, current.Tag, baseUrl, url.PathEscape(previous.Tag), url.PathEscape(current.Tag))
+}
@@ -0,0 +1,90 @@
+package fetch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewManifestVersions(t *testing.T) {
+	t.Parallel()
+
+	manifest := &Manifest{Entries: []ManifestEntry{
+		{Repo: "https://github.com/foo/bar", Tag: "~>1.0", ReleaseAsset: "tool", Destination: "bin"},
+		{Repo: "https://github.com/foo/modules", Branch: "main", SourcePaths: []string{"/vpc"}, Destination: "modules/vpc"},
+	}}
+	results := []*Result{
+		{Tag: "v1.2.0", TagCommitSha: "abc123", Files: []FetchedFile{{Kind: FetchedReleaseAsset, Path: "bin/tool", Sha256: "1111"}}},
+		{Tag: "v0.9.0", TagCommitSha: "def456"},
+	}
+
+	versions := NewManifestVersions(manifest, results)
+	assert.Equal(t, []ManifestEntryVersion{
+		{Repo: "https://github.com/foo/bar", Destination: "bin", Version: "v1.2.0", Tag: "v1.2.0", Commit: "abc123", Checksums: map[string]string{"bin/tool": "1111"}},
+		{Repo: "https://github.com/foo/modules", Destination: "modules/vpc", Version: "main"},
+	}, versions.Entries)
+}
+
+func TestManifestVersionsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "versions.json")
+	versions, err := LoadManifestVersions(path)
+	require.NoError(t, err)
+	assert.Empty(t, versions.Entries)
+
+	versions.Entries = append(versions.Entries, ManifestEntryVersion{Repo: "https://github.com/foo/bar", Destination: "bin", Version: "v1.2.0", Tag: "v1.2.0"})
+	require.NoError(t, versions.Write(path))
+	loaded, err := LoadManifestVersions(path)
+	require.NoError(t, err)
+	assert.Equal(t, versions, loaded)
+}
+
+func TestWriteUpdateSummary(t *testing.T) {
+	t.Parallel()
+
+	previous := &ManifestVersions{Entries: []ManifestEntryVersion{
+		{Repo: "https://github.com/foo/bar", Destination: "bin", Version: "v1.1.0", Tag: "v1.1.0", Checksums: map[string]string{"bin/tool": "0000"}},
+		{Repo: "https://github.com/foo/baz", Destination: "baz", Version: "v2.0.0", Tag: "v2.0.0", Commit: "aaa"},
+		{Repo: "https://github.com/foo/modules", Destination: "modules", Version: "main", Checksums: map[string]string{"modules/main.tf": "2222"}},
+	}}
+	current := &ManifestVersions{Entries: []ManifestEntryVersion{
+		{Repo: "https://github.com/foo/bar", Destination: "bin", Version: "v1.2.0", Tag: "v1.2.0", Checksums: map[string]string{"bin/tool": "1111"}},
+		{Repo: "https://github.com/foo/baz", Destination: "baz", Version: "v2.0.0", Tag: "v2.0.0", Commit: "aaa"},
+		{Repo: "https://github.com/foo/modules", Destination: "modules", Version: "main", Checksums: map[string]string{"modules/main.tf": "3333"}},
+		{Repo: "https://ghe.mycompany.com/ops/tool", Destination: "tool", Version: "v0.1.0", Tag: "v0.1.0"},
+	}}
+
+	var summary strings.Builder
+	require.NoError(t, WriteUpdateSummary(&summary, previous, current))
+	assert.Equal(t, `## Dependency updates
+
+3 of 4 manifest entries were updated.
+
+| Repo | Destination | Old version | New version | Changelog |
+|------|-------------|-------------|-------------|-----------|
+| [foo/bar](https://github.com/foo/bar) | `+"`bin`"+` | `+"`v1.1.0`"+` | `+"`v1.2.0`"+` | [v1.1.0...v1.2.0](https://github.com/foo/bar/compare/v1.1.0...v1.2.0) |
+| [foo/modules](https://github.com/foo/modules) | `+"`modules`"+` | `+"`main`"+` | `+"`main`"+` | - |
+| [ops/tool](https://ghe.mycompany.com/ops/tool) | `+"`tool`"+` | _new_ | `+"`v0.1.0`"+` | [Release notes](https://ghe.mycompany.com/ops/tool/releases/tag/v0.1.0) |
+
+### Checksums
+
+| File | SHA256 |
+|------|--------|
+| `+"`bin/tool`"+` | `+"`1111`"+` |
+| `+"`modules/main.tf`"+` | `+"`3333`"+` |
+`, summary.String())
+}
+
+func TestWriteUpdateSummaryNoUpdates(t *testing.T) {
+	t.Parallel()
+
+	versions := &ManifestVersions{Entries: []ManifestEntryVersion{{Repo: "https://github.com/foo/bar", Destination: "bin", Version: "v1.1.0", Tag: "v1.1.0"}}}
+
+	var summary strings.Builder
+	require.NoError(t, WriteUpdateSummary(&summary, versions, versions))
+	assert.Equal(t, "## Dependency updates\n\nAll 1 manifest entries are up to date.\n", summary.String())
+}
@@ -0,0 +1,264 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Parse the given plain HTTP(S) URL, as passed in the Url option, and return it along with the name of the file it
+// points to, which is what the file is downloaded as
+func parseArtifactUrl(artifactUrl string) (*url.URL, string, error) {
+	parsed, err := url.Parse(artifactUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, "", fmt.Errorf("The URL %s must be a plain http:// or https:// URL.", artifactUrl)
+	}
+	name := path.Base(parsed.Path)
+	if name == "/" || name == "." || name == ".." {
+		return nil, "", fmt.Errorf("The URL %s must end in the name of the file it downloads.", artifactUrl)
+	}
+	return parsed, name, nil
+}
+
+// Return an error if options ask for anything that needs a repo as well as the Url option. A URL has no tags, source
+// files, or release, so it's verified only by checksum or package signature.
+func validateUrlOptions(options Options) error {
+	if _, _, err := parseArtifactUrl(options.Url); err != nil {
+		return err
+	}
+
+	switch {
+	case options.RepoUrl != "":
+		return fmt.Errorf("A repo and a URL can't both be downloaded in one fetch. Drop --repo to download %s.", options.Url)
+	case options.GitRef != "" || options.TagConstraint != "" || options.Channel != "" || options.TagPrefix != "" || options.CommitSha != "" || options.BranchName != "" || options.ResolveRef || options.ExpectedCommitSha != "":
+		return fmt.Errorf("A URL has no tags, commits, or branches to download, so --ref, --tag, --channel, --tag-prefix, --commit, --branch, --resolve-ref, and --expected-commit-sha can't be used with one.")
+	case len(options.SourcePaths) > 0 || len(options.SourceFiles) > 0:
+		return fmt.Errorf("Source paths and files can only be downloaded from a repo, not from a URL.")
+	case options.releaseAssetRegex() != "" || options.AutoAsset || options.AllReleaseAssets || options.MinAssetSize != "" || options.MaxAssetSize != "" || options.ReleaseAssetPickBy != "":
+		return fmt.Errorf("A URL downloads a single file, so release assets can't be picked from it.")
+	case options.VerifyWithRepoKey || options.CosignVerify || options.CheckImmutableTag || options.RequireImmutableTag || options.RequireSignedRef:
+		return fmt.Errorf("Only checksums and package signatures can be verified for a file downloaded from a URL, as the other checks need a repo.")
+	case options.JoinParts || options.DownloadConnections > 1:
+		return fmt.Errorf("A file downloaded from a URL can't be joined from parts or downloaded over several connections.")
+	case options.EmitSbomLite != "":
+		return fmt.Errorf("A manifest of what's downloaded can't be written for a file downloaded from a URL.")
+	case options.LocalDownloadPath == StdoutDownloadPath:
+		return fmt.Errorf("A file downloaded from a URL can't be streamed to stdout. Use --stdout to print it once it's verified.")
+	case options.AssetSink != nil:
+		return fmt.Errorf("A file downloaded from a URL can't be streamed to an AssetSink.")
+	}
+	return nil
+}
+
+// Download the file at the given URL to destPath. With noCache set, caches along the way are asked not to serve a
+// stored copy, as when a file whose checksum didn't match is downloaded again.
+func downloadUrl(ctx context.Context, fileUrl string, destPath string, withProgress bool, noCache bool) *FetchError {
+	request, err := http.NewRequestWithContext(ctx, "GET", fileUrl, nil)
+	if err != nil {
+		return wrapError(err)
+	}
+	if noCache {
+		request.Header.Set("Accept-Encoding", "identity")
+		request.Header.Set("Cache-Control", "no-cache")
+		request.Header.Set("Pragma", "no-cache")
+	}
+
+	resp, err := newHttpClient(ctx).Do(request)
+	if err != nil {
+		return wrapError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return newError(failedToDownloadFile, fmt.Sprintf("Failed to download file at the url %s. Received HTTP Response %d.", fileUrl, resp.StatusCode))
+	}
+	return writeResonseToDisk(resp, destPath, withProgress)
+}
+
+// Return the name a binary downloaded from the given URL is most likely named, such as terraform for
+// terraform_1.5.7_linux_amd64.zip, which is what it's looked for by when installing or unpacking it without the
+// BinaryName option, as a repo's name is for a release asset
+func artifactBinaryName(name string) string {
+	if i := strings.IndexAny(name, "_-."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// Download the file at the Url option, as Fetch does for a release asset: it's verified against the
+// ReleaseAssetChecksums and ReleaseAssetChecksumFile options, downloaded once more if its checksum doesn't match, and
+// then published, written to writer, installed, or unpacked, as the options ask. A ReleaseAssetChecksumFile that isn't
+// a URL itself is resolved relative to the Url option, as vendors usually publish checksums next to their downloads.
+func (fetcher *Fetcher) fetchFromUrl(ctx context.Context, writer io.Writer) (*Result, error) {
+	options := fetcher.options
+	logger := fetcher.logger
+
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	artifactUrl, name, err := parseArtifactUrl(options.Url)
+	if err != nil {
+		return nil, err
+	}
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+
+	if err := os.MkdirAll(options.LocalDownloadPath, 0755); err != nil {
+		return nil, err
+	}
+	assetPath, err := newDestRoot(options.LocalDownloadPath, options.FollowDestSymlinks).filePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	store := fetcher.assetStore
+	if store == nil && options.ArchiveCacheDir != "" {
+		store = openAssetStore(options.ArchiveCacheDir, options.LinkMode)
+	}
+
+	downloadStart := time.Now()
+	download := func(assetPath string, noCache bool) *FetchError {
+		return downloadUrl(ctx, options.Url, assetPath, options.WithProgress, noCache)
+	}
+	if err := placeOrDownloadArtifact(logger, store, options.Url, assetPath, download); err != nil {
+		return nil, err
+	}
+	result.Timings.Download = time.Since(downloadStart)
+
+	var checksums map[string]string
+	if options.ReleaseAssetChecksumFile != "" {
+		checksumFileUrl, err := artifactUrl.Parse(options.ReleaseAssetChecksumFile)
+		if err != nil {
+			return nil, fmt.Errorf("The checksum file %s is not a valid URL: %s", options.ReleaseAssetChecksumFile, err)
+		}
+		if checksums, err = loadChecksumFile(ctx, fetcher.repo, "", checksumFileUrl.String()); err != nil {
+			return nil, err
+		}
+	}
+	redownload := artifactRedownloader(store, func(string) string { return options.Url }, download)
+	if err := fetcher.useArtifacts(ctx, writer, result, []string{assetPath}, checksums, redownload, artifactBinaryName(name), extractOptions); err != nil {
+		return nil, err
+	}
+
+	result.Timings.Total = time.Since(start)
+	return result, nil
+}
+
+// Place the copy of the artifact stored under key in store at assetPath, if there is one, or else download it there
+// with download and add it to store. store may be nil.
+func placeOrDownloadArtifact(logger *logrus.Entry, store *assetStore, key string, assetPath string, download func(assetPath string, noCache bool) *FetchError) error {
+	if store != nil {
+		placed, err := store.place(key, assetPath)
+		if err != nil {
+			return err
+		}
+		if placed {
+			logger.Infof("Reused %s, which was already downloaded, at %s\n", key, assetPath)
+			return nil
+		}
+	}
+	logger.Infof("Downloading %s to %s\n", key, assetPath)
+	if fetchErr := download(assetPath, false); fetchErr != nil {
+		return fetchErr
+	}
+	if store != nil {
+		return store.add(key, assetPath)
+	}
+	return nil
+}
+
+// Return a releaseAssetRedownloader that downloads an artifact whose checksum doesn't match once more with download,
+// bypassing caches, and replaces its copy in store, if store isn't nil, under the key that keyOf returns for its path
+func artifactRedownloader(store *assetStore, keyOf func(assetPath string) string, download func(assetPath string, noCache bool) *FetchError) releaseAssetRedownloader {
+	return func(assetPath string) *FetchError {
+		// The file may be a hardlink or symlink to the copy in the asset store, which mustn't be overwritten in place
+		if err := os.Remove(assetPath); err != nil && !os.IsNotExist(err) {
+			return wrapError(err)
+		}
+		if fetchErr := download(assetPath, true); fetchErr != nil {
+			return fetchErr
+		}
+		if store != nil {
+			return wrapError(store.add(keyOf(assetPath), assetPath))
+		}
+		return nil
+	}
+}
+
+// Verify the given artifacts, which were downloaded from somewhere other than a GitHub release, against the
+// ReleaseAssetChecksums option, checksums (as loaded from the ReleaseAssetChecksumFile option, if it's set), and the
+// PackageSigningKey option, and then rename, record, publish, and use them as Fetch does release assets. binaryName is
+// what the binary in them is looked for by when there's no BinaryName option.
+func (fetcher *Fetcher) useArtifacts(ctx context.Context, writer io.Writer, result *Result, assetPaths []string, checksums map[string]string, redownload releaseAssetRedownloader, binaryName string, extractOptions ExtractOptions) error {
+	options := fetcher.options
+	logger := fetcher.logger
+	var err error
+
+	verifyStart := time.Now()
+	if len(options.ReleaseAssetChecksums) > 0 {
+		for _, assetPath := range assetPaths {
+			if fetchErr := verifyChecksumOfReleaseAssetWithRetry(logger, assetPath, options.ReleaseAssetChecksums, options.ReleaseAssetChecksumAlgo, options.WithProgress, options.UpgradeWeakChecksums, redownload); fetchErr != nil {
+				return fetchErr
+			}
+		}
+	}
+	if options.ReleaseAssetChecksumFile != "" {
+		if err := verifyReleaseAssetsWithChecksums(logger, checksums, options.ReleaseAssetChecksumFile, options.ReleaseAssetChecksumAlgo, assetPaths, options.WithProgress, options.UpgradeWeakChecksums, redownload); err != nil {
+			return err
+		}
+	}
+	if options.PackageSigningKey != "" {
+		if err := verifyPackageSignatures(logger, options.PackageSigningKey, assetPaths); err != nil {
+			return err
+		}
+	}
+	result.Timings.Verify = time.Since(verifyStart)
+
+	if len(options.Renames) > 0 {
+		if assetPaths, err = renameReleaseAssets(logger, assetPaths, newDestRoot(options.LocalDownloadPath, options.FollowDestSymlinks), options.Renames); err != nil {
+			return err
+		}
+	}
+	result.AssetPaths = assetPaths
+	for _, assetPath := range assetPaths {
+		file, err := fetcher.newFetchedFile(FetchedReleaseAsset, assetPath)
+		if err != nil {
+			return err
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	if options.PublishS3 != "" {
+		if result.PresignedUrls, err = publishToS3(ctx, logger, options, assetPaths, writer); err != nil {
+			return err
+		}
+	}
+	result.InstalledPath, err = fetcher.useReleaseAssets(ctx, writer, assetPaths, binaryName, extractOptions)
+	return err
+}
+
+// Return what fetchFromUrl would download, and where to, as Plan does for a repo
+func (fetcher *Fetcher) planUrl() (*FetchPlan, error) {
+	_, name, err := parseArtifactUrl(fetcher.options.Url)
+	if err != nil {
+		return nil, err
+	}
+	extractOptions, err := fetcher.extractOptions()
+	if err != nil {
+		return nil, err
+	}
+	destination := filepath.Join(fetcher.options.LocalDownloadPath, filepath.FromSlash(renamePath(name, extractOptions.Renames)))
+	return &FetchPlan{ReleaseAssets: []PlannedDownload{{Name: name, Url: fetcher.options.Url, Destination: destination}}}, nil
+}
@@ -0,0 +1,147 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArtifactUrl(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		url          string
+		expectedName string
+	}{
+		{"https://releases.hashicorp.com/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip", "terraform_1.5.7_linux_amd64.zip"},
+		{"http://example.com/tool?version=1", "tool"},
+		{"https://example.com/", ""},
+		{"ftp://example.com/tool", ""},
+		{"example.com/tool", ""},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.url, func(t *testing.T) {
+			t.Parallel()
+			_, name, err := parseArtifactUrl(tc.url)
+			if tc.expectedName == "" {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestValidateUrlOptions(t *testing.T) {
+	t.Parallel()
+
+	url := "https://example.com/tool.zip"
+	testCases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"checksum", Options{Url: url, ReleaseAssetChecksums: map[string]bool{"sha256:abcd": true}}, true},
+		{"unpack", Options{Url: url, Unpack: true, BinaryName: "tool"}, true},
+		{"repo", Options{Url: url, RepoUrl: "https://github.com/foo/bar"}, false},
+		{"tag", Options{Url: url, TagConstraint: "v1.0.0"}, false},
+		{"release-asset", Options{Url: url, ReleaseAsset: "tool"}, false},
+		{"source-path", Options{Url: url, SourcePaths: []string{"/"}}, false},
+		{"cosign", Options{Url: url, CosignVerify: true}, false},
+		{"stdout", Options{Url: url, LocalDownloadPath: StdoutDownloadPath}, false},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateUrlOptions(tc.options)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestArtifactBinaryName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "terraform", artifactBinaryName("terraform_1.5.7_linux_amd64.zip"))
+	assert.Equal(t, "kubectl", artifactBinaryName("kubectl"))
+	assert.Equal(t, "helm", artifactBinaryName("helm-v3.12.0-linux-amd64.tar.gz"))
+}
+
+func TestFetchFromUrl(t *testing.T) {
+	t.Parallel()
+
+	contents := []byte("terraform binary")
+	sum := sha256.Sum256(contents)
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip":
+			w.Write(contents)
+		case "/terraform/1.5.7/terraform_1.5.7_SHA256SUMS":
+			w.Write([]byte(checksum + "  terraform_1.5.7_linux_amd64.zip\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name         string
+		checksums    map[string]bool
+		checksumFile string
+		valid        bool
+	}{
+		{"checksum", map[string]bool{"sha256:" + checksum: true}, "", true},
+		{"checksum-file", nil, "terraform_1.5.7_SHA256SUMS", true},
+		{"checksum-mismatch", map[string]bool{"sha256:" + checksum[1:] + "0": true}, "", false},
+		{"checksum-file-missing", nil, "SHA256SUMS", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			destPath := t.TempDir()
+			fetcher, err := NewFetcher(Options{
+				Url:                      server.URL + "/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip",
+				ReleaseAssetChecksums:    tc.checksums,
+				ReleaseAssetChecksumFile: tc.checksumFile,
+				LocalDownloadPath:        destPath,
+			})
+			require.NoError(t, err)
+
+			result, err := fetcher.Fetch(context.Background(), io.Discard)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assetPath := filepath.Join(destPath, "terraform_1.5.7_linux_amd64.zip")
+			assert.Equal(t, []string{assetPath}, result.AssetPaths)
+			downloaded, err := ioutil.ReadFile(assetPath)
+			require.NoError(t, err)
+			assert.Equal(t, contents, downloaded)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package fetch
+
+import (
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The checksum of the first Offset bytes of a file
+type PrefixHash struct {
+	Offset   int64  `json:"offset"`
+	Checksum string `json:"checksum"`
+}
+
+// The checksums of a file's prefixes at regular offsets, published alongside it, so that a download can be checked
+// before all of it has been transferred. Its last prefix is the whole file.
+type PrefixHashManifest struct {
+	Algorithm string       `json:"algorithm"`
+	Prefixes  []PrefixHash `json:"prefixes"` // In order of Offset
+}
+
+// Compute the PrefixHashManifest of everything read from reader, with a prefix every interval bytes and one for the
+// whole of it, unless it's empty
+func NewPrefixHashManifest(reader io.Reader, algorithm string, interval int64) (PrefixHashManifest, error) {
+	manifest := PrefixHashManifest{Algorithm: algorithm}
+	if interval <= 0 {
+		return manifest, fmt.Errorf("The interval between the prefixes of a prefix hash manifest must be positive, but it was %d.", interval)
+	}
+	hasher, err := GetHasher(algorithm)
+	if err != nil {
+		return manifest, err
+	}
+
+	var offset int64
+	for {
+		n, err := io.CopyN(hasher, reader, interval)
+		offset += n
+		if n > 0 {
+			manifest.Prefixes = append(manifest.Prefixes, PrefixHash{Offset: offset, Checksum: hasherToString(hasher)})
+		}
+		if err == io.EOF {
+			return manifest, nil
+		}
+		if err != nil {
+			return manifest, err
+		}
+	}
+}
+
+// Return an error if the manifest's algorithm isn't supported or its offsets aren't positive and in order
+func (manifest PrefixHashManifest) validate() error {
+	if _, err := GetHasher(manifest.Algorithm); err != nil {
+		return err
+	}
+	var previous int64
+	for _, prefix := range manifest.Prefixes {
+		if prefix.Offset <= previous {
+			return fmt.Errorf("The offsets of a prefix hash manifest must be positive and increase, but %d follows %d.", prefix.Offset, previous)
+		}
+		previous = prefix.Offset
+	}
+	return nil
+}
+
+// What a VerifyingReader checks the content read through it against
+type VerifyOptions struct {
+	MaxSize   int64               // If positive, fail as soon as more than this many bytes are read
+	Checksums map[string]bool     // If set, the whole content must match one of these, as the ReleaseAssetChecksums option must
+	Algorithm string              // The algorithm of the Checksums that aren't prefixed with one
+	Prefixes  *PrefixHashManifest // If set, fail as soon as a prefix of the content doesn't match its checksum in it
+	Logger    *logrus.Entry       // What the checksums are logged with. If nil, GetProjectLogger() is used.
+}
+
+// A VerifyingReader wraps a reader, such as the body of a download, and fails as soon as what's read from it breaks one
+// of its VerifyOptions: once more than MaxSize bytes are read, once a prefix doesn't match the prefix hash manifest, or
+// at the end, if the whole content doesn't match the checksums. Content past MaxSize or in a prefix that doesn't match
+// is never returned from Read: with a prefix hash manifest, each prefix is read in full and held in memory until it's
+// verified, so the interval between its offsets bounds how much is held at once. The whole content's checksums can only
+// be checked at the end, though, so without a manifest, everything but the last Read has been returned by the time they
+// fail. Closing the reader when Read fails aborts the transfer before any more of it is downloaded. Create one with
+// NewVerifyingReader.
+type VerifyingReader struct {
+	reader  io.Reader
+	name    string
+	options VerifyOptions
+
+	whole        *streamHasher
+	prefixHasher hash.Hash
+	nextPrefix   int
+	read         int64
+	err          error
+
+	buffer   []byte // Holds each prefix while it's read and verified
+	verified []byte // The part of buffer that was verified, but not returned from Read yet
+}
+
+// Return a VerifyingReader that checks what's read from reader against options. name identifies the content in errors.
+func NewVerifyingReader(reader io.Reader, name string, options VerifyOptions) (*VerifyingReader, error) {
+	verifier := &VerifyingReader{reader: reader, name: name, options: options}
+	if len(options.Checksums) > 0 {
+		whole, err := newStreamHasher(options.Checksums, options.Algorithm, false)
+		if err != nil {
+			return nil, err
+		}
+		whole.destination = "whatever read it"
+		verifier.whole = whole
+	}
+	if options.Prefixes != nil {
+		if err := options.Prefixes.validate(); err != nil {
+			return nil, err
+		}
+		verifier.prefixHasher, _ = GetHasher(options.Prefixes.Algorithm)
+	}
+	return verifier, nil
+}
+
+// The number of bytes read from the wrapped reader so far
+func (verifier *VerifyingReader) BytesRead() int64 {
+	return verifier.read
+}
+
+func (verifier *VerifyingReader) Read(p []byte) (int, error) {
+	if len(verifier.verified) == 0 && verifier.err == nil {
+		if prefixes := verifier.options.Prefixes; prefixes == nil || verifier.nextPrefix >= len(prefixes.Prefixes) {
+			return verifier.readUnbuffered(p)
+		}
+		verifier.err = verifier.readPrefix()
+	}
+	if len(verifier.verified) > 0 {
+		n := copy(p, verifier.verified)
+		verifier.verified = verifier.verified[n:]
+		return n, nil
+	}
+	return 0, verifier.err
+}
+
+// Read the rest of the next prefix into the buffer and check it, so that none of it is returned until all of it has
+// been verified. Returns an error if it doesn't match, or the content ends before it does.
+func (verifier *VerifyingReader) readPrefix() error {
+	size := verifier.options.Prefixes.Prefixes[verifier.nextPrefix].Offset - verifier.read
+	// Never read more than one byte past the maximum size, which is enough to tell that it was exceeded
+	if maxSize := verifier.options.MaxSize; maxSize > 0 && size > maxSize-verifier.read+1 {
+		size = maxSize - verifier.read + 1
+	}
+	if int64(cap(verifier.buffer)) < size {
+		verifier.buffer = make([]byte, size)
+	}
+	content := verifier.buffer[:size]
+
+	n, err := io.ReadFull(verifier.reader, content)
+	if n > 0 {
+		if failure := verifier.check(content[:n]); failure != nil {
+			return failure
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// The content ended part way through the prefix, which checkEnd always reports
+		return verifier.checkEnd()
+	}
+	if err != nil {
+		return err
+	}
+	verifier.verified = content
+	return nil
+}
+
+// Read into p straight from the wrapped reader, once there are no prefixes left to check
+func (verifier *VerifyingReader) readUnbuffered(p []byte) (int, error) {
+	// Never read more than one byte past the maximum size, which is enough to tell that it was exceeded
+	if maxSize := verifier.options.MaxSize; maxSize > 0 && int64(len(p)) > maxSize-verifier.read+1 {
+		p = p[:maxSize-verifier.read+1]
+	}
+
+	n, err := verifier.reader.Read(p)
+	if n > 0 {
+		if failure := verifier.check(p[:n]); failure != nil {
+			verifier.err = failure
+			return 0, failure
+		}
+	}
+	if err == io.EOF {
+		if failure := verifier.checkEnd(); failure != nil {
+			verifier.err = failure
+			return 0, failure
+		}
+	}
+	return n, err
+}
+
+// Hash the given content, which follows everything read so far, and check the size and the prefix it ends
+func (verifier *VerifyingReader) check(content []byte) error {
+	verifier.read += int64(len(content))
+	if maxSize := verifier.options.MaxSize; maxSize > 0 && verifier.read > maxSize {
+		return fmt.Errorf("%s is larger than the maximum size of %d bytes, so the transfer was aborted.", verifier.name, maxSize)
+	}
+	if verifier.whole != nil {
+		verifier.whole.Write(content)
+	}
+
+	prefixes := verifier.options.Prefixes
+	if prefixes == nil {
+		return nil
+	}
+	if verifier.nextPrefix >= len(prefixes.Prefixes) {
+		return newError(checksumDoesNotMatch, fmt.Sprintf("%s is longer than the %d bytes its prefix hash manifest covers, so the transfer was aborted.", verifier.name, verifier.read-int64(len(content))))
+	}
+	verifier.prefixHasher.Write(content)
+	prefix := prefixes.Prefixes[verifier.nextPrefix]
+	if verifier.read < prefix.Offset {
+		return nil
+	}
+	verifier.nextPrefix++
+	_, expected := ParseChecksum(prefix.Checksum, prefixes.Algorithm)
+	if computed := hasherToString(verifier.prefixHasher); computed != expected {
+		return newError(checksumDoesNotMatch, fmt.Sprintf("The %s checksum of the first %d bytes of %s should be %s, but it's %s, so the transfer was aborted. This means that someone may have replaced it with a potentially dangerous file.", prefixes.Algorithm, prefix.Offset, verifier.name, expected, computed))
+	}
+	return nil
+}
+
+// Check that the content didn't end before the last prefix, and that all of it matches the checksums
+func (verifier *VerifyingReader) checkEnd() error {
+	if prefixes := verifier.options.Prefixes; prefixes != nil && verifier.nextPrefix < len(prefixes.Prefixes) {
+		expectedSize := prefixes.Prefixes[len(prefixes.Prefixes)-1].Offset
+		return newError(checksumDoesNotMatch, fmt.Sprintf("%s ended after %d bytes, but its prefix hash manifest expects %d.", verifier.name, verifier.read, expectedSize))
+	}
+	if verifier.whole != nil {
+		logger := verifier.options.Logger
+		if logger == nil {
+			logger = GetProjectLogger()
+		}
+		if fetchErr := verifier.whole.verify(logger, verifier.name); fetchErr != nil {
+			return fetchErr
+		}
+	}
+	return nil
+}
+
+// Close the wrapped reader, if it can be closed, which aborts a transfer that failed verification
+func (verifier *VerifyingReader) Close() error {
+	if closer, ok := verifier.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package fetch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPrefixHashManifest(t *testing.T) {
+	t.Parallel()
+
+	manifest, err := NewPrefixHashManifest(strings.NewReader("0123456789"), "sha256", 4)
+	require.NoError(t, err)
+	assert.Equal(t, PrefixHashManifest{Algorithm: "sha256", Prefixes: []PrefixHash{
+		{Offset: 4, Checksum: sha256Hex([]byte("0123"))},
+		{Offset: 8, Checksum: sha256Hex([]byte("01234567"))},
+		{Offset: 10, Checksum: sha256Hex([]byte("0123456789"))},
+	}}, manifest)
+
+	_, err = NewPrefixHashManifest(strings.NewReader("0123456789"), "sha256", 0)
+	assert.Error(t, err)
+}
+
+func TestVerifyingReader(t *testing.T) {
+	t.Parallel()
+
+	original := strings.Repeat("a", 100) + strings.Repeat("b", 100) + strings.Repeat("c", 100)
+	manifest, err := NewPrefixHashManifest(strings.NewReader(original), "sha256", 100)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		content       string
+		options       VerifyOptions
+		expectedError string
+		maxRead       int // The most bytes the verifier may return before it fails
+	}{
+		{"valid", original, VerifyOptions{MaxSize: 300, Checksums: map[string]bool{sha256Hex([]byte(original)): true}, Algorithm: "sha256", Prefixes: &manifest}, "", 300},
+		{"checksum-mismatch", original + "d", VerifyOptions{Checksums: map[string]bool{sha256Hex([]byte(original)): true}, Algorithm: "sha256"}, "Expected to checksum value to be one of", 301},
+		{"too-large", original, VerifyOptions{MaxSize: 150}, "larger than the maximum size of 150 bytes", 150},
+		{"prefix-mismatch", original[:150] + "x" + original[151:], VerifyOptions{Prefixes: &manifest}, "checksum of the first 200 bytes", 100},
+		{"truncated", original[:250], VerifyOptions{Prefixes: &manifest}, "ended after 250 bytes, but its prefix hash manifest expects 300", 250},
+		{"too-long", original + "d", VerifyOptions{Prefixes: &manifest}, "longer than the 300 bytes its prefix hash manifest covers", 300},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := strings.NewReader(tc.content)
+			verifier, err := NewVerifyingReader(source, "tool.zip", tc.options)
+			require.NoError(t, err)
+
+			var output bytes.Buffer
+			_, err = output.ReadFrom(verifier)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tc.content, output.String())
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+			assert.LessOrEqual(t, output.Len(), tc.maxRead)
+
+			// A failed verifier keeps failing, rather than handing out any more of the content
+			_, err = ioutil.ReadAll(verifier)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyingReaderAbortsEarly(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("x", 1<<20)
+	tampered := "y" + content[1:]
+	manifest, err := NewPrefixHashManifest(strings.NewReader(content), "sha256", 64*1024)
+	require.NoError(t, err)
+
+	source := strings.NewReader(tampered)
+	verifier, err := NewVerifyingReader(source, "tool.zip", VerifyOptions{Prefixes: &manifest})
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(verifier)
+	require.Error(t, err)
+
+	// Only the first prefix should have been read from the source before the mismatch was caught
+	assert.Equal(t, int64(64*1024), verifier.BytesRead())
+	assert.Equal(t, len(tampered)-64*1024, source.Len())
+}
+
+func TestVerifyingReaderHoldsBackUnverifiedPrefixes(t *testing.T) {
+	t.Parallel()
+
+	original := strings.Repeat("a", 100) + strings.Repeat("b", 100)
+	tampered := original[:199] + "x"
+	manifest, err := NewPrefixHashManifest(strings.NewReader(original), "sha256", 100)
+	require.NoError(t, err)
+
+	// Reading a byte at a time, from a source that hands out a few bytes at a time, must still return nothing of the
+	// second prefix, as none of it can be verified until the last of it is read
+	verifier, err := NewVerifyingReader(iotest.HalfReader(strings.NewReader(tampered)), "tool.zip", VerifyOptions{Prefixes: &manifest})
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(iotest.OneByteReader(verifier))
+	require.Error(t, err)
+	assert.Equal(t, original[:100], string(output))
+
+	verifier, err = NewVerifyingReader(iotest.HalfReader(strings.NewReader(original)), "tool.zip", VerifyOptions{Prefixes: &manifest})
+	require.NoError(t, err)
+	output, err = ioutil.ReadAll(iotest.OneByteReader(verifier))
+	require.NoError(t, err)
+	assert.Equal(t, original, string(output))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const optionTargetRepo = "target-repo"
+const optionTargetTag = "target-tag"
+const optionTargetGithubToken = "target-github-oauth-token"
+
+// Create the "fetch republish" command, which copies release assets from a release in one repo to a release in another
+func createRepublishCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "republish",
+		Usage:     "Download the release assets of a release in one GitHub repo and upload them, along with a SHA256SUMS file, to a release in another repo.",
+		UsageText: "fetch republish --repo <source-repo> --tag <tag> --target-repo <target-repo> [options]",
+		Action:    runRepublishWrapper,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  optionRepo,
+				Usage: "Required. Fully qualified URL of the GitHub repo to copy release assets from.",
+			},
+			&cli.StringFlag{
+				Name:  optionTag,
+				Usage: "Required. The git tag of the release to copy, expressed with Version Constraint Operators.",
+			},
+			&cli.StringFlag{
+				Name:  optionReleaseAsset,
+				Value: ".*",
+				Usage: "A regular expression matching the names of the release assets to copy. Defaults to all assets.",
+			},
+			&cli.StringFlag{
+				Name:    optionGithubToken,
+				Usage:   "A GitHub Personal Access Token used to read from the source repo. Populate by setting env var",
+				EnvVars: []string{envVarGithubToken},
+			},
+			&cli.StringFlag{
+				Name:    optionTokenCommand,
+				Usage:   "A command that prints the GitHub token used to read from the source repo, such as \"gh auth token\", which is run with the shell.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionTokenCommand)},
+			},
+			&cli.StringFlag{
+				Name:    optionGithubTokenFile,
+				Aliases: []string{"token-file"},
+				Usage:   "A file to read the GitHub token to read from the source repo with from, such as a secret mounted as a file, or \"-\" to read it from stdin.\n\tTakes precedence over --github-oauth-token.",
+				EnvVars: []string{optionEnvVar(optionGithubTokenFile)},
+			},
+			&cli.StringSliceFlag{
+				Name:    optionCredentialFallback,
+				Usage:   "Where to look for the GitHub token to read from the source repo with if none is given: \"netrc\" (~/.netrc, or $NETRC), \"git\"\n\t(git credential fill), or both, in the order given.",
+				EnvVars: []string{optionEnvVar(optionCredentialFallback)},
+			},
+			&cli.StringFlag{
+				Name:  optionTargetRepo,
+				Usage: "Required. Fully qualified URL of the GitHub repo to upload the release assets to.",
+			},
+			&cli.StringFlag{
+				Name:  optionTargetTag,
+				Usage: "The git tag of the release in the target repo to upload to. The release is created if it\n\tdoesn't exist. Defaults to the tag resolved from --tag.",
+			},
+			&cli.StringFlag{
+				Name:  optionTargetGithubToken,
+				Usage: "A GitHub Personal Access Token used to write to the target repo. Defaults to --github-oauth-token.",
+			},
+			&cli.StringFlag{
+				Name:  optionGithubAPIVersion,
+				Value: "v3",
+				Usage: "The api version of the GitHub instance. If left blank, v3 will be used.\n\tThis will only be used if the repo url is not a github.com url.",
+			},
+		}, connectionFlags()...),
+	}
+}
+
+func runRepublishWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runRepublish(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch republish" command
+func runRepublish(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	// The target repo is written to with the source repo's token by default, so the token is needed to validate
+	githubToken, err := resolveGithubToken(ctx, c, c.String(optionRepo), c.String(optionGithubToken))
+	if err != nil {
+		return err
+	}
+	options := parseRepublishOptions(c, githubToken)
+	if err := validateRepublishOptions(options); err != nil {
+		return err
+	}
+
+	return fetch.Republish(ctx, logger, options)
+}
+
+func parseRepublishOptions(c *cli.Context, githubToken string) fetch.RepublishOptions {
+	targetToken := c.String(optionTargetGithubToken)
+	if targetToken == "" {
+		targetToken = githubToken
+	}
+
+	return fetch.RepublishOptions{
+		Source: fetch.Options{
+			RepoUrl:          c.String(optionRepo),
+			TagConstraint:    c.String(optionTag),
+			GithubToken:      githubToken,
+			ReleaseAsset:     c.String(optionReleaseAsset),
+			GithubApiVersion: c.String(optionGithubAPIVersion),
+			Connection:       parseConnectionOptions(c),
+			Locale:           resolveLocale(c),
+		},
+		TargetRepoUrl:     c.String(optionTargetRepo),
+		TargetTag:         c.String(optionTargetTag),
+		TargetGithubToken: targetToken,
+	}
+}
+
+func validateRepublishOptions(options fetch.RepublishOptions) error {
+	if options.Source.RepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch republish --help\" for full usage info.", optionRepo)
+	}
+
+	if options.Source.TagConstraint == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch republish --help\" for full usage info.", optionTag)
+	}
+
+	if options.TargetRepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch republish --help\" for full usage info.", optionTargetRepo)
+	}
+
+	if options.TargetGithubToken == "" {
+		return fmt.Errorf("A GitHub token with write access to the target repo is required. Set --%s, --%s, --%s, or --%s.", optionTargetGithubToken, optionGithubToken, optionTokenCommand, optionGithubTokenFile)
+	}
+
+	return nil
+}
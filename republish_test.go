@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRepublishOptions(t *testing.T) {
+	t.Parallel()
+
+	valid := fetch.RepublishOptions{
+		Source:            fetch.Options{RepoUrl: "https://github.com/foo/bar", TagConstraint: "v0.0.1"},
+		TargetRepoUrl:     "https://github.com/foo/bar-mirror",
+		TargetGithubToken: "token",
+	}
+	assert.NoError(t, validateRepublishOptions(valid))
+
+	noTarget := valid
+	noTarget.TargetRepoUrl = ""
+	assert.Error(t, validateRepublishOptions(noTarget))
+
+	noToken := valid
+	noToken.TargetGithubToken = ""
+	assert.Error(t, validateRepublishOptions(noToken))
+
+	noTag := valid
+	noTag.Source.TagConstraint = ""
+	assert.Error(t, validateRepublishOptions(noTag))
+}
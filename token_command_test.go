@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestResolveGithubToken(t *testing.T) {
+	t.Parallel()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"token-only", []string{"fetch", "--github-oauth-token=flag-token"}, "flag-token"},
+		{"command-only", []string{"fetch", "--token-command=echo command-token"}, "command-token"},
+		{"command-takes-precedence", []string{"fetch", "--github-oauth-token=flag-token", "--token-command=echo command-token"}, "command-token"},
+		{"file-takes-precedence", []string{"fetch", "--github-oauth-token=flag-token", "--github-oauth-token-file=" + tokenFile}, "file-token"},
+		{"token-file-alias", []string{"fetch", "--token-file=" + tokenFile}, "file-token"},
+		{"stdin", []string{"fetch", "--token-file=-"}, "stdin-token"},
+	}
+
+	for _, tc := range testCases {
+		// The following is necessary to make sure tc's values don't get updated due to concurrency
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var token string
+			app := CreateFetchCli(VERSION, nil, nil)
+			app.Reader = strings.NewReader("stdin-token\n")
+			app.Action = func(c *cli.Context) error {
+				var err error
+				token, err = resolveGithubToken(context.Background(), c, "https://github.com/gruntwork-io/fetch", parseOptions(c, fetch.GetProjectLogger()).GithubToken)
+				return err
+			}
+			require.NoError(t, app.Run(tc.args))
+			assert.Equal(t, tc.expected, token)
+		})
+	}
+}
+
+// This test sets env vars, so it can't run in parallel with other tests
+func TestResolveGithubTokenCredentialFallback(t *testing.T) {
+	netrcPath := filepath.Join(t.TempDir(), ".netrc")
+	require.NoError(t, ioutil.WriteFile(netrcPath, []byte("machine github.com password netrc-token\nmachine ghe.mycompany.com password ghes-token\n"), 0600))
+	t.Setenv("NETRC", netrcPath)
+	t.Setenv(envVarGithubToken, "")
+
+	testCases := []struct {
+		name      string
+		args      []string
+		repoUrl   string
+		expected  string
+		expectErr bool
+	}{
+		{"no-fallback", []string{"fetch"}, "https://github.com/foo/bar", "", false},
+		{"netrc", []string{"fetch", "--credential-fallback=netrc"}, "https://github.com/foo/bar", "netrc-token", false},
+		{"netrc-ghes", []string{"fetch", "--credential-fallback=netrc"}, "https://www.ghe.mycompany.com/foo/bar", "ghes-token", false},
+		{"token-takes-precedence", []string{"fetch", "--github-oauth-token=flag-token", "--credential-fallback=netrc"}, "https://github.com/foo/bar", "flag-token", false},
+		{"command-takes-precedence", []string{"fetch", "--token-command=echo command-token", "--credential-fallback=netrc"}, "https://github.com/foo/bar", "command-token", false},
+		{"unknown-source", []string{"fetch", "--credential-fallback=keychain"}, "https://github.com/foo/bar", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var token string
+			app := CreateFetchCli(VERSION, nil, nil)
+			app.Action = func(c *cli.Context) error {
+				var err error
+				token, err = resolveGithubToken(context.Background(), c, tc.repoUrl, c.String(optionGithubToken))
+				return err
+			}
+			err := app.Run(tc.args)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, token)
+		})
+	}
+}
+
+func TestResolveGithubTokenCommandAndFileConflict(t *testing.T) {
+	t.Parallel()
+
+	app := CreateFetchCli(VERSION, nil, nil)
+	app.Action = func(c *cli.Context) error {
+		_, err := resolveGithubToken(context.Background(), c, "https://github.com/gruntwork-io/fetch", "")
+		return err
+	}
+	assert.Error(t, app.Run([]string{"fetch", "--token-command=echo command-token", "--token-file=-"}))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Create the "fetch verify" command, which verifies release assets that are already on disk against the checksums,
+// checksum file, and signatures of their release, without downloading them
+func createVerifyCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     optionRepo,
+			Category: flagCategorySelection,
+			Usage:    "Required. Fully qualified URL of the GitHub repo.",
+		},
+		&cli.StringFlag{
+			Name:     optionTag,
+			Category: flagCategorySelection,
+			Usage:    "Required. The git tag of the release the assets are from, expressed with Version Constraint Operators.",
+		},
+	}
+	flags = append(flags, fetchFlagsNamed(
+		optionLooseSemver,
+		optionTagPrefix,
+		optionIncludePrereleases,
+		optionIncludeDrafts,
+		optionReleaseAssetChecksum,
+		optionReleaseAssetChecksumAlgo,
+		optionReleaseAssetChecksumFile,
+		optionUpgradeWeakChecksums,
+		optionVerifyWithRepoKey,
+		optionCosignVerify,
+		optionCosignCertificateIdentity,
+		optionCosignCertificateOidcIssuer,
+		optionCosignFulcioRoot,
+		optionCosignRekorPublicKey,
+		optionCosignRekorUrl,
+		optionPackageSigningKey,
+		optionGithubToken,
+		optionTokenCommand,
+		optionGithubTokenFile,
+		optionCredentialFallback,
+		optionGithubAPIVersion,
+		optionGhesVersion,
+	)...)
+	flags = append(flags, connectionFlags()...)
+
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Verify release assets that are already on disk against the checksums and signatures of their release.",
+		UsageText: "fetch verify --repo <repo> --tag <tag> [--release-asset-checksum <checksum> | --release-asset-checksum-file <file> | --verify-with-repo-key | --cosign-verify] [options] <asset-path>...",
+		Action:    runVerifyWrapper,
+		Flags:     flags,
+	}
+}
+
+func runVerifyWrapper(c *cli.Context) error {
+	logger := fetch.GetProjectLogger()
+	ctx, stop := newInterruptibleContext()
+	defer stop()
+
+	err := runVerify(ctx, c, logger)
+	exitOnError(ctx, logger, err)
+	return nil
+}
+
+// Run the "fetch verify" command
+func runVerify(ctx context.Context, c *cli.Context, logger *logrus.Entry) error {
+	options := parseVerifyOptions(c, logger)
+	assetPaths := c.Args().Slice()
+	if err := validateVerifyOptions(options, assetPaths); err != nil {
+		return err
+	}
+	token, err := resolveGithubToken(ctx, c, options.RepoUrl, options.GithubToken)
+	if err != nil {
+		return err
+	}
+	options.GithubToken = token
+
+	fetcher, err := fetch.NewFetcher(options)
+	if err != nil {
+		return err
+	}
+	resolvedTag, err := fetcher.ResolveTag(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fetcher.VerifyLocalReleaseAssets(ctx, resolvedTag.Tag, assetPaths); err != nil {
+		return err
+	}
+
+	logger.Infof("Verified %d release asset(s) against release %s.\n", len(assetPaths), resolvedTag.Tag)
+	return nil
+}
+
+func parseVerifyOptions(c *cli.Context, logger *logrus.Entry) fetch.Options {
+	assetChecksums := c.StringSlice(optionReleaseAssetChecksum)
+	assetChecksumMap := make(map[string]bool, len(assetChecksums))
+	for _, assetChecksum := range assetChecksums {
+		assetChecksumMap[assetChecksum] = true
+	}
+
+	return fetch.Options{
+		RepoUrl:                  c.String(optionRepo),
+		TagConstraint:            c.String(optionTag),
+		LooseSemver:              c.Bool(optionLooseSemver),
+		TagPrefix:                c.String(optionTagPrefix),
+		IncludePrereleases:       c.Bool(optionIncludePrereleases),
+		IncludeDrafts:            c.Bool(optionIncludeDrafts),
+		GithubToken:              c.String(optionGithubToken),
+		GithubApiVersion:         c.String(optionGithubAPIVersion),
+		GhesVersion:              c.String(optionGhesVersion),
+		Connection:               parseConnectionOptions(c),
+		ReleaseAssetChecksums:    assetChecksumMap,
+		ReleaseAssetChecksumAlgo: c.String(optionReleaseAssetChecksumAlgo),
+		ReleaseAssetChecksumFile: c.String(optionReleaseAssetChecksumFile),
+		UpgradeWeakChecksums:     c.Bool(optionUpgradeWeakChecksums),
+		VerifyWithRepoKey:        c.Bool(optionVerifyWithRepoKey),
+		CosignVerify:             c.Bool(optionCosignVerify),
+		CosignVerifyOptions: fetch.CosignVerifyOptions{
+			CertificateIdentity:   c.String(optionCosignCertificateIdentity),
+			CertificateOidcIssuer: c.String(optionCosignCertificateOidcIssuer),
+			FulcioRootPath:        c.String(optionCosignFulcioRoot),
+			RekorPublicKeyPath:    c.String(optionCosignRekorPublicKey),
+			RekorUrl:              c.String(optionCosignRekorUrl),
+		},
+		PackageSigningKey: c.String(optionPackageSigningKey),
+		Logger:            logger,
+		Locale:            resolveLocale(c),
+	}
+}
+
+func validateVerifyOptions(options fetch.Options, assetPaths []string) error {
+	if options.RepoUrl == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch verify --help\" for full usage info.", optionRepo)
+	}
+	if options.TagConstraint == "" {
+		return fmt.Errorf("The --%s flag is required. Run \"fetch verify --help\" for full usage info.", optionTag)
+	}
+	if len(assetPaths) == 0 {
+		return fmt.Errorf("Missing required arguments specifying the paths of the release assets to verify. Run \"fetch verify --help\" for full usage info.")
+	}
+
+	if len(options.ReleaseAssetChecksums) == 0 && options.ReleaseAssetChecksumFile == "" && !options.VerifyWithRepoKey && !options.CosignVerify && options.PackageSigningKey == "" {
+		return fmt.Errorf("You must specify at least one of --%s, --%s, --%s, --%s, or --%s. Run \"fetch verify --help\" for full usage info.", optionReleaseAssetChecksum, optionReleaseAssetChecksumFile, optionVerifyWithRepoKey, optionCosignVerify, optionPackageSigningKey)
+	}
+	if options.PackageSigningKey != "" {
+		if err := fetch.ValidatePackageSigningKey(options.PackageSigningKey); err != nil {
+			return err
+		}
+	}
+	if options.CosignVerify && (options.CosignVerifyOptions.CertificateIdentity == "" || options.CosignVerifyOptions.CertificateOidcIssuer == "") {
+		return fmt.Errorf("The --%s flag requires both --%s and --%s to be set. Run \"fetch verify --help\" for full usage info.", optionCosignVerify, optionCosignCertificateIdentity, optionCosignCertificateOidcIssuer)
+	}
+
+	if _, err := fetch.ParseGhesVersion(options.GhesVersion); err != nil {
+		return err
+	}
+	if err := validateConnectionOptions(options.Connection); err != nil {
+		return err
+	}
+	return validateReleaseAssetChecksums(options)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/fetch/pkg/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVerifyOptions(t *testing.T) {
+	t.Parallel()
+
+	assetPaths := []string{"/tmp/tool_linux_amd64"}
+	valid := fetch.Options{
+		RepoUrl:                  "https://github.com/foo/bar",
+		TagConstraint:            "v0.0.1",
+		ReleaseAssetChecksumFile: "SHA256SUMS",
+	}
+	assert.NoError(t, validateVerifyOptions(valid, assetPaths))
+
+	assert.Error(t, validateVerifyOptions(valid, nil))
+
+	noRepo := valid
+	noRepo.RepoUrl = ""
+	assert.Error(t, validateVerifyOptions(noRepo, assetPaths))
+
+	noTag := valid
+	noTag.TagConstraint = ""
+	assert.Error(t, validateVerifyOptions(noTag, assetPaths))
+
+	nothingToVerify := valid
+	nothingToVerify.ReleaseAssetChecksumFile = ""
+	assert.Error(t, validateVerifyOptions(nothingToVerify, assetPaths))
+
+	checksumWithoutAlgo := nothingToVerify
+	checksumWithoutAlgo.ReleaseAssetChecksums = map[string]bool{"abc123": true}
+	assert.Error(t, validateVerifyOptions(checksumWithoutAlgo, assetPaths))
+
+	checksumWithAlgo := checksumWithoutAlgo
+	checksumWithAlgo.ReleaseAssetChecksumAlgo = "sha256"
+	assert.NoError(t, validateVerifyOptions(checksumWithAlgo, assetPaths))
+
+	cosignWithoutIdentity := nothingToVerify
+	cosignWithoutIdentity.CosignVerify = true
+	assert.Error(t, validateVerifyOptions(cosignWithoutIdentity, assetPaths))
+}